@@ -17,31 +17,118 @@ import (
 	c "github.com/RedHatInsights/platform-receptor-controller/internal/controller"
 	"github.com/RedHatInsights/platform-receptor-controller/internal/controller/api"
 	"github.com/RedHatInsights/platform-receptor-controller/internal/controller/ws"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/middlewares"
 	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/openmetrics"
 	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/queue"
 	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/utils"
 	"github.com/redhatinsights/platform-go-middlewares/request_id"
 
 	"github.com/gorilla/mux"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	kafka "github.com/segmentio/kafka-go"
+
+	// The database/sql driver of the SQL outbox store
+	_ "github.com/lib/pq"
 )
 
 const (
 	OPENAPI_SPEC_FILE = "/opt/app-root/src/api/api.spec.file"
 )
 
-func closeConnections(cm c.ConnectionLocator, wg *sync.WaitGroup, timeout time.Duration) {
+func closeConnections(cm c.ConnectionLocator, wg *sync.WaitGroup, drainTimeout time.Duration, timeout time.Duration) {
 	defer wg.Done()
+	drainConnections(cm, drainTimeout)
 	connections := cm.GetAllConnections()
 	for _, conn := range connections {
 		for _, client := range conn {
-			client.Close(context.TODO())
+			client.Close(c.WithCloseReason(context.TODO(), c.CLOSE_REASON_SHUTDOWN))
 		}
 	}
 	time.Sleep(timeout)
 }
 
-func configureConnectionRegistrar(cfg *config.Config, localCM c.ConnectionRegistrar) c.ConnectionRegistrar {
+// drainConnections gives the nodes up to timeout to disconnect on their own
+// before the remaining connections are closed.  The listener has already been
+// closed, so a process that shares the address (see LISTEN_REUSE_PORT) accepts
+// the nodes that reconnect.
+func drainConnections(cm c.ConnectionLocator, timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+
+	logger.Log.Infof("Draining the connections for up to %s", timeout)
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		remaining := 0
+		for _, conn := range cm.GetAllConnections() {
+			remaining += len(conn)
+		}
+
+		if remaining == 0 {
+			logger.Log.Info("All connections have been drained")
+			return
+		}
+
+		time.Sleep(time.Second)
+	}
+
+	logger.Log.Info("Timed out draining the connections...closing the remaining connections")
+}
+
+// startInventoryExporter periodically produces the connections registered
+// with this pod to the inventory topic.  The pod is identified by its ip
+// address, which is also how the pod is identified in Redis.
+func startInventoryExporter(ctx context.Context, cfg *config.Config, cm c.ConnectionLocator) *kafka.Writer {
+	ipAddr := utils.GetIPAddress()
+	if ipAddr == nil {
+		logger.Log.Fatal("Unable to determine IP address")
+	}
+
+	w := queue.StartProducer(&queue.ProducerConfig{
+		Brokers:      cfg.KafkaBrokers,
+		Topic:        cfg.KafkaInventoryTopic,
+		BatchSize:    cfg.KafkaResponsesBatchSize,
+		BatchBytes:   cfg.KafkaResponsesBatchBytes,
+		Batching:     cfg.KafkaResponsesBatching,
+		BatchTimeout: cfg.KafkaResponsesBatchTimeout,
+		Compression:  cfg.KafkaResponsesCompression,
+		WriteTimeout: cfg.KafkaResponsesWriteTimeout,
+	})
+
+	go c.NewInventoryExporter(w, cm, ipAddr.String(), cfg).Run(ctx)
+
+	return w
+}
+
+// startDeliveryReceiptProducer produces a receipt to the delivery receipts
+// topic for each message delivered to (or acknowledged by) a node and for each
+// message that could not be delivered
+func startDeliveryReceiptProducer(cfg *config.Config) (*c.DeliveryReceiptProducer, *kafka.Writer) {
+	ipAddr := utils.GetIPAddress()
+	if ipAddr == nil {
+		logger.Log.Fatal("Unable to determine IP address")
+	}
+
+	w := queue.StartProducer(&queue.ProducerConfig{
+		Brokers:      cfg.KafkaBrokers,
+		Topic:        cfg.KafkaDeliveryReceiptsTopic,
+		BatchSize:    cfg.KafkaResponsesBatchSize,
+		BatchBytes:   cfg.KafkaResponsesBatchBytes,
+		Batching:     cfg.KafkaResponsesBatching,
+		BatchTimeout: cfg.KafkaResponsesBatchTimeout,
+		Compression:  cfg.KafkaResponsesCompression,
+		WriteTimeout: cfg.KafkaResponsesWriteTimeout,
+	})
+
+	return c.NewDeliveryReceiptProducer(w, ipAddr.String(), cfg), w
+}
+
+// configureConnectionRegistrar also returns the forwarder used to pass the
+// messages queued for a lost connection to the pod the node reconnects to, and
+// the Redis client the connections are registered with.  Messages can only be
+// forwarded when the connections are registered with Redis.
+func configureConnectionRegistrar(cfg *config.Config, localCM c.ConnectionRegistrar) (c.ConnectionRegistrar, c.MessageForwarder, *redis.Client) {
 	switch strings.ToLower(cfg.GatewayConnectionRegistrarImpl) {
 	case "redis":
 		logger.Log.Info("Using GatewayConnectionRegistrar as the ConnectionRegistrar impl." +
@@ -58,21 +145,26 @@ func configureConnectionRegistrar(cfg *config.Config, localCM c.ConnectionRegist
 			logger.Log.Fatal("Unable to determine IP address")
 		}
 
-		return c.NewGatewayConnectionRegistrar(redisClient, localCM, ipAddr.String())
+		var forwarder c.MessageForwarder
+		if cfg.MessageForwardTimeout > 0 {
+			forwarder = &api.PodMessageForwarder{Client: redisClient, Hostname: ipAddr.String(), Cfg: cfg}
+		}
+
+		return c.NewGatewayConnectionRegistrar(redisClient, localCM, ipAddr.String()), forwarder, redisClient
 	case "local":
 		logger.Log.Info("Using LocalConnectionManager as the ConnectionRegistrar impl." +
 			"  Connections will NOT be registered with Redis.")
 
-		return localCM
+		return localCM, nil, nil
 	default:
 		logger.Log.Fatalf("Invalid configuration value for %s!", config.GATEWAY_CONNECTION_REGISTRAR_IMPL)
-		return nil
+		return nil, nil, nil
 	}
 }
 
 func main() {
 	var wsAddr = flag.String("wsAddr", ":8080", "Hostname:port of the websocket server")
-	var mgmtAddr = flag.String("mgmtAddr", ":9090", "Hostname:port of the management server")
+	flag.String("mgmtAddr", ":9090", "Hostname:port of the management server (overrides "+config.MANAGEMENT_ADDR+")")
 	flag.Parse()
 
 	logger.InitLogger()
@@ -86,10 +178,14 @@ func main() {
 	wsMux.Use(request_id.ConfiguredRequestID("x-rh-insights-request-id"))
 
 	kw := queue.StartProducer(&queue.ProducerConfig{
-		Brokers:    cfg.KafkaBrokers,
-		Topic:      cfg.KafkaResponsesTopic,
-		BatchSize:  cfg.KafkaResponsesBatchSize,
-		BatchBytes: cfg.KafkaResponsesBatchBytes,
+		Brokers:      cfg.KafkaBrokers,
+		Topic:        cfg.KafkaResponsesTopic,
+		BatchSize:    cfg.KafkaResponsesBatchSize,
+		BatchBytes:   cfg.KafkaResponsesBatchBytes,
+		Batching:     cfg.KafkaResponsesBatching,
+		BatchTimeout: cfg.KafkaResponsesBatchTimeout,
+		Compression:  cfg.KafkaResponsesCompression,
+		WriteTimeout: cfg.KafkaResponsesWriteTimeout,
 	})
 
 	kc := &queue.ConsumerConfig{
@@ -97,39 +193,124 @@ func main() {
 		Topic:          cfg.KafkaJobsTopic,
 		GroupID:        cfg.KafkaGroupID,
 		ConsumerOffset: cfg.KafkaConsumerOffset,
+		ReadTimeout:    cfg.KafkaJobsReadTimeout,
+		CommitTimeout:  cfg.KafkaJobsCommitTimeout,
 	}
 
 	var gatewayCR c.ConnectionRegistrar
 
-	localCM := c.NewLocalConnectionManager()
-	gatewayCR = configureConnectionRegistrar(cfg, localCM)
+	localCM := c.NewShardedLocalConnectionManager(c.ConnectionLimit{
+		Default:             cfg.MaxConnectionsPerAccount,
+		Override:            cfg.MaxConnectionsPerAccountOverride,
+		SoftPercent:         cfg.SoftConnectionLimitPercent,
+		SoftPercentOverride: cfg.SoftConnectionLimitOverride,
+	}, cfg.ConnectionManagerShards)
+	connectionEvents := c.NewConnectionEventBroker(cfg.ConnectionEventsBufferSize, cfg.ConnectionEventsHistorySize)
+	connectionRegistrar, messageForwarder, redisClient := configureConnectionRegistrar(cfg, localCM)
+	connectionStats := c.NewConnectionStatsTracker(cfg.ConnectionStatsReconnectWindow)
+	connectionRegistrar = c.NewStatsRecordingConnectionRegistrar(connectionRegistrar, connectionStats)
+	gatewayCR = c.NewEventPublishingConnectionRegistrar(connectionRegistrar, connectionEvents)
+	localCM.SetConnectionQuotaWarningListener(gatewayCR.(c.ConnectionQuotaWarningListener))
+
+	outbox, err := c.NewOutboxStore(cfg)
+	if err != nil {
+		logger.Log.Fatal("Unable to initialize the outbox: ", err)
+	}
+
+	connectionPolicy, err := c.NewConnectionPolicy(cfg)
+	if err != nil {
+		logger.Log.Fatal("Unable to initialize the connection policy: ", err)
+	}
+
+	sweeperCtx, stopSweeper := context.WithCancel(context.Background())
+	go c.NewOutboxSweeper(outbox, localCM, cfg).Run(sweeperCtx)
+
+	webhookCtx, stopWebhook := context.WithCancel(context.Background())
+	if cfg.ConnectionEventsWebhookUrl != "" {
+		go c.NewConnectionEventWebhook(cfg).Run(webhookCtx, connectionEvents)
+	}
+
+	exporterCtx, stopExporter := context.WithCancel(context.Background())
+	var inventoryWriter *kafka.Writer
+	if cfg.InventoryExportInterval > 0 {
+		inventoryWriter = startInventoryExporter(exporterCtx, cfg, localCM)
+	}
 
 	rd := c.NewResponseReactorFactory()
-	rs := c.NewReceptorServiceFactory(kw, cfg)
+	accountThroughput := c.NewAccountThroughputTracker(cfg.AccountThroughputWindow)
+
+	rs := c.NewReceptorServiceFactory(kw, outbox, cfg)
+	if messageForwarder != nil {
+		rs.SetMessageForwarder(messageForwarder)
+	}
+	rs.SetAccountThroughputTracker(accountThroughput)
+
+	var deliveryReceipts *c.DeliveryReceiptProducer
+	var deliveryReceiptsWriter *kafka.Writer
+	if cfg.DeliveryReceiptsEnabled {
+		deliveryReceipts, deliveryReceiptsWriter = startDeliveryReceiptProducer(cfg)
+		rs.SetDeliveryReceiptProducer(deliveryReceipts)
+	}
+
 	md := c.NewMessageDispatcherFactory(kc)
-	rc := ws.NewReceptorController(cfg, gatewayCR, wsMux, rd, md, rs)
+	rc := ws.NewReceptorController(cfg, gatewayCR, connectionPolicy, wsMux, rd, md, rs)
+	rc.AddRegistrationHook(c.REGISTRATION_HOOK_QUOTA, c.NewConnectionQuotaHook(localCM))
 	rc.Routes()
 
 	apiMux := mux.NewRouter()
 	apiMux.Use(request_id.ConfiguredRequestID("x-rh-insights-request-id"))
+	apiMux.Use(middlewares.NewConcurrencyLimitMiddleware(cfg.ManagementMaxConcurrentRequests,
+		cfg.ManagementConcurrentRequestsQueueTimeout).Limit)
 
 	apiSpecServer := api.NewApiSpecServer(apiMux, OPENAPI_SPEC_FILE)
 	apiSpecServer.Routes()
 
-	mgmtServer := api.NewManagementServer(localCM, apiMux, cfg)
+	mgmtServer, err := api.NewManagementServer(localCM, connectionEvents, apiMux, cfg)
+	if err != nil {
+		logger.Log.Fatal("Unable to initialize the management server: ", err)
+	}
+	maintenance := api.NewMaintenanceMode()
+
+	mgmtServer.SetConnectionStatsTracker(connectionStats)
+	mgmtServer.SetAccountThroughputTracker(accountThroughput)
+	mgmtServer.SetMaintenanceMode(maintenance)
+	mgmtServer.AddHealthCheck(api.HEALTH_CHECK_KAFKA, api.NewKafkaHealthCheck(cfg.KafkaBrokers))
+	if redisClient != nil {
+		mgmtServer.AddHealthCheck(api.HEALTH_CHECK_LOCATOR, api.NewRedisHealthCheck(redisClient))
+	}
 	mgmtServer.Routes()
 
-	jr := api.NewJobReceiver(localCM, apiMux, cfg)
+	nodeSelector, err := c.NewNodeSelector(cfg)
+	if err != nil {
+		logger.Log.Fatal("Unable to initialize the node selector: ", err)
+	}
+
+	jr := api.NewJobReceiver(localCM, outbox, apiMux, cfg)
+	jr.SetNodeSelector(nodeSelector)
+	jr.SetMaintenanceMode(maintenance)
 	jr.Routes()
 
-	apiMux.Handle("/metrics", promhttp.Handler())
+	apiMux.Handle("/metrics", openmetrics.Handler())
 
 	wg := &sync.WaitGroup{}
 	wg.Add(1)
 
-	apiSrv := utils.StartHTTPServer(*mgmtAddr, "management", apiMux)
-	wsSrv := utils.StartHTTPServer(*wsAddr, "websocket", wsMux)
-	wsSrv.RegisterOnShutdown(func() { closeConnections(localCM, wg, cfg.HttpShutdownTimeout) })
+	mgmtTLSConfig, err := utils.ConfigureServerTLS(cfg.ManagementTLSCertFile, cfg.ManagementTLSKeyFile,
+		cfg.ManagementTLSMinVersion, cfg.ManagementTLSCipherSuites)
+	if err != nil {
+		logger.Log.Fatal("Unable to configure TLS for the management server: ", err)
+	}
+
+	apiSrv, err := utils.StartHTTPServer(utils.ListenAddr("mgmtAddr", cfg.ManagementAddr), "management", apiMux, cfg.ListenReusePort, mgmtTLSConfig)
+	if err != nil {
+		logger.Log.Fatal("Unable to start the management server: ", err)
+	}
+
+	wsSrv, err := utils.StartHTTPServer(*wsAddr, "websocket", wsMux, cfg.ListenReusePort, nil)
+	if err != nil {
+		logger.Log.Fatal("Unable to start the websocket server: ", err)
+	}
+	wsSrv.RegisterOnShutdown(func() { closeConnections(localCM, wg, cfg.ShutdownDrainTimeout, cfg.HttpShutdownTimeout) })
 
 	signalChan := make(chan os.Signal, 1)
 
@@ -138,6 +319,10 @@ func main() {
 	sig := <-signalChan
 	logger.Log.Info("Received signal to shutdown: ", sig)
 
+	stopSweeper()
+	stopExporter()
+	stopWebhook()
+
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.HttpShutdownTimeout)
 	defer cancel()
 
@@ -145,5 +330,29 @@ func main() {
 	utils.ShutdownHTTPServer(ctx, "websocket", wsSrv)
 
 	wg.Wait()
+
+	producerCtx, producerCancel := context.WithTimeout(context.Background(), cfg.KafkaResponsesCloseTimeout)
+	defer producerCancel()
+
+	if err := rs.Close(producerCtx); err != nil {
+		logger.Log.Error("Unable to flush the Kafka responses producer: ", err)
+	}
+
+	if deliveryReceipts != nil {
+		if !deliveryReceipts.Flush(producerCtx) {
+			logger.Log.Warn("Timed out waiting for delivery receipts to be written to kafka")
+		}
+
+		if err := queue.CloseProducer(producerCtx, deliveryReceiptsWriter); err != nil {
+			logger.Log.Error("Unable to flush the Kafka delivery receipts producer: ", err)
+		}
+	}
+
+	if inventoryWriter != nil {
+		if err := queue.CloseProducer(producerCtx, inventoryWriter); err != nil {
+			logger.Log.Error("Unable to flush the Kafka inventory producer: ", err)
+		}
+	}
+
 	logger.Log.Info("Receptor-Controller shutting down")
 }
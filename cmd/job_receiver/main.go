@@ -12,13 +12,17 @@ import (
 	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
 	"github.com/RedHatInsights/platform-receptor-controller/internal/controller"
 	"github.com/RedHatInsights/platform-receptor-controller/internal/controller/api"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/middlewares"
 	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/openmetrics"
 	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/utils"
 	"github.com/redhatinsights/platform-go-middlewares/request_id"
 
 	"github.com/go-redis/redis"
 	"github.com/gorilla/mux"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	// The database/sql driver of the SQL outbox store
+	_ "github.com/lib/pq"
 )
 
 func initRedis(cfg *config.Config) (*redis.Client, error) {
@@ -50,7 +54,7 @@ func verifyConfiguration(cfg *config.Config) error {
 }
 
 func main() {
-	var mgmtAddr = flag.String("mgmtAddr", ":8081", "Hostname:port of the management server")
+	flag.String("mgmtAddr", ":8081", "Hostname:port of the management server (overrides "+config.MANAGEMENT_ADDR+")")
 	flag.Parse()
 
 	logger.InitLogger()
@@ -70,21 +74,62 @@ func main() {
 		logger.Log.Fatal("Unable to connect to Redis: ", err)
 	}
 
+	redisLocator := &api.RedisConnectionLocator{Client: redisClient, Cfg: cfg}
+
 	var connectionLocator controller.ConnectionLocator
-	connectionLocator = &api.RedisConnectionLocator{Client: redisClient, Cfg: cfg}
+	connectionLocator = redisLocator
+
+	// Only the gateway pods write to the outbox.  The jobs are read from the
+	// outbox when it is shared with the gateway pods, otherwise the requests
+	// for a job go to the pod that the job was sent through.
+	var outbox controller.OutboxStore
+	if cfg.OutboxStoreImpl == "sql" {
+		outbox, err = controller.NewOutboxStore(cfg)
+		if err != nil {
+			logger.Log.Fatal("Unable to initialize the outbox: ", err)
+		}
+	}
 
 	apiMux := mux.NewRouter()
 	apiMux.Use(request_id.ConfiguredRequestID("x-rh-insights-request-id"))
+	apiMux.Use(middlewares.NewConcurrencyLimitMiddleware(cfg.ManagementMaxConcurrentRequests,
+		cfg.ManagementConcurrentRequestsQueueTimeout).Limit)
 
-	apiMux.Handle("/metrics", promhttp.Handler())
+	apiMux.Handle("/metrics", openmetrics.Handler())
 
-	mgmtServer := api.NewManagementServer(connectionLocator, apiMux, cfg)
+	// Connection events are only published by the gateway pods
+	mgmtServer, err := api.NewManagementServer(connectionLocator, nil, apiMux, cfg)
+	if err != nil {
+		logger.Log.Fatal("Unable to initialize the management server: ", err)
+	}
+	maintenance := api.NewMaintenanceMode()
+	mgmtServer.SetMaintenanceMode(maintenance)
+	mgmtServer.AddHealthCheck(api.HEALTH_CHECK_LOCATOR, api.NewRedisHealthCheck(redisClient))
 	mgmtServer.Routes()
 
-	jr := api.NewJobReceiver(connectionLocator, apiMux, cfg)
+	nodeSelector, err := controller.NewNodeSelector(cfg)
+	if err != nil {
+		logger.Log.Fatal("Unable to initialize the node selector: ", err)
+	}
+
+	jr := api.NewJobReceiver(connectionLocator, outbox, apiMux, cfg)
+	if outbox == nil {
+		jr.SetJobLocator(redisLocator)
+	}
+	jr.SetNodeSelector(nodeSelector)
+	jr.SetMaintenanceMode(maintenance)
 	jr.Routes()
 
-	apiSrv := utils.StartHTTPServer(*mgmtAddr, "management", apiMux)
+	mgmtTLSConfig, err := utils.ConfigureServerTLS(cfg.ManagementTLSCertFile, cfg.ManagementTLSKeyFile,
+		cfg.ManagementTLSMinVersion, cfg.ManagementTLSCipherSuites)
+	if err != nil {
+		logger.Log.Fatal("Unable to configure TLS for the management server: ", err)
+	}
+
+	apiSrv, err := utils.StartHTTPServer(utils.ListenAddr("mgmtAddr", cfg.ManagementAddr), "management", apiMux, cfg.ListenReusePort, mgmtTLSConfig)
+	if err != nil {
+		logger.Log.Fatal("Unable to start the management server: ", err)
+	}
 
 	signalChan := make(chan os.Signal, 1)
 
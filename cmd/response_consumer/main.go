@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/controller"
 
 	"github.com/google/uuid"
 	kafka "github.com/segmentio/kafka-go"
@@ -37,6 +38,10 @@ func main() {
 			break
 		}
 		fmt.Printf("message at offset %d: %s = %s\n", m.Offset, string(m.Key), string(m.Value))
+
+		if tc, err := controller.TraceContextFromKafkaHeaders(m.Headers); err == nil {
+			fmt.Printf("  traceparent: %s tracestate: %s\n", tc.TraceParent, tc.TraceState)
+		}
 	}
 
 	kafkaReader.Close()
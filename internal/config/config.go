@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,69 +13,275 @@ import (
 const (
 	ENV_PREFIX = "RECEPTOR_CONTROLLER"
 
-	HANDSHAKE_READ_WAIT                   = "WebSocket_Handshake_Read_Wait"
-	WRITE_WAIT                            = "WebSocket_Write_Wait"
-	PONG_WAIT                             = "WebSocket_Pong_Wait"
-	PING_PERIOD                           = "WebSocket_Ping_Period"
-	RECEPTOR_SYNC_PING_TIMEOUT            = "Receptor_Sync_Ping_Timeout"
-	HTTP_SHUTDOWN_TIMEOUT                 = "HTTP_Shutdown_Timeout"
-	MAX_MESSAGE_SIZE                      = "WebSocket_Max_Message_Size"
-	SOCKET_BUFFER_SIZE                    = "WebSocket_IO_Buffer_Size"
-	BUFFERED_CHANNEL_SIZE                 = "WebSocket_Buffered_Channel_Size"
-	SERVICE_TO_SERVICE_CREDENTIALS        = "Service_To_Service_Credentials"
-	PROFILE                               = "Enable_Profile"
-	BROKERS                               = "Kafka_Brokers"
-	JOBS_TOPIC                            = "Kafka_Jobs_Topic"
-	JOBS_GROUP_ID                         = "Kafka_Jobs_Group_Id"
-	JOBS_CONSUMER_OFFSET                  = "Kafka_Jobs_Consumer_Offset"
-	RESPONSES_TOPIC                       = "Kafka_Responses_Topic"
-	RESPONSES_BATCH_SIZE                  = "Kafka_Responses_Batch_Size"
-	RESPONSES_BATCH_BYTES                 = "Kafka_Responses_Batch_Bytes"
-	DEFAULT_BROKER_ADDRESS                = "kafka:29092"
-	REDIS_HOST                            = "Redis_Host"
-	REDIS_PORT                            = "Redis_Port"
-	REDIS_PASSWORD                        = "Redis_Password"
-	REDIS_DB                              = "Redis_DB"
-	JOB_RECEIVER_RECEPTOR_PROXY_CLIENT_ID = "Job_Receiver_Receptor_Proxy_ClientID"
-	JOB_RECEIVER_RECEPTOR_PROXY_PSK       = "Job_Receiver_Receptor_Proxy_PSK"
-	JOB_RECEIVER_RECEPTOR_PROXY_SCHEME    = "Job_Receiver_Receptor_Proxy_Scheme"
-	JOB_RECEIVER_RECEPTOR_PROXY_PORT      = "Job_Receiver_Receptor_Proxy_Port"
-	JOB_RECEIVER_RECEPTOR_PROXY_TIMEOUT   = "Job_Receiver_Receptor_Proxy_Timeout"
-	GATEWAY_CONNECTION_REGISTRAR_IMPL     = "Gateway_Connection_Registrar_Impl"
+	HANDSHAKE_READ_WAIT                          = "WebSocket_Handshake_Read_Wait"
+	WRITE_WAIT                                   = "WebSocket_Write_Wait"
+	PONG_WAIT                                    = "WebSocket_Pong_Wait"
+	PING_PERIOD                                  = "WebSocket_Ping_Period"
+	RECEPTOR_SYNC_PING_TIMEOUT                   = "Receptor_Sync_Ping_Timeout"
+	RECEPTOR_MAX_CONNECTION_TIMEOUT              = "Receptor_Max_Connection_Timeout"
+	RECEPTOR_MAX_BACK_OFF                        = "Receptor_Max_Back_Off"
+	SLOW_OPERATION_THRESHOLD                     = "Slow_Operation_Threshold"
+	SLOW_OPERATION_THRESHOLD_OVERRIDES           = "Slow_Operation_Threshold_Overrides"
+	HEALTH_CHECK_TIMEOUT                         = "Health_Check_Timeout"
+	HEALTH_MAX_GOROUTINES                        = "Health_Max_Goroutines"
+	HEALTH_MAX_CONNECTIONS                       = "Health_Max_Connections"
+	RECEPTOR_CLOSE_TIMEOUT                       = "Receptor_Close_Timeout"
+	MESSAGE_FORWARD_TIMEOUT                      = "Message_Forward_Timeout"
+	RECEPTOR_PAUSED_MESSAGE_LIMIT                = "Receptor_Paused_Message_Limit"
+	RECEPTOR_WARMUP_GRACE_PERIOD                 = "Receptor_Warmup_Grace_Period"
+	MAX_IN_FLIGHT_MESSAGES                       = "Max_In_Flight_Messages"
+	MAX_IN_FLIGHT_MESSAGES_OVERRIDES             = "Max_In_Flight_Messages_Overrides"
+	RECEPTOR_SEND_RATE_LIMIT                     = "Receptor_Send_Rate_Limit"
+	RECEPTOR_SEND_RATE_LIMIT_OVERRIDES           = "Receptor_Send_Rate_Limit_Overrides"
+	RECEPTOR_SEND_RATE_LIMIT_BURST               = "Receptor_Send_Rate_Limit_Burst"
+	RECEPTOR_SEND_RATE_LIMIT_MAX_WAIT            = "Receptor_Send_Rate_Limit_Max_Wait"
+	RECEPTOR_STRICT_SEND_ORDERING                = "Receptor_Strict_Send_Ordering"
+	ACCOUNT_BANDWIDTH_QUOTA                      = "Account_Bandwidth_Quota"
+	ACCOUNT_BANDWIDTH_QUOTA_OVERRIDES            = "Account_Bandwidth_Quota_Overrides"
+	ACCOUNT_BANDWIDTH_QUOTA_WINDOW               = "Account_Bandwidth_Quota_Window"
+	RECEPTOR_CAPABILITIES_WARMING                = "Receptor_Capabilities_Warming"
+	RECEPTOR_CAPABILITIES_WARMING_RATE           = "Receptor_Capabilities_Warming_Rate"
+	RECEPTOR_CAPABILITIES_WARMING_MAX_WAIT       = "Receptor_Capabilities_Warming_Max_Wait"
+	RECEPTOR_CAPABILITIES_WARMING_MAX_CONCURRENT = "Receptor_Capabilities_Warming_Max_Concurrent"
+	RECEPTOR_MIN_PROTOCOL_VERSION                = "Receptor_Min_Protocol_Version"
+	DIRECTIVE_METRICS_MAX_ACCOUNTS               = "Directive_Metrics_Max_Accounts"
+	DIRECTIVE_METRICS_MAX_DIRECTIVES             = "Directive_Metrics_Max_Directives"
+	RECEPTOR_ACK_TIMEOUT                         = "Receptor_Ack_Timeout"
+	HTTP_SHUTDOWN_TIMEOUT                        = "HTTP_Shutdown_Timeout"
+	MANAGEMENT_ADDR                              = "Management_Addr"
+	MANAGEMENT_TLS_CERT_FILE                     = "Management_TLS_Cert_File"
+	MANAGEMENT_TLS_KEY_FILE                      = "Management_TLS_Key_File"
+	MANAGEMENT_TLS_MIN_VERSION                   = "Management_TLS_Min_Version"
+	MANAGEMENT_TLS_CIPHER_SUITES                 = "Management_TLS_Cipher_Suites"
+	MANAGEMENT_MAX_CONCURRENT_REQUESTS           = "Management_Max_Concurrent_Requests"
+	MANAGEMENT_CONCURRENT_REQUESTS_QUEUE_TIMEOUT = "Management_Concurrent_Requests_Queue_Timeout"
+	LISTEN_REUSE_PORT                            = "Listen_Reuse_Port"
+	SHUTDOWN_DRAIN_TIMEOUT                       = "Shutdown_Drain_Timeout"
+	MAX_MESSAGE_SIZE                             = "WebSocket_Max_Message_Size"
+	MAX_RESPONSE_PAYLOAD_SIZE                    = "Max_Response_Payload_Size"
+	SOCKET_BUFFER_SIZE                           = "WebSocket_IO_Buffer_Size"
+	SOCKET_COMPRESSION                           = "WebSocket_Enable_Compression"
+	SOCKET_COMPRESSION_LEVEL                     = "WebSocket_Compression_Level"
+	BUFFERED_CHANNEL_SIZE                        = "WebSocket_Buffered_Channel_Size"
+	ACCEPT_RATE                                  = "WebSocket_Accept_Rate"
+	ACCEPT_BURST                                 = "WebSocket_Accept_Burst"
+	SERVICE_TO_SERVICE_CREDENTIALS               = "Service_To_Service_Credentials"
+	PROFILE                                      = "Enable_Profile"
+	DEBUG_PRINCIPAL_HEADER                       = "Debug_Principal_Header"
+	PRETTY_JSON_RESPONSES                        = "Pretty_Json_Responses"
+	JSON_NAMING                                  = "JSON_Naming"
+	BROKERS                                      = "Kafka_Brokers"
+	JOBS_TOPIC                                   = "Kafka_Jobs_Topic"
+	JOBS_GROUP_ID                                = "Kafka_Jobs_Group_Id"
+	JOBS_CONSUMER_OFFSET                         = "Kafka_Jobs_Consumer_Offset"
+	RESPONSES_TOPIC                              = "Kafka_Responses_Topic"
+	RESPONSES_BATCH_SIZE                         = "Kafka_Responses_Batch_Size"
+	RESPONSES_BATCH_BYTES                        = "Kafka_Responses_Batch_Bytes"
+	RESPONSES_BATCHING                           = "Kafka_Responses_Batching"
+	RESPONSES_BATCH_TIMEOUT                      = "Kafka_Responses_Batch_Timeout"
+	RESPONSES_COMPRESSION                        = "Kafka_Responses_Compression"
+	RESPONSES_WRITE_TIMEOUT                      = "Kafka_Responses_Write_Timeout"
+	RESPONSES_CLOSE_TIMEOUT                      = "Kafka_Responses_Close_Timeout"
+	JOBS_READ_TIMEOUT                            = "Kafka_Jobs_Read_Timeout"
+	JOBS_COMMIT_TIMEOUT                          = "Kafka_Jobs_Commit_Timeout"
+	DEFAULT_BROKER_ADDRESS                       = "kafka:29092"
+	REDIS_HOST                                   = "Redis_Host"
+	REDIS_PORT                                   = "Redis_Port"
+	REDIS_PASSWORD                               = "Redis_Password"
+	REDIS_DB                                     = "Redis_DB"
+	JOB_RECEIVER_RECEPTOR_PROXY_CLIENT_ID        = "Job_Receiver_Receptor_Proxy_ClientID"
+	JOB_RECEIVER_RECEPTOR_PROXY_PSK              = "Job_Receiver_Receptor_Proxy_PSK"
+	JOB_RECEIVER_RECEPTOR_PROXY_SCHEME           = "Job_Receiver_Receptor_Proxy_Scheme"
+	JOB_RECEIVER_RECEPTOR_PROXY_PORT             = "Job_Receiver_Receptor_Proxy_Port"
+	JOB_RECEIVER_RECEPTOR_PROXY_TIMEOUT          = "Job_Receiver_Receptor_Proxy_Timeout"
+	GATEWAY_CONNECTION_REGISTRAR_IMPL            = "Gateway_Connection_Registrar_Impl"
+	MAX_CONNECTIONS_PER_ACCOUNT                  = "Max_Connections_Per_Account"
+	MAX_CONNECTIONS_PER_ACCOUNT_OVERRIDES        = "Max_Connections_Per_Account_Overrides"
+	SOFT_CONNECTION_LIMIT_PERCENT                = "Soft_Connection_Limit_Percent"
+	SOFT_CONNECTION_LIMIT_OVERRIDES              = "Soft_Connection_Limit_Overrides"
+	CONNECTION_MANAGER_SHARDS                    = "Connection_Manager_Shards"
+	HEALTH_DEGRADED_KEEPALIVE_AGE                = "Health_Degraded_Keepalive_Age"
+	HEALTH_STALLED_KEEPALIVE_AGE                 = "Health_Stalled_Keepalive_Age"
+	HEALTH_DEGRADED_SEND_CHANNEL_USAGE           = "Health_Degraded_Send_Channel_Usage"
+	HEALTH_STALLED_SEND_CHANNEL_USAGE            = "Health_Stalled_Send_Channel_Usage"
+	ADMIN_CLIENT_IDS                             = "Admin_Client_Ids"
+	CONNECTION_LABEL_HEADERS                     = "Connection_Label_Headers"
+	CONNECTION_EVENTS_BUFFER_SIZE                = "Connection_Events_Buffer_Size"
+	CONNECTION_EVENTS_HISTORY_SIZE               = "Connection_Events_History_Size"
+	CONNECTION_EVENTS_WEBHOOK_URL                = "Connection_Events_Webhook_Url"
+	CONNECTION_EVENTS_WEBHOOK_MAX_ATTEMPTS       = "Connection_Events_Webhook_Max_Attempts"
+	CONNECTION_EVENTS_WEBHOOK_BACKOFF_MIN        = "Connection_Events_Webhook_Backoff_Min"
+	CONNECTION_EVENTS_WEBHOOK_BACKOFF_MAX        = "Connection_Events_Webhook_Backoff_Max"
+	CONNECTION_EVENTS_WEBHOOK_ATTEMPT_TIMEOUT    = "Connection_Events_Webhook_Attempt_Timeout"
+	CONNECTION_EVENTS_WEBHOOK_QUEUE_SIZE         = "Connection_Events_Webhook_Queue_Size"
+	CONNECTION_WAIT_MAX_TIMEOUT                  = "Connection_Wait_Max_Timeout"
+	CONNECTION_STATS_CACHE_TTL                   = "Connection_Stats_Cache_Ttl"
+	CONNECTION_STATS_RECONNECT_WINDOW            = "Connection_Stats_Reconnect_Window"
+	ACCOUNT_THROUGHPUT_WINDOW                    = "Account_Throughput_Window"
+	OUTBOX_STORE_IMPL                            = "Outbox_Store_Impl"
+	CONNECTION_POLICY_IMPL                       = "Connection_Policy_Impl"
+	CONNECTION_POLICY_DENIED_ACCOUNTS            = "Connection_Policy_Denied_Accounts"
+	CONNECTION_POLICY_DENIED_NODE_IDS            = "Connection_Policy_Denied_Node_Ids"
+	CONNECTION_METADATA_SCHEMA_FILE              = "Connection_Metadata_Schema_File"
+	CONNECTION_METADATA_SCHEMA_WARN_ONLY         = "Connection_Metadata_Schema_Warn_Only"
+	ALLOWED_DIRECTIVES                           = "Allowed_Directives"
+	AFFINITY_PODS                                = "Affinity_Pods"
+	BROADCAST_CONCURRENCY                        = "Broadcast_Concurrency"
+	NODE_SELECTION_STRATEGY                      = "Node_Selection_Strategy"
+	POD_ID                                       = "Pod_ID"
+	CAPABILITIES_REFRESH_CONCURRENCY             = "Capabilities_Refresh_Concurrency"
+	CAPABILITIES_REFRESH_RATE                    = "Capabilities_Refresh_Rate"
+	CONNECTION_REAP_CONCURRENCY                  = "Connection_Reap_Concurrency"
+	OUTBOX_DATABASE_DRIVER                       = "Outbox_Database_Driver"
+	OUTBOX_DATABASE_URL                          = "Outbox_Database_Url"
+	OUTBOX_SWEEP_INTERVAL                        = "Outbox_Sweep_Interval"
+	OUTBOX_PENDING_THRESHOLD                     = "Outbox_Pending_Threshold"
+	OUTBOX_MAX_ATTEMPTS                          = "Outbox_Max_Attempts"
+	OUTBOX_RETENTION                             = "Outbox_Retention"
+	INVENTORY_EXPORT_TOPIC                       = "Kafka_Inventory_Topic"
+	INVENTORY_EXPORT_INTERVAL                    = "Inventory_Export_Interval"
+	INVENTORY_EXPORT_CHUNK_SIZE                  = "Inventory_Export_Chunk_Size"
+	DELIVERY_RECEIPTS_ENABLED                    = "Delivery_Receipts_Enabled"
+	DELIVERY_RECEIPTS_TOPIC                      = "Kafka_Delivery_Receipts_Topic"
+	ACCESS_LOG_READ_ONLY_SAMPLE_RATE             = "Access_Log_Read_Only_Sample_Rate"
+	ACCESS_LOG_SUPPRESSED_PATHS                  = "Access_Log_Suppressed_Paths"
 
 	NODE_ID = "ReceptorControllerNodeId"
 )
 
 type Config struct {
-	HandshakeReadWait                time.Duration
-	WriteWait                        time.Duration
-	PongWait                         time.Duration
-	PingPeriod                       time.Duration
-	ReceptorSyncPingTimeout          time.Duration
-	HttpShutdownTimeout              time.Duration
-	MaxMessageSize                   int64
-	SocketBufferSize                 int
-	BufferedChannelSize              int
-	ServiceToServiceCredentials      map[string]interface{}
-	Profile                          bool
-	ReceptorControllerNodeId         string
-	KafkaBrokers                     []string
-	KafkaJobsTopic                   string
-	KafkaResponsesTopic              string
-	KafkaResponsesBatchSize          int
-	KafkaResponsesBatchBytes         int
-	KafkaGroupID                     string
-	KafkaConsumerOffset              int64
-	RedisHost                        string
-	RedisPort                        string
-	RedisPassword                    string
-	RedisDB                          int
-	JobReceiverReceptorProxyClientID string
-	JobReceiverReceptorProxyPSK      string
-	JobReceiverReceptorProxyScheme   string
-	JobReceiverReceptorProxyPort     int
-	JobReceiverReceptorProxyTimeout  time.Duration
-	GatewayConnectionRegistrarImpl   string
+	HandshakeReadWait                        time.Duration
+	WriteWait                                time.Duration
+	PongWait                                 time.Duration
+	PingPeriod                               time.Duration
+	ReceptorSyncPingTimeout                  time.Duration
+	ReceptorMaxConnectionTimeout             time.Duration
+	ReceptorMaxBackOff                       time.Duration
+	SlowOperationThreshold                   time.Duration
+	SlowOperationThresholdOverride           map[string]int
+	HealthCheckTimeout                       time.Duration
+	HealthMaxGoroutines                      int
+	HealthMaxConnections                     int
+	ReceptorCloseTimeout                     time.Duration
+	MessageForwardTimeout                    time.Duration
+	ReceptorPausedMessageLimit               int
+	ReceptorWarmupGracePeriod                time.Duration
+	MaxInFlightMessages                      int
+	MaxInFlightMessagesOverride              map[string]int
+	ReceptorSendRateLimit                    int
+	ReceptorSendRateLimitOverride            map[string]int
+	ReceptorSendRateLimitBurst               int
+	ReceptorSendRateLimitMaxWait             time.Duration
+	ReceptorStrictSendOrdering               bool
+	AccountBandwidthQuota                    int
+	AccountBandwidthQuotaOverride            map[string]int
+	AccountBandwidthQuotaWindow              time.Duration
+	ReceptorCapabilitiesWarming              bool
+	ReceptorCapabilitiesWarmingRate          int
+	ReceptorCapabilitiesWarmingMaxWait       time.Duration
+	ReceptorCapabilitiesWarmingMaxConcurrent int
+	ReceptorMinProtocolVersion               int
+	DirectiveMetricsMaxAccounts              int
+	DirectiveMetricsMaxDirectives            int
+	ReceptorAckTimeout                       time.Duration
+	HttpShutdownTimeout                      time.Duration
+	ManagementAddr                           string
+	ManagementTLSCertFile                    string
+	ManagementTLSKeyFile                     string
+	ManagementTLSMinVersion                  string
+	ManagementTLSCipherSuites                []string
+	ManagementMaxConcurrentRequests          int
+	ManagementConcurrentRequestsQueueTimeout time.Duration
+	ListenReusePort                          bool
+	ShutdownDrainTimeout                     time.Duration
+	MaxMessageSize                           int64
+	MaxResponsePayloadSize                   uint32
+	SocketBufferSize                         int
+	SocketCompression                        bool
+	SocketCompressionLevel                   int
+	BufferedChannelSize                      int
+	AcceptRate                               int
+	AcceptBurst                              int
+	ServiceToServiceCredentials              map[string]interface{}
+	Profile                                  bool
+	DebugPrincipalHeader                     bool
+	PrettyJSONResponses                      bool
+	JSONNaming                               string
+	ReceptorControllerNodeId                 string
+	KafkaBrokers                             []string
+	KafkaJobsTopic                           string
+	KafkaResponsesTopic                      string
+	KafkaResponsesBatchSize                  int
+	KafkaResponsesBatchBytes                 int
+	KafkaResponsesBatching                   bool
+	KafkaResponsesBatchTimeout               time.Duration
+	KafkaResponsesCompression                string
+	KafkaResponsesWriteTimeout               time.Duration
+	KafkaResponsesCloseTimeout               time.Duration
+	KafkaJobsReadTimeout                     time.Duration
+	KafkaJobsCommitTimeout                   time.Duration
+	KafkaGroupID                             string
+	KafkaConsumerOffset                      int64
+	RedisHost                                string
+	RedisPort                                string
+	RedisPassword                            string
+	RedisDB                                  int
+	JobReceiverReceptorProxyClientID         string
+	JobReceiverReceptorProxyPSK              string
+	JobReceiverReceptorProxyScheme           string
+	JobReceiverReceptorProxyPort             int
+	JobReceiverReceptorProxyTimeout          time.Duration
+	GatewayConnectionRegistrarImpl           string
+	MaxConnectionsPerAccount                 int
+	MaxConnectionsPerAccountOverride         map[string]int
+	SoftConnectionLimitPercent               int
+	SoftConnectionLimitOverride              map[string]int
+	ConnectionManagerShards                  int
+	HealthDegradedKeepaliveAge               time.Duration
+	HealthStalledKeepaliveAge                time.Duration
+	HealthDegradedSendChannelUsage           int
+	HealthStalledSendChannelUsage            int
+	AdminClientIDs                           []string
+	ConnectionLabelHeaders                   []string
+	ConnectionEventsBufferSize               int
+	ConnectionEventsHistorySize              int
+	ConnectionEventsWebhookUrl               string
+	ConnectionEventsWebhookMaxAttempts       int
+	ConnectionEventsWebhookBackoffMin        time.Duration
+	ConnectionEventsWebhookBackoffMax        time.Duration
+	ConnectionEventsWebhookAttemptTimeout    time.Duration
+	ConnectionEventsWebhookQueueSize         int
+	ConnectionWaitMaxTimeout                 time.Duration
+	ConnectionStatsCacheTTL                  time.Duration
+	ConnectionStatsReconnectWindow           time.Duration
+	AccountThroughputWindow                  time.Duration
+	OutboxStoreImpl                          string
+	ConnectionPolicyImpl                     string
+	ConnectionPolicyDeniedAccounts           []string
+	ConnectionPolicyDeniedNodeIDs            []string
+	ConnectionMetadataSchemaFile             string
+	ConnectionMetadataSchemaWarnOnly         bool
+	AllowedDirectives                        []string
+	AffinityPods                             []string
+	BroadcastConcurrency                     int
+	NodeSelectionStrategy                    string
+	PodID                                    string
+	CapabilitiesRefreshConcurrency           int
+	CapabilitiesRefreshRate                  int
+	ConnectionReapConcurrency                int
+	OutboxDatabaseDriver                     string
+	OutboxDatabaseUrl                        string
+	OutboxSweepInterval                      time.Duration
+	OutboxPendingThreshold                   time.Duration
+	OutboxMaxAttempts                        int
+	OutboxRetention                          time.Duration
+	KafkaInventoryTopic                      string
+	InventoryExportInterval                  time.Duration
+	InventoryExportChunkSize                 int
+	DeliveryReceiptsEnabled                  bool
+	KafkaDeliveryReceiptsTopic               string
+	AccessLogReadOnlySampleRate              float64
+	AccessLogSuppressedPaths                 []string
 }
 
 func (c Config) String() string {
@@ -83,17 +291,70 @@ func (c Config) String() string {
 	fmt.Fprintf(&b, "%s: %s\n", PONG_WAIT, c.PongWait)
 	fmt.Fprintf(&b, "%s: %s\n", PING_PERIOD, c.PingPeriod)
 	fmt.Fprintf(&b, "%s: %s\n", RECEPTOR_SYNC_PING_TIMEOUT, c.ReceptorSyncPingTimeout)
+	fmt.Fprintf(&b, "%s: %s\n", RECEPTOR_MAX_CONNECTION_TIMEOUT, c.ReceptorMaxConnectionTimeout)
+	fmt.Fprintf(&b, "%s: %s\n", RECEPTOR_MAX_BACK_OFF, c.ReceptorMaxBackOff)
+	fmt.Fprintf(&b, "%s: %s\n", SLOW_OPERATION_THRESHOLD, c.SlowOperationThreshold)
+	fmt.Fprintf(&b, "%s: %v\n", SLOW_OPERATION_THRESHOLD_OVERRIDES, c.SlowOperationThresholdOverride)
+	fmt.Fprintf(&b, "%s: %s\n", HEALTH_CHECK_TIMEOUT, c.HealthCheckTimeout)
+	fmt.Fprintf(&b, "%s: %d\n", HEALTH_MAX_GOROUTINES, c.HealthMaxGoroutines)
+	fmt.Fprintf(&b, "%s: %d\n", HEALTH_MAX_CONNECTIONS, c.HealthMaxConnections)
+	fmt.Fprintf(&b, "%s: %s\n", RECEPTOR_CLOSE_TIMEOUT, c.ReceptorCloseTimeout)
+	fmt.Fprintf(&b, "%s: %s\n", MESSAGE_FORWARD_TIMEOUT, c.MessageForwardTimeout)
+	fmt.Fprintf(&b, "%s: %d\n", RECEPTOR_PAUSED_MESSAGE_LIMIT, c.ReceptorPausedMessageLimit)
+	fmt.Fprintf(&b, "%s: %s\n", RECEPTOR_WARMUP_GRACE_PERIOD, c.ReceptorWarmupGracePeriod)
+	fmt.Fprintf(&b, "%s: %d\n", MAX_IN_FLIGHT_MESSAGES, c.MaxInFlightMessages)
+	fmt.Fprintf(&b, "%s: %v\n", MAX_IN_FLIGHT_MESSAGES_OVERRIDES, c.MaxInFlightMessagesOverride)
+	fmt.Fprintf(&b, "%s: %d\n", RECEPTOR_SEND_RATE_LIMIT, c.ReceptorSendRateLimit)
+	fmt.Fprintf(&b, "%s: %v\n", RECEPTOR_SEND_RATE_LIMIT_OVERRIDES, c.ReceptorSendRateLimitOverride)
+	fmt.Fprintf(&b, "%s: %d\n", RECEPTOR_SEND_RATE_LIMIT_BURST, c.ReceptorSendRateLimitBurst)
+	fmt.Fprintf(&b, "%s: %s\n", RECEPTOR_SEND_RATE_LIMIT_MAX_WAIT, c.ReceptorSendRateLimitMaxWait)
+	fmt.Fprintf(&b, "%s: %t\n", RECEPTOR_STRICT_SEND_ORDERING, c.ReceptorStrictSendOrdering)
+	fmt.Fprintf(&b, "%s: %d\n", ACCOUNT_BANDWIDTH_QUOTA, c.AccountBandwidthQuota)
+	fmt.Fprintf(&b, "%s: %v\n", ACCOUNT_BANDWIDTH_QUOTA_OVERRIDES, c.AccountBandwidthQuotaOverride)
+	fmt.Fprintf(&b, "%s: %s\n", ACCOUNT_BANDWIDTH_QUOTA_WINDOW, c.AccountBandwidthQuotaWindow)
+	fmt.Fprintf(&b, "%s: %t\n", RECEPTOR_CAPABILITIES_WARMING, c.ReceptorCapabilitiesWarming)
+	fmt.Fprintf(&b, "%s: %d\n", RECEPTOR_CAPABILITIES_WARMING_RATE, c.ReceptorCapabilitiesWarmingRate)
+	fmt.Fprintf(&b, "%s: %s\n", RECEPTOR_CAPABILITIES_WARMING_MAX_WAIT, c.ReceptorCapabilitiesWarmingMaxWait)
+	fmt.Fprintf(&b, "%s: %d\n", RECEPTOR_CAPABILITIES_WARMING_MAX_CONCURRENT, c.ReceptorCapabilitiesWarmingMaxConcurrent)
+	fmt.Fprintf(&b, "%s: %d\n", RECEPTOR_MIN_PROTOCOL_VERSION, c.ReceptorMinProtocolVersion)
+	fmt.Fprintf(&b, "%s: %d\n", DIRECTIVE_METRICS_MAX_ACCOUNTS, c.DirectiveMetricsMaxAccounts)
+	fmt.Fprintf(&b, "%s: %d\n", DIRECTIVE_METRICS_MAX_DIRECTIVES, c.DirectiveMetricsMaxDirectives)
+	fmt.Fprintf(&b, "%s: %s\n", RECEPTOR_ACK_TIMEOUT, c.ReceptorAckTimeout)
 	fmt.Fprintf(&b, "%s: %s\n", HTTP_SHUTDOWN_TIMEOUT, c.HttpShutdownTimeout)
+	fmt.Fprintf(&b, "%s: %s\n", MANAGEMENT_ADDR, c.ManagementAddr)
+	fmt.Fprintf(&b, "%s: %s\n", MANAGEMENT_TLS_CERT_FILE, c.ManagementTLSCertFile)
+	fmt.Fprintf(&b, "%s: %s\n", MANAGEMENT_TLS_KEY_FILE, c.ManagementTLSKeyFile)
+	fmt.Fprintf(&b, "%s: %s\n", MANAGEMENT_TLS_MIN_VERSION, c.ManagementTLSMinVersion)
+	fmt.Fprintf(&b, "%s: %s\n", MANAGEMENT_TLS_CIPHER_SUITES, c.ManagementTLSCipherSuites)
+	fmt.Fprintf(&b, "%s: %d\n", MANAGEMENT_MAX_CONCURRENT_REQUESTS, c.ManagementMaxConcurrentRequests)
+	fmt.Fprintf(&b, "%s: %s\n", MANAGEMENT_CONCURRENT_REQUESTS_QUEUE_TIMEOUT, c.ManagementConcurrentRequestsQueueTimeout)
+	fmt.Fprintf(&b, "%s: %t\n", LISTEN_REUSE_PORT, c.ListenReusePort)
+	fmt.Fprintf(&b, "%s: %s\n", SHUTDOWN_DRAIN_TIMEOUT, c.ShutdownDrainTimeout)
 	fmt.Fprintf(&b, "%s: %d\n", MAX_MESSAGE_SIZE, c.MaxMessageSize)
+	fmt.Fprintf(&b, "%s: %d\n", MAX_RESPONSE_PAYLOAD_SIZE, c.MaxResponsePayloadSize)
 	fmt.Fprintf(&b, "%s: %d\n", SOCKET_BUFFER_SIZE, c.SocketBufferSize)
+	fmt.Fprintf(&b, "%s: %t\n", SOCKET_COMPRESSION, c.SocketCompression)
+	fmt.Fprintf(&b, "%s: %d\n", SOCKET_COMPRESSION_LEVEL, c.SocketCompressionLevel)
 	fmt.Fprintf(&b, "%s: %d\n", BUFFERED_CHANNEL_SIZE, c.BufferedChannelSize)
+	fmt.Fprintf(&b, "%s: %d\n", ACCEPT_RATE, c.AcceptRate)
+	fmt.Fprintf(&b, "%s: %d\n", ACCEPT_BURST, c.AcceptBurst)
 	fmt.Fprintf(&b, "%s: %t\n", PROFILE, c.Profile)
+	fmt.Fprintf(&b, "%s: %t\n", DEBUG_PRINCIPAL_HEADER, c.DebugPrincipalHeader)
+	fmt.Fprintf(&b, "%s: %t\n", PRETTY_JSON_RESPONSES, c.PrettyJSONResponses)
+	fmt.Fprintf(&b, "%s: %s\n", JSON_NAMING, c.JSONNaming)
 	fmt.Fprintf(&b, "%s: %s\n", NODE_ID, c.ReceptorControllerNodeId)
 	fmt.Fprintf(&b, "%s: %s\n", BROKERS, c.KafkaBrokers)
 	fmt.Fprintf(&b, "%s: %s\n", JOBS_TOPIC, c.KafkaJobsTopic)
 	fmt.Fprintf(&b, "%s: %s\n", RESPONSES_TOPIC, c.KafkaResponsesTopic)
 	fmt.Fprintf(&b, "%s: %d\n", RESPONSES_BATCH_SIZE, c.KafkaResponsesBatchSize)
 	fmt.Fprintf(&b, "%s: %d\n", RESPONSES_BATCH_BYTES, c.KafkaResponsesBatchBytes)
+	fmt.Fprintf(&b, "%s: %t\n", RESPONSES_BATCHING, c.KafkaResponsesBatching)
+	fmt.Fprintf(&b, "%s: %s\n", RESPONSES_BATCH_TIMEOUT, c.KafkaResponsesBatchTimeout)
+	fmt.Fprintf(&b, "%s: %s\n", RESPONSES_COMPRESSION, c.KafkaResponsesCompression)
+	fmt.Fprintf(&b, "%s: %s\n", RESPONSES_WRITE_TIMEOUT, c.KafkaResponsesWriteTimeout)
+	fmt.Fprintf(&b, "%s: %s\n", RESPONSES_CLOSE_TIMEOUT, c.KafkaResponsesCloseTimeout)
+	fmt.Fprintf(&b, "%s: %s\n", JOBS_READ_TIMEOUT, c.KafkaJobsReadTimeout)
+	fmt.Fprintf(&b, "%s: %s\n", JOBS_COMMIT_TIMEOUT, c.KafkaJobsCommitTimeout)
 	fmt.Fprintf(&b, "%s: %s\n", JOBS_GROUP_ID, c.KafkaGroupID)
 	fmt.Fprintf(&b, "%s: %d\n", JOBS_CONSUMER_OFFSET, c.KafkaConsumerOffset)
 	fmt.Fprintf(&b, "%s: %s\n", REDIS_HOST, c.RedisHost)
@@ -104,6 +365,55 @@ func (c Config) String() string {
 	fmt.Fprintf(&b, "%s: %d\n", JOB_RECEIVER_RECEPTOR_PROXY_PORT, c.JobReceiverReceptorProxyPort)
 	fmt.Fprintf(&b, "%s: %s\n", JOB_RECEIVER_RECEPTOR_PROXY_TIMEOUT, c.JobReceiverReceptorProxyTimeout)
 	fmt.Fprintf(&b, "%s: %s\n", GATEWAY_CONNECTION_REGISTRAR_IMPL, c.GatewayConnectionRegistrarImpl)
+	fmt.Fprintf(&b, "%s: %d\n", MAX_CONNECTIONS_PER_ACCOUNT, c.MaxConnectionsPerAccount)
+	fmt.Fprintf(&b, "%s: %v\n", MAX_CONNECTIONS_PER_ACCOUNT_OVERRIDES, c.MaxConnectionsPerAccountOverride)
+	fmt.Fprintf(&b, "%s: %d\n", SOFT_CONNECTION_LIMIT_PERCENT, c.SoftConnectionLimitPercent)
+	fmt.Fprintf(&b, "%s: %v\n", SOFT_CONNECTION_LIMIT_OVERRIDES, c.SoftConnectionLimitOverride)
+	fmt.Fprintf(&b, "%s: %d\n", CONNECTION_MANAGER_SHARDS, c.ConnectionManagerShards)
+	fmt.Fprintf(&b, "%s: %s\n", HEALTH_DEGRADED_KEEPALIVE_AGE, c.HealthDegradedKeepaliveAge)
+	fmt.Fprintf(&b, "%s: %s\n", HEALTH_STALLED_KEEPALIVE_AGE, c.HealthStalledKeepaliveAge)
+	fmt.Fprintf(&b, "%s: %d\n", HEALTH_DEGRADED_SEND_CHANNEL_USAGE, c.HealthDegradedSendChannelUsage)
+	fmt.Fprintf(&b, "%s: %d\n", HEALTH_STALLED_SEND_CHANNEL_USAGE, c.HealthStalledSendChannelUsage)
+	fmt.Fprintf(&b, "%s: %s\n", ADMIN_CLIENT_IDS, c.AdminClientIDs)
+	fmt.Fprintf(&b, "%s: %s\n", CONNECTION_LABEL_HEADERS, c.ConnectionLabelHeaders)
+	fmt.Fprintf(&b, "%s: %d\n", CONNECTION_EVENTS_BUFFER_SIZE, c.ConnectionEventsBufferSize)
+	fmt.Fprintf(&b, "%s: %d\n", CONNECTION_EVENTS_HISTORY_SIZE, c.ConnectionEventsHistorySize)
+	fmt.Fprintf(&b, "%s: %s\n", CONNECTION_EVENTS_WEBHOOK_URL, redactString(c.ConnectionEventsWebhookUrl))
+	fmt.Fprintf(&b, "%s: %d\n", CONNECTION_EVENTS_WEBHOOK_MAX_ATTEMPTS, c.ConnectionEventsWebhookMaxAttempts)
+	fmt.Fprintf(&b, "%s: %s\n", CONNECTION_EVENTS_WEBHOOK_BACKOFF_MIN, c.ConnectionEventsWebhookBackoffMin)
+	fmt.Fprintf(&b, "%s: %s\n", CONNECTION_EVENTS_WEBHOOK_BACKOFF_MAX, c.ConnectionEventsWebhookBackoffMax)
+	fmt.Fprintf(&b, "%s: %s\n", CONNECTION_EVENTS_WEBHOOK_ATTEMPT_TIMEOUT, c.ConnectionEventsWebhookAttemptTimeout)
+	fmt.Fprintf(&b, "%s: %d\n", CONNECTION_EVENTS_WEBHOOK_QUEUE_SIZE, c.ConnectionEventsWebhookQueueSize)
+	fmt.Fprintf(&b, "%s: %s\n", CONNECTION_WAIT_MAX_TIMEOUT, c.ConnectionWaitMaxTimeout)
+	fmt.Fprintf(&b, "%s: %s\n", CONNECTION_STATS_CACHE_TTL, c.ConnectionStatsCacheTTL)
+	fmt.Fprintf(&b, "%s: %s\n", CONNECTION_STATS_RECONNECT_WINDOW, c.ConnectionStatsReconnectWindow)
+	fmt.Fprintf(&b, "%s: %s\n", ACCOUNT_THROUGHPUT_WINDOW, c.AccountThroughputWindow)
+	fmt.Fprintf(&b, "%s: %s\n", OUTBOX_STORE_IMPL, c.OutboxStoreImpl)
+	fmt.Fprintf(&b, "%s: %s\n", CONNECTION_POLICY_IMPL, c.ConnectionPolicyImpl)
+	fmt.Fprintf(&b, "%s: %s\n", CONNECTION_POLICY_DENIED_ACCOUNTS, c.ConnectionPolicyDeniedAccounts)
+	fmt.Fprintf(&b, "%s: %s\n", CONNECTION_POLICY_DENIED_NODE_IDS, c.ConnectionPolicyDeniedNodeIDs)
+	fmt.Fprintf(&b, "%s: %s\n", CONNECTION_METADATA_SCHEMA_FILE, c.ConnectionMetadataSchemaFile)
+	fmt.Fprintf(&b, "%s: %t\n", CONNECTION_METADATA_SCHEMA_WARN_ONLY, c.ConnectionMetadataSchemaWarnOnly)
+	fmt.Fprintf(&b, "%s: %s\n", ALLOWED_DIRECTIVES, c.AllowedDirectives)
+	fmt.Fprintf(&b, "%s: %s\n", AFFINITY_PODS, c.AffinityPods)
+	fmt.Fprintf(&b, "%s: %d\n", BROADCAST_CONCURRENCY, c.BroadcastConcurrency)
+	fmt.Fprintf(&b, "%s: %s\n", NODE_SELECTION_STRATEGY, c.NodeSelectionStrategy)
+	fmt.Fprintf(&b, "%s: %s\n", POD_ID, c.PodID)
+	fmt.Fprintf(&b, "%s: %d\n", CAPABILITIES_REFRESH_CONCURRENCY, c.CapabilitiesRefreshConcurrency)
+	fmt.Fprintf(&b, "%s: %d\n", CAPABILITIES_REFRESH_RATE, c.CapabilitiesRefreshRate)
+	fmt.Fprintf(&b, "%s: %d\n", CONNECTION_REAP_CONCURRENCY, c.ConnectionReapConcurrency)
+	fmt.Fprintf(&b, "%s: %s\n", OUTBOX_DATABASE_DRIVER, c.OutboxDatabaseDriver)
+	fmt.Fprintf(&b, "%s: %s\n", OUTBOX_SWEEP_INTERVAL, c.OutboxSweepInterval)
+	fmt.Fprintf(&b, "%s: %s\n", OUTBOX_PENDING_THRESHOLD, c.OutboxPendingThreshold)
+	fmt.Fprintf(&b, "%s: %d\n", OUTBOX_MAX_ATTEMPTS, c.OutboxMaxAttempts)
+	fmt.Fprintf(&b, "%s: %s\n", OUTBOX_RETENTION, c.OutboxRetention)
+	fmt.Fprintf(&b, "%s: %s\n", INVENTORY_EXPORT_TOPIC, c.KafkaInventoryTopic)
+	fmt.Fprintf(&b, "%s: %s\n", INVENTORY_EXPORT_INTERVAL, c.InventoryExportInterval)
+	fmt.Fprintf(&b, "%s: %d\n", INVENTORY_EXPORT_CHUNK_SIZE, c.InventoryExportChunkSize)
+	fmt.Fprintf(&b, "%s: %t\n", DELIVERY_RECEIPTS_ENABLED, c.DeliveryReceiptsEnabled)
+	fmt.Fprintf(&b, "%s: %s\n", DELIVERY_RECEIPTS_TOPIC, c.KafkaDeliveryReceiptsTopic)
+	fmt.Fprintf(&b, "%s: %g\n", ACCESS_LOG_READ_ONLY_SAMPLE_RATE, c.AccessLogReadOnlySampleRate)
+	fmt.Fprintf(&b, "%s: %s\n", ACCESS_LOG_SUPPRESSED_PATHS, c.AccessLogSuppressedPaths)
 	return b.String()
 }
 
@@ -114,18 +424,71 @@ func GetConfig() *Config {
 	options.SetDefault(WRITE_WAIT, 5)
 	options.SetDefault(PONG_WAIT, 25)
 	options.SetDefault(RECEPTOR_SYNC_PING_TIMEOUT, 10)
+	options.SetDefault(RECEPTOR_MAX_CONNECTION_TIMEOUT, 300)
+	options.SetDefault(RECEPTOR_MAX_BACK_OFF, 300)
+	options.SetDefault(SLOW_OPERATION_THRESHOLD, 0)
+	options.SetDefault(SLOW_OPERATION_THRESHOLD_OVERRIDES, "")
+	options.SetDefault(HEALTH_CHECK_TIMEOUT, 2)
+	options.SetDefault(HEALTH_MAX_GOROUTINES, 10000)
+	options.SetDefault(HEALTH_MAX_CONNECTIONS, 0)
+	options.SetDefault(RECEPTOR_CLOSE_TIMEOUT, 5)
+	options.SetDefault(MESSAGE_FORWARD_TIMEOUT, 0)
+	options.SetDefault(RECEPTOR_PAUSED_MESSAGE_LIMIT, 100)
+	options.SetDefault(RECEPTOR_WARMUP_GRACE_PERIOD, 250)
+	options.SetDefault(MAX_IN_FLIGHT_MESSAGES, 0)
+	options.SetDefault(MAX_IN_FLIGHT_MESSAGES_OVERRIDES, "")
+	options.SetDefault(RECEPTOR_SEND_RATE_LIMIT, 0)
+	options.SetDefault(RECEPTOR_SEND_RATE_LIMIT_OVERRIDES, "")
+	options.SetDefault(RECEPTOR_SEND_RATE_LIMIT_BURST, 10)
+	options.SetDefault(RECEPTOR_SEND_RATE_LIMIT_MAX_WAIT, 0)
+	options.SetDefault(RECEPTOR_STRICT_SEND_ORDERING, false)
+	options.SetDefault(ACCOUNT_BANDWIDTH_QUOTA, 0)
+	options.SetDefault(ACCOUNT_BANDWIDTH_QUOTA_OVERRIDES, "")
+	options.SetDefault(ACCOUNT_BANDWIDTH_QUOTA_WINDOW, 3600)
+	options.SetDefault(RECEPTOR_CAPABILITIES_WARMING, false)
+	options.SetDefault(RECEPTOR_CAPABILITIES_WARMING_RATE, 10)
+	options.SetDefault(RECEPTOR_CAPABILITIES_WARMING_MAX_WAIT, 5000)
+	options.SetDefault(RECEPTOR_CAPABILITIES_WARMING_MAX_CONCURRENT, 10)
+	options.SetDefault(RECEPTOR_MIN_PROTOCOL_VERSION, 1)
+	options.SetDefault(DIRECTIVE_METRICS_MAX_ACCOUNTS, 0)
+	options.SetDefault(DIRECTIVE_METRICS_MAX_DIRECTIVES, 50)
+	options.SetDefault(RECEPTOR_ACK_TIMEOUT, 10)
 	options.SetDefault(HTTP_SHUTDOWN_TIMEOUT, 2)
+	options.SetDefault(MANAGEMENT_ADDR, "")
+	options.SetDefault(MANAGEMENT_TLS_CERT_FILE, "")
+	options.SetDefault(MANAGEMENT_TLS_KEY_FILE, "")
+	options.SetDefault(MANAGEMENT_TLS_MIN_VERSION, "1.2")
+	options.SetDefault(MANAGEMENT_TLS_CIPHER_SUITES, []string{})
+	options.SetDefault(MANAGEMENT_MAX_CONCURRENT_REQUESTS, 200)
+	options.SetDefault(MANAGEMENT_CONCURRENT_REQUESTS_QUEUE_TIMEOUT, 1)
+	options.SetDefault(LISTEN_REUSE_PORT, false)
+	options.SetDefault(SHUTDOWN_DRAIN_TIMEOUT, 0)
 	options.SetDefault(MAX_MESSAGE_SIZE, 1*1024*1024)
+	options.SetDefault(MAX_RESPONSE_PAYLOAD_SIZE, 1*1024*1024)
 	options.SetDefault(SOCKET_BUFFER_SIZE, 1024)
+	options.SetDefault(SOCKET_COMPRESSION, false)
+	options.SetDefault(SOCKET_COMPRESSION_LEVEL, 1)
 	options.SetDefault(BUFFERED_CHANNEL_SIZE, 10)
+	options.SetDefault(ACCEPT_RATE, 0)
+	options.SetDefault(ACCEPT_BURST, 100)
 	options.SetDefault(SERVICE_TO_SERVICE_CREDENTIALS, "")
 	options.SetDefault(PROFILE, false)
+	options.SetDefault(DEBUG_PRINCIPAL_HEADER, false)
+	options.SetDefault(PRETTY_JSON_RESPONSES, false)
+	options.SetDefault(JSON_NAMING, "snake_case")
 	options.SetDefault(NODE_ID, "node-cloud-receptor-controller")
 	options.SetDefault(BROKERS, []string{DEFAULT_BROKER_ADDRESS})
 	options.SetDefault(JOBS_TOPIC, "platform.receptor-controller.jobs")
 	options.SetDefault(RESPONSES_TOPIC, "platform.receptor-controller.responses")
 	options.SetDefault(RESPONSES_BATCH_SIZE, 100)
 	options.SetDefault(RESPONSES_BATCH_BYTES, 1048576)
+	options.SetDefault(RESPONSES_BATCHING, false)
+	options.SetDefault(RESPONSES_BATCH_TIMEOUT, 10)
+	options.SetDefault(RESPONSES_COMPRESSION, "lz4")
+	options.SetDefault(RESPONSES_WRITE_TIMEOUT, 10)
+	options.SetDefault(RESPONSES_CLOSE_TIMEOUT, 10)
+	options.SetDefault(JOBS_READ_TIMEOUT, 10)
+	options.SetDefault(JOBS_COMMIT_TIMEOUT, 10)
 	options.SetDefault(JOBS_GROUP_ID, "receptor-controller")
 	options.SetDefault(JOBS_CONSUMER_OFFSET, -1)
 	options.SetDefault(REDIS_HOST, "localhost")
@@ -138,6 +501,57 @@ func GetConfig() *Config {
 	options.SetDefault(JOB_RECEIVER_RECEPTOR_PROXY_PORT, 9090)
 	options.SetDefault(JOB_RECEIVER_RECEPTOR_PROXY_TIMEOUT, 10)
 	options.SetDefault(GATEWAY_CONNECTION_REGISTRAR_IMPL, "local")
+	options.SetDefault(MAX_CONNECTIONS_PER_ACCOUNT, 0)
+	options.SetDefault(MAX_CONNECTIONS_PER_ACCOUNT_OVERRIDES, "")
+	options.SetDefault(SOFT_CONNECTION_LIMIT_PERCENT, 80)
+	options.SetDefault(SOFT_CONNECTION_LIMIT_OVERRIDES, "")
+	options.SetDefault(CONNECTION_MANAGER_SHARDS, 1)
+	options.SetDefault(HEALTH_DEGRADED_KEEPALIVE_AGE, 30)
+	options.SetDefault(HEALTH_STALLED_KEEPALIVE_AGE, 60)
+	options.SetDefault(HEALTH_DEGRADED_SEND_CHANNEL_USAGE, 50)
+	options.SetDefault(HEALTH_STALLED_SEND_CHANNEL_USAGE, 100)
+	options.SetDefault(ADMIN_CLIENT_IDS, []string{})
+	options.SetDefault(CONNECTION_LABEL_HEADERS, []string{})
+	options.SetDefault(CONNECTION_EVENTS_BUFFER_SIZE, 100)
+	options.SetDefault(CONNECTION_EVENTS_HISTORY_SIZE, 1000)
+	options.SetDefault(CONNECTION_EVENTS_WEBHOOK_URL, "")
+	options.SetDefault(CONNECTION_EVENTS_WEBHOOK_MAX_ATTEMPTS, 5)
+	options.SetDefault(CONNECTION_EVENTS_WEBHOOK_BACKOFF_MIN, 100)
+	options.SetDefault(CONNECTION_EVENTS_WEBHOOK_BACKOFF_MAX, 10000)
+	options.SetDefault(CONNECTION_EVENTS_WEBHOOK_ATTEMPT_TIMEOUT, 5)
+	options.SetDefault(CONNECTION_EVENTS_WEBHOOK_QUEUE_SIZE, 100)
+	options.SetDefault(CONNECTION_WAIT_MAX_TIMEOUT, 60)
+	options.SetDefault(CONNECTION_STATS_CACHE_TTL, 5)
+	options.SetDefault(CONNECTION_STATS_RECONNECT_WINDOW, 3600)
+	options.SetDefault(ACCOUNT_THROUGHPUT_WINDOW, 300)
+	options.SetDefault(OUTBOX_STORE_IMPL, "memory")
+	options.SetDefault(CONNECTION_POLICY_IMPL, "allow_all")
+	options.SetDefault(CONNECTION_POLICY_DENIED_ACCOUNTS, []string{})
+	options.SetDefault(CONNECTION_POLICY_DENIED_NODE_IDS, []string{})
+	options.SetDefault(CONNECTION_METADATA_SCHEMA_FILE, "")
+	options.SetDefault(CONNECTION_METADATA_SCHEMA_WARN_ONLY, false)
+	options.SetDefault(ALLOWED_DIRECTIVES, []string{})
+	options.SetDefault(AFFINITY_PODS, []string{})
+	options.SetDefault(BROADCAST_CONCURRENCY, 10)
+	options.SetDefault(NODE_SELECTION_STRATEGY, "round_robin")
+	hostname, _ := os.Hostname()
+	options.SetDefault(POD_ID, hostname)
+	options.SetDefault(CAPABILITIES_REFRESH_CONCURRENCY, 10)
+	options.SetDefault(CAPABILITIES_REFRESH_RATE, 50)
+	options.SetDefault(CONNECTION_REAP_CONCURRENCY, 10)
+	options.SetDefault(OUTBOX_DATABASE_DRIVER, "postgres")
+	options.SetDefault(OUTBOX_DATABASE_URL, "")
+	options.SetDefault(OUTBOX_SWEEP_INTERVAL, 10)
+	options.SetDefault(OUTBOX_PENDING_THRESHOLD, 30)
+	options.SetDefault(OUTBOX_MAX_ATTEMPTS, 3)
+	options.SetDefault(OUTBOX_RETENTION, 3600)
+	options.SetDefault(INVENTORY_EXPORT_TOPIC, "platform.receptor-controller.inventory")
+	options.SetDefault(INVENTORY_EXPORT_INTERVAL, 0)
+	options.SetDefault(INVENTORY_EXPORT_CHUNK_SIZE, 1000)
+	options.SetDefault(DELIVERY_RECEIPTS_ENABLED, false)
+	options.SetDefault(DELIVERY_RECEIPTS_TOPIC, "platform.receptor-controller.delivery-receipts")
+	options.SetDefault(ACCESS_LOG_READ_ONLY_SAMPLE_RATE, 1.0)
+	options.SetDefault(ACCESS_LOG_SUPPRESSED_PATHS, []string{"/healthz"})
 	options.SetEnvPrefix(ENV_PREFIX)
 	options.AutomaticEnv()
 
@@ -146,38 +560,162 @@ func GetConfig() *Config {
 	pingPeriod := calculatePingPeriod(pongWait)
 
 	return &Config{
-		HandshakeReadWait:                options.GetDuration(HANDSHAKE_READ_WAIT) * time.Second,
-		WriteWait:                        writeWait,
-		PongWait:                         pongWait,
-		PingPeriod:                       pingPeriod,
-		ReceptorSyncPingTimeout:          options.GetDuration(RECEPTOR_SYNC_PING_TIMEOUT) * time.Second,
-		HttpShutdownTimeout:              options.GetDuration(HTTP_SHUTDOWN_TIMEOUT) * time.Second,
-		MaxMessageSize:                   options.GetInt64(MAX_MESSAGE_SIZE),
-		SocketBufferSize:                 options.GetInt(SOCKET_BUFFER_SIZE),
-		BufferedChannelSize:              options.GetInt(BUFFERED_CHANNEL_SIZE),
-		ServiceToServiceCredentials:      options.GetStringMap(SERVICE_TO_SERVICE_CREDENTIALS),
-		Profile:                          options.GetBool(PROFILE),
-		ReceptorControllerNodeId:         options.GetString(NODE_ID),
-		KafkaBrokers:                     options.GetStringSlice(BROKERS),
-		KafkaJobsTopic:                   options.GetString(JOBS_TOPIC),
-		KafkaResponsesTopic:              options.GetString(RESPONSES_TOPIC),
-		KafkaResponsesBatchSize:          options.GetInt(RESPONSES_BATCH_SIZE),
-		KafkaResponsesBatchBytes:         options.GetInt(RESPONSES_BATCH_BYTES),
-		KafkaGroupID:                     options.GetString(JOBS_GROUP_ID),
-		KafkaConsumerOffset:              options.GetInt64(JOBS_CONSUMER_OFFSET),
-		RedisHost:                        options.GetString(REDIS_HOST),
-		RedisPort:                        options.GetString(REDIS_PORT),
-		RedisPassword:                    options.GetString(REDIS_PASSWORD),
-		RedisDB:                          options.GetInt(REDIS_DB),
-		JobReceiverReceptorProxyClientID: options.GetString(JOB_RECEIVER_RECEPTOR_PROXY_CLIENT_ID),
-		JobReceiverReceptorProxyPSK:      options.GetString(JOB_RECEIVER_RECEPTOR_PROXY_PSK),
-		JobReceiverReceptorProxyScheme:   options.GetString(JOB_RECEIVER_RECEPTOR_PROXY_SCHEME),
-		JobReceiverReceptorProxyPort:     options.GetInt(JOB_RECEIVER_RECEPTOR_PROXY_PORT),
-		JobReceiverReceptorProxyTimeout:  options.GetDuration(JOB_RECEIVER_RECEPTOR_PROXY_TIMEOUT) * time.Second,
-		GatewayConnectionRegistrarImpl:   options.GetString(GATEWAY_CONNECTION_REGISTRAR_IMPL),
+		HandshakeReadWait:                        options.GetDuration(HANDSHAKE_READ_WAIT) * time.Second,
+		WriteWait:                                writeWait,
+		PongWait:                                 pongWait,
+		PingPeriod:                               pingPeriod,
+		ReceptorSyncPingTimeout:                  options.GetDuration(RECEPTOR_SYNC_PING_TIMEOUT) * time.Second,
+		ReceptorMaxConnectionTimeout:             options.GetDuration(RECEPTOR_MAX_CONNECTION_TIMEOUT) * time.Second,
+		ReceptorMaxBackOff:                       options.GetDuration(RECEPTOR_MAX_BACK_OFF) * time.Second,
+		SlowOperationThreshold:                   options.GetDuration(SLOW_OPERATION_THRESHOLD) * time.Millisecond,
+		SlowOperationThresholdOverride:           getIntMap(options, SLOW_OPERATION_THRESHOLD_OVERRIDES),
+		HealthCheckTimeout:                       options.GetDuration(HEALTH_CHECK_TIMEOUT) * time.Second,
+		HealthMaxGoroutines:                      options.GetInt(HEALTH_MAX_GOROUTINES),
+		HealthMaxConnections:                     options.GetInt(HEALTH_MAX_CONNECTIONS),
+		ReceptorCloseTimeout:                     options.GetDuration(RECEPTOR_CLOSE_TIMEOUT) * time.Second,
+		MessageForwardTimeout:                    options.GetDuration(MESSAGE_FORWARD_TIMEOUT) * time.Second,
+		ReceptorPausedMessageLimit:               options.GetInt(RECEPTOR_PAUSED_MESSAGE_LIMIT),
+		ReceptorWarmupGracePeriod:                options.GetDuration(RECEPTOR_WARMUP_GRACE_PERIOD) * time.Millisecond,
+		MaxInFlightMessages:                      options.GetInt(MAX_IN_FLIGHT_MESSAGES),
+		MaxInFlightMessagesOverride:              getIntMap(options, MAX_IN_FLIGHT_MESSAGES_OVERRIDES),
+		ReceptorSendRateLimit:                    options.GetInt(RECEPTOR_SEND_RATE_LIMIT),
+		ReceptorSendRateLimitOverride:            getIntMap(options, RECEPTOR_SEND_RATE_LIMIT_OVERRIDES),
+		ReceptorSendRateLimitBurst:               options.GetInt(RECEPTOR_SEND_RATE_LIMIT_BURST),
+		ReceptorSendRateLimitMaxWait:             options.GetDuration(RECEPTOR_SEND_RATE_LIMIT_MAX_WAIT) * time.Millisecond,
+		ReceptorStrictSendOrdering:               options.GetBool(RECEPTOR_STRICT_SEND_ORDERING),
+		AccountBandwidthQuota:                    options.GetInt(ACCOUNT_BANDWIDTH_QUOTA),
+		AccountBandwidthQuotaOverride:            getIntMap(options, ACCOUNT_BANDWIDTH_QUOTA_OVERRIDES),
+		AccountBandwidthQuotaWindow:              options.GetDuration(ACCOUNT_BANDWIDTH_QUOTA_WINDOW) * time.Second,
+		ReceptorCapabilitiesWarming:              options.GetBool(RECEPTOR_CAPABILITIES_WARMING),
+		ReceptorCapabilitiesWarmingRate:          options.GetInt(RECEPTOR_CAPABILITIES_WARMING_RATE),
+		ReceptorCapabilitiesWarmingMaxWait:       options.GetDuration(RECEPTOR_CAPABILITIES_WARMING_MAX_WAIT) * time.Millisecond,
+		ReceptorCapabilitiesWarmingMaxConcurrent: options.GetInt(RECEPTOR_CAPABILITIES_WARMING_MAX_CONCURRENT),
+		ReceptorMinProtocolVersion:               options.GetInt(RECEPTOR_MIN_PROTOCOL_VERSION),
+		DirectiveMetricsMaxAccounts:              options.GetInt(DIRECTIVE_METRICS_MAX_ACCOUNTS),
+		DirectiveMetricsMaxDirectives:            options.GetInt(DIRECTIVE_METRICS_MAX_DIRECTIVES),
+		ReceptorAckTimeout:                       options.GetDuration(RECEPTOR_ACK_TIMEOUT) * time.Second,
+		HttpShutdownTimeout:                      options.GetDuration(HTTP_SHUTDOWN_TIMEOUT) * time.Second,
+		ManagementAddr:                           options.GetString(MANAGEMENT_ADDR),
+		ManagementTLSCertFile:                    options.GetString(MANAGEMENT_TLS_CERT_FILE),
+		ManagementTLSKeyFile:                     options.GetString(MANAGEMENT_TLS_KEY_FILE),
+		ManagementTLSMinVersion:                  options.GetString(MANAGEMENT_TLS_MIN_VERSION),
+		ManagementTLSCipherSuites:                options.GetStringSlice(MANAGEMENT_TLS_CIPHER_SUITES),
+		ManagementMaxConcurrentRequests:          options.GetInt(MANAGEMENT_MAX_CONCURRENT_REQUESTS),
+		ManagementConcurrentRequestsQueueTimeout: options.GetDuration(MANAGEMENT_CONCURRENT_REQUESTS_QUEUE_TIMEOUT) * time.Second,
+		ListenReusePort:                          options.GetBool(LISTEN_REUSE_PORT),
+		ShutdownDrainTimeout:                     options.GetDuration(SHUTDOWN_DRAIN_TIMEOUT) * time.Second,
+		MaxMessageSize:                           options.GetInt64(MAX_MESSAGE_SIZE),
+		MaxResponsePayloadSize:                   options.GetUint32(MAX_RESPONSE_PAYLOAD_SIZE),
+		SocketBufferSize:                         options.GetInt(SOCKET_BUFFER_SIZE),
+		SocketCompression:                        options.GetBool(SOCKET_COMPRESSION),
+		SocketCompressionLevel:                   options.GetInt(SOCKET_COMPRESSION_LEVEL),
+		BufferedChannelSize:                      options.GetInt(BUFFERED_CHANNEL_SIZE),
+		AcceptRate:                               options.GetInt(ACCEPT_RATE),
+		AcceptBurst:                              options.GetInt(ACCEPT_BURST),
+		ServiceToServiceCredentials:              options.GetStringMap(SERVICE_TO_SERVICE_CREDENTIALS),
+		Profile:                                  options.GetBool(PROFILE),
+		DebugPrincipalHeader:                     options.GetBool(DEBUG_PRINCIPAL_HEADER),
+		PrettyJSONResponses:                      options.GetBool(PRETTY_JSON_RESPONSES),
+		JSONNaming:                               options.GetString(JSON_NAMING),
+		ReceptorControllerNodeId:                 options.GetString(NODE_ID),
+		KafkaBrokers:                             options.GetStringSlice(BROKERS),
+		KafkaJobsTopic:                           options.GetString(JOBS_TOPIC),
+		KafkaResponsesTopic:                      options.GetString(RESPONSES_TOPIC),
+		KafkaResponsesBatchSize:                  options.GetInt(RESPONSES_BATCH_SIZE),
+		KafkaResponsesBatchBytes:                 options.GetInt(RESPONSES_BATCH_BYTES),
+		KafkaResponsesBatching:                   options.GetBool(RESPONSES_BATCHING),
+		KafkaResponsesBatchTimeout:               options.GetDuration(RESPONSES_BATCH_TIMEOUT) * time.Millisecond,
+		KafkaResponsesCompression:                options.GetString(RESPONSES_COMPRESSION),
+		KafkaResponsesWriteTimeout:               options.GetDuration(RESPONSES_WRITE_TIMEOUT) * time.Second,
+		KafkaResponsesCloseTimeout:               options.GetDuration(RESPONSES_CLOSE_TIMEOUT) * time.Second,
+		KafkaJobsReadTimeout:                     options.GetDuration(JOBS_READ_TIMEOUT) * time.Second,
+		KafkaJobsCommitTimeout:                   options.GetDuration(JOBS_COMMIT_TIMEOUT) * time.Second,
+		KafkaGroupID:                             options.GetString(JOBS_GROUP_ID),
+		KafkaConsumerOffset:                      options.GetInt64(JOBS_CONSUMER_OFFSET),
+		RedisHost:                                options.GetString(REDIS_HOST),
+		RedisPort:                                options.GetString(REDIS_PORT),
+		RedisPassword:                            options.GetString(REDIS_PASSWORD),
+		RedisDB:                                  options.GetInt(REDIS_DB),
+		JobReceiverReceptorProxyClientID:         options.GetString(JOB_RECEIVER_RECEPTOR_PROXY_CLIENT_ID),
+		JobReceiverReceptorProxyPSK:              options.GetString(JOB_RECEIVER_RECEPTOR_PROXY_PSK),
+		JobReceiverReceptorProxyScheme:           options.GetString(JOB_RECEIVER_RECEPTOR_PROXY_SCHEME),
+		JobReceiverReceptorProxyPort:             options.GetInt(JOB_RECEIVER_RECEPTOR_PROXY_PORT),
+		JobReceiverReceptorProxyTimeout:          options.GetDuration(JOB_RECEIVER_RECEPTOR_PROXY_TIMEOUT) * time.Second,
+		GatewayConnectionRegistrarImpl:           options.GetString(GATEWAY_CONNECTION_REGISTRAR_IMPL),
+		MaxConnectionsPerAccount:                 options.GetInt(MAX_CONNECTIONS_PER_ACCOUNT),
+		MaxConnectionsPerAccountOverride:         getIntMap(options, MAX_CONNECTIONS_PER_ACCOUNT_OVERRIDES),
+		SoftConnectionLimitPercent:               options.GetInt(SOFT_CONNECTION_LIMIT_PERCENT),
+		SoftConnectionLimitOverride:              getIntMap(options, SOFT_CONNECTION_LIMIT_OVERRIDES),
+		ConnectionManagerShards:                  options.GetInt(CONNECTION_MANAGER_SHARDS),
+		HealthDegradedKeepaliveAge:               options.GetDuration(HEALTH_DEGRADED_KEEPALIVE_AGE) * time.Second,
+		HealthStalledKeepaliveAge:                options.GetDuration(HEALTH_STALLED_KEEPALIVE_AGE) * time.Second,
+		HealthDegradedSendChannelUsage:           options.GetInt(HEALTH_DEGRADED_SEND_CHANNEL_USAGE),
+		HealthStalledSendChannelUsage:            options.GetInt(HEALTH_STALLED_SEND_CHANNEL_USAGE),
+		AdminClientIDs:                           options.GetStringSlice(ADMIN_CLIENT_IDS),
+		ConnectionLabelHeaders:                   options.GetStringSlice(CONNECTION_LABEL_HEADERS),
+		ConnectionEventsBufferSize:               options.GetInt(CONNECTION_EVENTS_BUFFER_SIZE),
+		ConnectionEventsHistorySize:              options.GetInt(CONNECTION_EVENTS_HISTORY_SIZE),
+		ConnectionEventsWebhookUrl:               options.GetString(CONNECTION_EVENTS_WEBHOOK_URL),
+		ConnectionEventsWebhookMaxAttempts:       options.GetInt(CONNECTION_EVENTS_WEBHOOK_MAX_ATTEMPTS),
+		ConnectionEventsWebhookBackoffMin:        options.GetDuration(CONNECTION_EVENTS_WEBHOOK_BACKOFF_MIN) * time.Millisecond,
+		ConnectionEventsWebhookBackoffMax:        options.GetDuration(CONNECTION_EVENTS_WEBHOOK_BACKOFF_MAX) * time.Millisecond,
+		ConnectionEventsWebhookAttemptTimeout:    options.GetDuration(CONNECTION_EVENTS_WEBHOOK_ATTEMPT_TIMEOUT) * time.Second,
+		ConnectionEventsWebhookQueueSize:         options.GetInt(CONNECTION_EVENTS_WEBHOOK_QUEUE_SIZE),
+		ConnectionWaitMaxTimeout:                 options.GetDuration(CONNECTION_WAIT_MAX_TIMEOUT) * time.Second,
+		ConnectionStatsCacheTTL:                  options.GetDuration(CONNECTION_STATS_CACHE_TTL) * time.Second,
+		ConnectionStatsReconnectWindow:           options.GetDuration(CONNECTION_STATS_RECONNECT_WINDOW) * time.Second,
+		AccountThroughputWindow:                  options.GetDuration(ACCOUNT_THROUGHPUT_WINDOW) * time.Second,
+		OutboxStoreImpl:                          options.GetString(OUTBOX_STORE_IMPL),
+		ConnectionPolicyImpl:                     options.GetString(CONNECTION_POLICY_IMPL),
+		ConnectionPolicyDeniedAccounts:           options.GetStringSlice(CONNECTION_POLICY_DENIED_ACCOUNTS),
+		ConnectionPolicyDeniedNodeIDs:            options.GetStringSlice(CONNECTION_POLICY_DENIED_NODE_IDS),
+		ConnectionMetadataSchemaFile:             options.GetString(CONNECTION_METADATA_SCHEMA_FILE),
+		ConnectionMetadataSchemaWarnOnly:         options.GetBool(CONNECTION_METADATA_SCHEMA_WARN_ONLY),
+		AllowedDirectives:                        options.GetStringSlice(ALLOWED_DIRECTIVES),
+		AffinityPods:                             options.GetStringSlice(AFFINITY_PODS),
+		BroadcastConcurrency:                     options.GetInt(BROADCAST_CONCURRENCY),
+		NodeSelectionStrategy:                    options.GetString(NODE_SELECTION_STRATEGY),
+		PodID:                                    options.GetString(POD_ID),
+		CapabilitiesRefreshConcurrency:           options.GetInt(CAPABILITIES_REFRESH_CONCURRENCY),
+		CapabilitiesRefreshRate:                  options.GetInt(CAPABILITIES_REFRESH_RATE),
+		ConnectionReapConcurrency:                options.GetInt(CONNECTION_REAP_CONCURRENCY),
+		OutboxDatabaseDriver:                     options.GetString(OUTBOX_DATABASE_DRIVER),
+		OutboxDatabaseUrl:                        options.GetString(OUTBOX_DATABASE_URL),
+		OutboxSweepInterval:                      options.GetDuration(OUTBOX_SWEEP_INTERVAL) * time.Second,
+		OutboxPendingThreshold:                   options.GetDuration(OUTBOX_PENDING_THRESHOLD) * time.Second,
+		OutboxMaxAttempts:                        options.GetInt(OUTBOX_MAX_ATTEMPTS),
+		OutboxRetention:                          options.GetDuration(OUTBOX_RETENTION) * time.Second,
+		KafkaInventoryTopic:                      options.GetString(INVENTORY_EXPORT_TOPIC),
+		InventoryExportInterval:                  options.GetDuration(INVENTORY_EXPORT_INTERVAL) * time.Second,
+		InventoryExportChunkSize:                 options.GetInt(INVENTORY_EXPORT_CHUNK_SIZE),
+		DeliveryReceiptsEnabled:                  options.GetBool(DELIVERY_RECEIPTS_ENABLED),
+		KafkaDeliveryReceiptsTopic:               options.GetString(DELIVERY_RECEIPTS_TOPIC),
+		AccessLogReadOnlySampleRate:              options.GetFloat64(ACCESS_LOG_READ_ONLY_SAMPLE_RATE),
+		AccessLogSuppressedPaths:                 options.GetStringSlice(ACCESS_LOG_SUPPRESSED_PATHS),
 	}
 }
 
+// getIntMap reads a json map (ex. '{"0000001": 5, "0000002": "10"}') from the
+// configuration.  Entries that cannot be converted to an int are ignored.
+func getIntMap(options *viper.Viper, key string) map[string]int {
+	intMap := make(map[string]int)
+
+	for k, v := range options.GetStringMap(key) {
+		switch value := v.(type) {
+		case float64:
+			intMap[k] = int(value)
+		case int:
+			intMap[k] = value
+		case string:
+			if i, err := strconv.Atoi(value); err == nil {
+				intMap[k] = i
+			}
+		}
+	}
+
+	return intMap
+}
+
 func calculatePingPeriod(pongWait time.Duration) time.Duration {
 	pingPeriod := (pongWait * 9) / 10
 	return pingPeriod
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// RedactedValue replaces the value of the sensitive settings
+const RedactedValue = "***"
+
+// sensitiveFieldMarkers identify the settings whose values must never be
+// shown as is.  They are matched against the names of the Config fields so
+// that a new secret is redacted without having to be listed here (e.g. any
+// *Password, *PSK or *Credentials field).  The urls are redacted because they
+// can embed credentials (e.g. the outbox database url).
+var sensitiveFieldMarkers = []string{
+	"Password",
+	"PSK",
+	"Credentials",
+	"Secret",
+	"Token",
+	"Key",
+	"Url",
+	"DSN",
+}
+
+func isSensitiveField(name string) bool {
+	for _, marker := range sensitiveFieldMarkers {
+		if strings.Contains(name, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redacted returns the effective config keyed by the names of the Config
+// fields, with the durations formatted as strings.  The values of the sensitive settings are replaced with
+// RedactedValue; the keys of the sensitive maps (e.g. the client ids of the
+// service to service credentials) are kept.  The sensitive settings that are
+// not set are left empty so that it is still possible to tell whether they
+// have been configured.
+func (c Config) Redacted() map[string]interface{} {
+	redacted := make(map[string]interface{})
+
+	value := reflect.ValueOf(c)
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.PkgPath != "" {
+			// unexported
+			continue
+		}
+
+		fieldValue := value.Field(i)
+		if !isSensitiveField(field.Name) {
+			if duration, ok := fieldValue.Interface().(time.Duration); ok {
+				redacted[field.Name] = duration.String()
+			} else {
+				redacted[field.Name] = fieldValue.Interface()
+			}
+			continue
+		}
+
+		redacted[field.Name] = redactValue(fieldValue)
+	}
+
+	return redacted
+}
+
+// redactString redacts a sensitive setting for String the same way as
+// Redacted does
+func redactString(value string) string {
+	return redactValue(reflect.ValueOf(value)).(string)
+}
+
+func redactValue(value reflect.Value) interface{} {
+	switch value.Kind() {
+	case reflect.Map:
+		keys := make(map[string]string, value.Len())
+		for _, key := range value.MapKeys() {
+			keys[fmt.Sprint(key.Interface())] = RedactedValue
+		}
+		return keys
+	case reflect.Slice:
+		values := make([]string, value.Len())
+		for i := range values {
+			values[i] = RedactedValue
+		}
+		return values
+	case reflect.String:
+		if value.Len() == 0 {
+			return ""
+		}
+		return RedactedValue
+	default:
+		if value.IsZero() {
+			return value.Interface()
+		}
+		return RedactedValue
+	}
+}
@@ -0,0 +1,168 @@
+package controller
+
+import (
+	"sync"
+	"time"
+)
+
+// accountThroughputBuckets is the number of buckets the window of the
+// throughput counters is split into.  The counters roll over one bucket at a
+// time.
+const accountThroughputBuckets = 60
+
+// AccountThroughput is the number of messages sent to the nodes of an account,
+// the number of bytes of their payloads and the number of messages that could
+// not be sent, within the window
+type AccountThroughput struct {
+	Messages int64
+	Bytes    int64
+	Errors   int64
+}
+
+type throughputBucket struct {
+	// slot identifies the period of time counted by the bucket.  A bucket
+	// whose slot has fallen out of the window is reset before it is reused.
+	slot int64
+	AccountThroughput
+}
+
+// AccountThroughputTracker maintains rolling counters of the messages sent
+// to the nodes, by account.  The counters are kept in memory and only cover
+// the messages sent by this pod.  They are not exported to prometheus, whose
+// account label is capped (see Directive_Metrics_Max_Accounts).
+type AccountThroughputTracker struct {
+	window     time.Duration
+	bucketSize time.Duration
+
+	accounts  map[string]*[accountThroughputBuckets]throughputBucket
+	lastPrune time.Time
+
+	sync.Mutex
+}
+
+func NewAccountThroughputTracker(window time.Duration) *AccountThroughputTracker {
+	bucketSize := window / accountThroughputBuckets
+	if bucketSize <= 0 {
+		bucketSize = time.Millisecond
+	}
+
+	return &AccountThroughputTracker{
+		window:     bucketSize * accountThroughputBuckets,
+		bucketSize: bucketSize,
+		accounts:   make(map[string]*[accountThroughputBuckets]throughputBucket),
+		lastPrune:  time.Now(),
+	}
+}
+
+func (t *AccountThroughputTracker) slot(now time.Time) int64 {
+	return now.UnixNano() / int64(t.bucketSize)
+}
+
+func (t *AccountThroughputTracker) record(account string, now time.Time, update func(*AccountThroughput)) {
+	t.Lock()
+	defer t.Unlock()
+
+	// Accounts that stop sending would otherwise be kept forever
+	if now.Sub(t.lastPrune) > t.window {
+		t.prune(now)
+	}
+
+	buckets, exists := t.accounts[account]
+	if !exists {
+		buckets = new([accountThroughputBuckets]throughputBucket)
+		t.accounts[account] = buckets
+	}
+
+	slot := t.slot(now)
+	bucket := &buckets[slot%accountThroughputBuckets]
+	if bucket.slot != slot {
+		*bucket = throughputBucket{slot: slot}
+	}
+
+	update(&bucket.AccountThroughput)
+}
+
+// RecordSent counts a message passed to a node of the account
+func (t *AccountThroughputTracker) RecordSent(account string, bytes int) {
+	t.recordSent(account, bytes, time.Now())
+}
+
+// RecordFailed counts a message that could not be passed to a node of the
+// account
+func (t *AccountThroughputTracker) RecordFailed(account string) {
+	t.recordFailed(account, time.Now())
+}
+
+func (t *AccountThroughputTracker) recordSent(account string, bytes int, now time.Time) {
+	t.record(account, now, func(throughput *AccountThroughput) {
+		throughput.Messages++
+		throughput.Bytes += int64(bytes)
+	})
+}
+
+func (t *AccountThroughputTracker) recordFailed(account string, now time.Time) {
+	t.record(account, now, func(throughput *AccountThroughput) {
+		throughput.Errors++
+	})
+}
+
+// Throughput returns the counters of the account within the window
+func (t *AccountThroughputTracker) Throughput(account string) (AccountThroughput, time.Duration) {
+	return t.throughputAt(account, time.Now()), t.window
+}
+
+func (t *AccountThroughputTracker) throughputAt(account string, now time.Time) AccountThroughput {
+	t.Lock()
+	defer t.Unlock()
+
+	return t.throughput(account, now)
+}
+
+func (t *AccountThroughputTracker) throughput(account string, now time.Time) AccountThroughput {
+	var throughput AccountThroughput
+
+	buckets, exists := t.accounts[account]
+	if !exists {
+		return throughput
+	}
+
+	oldest := t.slot(now) - accountThroughputBuckets
+	for _, bucket := range buckets {
+		if bucket.slot > oldest {
+			throughput.Messages += bucket.Messages
+			throughput.Bytes += bucket.Bytes
+			throughput.Errors += bucket.Errors
+		}
+	}
+
+	return throughput
+}
+
+func (t *AccountThroughputTracker) prune(now time.Time) {
+	for account := range t.accounts {
+		if t.throughput(account, now) == (AccountThroughput{}) {
+			delete(t.accounts, account)
+		}
+	}
+
+	t.lastPrune = now
+}
+
+// SetAccountThroughputTracker enables counting the messages sent by the
+// receptor services created by the factory, by account
+func (fact *ReceptorServiceFactory) SetAccountThroughputTracker(tracker *AccountThroughputTracker) {
+	fact.throughput = tracker
+}
+
+func (r *ReceptorService) recordThroughput(message Message, err error) {
+	if r.throughput == nil {
+		return
+	}
+
+	if err != nil {
+		r.throughput.RecordFailed(r.AccountNumber)
+		return
+	}
+
+	r.throughput.RecordSent(r.AccountNumber, PayloadSize(message.Payload))
+}
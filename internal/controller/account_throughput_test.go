@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestAccountThroughputTracker(t *testing.T) {
+	tracker := NewAccountThroughputTracker(time.Minute)
+	start := time.Now()
+
+	tracker.recordSent("0000001", 100, start)
+	tracker.recordSent("0000001", 50, start.Add(10*time.Second))
+	tracker.recordFailed("0000001", start.Add(20*time.Second))
+	tracker.recordSent("0000002", 10, start.Add(20*time.Second))
+
+	expected := AccountThroughput{Messages: 2, Bytes: 150, Errors: 1}
+	if throughput := tracker.throughputAt("0000001", start.Add(30*time.Second)); throughput != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, throughput)
+	}
+
+	expected = AccountThroughput{Messages: 1, Bytes: 10}
+	if throughput := tracker.throughputAt("0000002", start.Add(30*time.Second)); throughput != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, throughput)
+	}
+
+	if throughput := tracker.throughputAt("0000003", start); throughput != (AccountThroughput{}) {
+		t.Fatalf("Expected an unknown account to have no throughput, got %+v", throughput)
+	}
+}
+
+func TestAccountThroughputTrackerRollsOver(t *testing.T) {
+	tracker := NewAccountThroughputTracker(time.Minute)
+	start := time.Now()
+
+	tracker.recordSent("0000001", 100, start)
+	tracker.recordFailed("0000001", start.Add(45*time.Second))
+	tracker.recordSent("0000001", 10, start.Add(70*time.Second))
+
+	// The first message has fallen out of the window
+	expected := AccountThroughput{Messages: 1, Bytes: 10, Errors: 1}
+	if throughput := tracker.throughputAt("0000001", start.Add(75*time.Second)); throughput != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, throughput)
+	}
+
+	// A bucket reused after a full window does not carry the old counts
+	tracker.recordSent("0000001", 1, start.Add(130*time.Second))
+
+	expected = AccountThroughput{Messages: 1, Bytes: 1}
+	if throughput := tracker.throughputAt("0000001", start.Add(130*time.Second)); throughput != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, throughput)
+	}
+}
+
+func TestAccountThroughputTrackerForgetsIdleAccounts(t *testing.T) {
+	tracker := NewAccountThroughputTracker(time.Minute)
+	start := time.Now()
+
+	tracker.recordSent("0000001", 100, start)
+	tracker.recordSent("0000002", 100, start.Add(2*time.Minute))
+
+	tracker.Lock()
+	defer tracker.Unlock()
+
+	if _, exists := tracker.accounts["0000001"]; exists {
+		t.Fatalf("Expected the idle account to be forgotten")
+	}
+}
+
+func TestReceptorServiceRecordsTheThroughputOfTheAccount(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.ReceptorWarmupGracePeriod = 0
+	cfg.AllowedDirectives = []string{"worker:action"}
+
+	tracker := NewAccountThroughputTracker(time.Minute)
+
+	factory := NewReceptorServiceFactory(nil, NewInMemoryOutboxStore(), cfg)
+	factory.SetAccountThroughputTracker(tracker)
+
+	transport := newBufferedTestTransport()
+	receptor := factory.NewReceptorService(logger.Log.WithFields(logrus.Fields{"account": testAccount}), testAccount, "node-cloud-receptor-controller")
+	receptor.RegisterConnection(testNodeID, nil, transport)
+	defer receptor.Close(context.TODO())
+
+	for i := 0; i < 2; i++ {
+		if _, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action"); err != nil {
+			t.Fatalf("Expected the error to be nil, got %v", err)
+		}
+	}
+
+	if _, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:denied"); err == nil {
+		t.Fatalf("Expected the directive to be rejected")
+	}
+
+	expected := AccountThroughput{Messages: 2, Bytes: int64(2 * len(`"payload"`)), Errors: 1}
+	if throughput, _ := tracker.Throughput(testAccount); throughput != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, throughput)
+	}
+}
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+type AckTimeoutError struct {
+}
+
+func (e AckTimeoutError) Error() string {
+	return "timed out waiting for the node to acknowledge the message"
+}
+
+// MessageAcknowledger is implemented by receptors that can wait for the node
+// to acknowledge the receipt of a message
+type MessageAcknowledger interface {
+	SendMessageWithAck(context.Context, string, string, []string, interface{}, string) (*uuid.UUID, error)
+}
+
+// SendMessageWithAck sends the message and waits (up to the configured ack
+// timeout) for the node to acknowledge its receipt.  If the ack does not
+// arrive in time, the message id is returned along with an AckTimeoutError;
+// the message may still be delivered and acknowledged later.
+func (r *ReceptorService) SendMessageWithAck(msgSenderCtx context.Context, account string, recipient string, route []string, payload interface{}, directive string) (*uuid.UUID, error) {
+
+	if account != r.AccountNumber {
+		return nil, accountMismatch
+	}
+
+	messageID, err := uuid.NewRandom()
+	if err != nil {
+		r.logger.Info("Unable to generate UUID for routing the job...cannot proceed")
+		return nil, err
+	}
+
+	// Register before sending so that an early ack cannot be missed
+	ackChannel := make(chan struct{}, 1)
+	r.ackDispatcherRegistrar.Register(messageID, ackChannel)
+	defer r.ackDispatcherRegistrar.Unregister(messageID)
+
+	if err := r.submitMessage(msgSenderCtx, messageID, recipient, route, payload, directive); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(r.config.ReceptorAckTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-ackChannel:
+		return &messageID, nil
+
+	case <-timer.C:
+		r.logger.WithFields(logrus.Fields{"message_id": messageID}).Info("Timed out waiting for the message to be acknowledged")
+		return &messageID, AckTimeoutError{}
+
+	case <-r.Transport.Ctx.Done():
+		r.logger.Info("Connection to receptor network lost")
+		return &messageID, ErrConnectionClosed
+
+	case <-msgSenderCtx.Done():
+		r.logger.Info("Message cancelled by sender")
+		return &messageID, requestCancelledBySender
+	}
+}
+
+// RecordAck records that the node has acknowledged the message.  The outbox
+// is updated whether or not a sender is waiting for the ack.
+func (r *ReceptorService) RecordAck(messageID uuid.UUID) {
+	r.logger.WithFields(logrus.Fields{"message_id": messageID}).Debug("Message acknowledged")
+
+	if !r.isCancelling(messageID) {
+		r.updateOutboxStatus(messageID, OUTBOX_ACKNOWLEDGED_STATUS)
+		r.recordAckReceipt(messageID)
+	}
+
+	if ackChannel := r.ackDispatcherRegistrar.GetDispatchChannel(messageID); ackChannel != nil {
+		select {
+		case ackChannel <- struct{}{}:
+		default:
+			// Duplicate ack, the sender has already been notified
+		}
+	}
+}
+
+// AckDispatcherTable keeps track of the senders that are waiting for a
+// message to be acknowledged
+type AckDispatcherTable struct {
+	dispatchTable map[uuid.UUID]chan struct{}
+	sync.Mutex
+}
+
+func (dt *AckDispatcherTable) Register(msgID uuid.UUID, ackChannel chan struct{}) {
+	dt.Lock()
+	dt.dispatchTable[msgID] = ackChannel
+	dt.Unlock()
+}
+
+func (dt *AckDispatcherTable) Unregister(msgID uuid.UUID) {
+	dt.Lock()
+	delete(dt.dispatchTable, msgID)
+	dt.Unlock()
+}
+
+func (dt *AckDispatcherTable) GetDispatchChannel(msgID uuid.UUID) chan struct{} {
+	dt.Lock()
+	defer dt.Unlock()
+	return dt.dispatchTable[msgID]
+}
@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+type AckHandler struct {
+	Receptor  *ReceptorService
+	Transport *Transport
+	Logger    *logrus.Entry
+}
+
+func (ah AckHandler) HandleMessage(ctx context.Context, m protocol.Message) {
+
+	if m.Type() != protocol.AckMessageType {
+		ah.Logger.Infof("Invalid message type (type: %d): %v", m.Type(), m)
+		return
+	}
+
+	ackMessage, ok := m.(*protocol.AckMessage)
+	if !ok {
+		ah.Logger.Info("Unable to convert message into AckMessage")
+		return
+	}
+
+	messageID, err := uuid.Parse(ackMessage.MessageID)
+	if err != nil {
+		ah.Logger.WithFields(logrus.Fields{"error": err}).Infof("Unable to convert the acknowledged message id (%s) into a UUID", ackMessage.MessageID)
+		return
+	}
+
+	ah.Receptor.RecordAck(messageID)
+
+	if ackMessage.RetryAfter > 0 {
+		ah.Receptor.BackOff(retryAfterDuration(ackMessage.RetryAfter))
+	}
+}
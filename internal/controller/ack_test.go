@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+func TestReceptorServiceSendMessageWithAck(t *testing.T) {
+	cfg := config.GetConfig()
+	outbox := NewInMemoryOutboxStore()
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorServiceWithOutbox(cfg, transport, outbox)
+	defer receptor.Close(context.TODO())
+
+	// Play the part of the node: ack the message once it reaches the transport
+	go func() {
+		msg := <-transport.Send
+		payloadMessage := msg.Message.(*protocol.PayloadMessage)
+		receptor.RecordAck(uuid.MustParse(payloadMessage.Data.MessageID))
+	}()
+
+	messageID, err := receptor.SendMessageWithAck(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action")
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	entry, _ := outbox.Get(context.TODO(), *messageID)
+	if entry.Status != OUTBOX_ACKNOWLEDGED_STATUS {
+		t.Fatalf("Expected the message to be %s, got %s", OUTBOX_ACKNOWLEDGED_STATUS, entry.Status)
+	}
+}
+
+func TestReceptorServiceSendMessageWithAckTimesOut(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.ReceptorAckTimeout = 50 * time.Millisecond
+	outbox := NewInMemoryOutboxStore()
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorServiceWithOutbox(cfg, transport, outbox)
+	defer receptor.Close(context.TODO())
+
+	messageID, err := receptor.SendMessageWithAck(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action")
+	if err != (AckTimeoutError{}) {
+		t.Fatalf("Expected %v, got %v", AckTimeoutError{}, err)
+	}
+
+	if messageID == nil {
+		t.Fatalf("Expected the message id to be returned along with the timeout")
+	}
+
+	entry, _ := outbox.Get(context.TODO(), *messageID)
+	if entry.Status != OUTBOX_PENDING_STATUS {
+		t.Fatalf("Expected the message to be %s, got %s", OUTBOX_PENDING_STATUS, entry.Status)
+	}
+}
+
+func TestAckHandlerRecordsAckOfFireAndForgetMessage(t *testing.T) {
+	cfg := config.GetConfig()
+	outbox := NewInMemoryOutboxStore()
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorServiceWithOutbox(cfg, transport, outbox)
+	defer receptor.Close(context.TODO())
+
+	messageID, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action")
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	handler := AckHandler{
+		Receptor:  receptor,
+		Transport: transport,
+		Logger:    logger.Log.WithFields(logrus.Fields{"account": testAccount}),
+	}
+	handler.HandleMessage(context.TODO(), &protocol.AckMessage{Command: protocol.AckCommand, ID: testNodeID, MessageID: messageID.String()})
+
+	entry, _ := outbox.Get(context.TODO(), *messageID)
+	if entry.Status != OUTBOX_ACKNOWLEDGED_STATUS {
+		t.Fatalf("Expected the message to be %s, got %s", OUTBOX_ACKNOWLEDGED_STATUS, entry.Status)
+	}
+}
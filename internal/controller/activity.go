@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// ActivityTracker records the last time that a message was read from or
+// written to a connection.  Unlike the keepalive, pings and pongs are not
+// counted as activity.
+type ActivityTracker struct {
+	lastActivity int64
+}
+
+func NewActivityTracker() *ActivityTracker {
+	at := &ActivityTracker{}
+	at.RecordActivity()
+	return at
+}
+
+func (at *ActivityTracker) RecordActivity() {
+	atomic.StoreInt64(&at.lastActivity, time.Now().UnixNano())
+}
+
+func (at *ActivityTracker) LastActivity() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&at.lastActivity))
+}
+
+// ActivityReporter is implemented by receptors that know when their
+// connection was last used
+type ActivityReporter interface {
+	GetLastActivity(ctx context.Context) (time.Time, error)
+}
+
+// GetLastActivity returns the last time that a message was exchanged with the
+// node.  The zero time is returned if the transport does not track the
+// activity of the connection.
+func (r *ReceptorService) GetLastActivity(ctx context.Context) (time.Time, error) {
+	if r.Transport == nil || r.Transport.Activity == nil {
+		return time.Time{}, nil
+	}
+
+	return r.Transport.Activity.LastActivity(), nil
+}
@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestActivityTracker(t *testing.T) {
+	tracker := NewActivityTracker()
+	before := tracker.LastActivity()
+
+	time.Sleep(time.Millisecond)
+	tracker.RecordActivity()
+
+	if !tracker.LastActivity().After(before) {
+		t.Fatalf("Expected the last activity to move forward")
+	}
+}
+
+func TestReceptorServiceGetLastActivity(t *testing.T) {
+	receptor := &ReceptorService{Transport: &Transport{}}
+
+	lastActivity, err := receptor.GetLastActivity(context.TODO())
+	if err != nil || !lastActivity.IsZero() {
+		t.Fatalf("Expected a zero time without an activity tracker, got %v (%v)", lastActivity, err)
+	}
+
+	receptor.Transport.Activity = NewActivityTracker()
+
+	lastActivity, err = receptor.GetLastActivity(context.TODO())
+	if err != nil || lastActivity != receptor.Transport.Activity.LastActivity() {
+		t.Fatalf("Expected the last activity of the transport, got %v (%v)", lastActivity, err)
+	}
+}
@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"hash/fnv"
+)
+
+// AffinityHint returns the pod that the node should prefer to connect to.  The
+// pod is picked by rendezvous hashing the node id against the pod set: every
+// gateway pod configured with the same pod set gives the same hint, and adding
+// or removing a pod only moves the nodes that preferred that pod.  An empty
+// hint is returned if no pods are configured.
+func AffinityHint(nodeID string, pods []string) string {
+	var hint string
+	var highestWeight uint64
+
+	for _, pod := range pods {
+		if pod == "" {
+			continue
+		}
+
+		h := fnv.New64a()
+		h.Write([]byte(nodeID))
+		h.Write([]byte{0})
+		h.Write([]byte(pod))
+
+		// Break the ties by name so that the order of the pods does not matter
+		weight := h.Sum64()
+		if hint == "" || weight > highestWeight || (weight == highestWeight && pod < hint) {
+			hint = pod
+			highestWeight = weight
+		}
+	}
+
+	return hint
+}
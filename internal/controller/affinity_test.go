@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAffinityHintIsDeterministic(t *testing.T) {
+	pods := []string{"gateway-0", "gateway-1", "gateway-2"}
+
+	for i := 0; i < 20; i++ {
+		nodeID := fmt.Sprintf("node-%d", i)
+
+		hint := AffinityHint(nodeID, pods)
+		if hint == "" {
+			t.Fatalf("Expected node %s to be given a hint", nodeID)
+		}
+
+		if again := AffinityHint(nodeID, pods); again != hint {
+			t.Fatalf("Expected node %s to be given the same hint, got %s and %s", nodeID, hint, again)
+		}
+
+		reordered := []string{pods[2], pods[0], pods[1]}
+		if other := AffinityHint(nodeID, reordered); other != hint {
+			t.Fatalf("Expected the hint of node %s not to depend on the order of the pods, got %s and %s", nodeID, hint, other)
+		}
+	}
+}
+
+func TestAffinityHintSpreadsTheNodes(t *testing.T) {
+	pods := []string{"gateway-0", "gateway-1", "gateway-2"}
+
+	hints := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		hints[AffinityHint(fmt.Sprintf("node-%d", i), pods)]++
+	}
+
+	for _, pod := range pods {
+		if hints[pod] < 50 {
+			t.Fatalf("Expected the nodes to be spread across the pods, got %v", hints)
+		}
+	}
+}
+
+func TestAffinityHintOnlyMovesTheNodesOfARemovedPod(t *testing.T) {
+	pods := []string{"gateway-0", "gateway-1", "gateway-2"}
+	remaining := []string{"gateway-0", "gateway-2"}
+
+	for i := 0; i < 100; i++ {
+		nodeID := fmt.Sprintf("node-%d", i)
+
+		before := AffinityHint(nodeID, pods)
+		after := AffinityHint(nodeID, remaining)
+		if before != "gateway-1" && before != after {
+			t.Fatalf("Expected node %s to keep its hint %s, got %s", nodeID, before, after)
+		}
+	}
+}
+
+func TestAffinityHintWithoutPods(t *testing.T) {
+	if hint := AffinityHint("node-a", nil); hint != "" {
+		t.Fatalf("Expected no hint, got %s", hint)
+	}
+}
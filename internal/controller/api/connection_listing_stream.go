@@ -0,0 +1,81 @@
+package api
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/controller"
+	"github.com/sirupsen/logrus"
+)
+
+const NDJSON_CONTENT_TYPE = "application/x-ndjson"
+
+// ndjsonFlushInterval is the number of connections written to the stream
+// between two flushes
+const ndjsonFlushInterval = 100
+
+type streamedConnection struct {
+	AccountNumber string `json:"account"`
+	NodeID        string `json:"node_id"`
+	Pod           string `json:"pod,omitempty"`
+	Quarantined   bool   `json:"quarantined,omitempty"`
+}
+
+// acceptsNDJSON reports whether the client asked for the connection listing
+// to be streamed as newline delimited json
+func acceptsNDJSON(req *http.Request) bool {
+	for _, mediaRange := range strings.Split(req.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err == nil && mediaType == NDJSON_CONTENT_TYPE {
+			return true
+		}
+	}
+	return false
+}
+
+// writeConnectionListingStream writes each connection of the snapshot that
+// matches the filter as a json object on its own line.  The connections are
+// written as they are iterated, so the listing is never held in memory.
+func writeConnectionListingStream(w http.ResponseWriter, req *http.Request, logger *logrus.Entry, connections map[string]map[string]controller.Receptor, filter connectionFilter) {
+	w.Header().Set("Content-Type", NDJSON_CONTENT_TYPE)
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+
+	written := 0
+	for account, accountConnections := range connections {
+		for nodeID, client := range accountConnections {
+			if req.Context().Err() != nil {
+				logger.Info("Client went away while streaming the connection listing")
+				return
+			}
+
+			if !matchesConnectionFilter(req.Context(), client, filter) {
+				continue
+			}
+
+			quarantinable, ok := client.(controller.Quarantinable)
+			quarantined := ok && quarantinable.IsQuarantined(req.Context())
+
+			line, err := marshalJSON(w, streamedConnection{AccountNumber: account, NodeID: nodeID,
+				Pod: connectionPod(client), Quarantined: quarantined})
+			if err != nil {
+				logger.WithFields(logrus.Fields{"error": err}).Error("Unable to encode the connection")
+				return
+			}
+
+			if _, err := w.Write(append(line, '\n')); err != nil {
+				logger.WithFields(logrus.Fields{"error": err}).Info("Unable to write the connection listing")
+				return
+			}
+
+			written++
+			if flusher != nil && written%ndjsonFlushInterval == 0 {
+				flusher.Flush()
+			}
+		}
+	}
+
+	logger.Debugf("Streamed %d connections", written)
+}
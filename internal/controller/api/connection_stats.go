@@ -0,0 +1,245 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/controller"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/middlewares"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+	"github.com/redhatinsights/platform-go-middlewares/request_id"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultStatsTopAccounts = 10
+	maxStatsTopAccounts     = 100
+)
+
+type accountConnectionCount struct {
+	Account     string `json:"account"`
+	Connections int    `json:"connections"`
+}
+
+type connectionStatsResponse struct {
+	TotalConnections     int                      `json:"total_connections"`
+	Accounts             int                      `json:"accounts"`
+	TopAccounts          []accountConnectionCount `json:"top_accounts"`
+	AverageUptimeSeconds *float64                 `json:"average_uptime_seconds,omitempty"`
+	Reconnects           *int                     `json:"reconnects,omitempty"`
+	ReconnectsPerMinute  *float64                 `json:"reconnects_per_minute,omitempty"`
+	ComputedAt           time.Time                `json:"computed_at"`
+}
+
+type accountThroughputResponse struct {
+	Account           string  `json:"account"`
+	WindowSeconds     float64 `json:"window_seconds"`
+	Messages          int64   `json:"messages"`
+	Bytes             int64   `json:"bytes"`
+	Errors            int64   `json:"errors"`
+	MessagesPerSecond float64 `json:"messages_per_second"`
+	BytesPerSecond    float64 `json:"bytes_per_second"`
+	ErrorRate         float64 `json:"error_rate"`
+}
+
+// connectionStats is the summary of the connections.  The accounts are sorted
+// by decreasing number of connections.
+type connectionStats struct {
+	totalConnections     int
+	accounts             []accountConnectionCount
+	averageUptimeSeconds *float64
+	reconnects           *int
+	reconnectsPerMinute  *float64
+	computedAt           time.Time
+}
+
+// connectionStatsCache keeps the last computed summary for a short time so
+// that dashboards polling the stats do not walk the connections each time
+type connectionStatsCache struct {
+	stats *connectionStats
+	sync.Mutex
+}
+
+// SetConnectionStatsTracker sets the tracker the reconnections are reported
+// from.  Without a tracker the reconnections are not reported.
+func (s *ManagementServer) SetConnectionStatsTracker(tracker *controller.ConnectionStatsTracker) {
+	s.connectionStats = tracker
+}
+
+// SetAccountThroughputTracker sets the tracker the throughput of the accounts
+// is reported from.  Without a tracker the throughput is not available.
+func (s *ManagementServer) SetAccountThroughputTracker(tracker *controller.AccountThroughputTracker) {
+	s.accountThroughput = tracker
+}
+
+func (s *ManagementServer) handleConnectionStats() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		principal, _ := middlewares.GetPrincipal(req.Context())
+		requestId := request_id.GetReqID(req.Context())
+		logger := logger.Log.WithFields(logrus.Fields{
+			"account":    principal.GetAccount(),
+			"request_id": requestId})
+
+		top, err := getQueryParamInt(req, "top", defaultStatsTopAccounts)
+		if err != nil || top < 0 || top > maxStatsTopAccounts {
+			errorResponse := errorResponse{Title: "Invalid top",
+				Status: http.StatusBadRequest,
+				Detail: fmt.Sprintf("top must be between 0 and %d", maxStatsTopAccounts)}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		stats := s.getConnectionStats(req.Context(), time.Now())
+
+		logger.Debugf("Returning the connection stats computed at %s", stats.computedAt)
+
+		if top > len(stats.accounts) {
+			top = len(stats.accounts)
+		}
+
+		response := connectionStatsResponse{
+			TotalConnections:     stats.totalConnections,
+			Accounts:             len(stats.accounts),
+			TopAccounts:          stats.accounts[:top],
+			AverageUptimeSeconds: stats.averageUptimeSeconds,
+			Reconnects:           stats.reconnects,
+			ReconnectsPerMinute:  stats.reconnectsPerMinute,
+			ComputedAt:           stats.computedAt,
+		}
+
+		if maxAge := s.config.ConnectionStatsCacheTTL - time.Since(stats.computedAt); maxAge > 0 {
+			w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int(math.Ceil(maxAge.Seconds()))))
+		}
+
+		writeJSONResponse(w, http.StatusOK, response)
+	}
+}
+
+// getConnectionStats returns the cached summary, computing it if it is older
+// than the cache ttl.  Concurrent requests wait for a single computation.
+func (s *ManagementServer) getConnectionStats(ctx context.Context, now time.Time) *connectionStats {
+	s.statsCache.Lock()
+	defer s.statsCache.Unlock()
+
+	if s.statsCache.stats != nil && now.Sub(s.statsCache.stats.computedAt) < s.config.ConnectionStatsCacheTTL {
+		return s.statsCache.stats
+	}
+
+	s.statsCache.stats = computeConnectionStats(ctx, s.connectionMgr.GetAllConnections(), s.connectionStats, now)
+	return s.statsCache.stats
+}
+
+func computeConnectionStats(ctx context.Context, connections map[string]map[string]controller.Receptor, tracker *controller.ConnectionStatsTracker, now time.Time) *connectionStats {
+	stats := &connectionStats{
+		accounts:   make([]accountConnectionCount, 0, len(connections)),
+		computedAt: now,
+	}
+
+	var totalUptime time.Duration
+	connectionsWithUptime := 0
+
+	for account, accountConnections := range connections {
+		if len(accountConnections) == 0 {
+			continue
+		}
+
+		stats.accounts = append(stats.accounts, accountConnectionCount{Account: account, Connections: len(accountConnections)})
+		stats.totalConnections += len(accountConnections)
+
+		for _, client := range accountConnections {
+			detailer, ok := client.(controller.ConnectionDetailer)
+			if !ok {
+				continue
+			}
+
+			connectedAt, err := detailer.GetConnectedAt(ctx)
+			if err != nil || connectedAt.IsZero() {
+				continue
+			}
+
+			totalUptime += now.Sub(connectedAt)
+			connectionsWithUptime++
+		}
+	}
+
+	sort.Slice(stats.accounts, func(i, j int) bool {
+		if stats.accounts[i].Connections != stats.accounts[j].Connections {
+			return stats.accounts[i].Connections > stats.accounts[j].Connections
+		}
+		return stats.accounts[i].Account < stats.accounts[j].Account
+	})
+
+	if connectionsWithUptime > 0 {
+		averageUptimeSeconds := totalUptime.Seconds() / float64(connectionsWithUptime)
+		stats.averageUptimeSeconds = &averageUptimeSeconds
+	}
+
+	if tracker != nil {
+		reconnects, window := tracker.Reconnects()
+		stats.reconnects = &reconnects
+
+		if window > 0 {
+			reconnectsPerMinute := float64(reconnects) / window.Minutes()
+			stats.reconnectsPerMinute = &reconnectsPerMinute
+		}
+	}
+
+	return stats
+}
+
+func (s *ManagementServer) handleAccountThroughputStats() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		principal, _ := middlewares.GetPrincipal(req.Context())
+		requestId := request_id.GetReqID(req.Context())
+		accountId := mux.Vars(req)["id"]
+		logger := logger.Log.WithFields(logrus.Fields{
+			"account":    principal.GetAccount(),
+			"request_id": requestId})
+
+		if s.accountThroughput == nil {
+			errorResponse := errorResponse{Title: "Throughput stats are not available",
+				Status: http.StatusServiceUnavailable,
+				Detail: "The throughput of the accounts is not tracked by this process"}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		logger.Debugf("Returning the throughput of account %s", accountId)
+
+		writeJSONResponse(w, http.StatusOK, newAccountThroughputResponse(accountId, s.accountThroughput))
+	}
+}
+
+func newAccountThroughputResponse(account string, tracker *controller.AccountThroughputTracker) accountThroughputResponse {
+	throughput, window := tracker.Throughput(account)
+
+	response := accountThroughputResponse{
+		Account:       account,
+		WindowSeconds: window.Seconds(),
+		Messages:      throughput.Messages,
+		Bytes:         throughput.Bytes,
+		Errors:        throughput.Errors,
+	}
+
+	if window > 0 {
+		response.MessagesPerSecond = float64(throughput.Messages) / window.Seconds()
+		response.BytesPerSecond = float64(throughput.Bytes) / window.Seconds()
+	}
+
+	if attempts := throughput.Messages + throughput.Errors; attempts > 0 {
+		response.ErrorRate = float64(throughput.Errors) / float64(attempts)
+	}
+
+	return response
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/controller"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/middlewares"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+	"github.com/redhatinsights/platform-go-middlewares/request_id"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// connectionTimeoutsRequest replaces the timeout overrides of a connection.
+// The timeouts are durations (e.g. 30s); the timeouts that are left out fall
+// back to the global default.
+type connectionTimeoutsRequest struct {
+	PingTimeout         string `json:"ping_timeout,omitempty"`
+	CapabilitiesTimeout string `json:"capabilities_timeout,omitempty"`
+	SendTimeout         string `json:"send_timeout,omitempty"`
+}
+
+// connectionTimeoutsResponse holds the effective timeouts of a connection
+type connectionTimeoutsResponse struct {
+	PingTimeout         string `json:"ping_timeout"`
+	CapabilitiesTimeout string `json:"capabilities_timeout"`
+	SendTimeout         string `json:"send_timeout"`
+}
+
+func (r connectionTimeoutsRequest) timeouts() (controller.ConnectionTimeouts, error) {
+	var timeouts controller.ConnectionTimeouts
+
+	for name, field := range map[string]struct {
+		value   string
+		timeout *time.Duration
+	}{
+		"ping_timeout":         {r.PingTimeout, &timeouts.Ping},
+		"capabilities_timeout": {r.CapabilitiesTimeout, &timeouts.Capabilities},
+		"send_timeout":         {r.SendTimeout, &timeouts.Send},
+	} {
+		if field.value == "" {
+			continue
+		}
+
+		timeout, err := time.ParseDuration(field.value)
+		if err != nil {
+			return timeouts, fmt.Errorf("%s must be a duration (e.g. 30s)", name)
+		}
+
+		*field.timeout = timeout
+	}
+
+	return timeouts, nil
+}
+
+func newConnectionTimeoutsResponse(timeouts controller.ConnectionTimeouts) connectionTimeoutsResponse {
+	return connectionTimeoutsResponse{
+		PingTimeout:         timeouts.Ping.String(),
+		CapabilitiesTimeout: timeouts.Capabilities.String(),
+		SendTimeout:         timeouts.Send.String(),
+	}
+}
+
+// handleConnectionTimeouts overrides the timeouts of the requests sent to a
+// node, e.g. for a node that is slow but healthy
+func (s *ManagementServer) handleConnectionTimeouts() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		principal, _ := middlewares.GetPrincipal(req.Context())
+		requestId := request_id.GetReqID(req.Context())
+		logger := logger.Log.WithFields(logrus.Fields{
+			"account":    principal.GetAccount(),
+			"request_id": requestId})
+
+		params := mux.Vars(req)
+		account := params["account"]
+		nodeID := params["node_id"]
+
+		body := http.MaxBytesReader(w, req.Body, 1048576)
+
+		var timeoutsReq connectionTimeoutsRequest
+
+		if err := decodeJSON(req.Context(), body, &timeoutsReq); err != nil {
+			errorResponse := errorResponse{Title: "Unable to process json input",
+				Status: http.StatusBadRequest,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		timeouts, err := timeoutsReq.timeouts()
+		if err != nil {
+			errorResponse := errorResponse{Title: "Invalid timeout",
+				Status: http.StatusBadRequest,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		if requestCancelled(w, req, logger) {
+			return
+		}
+
+		client := s.connectionMgr.GetConnection(account, nodeID)
+		if client == nil {
+			errMsg := fmt.Sprintf("No connection found for node (%s:%s)", account, nodeID)
+			logger.Info(errMsg)
+			errorResponse := errorResponse{Title: errMsg,
+				Status: http.StatusNotFound,
+				Detail: errMsg}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		configurable, ok := client.(controller.TimeoutConfigurable)
+		if !ok {
+			errorResponse := errorResponse{Title: "Overriding the timeouts is unsupported for this backend",
+				Status: http.StatusNotImplemented,
+				Detail: controller.UnsupportedBackendError{}.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		logger.WithFields(logrus.Fields{"audit": true, "timeouts": timeoutsReq}).Infof("Overriding the timeouts of the connection for account:%s - node id:%s", account, nodeID)
+
+		if err := configurable.SetTimeouts(req.Context(), timeouts); err != nil {
+			status := http.StatusInternalServerError
+			if _, ok := err.(controller.InvalidConnectionTimeoutError); ok {
+				status = http.StatusBadRequest
+			}
+
+			logger.WithFields(logrus.Fields{"error": err}).Info("Unable to override the timeouts")
+			errorResponse := errorResponse{Title: "Unable to override the timeouts",
+				Status: status,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		effective, err := configurable.GetTimeouts(req.Context())
+		if err != nil {
+			errorResponse := errorResponse{Title: "Unable to retrieve the timeouts",
+				Status: http.StatusInternalServerError,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		writeJSONResponse(w, http.StatusOK, newConnectionTimeoutsResponse(effective))
+	}
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/controller"
+)
+
+// connectionListingETag returns a weak ETag for the unfiltered connection
+// listings.  It is derived from the generation of the connection locator and
+// from the number of changes to the quarantine state of the connections, the
+// only other state of a connection shown by an unfiltered listing.  False is
+// returned if the connection locator does not report its generation.
+func (s *ManagementServer) connectionListingETag() (string, bool) {
+	reporter, ok := s.connectionMgr.(controller.ConnectionGenerationReporter)
+	if !ok {
+		return "", false
+	}
+
+	return fmt.Sprintf(`W/"%d-%d"`, reporter.GetConnectionGeneration(), atomic.LoadUint64(&s.quarantineGeneration)), true
+}
+
+// writeNotModified sets the ETag header of the response and, if the request
+// carries a matching If-None-Match header, writes a 304 response in place of
+// the listing and returns true
+func writeNotModified(w http.ResponseWriter, req *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+
+	if !etagMatches(req.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches compares the entity tags of an If-None-Match header with the
+// ETag using the weak comparison
+func etagMatches(ifNoneMatch string, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,215 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+	"github.com/redhatinsights/platform-go-middlewares/request_id"
+
+	"github.com/go-redis/redis"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	HEALTH_STATUS_HEALTHY   = "healthy"
+	HEALTH_STATUS_UNHEALTHY = "unhealthy"
+
+	HEALTH_CHECK_KAFKA       = "kafka"
+	HEALTH_CHECK_LOCATOR     = "locator"
+	HEALTH_CHECK_GOROUTINES  = "goroutines"
+	HEALTH_CHECK_CONNECTIONS = "connections"
+)
+
+var errHealthCheckTimedOut = errors.New("health check timed out")
+
+// HealthCheckFunc checks a subsystem.  It returns a value describing the
+// subsystem (e.g. the number of goroutines), which can be nil, and an error if
+// the subsystem is unhealthy.  The check should give up once the context is
+// done; a check that does not is reported as timed out anyway.
+type HealthCheckFunc func(ctx context.Context) (interface{}, error)
+
+type healthCheck struct {
+	name  string
+	check HealthCheckFunc
+}
+
+type healthCheckResult struct {
+	Status     string      `json:"status"`
+	Value      interface{} `json:"value,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	DurationMs int64       `json:"duration_ms"`
+}
+
+type detailedHealthResponse struct {
+	Status     string                       `json:"status"`
+	Subsystems map[string]healthCheckResult `json:"subsystems"`
+}
+
+// AddHealthCheck registers a check reported by /healthz/detailed.  A check
+// registered with the name of an existing check replaces it.
+func (s *ManagementServer) AddHealthCheck(name string, check HealthCheckFunc) {
+	for i := range s.healthChecks {
+		if s.healthChecks[i].name == name {
+			s.healthChecks[i].check = check
+			return
+		}
+	}
+
+	s.healthChecks = append(s.healthChecks, healthCheck{name: name, check: check})
+}
+
+// NewKafkaHealthCheck checks that at least one of the brokers accepts
+// connections.  The connection is not handed to kafka-go: its dialer asks the
+// broker for the supported api versions without a deadline, which can block
+// the check long after it has timed out.
+func NewKafkaHealthCheck(brokers []string) HealthCheckFunc {
+	return func(ctx context.Context) (interface{}, error) {
+		if len(brokers) == 0 {
+			return nil, errors.New("no kafka brokers configured")
+		}
+
+		var dialer net.Dialer
+		var err error
+		for _, broker := range brokers {
+			var conn net.Conn
+			conn, err = dialer.DialContext(ctx, "tcp", broker)
+			if err == nil {
+				conn.Close()
+				return nil, nil
+			}
+		}
+
+		return nil, err
+	}
+}
+
+// NewRedisHealthCheck checks that the Redis server backing the connection
+// locator answers a ping.
+func NewRedisHealthCheck(client *redis.Client) HealthCheckFunc {
+	return func(ctx context.Context) (interface{}, error) {
+		return nil, client.WithContext(ctx).Ping().Err()
+	}
+}
+
+// goroutinesHealthCheck reports the number of goroutines.  Too many goroutines
+// usually means that something is leaking or stuck.
+func (s *ManagementServer) goroutinesHealthCheck(ctx context.Context) (interface{}, error) {
+	goroutines := runtime.NumGoroutine()
+	if s.config.HealthMaxGoroutines > 0 && goroutines > s.config.HealthMaxGoroutines {
+		return goroutines, fmt.Errorf("%d goroutines exceeds the maximum of %d", goroutines, s.config.HealthMaxGoroutines)
+	}
+	return goroutines, nil
+}
+
+// connectionsHealthCheck reports the number of connections.  Zero for
+// RECEPTOR_CONTROLLER_HEALTH_MAX_CONNECTIONS means there is no maximum.
+func (s *ManagementServer) connectionsHealthCheck(ctx context.Context) (interface{}, error) {
+	connections := 0
+	for _, conn := range s.connectionMgr.GetAllConnections() {
+		connections += len(conn)
+	}
+
+	if s.config.HealthMaxConnections > 0 && connections > s.config.HealthMaxConnections {
+		return connections, fmt.Errorf("%d connections exceeds the maximum of %d", connections, s.config.HealthMaxConnections)
+	}
+	return connections, nil
+}
+
+// runHealthCheck runs the check with the health check timeout.  The check runs
+// in its own goroutine so that a check ignoring the context cannot hang the
+// endpoint.
+func (s *ManagementServer) runHealthCheck(ctx context.Context, check HealthCheckFunc) healthCheckResult {
+	if s.config.HealthCheckTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.HealthCheckTimeout)
+		defer cancel()
+	}
+
+	type checkOutcome struct {
+		value interface{}
+		err   error
+	}
+
+	done := make(chan checkOutcome, 1)
+	start := time.Now()
+
+	go func() {
+		value, err := check(ctx)
+		done <- checkOutcome{value: value, err: err}
+	}()
+
+	var outcome checkOutcome
+	select {
+	case outcome = <-done:
+	case <-ctx.Done():
+		outcome.err = errHealthCheckTimedOut
+	}
+
+	result := healthCheckResult{
+		Status:     HEALTH_STATUS_HEALTHY,
+		Value:      outcome.value,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+
+	if outcome.err != nil {
+		result.Status = HEALTH_STATUS_UNHEALTHY
+		result.Error = outcome.err.Error()
+	}
+
+	return result
+}
+
+func (s *ManagementServer) handleDetailedHealth() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		requestId := request_id.GetReqID(req.Context())
+		logger := logger.Log.WithFields(logrus.Fields{"request_id": requestId})
+
+		response := detailedHealthResponse{
+			Status:     HEALTH_STATUS_HEALTHY,
+			Subsystems: make(map[string]healthCheckResult, len(s.healthChecks)),
+		}
+
+		var lock sync.Mutex
+		var wg sync.WaitGroup
+
+		for _, hc := range s.healthChecks {
+			wg.Add(1)
+			go func(hc healthCheck) {
+				defer wg.Done()
+
+				result := s.runHealthCheck(req.Context(), hc.check)
+
+				lock.Lock()
+				defer lock.Unlock()
+
+				response.Subsystems[hc.name] = result
+				if result.Status != HEALTH_STATUS_HEALTHY {
+					response.Status = HEALTH_STATUS_UNHEALTHY
+				}
+			}(hc)
+		}
+
+		wg.Wait()
+
+		status := http.StatusOK
+		if response.Status != HEALTH_STATUS_HEALTHY {
+			for name, result := range response.Subsystems {
+				if result.Status != HEALTH_STATUS_HEALTHY {
+					logger.WithFields(logrus.Fields{"subsystem": name, "error": result.Error}).Warn("Subsystem is unhealthy")
+				}
+			}
+			status = http.StatusServiceUnavailable
+		}
+
+		writeJSONResponse(w, status, response)
+	}
+}
@@ -6,9 +6,20 @@ import (
 	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
 
 	"github.com/go-redis/redis"
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 )
 
+// JobLocator keeps track of the gateway pod that each job was sent through.
+// The services that proxy the jobs to the gateway pods use it to reach the
+// outbox of the pod that owns a job.
+type JobLocator interface {
+	RecordJobLocation(jobID uuid.UUID, pod string)
+
+	// GetJobLocation returns an empty pod if the job is not known
+	GetJobLocation(jobID uuid.UUID) (string, error)
+}
+
 type RedisConnectionLocator struct {
 	Client *redis.Client
 	Cfg    *config.Config
@@ -36,6 +47,7 @@ func (rcl *RedisConnectionLocator) GetConnection(account string, node_id string)
 		AccountNumber: account,
 		NodeID:        node_id,
 		Config:        rcl.Cfg,
+		JobLocator:    rcl,
 	}
 
 	return conn
@@ -83,3 +95,69 @@ func (rcl *RedisConnectionLocator) GetAllConnections() map[string]map[string]con
 
 	return connectionMap
 }
+
+func (rcl *RedisConnectionLocator) ImportConnections(connections []controller.ImportedConnection) (int, error) {
+	imported := 0
+
+	for _, conn := range connections {
+		log := logger.Log.WithFields(logrus.Fields{"account": conn.Account, "node_id": conn.NodeID, "pod": conn.Pod})
+
+		ok, err := controller.ImportWithRedis(rcl.Client, conn.Account, conn.NodeID, conn.Pod)
+		if err != nil {
+			log.WithFields(logrus.Fields{"error": err}).Error("Error during connection import")
+			return imported, err
+		}
+
+		if ok {
+			imported++
+		}
+	}
+
+	return imported, nil
+}
+
+// GetConnectionHistory returns the pods that owned the connection, oldest first
+func (rcl *RedisConnectionLocator) GetConnectionHistory(account string, nodeID string) ([]controller.ConnectionOwnershipChange, error) {
+	return controller.GetRedisConnectionHistory(rcl.Client, account, nodeID)
+}
+
+func (rcl *RedisConnectionLocator) GetConnectionsByAccountPrefix(prefix string, offset int, limit int) (map[string]map[string]controller.Receptor, int) {
+
+	log := logger.Log.WithFields(logrus.Fields{"account_prefix": prefix})
+
+	connectionMap := make(map[string]map[string]controller.Receptor)
+
+	connections, err := controller.GetRedisConnectionsByAccountPrefix(rcl.Client, prefix)
+	if err != nil {
+		log.WithFields(logrus.Fields{"error": err}).Error("Error during connection lookup for account prefix ", prefix)
+		return nil, 0
+	}
+
+	matchingAccounts := make([]string, 0, len(connections))
+	for account := range connections {
+		matchingAccounts = append(matchingAccounts, account)
+	}
+
+	for _, account := range controller.PaginateAccounts(matchingAccounts, offset, limit) {
+		connectionMap[account] = make(map[string]controller.Receptor)
+		for node := range connections[account] {
+			proxy := rcl.GetConnection(account, node)
+			connectionMap[account][node] = proxy
+		}
+	}
+
+	return connectionMap, len(matchingAccounts)
+}
+
+// RecordJobLocation records the pod for as long as the pod keeps the job in
+// its outbox.  A failure to record the pod is logged, it does not fail the
+// send.
+func (rcl *RedisConnectionLocator) RecordJobLocation(jobID uuid.UUID, pod string) {
+	if err := controller.RecordJobLocationWithRedis(rcl.Client, jobID.String(), pod, rcl.Cfg.OutboxRetention); err != nil {
+		logger.Log.WithFields(logrus.Fields{"message_id": jobID, "pod": pod, "error": err}).Error("Unable to record the pod of the job in Redis")
+	}
+}
+
+func (rcl *RedisConnectionLocator) GetJobLocation(jobID uuid.UUID) (string, error) {
+	return controller.GetRedisJobLocation(rcl.Client, jobID.String())
+}
@@ -1,6 +1,13 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
@@ -9,6 +16,8 @@ import (
 	"github.com/alicebob/miniredis"
 	"github.com/go-playground/assert/v2"
 	"github.com/go-redis/redis"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 )
 
 func newTestRedisClient(addr string) *redis.Client {
@@ -45,6 +54,7 @@ func TestGetConnection(t *testing.T) {
 				AccountNumber: "01",
 				NodeID:        "node-a",
 				Config:        locator.Cfg,
+				JobLocator:    locator,
 			},
 		},
 		{
@@ -87,12 +97,14 @@ func TestGetConnectionsByAccount(t *testing.T) {
 					AccountNumber: "01",
 					NodeID:        "node-a",
 					Config:        locator.Cfg,
+					JobLocator:    locator,
 				},
 				"node-b": &ReceptorHttpProxy{
 					Hostname:      "localhost",
 					AccountNumber: "01",
 					NodeID:        "node-b",
 					Config:        locator.Cfg,
+					JobLocator:    locator,
 				},
 			},
 		},
@@ -104,6 +116,7 @@ func TestGetConnectionsByAccount(t *testing.T) {
 					AccountNumber: "02",
 					NodeID:        "node-c",
 					Config:        locator.Cfg,
+					JobLocator:    locator,
 				},
 			},
 		},
@@ -143,12 +156,14 @@ func TestGetAllConnections(t *testing.T) {
 				AccountNumber: "01",
 				NodeID:        "node-a",
 				Config:        locator.Cfg,
+				JobLocator:    locator,
 			},
 			"node-b": &ReceptorHttpProxy{
 				Hostname:      "localhost",
 				AccountNumber: "01",
 				NodeID:        "node-b",
 				Config:        locator.Cfg,
+				JobLocator:    locator,
 			},
 		},
 		"02": {
@@ -157,7 +172,160 @@ func TestGetAllConnections(t *testing.T) {
 				AccountNumber: "02",
 				NodeID:        "node-c",
 				Config:        locator.Cfg,
+				JobLocator:    locator,
 			},
 		},
 	}, res)
 }
+
+func TestImportConnections(t *testing.T) {
+	s, _ := miniredis.Run()
+	defer s.Close()
+
+	c := newTestRedisClient(s.Addr())
+
+	locator := &RedisConnectionLocator{
+		Client: c,
+		Cfg:    config.GetConfig(),
+	}
+
+	_ = controller.RegisterWithRedis(c, "01", "node-a", "localhost")
+
+	imported, err := locator.ImportConnections([]controller.ImportedConnection{
+		{Account: "01", NodeID: "node-a", Pod: "gateway-pod-9"},
+		{Account: "01", NodeID: "node-b", Pod: "gateway-pod-9"},
+	})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, imported, 1)
+	assert.Equal(t, locator.GetConnection("01", "node-a").(*ReceptorHttpProxy).Hostname, "localhost")
+	assert.Equal(t, locator.GetConnection("01", "node-b").(*ReceptorHttpProxy).Hostname, "gateway-pod-9")
+}
+
+func TestGetConnectionsByAccountPrefix(t *testing.T) {
+	s, _ := miniredis.Run()
+	defer s.Close()
+
+	c := newTestRedisClient(s.Addr())
+
+	locator := &RedisConnectionLocator{
+		Client: c,
+		Cfg:    config.GetConfig(),
+	}
+
+	_ = controller.RegisterWithRedis(c, "901", "node-a", "localhost")
+	_ = controller.RegisterWithRedis(c, "902", "node-b", "localhost")
+	_ = controller.RegisterWithRedis(c, "01", "node-c", "localhost")
+
+	res, count := locator.GetConnectionsByAccountPrefix("90", 1, 10)
+
+	assert.Equal(t, count, 2)
+	assert.Equal(t, map[string]map[string]controller.Receptor{
+		"902": {
+			"node-b": &ReceptorHttpProxy{
+				Hostname:      "localhost",
+				AccountNumber: "902",
+				NodeID:        "node-b",
+				Config:        locator.Cfg,
+				JobLocator:    locator,
+			},
+		},
+	}, res)
+}
+
+// TestJobReceiverReadsTheJobsFromTheGatewayPod sends a job through a job
+// receiver that does not share the outbox of the gateway pod, then reads and
+// cancels the job through the job receiver
+func TestJobReceiverReadsTheJobsFromTheGatewayPod(t *testing.T) {
+	s, _ := miniredis.Run()
+	defer s.Close()
+
+	client := newTestRedisClient(s.Addr())
+
+	cfg := config.GetConfig()
+	cfg.JobReceiverReceptorProxyScheme = "http"
+	cfg.JobReceiverReceptorProxyClientID = "test_client_1"
+	cfg.JobReceiverReceptorProxyPSK = "12345"
+	cfg.ServiceToServiceCredentials["test_client_1"] = "12345"
+	cfg.ReceptorWarmupGracePeriod = 0
+
+	// The gateway pod, which owns the connection and the outbox
+	outbox := controller.NewInMemoryOutboxStore()
+	connectionMgr := controller.NewLocalConnectionManager()
+	connectionMgr.Register(forwardTestAccount, forwardTestNodeID,
+		newForwardTestReceptor(cfg, outbox, nil, make(chan controller.ReceptorMessage, 10)))
+
+	gateway := NewJobReceiver(connectionMgr, outbox, mux.NewRouter(), cfg)
+	gateway.Routes()
+
+	server := httptest.NewServer(gateway.router)
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	cfg.JobReceiverReceptorProxyPort, _ = strconv.Atoi(serverURL.Port())
+	controller.RegisterWithRedis(client, forwardTestAccount, forwardTestNodeID, serverURL.Hostname())
+
+	// The job receiver
+	locator := &RedisConnectionLocator{Client: client, Cfg: cfg}
+	jr := NewJobReceiver(locator, nil, mux.NewRouter(), cfg)
+	jr.SetJobLocator(locator)
+	jr.Routes()
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		addPreSharedKeyHeaders(req.Header, cfg, forwardTestAccount)
+
+		rr := httptest.NewRecorder()
+		jr.router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve(http.MethodPost, "/job", `{"account": "01", "recipient": "node-a", "payload": "payload", "directive": "fred:flintstone"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected the job to be sent, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var job jobResponse
+	json.Unmarshal(rr.Body.Bytes(), &job)
+
+	rr = serve(http.MethodGet, "/job/"+job.JobID, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected the status of the job, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var jobStatus jobStatusResponse
+	json.Unmarshal(rr.Body.Bytes(), &jobStatus)
+	if jobStatus.JobID != job.JobID || jobStatus.Account != forwardTestAccount ||
+		jobStatus.Recipient != forwardTestNodeID || jobStatus.Status != controller.OUTBOX_PENDING_STATUS {
+		t.Fatalf("Unexpected job status %+v", jobStatus)
+	}
+
+	rr = serve(http.MethodPost, "/job/"+job.JobID+"/cancel", "")
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("Expected the job to be cancelled, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var cancelResponse jobCancelResponse
+	json.Unmarshal(rr.Body.Bytes(), &cancelResponse)
+	if cancelResponse.JobID != job.JobID || cancelResponse.Status != controller.OUTBOX_CANCELLING_STATUS {
+		t.Fatalf("Unexpected cancel response %+v", cancelResponse)
+	}
+
+	// A job that has ended can not be cancelled
+	outbox.UpdateStatus(context.TODO(), uuid.MustParse(job.JobID), controller.OUTBOX_COMPLETED_STATUS)
+
+	if rr = serve(http.MethodPost, "/job/"+job.JobID+"/cancel", ""); rr.Code != http.StatusConflict {
+		t.Fatalf("Expected the cancel of a completed job to be rejected, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	unknownJobID := uuid.New().String()
+
+	if rr = serve(http.MethodGet, "/job/"+unknownJobID, ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("Expected an unknown job not to be found, got %d", rr.Code)
+	}
+
+	if rr = serve(http.MethodPost, "/job/"+unknownJobID+"/cancel", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("Expected an unknown job not to be found, got %d", rr.Code)
+	}
+}
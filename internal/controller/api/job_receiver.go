@@ -1,7 +1,9 @@
 package api
 
 import (
+	"context"
 	"net/http"
+	"time"
 
 	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
 	"github.com/RedHatInsights/platform-receptor-controller/internal/controller"
@@ -9,42 +11,160 @@ import (
 	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
 	"github.com/redhatinsights/platform-go-middlewares/request_id"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
 
 type JobReceiver struct {
 	connectionMgr controller.ConnectionLocator
+	outbox        controller.OutboxStore
 	router        *mux.Router
 	config        *config.Config
+	dispatcher    *controller.AccountDispatcher
+	maintenance   *MaintenanceMode
+
+	// jobLocator is set when the jobs are kept in the outbox of the gateway
+	// pods rather than in an outbox shared with this service
+	jobLocator JobLocator
 }
 
-func NewJobReceiver(cm controller.ConnectionLocator, r *mux.Router, cfg *config.Config) *JobReceiver {
+func NewJobReceiver(cm controller.ConnectionLocator, outbox controller.OutboxStore, r *mux.Router, cfg *config.Config) *JobReceiver {
 	return &JobReceiver{
 		connectionMgr: cm,
+		outbox:        outbox,
 		router:        r,
 		config:        cfg,
+		dispatcher:    controller.NewAccountDispatcher(cm, controller.NewWeightedRoundRobinSelector()),
+		maintenance:   NewMaintenanceMode(),
+	}
+}
+
+// SetNodeSelector changes how the node is picked for the jobs that can be
+// sent to any node of an account
+func (jr *JobReceiver) SetNodeSelector(selector controller.NodeSelector) {
+	jr.dispatcher = controller.NewAccountDispatcher(jr.connectionMgr, selector)
+}
+
+// SetJobLocator makes the job status and cancel requests go to the gateway
+// pod that the job was sent through.  It is used when the outbox is not
+// shared with the gateway pods.
+func (jr *JobReceiver) SetJobLocator(locator JobLocator) {
+	jr.jobLocator = locator
+}
+
+// proxyToJobPod returns the proxy to the gateway pod that the job was sent
+// through
+func (jr *JobReceiver) proxyToJobPod(account string, jobID uuid.UUID) (*ReceptorHttpProxy, error) {
+	pod, err := jr.jobLocator.GetJobLocation(jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	if pod == "" {
+		return nil, controller.OutboxEntryNotFoundError{}
+	}
+
+	return &ReceptorHttpProxy{Hostname: pod, AccountNumber: account, Config: jr.config}, nil
+}
+
+// getJobStatus reads the job from the outbox, or from the outbox of the
+// gateway pod that the job was sent through
+func (jr *JobReceiver) getJobStatus(ctx context.Context, account string, jobID uuid.UUID) (*jobStatusResponse, error) {
+	if jr.jobLocator != nil {
+		proxy, err := jr.proxyToJobPod(account, jobID)
+		if err != nil {
+			return nil, err
+		}
+		return proxy.GetJobStatus(ctx, jobID)
+	}
+
+	entry, err := jr.outbox.Get(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.AccountNumber != account {
+		return nil, controller.OutboxEntryNotFoundError{}
+	}
+
+	return &jobStatusResponse{
+		JobID:        entry.Message.MessageID.String(),
+		Account:      entry.AccountNumber,
+		Recipient:    entry.Message.Recipient,
+		Directive:    entry.Message.Directive,
+		Status:       entry.Status,
+		Attempts:     entry.Attempts,
+		PayloadBytes: controller.PayloadSize(entry.Message.Payload),
+		CreatedAt:    entry.CreatedAt,
+		UpdatedAt:    entry.UpdatedAt,
+	}, nil
+}
+
+// cancelJobOnPod cancels the job through the gateway pod that the job was
+// sent through
+func (jr *JobReceiver) cancelJobOnPod(ctx context.Context, account string, jobID uuid.UUID) (string, error) {
+	proxy, err := jr.proxyToJobPod(account, jobID)
+	if err != nil {
+		return "", err
 	}
+	return proxy.CancelMessage(ctx, account, jobID)
 }
 
 func (jr *JobReceiver) Routes() {
 	securedSubRouter := jr.router.PathPrefix("/").Subrouter()
 	amw := &middlewares.AuthMiddleware{Secrets: jr.config.ServiceToServiceCredentials}
-	securedSubRouter.Use(logger.AccessLoggerMiddleware, amw.Authenticate)
-	securedSubRouter.HandleFunc("/job", jr.handleJob()).Methods(http.MethodPost)
+	pmw := &prettyJSONMiddleware{always: jr.config.PrettyJSONResponses}
+	nmw := newJSONNamingMiddleware(jr.config.JSONNaming)
+	securedSubRouter.Use(newAccessLogger(jr.config), amw.Authenticate, nmw.RenameFields, pmw.IndentResponses)
+	securedSubRouter.Handle("/job", jr.maintenance.rejectDuringMaintenance(middlewares.RequireJSONContentType(jr.handleJob()))).Methods(http.MethodPost)
+	securedSubRouter.Handle("/job/any", jr.maintenance.rejectDuringMaintenance(middlewares.RequireJSONContentType(jr.handleAccountJob()))).Methods(http.MethodPost)
+	securedSubRouter.HandleFunc("/job/{id}", jr.handleJobStatus()).Methods(http.MethodGet)
+	securedSubRouter.HandleFunc("/job/{id}/cancel", jr.handleJobCancel()).Methods(http.MethodPost)
+	securedSubRouter.Handle("/message/forward", middlewares.RequireJSONContentType(jr.handleForwardedMessage())).Methods(http.MethodPost)
 }
 
 type jobRequest struct {
-	Account   string      `json:"account" validate:"required"`
+	Account   string      `json:"account" validate:"required,account"`
 	Recipient string      `json:"recipient" validate:"required"`
 	Payload   interface{} `json:"payload" validate:"required"`
 	Directive string      `json:"directive" validate:"required"`
+	TTL       int         `json:"ttl,omitempty" validate:"gte=0"`
 }
 
 type jobResponse struct {
 	JobID string `json:"id"`
 }
 
+type accountJobRequest struct {
+	Account   string      `json:"account" validate:"required,account"`
+	Payload   interface{} `json:"payload" validate:"required"`
+	Directive string      `json:"directive" validate:"required"`
+	TTL       int         `json:"ttl,omitempty" validate:"gte=0"`
+}
+
+type accountJobResponse struct {
+	JobID     string `json:"id"`
+	Recipient string `json:"recipient"`
+}
+
+type jobStatusResponse struct {
+	JobID        string    `json:"id"`
+	Account      string    `json:"account"`
+	Recipient    string    `json:"recipient"`
+	Directive    string    `json:"directive"`
+	Status       string    `json:"status"`
+	Attempts     int       `json:"attempts"`
+	PayloadBytes int       `json:"payload_bytes"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type jobCancelResponse struct {
+	JobID  string `json:"id"`
+	Status string `json:"status"`
+}
+
 func (jr *JobReceiver) handleJob() http.HandlerFunc {
 
 	return func(w http.ResponseWriter, req *http.Request) {
@@ -59,7 +179,7 @@ func (jr *JobReceiver) handleJob() http.HandlerFunc {
 
 		body := http.MaxBytesReader(w, req.Body, 1048576)
 
-		if err := decodeJSON(body, &jobRequest); err != nil {
+		if err := decodeJSON(req.Context(), body, &jobRequest); err != nil {
 			errMsg := "Unable to process json input"
 			logger.WithFields(logrus.Fields{"error": err}).Debug(errMsg)
 			errorResponse := errorResponse{Title: errMsg,
@@ -69,6 +189,19 @@ func (jr *JobReceiver) handleJob() http.HandlerFunc {
 			return
 		}
 
+		if requestCancelled(w, req, logger) {
+			return
+		}
+
+		if err := controller.VerifyDirective(jr.config.AllowedDirectives, jobRequest.Directive); err != nil {
+			logger.WithFields(logrus.Fields{"audit": true, "recipient": jobRequest.Recipient, "directive": jobRequest.Directive}).Warn("Rejected a job with a directive that is not allowed")
+			errorResponse := errorResponse{Title: "Directive not allowed",
+				Status: http.StatusBadRequest,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
 		var client controller.Receptor
 		client = jr.connectionMgr.GetConnection(jobRequest.Account, jobRequest.Recipient)
 		if client == nil {
@@ -86,7 +219,12 @@ func (jr *JobReceiver) handleJob() http.HandlerFunc {
 			"directive": jobRequest.Directive})
 		logger.Info("Sending a message")
 
-		jobID, err := client.SendMessage(req.Context(), jobRequest.Account, jobRequest.Recipient,
+		ctx := withRequestTraceContext(req, logger)
+		if jobRequest.TTL > 0 {
+			ctx = controller.WithMessageExpiry(ctx, time.Now().Add(time.Duration(jobRequest.TTL)*time.Second))
+		}
+
+		jobID, err := client.SendMessage(ctx, jobRequest.Account, jobRequest.Recipient,
 			[]string{jobRequest.Recipient},
 			jobRequest.Payload,
 			jobRequest.Directive)
@@ -107,3 +245,235 @@ func (jr *JobReceiver) handleJob() http.HandlerFunc {
 		writeJSONResponse(w, http.StatusCreated, jobResponse)
 	}
 }
+
+// handleAccountJob sends the job to one of the nodes of the account.  The node
+// is picked by the node selector.
+func (jr *JobReceiver) handleAccountJob() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		principal, _ := middlewares.GetPrincipal(req.Context())
+		requestId := request_id.GetReqID(req.Context())
+		logger := logger.Log.WithFields(logrus.Fields{
+			"account":    principal.GetAccount(),
+			"request_id": requestId})
+
+		var jobRequest accountJobRequest
+
+		body := http.MaxBytesReader(w, req.Body, 1048576)
+
+		if err := decodeJSON(req.Context(), body, &jobRequest); err != nil {
+			errMsg := "Unable to process json input"
+			logger.WithFields(logrus.Fields{"error": err}).Debug(errMsg)
+			errorResponse := errorResponse{Title: errMsg,
+				Status: http.StatusBadRequest,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		if requestCancelled(w, req, logger) {
+			return
+		}
+
+		if err := controller.VerifyDirective(jr.config.AllowedDirectives, jobRequest.Directive); err != nil {
+			logger.WithFields(logrus.Fields{"audit": true, "directive": jobRequest.Directive}).Warn("Rejected a job with a directive that is not allowed")
+			errorResponse := errorResponse{Title: "Directive not allowed",
+				Status: http.StatusBadRequest,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		logger = logger.WithFields(logrus.Fields{"directive": jobRequest.Directive})
+
+		ctx := withRequestTraceContext(req, logger)
+		if jobRequest.TTL > 0 {
+			ctx = controller.WithMessageExpiry(ctx, time.Now().Add(time.Duration(jobRequest.TTL)*time.Second))
+		}
+
+		recipient, jobID, err := jr.dispatcher.SendToAccount(ctx, jobRequest.Account, jobRequest.Payload, jobRequest.Directive)
+		if _, ok := err.(controller.NoConnectionsError); ok {
+			errMsg := "No connection to the receptor node"
+			logger.Info(errMsg)
+			errorResponse := errorResponse{Title: errMsg,
+				Status: http.StatusNotFound,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		} else if err != nil {
+			logger.WithFields(logrus.Fields{"error": err, "recipient": recipient}).Info("Error passing message to receptor")
+			errorResponse := errorResponse{Title: "Error passing message to receptor",
+				Status: http.StatusInternalServerError,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		logger.WithFields(logrus.Fields{"message_id": jobID, "recipient": recipient}).Info("Message sent")
+
+		writeJSONResponse(w, http.StatusCreated, accountJobResponse{JobID: jobID.String(), Recipient: recipient})
+	}
+}
+
+func (jr *JobReceiver) handleJobStatus() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		principal, _ := middlewares.GetPrincipal(req.Context())
+		requestId := request_id.GetReqID(req.Context())
+		logger := logger.Log.WithFields(logrus.Fields{
+			"account":    principal.GetAccount(),
+			"request_id": requestId})
+
+		jobID, err := uuid.Parse(mux.Vars(req)["id"])
+		if err != nil {
+			errorResponse := errorResponse{Title: "Invalid job id",
+				Status: http.StatusBadRequest,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		logger = logger.WithFields(logrus.Fields{"message_id": jobID})
+		logger.Debug("Getting job status")
+
+		if requestCancelled(w, req, logger) {
+			return
+		}
+
+		jobStatus, err := jr.getJobStatus(withRequestTraceContext(req, logger), principal.GetAccount(), jobID)
+		if _, ok := err.(controller.OutboxEntryNotFoundError); ok {
+			writeJobNotFoundResponse(w)
+			return
+		} else if err != nil {
+			logger.WithFields(logrus.Fields{"error": err}).Error("Unable to read the job from the outbox")
+			errorResponse := errorResponse{Title: "Unable to read the job status",
+				Status: http.StatusInternalServerError,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		writeJSONResponse(w, http.StatusOK, jobStatus)
+	}
+}
+
+func (jr *JobReceiver) handleJobCancel() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		principal, _ := middlewares.GetPrincipal(req.Context())
+		requestId := request_id.GetReqID(req.Context())
+		logger := logger.Log.WithFields(logrus.Fields{
+			"account":    principal.GetAccount(),
+			"request_id": requestId})
+
+		jobID, err := uuid.Parse(mux.Vars(req)["id"])
+		if err != nil {
+			errorResponse := errorResponse{Title: "Invalid job id",
+				Status: http.StatusBadRequest,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		logger = logger.WithFields(logrus.Fields{"message_id": jobID})
+		logger.Info("Cancelling job")
+
+		if requestCancelled(w, req, logger) {
+			return
+		}
+
+		if jr.jobLocator != nil {
+			status, err := jr.cancelJobOnPod(withRequestTraceContext(req, logger), principal.GetAccount(), jobID)
+			writeJobCancelResult(w, logger, jobID, status, err)
+			return
+		}
+
+		entry, err := jr.outbox.Get(req.Context(), jobID)
+		if _, ok := err.(controller.OutboxEntryNotFoundError); ok || (err == nil && entry.AccountNumber != principal.GetAccount()) {
+			writeJobNotFoundResponse(w)
+			return
+		} else if err != nil {
+			logger.WithFields(logrus.Fields{"error": err}).Error("Unable to read the job from the outbox")
+			errorResponse := errorResponse{Title: "Unable to read the job status",
+				Status: http.StatusInternalServerError,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		if controller.IsFinalOutboxStatus(entry.Status) {
+			writeJobCompletedResponse(w, entry.Status)
+			return
+		}
+
+		client := jr.connectionMgr.GetConnection(entry.AccountNumber, entry.NodeID)
+		if client == nil {
+			errMsg := "No connection to the receptor node"
+			logger.Info(errMsg)
+			errorResponse := errorResponse{Title: errMsg,
+				Status: http.StatusNotFound,
+				Detail: errMsg}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		canceller, ok := client.(controller.MessageCanceller)
+		if !ok {
+			errorResponse := errorResponse{Title: "Cancelling jobs is not supported",
+				Status: http.StatusNotImplemented,
+				Detail: controller.UnsupportedBackendError{}.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		status, err := canceller.CancelMessage(withRequestTraceContext(req, logger), entry.AccountNumber, jobID)
+		writeJobCancelResult(w, logger, jobID, status, err)
+	}
+}
+
+// writeJobCancelResult writes the response to a cancel request from the
+// outcome of CancelMessage
+func writeJobCancelResult(w http.ResponseWriter, logger *logrus.Entry, jobID uuid.UUID, status string, err error) {
+	if _, ok := err.(controller.OutboxEntryNotFoundError); ok {
+		writeJobNotFoundResponse(w)
+		return
+	} else if _, ok := err.(controller.JobCompletedError); ok {
+		writeJobCompletedResponse(w, "")
+		return
+	} else if err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Info("Unable to cancel the job")
+		errorResponse := errorResponse{Title: "Unable to cancel the job",
+			Status: http.StatusInternalServerError,
+			Detail: err.Error()}
+		writeJSONResponse(w, errorResponse.Status, errorResponse)
+		return
+	}
+
+	logger.WithFields(logrus.Fields{"status": status}).Info("Job cancel requested")
+
+	writeJSONResponse(w, http.StatusAccepted, jobCancelResponse{JobID: jobID.String(), Status: status})
+}
+
+func writeJobNotFoundResponse(w http.ResponseWriter) {
+	errMsg := "Job not found"
+	errorResponse := errorResponse{Title: errMsg,
+		Status: http.StatusNotFound,
+		Detail: errMsg}
+	writeJSONResponse(w, errorResponse.Status, errorResponse)
+}
+
+// writeJobCompletedResponse rejects the cancel of a job that has already
+// ended.  The status is omitted from the detail when it is not known.
+func writeJobCompletedResponse(w http.ResponseWriter, status string) {
+	detail := controller.JobCompletedError{}.Error()
+	if status != "" {
+		detail += " (status: " + status + ")"
+	}
+	errorResponse := errorResponse{Title: "Job already completed",
+		Status: http.StatusConflict,
+		Detail: detail}
+	writeJSONResponse(w, errorResponse.Status, errorResponse)
+}
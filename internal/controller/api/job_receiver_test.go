@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -28,7 +29,9 @@ const (
 )
 
 type MockClient struct {
-	returnAnError bool
+	returnAnError       bool
+	capabilitiesReason  string
+	partialCapabilities bool
 }
 
 func (mc MockClient) SendMessage(ctx context.Context, account string, recipient string, route []string, payload interface{}, directive string) (*uuid.UUID, error) {
@@ -39,6 +42,29 @@ func (mc MockClient) SendMessage(ctx context.Context, account string, recipient
 	return &myUUID, nil
 }
 
+// MockTracingClient records the trace context that the messages are sent with
+type MockTracingClient struct {
+	MockClient
+	traceContexts chan controller.TraceContext
+}
+
+func (mc MockTracingClient) SendMessage(ctx context.Context, account string, recipient string, route []string, payload interface{}, directive string) (*uuid.UUID, error) {
+	tc, _ := controller.GetTraceContext(ctx)
+	mc.traceContexts <- tc
+	return mc.MockClient.SendMessage(ctx, account, recipient, route, payload, directive)
+}
+
+// MockCancellingClient records the jobs that are cancelled
+type MockCancellingClient struct {
+	MockClient
+	cancelled chan uuid.UUID
+}
+
+func (mc MockCancellingClient) CancelMessage(ctx context.Context, account string, messageID uuid.UUID) (string, error) {
+	mc.cancelled <- messageID
+	return controller.OUTBOX_CANCELLING_STATUS, nil
+}
+
 func (mc MockClient) Ping(context.Context, string, string, []string) (interface{}, error) {
 	if mc.returnAnError {
 		return nil, errors.New("ImaErrorToo")
@@ -51,9 +77,23 @@ func (mc MockClient) Close(context.Context) error {
 }
 
 func (mc MockClient) GetCapabilities(context.Context) (interface{}, error) {
+	if mc.capabilitiesReason != "" {
+		return nil, controller.MalformedCapabilitiesError{Reason: mc.capabilitiesReason}
+	}
+	if mc.partialCapabilities {
+		return map[string]interface{}{"max_work_threads": 4}, controller.PartialCapabilitiesError{}
+	}
 	return struct{}{}, nil
 }
 
+func (mc MockClient) GetRoutingTable(context.Context) (*controller.RoutingTable, error) {
+	return &controller.RoutingTable{}, nil
+}
+
+func (mc MockClient) GetHealth(context.Context) (string, error) {
+	return controller.HEALTHY_STATUS, nil
+}
+
 func init() {
 	logger.InitLogger()
 }
@@ -62,6 +102,9 @@ var _ = Describe("JobReceiver", func() {
 
 	var (
 		jr                  *JobReceiver
+		outbox              *controller.InMemoryOutboxStore
+		traceContexts       chan controller.TraceContext
+		cancelled           chan uuid.UUID
 		validIdentityHeader string
 	)
 
@@ -72,11 +115,16 @@ var _ = Describe("JobReceiver", func() {
 		cm.Register("1234", "345", mc)
 		errorMC := MockClient{returnAnError: true}
 		cm.Register("1234", "error-client", errorMC)
+		traceContexts = make(chan controller.TraceContext, 1)
+		cm.Register("1234", "traced", MockTracingClient{traceContexts: traceContexts})
+		cancelled = make(chan uuid.UUID, 1)
+		cm.Register("1234", "cancellable", MockCancellingClient{cancelled: cancelled})
 		cfg := config.GetConfig()
-		jr = NewJobReceiver(cm, apiMux, cfg)
+		outbox = controller.NewInMemoryOutboxStore()
+		jr = NewJobReceiver(cm, outbox, apiMux, cfg)
 		jr.Routes()
 
-		identity := `{ "identity": {"account_number": "540155", "type": "User", "internal": { "org_id": "1979710" } } }`
+		identity := `{ "identity": {"account_number": "1234", "type": "User", "internal": { "org_id": "1979710" } } }`
 		validIdentityHeader = base64.StdEncoding.EncodeToString([]byte(identity))
 	})
 
@@ -89,6 +137,7 @@ var _ = Describe("JobReceiver", func() {
 				req, err := http.NewRequest("POST", "/job", strings.NewReader(postBody))
 				Expect(err).NotTo(HaveOccurred())
 
+				req.Header.Add("Content-Type", "application/json")
 				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
 
 				rr := httptest.NewRecorder()
@@ -102,6 +151,104 @@ var _ = Describe("JobReceiver", func() {
 				Expect(m).Should(HaveKey("id"))
 			})
 
+			It("Should be able to send a job with a ttl to a connected customer", func() {
+
+				postBody := "{\"account\": \"1234\", \"recipient\": \"345\", \"payload\": [\"678\"], \"directive\": \"fred:flintstone\", \"ttl\": 60}"
+
+				req, err := http.NewRequest("POST", "/job", strings.NewReader(postBody))
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add("Content-Type", "application/json")
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				jr.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusCreated))
+			})
+
+			It("Should send the job with the trace context passed in the request headers", func() {
+
+				postBody := "{\"account\": \"1234\", \"recipient\": \"traced\", \"payload\": [\"678\"], \"directive\": \"fred:flintstone\"}"
+
+				req, err := http.NewRequest("POST", "/job", strings.NewReader(postBody))
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add("Content-Type", "application/json")
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+				req.Header.Add("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+				req.Header.Add("tracestate", "vendor=value")
+
+				rr := httptest.NewRecorder()
+
+				jr.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusCreated))
+				Expect(<-traceContexts).To(Equal(controller.TraceContext{
+					TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+					TraceState:  "vendor=value"}))
+			})
+
+			It("Should ignore a malformed trace context", func() {
+
+				postBody := "{\"account\": \"1234\", \"recipient\": \"traced\", \"payload\": [\"678\"], \"directive\": \"fred:flintstone\"}"
+
+				req, err := http.NewRequest("POST", "/job", strings.NewReader(postBody))
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add("Content-Type", "application/json")
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+				req.Header.Add("traceparent", "not-a-traceparent")
+
+				rr := httptest.NewRecorder()
+
+				jr.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusCreated))
+				Expect(<-traceContexts).To(Equal(controller.TraceContext{}))
+			})
+
+			It("Should not allow sending a job with a directive that is not allowed", func() {
+
+				jr.config.AllowedDirectives = []string{"fred:flintstone"}
+
+				postBody := "{\"account\": \"1234\", \"recipient\": \"345\", \"payload\": [\"678\"], \"directive\": \"barney:rubble\"}"
+
+				req, err := http.NewRequest("POST", "/job", strings.NewReader(postBody))
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add("Content-Type", "application/json")
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				jr.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusBadRequest))
+
+				var errResponse errorResponse
+				json.Unmarshal(rr.Body.Bytes(), &errResponse)
+				Expect(errResponse.Detail).Should(Equal(controller.DirectiveNotAllowedError{Directive: "barney:rubble"}.Error()))
+			})
+
+			It("Should not allow sending a job with a negative ttl", func() {
+
+				postBody := "{\"account\": \"1234\", \"recipient\": \"345\", \"payload\": [\"678\"], \"directive\": \"fred:flintstone\", \"ttl\": -1}"
+
+				req, err := http.NewRequest("POST", "/job", strings.NewReader(postBody))
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add("Content-Type", "application/json")
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				jr.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			})
+
 			It("Should be able to send a job to a connected customer but get an error", func() {
 
 				postBody := "{\"account\": \"1234\", \"recipient\": \"error-client\", \"payload\": [\"678\"], \"directive\": \"fred:flintstone\"}"
@@ -109,6 +256,7 @@ var _ = Describe("JobReceiver", func() {
 				req, err := http.NewRequest("POST", "/job", strings.NewReader(postBody))
 				Expect(err).NotTo(HaveOccurred())
 
+				req.Header.Add("Content-Type", "application/json")
 				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
 
 				rr := httptest.NewRecorder()
@@ -126,11 +274,12 @@ var _ = Describe("JobReceiver", func() {
 
 			It("Should not allow sending a job to a disconnected customer", func() {
 
-				postBody := "{\"account\": \"1234-not-here\", \"recipient\": \"345\", \"payload\": [\"678\"], \"directive\": \"fred:flintstone\"}"
+				postBody := "{\"account\": \"4321\", \"recipient\": \"345\", \"payload\": [\"678\"], \"directive\": \"fred:flintstone\"}"
 
 				req, err := http.NewRequest("POST", "/job", strings.NewReader(postBody))
 				Expect(err).NotTo(HaveOccurred())
 
+				req.Header.Add("Content-Type", "application/json")
 				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
 
 				rr := httptest.NewRecorder()
@@ -147,6 +296,24 @@ var _ = Describe("JobReceiver", func() {
 				req, err := http.NewRequest("POST", "/job", strings.NewReader(postBody))
 				Expect(err).NotTo(HaveOccurred())
 
+				req.Header.Add("Content-Type", "application/json")
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				jr.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			})
+
+			It("Should not allow sending a job with a non-numeric account", func() {
+
+				postBody := "{\"account\": \"1234-abc\", \"recipient\": \"345\", \"payload\": [\"678\"], \"directive\": \"fred:flintstone\"}"
+
+				req, err := http.NewRequest("POST", "/job", strings.NewReader(postBody))
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add("Content-Type", "application/json")
 				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
 
 				rr := httptest.NewRecorder()
@@ -163,6 +330,7 @@ var _ = Describe("JobReceiver", func() {
 				req, err := http.NewRequest("POST", "/job", strings.NewReader(postBody))
 				Expect(err).NotTo(HaveOccurred())
 
+				req.Header.Add("Content-Type", "application/json")
 				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
 
 				rr := httptest.NewRecorder()
@@ -179,6 +347,7 @@ var _ = Describe("JobReceiver", func() {
 				req, err := http.NewRequest("POST", "/job", strings.NewReader(postBody))
 				Expect(err).NotTo(HaveOccurred())
 
+				req.Header.Add("Content-Type", "application/json")
 				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
 
 				rr := httptest.NewRecorder()
@@ -195,6 +364,7 @@ var _ = Describe("JobReceiver", func() {
 				req, err := http.NewRequest("POST", "/job", strings.NewReader(postBody))
 				Expect(err).NotTo(HaveOccurred())
 
+				req.Header.Add("Content-Type", "application/json")
 				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
 
 				rr := httptest.NewRecorder()
@@ -204,6 +374,22 @@ var _ = Describe("JobReceiver", func() {
 				Expect(rr.Code).To(Equal(http.StatusBadRequest))
 			})
 
+			It("Should not allow sending a job without a content type", func() {
+
+				postBody := "{\"account\": \"1234\", \"recipient\": \"345\", \"payload\": [\"678\"], \"directive\": \"fred:flintstone\"}"
+
+				req, err := http.NewRequest("POST", "/job", strings.NewReader(postBody))
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				jr.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusUnsupportedMediaType))
+			})
+
 			It("Should allow sending a job with unknown fields", func() {
 
 				postBody := "{\"account\": \"1234\", \"recipient\": \"345\", \"payload\": [\"678\"], \"directive\": \"fred:flintstone\", \"extra\": \"field\"}"
@@ -211,6 +397,7 @@ var _ = Describe("JobReceiver", func() {
 				req, err := http.NewRequest("POST", "/job", strings.NewReader(postBody))
 				Expect(err).NotTo(HaveOccurred())
 
+				req.Header.Add("Content-Type", "application/json")
 				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
 
 				rr := httptest.NewRecorder()
@@ -230,6 +417,8 @@ var _ = Describe("JobReceiver", func() {
 				req, err := http.NewRequest("POST", "/job", strings.NewReader(postBody))
 				Expect(err).NotTo(HaveOccurred())
 
+				req.Header.Add("Content-Type", "application/json")
+
 				rr := httptest.NewRecorder()
 
 				jr.router.ServeHTTP(rr, req)
@@ -248,6 +437,7 @@ var _ = Describe("JobReceiver", func() {
 				req, err := http.NewRequest("POST", "/job", strings.NewReader(postBody))
 				Expect(err).NotTo(HaveOccurred())
 
+				req.Header.Add("Content-Type", "application/json")
 				req.Header.Add(TOKEN_HEADER_CLIENT_NAME, "test_client_1")
 				req.Header.Add(TOKEN_HEADER_ACCOUNT_NAME, "0000001")
 				req.Header.Add(TOKEN_HEADER_PSK_NAME, "12345")
@@ -273,6 +463,7 @@ var _ = Describe("JobReceiver", func() {
 				req, err := http.NewRequest("POST", "/job", strings.NewReader(postBody))
 				Expect(err).NotTo(HaveOccurred())
 
+				req.Header.Add("Content-Type", "application/json")
 				req.Header.Add(TOKEN_HEADER_CLIENT_NAME, "test_client_1")
 				req.Header.Add(TOKEN_HEADER_ACCOUNT_NAME, "0000001")
 				req.Header.Add(TOKEN_HEADER_PSK_NAME, "6789")
@@ -294,6 +485,7 @@ var _ = Describe("JobReceiver", func() {
 				req, err := http.NewRequest("POST", "/job", strings.NewReader(postBody))
 				Expect(err).NotTo(HaveOccurred())
 
+				req.Header.Add("Content-Type", "application/json")
 				req.Header.Add(TOKEN_HEADER_CLIENT_NAME, "test_client_nil")
 				req.Header.Add(TOKEN_HEADER_ACCOUNT_NAME, "0000001")
 				req.Header.Add(TOKEN_HEADER_PSK_NAME, "12345")
@@ -307,4 +499,411 @@ var _ = Describe("JobReceiver", func() {
 		})
 
 	})
+
+	Describe("Sending a job to any node of an account", func() {
+		Context("With a valid identity header", func() {
+
+			var accountJR *JobReceiver
+
+			BeforeEach(func() {
+				cm := controller.NewLocalConnectionManager()
+				cm.Register("5678", "node-a", MockClient{})
+				cm.Register("5678", "node-b", MockClient{})
+				accountJR = NewJobReceiver(cm, outbox, mux.NewRouter(), config.GetConfig())
+				accountJR.Routes()
+			})
+
+			sendAccountJob := func(postBody string) *httptest.ResponseRecorder {
+				req, err := http.NewRequest("POST", "/job/any", strings.NewReader(postBody))
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add("Content-Type", "application/json")
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				accountJR.router.ServeHTTP(rr, req)
+
+				return rr
+			}
+
+			It("Should spread the jobs across the nodes of the account", func() {
+
+				recipients := make(map[string]int)
+				for i := 0; i < 4; i++ {
+					rr := sendAccountJob("{\"account\": \"5678\", \"payload\": [\"678\"], \"directive\": \"fred:flintstone\"}")
+
+					Expect(rr.Code).To(Equal(http.StatusCreated))
+
+					var m map[string]interface{}
+					json.Unmarshal(rr.Body.Bytes(), &m)
+					Expect(m).Should(HaveKey("id"))
+					recipients[m["recipient"].(string)]++
+				}
+
+				Expect(recipients).To(Equal(map[string]int{"node-a": 2, "node-b": 2}))
+			})
+
+			It("Should not allow sending a job to an account without connections", func() {
+
+				rr := sendAccountJob("{\"account\": \"1234\", \"payload\": [\"678\"], \"directive\": \"fred:flintstone\"}")
+
+				Expect(rr.Code).To(Equal(http.StatusNotFound))
+			})
+
+			It("Should not allow sending a job with missing required fields", func() {
+
+				rr := sendAccountJob("{\"account\": \"5678\", \"payload\": [\"678\"]}")
+
+				Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			})
+
+		})
+
+	})
+
+	Describe("Getting the status of a job", func() {
+		Context("With a valid identity header", func() {
+			It("Should be able to get the status of a job from the outbox", func() {
+
+				jobID, _ := uuid.NewRandom()
+				now := time.Now().UTC()
+				outbox.Add(context.TODO(), controller.OutboxEntry{
+					AccountNumber: "1234",
+					Message:       controller.Message{MessageID: jobID, Recipient: "345", Directive: "fred:flintstone"},
+					Status:        controller.OUTBOX_SENT_STATUS,
+					CreatedAt:     now,
+					UpdatedAt:     now,
+				})
+
+				req, err := http.NewRequest("GET", "/job/"+jobID.String(), nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				jr.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusOK))
+
+				var m map[string]interface{}
+				json.Unmarshal(rr.Body.Bytes(), &m)
+				Expect(m).Should(HaveKeyWithValue("id", jobID.String()))
+				Expect(m).Should(HaveKeyWithValue("account", "1234"))
+				Expect(m).Should(HaveKeyWithValue("recipient", "345"))
+				Expect(m).Should(HaveKeyWithValue("status", controller.OUTBOX_SENT_STATUS))
+			})
+
+			It("Should report a job that expired before it was sent", func() {
+
+				jobID, _ := uuid.NewRandom()
+				old := time.Now().UTC().Add(-time.Hour)
+				outbox.Add(context.TODO(), controller.OutboxEntry{
+					AccountNumber: "1234",
+					Message:       controller.Message{MessageID: jobID, Recipient: "345", Directive: "fred:flintstone", ExpiresAt: old},
+					Status:        controller.OUTBOX_PENDING_STATUS,
+					CreatedAt:     old,
+					UpdatedAt:     old,
+				})
+
+				controller.NewOutboxSweeper(outbox, controller.NewLocalConnectionManager(), config.GetConfig()).Sweep(context.TODO())
+
+				req, err := http.NewRequest("GET", "/job/"+jobID.String(), nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				jr.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusOK))
+
+				var m map[string]interface{}
+				json.Unmarshal(rr.Body.Bytes(), &m)
+				Expect(m).Should(HaveKeyWithValue("status", controller.OUTBOX_EXPIRED_STATUS))
+			})
+
+			It("Should report the size of a binary payload", func() {
+
+				jobID, _ := uuid.NewRandom()
+				now := time.Now().UTC()
+				outbox.Add(context.TODO(), controller.OutboxEntry{
+					AccountNumber: "1234",
+					Message:       controller.Message{MessageID: jobID, Recipient: "345", Directive: "fred:upload", Payload: controller.BinaryPayload(make([]byte, 1024))},
+					Status:        controller.OUTBOX_SENT_STATUS,
+					CreatedAt:     now,
+					UpdatedAt:     now,
+				})
+
+				req, err := http.NewRequest("GET", "/job/"+jobID.String(), nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				jr.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusOK))
+
+				var m map[string]interface{}
+				json.Unmarshal(rr.Body.Bytes(), &m)
+				Expect(m).Should(HaveKeyWithValue("payload_bytes", 1024.0))
+			})
+
+			It("Should return a 404 for a job of another account", func() {
+
+				jobID, _ := uuid.NewRandom()
+				now := time.Now().UTC()
+				outbox.Add(context.TODO(), controller.OutboxEntry{
+					AccountNumber: "5678",
+					Message:       controller.Message{MessageID: jobID, Recipient: "345", Directive: "fred:flintstone"},
+					Status:        controller.OUTBOX_SENT_STATUS,
+					CreatedAt:     now,
+					UpdatedAt:     now,
+				})
+
+				req, err := http.NewRequest("GET", "/job/"+jobID.String(), nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				jr.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusNotFound))
+			})
+
+			It("Should return a 404 for an unknown job", func() {
+
+				jobID, _ := uuid.NewRandom()
+
+				req, err := http.NewRequest("GET", "/job/"+jobID.String(), nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				jr.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusNotFound))
+			})
+
+			It("Should return a 400 for an invalid job id", func() {
+
+				req, err := http.NewRequest("GET", "/job/not-a-uuid", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				jr.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			})
+
+			It("Should indent the responses when pretty printing is enabled", func() {
+
+				cfg := config.GetConfig()
+				cfg.PrettyJSONResponses = true
+				prettyJR := NewJobReceiver(controller.NewLocalConnectionManager(), outbox, mux.NewRouter(), cfg)
+				prettyJR.Routes()
+
+				req, err := http.NewRequest("GET", "/job/not-a-uuid", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				prettyJR.router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusBadRequest))
+				Expect(rr.Body.String()).To(HavePrefix("{\n  \"title\": \"Invalid job id\",\n  \"status\": 400,\n"))
+			})
+
+		})
+
+	})
+
+	Describe("Cancelling a job", func() {
+		Context("With a valid identity header", func() {
+
+			addAccountJob := func(account string, nodeID string, status string) uuid.UUID {
+				jobID, _ := uuid.NewRandom()
+				now := time.Now().UTC()
+				outbox.Add(context.TODO(), controller.OutboxEntry{
+					AccountNumber: account,
+					NodeID:        nodeID,
+					Message:       controller.Message{MessageID: jobID, Recipient: nodeID, Directive: "fred:flintstone"},
+					Status:        status,
+					CreatedAt:     now,
+					UpdatedAt:     now,
+				})
+				return jobID
+			}
+
+			addJob := func(nodeID string, status string) uuid.UUID {
+				return addAccountJob("1234", nodeID, status)
+			}
+
+			cancelJob := func(jobID string) *httptest.ResponseRecorder {
+				req, err := http.NewRequest("POST", "/job/"+jobID+"/cancel", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				jr.router.ServeHTTP(rr, req)
+
+				return rr
+			}
+
+			It("Should be able to cancel a pending job", func() {
+
+				jobID := addJob("cancellable", controller.OUTBOX_PENDING_STATUS)
+
+				rr := cancelJob(jobID.String())
+
+				Expect(rr.Code).To(Equal(http.StatusAccepted))
+				Expect(<-cancelled).To(Equal(jobID))
+
+				var m map[string]interface{}
+				json.Unmarshal(rr.Body.Bytes(), &m)
+				Expect(m).Should(HaveKeyWithValue("id", jobID.String()))
+				Expect(m).Should(HaveKeyWithValue("status", controller.OUTBOX_CANCELLING_STATUS))
+			})
+
+			It("Should not allow cancelling a completed job", func() {
+
+				jobID := addJob("cancellable", controller.OUTBOX_COMPLETED_STATUS)
+
+				rr := cancelJob(jobID.String())
+
+				Expect(rr.Code).To(Equal(http.StatusConflict))
+				Expect(cancelled).ShouldNot(Receive())
+			})
+
+			It("Should return a 404 for an unknown job", func() {
+
+				jobID, _ := uuid.NewRandom()
+
+				rr := cancelJob(jobID.String())
+
+				Expect(rr.Code).To(Equal(http.StatusNotFound))
+			})
+
+			It("Should return a 404 for a job of another account", func() {
+
+				jobID := addAccountJob("5678", "cancellable", controller.OUTBOX_PENDING_STATUS)
+
+				rr := cancelJob(jobID.String())
+
+				Expect(rr.Code).To(Equal(http.StatusNotFound))
+				Expect(cancelled).ShouldNot(Receive())
+			})
+
+			It("Should return a 404 if the node is no longer connected", func() {
+
+				jobID := addJob("gone", controller.OUTBOX_SENT_STATUS)
+
+				rr := cancelJob(jobID.String())
+
+				Expect(rr.Code).To(Equal(http.StatusNotFound))
+			})
+
+			It("Should return a 501 if the connection does not support cancelling jobs", func() {
+
+				jobID := addJob("345", controller.OUTBOX_SENT_STATUS)
+
+				rr := cancelJob(jobID.String())
+
+				Expect(rr.Code).To(Equal(http.StatusNotImplemented))
+			})
+
+			It("Should return a 400 for an invalid job id", func() {
+
+				rr := cancelJob("not-a-uuid")
+
+				Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			})
+
+		})
+
+	})
+
+	Describe("Sending a job during maintenance", func() {
+		Context("With a valid identity header", func() {
+
+			var maintenance *MaintenanceMode
+
+			BeforeEach(func() {
+				cm := controller.NewLocalConnectionManager()
+				cm.Register("1234", "345", MockClient{})
+				maintenance = NewMaintenanceMode()
+				jr = NewJobReceiver(cm, outbox, mux.NewRouter(), config.GetConfig())
+				jr.SetMaintenanceMode(maintenance)
+				jr.Routes()
+			})
+
+			sendRequest := func(method string, url string, postBody string) *httptest.ResponseRecorder {
+				req, err := http.NewRequest(method, url, strings.NewReader(postBody))
+				Expect(err).NotTo(HaveOccurred())
+
+				req.Header.Add("Content-Type", "application/json")
+				req.Header.Add(IDENTITY_HEADER_NAME, validIdentityHeader)
+
+				rr := httptest.NewRecorder()
+
+				jr.router.ServeHTTP(rr, req)
+
+				return rr
+			}
+
+			It("Should reject the jobs while the maintenance is in progress", func() {
+
+				maintenance.SetEnabled(true, "admin_client")
+
+				rr := sendRequest("POST", "/job", "{\"account\": \"1234\", \"recipient\": \"345\", \"payload\": [\"678\"], \"directive\": \"fred:flintstone\"}")
+				Expect(rr.Code).To(Equal(http.StatusServiceUnavailable))
+
+				var m map[string]interface{}
+				json.Unmarshal(rr.Body.Bytes(), &m)
+				Expect(m).Should(HaveKeyWithValue("title", "Maintenance in progress"))
+
+				rr = sendRequest("POST", "/job/any", "{\"account\": \"1234\", \"payload\": [\"678\"], \"directive\": \"fred:flintstone\"}")
+				Expect(rr.Code).To(Equal(http.StatusServiceUnavailable))
+
+				maintenance.SetEnabled(false, "admin_client")
+
+				rr = sendRequest("POST", "/job", "{\"account\": \"1234\", \"recipient\": \"345\", \"payload\": [\"678\"], \"directive\": \"fred:flintstone\"}")
+				Expect(rr.Code).To(Equal(http.StatusCreated))
+			})
+
+			It("Should still return the status of the jobs while the maintenance is in progress", func() {
+
+				jobID, _ := uuid.NewRandom()
+				now := time.Now().UTC()
+				outbox.Add(context.TODO(), controller.OutboxEntry{
+					AccountNumber: "1234",
+					Message:       controller.Message{MessageID: jobID, Recipient: "345", Directive: "fred:flintstone"},
+					Status:        controller.OUTBOX_SENT_STATUS,
+					CreatedAt:     now,
+					UpdatedAt:     now,
+				})
+
+				maintenance.SetEnabled(true, "admin_client")
+
+				rr := sendRequest("GET", "/job/"+jobID.String(), "")
+				Expect(rr.Code).To(Equal(http.StatusOK))
+			})
+		})
+	})
+
 })
@@ -0,0 +1,373 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+const (
+	SNAKE_CASE_JSON_NAMING = "snake_case"
+	CAMEL_CASE_JSON_NAMING = "camelCase"
+)
+
+type jsonNamingKey int
+
+var camelCaseJSONKey jsonNamingKey
+
+// jsonNamingMiddleware renames the fields of the json requests and responses
+// from snake_case (e.g. node_id) to camelCase (e.g. nodeId) for the clients
+// that can not parse snake_case.  Only the fields of the request and response
+// structs are renamed.  The keys of maps (e.g. the node ids of a listing) and
+// the opaque values (e.g. the payloads and the metadata) are left as they are.
+type jsonNamingMiddleware struct {
+	camelCase bool
+}
+
+func newJSONNamingMiddleware(naming string) *jsonNamingMiddleware {
+	return &jsonNamingMiddleware{camelCase: naming == CAMEL_CASE_JSON_NAMING}
+}
+
+func (nmw *jsonNamingMiddleware) RenameFields(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if nmw.camelCase {
+			w = &camelCaseJSONResponseWriter{ResponseWriter: w}
+			r = r.WithContext(context.WithValue(r.Context(), camelCaseJSONKey, true))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// camelCaseJSONResponseWriter marks a response whose json fields should be
+// renamed to camelCase
+type camelCaseJSONResponseWriter struct {
+	http.ResponseWriter
+}
+
+// Flush lets streaming handlers (e.g. the connection events) flush through
+// the wrapper
+func (cw *camelCaseJSONResponseWriter) Flush() {
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// jsonResponseOptions reports how the json of a response should be written,
+// based on the wrappers added by the json middlewares
+func jsonResponseOptions(w http.ResponseWriter) (pretty bool, camelCase bool) {
+	for {
+		switch wrapper := w.(type) {
+		case *prettyJSONResponseWriter:
+			pretty = true
+			w = wrapper.ResponseWriter
+		case *camelCaseJSONResponseWriter:
+			camelCase = true
+			w = wrapper.ResponseWriter
+		default:
+			return pretty, camelCase
+		}
+	}
+}
+
+func isCamelCaseJSONRequest(ctx context.Context) bool {
+	camelCase, _ := ctx.Value(camelCaseJSONKey).(bool)
+	return camelCase
+}
+
+// marshalJSON encodes v using the naming convention of the response
+func marshalJSON(w http.ResponseWriter, v interface{}) ([]byte, error) {
+	if _, camelCase := jsonResponseOptions(w); camelCase {
+		renamed, err := toCamelCaseJSON(v)
+		if err != nil {
+			return nil, err
+		}
+		v = renamed
+	}
+
+	return json.Marshal(v)
+}
+
+// toCamelCaseJSON returns a json document equivalent to v, with the fields
+// of the structs renamed to camelCase
+func toCamelCaseJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	document, err := parseOrderedJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return renameJSONFields(reflect.TypeOf(v), document, true), nil
+}
+
+// fromCamelCaseJSON renames the camelCase fields of a json document to the
+// names used by the struct data is decoded into.  Fields that are already
+// using snake_case are left as they are.
+func fromCamelCaseJSON(body []byte, data interface{}) ([]byte, error) {
+	document, err := parseOrderedJSON(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(renameJSONFields(reflect.TypeOf(data), document, false))
+}
+
+// camelCaseName converts a snake_case name to camelCase
+func camelCaseName(name string) string {
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] == "" {
+			continue
+		}
+		runes := []rune(parts[i])
+		runes[0] = unicode.ToUpper(runes[0])
+		parts[i] = string(runes)
+	}
+	return strings.Join(parts, "")
+}
+
+var (
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// hasCustomJSON reports whether values of t are encoded by their own
+// MarshalJSON / UnmarshalJSON methods (e.g. time.Time)
+func hasCustomJSON(t reflect.Type) bool {
+	for _, iface := range []reflect.Type{jsonMarshalerType, jsonUnmarshalerType} {
+		if t.Implements(iface) || reflect.PtrTo(t).Implements(iface) {
+			return true
+		}
+	}
+	return false
+}
+
+// renameJSONFields walks the json document along with the go type that it was
+// encoded from (or is decoded into).  toCamelCase selects the direction.
+func renameJSONFields(t reflect.Type, v interface{}, toCamelCase bool) interface{} {
+	if t == nil {
+		return v
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if hasCustomJSON(t) {
+		return v
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := v.(jsonObject)
+		if !ok {
+			return v
+		}
+
+		fields := jsonFieldTypes(t)
+		renamed := make(jsonObject, len(object))
+		for i, member := range object {
+			name := member.key
+			if toCamelCase {
+				if _, known := fields[name]; known {
+					member.key = camelCaseName(name)
+				}
+			} else if snakeName, known := fields.snakeCaseName(name); known {
+				name = snakeName
+				member.key = snakeName
+			}
+			member.value = renameJSONFields(fields[name], member.value, toCamelCase)
+			renamed[i] = member
+		}
+		return renamed
+
+	case reflect.Map:
+		object, ok := v.(jsonObject)
+		if !ok {
+			return v
+		}
+
+		renamed := make(jsonObject, len(object))
+		for i, member := range object {
+			member.value = renameJSONFields(t.Elem(), member.value, toCamelCase)
+			renamed[i] = member
+		}
+		return renamed
+
+	case reflect.Slice, reflect.Array:
+		elements, ok := v.([]interface{})
+		if !ok {
+			return v
+		}
+
+		renamed := make([]interface{}, len(elements))
+		for i, element := range elements {
+			renamed[i] = renameJSONFields(t.Elem(), element, toCamelCase)
+		}
+		return renamed
+	}
+
+	// Opaque values (interface{}) and scalars are left as they are
+	return v
+}
+
+// jsonFields maps the json names of the fields of a struct to their types
+type jsonFields map[string]reflect.Type
+
+func (fields jsonFields) snakeCaseName(camelCase string) (string, bool) {
+	if _, known := fields[camelCase]; known {
+		return camelCase, true
+	}
+	for name := range fields {
+		if camelCaseName(name) == camelCase {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// jsonFieldTypes lists the fields of a struct the way encoding/json does.
+// The fields of embedded structs are promoted.
+func jsonFieldTypes(t reflect.Type) jsonFields {
+	fields := make(jsonFields)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for embeddedName, embeddedType := range jsonFieldTypes(embedded) {
+					if _, exists := fields[embeddedName]; !exists {
+						fields[embeddedName] = embeddedType
+					}
+				}
+				continue
+			}
+		}
+
+		if field.PkgPath != "" {
+			// unexported
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+
+	return fields
+}
+
+// jsonObject is a json object that keeps the order of its members
+type jsonObject []jsonMember
+
+type jsonMember struct {
+	key   string
+	value interface{}
+}
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, member := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, err := json.Marshal(member.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(member.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// parseOrderedJSON decodes a json document.  Objects are decoded into
+// jsonObjects so that the order of their members is kept, and numbers are
+// kept as they were written.
+func parseOrderedJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	document, err := parseJSONValue(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	if dec.More() {
+		return nil, errors.New("json document contains more than one value")
+	}
+
+	return document, nil
+}
+
+func parseJSONValue(dec *json.Decoder) (interface{}, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		object := jsonObject{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := parseJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			object = append(object, jsonMember{key: key.(string), value: value})
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return object, nil
+
+	case json.Delim('['):
+		array := []interface{}{}
+		for dec.More() {
+			value, err := parseJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return array, nil
+	}
+
+	return token, nil
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/controller"
+
+	"github.com/gorilla/mux"
+)
+
+func newTestConnectionDetail() connectionDetailResponse {
+	connectedAt := time.Date(2020, 1, 29, 20, 23, 49, 0, time.UTC)
+	return connectionDetailResponse{
+		Account: "1234",
+		NodeID:  "node_a",
+		connectionStatusResponse: connectionStatusResponse{
+			Status:            CONNECTED_STATUS,
+			Health:            controller.HEALTHY_STATUS,
+			Capabilities:      map[string]interface{}{"max_work_threads": "12"},
+			CapabilitiesError: "none",
+		},
+		ConnectedAt:   &connectedAt,
+		UptimeSeconds: 3600.5,
+		Metadata:      map[string]interface{}{"node_version": "1.0"},
+		Labels:        map[string]string{"cluster_id": "abc"},
+		Ping:          &connectionPingResponse{Status: CONNECTED_STATUS, Payload: "pong", LatencyMS: 12.5},
+	}
+}
+
+func TestCamelCaseName(t *testing.T) {
+	tests := map[string]string{
+		"id":                 "id",
+		"node_id":            "nodeId",
+		"capabilities_error": "capabilitiesError",
+		"latency_ms":         "latencyMs",
+	}
+
+	for name, expected := range tests {
+		if camelCase := camelCaseName(name); camelCase != expected {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected, camelCase)
+		}
+	}
+}
+
+func TestConnectionDetailRoundTripsInCamelCase(t *testing.T) {
+	detail := newTestConnectionDetail()
+
+	renamed, err := toCamelCaseJSON(detail)
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	encoded, _ := json.Marshal(renamed)
+
+	expected := `{"account":"1234","nodeId":"node_a","status":"connected","health":"healthy",` +
+		`"capabilities":{"max_work_threads":"12"},"capabilitiesError":"none",` +
+		`"connectedAt":"2020-01-29T20:23:49Z","uptimeSeconds":3600.5,"metadata":{"node_version":"1.0"},` +
+		`"labels":{"cluster_id":"abc"},"ping":{"status":"connected","payload":"pong","latencyMs":12.5}}`
+	if string(encoded) != expected {
+		t.Fatalf("Expected %s, got %s", expected, encoded)
+	}
+
+	var decoded connectionDetailResponse
+	body, err := fromCamelCaseJSON(encoded, &decoded)
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	if !reflect.DeepEqual(decoded, detail) {
+		t.Fatalf("Expected %+v, got %+v", detail, decoded)
+	}
+}
+
+func TestConnectionDetailRoundTripsInSnakeCase(t *testing.T) {
+	detail := newTestConnectionDetail()
+
+	encoded, _ := json.Marshal(detail)
+	if !strings.Contains(string(encoded), `"node_id":"node_a"`) {
+		t.Fatalf("Expected the node id to be encoded in snake_case, got %s", encoded)
+	}
+
+	// Decoding a snake_case document with the camelCase naming leaves it as it is
+	var decoded connectionDetailResponse
+	body, err := fromCamelCaseJSON(encoded, &decoded)
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	if !reflect.DeepEqual(decoded, detail) {
+		t.Fatalf("Expected %+v, got %+v", detail, decoded)
+	}
+}
+
+func TestConnectionListingKeepsTheNodeIDs(t *testing.T) {
+	listing := connectionDisconnectResponse{Connections: []connectionID{{Account: "1234", NodeID: "node_a"}}, DryRun: true}
+
+	renamed, _ := toCamelCaseJSON(listing)
+	encoded, _ := json.Marshal(renamed)
+
+	expected := `{"connections":[{"account":"1234","nodeId":"node_a"}],"dryRun":true}`
+	if string(encoded) != expected {
+		t.Fatalf("Expected %s, got %s", expected, encoded)
+	}
+}
+
+func TestManagementServerUsesCamelCaseJSON(t *testing.T) {
+	cm := controller.NewLocalConnectionManager()
+	cm.Register("1234", "345", MockClient{})
+
+	cfg := config.GetConfig()
+	cfg.JSONNaming = CAMEL_CASE_JSON_NAMING
+
+	apiMux := mux.NewRouter()
+	ms, _ := NewManagementServer(cm, nil, apiMux, cfg)
+	ms.Routes()
+
+	identity := `{ "identity": {"account_number": "540155", "type": "User", "internal": { "org_id": "1979710" } } }`
+	identityHeader := base64.StdEncoding.EncodeToString([]byte(identity))
+
+	req, _ := http.NewRequest("POST", CONNECTION_STATUS_ENDPOINT+"?pretty=true", strings.NewReader(`{"account": "1234", "nodeId": "345"}`))
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add(IDENTITY_HEADER_NAME, identityHeader)
+
+	rr := httptest.NewRecorder()
+	apiMux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var status map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &status)
+	if status["status"] != CONNECTED_STATUS {
+		t.Fatalf("Expected the node to be connected, got %s", rr.Body.String())
+	}
+
+	req, _ = http.NewRequest("GET", CONNECTION_LIST_ENDPOINT+"/1234/345", nil)
+	req.Header.Add(IDENTITY_HEADER_NAME, identityHeader)
+
+	rr = httptest.NewRecorder()
+	apiMux.ServeHTTP(rr, req)
+
+	var detail map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &detail)
+	if detail["nodeId"] != "345" {
+		t.Fatalf("Expected the node id to be encoded in camelCase, got %s", rr.Body.String())
+	}
+	if _, exists := detail["node_id"]; exists {
+		t.Fatalf("Expected the node id to not be encoded in snake_case, got %s", rr.Body.String())
+	}
+}
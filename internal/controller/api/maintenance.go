@@ -0,0 +1,147 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/middlewares"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+	"github.com/redhatinsights/platform-go-middlewares/request_id"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MaintenanceMode tracks whether the pod is in maintenance.  While it is, the
+// endpoints that initiate work on the nodes are rejected with a 503 but the
+// connections are kept alive and the read-only endpoints keep working.  The
+// management server and the job receiver of a pod share the same
+// MaintenanceMode.
+type MaintenanceMode struct {
+	lock      sync.RWMutex
+	enabled   bool
+	since     time.Time
+	enabledBy string
+}
+
+func NewMaintenanceMode() *MaintenanceMode {
+	return &MaintenanceMode{}
+}
+
+// Enabled reports whether the pod is in maintenance
+func (m *MaintenanceMode) Enabled() bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.enabled
+}
+
+// SetEnabled turns the maintenance mode on or off.  clientID identifies who
+// turned it on.
+func (m *MaintenanceMode) SetEnabled(enabled bool, clientID string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.enabled == enabled {
+		return
+	}
+
+	m.enabled = enabled
+	if enabled {
+		m.since = time.Now()
+		m.enabledBy = clientID
+	} else {
+		m.since = time.Time{}
+		m.enabledBy = ""
+	}
+}
+
+func (m *MaintenanceMode) status() maintenanceResponse {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	response := maintenanceResponse{Enabled: m.enabled, EnabledBy: m.enabledBy}
+	if m.enabled {
+		since := m.since
+		response.Since = &since
+	}
+	return response
+}
+
+// rejectDuringMaintenance rejects the requests with a 503 while the pod is in
+// maintenance
+func (m *MaintenanceMode) rejectDuringMaintenance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if m.Enabled() {
+			logger.Log.WithFields(logrus.Fields{"path": req.URL.Path}).Debug("Rejecting request...maintenance in progress")
+			errorResponse := errorResponse{Title: "Maintenance in progress",
+				Status: http.StatusServiceUnavailable,
+				Detail: "New work is not accepted while the maintenance is in progress"}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+type maintenanceRequest struct {
+	Enabled *bool `json:"enabled" validate:"required"`
+}
+
+type maintenanceResponse struct {
+	Enabled   bool       `json:"enabled"`
+	Since     *time.Time `json:"since,omitempty"`
+	EnabledBy string     `json:"enabled_by,omitempty"`
+}
+
+// SetMaintenanceMode shares the maintenance mode of the pod with the
+// management server.  It must be called before Routes.
+func (s *ManagementServer) SetMaintenanceMode(maintenance *MaintenanceMode) {
+	s.maintenance = maintenance
+}
+
+func (s *ManagementServer) handleMaintenanceStatus() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		writeJSONResponse(w, http.StatusOK, s.maintenance.status())
+	}
+}
+
+func (s *ManagementServer) handleMaintenanceToggle() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		principal, _ := middlewares.GetPrincipal(req.Context())
+		requestId := request_id.GetReqID(req.Context())
+		logger := logger.Log.WithFields(logrus.Fields{
+			"account":    principal.GetAccount(),
+			"request_id": requestId})
+
+		body := http.MaxBytesReader(w, req.Body, 1048576)
+
+		var maintenanceReq maintenanceRequest
+
+		if err := decodeJSON(req.Context(), body, &maintenanceReq); err != nil {
+			errorResponse := errorResponse{Title: "Unable to process json input",
+				Status: http.StatusBadRequest,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		clientID := middlewares.GetClientID(req.Context())
+
+		logger.WithFields(logrus.Fields{"audit": true, "client_id": clientID, "enabled": *maintenanceReq.Enabled}).Info("Toggling the maintenance mode")
+
+		s.maintenance.SetEnabled(*maintenanceReq.Enabled, clientID)
+
+		writeJSONResponse(w, http.StatusOK, s.maintenance.status())
+	}
+}
+
+// SetMaintenanceMode shares the maintenance mode of the pod with the job
+// receiver.  It must be called before Routes.
+func (jr *JobReceiver) SetMaintenanceMode(maintenance *MaintenanceMode) {
+	jr.maintenance = maintenance
+}
@@ -1,9 +1,16 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	_ "net/http/pprof"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
 	"github.com/RedHatInsights/platform-receptor-controller/internal/controller"
@@ -12,37 +19,135 @@ import (
 	"github.com/redhatinsights/platform-go-middlewares/request_id"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 )
 
 const (
 	CONNECTED_STATUS    = "connected"
+	CONNECTING_STATUS   = "connecting"
 	DISCONNECTED_STATUS = "disconnected"
+
+	defaultPrefixListingLimit = 100
+	maxPrefixListingLimit     = 1000
+
+	connectionEventsKeepaliveInterval = 30 * time.Second
+
+	defaultRecentEventsLimit = 100
+	maxRecentEventsLimit     = 1000
 )
 
 type ManagementServer struct {
-	connectionMgr controller.ConnectionLocator
-	router        *mux.Router
-	config        *config.Config
+	// quarantineGeneration is accessed atomically and kept first for 64-bit
+	// alignment
+	quarantineGeneration uint64
+
+	connectionMgr     controller.ConnectionLocator
+	connectionEvents  *controller.ConnectionEventBroker
+	connectionStats   *controller.ConnectionStatsTracker
+	accountThroughput *controller.AccountThroughputTracker
+	statsCache        connectionStatsCache
+	maintenance       *MaintenanceMode
+	healthChecks      []healthCheck
+	router            *mux.Router
+	config            *config.Config
 }
 
-func NewManagementServer(cm controller.ConnectionLocator, r *mux.Router, cfg *config.Config) *ManagementServer {
-	return &ManagementServer{
-		connectionMgr: cm,
-		router:        r,
-		config:        cfg,
+// NewManagementServer creates the management server.  The connection events
+// broker can be nil if connection events are not available in this process.
+// An error is returned if the connection locator, router or config is missing.
+func NewManagementServer(cm controller.ConnectionLocator, events *controller.ConnectionEventBroker, r *mux.Router, cfg *config.Config) (*ManagementServer, error) {
+	if cm == nil {
+		return nil, errors.New("management server requires a connection locator")
+	}
+
+	if r == nil {
+		return nil, errors.New("management server requires a router")
+	}
+
+	if cfg == nil {
+		return nil, errors.New("management server requires a config")
 	}
+
+	s := &ManagementServer{
+		connectionMgr:    cm,
+		connectionEvents: events,
+		maintenance:      NewMaintenanceMode(),
+		router:           r,
+		config:           cfg,
+	}
+
+	s.AddHealthCheck(HEALTH_CHECK_GOROUTINES, s.goroutinesHealthCheck)
+	s.AddHealthCheck(HEALTH_CHECK_CONNECTIONS, s.connectionsHealthCheck)
+
+	return s, nil
+}
+
+// newAccessLogger logs the requests with the configured verbosity
+func newAccessLogger(cfg *config.Config) func(http.Handler) http.Handler {
+	return logger.NewAccessLoggerMiddleware(logger.AccessLogVerbosity{
+		ReadOnlySampleRate: cfg.AccessLogReadOnlySampleRate,
+		SuppressedPaths:    cfg.AccessLogSuppressedPaths,
+	})
 }
 
 func (s *ManagementServer) Routes() {
 	securedSubRouter := s.router.PathPrefix("/connection").Subrouter()
 	amw := &middlewares.AuthMiddleware{Secrets: s.config.ServiceToServiceCredentials}
-	securedSubRouter.Use(logger.AccessLoggerMiddleware, amw.Authenticate)
+	rmw := &middlewares.ResponseHeadersMiddleware{IncludePrincipal: s.config.DebugPrincipalHeader}
+	pmw := &prettyJSONMiddleware{always: s.config.PrettyJSONResponses}
+	nmw := newJSONNamingMiddleware(s.config.JSONNaming)
+	accessLogger := newAccessLogger(s.config)
+	securedSubRouter.Use(accessLogger, rmw.AddRequestID, amw.Authenticate, rmw.AddPrincipal, nmw.RenameFields, pmw.IndentResponses)
+
+	accountPath := "/{id:" + accountPattern + "}"
+	connectionPath := "/{account:" + accountPattern + "}/{node_id}"
+
 	securedSubRouter.HandleFunc("", s.handleConnectionListing()).Methods(http.MethodGet)
-	securedSubRouter.HandleFunc("/{id:[0-9]+}", s.handleConnectionListingByAccount()).Methods(http.MethodGet)
-	securedSubRouter.HandleFunc("/disconnect", s.handleDisconnect()).Methods(http.MethodPost)
-	securedSubRouter.HandleFunc("/status", s.handleConnectionStatus()).Methods(http.MethodPost)
-	securedSubRouter.HandleFunc("/ping", s.handleConnectionPing()).Methods(http.MethodPost)
+	securedSubRouter.HandleFunc(accountPath, s.handleConnectionListingByAccount()).Methods(http.MethodGet)
+	securedSubRouter.Handle("/disconnect", middlewares.RequireJSONContentType(s.handleDisconnect())).Methods(http.MethodPost)
+	securedSubRouter.Handle("/status", middlewares.RequireJSONContentType(s.handleConnectionStatus())).Methods(http.MethodPost)
+	securedSubRouter.Handle("/ping", s.maintenance.rejectDuringMaintenance(middlewares.RequireJSONContentType(s.handleConnectionPing()))).Methods(http.MethodPost)
+	securedSubRouter.Handle("/ping/batch", s.maintenance.rejectDuringMaintenance(middlewares.RequireJSONContentType(s.handleConnectionPingBatch()))).Methods(http.MethodPost)
+	securedSubRouter.Handle("/broadcast", s.maintenance.rejectDuringMaintenance(middlewares.RequireJSONContentType(s.handleConnectionBroadcast()))).Methods(http.MethodPost)
+	securedSubRouter.Handle("/send_by_selector", s.maintenance.rejectDuringMaintenance(middlewares.RequireJSONContentType(s.handleConnectionSendBySelector()))).Methods(http.MethodPost)
+	securedSubRouter.HandleFunc("/events", s.handleConnectionEvents()).Methods(http.MethodGet)
+	securedSubRouter.HandleFunc("/events/recent", s.handleRecentConnectionEvents()).Methods(http.MethodGet)
+	securedSubRouter.HandleFunc(connectionPath, s.handleConnectionDetail()).Methods(http.MethodGet)
+	securedSubRouter.HandleFunc(connectionPath+"/wait", s.handleConnectionWait()).Methods(http.MethodGet)
+	securedSubRouter.HandleFunc(connectionPath+"/history", s.handleConnectionHistory()).Methods(http.MethodGet)
+	securedSubRouter.HandleFunc(connectionPath+"/pause", s.handleConnectionPause()).Methods(http.MethodPost)
+	securedSubRouter.HandleFunc(connectionPath+"/resume", s.handleConnectionResume()).Methods(http.MethodPost)
+	securedSubRouter.HandleFunc(connectionPath+"/quarantine", s.handleConnectionQuarantine()).Methods(http.MethodPost)
+	securedSubRouter.HandleFunc(connectionPath+"/unquarantine", s.handleConnectionUnquarantine()).Methods(http.MethodPost)
+	securedSubRouter.HandleFunc(connectionPath+"/timeouts", s.handleConnectionTimeouts()).Methods(http.MethodPut)
+
+	statsSubRouter := s.router.PathPrefix("/stats").Subrouter()
+	statsSubRouter.Use(accessLogger, rmw.AddRequestID, amw.Authenticate, rmw.AddPrincipal, nmw.RenameFields, pmw.IndentResponses)
+	statsSubRouter.HandleFunc("", s.handleConnectionStats()).Methods(http.MethodGet)
+	statsSubRouter.HandleFunc("/account"+accountPath, s.handleAccountThroughputStats()).Methods(http.MethodGet)
+
+	routingSubRouter := s.router.PathPrefix("/routing").Subrouter()
+	routingSubRouter.Use(accessLogger, rmw.AddRequestID, amw.Authenticate, rmw.AddPrincipal, nmw.RenameFields, pmw.IndentResponses)
+	routingSubRouter.HandleFunc(accountPath, s.handleRoutingTableByAccount()).Methods(http.MethodGet)
+
+	// The health endpoint is polled by the orchestrator, which does not
+	// authenticate
+	healthSubRouter := s.router.PathPrefix("/healthz").Subrouter()
+	healthSubRouter.Use(accessLogger, rmw.AddRequestID, nmw.RenameFields, pmw.IndentResponses)
+	healthSubRouter.HandleFunc("/detailed", s.handleDetailedHealth()).Methods(http.MethodGet)
+
+	adminSubRouter := s.router.PathPrefix("/admin").Subrouter()
+	adminMw := &middlewares.AdminMiddleware{AdminClientIDs: s.config.AdminClientIDs}
+	adminSubRouter.Use(accessLogger, rmw.AddRequestID, amw.Authenticate, rmw.AddPrincipal, adminMw.RequireAdmin, nmw.RenameFields, pmw.IndentResponses)
+	adminSubRouter.Handle("/connections/import", middlewares.RequireJSONContentType(s.handleConnectionImport())).Methods(http.MethodPost)
+	adminSubRouter.HandleFunc("/capabilities/refresh", s.handleCapabilitiesRefresh()).Methods(http.MethodPost)
+	adminSubRouter.HandleFunc("/connections/reap", s.handleConnectionReap()).Methods(http.MethodPost)
+	adminSubRouter.Handle("/rebalance", middlewares.RequireJSONContentType(s.handleRebalance())).Methods(http.MethodPost)
+	adminSubRouter.HandleFunc("/maintenance", s.handleMaintenanceStatus()).Methods(http.MethodGet)
+	adminSubRouter.Handle("/maintenance", middlewares.RequireJSONContentType(s.handleMaintenanceToggle())).Methods(http.MethodPost)
+	adminSubRouter.HandleFunc("/config", s.handleConfig()).Methods(http.MethodGet)
+
 	if s.config.Profile {
 		logger.Log.Warn("WARNING: Enabling the profiler endpoint!!")
 		s.router.PathPrefix("/debug").Handler(http.DefaultServeMux)
@@ -50,18 +155,152 @@ func (s *ManagementServer) Routes() {
 }
 
 type connectionID struct {
-	Account string `json:"account" validate:"required"`
+	Account string `json:"account" validate:"required,account"`
 	NodeID  string `json:"node_id" validate:"required"`
 }
 
+// connectionDisconnectResponse lists the connections that were closed (or, in
+// a dry run, the connections that would have been closed)
+type connectionDisconnectResponse struct {
+	Connections []connectionID `json:"connections"`
+	DryRun      bool           `json:"dry_run"`
+}
+
 type connectionStatusResponse struct {
-	Status       string      `json:"status"`
-	Capabilities interface{} `json:"capabilities,omitempty"`
+	Status              string      `json:"status"`
+	Health              string      `json:"health,omitempty"`
+	Capabilities        interface{} `json:"capabilities,omitempty"`
+	CapabilitiesError   string      `json:"capabilities_error,omitempty"`
+	CapabilitiesPartial bool        `json:"capabilities_partial,omitempty"`
+	Paused              bool        `json:"paused,omitempty"`
+	PausedByNode        bool        `json:"paused_by_node,omitempty"`
+	BackOffUntil        *time.Time  `json:"back_off_until,omitempty"`
+	Quarantined         bool        `json:"quarantined,omitempty"`
+}
+
+type connectionDetailResponse struct {
+	Account string `json:"account"`
+	NodeID  string `json:"node_id"`
+	Pod     string `json:"pod,omitempty"`
+	connectionStatusResponse
+	ConnectedAt     *time.Time              `json:"connected_at,omitempty"`
+	UptimeSeconds   float64                 `json:"uptime_seconds,omitempty"`
+	Metadata        interface{}             `json:"metadata,omitempty"`
+	Labels          map[string]string       `json:"labels,omitempty"`
+	ProtocolVersion int                     `json:"protocol_version,omitempty"`
+	Ping            *connectionPingResponse `json:"ping,omitempty"`
+	PingError       string                  `json:"ping_error,omitempty"`
+}
+
+type connectionWaitResponse struct {
+	Account string `json:"account"`
+	NodeID  string `json:"node_id"`
+	Status  string `json:"status"`
+	Reached bool   `json:"reached"`
+}
+
+type recentConnectionEventsResponse struct {
+	Events []controller.ConnectionEvent `json:"events"`
+}
+
+type connectionPauseResponse struct {
+	Paused bool `json:"paused"`
+}
+
+type connectionQuarantineResponse struct {
+	Quarantined bool `json:"quarantined"`
+}
+
+type connectionPingRequest struct {
+	connectionID
+	Retry bool `json:"retry,omitempty"`
 }
 
 type connectionPingResponse struct {
-	Status  string      `json:"status"`
-	Payload interface{} `json:"payload"`
+	Status    string      `json:"status"`
+	Payload   interface{} `json:"payload"`
+	LatencyMS float64     `json:"latency_ms,omitempty"`
+}
+
+type connectionPingBatchRequest struct {
+	Connections []connectionID `json:"connections" validate:"required"`
+	Retry       bool           `json:"retry,omitempty"`
+}
+
+func (r *connectionPingBatchRequest) batchItems() interface{} {
+	return r.Connections
+}
+
+type connectionPingBatchResult struct {
+	Account string `json:"account"`
+	NodeID  string `json:"node_id"`
+	connectionPingResponse
+	Error string `json:"error,omitempty"`
+}
+
+type connectionPingBatchResponse struct {
+	Results []connectionPingBatchResult `json:"results"`
+}
+
+type connectionBroadcastRequest struct {
+	Account   string      `json:"account" validate:"required,account"`
+	Payload   interface{} `json:"payload" validate:"required"`
+	Directive string      `json:"directive" validate:"required"`
+	TTL       int         `json:"ttl,omitempty" validate:"gte=0"`
+}
+
+type connectionBroadcastResponse struct {
+	Jobs   map[string]string `json:"jobs"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+type routingTableResponse struct {
+	Account       string                              `json:"account"`
+	RoutingTables map[string]*controller.RoutingTable `json:"routing_tables"`
+}
+
+type importedConnection struct {
+	Account string `json:"account" validate:"required,account"`
+	NodeID  string `json:"node_id" validate:"required"`
+	Pod     string `json:"pod" validate:"required"`
+}
+
+type connectionImportRequest struct {
+	Connections []importedConnection `json:"connections" validate:"required"`
+}
+
+func (r *connectionImportRequest) batchItems() interface{} {
+	return r.Connections
+}
+
+type connectionImportResponse struct {
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"`
+}
+
+type capabilitiesRefreshError struct {
+	connectionID
+	Error string `json:"error"`
+}
+
+type capabilitiesRefreshResponse struct {
+	Refreshed int                        `json:"refreshed"`
+	Failed    int                        `json:"failed"`
+	Skipped   int                        `json:"skipped"`
+	Errors    []capabilitiesRefreshError `json:"errors,omitempty"`
+}
+
+type connectionReapResult struct {
+	connectionID
+	IdleSeconds int    `json:"idle_seconds"`
+	Error       string `json:"error,omitempty"`
+}
+
+// connectionReapResponse lists the idle connections that were closed
+type connectionReapResponse struct {
+	Reaped      int                    `json:"reaped"`
+	Failed      int                    `json:"failed"`
+	Connections []connectionReapResult `json:"connections"`
 }
 
 func (s *ManagementServer) handleDisconnect() http.HandlerFunc {
@@ -78,7 +317,7 @@ func (s *ManagementServer) handleDisconnect() http.HandlerFunc {
 
 		var connID connectionID
 
-		if err := decodeJSON(body, &connID); err != nil {
+		if err := decodeJSON(req.Context(), body, &connID); err != nil {
 			errorResponse := errorResponse{Title: "Unable to process json input",
 				Status: http.StatusBadRequest,
 				Detail: err.Error()}
@@ -86,6 +325,10 @@ func (s *ManagementServer) handleDisconnect() http.HandlerFunc {
 			return
 		}
 
+		if requestCancelled(w, req, logger) {
+			return
+		}
+
 		client := s.connectionMgr.GetConnection(connID.Account, connID.NodeID)
 		if client == nil {
 			errMsg := fmt.Sprintf("No connection found for node (%s:%s)", connID.Account, connID.NodeID)
@@ -97,12 +340,24 @@ func (s *ManagementServer) handleDisconnect() http.HandlerFunc {
 			return
 		}
 
+		response := connectionDisconnectResponse{
+			Connections: []connectionID{connID},
+			DryRun:      req.URL.Query().Get("dry_run") == "true",
+		}
+
+		if response.DryRun {
+			logger.Infof("Dry run...not disconnecting account:%s - node id:%s",
+				connID.Account, connID.NodeID)
+			writeJSONResponse(w, http.StatusOK, response)
+			return
+		}
+
 		logger.Infof("Attempting to disconnect account:%s - node id:%s",
 			connID.Account, connID.NodeID)
 
-		client.Close(req.Context())
+		client.Close(controller.WithCloseReason(req.Context(), controller.CLOSE_REASON_ADMIN_DISCONNECT))
 
-		writeJSONResponse(w, http.StatusOK, struct{}{})
+		writeJSONResponse(w, http.StatusOK, response)
 	}
 }
 
@@ -120,7 +375,7 @@ func (s *ManagementServer) handleConnectionStatus() http.HandlerFunc {
 
 		var connID connectionID
 
-		if err := decodeJSON(body, &connID); err != nil {
+		if err := decodeJSON(req.Context(), body, &connID); err != nil {
 			errorResponse := errorResponse{Title: "Unable to process json input",
 				Status: http.StatusBadRequest,
 				Detail: err.Error()}
@@ -128,6 +383,10 @@ func (s *ManagementServer) handleConnectionStatus() http.HandlerFunc {
 			return
 		}
 
+		if requestCancelled(w, req, logger) {
+			return
+		}
+
 		logger.Infof("Checking connection status for account:%s - node id:%s",
 			connID.Account, connID.NodeID)
 
@@ -135,14 +394,7 @@ func (s *ManagementServer) handleConnectionStatus() http.HandlerFunc {
 
 		client := s.connectionMgr.GetConnection(connID.Account, connID.NodeID)
 		if client != nil {
-			connectionStatus.Status = CONNECTED_STATUS
-			capabilities, err := client.GetCapabilities(req.Context())
-			if err != nil {
-				logger.WithFields(
-					logrus.Fields{"error": err},
-				).Errorf("Unable to retrieve the capabilities of node %s", connID.NodeID)
-			}
-			connectionStatus.Capabilities = capabilities
+			connectionStatus = getConnectionStatus(req.Context(), logger, connID, client)
 		} else {
 			connectionStatus.Status = DISCONNECTED_STATUS
 		}
@@ -166,9 +418,9 @@ func (s *ManagementServer) handleConnectionPing() http.HandlerFunc {
 
 		body := http.MaxBytesReader(w, req.Body, 1048576)
 
-		var connID connectionID
+		var pingRequest connectionPingRequest
 
-		if err := decodeJSON(body, &connID); err != nil {
+		if err := decodeJSON(req.Context(), body, &pingRequest); err != nil {
 			errorResponse := errorResponse{Title: "Unable to process json input",
 				Status: http.StatusBadRequest,
 				Detail: err.Error()}
@@ -176,19 +428,16 @@ func (s *ManagementServer) handleConnectionPing() http.HandlerFunc {
 			return
 		}
 
-		logger.Infof("Submitting ping for account:%s - node id:%s",
-			connID.Account, connID.NodeID)
-
-		pingResponse := connectionPingResponse{Status: DISCONNECTED_STATUS}
-		client := s.connectionMgr.GetConnection(connID.Account, connID.NodeID)
-		if client == nil {
-			writeJSONResponse(w, http.StatusOK, pingResponse)
+		if requestCancelled(w, req, logger) {
 			return
 		}
 
-		pingResponse.Status = CONNECTED_STATUS
-		var err error
-		pingResponse.Payload, err = client.Ping(req.Context(), connID.Account, connID.NodeID, []string{connID.NodeID})
+		connID := pingRequest.connectionID
+
+		logger.Infof("Submitting ping for account:%s - node id:%s",
+			connID.Account, connID.NodeID)
+
+		pingResponse, err := s.pingConnection(withRequestTraceContext(req, logger), logger, connID, pingRequest.Retry)
 		if err != nil {
 			errorResponse := errorResponse{Title: "Ping failed",
 				Status: http.StatusBadRequest,
@@ -201,16 +450,63 @@ func (s *ManagementServer) handleConnectionPing() http.HandlerFunc {
 	}
 }
 
-func (s *ManagementServer) handleConnectionListing() http.HandlerFunc {
+func (s *ManagementServer) handleConnectionPingBatch() http.HandlerFunc {
 
-	type ConnectionsPerAccount struct {
-		AccountNumber string   `json:"account"`
-		Connections   []string `json:"connections"`
-	}
+	return func(w http.ResponseWriter, req *http.Request) {
 
-	type Response struct {
-		Connections []ConnectionsPerAccount `json:"connections"`
+		principal, _ := middlewares.GetPrincipal(req.Context())
+		requestId := request_id.GetReqID(req.Context())
+		logger := logger.Log.WithFields(logrus.Fields{
+			"account":    principal.GetAccount(),
+			"request_id": requestId})
+
+		var pingRequest connectionPingBatchRequest
+
+		if decodeJSONBatch(w, req, &pingRequest) == false {
+			return
+		}
+
+		if requestCancelled(w, req, logger) {
+			return
+		}
+
+		logger.Infof("Submitting %d pings", len(pingRequest.Connections))
+
+		results := make([]connectionPingBatchResult, len(pingRequest.Connections))
+
+		var wg sync.WaitGroup
+		for i, connID := range pingRequest.Connections {
+			wg.Add(1)
+			go func(i int, connID connectionID) {
+				defer wg.Done()
+
+				results[i].Account = connID.Account
+				results[i].NodeID = connID.NodeID
+
+				pingResponse, err := s.pingConnection(withRequestTraceContext(req, logger), logger, connID, pingRequest.Retry)
+				results[i].connectionPingResponse = pingResponse
+				if err != nil {
+					logger.WithFields(logrus.Fields{"error": err}).Infof("Ping failed for account:%s - node id:%s",
+						connID.Account, connID.NodeID)
+					results[i].Error = err.Error()
+				}
+			}(i, connID)
+		}
+		wg.Wait()
+
+		status := http.StatusOK
+		for _, result := range results {
+			if result.Error != "" {
+				status = http.StatusMultiStatus
+				break
+			}
+		}
+
+		writeJSONResponse(w, status, connectionPingBatchResponse{Results: results})
 	}
+}
+
+func (s *ManagementServer) handleConnectionBroadcast() http.HandlerFunc {
 
 	return func(w http.ResponseWriter, req *http.Request) {
 
@@ -220,58 +516,1507 @@ func (s *ManagementServer) handleConnectionListing() http.HandlerFunc {
 			"account":    principal.GetAccount(),
 			"request_id": requestId})
 
-		logger.Debugf("Getting connection list")
+		body := http.MaxBytesReader(w, req.Body, 1048576)
 
-		allReceptorConnections := s.connectionMgr.GetAllConnections()
+		var broadcastRequest connectionBroadcastRequest
+
+		if err := decodeJSON(req.Context(), body, &broadcastRequest); err != nil {
+			errorResponse := errorResponse{Title: "Unable to process json input",
+				Status: http.StatusBadRequest,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
 
-		connections := make([]ConnectionsPerAccount, len(allReceptorConnections))
+		if requestCancelled(w, req, logger) {
+			return
+		}
 
-		accountCount := 0
-		for key, value := range allReceptorConnections {
-			connections[accountCount].AccountNumber = key
-			connections[accountCount].Connections = make([]string, len(value))
-			nodeCount := 0
-			for k, _ := range value {
-				connections[accountCount].Connections[nodeCount] = k
-				nodeCount++
-			}
+		if err := controller.VerifyDirective(s.config.AllowedDirectives, broadcastRequest.Directive); err != nil {
+			logger.WithFields(logrus.Fields{"audit": true, "directive": broadcastRequest.Directive}).Warn("Rejected a broadcast with a directive that is not allowed")
+			errorResponse := errorResponse{Title: "Directive not allowed",
+				Status: http.StatusBadRequest,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		connections := s.connectionMgr.GetConnectionsByAccount(broadcastRequest.Account)
+		if len(connections) == 0 {
+			errMsg := fmt.Sprintf("No connections found for account %s", broadcastRequest.Account)
+			logger.Info(errMsg)
+			errorResponse := errorResponse{Title: errMsg,
+				Status: http.StatusNotFound,
+				Detail: errMsg}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		logger = logger.WithFields(logrus.Fields{"directive": broadcastRequest.Directive})
+		logger.Infof("Broadcasting a message to %d nodes of account %s", len(connections), broadcastRequest.Account)
+
+		response := s.sendToConnections(withMessageTTL(withRequestTraceContext(req, logger), broadcastRequest.TTL), logger,
+			broadcastRequest.Account, connections, broadcastRequest.Payload, broadcastRequest.Directive)
+
+		logger.Infof("Broadcast message sent to %d of %d nodes", len(response.Jobs), len(connections))
 
-			accountCount++
+		status := http.StatusCreated
+		if len(response.Errors) > 0 {
+			status = http.StatusMultiStatus
 		}
 
-		response := Response{Connections: connections}
+		writeJSONResponse(w, status, response)
+	}
+}
 
-		writeJSONResponse(w, http.StatusOK, response)
+// withMessageTTL sets the expiry of the messages sent with ctx to ttl seconds
+// from now.  A ttl of 0 means the messages do not expire.
+func withMessageTTL(ctx context.Context, ttl int) context.Context {
+	if ttl > 0 {
+		return controller.WithMessageExpiry(ctx, time.Now().Add(time.Duration(ttl)*time.Second))
 	}
+	return ctx
 }
 
-func (s *ManagementServer) handleConnectionListingByAccount() http.HandlerFunc {
+// sendToConnections fans the message out to the connections of the account.
+// The job id of each node the message was passed to is returned, along with
+// the errors keyed by node id.
+func (s *ManagementServer) sendToConnections(ctx context.Context, logger *logrus.Entry, account string, connections map[string]controller.Receptor, payload interface{}, directive string) connectionBroadcastResponse {
+	response := connectionBroadcastResponse{Jobs: make(map[string]string)}
+	var responseLock sync.Mutex
 
-	type Response struct {
-		Connections []string `json:"connections"`
+	// The sends are bounded so that a large account does not tie up a
+	// goroutine per node
+	concurrency := make(chan struct{}, s.broadcastConcurrency())
+
+	var wg sync.WaitGroup
+	for nodeID, client := range connections {
+		wg.Add(1)
+		concurrency <- struct{}{}
+		go func(nodeID string, client controller.Receptor) {
+			defer func() {
+				<-concurrency
+				wg.Done()
+			}()
+
+			jobID, err := client.SendMessage(ctx, account, nodeID,
+				[]string{nodeID},
+				payload,
+				directive)
+
+			responseLock.Lock()
+			defer responseLock.Unlock()
+
+			if err != nil {
+				logger.WithFields(logrus.Fields{"error": err, "recipient": nodeID}).Info("Error passing broadcast message to receptor")
+				if response.Errors == nil {
+					response.Errors = make(map[string]string)
+				}
+				response.Errors[nodeID] = err.Error()
+				return
+			}
+
+			response.Jobs[nodeID] = jobID.String()
+		}(nodeID, client)
+	}
+	wg.Wait()
+
+	return response
+}
+
+func (s *ManagementServer) broadcastConcurrency() int {
+	if s.config.BroadcastConcurrency > 0 {
+		return s.config.BroadcastConcurrency
+	}
+	return 1
+}
+
+// pingConnection pings the node and records the round-trip latency of the
+// ping.  A node that is not connected is reported as disconnected rather than
+// as an error.  If retry is set and the ping fails because the node
+// reconnected while it was being pinged, the ping is retried once using the
+// new connection.
+func (s *ManagementServer) pingConnection(ctx context.Context, logger *logrus.Entry, connID connectionID, retry bool) (connectionPingResponse, error) {
+	pingResponse := connectionPingResponse{Status: DISCONNECTED_STATUS}
+	client := s.connectionMgr.GetConnection(connID.Account, connID.NodeID)
+	if client == nil {
+		return pingResponse, nil
+	}
+
+	pingResponse.Status = CONNECTED_STATUS
+	var err error
+	pingStart := time.Now()
+	pingResponse.Payload, err = client.Ping(ctx, connID.Account, connID.NodeID, []string{connID.NodeID})
+	if err != nil && retry {
+		current := s.connectionMgr.GetConnection(connID.Account, connID.NodeID)
+		if current != nil && current != client {
+			logger.WithFields(logrus.Fields{"error": err}).Infof("Connection for account:%s - node id:%s was replaced during the ping...retrying",
+				connID.Account, connID.NodeID)
+			managementMetrics.pingRetryCounter.Inc()
+
+			pingStart = time.Now()
+			pingResponse.Payload, err = current.Ping(ctx, connID.Account, connID.NodeID, []string{connID.NodeID})
+		}
+	}
+	pingLatency := time.Since(pingStart)
+	if err != nil {
+		return pingResponse, err
+	}
+
+	traceID := ""
+	if tc, ok := controller.GetTraceContext(ctx); ok {
+		traceID = tc.TraceID()
+	}
+	managementMetrics.pingLatency.ObserveWithTraceID(prometheus.Labels{"account": connID.Account}, pingLatency.Seconds(), traceID)
+	pingResponse.LatencyMS = float64(pingLatency) / float64(time.Millisecond)
+
+	return pingResponse, nil
+}
+
+// getConnectionStatus builds the status of a connected node from the state
+// cached by its receptor
+func getConnectionStatus(ctx context.Context, logger *logrus.Entry, connID connectionID, client controller.Receptor) connectionStatusResponse {
+	connectionStatus := connectionStatusResponse{Status: CONNECTED_STATUS}
+
+	capabilities, err := client.GetCapabilities(ctx)
+	if malformedErr, ok := err.(controller.MalformedCapabilitiesError); ok {
+		logger.WithFields(
+			logrus.Fields{"error": err, "reason": malformedErr.Reason, "raw_capabilities": malformedErr.Raw},
+		).Warnf("Node %s returned malformed capabilities", connID.NodeID)
+		connectionStatus.CapabilitiesError = malformedErr.Reason
+	} else if _, ok := err.(controller.PartialCapabilitiesError); ok {
+		logger.Infof("Node %s has not sent all of its capabilities", connID.NodeID)
+		connectionStatus.CapabilitiesPartial = true
+	} else if err != nil {
+		logger.WithFields(
+			logrus.Fields{"error": err},
+		).Errorf("Unable to retrieve the capabilities of node %s", connID.NodeID)
+	}
+	connectionStatus.Capabilities = capabilities
+
+	health, err := client.GetHealth(ctx)
+	if err != nil {
+		logger.WithFields(
+			logrus.Fields{"error": err},
+		).Errorf("Unable to retrieve the health of node %s", connID.NodeID)
+	}
+	connectionStatus.Health = health
+
+	if pausable, ok := client.(controller.Pausable); ok {
+		connectionStatus.Paused = pausable.IsPaused(ctx)
+	}
+
+	if nodePause, ok := client.(controller.NodePauseReporter); ok {
+		connectionStatus.PausedByNode = nodePause.IsPausedByNode(ctx)
+		if until := nodePause.GetBackOffUntil(ctx); !until.IsZero() {
+			connectionStatus.BackOffUntil = &until
+		}
+	}
+
+	if quarantinable, ok := client.(controller.Quarantinable); ok {
+		connectionStatus.Quarantined = quarantinable.IsQuarantined(ctx)
+	}
+
+	// The messages sent while the connection is warming up are held until
+	// the connection is ready
+	if warmup, ok := client.(controller.WarmupReporter); ok && warmup.IsWarmingUp() {
+		connectionStatus.Status = CONNECTING_STATUS
 	}
 
+	return connectionStatus
+}
+
+func (s *ManagementServer) handleConnectionDetail() http.HandlerFunc {
+
 	return func(w http.ResponseWriter, req *http.Request) {
 
 		principal, _ := middlewares.GetPrincipal(req.Context())
 		requestId := request_id.GetReqID(req.Context())
-		accountId := mux.Vars(req)["id"]
 		logger := logger.Log.WithFields(logrus.Fields{
 			"account":    principal.GetAccount(),
 			"request_id": requestId})
 
-		logger.Debug("Getting connections for ", accountId)
+		params := mux.Vars(req)
+		connID := connectionID{Account: params["account"], NodeID: params["node_id"]}
 
-		accountConnections := s.connectionMgr.GetConnectionsByAccount(accountId)
-		connections := make([]string, len(accountConnections))
+		if requestCancelled(w, req, logger) {
+			return
+		}
+
+		logger.Infof("Getting connection detail for account:%s - node id:%s",
+			connID.Account, connID.NodeID)
+
+		client := s.connectionMgr.GetConnection(connID.Account, connID.NodeID)
+		if client == nil {
+			errMsg := fmt.Sprintf("No connection found for node (%s:%s)", connID.Account, connID.NodeID)
+			logger.Info(errMsg)
+			errorResponse := errorResponse{Title: errMsg,
+				Status: http.StatusNotFound,
+				Detail: errMsg}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
 
-		connCount := 0
-		for conn := range accountConnections {
-			connections[connCount] = conn
-			connCount++
+		connectionDetail := connectionDetailResponse{
+			Account:                  connID.Account,
+			NodeID:                   connID.NodeID,
+			Pod:                      connectionPod(client),
+			connectionStatusResponse: getConnectionStatus(req.Context(), logger, connID, client),
 		}
 
-		response := Response{Connections: connections}
+		if detailer, ok := client.(controller.ConnectionDetailer); ok {
+			connectedAt, err := detailer.GetConnectedAt(req.Context())
+			if err != nil {
+				logger.WithFields(
+					logrus.Fields{"error": err},
+				).Errorf("Unable to retrieve the connection time of node %s", connID.NodeID)
+			} else if connectedAt.IsZero() == false {
+				connectionDetail.ConnectedAt = &connectedAt
+				connectionDetail.UptimeSeconds = time.Since(connectedAt).Seconds()
+			}
+
+			metadata, err := detailer.GetMetadata(req.Context())
+			if err != nil {
+				logger.WithFields(
+					logrus.Fields{"error": err},
+				).Errorf("Unable to retrieve the metadata of node %s", connID.NodeID)
+			}
+			connectionDetail.Metadata = metadata
+		}
+
+		if labeler, ok := client.(controller.ConnectionLabeler); ok {
+			labels, err := labeler.GetLabels(req.Context())
+			if err != nil {
+				logger.WithFields(
+					logrus.Fields{"error": err},
+				).Errorf("Unable to retrieve the labels of node %s", connID.NodeID)
+			}
+			connectionDetail.Labels = labels
+		}
+
+		if reporter, ok := client.(controller.ProtocolVersionReporter); ok {
+			protocolVersion, err := reporter.GetProtocolVersion(req.Context())
+			if err != nil {
+				logger.WithFields(
+					logrus.Fields{"error": err},
+				).Errorf("Unable to retrieve the protocol version of node %s", connID.NodeID)
+			}
+			connectionDetail.ProtocolVersion = protocolVersion
+		}
+
+		if req.URL.Query().Get("refresh") == "true" {
+			pingResponse, err := s.pingConnection(withRequestTraceContext(req, logger), logger, connID, false)
+			if err != nil {
+				logger.WithFields(
+					logrus.Fields{"error": err},
+				).Infof("Unable to ping node %s", connID.NodeID)
+				connectionDetail.PingError = err.Error()
+			} else {
+				connectionDetail.Ping = &pingResponse
+			}
+		}
+
+		writeJSONResponse(w, http.StatusOK, connectionDetail)
+	}
+}
+
+func (s *ManagementServer) handleConnectionListing() http.HandlerFunc {
+
+	type ConnectionsPerAccount struct {
+		AccountNumber string            `json:"account"`
+		Connections   []string          `json:"connections"`
+		Quarantined   []string          `json:"quarantined,omitempty"`
+		Pods          map[string]string `json:"pods,omitempty"`
+	}
+
+	type Response struct {
+		Connections []ConnectionsPerAccount `json:"connections"`
+	}
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		principal, _ := middlewares.GetPrincipal(req.Context())
+		requestId := request_id.GetReqID(req.Context())
+		logger := logger.Log.WithFields(logrus.Fields{
+			"account":    principal.GetAccount(),
+			"request_id": requestId})
+
+		if requestCancelled(w, req, logger) {
+			return
+		}
+
+		filter, ok := parseConnectionFilter(w, req)
+		if !ok {
+			return
+		}
+
+		if filter.isEmpty() && !acceptsNDJSON(req) {
+			if etag, ok := s.connectionListingETag(); ok && writeNotModified(w, req, etag) {
+				return
+			}
+		}
+
+		logger.Debugf("Getting connection list")
+
+		allReceptorConnections := s.connectionMgr.GetAllConnections()
+
+		if acceptsNDJSON(req) {
+			writeConnectionListingStream(w, req, logger, allReceptorConnections, filter)
+			return
+		}
+
+		connections := make([]ConnectionsPerAccount, 0, len(allReceptorConnections))
+
+		for key, value := range allReceptorConnections {
+			nodes := filterConnections(req.Context(), value, filter)
+			if !filter.isEmpty() && len(nodes) == 0 {
+				continue
+			}
+
+			connections = append(connections, ConnectionsPerAccount{AccountNumber: key, Connections: nodes,
+				Quarantined: quarantinedConnections(req.Context(), value, nodes),
+				Pods:        connectionPods(value, nodes)})
+		}
+
+		response := Response{Connections: connections}
+
+		writeJSONResponse(w, http.StatusOK, response)
+	}
+}
+
+func (s *ManagementServer) handleConnectionListingByAccount() http.HandlerFunc {
+
+	type Response struct {
+		Connections []string          `json:"connections"`
+		Quarantined []string          `json:"quarantined,omitempty"`
+		Pods        map[string]string `json:"pods,omitempty"`
+	}
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		principal, _ := middlewares.GetPrincipal(req.Context())
+		requestId := request_id.GetReqID(req.Context())
+		accountId := mux.Vars(req)["id"]
+		logger := logger.Log.WithFields(logrus.Fields{
+			"account":    principal.GetAccount(),
+			"request_id": requestId})
+
+		if requestCancelled(w, req, logger) {
+			return
+		}
+
+		filter, ok := parseConnectionFilter(w, req)
+		if !ok {
+			return
+		}
+
+		if req.URL.Query().Get("prefix") == "true" {
+			s.writeConnectionListingByAccountPrefix(w, req, logger, accountId, filter)
+			return
+		}
+
+		if filter.isEmpty() && !acceptsNDJSON(req) {
+			if etag, ok := s.connectionListingETag(); ok && writeNotModified(w, req, etag) {
+				return
+			}
+		}
+
+		logger.Debug("Getting connections for ", accountId)
+
+		accountConnections := s.connectionMgr.GetConnectionsByAccount(accountId)
+
+		if acceptsNDJSON(req) {
+			writeConnectionListingStream(w, req, logger, map[string]map[string]controller.Receptor{accountId: accountConnections}, filter)
+			return
+		}
+
+		connections := filterConnections(req.Context(), accountConnections, filter)
+
+		response := Response{Connections: connections,
+			Quarantined: quarantinedConnections(req.Context(), accountConnections, connections),
+			Pods:        connectionPods(accountConnections, connections)}
+
+		writeJSONResponse(w, http.StatusOK, response)
+	}
+}
+
+// connectionFilter selects the connections of a connection listing
+type connectionFilter struct {
+	labels          map[string]string
+	connectedBefore time.Time
+	connectedAfter  time.Time
+	health          string
+	pod             string
+}
+
+func (f connectionFilter) isEmpty() bool {
+	return len(f.labels) == 0 && f.connectedBefore.IsZero() && f.connectedAfter.IsZero() && f.health == "" && f.pod == ""
+}
+
+// parseConnectionFilter parses the label, connected_before, connected_after,
+// health and pod query parameters of a connection listing.  If a parameter
+// is malformed, a 400 response is written and false is returned.
+func parseConnectionFilter(w http.ResponseWriter, req *http.Request) (connectionFilter, bool) {
+	var filter connectionFilter
+	var ok bool
+
+	if filter.labels, ok = parseLabelSelector(w, req); !ok {
+		return filter, false
+	}
+
+	if filter.connectedBefore, ok = parseConnectedTime(w, req, "connected_before"); !ok {
+		return filter, false
+	}
+
+	if filter.connectedAfter, ok = parseConnectedTime(w, req, "connected_after"); !ok {
+		return filter, false
+	}
+
+	if filter.health, ok = parseHealth(w, req); !ok {
+		return filter, false
+	}
+
+	filter.pod = req.URL.Query().Get("pod")
+
+	return filter, true
+}
+
+// parseHealth parses the health query parameter of a connection listing.  An
+// empty string is returned if the parameter is not provided.
+func parseHealth(w http.ResponseWriter, req *http.Request) (string, bool) {
+	health := strings.ToLower(req.URL.Query().Get("health"))
+	switch health {
+	case "", controller.HEALTHY_STATUS, controller.DEGRADED_STATUS, controller.STALLED_STATUS:
+		return health, true
+	}
+
+	errorResponse := errorResponse{Title: "Invalid health",
+		Status: http.StatusBadRequest,
+		Detail: fmt.Sprintf("health must be one of %s, %s or %s",
+			controller.HEALTHY_STATUS, controller.DEGRADED_STATUS, controller.STALLED_STATUS)}
+	writeJSONResponse(w, errorResponse.Status, errorResponse)
+	return "", false
+}
+
+// parseConnectedTime parses an RFC3339 timestamp from the query parameter.
+// The zero time is returned if the parameter is not provided.
+func parseConnectedTime(w http.ResponseWriter, req *http.Request, param string) (time.Time, bool) {
+	value := req.URL.Query().Get(param)
+	if value == "" {
+		return time.Time{}, true
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		errorResponse := errorResponse{Title: "Invalid " + param,
+			Status: http.StatusBadRequest,
+			Detail: param + " must be an RFC3339 timestamp"}
+		writeJSONResponse(w, errorResponse.Status, errorResponse)
+		return time.Time{}, false
+	}
+
+	return t, true
+}
+
+// parseLabelSelector parses the label query parameters of a connection
+// listing.  Each parameter has the form name:value and a connection must match
+// all of them.  If a parameter is malformed, a 400 response is written and
+// false is returned.
+func parseLabelSelector(w http.ResponseWriter, req *http.Request) (map[string]string, bool) {
+	selector := make(map[string]string)
+	for _, label := range req.URL.Query()["label"] {
+		parts := strings.SplitN(label, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			errorResponse := errorResponse{Title: "Invalid label",
+				Status: http.StatusBadRequest,
+				Detail: "label must have the form name:value"}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return nil, false
+		}
+		selector[strings.ToLower(parts[0])] = parts[1]
+	}
+	return selector, true
+}
+
+// filterConnections returns the node ids of the connections that match the
+// filter.  Connections that do not carry labels only match an empty label
+// selector and connections that do not know when they were established only
+// match a filter without connected_before and connected_after.  Connections
+// whose health cannot be retrieved only match a filter without health and
+// connections whose pod is not known only match a filter without pod.
+func filterConnections(ctx context.Context, connections map[string]controller.Receptor, filter connectionFilter) []string {
+	nodes := make([]string, 0, len(connections))
+	for nodeID, client := range connections {
+		if matchesConnectionFilter(ctx, client, filter) {
+			nodes = append(nodes, nodeID)
+		}
+	}
+	return nodes
+}
+
+// quarantinedConnections returns the node ids of the listed connections that
+// have been quarantined
+func quarantinedConnections(ctx context.Context, connections map[string]controller.Receptor, nodes []string) []string {
+	var quarantined []string
+	for _, nodeID := range nodes {
+		if quarantinable, ok := connections[nodeID].(controller.Quarantinable); ok && quarantinable.IsQuarantined(ctx) {
+			quarantined = append(quarantined, nodeID)
+		}
+	}
+	return quarantined
+}
+
+// connectionPods returns the pod serving each of the listed connections, by
+// node id
+func connectionPods(connections map[string]controller.Receptor, nodes []string) map[string]string {
+	pods := make(map[string]string)
+	for _, nodeID := range nodes {
+		if pod := connectionPod(connections[nodeID]); pod != "" {
+			pods[nodeID] = pod
+		}
+	}
+	return pods
+}
+
+// connectionPod returns the pod serving the connection, or an empty string if
+// it is not known
+func connectionPod(client controller.Receptor) string {
+	if reporter, ok := client.(controller.PodReporter); ok {
+		return reporter.GetPod()
+	}
+	return ""
+}
+
+func matchesConnectionFilter(ctx context.Context, client controller.Receptor, filter connectionFilter) bool {
+	return matchesLabels(ctx, client, filter.labels) &&
+		matchesConnectedTime(ctx, client, filter) &&
+		matchesHealth(ctx, client, filter.health) &&
+		matchesPod(client, filter.pod)
+}
+
+func matchesLabels(ctx context.Context, client controller.Receptor, selector map[string]string) bool {
+	if len(selector) == 0 {
+		return true
+	}
+
+	labeler, ok := client.(controller.ConnectionLabeler)
+	if !ok {
+		return false
+	}
+
+	labels, err := labeler.GetLabels(ctx)
+	if err != nil {
+		return false
+	}
+
+	for name, value := range selector {
+		if labels[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesConnectedTime(ctx context.Context, client controller.Receptor, filter connectionFilter) bool {
+	if filter.connectedBefore.IsZero() && filter.connectedAfter.IsZero() {
+		return true
+	}
+
+	detailer, ok := client.(controller.ConnectionDetailer)
+	if !ok {
+		return false
+	}
+
+	connectedAt, err := detailer.GetConnectedAt(ctx)
+	if err != nil {
+		return false
+	}
+
+	if !filter.connectedBefore.IsZero() && !connectedAt.Before(filter.connectedBefore) {
+		return false
+	}
+
+	if !filter.connectedAfter.IsZero() && !connectedAt.After(filter.connectedAfter) {
+		return false
+	}
+
+	return true
+}
+
+func matchesHealth(ctx context.Context, client controller.Receptor, health string) bool {
+	if health == "" {
+		return true
+	}
+
+	actual, err := client.GetHealth(ctx)
+	if err != nil {
+		return false
+	}
+
+	return actual == health
+}
+
+// matchesPod matches the connections owned by the pod.  The connections of the
+// local connection manager are all owned by this pod, while the owner of the
+// connections located with redis is the one stored in redis.
+func matchesPod(client controller.Receptor, pod string) bool {
+	if pod == "" {
+		return true
+	}
+
+	return connectionPod(client) == pod
+}
+
+// writeConnectionListingByAccountPrefix lists the connections of a page of
+// the accounts matching the prefix.  The filter is applied to the connections
+// of the accounts on the page; the accounts without a matching connection are
+// left out of the page.
+func (s *ManagementServer) writeConnectionListingByAccountPrefix(w http.ResponseWriter, req *http.Request, logger *logrus.Entry, accountPrefix string, filter connectionFilter) {
+
+	type ConnectionsPerAccount struct {
+		AccountNumber string            `json:"account"`
+		Connections   []string          `json:"connections"`
+		Quarantined   []string          `json:"quarantined,omitempty"`
+		Pods          map[string]string `json:"pods,omitempty"`
+	}
+
+	type Meta struct {
+		Count  int `json:"count"`
+		Limit  int `json:"limit"`
+		Offset int `json:"offset"`
+	}
+
+	type Response struct {
+		Connections []ConnectionsPerAccount `json:"connections"`
+		Meta        Meta                    `json:"meta"`
+	}
+
+	limit, err := getQueryParamInt(req, "limit", defaultPrefixListingLimit)
+	if err != nil || limit < 1 || limit > maxPrefixListingLimit {
+		errorResponse := errorResponse{Title: "Invalid limit",
+			Status: http.StatusBadRequest,
+			Detail: fmt.Sprintf("limit must be between 1 and %d", maxPrefixListingLimit)}
+		writeJSONResponse(w, errorResponse.Status, errorResponse)
+		return
+	}
+
+	offset, err := getQueryParamInt(req, "offset", 0)
+	if err != nil || offset < 0 {
+		errorResponse := errorResponse{Title: "Invalid offset",
+			Status: http.StatusBadRequest,
+			Detail: "offset must be greater than or equal to 0"}
+		writeJSONResponse(w, errorResponse.Status, errorResponse)
+		return
+	}
+
+	logger.Debugf("Getting connections for accounts starting with %s (offset:%d, limit:%d)", accountPrefix, offset, limit)
+
+	prefixConnections, count := s.connectionMgr.GetConnectionsByAccountPrefix(accountPrefix, offset, limit)
+
+	accounts := make([]string, 0, len(prefixConnections))
+	for account := range prefixConnections {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+
+	connections := make([]ConnectionsPerAccount, 0, len(accounts))
+	for _, account := range accounts {
+		nodes := filterConnections(req.Context(), prefixConnections[account], filter)
+		if !filter.isEmpty() && len(nodes) == 0 {
+			continue
+		}
+
+		connections = append(connections, ConnectionsPerAccount{AccountNumber: account, Connections: nodes,
+			Quarantined: quarantinedConnections(req.Context(), prefixConnections[account], nodes),
+			Pods:        connectionPods(prefixConnections[account], nodes)})
+	}
+
+	response := Response{
+		Connections: connections,
+		Meta:        Meta{Count: count, Limit: limit, Offset: offset},
+	}
+
+	writeJSONResponse(w, http.StatusOK, response)
+}
+
+func (s *ManagementServer) handleRoutingTableByAccount() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		principal, _ := middlewares.GetPrincipal(req.Context())
+		requestId := request_id.GetReqID(req.Context())
+		accountId := mux.Vars(req)["id"]
+		logger := logger.Log.WithFields(logrus.Fields{
+			"account":    principal.GetAccount(),
+			"request_id": requestId})
+
+		if requestCancelled(w, req, logger) {
+			return
+		}
+
+		format := req.URL.Query().Get("format")
+		if format == "" {
+			format = routingFormatJSON
+		}
+
+		if format != routingFormatJSON && format != routingFormatDOT && format != routingFormatGraph {
+			errorResponse := errorResponse{Title: "Invalid format",
+				Status: http.StatusBadRequest,
+				Detail: fmt.Sprintf("format must be one of %s, %s or %s", routingFormatJSON, routingFormatDOT, routingFormatGraph)}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		logger.Debug("Getting routing tables for ", accountId)
+
+		accountConnections := s.connectionMgr.GetConnectionsByAccount(accountId)
+
+		response := routingTableResponse{
+			Account:       accountId,
+			RoutingTables: make(map[string]*controller.RoutingTable, len(accountConnections)),
+		}
+
+		for nodeID, client := range accountConnections {
+			routingTable, err := client.GetRoutingTable(req.Context())
+			if err != nil {
+				logger.WithFields(
+					logrus.Fields{"error": err},
+				).Errorf("Unable to retrieve the routing table of node %s", nodeID)
+				continue
+			}
+			response.RoutingTables[nodeID] = routingTable
+		}
+
+		if format != routingFormatJSON {
+			writeRoutingGraphResponse(w, format, newRoutingGraph(accountId, response.RoutingTables))
+			return
+		}
+
+		writeJSONResponse(w, http.StatusOK, response)
+	}
+}
+
+func (s *ManagementServer) handleConnectionImport() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		principal, _ := middlewares.GetPrincipal(req.Context())
+		requestId := request_id.GetReqID(req.Context())
+		logger := logger.Log.WithFields(logrus.Fields{
+			"account":    principal.GetAccount(),
+			"request_id": requestId})
+
+		var importRequest connectionImportRequest
+
+		if decodeJSONBatch(w, req, &importRequest) == false {
+			return
+		}
+
+		if requestCancelled(w, req, logger) {
+			return
+		}
+
+		importer, ok := s.connectionMgr.(controller.ConnectionImporter)
+		if !ok {
+			writeUnsupportedBackendResponse(w)
+			return
+		}
+
+		connections := make([]controller.ImportedConnection, len(importRequest.Connections))
+		for i, conn := range importRequest.Connections {
+			connections[i] = controller.ImportedConnection{Account: conn.Account, NodeID: conn.NodeID, Pod: conn.Pod}
+		}
+
+		logger.Infof("Importing %d connections", len(connections))
+
+		imported, err := importer.ImportConnections(connections)
+		if _, ok := err.(controller.UnsupportedBackendError); ok {
+			writeUnsupportedBackendResponse(w)
+			return
+		} else if err != nil {
+			errorResponse := errorResponse{Title: "Unable to import connections",
+				Status: http.StatusInternalServerError,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		logger.Infof("Imported %d of %d connections", imported, len(connections))
+
+		response := connectionImportResponse{Imported: imported, Skipped: len(connections) - imported}
+
+		writeJSONResponse(w, http.StatusOK, response)
+	}
+}
+
+func (s *ManagementServer) handleCapabilitiesRefresh() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		principal, _ := middlewares.GetPrincipal(req.Context())
+		requestId := request_id.GetReqID(req.Context())
+		logger := logger.Log.WithFields(logrus.Fields{
+			"account":    principal.GetAccount(),
+			"request_id": requestId})
+
+		account := req.URL.Query().Get("account")
+		if account != "" && !accountRegexp.MatchString(account) {
+			errorResponse := errorResponse{Title: "Invalid account",
+				Status: http.StatusBadRequest,
+				Detail: "the account must be numeric"}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		if requestCancelled(w, req, logger) {
+			return
+		}
+
+		var connections map[string]map[string]controller.Receptor
+		if account != "" {
+			connections = map[string]map[string]controller.Receptor{account: s.connectionMgr.GetConnectionsByAccount(account)}
+		} else {
+			connections = s.connectionMgr.GetAllConnections()
+		}
+
+		logger.WithFields(logrus.Fields{"audit": true, "refresh_account": account}).Info("Refreshing the capabilities of the connections")
+
+		response := capabilitiesRefreshResponse{}
+		var responseLock sync.Mutex
+
+		// The refreshes are bounded and spread out over time so that the nodes
+		// are not all asked for their capabilities at once
+		concurrency := make(chan struct{}, s.capabilitiesRefreshConcurrency())
+
+		var throttle <-chan time.Time
+		if s.config.CapabilitiesRefreshRate > 0 {
+			ticker := time.NewTicker(time.Second / time.Duration(s.config.CapabilitiesRefreshRate))
+			defer ticker.Stop()
+			throttle = ticker.C
+		}
+
+		var wg sync.WaitGroup
+
+	refresh:
+		for connAccount, nodes := range connections {
+			for nodeID, client := range nodes {
+				refresher, ok := client.(controller.CapabilitiesRefresher)
+				if !ok {
+					response.Skipped++
+					continue
+				}
+
+				if throttle != nil {
+					select {
+					case <-throttle:
+					case <-req.Context().Done():
+						break refresh
+					}
+				}
+
+				wg.Add(1)
+				concurrency <- struct{}{}
+				go func(connID connectionID, refresher controller.CapabilitiesRefresher) {
+					defer func() {
+						<-concurrency
+						wg.Done()
+					}()
+
+					err := refresher.RefreshCapabilities(req.Context())
+
+					responseLock.Lock()
+					defer responseLock.Unlock()
+
+					if err != nil {
+						logger.WithFields(logrus.Fields{"error": err}).Infof("Unable to refresh the capabilities for account:%s - node id:%s",
+							connID.Account, connID.NodeID)
+						response.Failed++
+						response.Errors = append(response.Errors, capabilitiesRefreshError{connectionID: connID, Error: err.Error()})
+						return
+					}
+
+					response.Refreshed++
+				}(connectionID{Account: connAccount, NodeID: nodeID}, refresher)
+			}
+		}
+		wg.Wait()
+
+		if requestCancelled(w, req, logger) {
+			return
+		}
+
+		logger.Infof("Refreshed the capabilities of %d nodes (%d failed, %d skipped)", response.Refreshed, response.Failed, response.Skipped)
+
+		status := http.StatusOK
+		if response.Failed > 0 {
+			status = http.StatusMultiStatus
+		}
+
+		writeJSONResponse(w, status, response)
+	}
+}
+
+func (s *ManagementServer) handleConnectionReap() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		principal, _ := middlewares.GetPrincipal(req.Context())
+		requestId := request_id.GetReqID(req.Context())
+		logger := logger.Log.WithFields(logrus.Fields{
+			"account":    principal.GetAccount(),
+			"request_id": requestId})
+
+		idleSeconds, err := getQueryParamInt(req, "idle_seconds", 0)
+		if err != nil || idleSeconds <= 0 {
+			errorResponse := errorResponse{Title: "Invalid idle_seconds",
+				Status: http.StatusBadRequest,
+				Detail: "idle_seconds must be a positive number of seconds"}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		if requestCancelled(w, req, logger) {
+			return
+		}
+
+		logger.WithFields(logrus.Fields{"audit": true, "client_id": middlewares.GetClientID(req.Context()), "idle_seconds": idleSeconds}).Info("Reaping idle connections")
+
+		now := time.Now()
+		idleThreshold := time.Duration(idleSeconds) * time.Second
+
+		response := connectionReapResponse{Connections: []connectionReapResult{}}
+		var responseLock sync.Mutex
+
+		concurrency := make(chan struct{}, s.connectionReapConcurrency())
+
+		var wg sync.WaitGroup
+
+		for account, nodes := range s.connectionMgr.GetAllConnections() {
+			for nodeID, client := range nodes {
+				reporter, ok := client.(controller.ActivityReporter)
+				if !ok {
+					continue
+				}
+
+				lastActivity, err := reporter.GetLastActivity(req.Context())
+				if err != nil || lastActivity.IsZero() || now.Sub(lastActivity) < idleThreshold {
+					continue
+				}
+
+				wg.Add(1)
+				concurrency <- struct{}{}
+				go func(result connectionReapResult, client controller.Receptor) {
+					defer func() {
+						<-concurrency
+						wg.Done()
+					}()
+
+					err := client.Close(controller.WithCloseReason(req.Context(), controller.CLOSE_REASON_ADMIN_DISCONNECT))
+
+					responseLock.Lock()
+					defer responseLock.Unlock()
+
+					if err != nil {
+						logger.WithFields(logrus.Fields{"error": err}).Infof("Unable to reap account:%s - node id:%s",
+							result.Account, result.NodeID)
+						result.Error = err.Error()
+						response.Failed++
+					} else {
+						logger.Infof("Reaped account:%s - node id:%s (idle for %d seconds)",
+							result.Account, result.NodeID, result.IdleSeconds)
+						response.Reaped++
+					}
+
+					response.Connections = append(response.Connections, result)
+				}(connectionReapResult{
+					connectionID: connectionID{Account: account, NodeID: nodeID},
+					IdleSeconds:  int(now.Sub(lastActivity).Seconds()),
+				}, client)
+			}
+		}
+		wg.Wait()
+
+		logger.Infof("Reaped %d idle connections (%d failed)", response.Reaped, response.Failed)
+
+		status := http.StatusOK
+		if response.Failed > 0 {
+			status = http.StatusMultiStatus
+		}
+
+		writeJSONResponse(w, status, response)
+	}
+}
+
+func (s *ManagementServer) connectionReapConcurrency() int {
+	if s.config.ConnectionReapConcurrency > 0 {
+		return s.config.ConnectionReapConcurrency
+	}
+	return 1
+}
+
+func (s *ManagementServer) handleConfig() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		principal, _ := middlewares.GetPrincipal(req.Context())
+		requestId := request_id.GetReqID(req.Context())
+		logger := logger.Log.WithFields(logrus.Fields{
+			"account":    principal.GetAccount(),
+			"request_id": requestId})
+
+		logger.WithFields(logrus.Fields{"audit": true, "client_id": middlewares.GetClientID(req.Context())}).Info("Reporting the effective config")
+
+		writeJSONResponse(w, http.StatusOK, s.config.Redacted())
+	}
+}
+
+func (s *ManagementServer) capabilitiesRefreshConcurrency() int {
+	if s.config.CapabilitiesRefreshConcurrency > 0 {
+		return s.config.CapabilitiesRefreshConcurrency
+	}
+	return 1
+}
+
+func (s *ManagementServer) handleConnectionPause() http.HandlerFunc {
+	return s.handleConnectionPauseChange(true)
+}
+
+func (s *ManagementServer) handleConnectionResume() http.HandlerFunc {
+	return s.handleConnectionPauseChange(false)
+}
+
+func (s *ManagementServer) handleConnectionPauseChange(pause bool) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		principal, _ := middlewares.GetPrincipal(req.Context())
+		requestId := request_id.GetReqID(req.Context())
+		logger := logger.Log.WithFields(logrus.Fields{
+			"account":    principal.GetAccount(),
+			"request_id": requestId})
+
+		params := mux.Vars(req)
+		account := params["account"]
+		nodeID := params["node_id"]
+
+		if requestCancelled(w, req, logger) {
+			return
+		}
+
+		client := s.connectionMgr.GetConnection(account, nodeID)
+		if client == nil {
+			errMsg := fmt.Sprintf("No connection found for node (%s:%s)", account, nodeID)
+			logger.Info(errMsg)
+			errorResponse := errorResponse{Title: errMsg,
+				Status: http.StatusNotFound,
+				Detail: errMsg}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		pausable, ok := client.(controller.Pausable)
+		if !ok {
+			errorResponse := errorResponse{Title: "Pausing message delivery is unsupported for this backend",
+				Status: http.StatusNotImplemented,
+				Detail: controller.UnsupportedBackendError{}.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		var err error
+		if pause {
+			logger.Infof("Pausing message delivery for account:%s - node id:%s", account, nodeID)
+			err = pausable.Pause(req.Context())
+		} else {
+			logger.Infof("Resuming message delivery for account:%s - node id:%s", account, nodeID)
+			err = pausable.Resume(req.Context())
+		}
+
+		if err != nil {
+			logger.WithFields(logrus.Fields{"error": err}).Info("Unable to change the message delivery state")
+			errorResponse := errorResponse{Title: "Unable to change the message delivery state",
+				Status: http.StatusInternalServerError,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		writeJSONResponse(w, http.StatusOK, connectionPauseResponse{Paused: pausable.IsPaused(req.Context())})
+	}
+}
+
+func (s *ManagementServer) handleConnectionQuarantine() http.HandlerFunc {
+	return s.handleConnectionQuarantineChange(true)
+}
+
+func (s *ManagementServer) handleConnectionUnquarantine() http.HandlerFunc {
+	return s.handleConnectionQuarantineChange(false)
+}
+
+// handleConnectionQuarantineChange quarantines (or unquarantines) a
+// connection.  A quarantined connection stays open but the messages sent to
+// the node are refused.
+func (s *ManagementServer) handleConnectionQuarantineChange(quarantine bool) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		principal, _ := middlewares.GetPrincipal(req.Context())
+		requestId := request_id.GetReqID(req.Context())
+		logger := logger.Log.WithFields(logrus.Fields{
+			"account":    principal.GetAccount(),
+			"request_id": requestId})
+
+		params := mux.Vars(req)
+		account := params["account"]
+		nodeID := params["node_id"]
+
+		if requestCancelled(w, req, logger) {
+			return
+		}
+
+		client := s.connectionMgr.GetConnection(account, nodeID)
+		if client == nil {
+			errMsg := fmt.Sprintf("No connection found for node (%s:%s)", account, nodeID)
+			logger.Info(errMsg)
+			errorResponse := errorResponse{Title: errMsg,
+				Status: http.StatusNotFound,
+				Detail: errMsg}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		quarantinable, ok := client.(controller.Quarantinable)
+		if !ok {
+			errorResponse := errorResponse{Title: "Quarantining connections is unsupported for this backend",
+				Status: http.StatusNotImplemented,
+				Detail: controller.UnsupportedBackendError{}.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		var err error
+		if quarantine {
+			logger.WithFields(logrus.Fields{"audit": true}).Infof("Quarantining the connection for account:%s - node id:%s", account, nodeID)
+			err = quarantinable.Quarantine(req.Context())
+		} else {
+			logger.WithFields(logrus.Fields{"audit": true}).Infof("Lifting the quarantine of the connection for account:%s - node id:%s", account, nodeID)
+			err = quarantinable.Unquarantine(req.Context())
+		}
+
+		if err != nil {
+			logger.WithFields(logrus.Fields{"error": err}).Info("Unable to change the quarantine state")
+			errorResponse := errorResponse{Title: "Unable to change the quarantine state",
+				Status: http.StatusInternalServerError,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		atomic.AddUint64(&s.quarantineGeneration, 1)
+
+		writeJSONResponse(w, http.StatusOK, connectionQuarantineResponse{Quarantined: quarantinable.IsQuarantined(req.Context())})
+	}
+}
+
+type connectionHistoryResponse struct {
+	Account string                                 `json:"account"`
+	NodeID  string                                 `json:"node_id"`
+	History []controller.ConnectionOwnershipChange `json:"history"`
+}
+
+func (s *ManagementServer) handleConnectionHistory() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		principal, _ := middlewares.GetPrincipal(req.Context())
+		requestId := request_id.GetReqID(req.Context())
+		logger := logger.Log.WithFields(logrus.Fields{
+			"account":    principal.GetAccount(),
+			"request_id": requestId})
+
+		params := mux.Vars(req)
+		connID := connectionID{Account: params["account"], NodeID: params["node_id"]}
+
+		if requestCancelled(w, req, logger) {
+			return
+		}
+
+		reporter, ok := s.connectionMgr.(controller.ConnectionHistoryReporter)
+		if !ok {
+			errMsg := "Connection history is unsupported for this backend"
+			errorResponse := errorResponse{Title: errMsg,
+				Status: http.StatusNotImplemented,
+				Detail: controller.UnsupportedBackendError{}.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		logger.Infof("Getting connection history for account:%s - node id:%s",
+			connID.Account, connID.NodeID)
+
+		history, err := reporter.GetConnectionHistory(connID.Account, connID.NodeID)
+		if err != nil {
+			logger.WithFields(logrus.Fields{"error": err}).Error("Unable to retrieve the connection history")
+			errorResponse := errorResponse{Title: "Unable to retrieve the connection history",
+				Status: http.StatusInternalServerError,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		response := connectionHistoryResponse{Account: connID.Account, NodeID: connID.NodeID, History: history}
+
+		writeJSONResponse(w, http.StatusOK, response)
+	}
+}
+
+func writeUnsupportedBackendResponse(w http.ResponseWriter) {
+	err := controller.UnsupportedBackendError{}
+	errorResponse := errorResponse{Title: "Connection import is unsupported for this backend",
+		Status: http.StatusNotImplemented,
+		Detail: err.Error()}
+	writeJSONResponse(w, errorResponse.Status, errorResponse)
+}
+
+func (s *ManagementServer) handleConnectionEvents() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		principal, _ := middlewares.GetPrincipal(req.Context())
+		requestId := request_id.GetReqID(req.Context())
+		logger := logger.Log.WithFields(logrus.Fields{
+			"account":    principal.GetAccount(),
+			"request_id": requestId})
+
+		if s.connectionEvents == nil {
+			errMsg := "Connection events are unavailable"
+			errorResponse := errorResponse{Title: errMsg,
+				Status: http.StatusNotImplemented,
+				Detail: controller.UnsupportedBackendError{}.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			errMsg := "Streaming is unsupported"
+			errorResponse := errorResponse{Title: errMsg,
+				Status: http.StatusInternalServerError,
+				Detail: errMsg}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		account := req.URL.Query().Get("account")
+
+		logger = logger.WithFields(logrus.Fields{"filter_account": account})
+		logger.Info("Subscribing to connection events")
+
+		subscription := s.connectionEvents.Subscribe(account)
+		defer s.connectionEvents.Unsubscribe(subscription)
+
+		// The stream stays open until the subscriber goes away
+		middlewares.ReleaseConcurrencySlot(req.Context())
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		keepalive := time.NewTicker(connectionEventsKeepaliveInterval)
+		defer keepalive.Stop()
+
+		for {
+			select {
+			case <-req.Context().Done():
+				logger.Info("Connection events subscriber disconnected")
+				return
+			case <-keepalive.C:
+				fmt.Fprint(w, ": keepalive\n\n")
+				flusher.Flush()
+			case event, ok := <-subscription.Events:
+				if !ok {
+					logger.Warn("Connection events subscriber fell behind and was dropped")
+					return
+				}
+
+				data, err := marshalJSON(w, event)
+				if err != nil {
+					logger.WithFields(logrus.Fields{"error": err}).Error("Unable to encode the connection event")
+					continue
+				}
+
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// handleConnectionWait blocks until the connection reaches the requested state
+// (connected or disconnected) or the timeout elapses.  The state of the
+// connection is tracked using the connection events published by this pod.
+func (s *ManagementServer) handleConnectionWait() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		principal, _ := middlewares.GetPrincipal(req.Context())
+		requestId := request_id.GetReqID(req.Context())
+		logger := logger.Log.WithFields(logrus.Fields{
+			"account":    principal.GetAccount(),
+			"request_id": requestId})
+
+		params := mux.Vars(req)
+		connID := connectionID{Account: params["account"], NodeID: params["node_id"]}
+
+		if s.connectionEvents == nil {
+			errMsg := "Connection events are unavailable"
+			errorResponse := errorResponse{Title: errMsg,
+				Status: http.StatusNotImplemented,
+				Detail: controller.UnsupportedBackendError{}.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		target := req.URL.Query().Get("for")
+		if target == "" {
+			target = CONNECTED_STATUS
+		}
+
+		if target != CONNECTED_STATUS && target != DISCONNECTED_STATUS {
+			errorResponse := errorResponse{Title: "Invalid state",
+				Status: http.StatusBadRequest,
+				Detail: fmt.Sprintf("for must be either %s or %s", CONNECTED_STATUS, DISCONNECTED_STATUS)}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		timeout := s.config.ConnectionWaitMaxTimeout
+		if value := req.URL.Query().Get("timeout"); value != "" {
+			requested, err := time.ParseDuration(value)
+			if err != nil || requested < 0 {
+				errorResponse := errorResponse{Title: "Invalid timeout",
+					Status: http.StatusBadRequest,
+					Detail: "timeout must be a non-negative duration (e.g. 30s)"}
+				writeJSONResponse(w, errorResponse.Status, errorResponse)
+				return
+			}
+
+			if requested < timeout {
+				timeout = requested
+			}
+		}
+
+		if requestCancelled(w, req, logger) {
+			return
+		}
+
+		logger.Infof("Waiting up to %s for node (%s:%s) to be %s", timeout, connID.Account, connID.NodeID, target)
+
+		// Waiting does not count against the cap on concurrent requests
+		middlewares.ReleaseConcurrencySlot(req.Context())
+
+		// Subscribe before checking the current state so that a change of state
+		// cannot be missed
+		subscription := s.connectionEvents.Subscribe(connID.Account)
+		defer s.connectionEvents.Unsubscribe(subscription)
+
+		currentStatus := func() string {
+			if s.connectionMgr.GetConnection(connID.Account, connID.NodeID) != nil {
+				return CONNECTED_STATUS
+			}
+			return DISCONNECTED_STATUS
+		}
+
+		respond := func(status string) {
+			writeJSONResponse(w, http.StatusOK, connectionWaitResponse{
+				Account: connID.Account,
+				NodeID:  connID.NodeID,
+				Status:  status,
+				Reached: status == target,
+			})
+		}
+
+		if status := currentStatus(); status == target {
+			respond(status)
+			return
+		}
+
+		targetEvent := controller.CONNECTION_EVENT_CONNECTED
+		if target == DISCONNECTED_STATUS {
+			targetEvent = controller.CONNECTION_EVENT_DISCONNECTED
+		}
+
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-req.Context().Done():
+				logger.Info("Client disconnected while waiting for the connection")
+				return
+			case <-timer.C:
+				logger.Info("Timed out waiting for the connection")
+				respond(currentStatus())
+				return
+			case event, ok := <-subscription.Events:
+				if !ok {
+					logger.Warn("Connection wait fell behind the connection events")
+					respond(currentStatus())
+					return
+				}
+
+				if event.NodeID == connID.NodeID && event.Type == targetEvent {
+					respond(target)
+					return
+				}
+			}
+		}
+	}
+}
+
+// handleRecentConnectionEvents returns the retained connection events that
+// were published after the since cursor, oldest first.  It lets the
+// subscribers that missed events on the stream catch up.
+func (s *ManagementServer) handleRecentConnectionEvents() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		principal, _ := middlewares.GetPrincipal(req.Context())
+		requestId := request_id.GetReqID(req.Context())
+		logger := logger.Log.WithFields(logrus.Fields{
+			"account":    principal.GetAccount(),
+			"request_id": requestId})
+
+		if s.connectionEvents == nil {
+			errMsg := "Connection events are unavailable"
+			errorResponse := errorResponse{Title: errMsg,
+				Status: http.StatusNotImplemented,
+				Detail: controller.UnsupportedBackendError{}.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		since, ok := parseConnectedTime(w, req, "since")
+		if !ok {
+			return
+		}
+
+		limit, err := getQueryParamInt(req, "limit", defaultRecentEventsLimit)
+		if err != nil || limit < 1 || limit > maxRecentEventsLimit {
+			errorResponse := errorResponse{Title: "Invalid limit",
+				Status: http.StatusBadRequest,
+				Detail: fmt.Sprintf("limit must be between 1 and %d", maxRecentEventsLimit)}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		account := req.URL.Query().Get("account")
+
+		logger.WithFields(logrus.Fields{"filter_account": account}).Debugf("Getting the connection events since %s (limit:%d)", since, limit)
+
+		response := recentConnectionEventsResponse{Events: s.connectionEvents.RecentEvents(account, since, limit)}
 
 		writeJSONResponse(w, http.StatusOK, response)
 	}
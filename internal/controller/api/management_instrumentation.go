@@ -0,0 +1,34 @@
+package api
+
+import (
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/openmetrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	managementMetrics = newManagementMetrics()
+)
+
+type managementServerMetrics struct {
+	pingLatency      *openmetrics.HistogramVec
+	pingRetryCounter prometheus.Counter
+}
+
+func newManagementMetrics() *managementServerMetrics {
+	metrics := new(managementServerMetrics)
+
+	metrics.pingLatency = openmetrics.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "receptor_controller_management_ping_latency_seconds",
+		Help: "Number of seconds spent waiting on a ping submitted through the management api",
+	},
+		[]string{"account"},
+	)
+
+	metrics.pingRetryCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "receptor_controller_management_ping_retry_count",
+		Help: "The number of pings that were retried because the connection was replaced during the ping",
+	})
+
+	return metrics
+}
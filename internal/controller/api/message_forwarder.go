@@ -0,0 +1,221 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/controller"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/middlewares"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+	"github.com/redhatinsights/platform-go-middlewares/request_id"
+
+	"github.com/go-redis/redis"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const forwardOwnerPollInterval = 250 * time.Millisecond
+
+var (
+	errNodeNotConnected   = errors.New("node is not connected to the pod")
+	errNodeNotReconnected = errors.New("node did not reconnect to another pod")
+)
+
+type forwardedMessageRequest struct {
+	Account   string      `json:"account" validate:"required,account"`
+	NodeID    string      `json:"node_id" validate:"required"`
+	MessageID uuid.UUID   `json:"message_id" validate:"required"`
+	Recipient string      `json:"recipient" validate:"required"`
+	Route     []string    `json:"route"`
+	Payload   interface{} `json:"payload" validate:"required"`
+	Directive string      `json:"directive" validate:"required"`
+	ExpiresAt *time.Time  `json:"expires_at,omitempty"`
+}
+
+// PodMessageForwarder forwards the messages queued for a node that lost its
+// connection to this pod to the pod that the node reconnects to.  The owner of
+// the connection is looked up in Redis.
+type PodMessageForwarder struct {
+	Client   *redis.Client
+	Hostname string
+	Cfg      *config.Config
+}
+
+// ForwardMessage waits for the node to reconnect to another pod and forwards
+// the message to it.  It gives up once ctx is done.
+func (f *PodMessageForwarder) ForwardMessage(ctx context.Context, account string, nodeID string, message controller.Message) error {
+	if message.RequestID != "" {
+		ctx = context.WithValue(ctx, request_id.RequestIDKey, message.RequestID)
+	}
+	if message.TraceContext.TraceParent != "" {
+		ctx = controller.WithTraceContext(ctx, message.TraceContext)
+	}
+
+	ticker := time.NewTicker(forwardOwnerPollInterval)
+	defer ticker.Stop()
+
+	for {
+		podName, _ := controller.GetRedisConnection(f.Client, account, nodeID)
+		if podName != "" && podName != f.Hostname {
+			proxy := &ReceptorHttpProxy{
+				Hostname:      podName,
+				AccountNumber: account,
+				NodeID:        nodeID,
+				Config:        f.Cfg,
+			}
+
+			// The node is registered with Redis before it is registered
+			// with the pod's local connection manager
+			err := proxy.ForwardMessage(ctx, message)
+			if err != errNodeNotConnected {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return errNodeNotReconnected
+		case <-ticker.C:
+		}
+	}
+}
+
+// ForwardMessage passes a message queued on another pod to the pod that the
+// node is connected to
+func (rhp *ReceptorHttpProxy) ForwardMessage(ctx context.Context, message controller.Message) error {
+
+	probe := createProbe(ctx)
+
+	probe.forwardingMessage(rhp.AccountNumber, rhp.NodeID, message.MessageID)
+
+	postPayload := forwardedMessageRequest{
+		Account:   rhp.AccountNumber,
+		NodeID:    rhp.NodeID,
+		MessageID: message.MessageID,
+		Recipient: message.Recipient,
+		Route:     message.RouteList,
+		Payload:   message.Payload,
+		Directive: message.Directive,
+	}
+	if !message.ExpiresAt.IsZero() {
+		postPayload.ExpiresAt = &message.ExpiresAt
+	}
+
+	jsonStr, err := json.Marshal(postPayload)
+	if err != nil {
+		probe.failedToForwardMessage("Unable to forward message.  Failed to marshal JSON payload.", err)
+		return errUnableToSendMessage
+	}
+
+	resp, err := makeHttpRequest(
+		ctx,
+		http.MethodPost,
+		rhp.generateUrl("message/forward"),
+		rhp.AccountNumber,
+		rhp.Config,
+		bytes.NewBuffer(jsonStr),
+	)
+
+	if err != nil {
+		probe.failedToForwardMessage("Unable to forward message.  Failed to create HTTP Request.", err)
+		return errUnableToSendMessage
+	}
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		return nil
+	case http.StatusNotFound:
+		return errNodeNotConnected
+	default:
+		probe.failedToForwardMessage("Unable to forward message.  Unexpected response from receptor-gateway.",
+			errors.New(resp.Status))
+		return errUnableToSendMessage
+	}
+}
+
+func (jr *JobReceiver) handleForwardedMessage() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		principal, _ := middlewares.GetPrincipal(req.Context())
+		requestId := request_id.GetReqID(req.Context())
+		logger := logger.Log.WithFields(logrus.Fields{
+			"account":    principal.GetAccount(),
+			"request_id": requestId})
+
+		var forwardedMessage forwardedMessageRequest
+
+		body := http.MaxBytesReader(w, req.Body, 1048576)
+
+		if err := decodeJSON(req.Context(), body, &forwardedMessage); err != nil {
+			errMsg := "Unable to process json input"
+			logger.WithFields(logrus.Fields{"error": err}).Debug(errMsg)
+			errorResponse := errorResponse{Title: errMsg,
+				Status: http.StatusBadRequest,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		logger = logger.WithFields(logrus.Fields{"node_id": forwardedMessage.NodeID,
+			"message_id": forwardedMessage.MessageID})
+
+		client := jr.connectionMgr.GetConnection(forwardedMessage.Account, forwardedMessage.NodeID)
+		if client == nil {
+			errMsg := "No connection to the receptor node"
+			logger.Info(errMsg)
+			errorResponse := errorResponse{Title: errMsg,
+				Status: http.StatusNotFound,
+				Detail: errMsg}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		receiver, ok := client.(controller.ForwardedMessageReceiver)
+		if !ok {
+			errMsg := "Forwarded messages can only be received by the pod the node is connected to"
+			errorResponse := errorResponse{Title: errMsg,
+				Status: http.StatusNotImplemented,
+				Detail: controller.UnsupportedBackendError{}.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		message := controller.Message{
+			MessageID: forwardedMessage.MessageID,
+			Recipient: forwardedMessage.Recipient,
+			RouteList: forwardedMessage.Route,
+			Payload:   controller.DecodePayload(forwardedMessage.Payload),
+			Directive: forwardedMessage.Directive,
+			RequestID: requestId,
+		}
+		if forwardedMessage.ExpiresAt != nil {
+			message.ExpiresAt = *forwardedMessage.ExpiresAt
+		}
+
+		ctx := withRequestTraceContext(req, logger)
+		if tc, exists := controller.GetTraceContext(ctx); exists {
+			message.TraceContext = tc
+		}
+
+		if err := receiver.ReceiveForwardedMessage(ctx, message); err != nil {
+			logger.WithFields(logrus.Fields{"error": err}).Info("Error passing forwarded message to receptor")
+			errorResponse := errorResponse{Title: "Error passing forwarded message to receptor",
+				Status: http.StatusInternalServerError,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		logger.Info("Forwarded message received")
+
+		writeJSONResponse(w, http.StatusCreated, jobResponse{forwardedMessage.MessageID.String()})
+	}
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/controller"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+
+	"github.com/alicebob/miniredis"
+	"github.com/gorilla/mux"
+)
+
+const (
+	forwardTestAccount = "01"
+	forwardTestNodeID  = "node-a"
+)
+
+func newForwardTestReceptor(cfg *config.Config, outbox controller.OutboxStore, forwarder controller.MessageForwarder, send chan controller.ReceptorMessage) *controller.ReceptorService {
+	factory := controller.NewReceptorServiceFactory(nil, outbox, cfg)
+	if forwarder != nil {
+		factory.SetMessageForwarder(forwarder)
+	}
+
+	receptor := factory.NewReceptorService(logger.Log.WithField("account", forwardTestAccount),
+		forwardTestAccount, "node-cloud-receptor-controller")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	receptor.RegisterConnection(forwardTestNodeID, nil, &controller.Transport{
+		Send:   send,
+		Ctx:    ctx,
+		Cancel: cancel,
+	})
+
+	return receptor
+}
+
+// TestForwardQueuedMessagesToSiblingPod simulates a node that loses its
+// connection to pod a and reconnects to pod b
+func TestForwardQueuedMessagesToSiblingPod(t *testing.T) {
+	s, _ := miniredis.Run()
+	defer s.Close()
+
+	client := newTestRedisClient(s.Addr())
+
+	cfg := config.GetConfig()
+	cfg.MessageForwardTimeout = 5 * time.Second
+	cfg.JobReceiverReceptorProxyScheme = "http"
+	cfg.JobReceiverReceptorProxyClientID = "test_client_1"
+	cfg.JobReceiverReceptorProxyPSK = "12345"
+	cfg.ServiceToServiceCredentials["test_client_1"] = "12345"
+
+	// Pod b
+	sentByPodB := make(chan controller.ReceptorMessage, 1)
+	connectionMgr := controller.NewLocalConnectionManager()
+	connectionMgr.Register(forwardTestAccount, forwardTestNodeID,
+		newForwardTestReceptor(cfg, controller.NewInMemoryOutboxStore(), nil, sentByPodB))
+
+	jr := NewJobReceiver(connectionMgr, controller.NewInMemoryOutboxStore(), mux.NewRouter(), cfg)
+	jr.Routes()
+
+	server := httptest.NewServer(jr.router)
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	cfg.JobReceiverReceptorProxyPort, _ = strconv.Atoi(serverURL.Port())
+
+	// Pod a.  Nothing reads from the send channel, the message stays queued.
+	outboxA := controller.NewInMemoryOutboxStore()
+	forwarder := &PodMessageForwarder{Client: client, Hostname: "pod-a", Cfg: cfg}
+	podA := newForwardTestReceptor(cfg, outboxA, forwarder, make(chan controller.ReceptorMessage, 1))
+	controller.RegisterWithRedis(client, forwardTestAccount, forwardTestNodeID, "pod-a")
+
+	messageID, err := podA.SendMessage(context.TODO(), forwardTestAccount, forwardTestNodeID,
+		[]string{forwardTestNodeID}, "payload", "fred:flintstone")
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	// The node reconnects to pod b a little after pod a noticed the lost connection
+	controller.UnregisterWithRedis(client, forwardTestAccount, forwardTestNodeID, "pod-a")
+	reconnected := make(chan struct{})
+	go func() {
+		defer close(reconnected)
+		time.Sleep(100 * time.Millisecond)
+		controller.RegisterWithRedis(client, forwardTestAccount, forwardTestNodeID, serverURL.Hostname())
+	}()
+	defer func() { <-reconnected }()
+
+	podA.Close(context.TODO())
+
+	select {
+	case msg := <-sentByPodB:
+		forwardedID := msg.Message.(*protocol.PayloadMessage).Data.MessageID
+		if forwardedID != messageID.String() {
+			t.Fatalf("Expected message %s to be forwarded, got %s", messageID, forwardedID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Expected the message to be forwarded to pod b")
+	}
+
+	entry, err := outboxA.Get(context.TODO(), *messageID)
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+	if entry.Status != controller.OUTBOX_FORWARDED_STATUS {
+		t.Fatalf("Expected the status to be %s, got %s", controller.OUTBOX_FORWARDED_STATUS, entry.Status)
+	}
+}
+
+func TestForwardMessageGivesUpWhenTheNodeDoesNotReconnect(t *testing.T) {
+	s, _ := miniredis.Run()
+	defer s.Close()
+
+	client := newTestRedisClient(s.Addr())
+	controller.RegisterWithRedis(client, forwardTestAccount, forwardTestNodeID, "pod-a")
+
+	forwarder := &PodMessageForwarder{Client: client, Hostname: "pod-a", Cfg: config.GetConfig()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*forwardOwnerPollInterval)
+	defer cancel()
+
+	err := forwarder.ForwardMessage(ctx, forwardTestAccount, forwardTestNodeID, controller.Message{})
+	if err != errNodeNotReconnected {
+		t.Fatalf("Expected %v, got %v", errNodeNotReconnected, err)
+	}
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"net/http"
+)
+
+// prettyJSONMiddleware asks writeJSONResponse to indent the json responses of
+// the requests with a pretty=true query parameter, or of all requests if
+// always is set.  It is meant for debugging with curl.
+type prettyJSONMiddleware struct {
+	always bool
+}
+
+func (pmw *prettyJSONMiddleware) IndentResponses(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if pmw.always || r.URL.Query().Get("pretty") == "true" {
+			w = &prettyJSONResponseWriter{ResponseWriter: w}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// prettyJSONResponseWriter marks a response whose json should be indented
+type prettyJSONResponseWriter struct {
+	http.ResponseWriter
+}
+
+// Flush lets streaming handlers (e.g. the connection events) flush through
+// the wrapper
+func (pw *prettyJSONResponseWriter) Flush() {
+	if flusher, ok := pw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
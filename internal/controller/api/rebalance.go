@@ -0,0 +1,148 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/controller"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/middlewares"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+	"github.com/redhatinsights/platform-go-middlewares/request_id"
+
+	"github.com/sirupsen/logrus"
+)
+
+// rebalanceRequest is limited to short pod names: the pod is sent to the node
+// in the text of the websocket close message, which is limited to 123 bytes
+type rebalanceRequest struct {
+	Account   string `json:"account" validate:"required,account"`
+	TargetPod string `json:"target_pod" validate:"required,max=64"`
+}
+
+type rebalanceResult struct {
+	connectionID
+	Error string `json:"error,omitempty"`
+}
+
+type rebalanceResponse struct {
+	Account     string            `json:"account"`
+	TargetPod   string            `json:"target_pod"`
+	Moved       int               `json:"moved"`
+	Failed      int               `json:"failed"`
+	Connections []rebalanceResult `json:"connections"`
+}
+
+func (s *ManagementServer) isKnownPod(pod string) bool {
+	if len(s.config.AffinityPods) == 0 {
+		return true
+	}
+
+	for _, p := range s.config.AffinityPods {
+		if p == pod {
+			return true
+		}
+	}
+
+	return false
+}
+
+// handleRebalance moves the connections of an account to another pod.  Each
+// connection is closed with a hint telling the node which pod to reconnect to.
+// Only the connections of this pod are moved.
+func (s *ManagementServer) handleRebalance() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		principal, _ := middlewares.GetPrincipal(req.Context())
+		requestId := request_id.GetReqID(req.Context())
+		logger := logger.Log.WithFields(logrus.Fields{
+			"account":    principal.GetAccount(),
+			"request_id": requestId})
+
+		body := http.MaxBytesReader(w, req.Body, 1048576)
+
+		var rebalanceReq rebalanceRequest
+
+		if err := decodeJSON(req.Context(), body, &rebalanceReq); err != nil {
+			errorResponse := errorResponse{Title: "Unable to process json input",
+				Status: http.StatusBadRequest,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		if !s.isKnownPod(rebalanceReq.TargetPod) {
+			errorResponse := errorResponse{Title: "Unknown target pod",
+				Status: http.StatusBadRequest,
+				Detail: fmt.Sprintf("%s is not one of the affinity pods", rebalanceReq.TargetPod)}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		if requestCancelled(w, req, logger) {
+			return
+		}
+
+		logger.WithFields(logrus.Fields{"audit": true, "client_id": middlewares.GetClientID(req.Context()),
+			"rebalanced_account": rebalanceReq.Account, "target_pod": rebalanceReq.TargetPod}).Info("Rebalancing the connections of an account")
+
+		ctx := controller.WithReconnectHint(controller.WithCloseReason(req.Context(), controller.CLOSE_REASON_REBALANCE), rebalanceReq.TargetPod)
+
+		response := rebalanceResponse{
+			Account:     rebalanceReq.Account,
+			TargetPod:   rebalanceReq.TargetPod,
+			Connections: []rebalanceResult{},
+		}
+		var responseLock sync.Mutex
+
+		// The connections are closed with the same concurrency as the idle
+		// connections are reaped with
+		concurrency := make(chan struct{}, s.connectionReapConcurrency())
+
+		var wg sync.WaitGroup
+
+		for nodeID, client := range s.connectionMgr.GetConnectionsByAccount(rebalanceReq.Account) {
+			wg.Add(1)
+			concurrency <- struct{}{}
+			go func(result rebalanceResult, client controller.Receptor) {
+				defer func() {
+					<-concurrency
+					wg.Done()
+				}()
+
+				err := client.Close(ctx)
+
+				responseLock.Lock()
+				defer responseLock.Unlock()
+
+				if err != nil {
+					logger.WithFields(logrus.Fields{"error": err}).Infof("Unable to move account:%s - node id:%s",
+						result.Account, result.NodeID)
+					result.Error = err.Error()
+					response.Failed++
+				} else {
+					response.Moved++
+				}
+
+				response.Connections = append(response.Connections, result)
+			}(rebalanceResult{connectionID: connectionID{Account: rebalanceReq.Account, NodeID: nodeID}}, client)
+		}
+		wg.Wait()
+
+		sort.Slice(response.Connections, func(i, j int) bool {
+			return response.Connections[i].NodeID < response.Connections[j].NodeID
+		})
+
+		logger.Infof("Moved %d connections of account %s to %s (%d failed)",
+			response.Moved, response.Account, response.TargetPod, response.Failed)
+
+		status := http.StatusOK
+		if response.Failed > 0 {
+			status = http.StatusMultiStatus
+		}
+
+		writeJSONResponse(w, status, response)
+	}
+}
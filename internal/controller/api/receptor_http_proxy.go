@@ -7,9 +7,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"time"
 
 	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/controller"
 	"github.com/redhatinsights/platform-go-middlewares/request_id"
 
 	"github.com/google/uuid"
@@ -18,6 +21,7 @@ import (
 var (
 	errUnableToSendMessage     = errors.New("unable to send message")
 	errUnableToProcessResponse = errors.New("unable to process response")
+	errMessageExpired          = errors.New("message expired before it could be sent")
 )
 
 type ReceptorHttpProxy struct {
@@ -25,6 +29,14 @@ type ReceptorHttpProxy struct {
 	AccountNumber string
 	NodeID        string
 	Config        *config.Config
+
+	// JobLocator, when set, records the pod of the jobs sent through the proxy
+	JobLocator JobLocator
+}
+
+// GetPod returns the pod that owns the connection, as stored in Redis
+func (rhp *ReceptorHttpProxy) GetPod() string {
+	return rhp.Hostname
 }
 
 func (rhp *ReceptorHttpProxy) SendMessage(ctx context.Context, accountNumber string, recipient string, route []string, payload interface{}, directive string) (*uuid.UUID, error) {
@@ -33,7 +45,13 @@ func (rhp *ReceptorHttpProxy) SendMessage(ctx context.Context, accountNumber str
 
 	probe.sendingMessage(accountNumber, recipient)
 
-	postPayload := jobRequest{accountNumber, recipient, payload, directive}
+	ttl, err := getMessageTTL(ctx)
+	if err != nil {
+		probe.failedToSendMessage("Unable to send message.  Message expired.", err)
+		return nil, err
+	}
+
+	postPayload := jobRequest{accountNumber, recipient, payload, directive, ttl}
 	jsonStr, err := json.Marshal(postPayload)
 	if err != nil {
 		probe.failedToSendMessage("Unable to send message.  Failed to marshal JSON payload.", err)
@@ -72,6 +90,10 @@ func (rhp *ReceptorHttpProxy) SendMessage(ctx context.Context, accountNumber str
 
 	probe.messageSent(messageID)
 
+	if rhp.JobLocator != nil {
+		rhp.JobLocator.RecordJobLocation(messageID, rhp.Hostname)
+	}
+
 	return &messageID, nil
 }
 
@@ -176,7 +198,11 @@ func (rhp *ReceptorHttpProxy) GetCapabilities(ctx context.Context) (interface{},
 
 	defer resp.Body.Close()
 
-	statusResponse := connectionStatusResponse{}
+	statusResponse := struct {
+		Capabilities        json.RawMessage `json:"capabilities"`
+		CapabilitiesError   string          `json:"capabilities_error"`
+		CapabilitiesPartial bool            `json:"capabilities_partial"`
+	}{}
 
 	dec := json.NewDecoder(resp.Body)
 	if err := dec.Decode(&statusResponse); err != nil {
@@ -184,7 +210,199 @@ func (rhp *ReceptorHttpProxy) GetCapabilities(ctx context.Context) (interface{},
 		return nil, errUnableToProcessResponse
 	}
 
-	return statusResponse.Capabilities, nil
+	if statusResponse.CapabilitiesError != "" {
+		return nil, controller.MalformedCapabilitiesError{Reason: statusResponse.CapabilitiesError}
+	}
+
+	capabilities, err := controller.ParseCapabilitiesJSON(statusResponse.Capabilities)
+	if err != nil {
+		return nil, err
+	}
+
+	if statusResponse.CapabilitiesPartial {
+		return capabilities, controller.PartialCapabilitiesError{}
+	}
+
+	return capabilities, nil
+}
+
+func (rhp *ReceptorHttpProxy) CancelMessage(ctx context.Context, accountNumber string, messageID uuid.UUID) (string, error) {
+	probe := createProbe(ctx)
+
+	probe.cancellingMessage(accountNumber, rhp.NodeID, messageID)
+
+	resp, err := makeHttpRequest(
+		ctx,
+		http.MethodPost,
+		rhp.generateUrl("job/"+messageID.String()+"/cancel"),
+		rhp.AccountNumber,
+		rhp.Config,
+		nil,
+	)
+
+	if err != nil {
+		probe.failedToCancelMessage("Unable to cancel message.  Failed to create HTTP Request.", err)
+		return "", errUnableToSendMessage
+	}
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusAccepted:
+	case http.StatusNotFound:
+		return "", controller.OutboxEntryNotFoundError{}
+	case http.StatusConflict:
+		return "", controller.JobCompletedError{}
+	default:
+		probe.failedToCancelMessage("Unable to cancel message.  Unexpected response from receptor-gateway.",
+			errors.New(resp.Status))
+		return "", errUnableToSendMessage
+	}
+
+	cancelResponse := jobCancelResponse{}
+
+	dec := json.NewDecoder(resp.Body)
+	if err := dec.Decode(&cancelResponse); err != nil {
+		probe.failedToCancelMessage("Unable to read response from receptor-gateway", err)
+		return "", errUnableToProcessResponse
+	}
+
+	return cancelResponse.Status, nil
+}
+
+// GetJobStatus reads the status of the job from the outbox of the pod
+func (rhp *ReceptorHttpProxy) GetJobStatus(ctx context.Context, messageID uuid.UUID) (*jobStatusResponse, error) {
+	probe := createProbe(ctx)
+
+	probe.gettingJobStatus(rhp.AccountNumber, messageID)
+
+	resp, err := makeHttpRequest(
+		ctx,
+		http.MethodGet,
+		rhp.generateUrl("job/"+messageID.String()),
+		rhp.AccountNumber,
+		rhp.Config,
+		nil,
+	)
+
+	if err != nil {
+		probe.failedToGetJobStatus("Unable to get the job status.  Failed to create HTTP Request.", err)
+		return nil, errUnableToSendMessage
+	}
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, controller.OutboxEntryNotFoundError{}
+	default:
+		probe.failedToGetJobStatus("Unable to get the job status.  Unexpected response from receptor-gateway.",
+			errors.New(resp.Status))
+		return nil, errUnableToSendMessage
+	}
+
+	// The gateway names the fields of the response the same way as this
+	// service, so the response is decoded like a request
+	jobStatus := jobStatusResponse{}
+	if err := decodeJSON(ctx, resp.Body, &jobStatus); err != nil {
+		probe.failedToGetJobStatus("Unable to read response from receptor-gateway", err)
+		return nil, errUnableToProcessResponse
+	}
+
+	return &jobStatus, nil
+}
+
+func (rhp *ReceptorHttpProxy) GetHealth(ctx context.Context) (string, error) {
+	probe := createProbe(ctx)
+
+	probe.gettingHealth(rhp.AccountNumber, rhp.NodeID)
+
+	postPayload := connectionID{rhp.AccountNumber, rhp.NodeID}
+	jsonStr, err := json.Marshal(postPayload)
+	if err != nil {
+		probe.failedToRetrieveHealth("Unable to retrieve health.  Failed to marshal JSON payload.", err)
+		return "", errUnableToSendMessage
+	}
+
+	resp, err := makeHttpRequest(
+		ctx,
+		http.MethodPost,
+		rhp.generateUrl("connection/status"),
+		rhp.AccountNumber,
+		rhp.Config,
+		bytes.NewBuffer(jsonStr),
+	)
+
+	if err != nil {
+		probe.failedToRetrieveHealth("Unable to retrieve health.  Failed to create HTTP Request.", err)
+		return "", errUnableToSendMessage
+	}
+
+	defer resp.Body.Close()
+
+	statusResponse := connectionStatusResponse{}
+
+	dec := json.NewDecoder(resp.Body)
+	if err := dec.Decode(&statusResponse); err != nil {
+		probe.failedToRetrieveHealth("Unable to read response from receptor-gateway", err)
+		return "", errUnableToProcessResponse
+	}
+
+	return statusResponse.Health, nil
+}
+
+func (rhp *ReceptorHttpProxy) GetRoutingTable(ctx context.Context) (*controller.RoutingTable, error) {
+	probe := createProbe(ctx)
+
+	probe.gettingRoutingTable(rhp.AccountNumber, rhp.NodeID)
+
+	resp, err := makeHttpRequest(
+		ctx,
+		http.MethodGet,
+		rhp.generateUrl("routing/"+rhp.AccountNumber),
+		rhp.AccountNumber,
+		rhp.Config,
+		nil,
+	)
+
+	if err != nil {
+		probe.failedToRetrieveRoutingTable("Unable to retrieve routing table.  Failed to create HTTP Request.", err)
+		return nil, errUnableToSendMessage
+	}
+
+	defer resp.Body.Close()
+
+	routingResponse := routingTableResponse{}
+
+	dec := json.NewDecoder(resp.Body)
+	if err := dec.Decode(&routingResponse); err != nil {
+		probe.failedToRetrieveRoutingTable("Unable to read response from receptor-gateway", err)
+		return nil, errUnableToProcessResponse
+	}
+
+	routingTable, exists := routingResponse.RoutingTables[rhp.NodeID]
+	if !exists {
+		return &controller.RoutingTable{}, nil
+	}
+
+	return routingTable, nil
+}
+
+// getMessageTTL converts the message expiration time stored in the context
+// into the number of seconds (rounded up) until the message expires
+func getMessageTTL(ctx context.Context) (int, error) {
+	expiresAt, exists := controller.GetMessageExpiry(ctx)
+	if !exists {
+		return 0, nil
+	}
+
+	remaining := time.Until(expiresAt)
+	if remaining <= 0 {
+		return 0, errMessageExpired
+	}
+
+	return int(math.Ceil(remaining.Seconds())), nil
 }
 
 func (rhp *ReceptorHttpProxy) generateUrl(path string) string {
@@ -211,6 +429,8 @@ func makeHttpRequest(ctx context.Context, method, url, accountNumber string, con
 
 	addRequestIdHeader(req.Header, ctx)
 
+	addTraceContextHeaders(req.Header, ctx)
+
 	return http.DefaultClient.Do(req.WithContext(ctx))
 }
 
@@ -227,3 +447,15 @@ func addRequestIdHeader(headers http.Header, ctx context.Context) {
 	requestId := request_id.GetReqID(ctx)
 	headers.Set("x-rh-insights-request-id", requestId)
 }
+
+func addTraceContextHeaders(headers http.Header, ctx context.Context) {
+	tc, exists := controller.GetTraceContext(ctx)
+	if !exists {
+		return
+	}
+
+	headers.Set(controller.TRACEPARENT_HEADER, tc.TraceParent)
+	if tc.TraceState != "" {
+		headers.Set(controller.TRACESTATE_HEADER, tc.TraceState)
+	}
+}
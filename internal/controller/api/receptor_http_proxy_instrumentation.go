@@ -63,6 +63,14 @@ func (rhpp *receptorHttpProxyProbe) gettingCapabilities(accountNumber, recipient
 	rhpp.logger.Info("Getting node capabilities from receptor-gateway")
 }
 
+func (rhpp *receptorHttpProxyProbe) gettingHealth(accountNumber, recipient string) {
+	rhpp.logger.Info("Getting node health from receptor-gateway")
+}
+
+func (rhpp *receptorHttpProxyProbe) gettingRoutingTable(accountNumber, recipient string) {
+	rhpp.logger.Info("Getting node routing table from receptor-gateway")
+}
+
 func (rhpp *receptorHttpProxyProbe) failedToProcessMessageResponse(errorMsg string, err error) {
 	metrics.receptorProxyMessageResponseProcessFailureCounter.Inc()
 	logError(rhpp.logger, err, errorMsg)
@@ -86,6 +94,14 @@ func (rhpp *receptorHttpProxyProbe) failedToRetrieveCapabilities(errorMsg string
 	logError(rhpp.logger, err, errorMsg)
 }
 
+func (rhpp *receptorHttpProxyProbe) failedToRetrieveHealth(errorMsg string, err error) {
+	logError(rhpp.logger, err, errorMsg)
+}
+
+func (rhpp *receptorHttpProxyProbe) failedToRetrieveRoutingTable(errorMsg string, err error) {
+	logError(rhpp.logger, err, errorMsg)
+}
+
 func logError(logger *logrus.Entry, err error, errMsg string) {
 	logger.WithFields(logrus.Fields{"error": err}).Error(errMsg)
 }
@@ -135,3 +151,27 @@ func newMetrics() *receptorHttpProxyMetrics {
 
 	return metrics
 }
+
+func (rhpp *receptorHttpProxyProbe) forwardingMessage(accountNumber, nodeID string, messageID uuid.UUID) {
+	rhpp.logger.WithFields(logrus.Fields{"message_id": messageID}).Infof("Forwarding message to receptor-gateway - %s:%s\n", accountNumber, nodeID)
+}
+
+func (rhpp *receptorHttpProxyProbe) failedToForwardMessage(errorMsg string, err error) {
+	logError(rhpp.logger, err, errorMsg)
+}
+
+func (rhpp *receptorHttpProxyProbe) cancellingMessage(accountNumber, nodeID string, messageID uuid.UUID) {
+	rhpp.logger.WithFields(logrus.Fields{"message_id": messageID}).Infof("Cancelling message on receptor-gateway - %s:%s\n", accountNumber, nodeID)
+}
+
+func (rhpp *receptorHttpProxyProbe) failedToCancelMessage(errorMsg string, err error) {
+	logError(rhpp.logger, err, errorMsg)
+}
+
+func (rhpp *receptorHttpProxyProbe) gettingJobStatus(accountNumber string, messageID uuid.UUID) {
+	rhpp.logger.WithFields(logrus.Fields{"message_id": messageID}).Debugf("Getting job status from receptor-gateway - %s\n", accountNumber)
+}
+
+func (rhpp *receptorHttpProxyProbe) failedToGetJobStatus(errorMsg string, err error) {
+	logError(rhpp.logger, err, errorMsg)
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/controller"
+)
+
+const (
+	routingFormatJSON  = "json"
+	routingFormatDOT   = "dot"
+	routingFormatGraph = "graph"
+)
+
+type routingGraphNode struct {
+	ID        string `json:"id"`
+	Connected bool   `json:"connected"`
+}
+
+type routingGraphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Cost   int    `json:"cost"`
+}
+
+// routingGraph is the mesh topology of an account, merged from the routing
+// tables of its connections.  The edges are undirected; an edge reported by
+// more than one connection is listed once, with its lowest cost.  The nodes
+// and edges are sorted so that the output is stable.
+type routingGraph struct {
+	Account string             `json:"account"`
+	Nodes   []routingGraphNode `json:"nodes"`
+	Edges   []routingGraphEdge `json:"edges"`
+}
+
+func newRoutingGraph(account string, routingTables map[string]*controller.RoutingTable) routingGraph {
+	connected := make(map[string]bool)
+	edges := make(map[[2]string]int)
+
+	addNode := func(nodeID string) {
+		if _, exists := connected[nodeID]; !exists {
+			connected[nodeID] = false
+		}
+	}
+
+	for nodeID, routingTable := range routingTables {
+		connected[nodeID] = true
+
+		for _, seen := range routingTable.Seen {
+			addNode(seen)
+		}
+
+		for _, edge := range routingTable.Edges {
+			addNode(edge.Left)
+			addNode(edge.Right)
+
+			key := [2]string{edge.Left, edge.Right}
+			if edge.Right < edge.Left {
+				key = [2]string{edge.Right, edge.Left}
+			}
+			if cost, exists := edges[key]; !exists || edge.Cost < cost {
+				edges[key] = edge.Cost
+			}
+		}
+	}
+
+	graph := routingGraph{
+		Account: account,
+		Nodes:   make([]routingGraphNode, 0, len(connected)),
+		Edges:   make([]routingGraphEdge, 0, len(edges)),
+	}
+
+	for nodeID, isConnected := range connected {
+		graph.Nodes = append(graph.Nodes, routingGraphNode{ID: nodeID, Connected: isConnected})
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool { return graph.Nodes[i].ID < graph.Nodes[j].ID })
+
+	for key, cost := range edges {
+		graph.Edges = append(graph.Edges, routingGraphEdge{Source: key[0], Target: key[1], Cost: cost})
+	}
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].Source != graph.Edges[j].Source {
+			return graph.Edges[i].Source < graph.Edges[j].Source
+		}
+		return graph.Edges[i].Target < graph.Edges[j].Target
+	})
+
+	return graph
+}
+
+// writeDOT writes the graph in the Graphviz DOT language.  The nodes
+// connected to the controller are drawn as boxes and the edges are labelled
+// with their cost.
+func (g routingGraph) writeDOT(w io.Writer) {
+	fmt.Fprintf(w, "graph %s {\n", dotID(g.Account))
+
+	for _, node := range g.Nodes {
+		if node.Connected {
+			fmt.Fprintf(w, "  %s [shape=box];\n", dotID(node.ID))
+		} else {
+			fmt.Fprintf(w, "  %s;\n", dotID(node.ID))
+		}
+	}
+
+	for _, edge := range g.Edges {
+		fmt.Fprintf(w, "  %s -- %s [label=\"%d\"];\n", dotID(edge.Source), dotID(edge.Target), edge.Cost)
+	}
+
+	fmt.Fprint(w, "}\n")
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// dotID quotes the node ids, which are chosen by the nodes
+func dotID(id string) string {
+	return `"` + dotEscaper.Replace(id) + `"`
+}
+
+func writeRoutingGraphResponse(w http.ResponseWriter, format string, graph routingGraph) {
+	if format == routingFormatGraph {
+		writeJSONResponse(w, http.StatusOK, graph)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	graph.writeDOT(w)
+}
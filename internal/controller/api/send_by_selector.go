@@ -0,0 +1,222 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/controller"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/middlewares"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+	"github.com/redhatinsights/platform-go-middlewares/request_id"
+
+	"github.com/sirupsen/logrus"
+)
+
+type connectionSendBySelectorRequest struct {
+	Account   string      `json:"account" validate:"required,account"`
+	Selector  string      `json:"selector" validate:"required"`
+	Payload   interface{} `json:"payload" validate:"required"`
+	Directive string      `json:"directive" validate:"required"`
+	TTL       int         `json:"ttl,omitempty" validate:"gte=0"`
+}
+
+const (
+	selectorEquals = iota
+	selectorNotEquals
+	selectorExists
+	selectorNotExists
+)
+
+type selectorRequirement struct {
+	key      string
+	operator int
+	value    string
+}
+
+// metadataSelector selects connections by their metadata, like a kubernetes
+// label selector.  A connection must meet all of the requirements.
+type metadataSelector []selectorRequirement
+
+// parseMetadataSelector parses a comma separated list of requirements.  Each
+// requirement is one of key=value (or key==value), key!=value, key (the key
+// is set) or !key (the key is not set).
+func parseMetadataSelector(selector string) (metadataSelector, error) {
+	var requirements metadataSelector
+
+	for _, requirement := range strings.Split(selector, ",") {
+		requirement = strings.TrimSpace(requirement)
+
+		var r selectorRequirement
+		switch {
+		case strings.Contains(requirement, "!="):
+			parts := strings.SplitN(requirement, "!=", 2)
+			r = selectorRequirement{key: parts[0], operator: selectorNotEquals, value: parts[1]}
+		case strings.Contains(requirement, "=="):
+			parts := strings.SplitN(requirement, "==", 2)
+			r = selectorRequirement{key: parts[0], operator: selectorEquals, value: parts[1]}
+		case strings.Contains(requirement, "="):
+			parts := strings.SplitN(requirement, "=", 2)
+			r = selectorRequirement{key: parts[0], operator: selectorEquals, value: parts[1]}
+		case strings.HasPrefix(requirement, "!"):
+			r = selectorRequirement{key: strings.TrimPrefix(requirement, "!"), operator: selectorNotExists}
+		default:
+			r = selectorRequirement{key: requirement, operator: selectorExists}
+		}
+
+		r.key = strings.TrimSpace(r.key)
+		r.value = strings.TrimSpace(r.value)
+
+		if r.key == "" {
+			return nil, fmt.Errorf("invalid requirement %q: the key is missing", requirement)
+		}
+
+		requirements = append(requirements, r)
+	}
+
+	return requirements, nil
+}
+
+// matches checks the requirements against the attributes of a connection.
+// Only scalar attributes are compared to a value.
+func (s metadataSelector) matches(attributes map[string]interface{}) bool {
+	for _, r := range s {
+		value, exists := attributes[r.key]
+
+		switch r.operator {
+		case selectorExists:
+			if !exists {
+				return false
+			}
+		case selectorNotExists:
+			if exists {
+				return false
+			}
+		case selectorEquals:
+			if !exists || !scalarEquals(value, r.value) {
+				return false
+			}
+		case selectorNotEquals:
+			if exists && scalarEquals(value, r.value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func scalarEquals(value interface{}, expected string) bool {
+	switch value.(type) {
+	case string, bool, float64, int:
+		return fmt.Sprint(value) == expected
+	}
+	return false
+}
+
+// connectionAttributes returns the attributes a selector is matched against:
+// the labels of the connection and the top level keys of the metadata that
+// the node reported.  A metadata key hides a label with the same name.
+func connectionAttributes(ctx context.Context, client controller.Receptor) map[string]interface{} {
+	attributes := make(map[string]interface{})
+
+	if labeler, ok := client.(controller.ConnectionLabeler); ok {
+		if labels, err := labeler.GetLabels(ctx); err == nil {
+			for name, value := range labels {
+				attributes[name] = value
+			}
+		}
+	}
+
+	if detailer, ok := client.(controller.ConnectionDetailer); ok {
+		if metadata, err := detailer.GetMetadata(ctx); err == nil {
+			if m, ok := metadata.(map[string]interface{}); ok {
+				for key, value := range m {
+					attributes[key] = value
+				}
+			}
+		}
+	}
+
+	return attributes
+}
+
+// handleConnectionSendBySelector sends a message to the nodes of an account
+// whose metadata matches the selector
+func (s *ManagementServer) handleConnectionSendBySelector() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		principal, _ := middlewares.GetPrincipal(req.Context())
+		requestId := request_id.GetReqID(req.Context())
+		logger := logger.Log.WithFields(logrus.Fields{
+			"account":    principal.GetAccount(),
+			"request_id": requestId})
+
+		body := http.MaxBytesReader(w, req.Body, 1048576)
+
+		var sendRequest connectionSendBySelectorRequest
+
+		if err := decodeJSON(req.Context(), body, &sendRequest); err != nil {
+			errorResponse := errorResponse{Title: "Unable to process json input",
+				Status: http.StatusBadRequest,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		selector, err := parseMetadataSelector(sendRequest.Selector)
+		if err != nil {
+			errorResponse := errorResponse{Title: "Invalid selector",
+				Status: http.StatusBadRequest,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		if requestCancelled(w, req, logger) {
+			return
+		}
+
+		if err := controller.VerifyDirective(s.config.AllowedDirectives, sendRequest.Directive); err != nil {
+			logger.WithFields(logrus.Fields{"audit": true, "directive": sendRequest.Directive}).Warn("Rejected a message with a directive that is not allowed")
+			errorResponse := errorResponse{Title: "Directive not allowed",
+				Status: http.StatusBadRequest,
+				Detail: err.Error()}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		connections := make(map[string]controller.Receptor)
+		for nodeID, client := range s.connectionMgr.GetConnectionsByAccount(sendRequest.Account) {
+			if selector.matches(connectionAttributes(req.Context(), client)) {
+				connections[nodeID] = client
+			}
+		}
+
+		if len(connections) == 0 {
+			errMsg := fmt.Sprintf("No connections of account %s match the selector", sendRequest.Account)
+			logger.Info(errMsg)
+			errorResponse := errorResponse{Title: errMsg,
+				Status: http.StatusNotFound,
+				Detail: errMsg}
+			writeJSONResponse(w, errorResponse.Status, errorResponse)
+			return
+		}
+
+		logger = logger.WithFields(logrus.Fields{"directive": sendRequest.Directive, "selector": sendRequest.Selector})
+		logger.Infof("Sending a message to the %d nodes of account %s matching the selector", len(connections), sendRequest.Account)
+
+		response := s.sendToConnections(withMessageTTL(withRequestTraceContext(req, logger), sendRequest.TTL), logger,
+			sendRequest.Account, connections, sendRequest.Payload, sendRequest.Directive)
+
+		logger.Infof("Message sent to %d of %d nodes", len(response.Jobs), len(connections))
+
+		status := http.StatusCreated
+		if len(response.Errors) > 0 {
+			status = http.StatusMultiStatus
+		}
+
+		writeJSONResponse(w, status, response)
+	}
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/controller"
+	"github.com/sirupsen/logrus"
+)
+
+// withRequestTraceContext returns a copy of the request context that carries
+// the trace context passed in the traceparent and tracestate headers.  A
+// malformed trace context is ignored.
+func withRequestTraceContext(req *http.Request, logger *logrus.Entry) context.Context {
+	ctx := req.Context()
+
+	traceParent := req.Header.Get(controller.TRACEPARENT_HEADER)
+	if traceParent == "" {
+		return ctx
+	}
+
+	tc, err := controller.ParseTraceContext(traceParent, req.Header.Get(controller.TRACESTATE_HEADER))
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err, "traceparent": traceParent}).Debug("Ignoring the trace context")
+		return ctx
+	}
+
+	return controller.WithTraceContext(ctx, tc)
+}
@@ -1,46 +1,216 @@
 package api
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
 )
 
+// statusClientClosedRequest is the non-standard status code (popularized by
+// nginx) used when the client closed the request before it was handled
+const statusClientClosedRequest = 499
+
 type errorResponse struct {
 	Title  string `json:"title"`
 	Status int    `json:"status"`
 	Detail string `json:"detail"`
 }
 
+// maxBatchSize is the maximum number of items accepted by a batch endpoint
+const maxBatchSize = 100
+
+type batchItemError struct {
+	Index  int    `json:"index"`
+	Detail string `json:"detail"`
+}
+
+type batchValidationErrorResponse struct {
+	errorResponse
+	Errors []batchItemError `json:"errors"`
+}
+
 func writeJSONResponse(w http.ResponseWriter, status int, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	w.WriteHeader(status)
-	if err := json.NewEncoder(w).Encode(payload); err != nil {
+
+	pretty, camelCase := jsonResponseOptions(w)
+
+	if camelCase {
+		renamed, err := toCamelCaseJSON(payload)
+		if err != nil {
+			http.Error(w, "Unable to encode payload!", http.StatusUnprocessableEntity)
+			log.Println("Unable to encode payload!")
+			return
+		}
+		payload = renamed
+	}
+
+	encoder := json.NewEncoder(w)
+	if pretty {
+		encoder.SetIndent("", "  ")
+	}
+
+	if err := encoder.Encode(payload); err != nil {
 		http.Error(w, "Unable to encode payload!", http.StatusUnprocessableEntity)
 		log.Println("Unable to encode payload!")
 	}
 }
 
-func decodeJSON(body io.ReadCloser, data interface{}) error {
-	dec := json.NewDecoder(body)
+// requestCancelled writes a client closed request response if the request has
+// already been cancelled by the client.  Handlers call it before doing any
+// expensive or side-effecting work so that an abandoned request is not acted
+// upon.
+func requestCancelled(w http.ResponseWriter, req *http.Request, logger *logrus.Entry) bool {
+	err := req.Context().Err()
+	if err == nil {
+		return false
+	}
+
+	logger.WithFields(logrus.Fields{"error": err}).Info("Request was cancelled by the client")
+	errorResponse := errorResponse{Title: "Request cancelled",
+		Status: statusClientClosedRequest,
+		Detail: err.Error()}
+	writeJSONResponse(w, errorResponse.Status, errorResponse)
+	return true
+}
+
+// accountPattern is the format of an account number.  It is shared by the
+// routes that take an account in the path and by the "account" validation of
+// the request bodies so that the two cannot disagree on what a valid account
+// is.
+const accountPattern = "[0-9]+"
+
+var accountRegexp = regexp.MustCompile("^" + accountPattern + "$")
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterValidation("account", func(fl validator.FieldLevel) bool {
+		return accountRegexp.MatchString(fl.Field().String())
+	})
+	return v
+}
+
+// validationErrorDetail describes why a request (or an item of a batch
+// request) failed validation
+func validationErrorDetail(err error) string {
+	if validationErrors, ok := err.(validator.ValidationErrors); ok {
+		for _, e := range validationErrors {
+			if e.Tag() == "account" {
+				return "includes an invalid account (the account must be numeric)"
+			}
+		}
+	}
+	return "is missing required fields"
+}
+
+// decodeJSON decodes the request body into data.  If the request uses the
+// camelCase naming convention, the fields are renamed before they are decoded.
+func decodeJSON(ctx context.Context, body io.ReadCloser, data interface{}) error {
+	var reader io.Reader = body
+	if isCamelCaseJSONRequest(ctx) {
+		raw, err := ioutil.ReadAll(body)
+		if err != nil {
+			return errors.New("Request body includes malformed json")
+		}
+		if renamed, err := fromCamelCaseJSON(raw, data); err == nil {
+			raw = renamed
+		}
+		reader = bytes.NewReader(raw)
+	}
+
+	dec := json.NewDecoder(reader)
 	if err := dec.Decode(&data); err != nil {
 		// FIXME: More specific error handling needed.. case statement for different scenarios?
 		return errors.New("Request body includes malformed json")
 	}
 
-	v := validator.New()
+	v := newValidator()
 	if err := v.Struct(data); err != nil {
 		for _, e := range err.(validator.ValidationErrors) {
 			log.Println(e)
 		}
-		return errors.New("Request body is missing required fields")
+		return errors.New("Request body " + validationErrorDetail(err))
 	} else if dec.More() {
 		return errors.New("Request body must only contain one json object")
 	}
 
 	return nil
 }
+
+// validateBatchItems validates each item of a batch request individually so
+// that the caller can report which items are invalid.  The items of a batch
+// request should not be validated by decodeJSON (i.e. the slice should not be
+// tagged with "dive").
+func validateBatchItems(items interface{}) []batchItemError {
+	var itemErrors []batchItemError
+
+	v := newValidator()
+	slice := reflect.ValueOf(items)
+	for i := 0; i < slice.Len(); i++ {
+		if err := v.Struct(slice.Index(i).Interface()); err != nil {
+			itemErrors = append(itemErrors, batchItemError{Index: i, Detail: "item " + validationErrorDetail(err)})
+		}
+	}
+
+	return itemErrors
+}
+
+// batchRequest is implemented by the requests of the batch endpoints
+type batchRequest interface {
+	batchItems() interface{}
+}
+
+// decodeJSONBatch decodes and validates a batch request.  If the request can
+// not be parsed, or any of its items are invalid, a 400 response is written
+// and false is returned.  None of the items should be processed in that case.
+func decodeJSONBatch(w http.ResponseWriter, req *http.Request, data batchRequest) bool {
+	body := http.MaxBytesReader(w, req.Body, 1048576)
+
+	if err := decodeJSON(req.Context(), body, data); err != nil {
+		errorResponse := errorResponse{Title: "Unable to process json input",
+			Status: http.StatusBadRequest,
+			Detail: err.Error()}
+		writeJSONResponse(w, errorResponse.Status, errorResponse)
+		return false
+	}
+
+	if reflect.ValueOf(data.batchItems()).Len() > maxBatchSize {
+		errorResponse := errorResponse{Title: "Batch is too large",
+			Status: http.StatusBadRequest,
+			Detail: fmt.Sprintf("a batch can contain at most %d items", maxBatchSize)}
+		writeJSONResponse(w, errorResponse.Status, errorResponse)
+		return false
+	}
+
+	if itemErrors := validateBatchItems(data.batchItems()); len(itemErrors) > 0 {
+		errorResponse := batchValidationErrorResponse{
+			errorResponse: errorResponse{Title: "Invalid batch items",
+				Status: http.StatusBadRequest,
+				Detail: fmt.Sprintf("%d of the items in the batch are invalid", len(itemErrors))},
+			Errors: itemErrors}
+		writeJSONResponse(w, errorResponse.Status, errorResponse)
+		return false
+	}
+
+	return true
+}
+
+func getQueryParamInt(req *http.Request, name string, defaultValue int) (int, error) {
+	value := req.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
+}
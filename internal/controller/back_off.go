@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type NodeBackedOffError struct {
+	Until time.Time
+}
+
+func (e NodeBackedOffError) Error() string {
+	return fmt.Sprintf("the node asked not to be contacted until %s", e.Until.UTC().Format(time.RFC3339))
+}
+
+// NodePauseReporter is implemented by receptors that report whether the node
+// itself paused the delivery of messages, either with a flow control message
+// or by asking the controller to back off (see BackOff)
+type NodePauseReporter interface {
+	IsPausedByNode(ctx context.Context) bool
+
+	// GetBackOffUntil returns the time until which the node asked not to be
+	// contacted.  The zero time is returned if the node is not backing off.
+	GetBackOffUntil(ctx context.Context) time.Time
+}
+
+// retryAfterDuration converts the retry_after field of a node response (in
+// seconds) into a duration
+func retryAfterDuration(retryAfter float64) time.Duration {
+	return time.Duration(retryAfter * float64(time.Second))
+}
+
+// BackOff honors a response in which the node asked the controller to wait
+// before sending it anything else.  The messages sent in the meantime are held
+// like the messages sent while the delivery is paused and the pings fail with
+// a NodeBackedOffError.  The back-off is bounded by Receptor_Max_Back_Off and
+// only ever extended by a later response.
+func (r *ReceptorService) BackOff(retryAfter time.Duration) {
+	if retryAfter <= 0 {
+		return
+	}
+
+	if r.config.ReceptorMaxBackOff > 0 && retryAfter > r.config.ReceptorMaxBackOff {
+		r.logger.Infof("Limiting the back-off requested by the node (%s) to %s", retryAfter, r.config.ReceptorMaxBackOff)
+		retryAfter = r.config.ReceptorMaxBackOff
+	}
+
+	until := time.Now().Add(retryAfter)
+
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+
+	if r.backOffStopped || until.Before(r.backOffUntil) {
+		return
+	}
+
+	r.logger.WithFields(logrus.Fields{"retry_after": retryAfter.String()}).Info("Backing off at the request of the node")
+
+	r.backedOff = true
+	r.backOffUntil = until
+
+	if r.backOffTimer == nil {
+		r.backOffTimer = time.AfterFunc(retryAfter, r.endBackOff)
+	} else {
+		r.backOffTimer.Reset(retryAfter)
+	}
+}
+
+// endBackOff flushes the messages held while the node was backing off
+func (r *ReceptorService) endBackOff() {
+	r.pauseLock.Lock()
+	extended := time.Now().Before(r.backOffUntil)
+	r.pauseLock.Unlock()
+
+	// The timer has been reset by a later response
+	if extended {
+		return
+	}
+
+	r.logger.Info("The back-off requested by the node has elapsed")
+	r.resume(context.Background(), &r.backedOff)
+}
+
+// stopBackOff keeps a pending back-off from flushing the held messages once
+// the connection has been closed
+func (r *ReceptorService) stopBackOff() {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+
+	r.backOffStopped = true
+	if r.backOffTimer != nil {
+		r.backOffTimer.Stop()
+	}
+}
+
+// checkBackOff returns a NodeBackedOffError while the node is backing off
+func (r *ReceptorService) checkBackOff() error {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+
+	if r.backedOff {
+		return NodeBackedOffError{Until: r.backOffUntil}
+	}
+
+	return nil
+}
+
+func (r *ReceptorService) IsPausedByNode(ctx context.Context) bool {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+	return r.nodePaused || r.backedOff
+}
+
+func (r *ReceptorService) GetBackOffUntil(ctx context.Context) time.Time {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+
+	if !r.backedOff {
+		return time.Time{}
+	}
+	return r.backOffUntil
+}
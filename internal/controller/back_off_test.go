@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+)
+
+// newBackOffTestTransport buffers the send channel so that the test can check
+// which messages were passed to the transport, while the pings still go over
+// the (unbuffered) control channel
+func newBackOffTestTransport() *Transport {
+	transport := newTestTransport(false)
+	transport.Send = make(chan ReceptorMessage, 10)
+	return transport
+}
+
+func TestReceptorServiceHoldsMessagesWhileTheNodeBacksOff(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.ReceptorMaxBackOff = time.Minute
+	transport := newBackOffTestTransport()
+	receptor := newTestReceptorService(cfg, transport)
+	defer receptor.Close(context.TODO())
+
+	first, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "first", "worker:action")
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+	<-transport.Send
+
+	handler := AckHandler{Receptor: receptor, Transport: transport, Logger: receptor.logger}
+	handler.HandleMessage(context.TODO(), &protocol.AckMessage{
+		Command:    protocol.AckCommand,
+		ID:         testNodeID,
+		MessageID:  first.String(),
+		RetryAfter: 0.2,
+	})
+
+	backedOffAt := time.Now()
+
+	if !receptor.IsPausedByNode(context.TODO()) {
+		t.Fatalf("Expected the delivery to be paused by the node")
+	}
+
+	if until := receptor.GetBackOffUntil(context.TODO()); until.Sub(backedOffAt) > 200*time.Millisecond || until.Before(backedOffAt) {
+		t.Fatalf("Expected the back-off to end in 200ms, got %s", until)
+	}
+
+	second, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "second", "worker:action")
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	if len(transport.Send) != 0 {
+		t.Fatalf("Expected the message to be held while the node backs off")
+	}
+
+	if _, err := receptor.Ping(context.TODO(), testAccount, testNodeID, []string{testNodeID}); err == nil {
+		t.Fatalf("Expected the ping to be suppressed while the node backs off")
+	} else if _, ok := err.(NodeBackedOffError); !ok {
+		t.Fatalf("Expected a NodeBackedOffError, got %v", err)
+	}
+
+	select {
+	case msg := <-transport.Send:
+		if elapsed := time.Since(backedOffAt); elapsed < 150*time.Millisecond {
+			t.Fatalf("Expected the message to be held until the back-off elapsed, it was sent after %s", elapsed)
+		}
+
+		payloadMessage := msg.Message.(*protocol.PayloadMessage)
+		if payloadMessage.Data.MessageID != second.String() {
+			t.Fatalf("Expected the held message %s, got %s", second, payloadMessage.Data.MessageID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Expected the held message to be sent once the back-off elapsed")
+	}
+
+	if receptor.IsPausedByNode(context.TODO()) || !receptor.GetBackOffUntil(context.TODO()).IsZero() {
+		t.Fatalf("Expected the back-off to be over")
+	}
+}
+
+func TestReceptorServiceBacksOffWhenAPingResponseAsksForIt(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.ReceptorMaxBackOff = time.Minute
+	transport := newBackOffTestTransport()
+	receptor := newTestReceptorService(cfg, transport)
+	defer receptor.Close(context.TODO())
+
+	go func() {
+		msg := <-transport.ControlChannel
+		request := msg.Message.(*protocol.PayloadMessage)
+
+		receptor.DispatchResponse(&protocol.PayloadMessage{
+			RoutingInfo: &protocol.RoutingMessage{Sender: testNodeID},
+			Data: protocol.InnerEnvelope{
+				MessageID:    "f8f1e292-a50b-4b35-b3a3-d5b4f5e4a9ef",
+				InResponseTo: request.Data.MessageID,
+				RawPayload:   "pong",
+				RetryAfter:   30,
+			},
+		})
+	}()
+
+	if _, err := receptor.Ping(context.TODO(), testAccount, testNodeID, []string{testNodeID}); err != nil {
+		t.Fatalf("Expected the ping to succeed, got %v", err)
+	}
+
+	if !receptor.IsPaused(context.TODO()) || !receptor.IsPausedByNode(context.TODO()) {
+		t.Fatalf("Expected the delivery to be paused by the node")
+	}
+
+	if _, err := receptor.Ping(context.TODO(), testAccount, testNodeID, []string{testNodeID}); err == nil {
+		t.Fatalf("Expected the next ping to be suppressed")
+	}
+}
+
+func TestReceptorServiceLimitsTheBackOff(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.ReceptorMaxBackOff = time.Second
+	receptor := newTestReceptorService(cfg, newBackOffTestTransport())
+	defer receptor.Close(context.TODO())
+
+	receptor.BackOff(time.Hour)
+
+	if until := receptor.GetBackOffUntil(context.TODO()); time.Until(until) > time.Second {
+		t.Fatalf("Expected the back-off to be limited to a second, got %s", until)
+	}
+
+	// A shorter back-off does not cut the current one short
+	until := receptor.GetBackOffUntil(context.TODO())
+	receptor.BackOff(time.Millisecond)
+	if receptor.GetBackOffUntil(context.TODO()) != until {
+		t.Fatalf("Expected the back-off to be unchanged")
+	}
+}
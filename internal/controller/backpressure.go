@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"errors"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrBackpressure is returned when too many messages are waiting to be
+// written to the node, e.g. because the node has stopped reading from the
+// connection
+var ErrBackpressure = errors.New("too many messages waiting to be sent to the node")
+
+// maxInFlightMessages returns the number of messages that can be waiting to
+// be written to the node.  Zero means there is no limit.
+func (r *ReceptorService) maxInFlightMessages() int {
+	if limit, exists := r.config.MaxInFlightMessagesOverride[r.AccountNumber]; exists {
+		return limit
+	}
+	return r.config.MaxInFlightMessages
+}
+
+// acquireInFlightSlot reserves room for a message that is about to be passed
+// to the transport.  The slot must be released once the message has been
+// written, has expired or has failed.
+func (r *ReceptorService) acquireInFlightSlot() error {
+	limit := r.maxInFlightMessages()
+
+	r.inFlightLock.Lock()
+	defer r.inFlightLock.Unlock()
+
+	if limit > 0 && r.inFlight >= limit {
+		r.logger.WithFields(logrus.Fields{"in_flight": r.inFlight, "limit": limit}).Warn("Too many messages waiting to be sent to the node")
+		metrics.backpressureCounter.Inc()
+		return ErrBackpressure
+	}
+
+	r.inFlight++
+
+	return nil
+}
+
+func (r *ReceptorService) releaseInFlightSlot() {
+	r.inFlightLock.Lock()
+	defer r.inFlightLock.Unlock()
+	r.inFlight--
+}
+
+func (r *ReceptorService) getInFlightCount() int {
+	r.inFlightLock.Lock()
+	defer r.inFlightLock.Unlock()
+	return r.inFlight
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestReceptorServiceSendMessageRejectsMessagesPastTheInFlightLimit(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.MaxInFlightMessages = 3
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorService(cfg, transport)
+	defer receptor.Close(context.TODO())
+
+	for i := 0; i < 3; i++ {
+		if _, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action"); err != nil {
+			t.Fatalf("Expected the error to be nil, got %v", err)
+		}
+	}
+
+	rejected := testutil.ToFloat64(metrics.backpressureCounter)
+
+	_, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action")
+	if err != ErrBackpressure {
+		t.Fatalf("Expected %v, got %v", ErrBackpressure, err)
+	}
+
+	if len(transport.Send) != 3 {
+		t.Fatalf("Expected the 3 accepted messages to remain queued, got %d", len(transport.Send))
+	}
+
+	if testutil.ToFloat64(metrics.backpressureCounter) != rejected+1 {
+		t.Fatalf("Expected the backpressure counter to be incremented")
+	}
+}
+
+func TestReceptorServiceInFlightSlotIsReleasedOnceTheMessageLeavesTheQueue(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.MaxInFlightMessages = 2
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorService(cfg, transport)
+	defer receptor.Close(context.TODO())
+
+	for i := 0; i < 2; i++ {
+		if _, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action"); err != nil {
+			t.Fatalf("Expected the error to be nil, got %v", err)
+		}
+	}
+
+	(<-transport.Send).OnSent()
+	(<-transport.Send).OnExpired()
+
+	if count := receptor.getInFlightCount(); count != 0 {
+		t.Fatalf("Expected no messages in flight, got %d", count)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action"); err != nil {
+			t.Fatalf("Expected the error to be nil, got %v", err)
+		}
+	}
+}
+
+func TestReceptorServiceInFlightLimitOverride(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.MaxInFlightMessages = 5
+	cfg.MaxInFlightMessagesOverride = map[string]int{testAccount: 1}
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorService(cfg, transport)
+	defer receptor.Close(context.TODO())
+
+	if _, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action"); err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	_, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action")
+	if err != ErrBackpressure {
+		t.Fatalf("Expected %v, got %v", ErrBackpressure, err)
+	}
+}
+
+func TestReceptorServiceWithoutInFlightLimit(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.MaxInFlightMessages = 0
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorService(cfg, transport)
+	defer receptor.Close(context.TODO())
+
+	for i := 0; i < cap(transport.Send); i++ {
+		if _, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action"); err != nil {
+			t.Fatalf("Expected the error to be nil, got %v", err)
+		}
+	}
+}
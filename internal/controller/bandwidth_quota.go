@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrQuotaExceeded is returned when a message would push more data to the
+// nodes of an account than its bandwidth quota allows within the window
+var ErrQuotaExceeded = errors.New("the account has exceeded its bandwidth quota")
+
+type bandwidthUsage struct {
+	windowStart time.Time
+	bytes       int64
+}
+
+// bandwidthQuota counts the bytes of the payloads sent to the nodes, by
+// account, over fixed windows.  The window of an account starts with its first
+// message and the count is reset once the window is over.  The counts are kept
+// in memory and only cover the messages sent by this pod.
+type bandwidthQuota struct {
+	window time.Duration
+
+	accounts  map[string]*bandwidthUsage
+	lastPrune time.Time
+
+	sync.Mutex
+}
+
+// newBandwidthQuota returns nil (no quota) if neither a global quota nor a
+// per account quota is configured
+func newBandwidthQuota(cfg *config.Config) *bandwidthQuota {
+	if cfg.AccountBandwidthQuota <= 0 && len(cfg.AccountBandwidthQuotaOverride) == 0 {
+		return nil
+	}
+
+	return &bandwidthQuota{
+		window:    cfg.AccountBandwidthQuotaWindow,
+		accounts:  make(map[string]*bandwidthUsage),
+		lastPrune: time.Now(),
+	}
+}
+
+// reserve counts the bytes against the quota of the account unless they would
+// exceed it.  It returns false, without counting the bytes, if they would.  The
+// time until the window of the account resets is returned along with false.
+func (q *bandwidthQuota) reserve(account string, quota int64, bytes int, now time.Time) (bool, time.Duration) {
+	if q == nil || quota <= 0 {
+		return true, 0
+	}
+
+	q.Lock()
+	defer q.Unlock()
+
+	// Accounts that stop sending would otherwise be kept forever
+	if now.Sub(q.lastPrune) > q.window {
+		q.prune(now)
+	}
+
+	usage, exists := q.accounts[account]
+	if !exists || now.Sub(usage.windowStart) >= q.window {
+		usage = &bandwidthUsage{windowStart: now}
+		q.accounts[account] = usage
+	}
+
+	if usage.bytes+int64(bytes) > quota {
+		return false, usage.windowStart.Add(q.window).Sub(now)
+	}
+
+	usage.bytes += int64(bytes)
+
+	return true, 0
+}
+
+// release gives back the bytes reserved at reservedAt.  Nothing is given back
+// once the window that the bytes were counted in is over.
+func (q *bandwidthQuota) release(account string, bytes int, reservedAt time.Time) {
+	q.Lock()
+	defer q.Unlock()
+
+	usage, exists := q.accounts[account]
+	if !exists || reservedAt.Before(usage.windowStart) {
+		return
+	}
+
+	usage.bytes -= int64(bytes)
+	if usage.bytes < 0 {
+		usage.bytes = 0
+	}
+}
+
+func (q *bandwidthQuota) prune(now time.Time) {
+	for account, usage := range q.accounts {
+		if now.Sub(usage.windowStart) >= q.window {
+			delete(q.accounts, account)
+		}
+	}
+
+	q.lastPrune = now
+}
+
+// bandwidthQuotaLimit returns the number of bytes that can be sent to the nodes
+// of the account within the window.  Zero means there is no quota.
+func (r *ReceptorService) bandwidthQuotaLimit() int64 {
+	if quota, exists := r.config.AccountBandwidthQuotaOverride[r.AccountNumber]; exists {
+		return int64(quota)
+	}
+	return int64(r.config.AccountBandwidthQuota)
+}
+
+// reserveBandwidth counts the payload of the message against the bandwidth
+// quota of the account.  A message that would exceed the quota is rejected
+// with ErrQuotaExceeded.  The returned function gives the reservation back if
+// the message is not accepted after all.  Once the message has been accepted,
+// the payload is counted whether or not it is then delivered.
+func (r *ReceptorService) reserveBandwidth(message Message) (func(), error) {
+	quota := r.bandwidthQuotaLimit()
+	size := PayloadSize(message.Payload)
+	now := time.Now()
+
+	allowed, retryAfter := r.bandwidthQuota.reserve(r.AccountNumber, quota, size, now)
+	if !allowed {
+		r.logger.WithFields(logrus.Fields{"message_id": message.MessageID, "retry_after": retryAfter}).Warn("Bandwidth quota exceeded...rejecting message")
+		metrics.quotaExceededMessageCounter.Inc()
+		return nil, ErrQuotaExceeded
+	}
+
+	if r.bandwidthQuota == nil || quota <= 0 {
+		return func() {}, nil
+	}
+
+	return func() { r.bandwidthQuota.release(r.AccountNumber, size, now) }, nil
+}
@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+)
+
+func sendBinaryMessages(receptor *ReceptorService, count int, size int) (int, int, error) {
+	accepted, rejected := 0, 0
+	for i := 0; i < count; i++ {
+		_, err := receptor.SendBinaryMessage(context.TODO(), receptor.AccountNumber, testNodeID, []string{testNodeID}, make([]byte, size), "worker:action")
+		switch err {
+		case nil:
+			accepted++
+		case ErrQuotaExceeded:
+			rejected++
+		default:
+			return accepted, rejected, err
+		}
+	}
+	return accepted, rejected, nil
+}
+
+func newQuotaTestConfig(quota int, window time.Duration) *config.Config {
+	cfg := config.GetConfig()
+	cfg.AccountBandwidthQuota = quota
+	cfg.AccountBandwidthQuotaOverride = map[string]int{}
+	cfg.AccountBandwidthQuotaWindow = window
+	return cfg
+}
+
+func TestBandwidthQuotaResetsAfterTheWindow(t *testing.T) {
+	quota := newBandwidthQuota(newQuotaTestConfig(300, time.Minute))
+	start := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if allowed, _ := quota.reserve(testAccount, 300, 100, start.Add(time.Duration(i)*time.Second)); !allowed {
+			t.Fatalf("Expected the message %d to be accepted", i)
+		}
+	}
+
+	allowed, retryAfter := quota.reserve(testAccount, 300, 1, start.Add(10*time.Second))
+	if allowed {
+		t.Fatalf("Expected the message over the quota to be rejected")
+	}
+	if retryAfter != 50*time.Second {
+		t.Fatalf("Expected the window to reset in 50s, got %s", retryAfter)
+	}
+
+	if allowed, _ := quota.reserve("0000002", 300, 100, start.Add(10*time.Second)); !allowed {
+		t.Fatalf("Expected the quota of the other accounts to be unaffected")
+	}
+
+	if allowed, _ := quota.reserve(testAccount, 300, 300, start.Add(time.Minute)); !allowed {
+		t.Fatalf("Expected the message to be accepted once the window has reset")
+	}
+}
+
+func TestReceptorServiceSendMessageRejectsMessagesOverTheBandwidthQuota(t *testing.T) {
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorService(newQuotaTestConfig(300, 200*time.Millisecond), transport)
+	defer receptor.Close(context.TODO())
+
+	quotaExceeded := testutil.ToFloat64(metrics.quotaExceededMessageCounter)
+
+	accepted, rejected, err := sendBinaryMessages(receptor, 5, 100)
+	if err != nil || accepted != 3 || rejected != 2 {
+		t.Fatalf("Expected 3 messages to be accepted and 2 to be rejected, got %d and %d (error: %v)", accepted, rejected, err)
+	}
+
+	if len(transport.Send) != 3 {
+		t.Fatalf("Expected the 3 accepted messages to be queued, got %d", len(transport.Send))
+	}
+
+	if testutil.ToFloat64(metrics.quotaExceededMessageCounter) != quotaExceeded+2 {
+		t.Fatalf("Expected the quota exceeded counter to be incremented")
+	}
+
+	time.Sleep(250 * time.Millisecond)
+
+	accepted, rejected, err = sendBinaryMessages(receptor, 1, 100)
+	if err != nil || accepted != 1 || rejected != 0 {
+		t.Fatalf("Expected the message to be accepted once the window has reset, got %d accepted, %d rejected (error: %v)", accepted, rejected, err)
+	}
+}
+
+func TestReceptorServiceBandwidthQuotaIsSharedByTheConnectionsOfAnAccount(t *testing.T) {
+	cfg := newQuotaTestConfig(0, time.Minute)
+	cfg.ReceptorWarmupGracePeriod = 0
+	cfg.AccountBandwidthQuotaOverride = map[string]int{testAccount: 150}
+
+	factory := NewReceptorServiceFactory(nil, NewInMemoryOutboxStore(), cfg)
+	log := logger.Log.WithFields(logrus.Fields{"account": testAccount})
+
+	newReceptor := func(account string) *ReceptorService {
+		receptor := factory.NewReceptorService(log, account, "node-cloud-receptor-controller")
+		receptor.RegisterConnection(testNodeID, nil, newBufferedTestTransport())
+		return receptor
+	}
+
+	first := newReceptor(testAccount)
+	defer first.Close(context.TODO())
+	second := newReceptor(testAccount)
+	defer second.Close(context.TODO())
+	unlimited := newReceptor("0000002")
+	defer unlimited.Close(context.TODO())
+
+	if accepted, _, err := sendBinaryMessages(first, 1, 100); err != nil || accepted != 1 {
+		t.Fatalf("Expected the first message to be accepted (error: %v)", err)
+	}
+
+	if _, rejected, err := sendBinaryMessages(second, 1, 100); err != nil || rejected != 1 {
+		t.Fatalf("Expected the message sent over the other connection to be rejected (error: %v)", err)
+	}
+
+	// The global quota is not set
+	if accepted, _, err := sendBinaryMessages(unlimited, 5, 100); err != nil || accepted != 5 {
+		t.Fatalf("Expected the messages of the account without a quota to be accepted (error: %v)", err)
+	}
+}
+
+func TestReceptorServiceBandwidthIsGivenBackWhenTheMessageIsNotSent(t *testing.T) {
+	// Nothing reads from the send channel until the first message has failed
+	transportCtx, transportCancel := context.WithCancel(context.Background())
+	transport := &Transport{
+		Send:   make(chan ReceptorMessage),
+		Ctx:    transportCtx,
+		Cancel: transportCancel,
+	}
+	receptor := newTestReceptorService(newQuotaTestConfig(100, time.Minute), transport)
+	defer receptor.Close(context.TODO())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := receptor.SendBinaryMessage(ctx, testAccount, testNodeID, []string{testNodeID}, make([]byte, 100), "worker:action"); err != requestTimedOut {
+		t.Fatalf("Expected the message to time out, got %v", err)
+	}
+
+	go func() { <-transport.Send }()
+
+	if _, err := receptor.SendBinaryMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, make([]byte, 100), "worker:action"); err != nil {
+		t.Fatalf("Expected the bandwidth of the message that was not sent to be given back, got %v", err)
+	}
+
+	if _, rejected, err := sendBinaryMessages(receptor, 1, 1); err != nil || rejected != 1 {
+		t.Fatalf("Expected the bandwidth of the message that was sent to be counted (error: %v)", err)
+	}
+}
@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+// BinaryMessageSender is implemented by receptors that can send a payload to
+// the node as is, without encoding it as json
+type BinaryMessageSender interface {
+	SendBinaryMessage(ctx context.Context, account string, recipient string, route []string, data []byte, directive string) (*uuid.UUID, error)
+}
+
+// BinaryPayload is the payload of a message sent with SendBinaryMessage.  It
+// is written to the node in a binary frame rather than being encoded as json.
+type BinaryPayload []byte
+
+// binaryPayloadKey tells a binary payload apart from a json payload once the
+// message has been encoded as json (e.g. in the SQL outbox or when the message
+// is forwarded to another pod)
+const binaryPayloadKey = "$binary"
+
+func (p BinaryPayload) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string][]byte{binaryPayloadKey: p})
+}
+
+// DecodePayload restores the binary payload of a message that has been decoded
+// from json.  The other payloads are returned unchanged.
+func DecodePayload(payload interface{}) interface{} {
+	encoded, ok := payload.(map[string]interface{})
+	if !ok || len(encoded) != 1 {
+		return payload
+	}
+
+	value, ok := encoded[binaryPayloadKey].(string)
+	if !ok {
+		return payload
+	}
+
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return payload
+	}
+
+	return BinaryPayload(data)
+}
+
+// PayloadSize returns the number of bytes the payload takes up when it is
+// written to the node
+func PayloadSize(payload interface{}) int {
+	if data, ok := payload.(BinaryPayload); ok {
+		return len(data)
+	}
+
+	// The other payloads are encoded as json
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return 0
+	}
+	return len(encoded)
+}
+
+// SendBinaryMessage sends data to the node as is.  The message goes through
+// the same outbox, flow control and rate limits as the messages sent with
+// SendMessage.
+func (r *ReceptorService) SendBinaryMessage(msgSenderCtx context.Context, account string, recipient string, route []string, data []byte, directive string) (*uuid.UUID, error) {
+	return r.SendMessage(msgSenderCtx, account, recipient, route, BinaryPayload(data), directive)
+}
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+)
+
+func TestBinaryPayloadSurvivesJSONEncoding(t *testing.T) {
+	data := []byte{0x1f, 0x8b, 0x08, 0x00, 0xff}
+
+	encoded, err := json.Marshal(Message{Payload: BinaryPayload(data)})
+	if err != nil {
+		t.Fatalf("Unable to encode the message: %v", err)
+	}
+
+	var decoded Message
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Unable to decode the message: %v", err)
+	}
+
+	payload, ok := DecodePayload(decoded.Payload).(BinaryPayload)
+	if !ok || !bytes.Equal(payload, data) {
+		t.Fatalf("Expected the binary payload to be restored, got %#v", decoded.Payload)
+	}
+
+	jsonPayload := map[string]interface{}{"url": "http://example.com"}
+	if DecodePayload(jsonPayload).(map[string]interface{})["url"] != "http://example.com" {
+		t.Fatalf("Expected the json payload to be left alone")
+	}
+}
+
+func TestPayloadSize(t *testing.T) {
+	if size := PayloadSize(BinaryPayload(make([]byte, 100))); size != 100 {
+		t.Fatalf("Expected the size of a binary payload to be its length, got %d", size)
+	}
+
+	if size := PayloadSize("payload"); size != len(`"payload"`) {
+		t.Fatalf("Expected the size of a json payload to be its encoded length, got %d", size)
+	}
+}
+
+func TestReceptorServiceSendBinaryMessage(t *testing.T) {
+	transport := newBufferedTestTransport()
+	outbox := NewInMemoryOutboxStore()
+	receptor := newTestReceptorServiceWithOutbox(config.GetConfig(), transport, outbox)
+	defer receptor.Close(context.TODO())
+
+	data := []byte{0x00, 0x01, 0x02}
+	messageID, err := receptor.SendBinaryMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, data, "worker:upload")
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	msg := <-transport.Send
+	binaryMessage, ok := msg.Message.(*protocol.BinaryPayloadMessage)
+	if !ok || !bytes.Equal(binaryMessage.Payload, data) {
+		t.Fatalf("Expected a binary payload message carrying the data, got %#v", msg.Message)
+	}
+
+	entry, err := outbox.Get(context.TODO(), *messageID)
+	if err != nil || PayloadSize(entry.Message.Payload) != len(data) {
+		t.Fatalf("Expected the outbox to record the binary payload, got %+v (error: %v)", entry, err)
+	}
+}
@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+type JobCompletedError struct {
+}
+
+func (e JobCompletedError) Error() string {
+	return "the job has already ended"
+}
+
+// MessageCanceller is implemented by receptors that can cancel a message that
+// was sent to the node.  The status of the job after the cancel is returned.
+type MessageCanceller interface {
+	CancelMessage(ctx context.Context, account string, messageID uuid.UUID) (string, error)
+}
+
+// CancelMessage cancels a message that was sent on this connection.  A message
+// that is held while the delivery is paused is dropped and its job is
+// cancelled right away.  Otherwise, a cancel command that references the
+// message is queued behind it, and the job is cancelling until the node
+// reports that the job has ended (or the connection is closed before the
+// message was written).
+func (r *ReceptorService) CancelMessage(ctx context.Context, account string, messageID uuid.UUID) (string, error) {
+
+	if account != r.AccountNumber {
+		return "", accountMismatch
+	}
+
+	if r.outbox == nil {
+		return "", OutboxEntryNotFoundError{}
+	}
+
+	entry, err := r.outbox.Get(ctx, messageID)
+	if err != nil {
+		return "", err
+	}
+
+	if entry.AccountNumber != r.AccountNumber || entry.NodeID != r.PeerNodeID {
+		return "", OutboxEntryNotFoundError{}
+	}
+
+	if IsFinalOutboxStatus(entry.Status) {
+		return "", JobCompletedError{}
+	}
+
+	logger := r.logger.WithFields(logrus.Fields{"message_id": messageID})
+
+	if r.dropHeldMessage(messageID) {
+		logger.Info("Dropped a held message that was cancelled")
+		r.updateOutboxStatus(messageID, OUTBOX_CANCELLED_STATUS)
+		return OUTBOX_CANCELLED_STATUS, nil
+	}
+
+	// The job is marked as cancelling before the cancel command is sent so
+	// that the status set once the job has ended is not overwritten
+	if err := r.outbox.UpdateStatus(ctx, messageID, OUTBOX_CANCELLING_STATUS); err != nil {
+		return "", err
+	}
+
+	r.startCancelling(messageID)
+
+	cancelMessage := &protocol.CancelMessage{
+		Command:   protocol.CancelCommand,
+		ID:        r.NodeID,
+		MessageID: messageID.String(),
+	}
+
+	if err := r.sendCancelMessage(ctx, cancelMessage); err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Info("Unable to send the cancel command")
+		// The job may have ended in the meantime, its status is left alone
+		if r.stopCancelling(messageID) {
+			r.updateOutboxStatus(messageID, entry.Status)
+		}
+		return "", err
+	}
+
+	logger.Info("Sent a cancel command")
+
+	return OUTBOX_CANCELLING_STATUS, nil
+}
+
+// sendCancelMessage passes the cancel command to the send channel (rather
+// than the control channel) so that it cannot overtake the message that it
+// cancels
+func (r *ReceptorService) sendCancelMessage(ctx context.Context, cancelMessage *protocol.CancelMessage) error {
+
+	ctx, cancel := context.WithTimeout(ctx, r.sendTimeout())
+	defer cancel()
+
+	msg := ReceptorMessage{AccountNumber: r.AccountNumber, Message: cancelMessage}
+
+	r.sendLock.RLock()
+	defer r.sendLock.RUnlock()
+
+	if r.closed {
+		r.logger.Info("Connection to receptor network has been closed")
+		return ErrConnectionClosed
+	}
+
+	return sendMessage(r.logger, r.Transport.Ctx, r.Transport.Send, ctx, msg)
+}
+
+// dropHeldMessage removes the message from the messages held while the
+// delivery is paused.  It returns false if the message is not held.
+func (r *ReceptorService) dropHeldMessage(messageID uuid.UUID) bool {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+
+	for i, message := range r.pausedMessages {
+		if message.MessageID == messageID {
+			r.pausedMessages = append(r.pausedMessages[:i:i], r.pausedMessages[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *ReceptorService) startCancelling(messageID uuid.UUID) {
+	r.cancellingLock.Lock()
+	defer r.cancellingLock.Unlock()
+
+	if r.cancelling == nil {
+		r.cancelling = make(map[uuid.UUID]struct{})
+	}
+	r.cancelling[messageID] = struct{}{}
+}
+
+func (r *ReceptorService) isCancelling(messageID uuid.UUID) bool {
+	r.cancellingLock.Lock()
+	defer r.cancellingLock.Unlock()
+
+	_, exists := r.cancelling[messageID]
+	return exists
+}
+
+// stopCancelling returns false if the message was not being cancelled
+func (r *ReceptorService) stopCancelling(messageID uuid.UUID) bool {
+	r.cancellingLock.Lock()
+	defer r.cancellingLock.Unlock()
+
+	_, exists := r.cancelling[messageID]
+	delete(r.cancelling, messageID)
+	return exists
+}
+
+// recordJobEnded records that the node has sent the last response for the
+// message
+func (r *ReceptorService) recordJobEnded(messageID uuid.UUID) {
+	status := OUTBOX_COMPLETED_STATUS
+	if r.stopCancelling(messageID) {
+		status = OUTBOX_CANCELLED_STATUS
+	}
+
+	r.updateOutboxStatus(messageID, status)
+}
@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+
+	"github.com/google/uuid"
+)
+
+func TestReceptorServiceCancelQueuedMessage(t *testing.T) {
+	cfg := config.GetConfig()
+	outbox := NewInMemoryOutboxStore()
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorServiceWithOutbox(cfg, transport, outbox)
+	defer receptor.Close(context.TODO())
+
+	messageID, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action")
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	status, err := receptor.CancelMessage(context.TODO(), testAccount, *messageID)
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	if status != OUTBOX_CANCELLING_STATUS {
+		t.Fatalf("Expected the job to be %s, got %s", OUTBOX_CANCELLING_STATUS, status)
+	}
+
+	// The cancel command is queued behind the message that it cancels
+	queued := <-transport.Send
+	if _, ok := queued.Message.(*protocol.PayloadMessage); !ok {
+		t.Fatalf("Expected the payload message to be sent first, got %+v", queued.Message)
+	}
+	queued.OnSent()
+
+	cancelMessage, ok := (<-transport.Send).Message.(*protocol.CancelMessage)
+	if !ok {
+		t.Fatalf("Expected a cancel command to be sent")
+	}
+
+	if cancelMessage.Command != protocol.CancelCommand || cancelMessage.MessageID != messageID.String() {
+		t.Fatalf("Incorrect cancel command: %+v", cancelMessage)
+	}
+
+	waitForOutboxStatus(t, outbox, *messageID, OUTBOX_CANCELLING_STATUS)
+
+	receptor.recordJobEnded(*messageID)
+
+	waitForOutboxStatus(t, outbox, *messageID, OUTBOX_CANCELLED_STATUS)
+}
+
+func TestReceptorServiceCancelInterleavedWithTheEndOfTheJob(t *testing.T) {
+	cfg := config.GetConfig()
+	outbox := NewInMemoryOutboxStore()
+	// The cancel command blocks on the send channel until the test reads it
+	transportCtx, transportCancel := context.WithCancel(context.Background())
+	transport := &Transport{
+		Send:   make(chan ReceptorMessage),
+		Ctx:    transportCtx,
+		Cancel: transportCancel,
+	}
+	receptor := newTestReceptorServiceWithOutbox(cfg, transport, outbox)
+	defer receptor.Close(context.TODO())
+
+	sent := make(chan error, 1)
+	var messageID *uuid.UUID
+	go func() {
+		var err error
+		messageID, err = receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action")
+		sent <- err
+	}()
+	(<-transport.Send).OnSent()
+	if err := <-sent; err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	cancelled := make(chan error, 1)
+	go func() {
+		_, err := receptor.CancelMessage(context.TODO(), testAccount, *messageID)
+		cancelled <- err
+	}()
+
+	waitForOutboxStatus(t, outbox, *messageID, OUTBOX_CANCELLING_STATUS)
+
+	// The node reports that the job has ended before the cancel command is
+	// passed to the transport
+	receptor.recordJobEnded(*messageID)
+	waitForOutboxStatus(t, outbox, *messageID, OUTBOX_CANCELLED_STATUS)
+
+	if _, ok := (<-transport.Send).Message.(*protocol.CancelMessage); !ok {
+		t.Fatalf("Expected a cancel command to be sent")
+	}
+	if err := <-cancelled; err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	entry, _ := outbox.Get(context.TODO(), *messageID)
+	if entry.Status != OUTBOX_CANCELLED_STATUS {
+		t.Fatalf("Expected the job to stay %s, got %s", OUTBOX_CANCELLED_STATUS, entry.Status)
+	}
+}
+
+func TestReceptorServiceCancelHeldMessage(t *testing.T) {
+	cfg := config.GetConfig()
+	outbox := NewInMemoryOutboxStore()
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorServiceWithOutbox(cfg, transport, outbox)
+	defer receptor.Close(context.TODO())
+
+	receptor.Pause(context.TODO())
+
+	messageID, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action")
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	status, err := receptor.CancelMessage(context.TODO(), testAccount, *messageID)
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	if status != OUTBOX_CANCELLED_STATUS {
+		t.Fatalf("Expected the job to be %s, got %s", OUTBOX_CANCELLED_STATUS, status)
+	}
+
+	receptor.Resume(context.TODO())
+
+	if len(transport.Send) != 0 {
+		t.Fatalf("Expected the cancelled message to be dropped, got %d queued messages", len(transport.Send))
+	}
+
+	waitForOutboxStatus(t, outbox, *messageID, OUTBOX_CANCELLED_STATUS)
+}
+
+func TestReceptorServiceCancelCompletedMessage(t *testing.T) {
+	cfg := config.GetConfig()
+	outbox := NewInMemoryOutboxStore()
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorServiceWithOutbox(cfg, transport, outbox)
+	defer receptor.Close(context.TODO())
+
+	messageID, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action")
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	receptor.recordJobEnded(*messageID)
+
+	_, err = receptor.CancelMessage(context.TODO(), testAccount, *messageID)
+	if err != (JobCompletedError{}) {
+		t.Fatalf("Expected %v, got %v", JobCompletedError{}, err)
+	}
+
+	waitForOutboxStatus(t, outbox, *messageID, OUTBOX_COMPLETED_STATUS)
+}
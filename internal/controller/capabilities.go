@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+const (
+	CAPABILITIES_EMPTY             = "empty"
+	CAPABILITIES_INVALID_JSON      = "invalid_json"
+	CAPABILITIES_UNEXPECTED_SCHEMA = "unexpected_schema"
+
+	maxRawCapabilitiesLength = 256
+)
+
+// MalformedCapabilitiesError is returned when the capabilities reported by a
+// node are missing or cannot be understood.  Raw holds (a truncated copy of)
+// what the node returned.
+type MalformedCapabilitiesError struct {
+	Reason string
+	Raw    string
+}
+
+func (e MalformedCapabilitiesError) Error() string {
+	switch e.Reason {
+	case CAPABILITIES_EMPTY:
+		return "node returned no capabilities"
+	case CAPABILITIES_INVALID_JSON:
+		return "node returned capabilities that are not valid json"
+	case CAPABILITIES_UNEXPECTED_SCHEMA:
+		return "node returned capabilities with an unexpected schema"
+	default:
+		return "node returned malformed capabilities"
+	}
+}
+
+// ParseCapabilities validates the capabilities found in the metadata sent by
+// a node.  The capabilities are expected to be a json object, but the contents
+// of the object are not checked so that new capabilities do not break older
+// controllers.  Some nodes send the capabilities as a json encoded string,
+// which is decoded before it is checked.
+func ParseCapabilities(capabilities interface{}) (map[string]interface{}, error) {
+	switch value := capabilities.(type) {
+	case nil:
+		return nil, MalformedCapabilitiesError{Reason: CAPABILITIES_EMPTY}
+	case map[string]interface{}:
+		return value, nil
+	case string:
+		if strings.TrimSpace(value) == "" {
+			return nil, MalformedCapabilitiesError{Reason: CAPABILITIES_EMPTY}
+		}
+		return ParseCapabilitiesJSON([]byte(value))
+	default:
+		return nil, MalformedCapabilitiesError{Reason: CAPABILITIES_UNEXPECTED_SCHEMA, Raw: rawCapabilities(value)}
+	}
+}
+
+// ParseCapabilitiesJSON validates capabilities that have not been decoded yet
+func ParseCapabilitiesJSON(rawJSON []byte) (map[string]interface{}, error) {
+	trimmed := strings.TrimSpace(string(rawJSON))
+	if trimmed == "" || trimmed == "null" {
+		return nil, MalformedCapabilitiesError{Reason: CAPABILITIES_EMPTY}
+	}
+
+	var capabilities interface{}
+	if err := json.Unmarshal([]byte(trimmed), &capabilities); err != nil {
+		return nil, MalformedCapabilitiesError{Reason: CAPABILITIES_INVALID_JSON, Raw: truncateRawCapabilities(trimmed)}
+	}
+
+	capabilitiesMap, ok := capabilities.(map[string]interface{})
+	if !ok {
+		return nil, MalformedCapabilitiesError{Reason: CAPABILITIES_UNEXPECTED_SCHEMA, Raw: truncateRawCapabilities(trimmed)}
+	}
+
+	return capabilitiesMap, nil
+}
+
+func rawCapabilities(capabilities interface{}) string {
+	raw, err := json.Marshal(capabilities)
+	if err != nil {
+		return ""
+	}
+	return truncateRawCapabilities(string(raw))
+}
+
+func truncateRawCapabilities(raw string) string {
+	if len(raw) > maxRawCapabilitiesLength {
+		return raw[:maxRawCapabilitiesLength]
+	}
+	return raw
+}
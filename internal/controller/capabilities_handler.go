@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+
+	"github.com/sirupsen/logrus"
+)
+
+type CapabilitiesHandler struct {
+	Receptor  *ReceptorService
+	Transport *Transport
+	Logger    *logrus.Entry
+
+	// Listener is notified when the capabilities have been updated.  It can
+	// be nil.
+	Listener CapabilitiesUpdateListener
+}
+
+func (ch CapabilitiesHandler) HandleMessage(ctx context.Context, m protocol.Message) {
+
+	if m.Type() != protocol.CapabilitiesMessageType {
+		ch.Logger.Infof("Invalid message type (type: %d): %v", m.Type(), m)
+		return
+	}
+
+	capabilitiesMessage, ok := m.(*protocol.CapabilitiesMessage)
+	if !ok {
+		ch.Logger.Info("Unable to convert message into CapabilitiesMessage")
+		return
+	}
+
+	complete, err := ch.Receptor.ReceiveCapabilities(capabilitiesMessage.Capabilities, capabilitiesMessage.More)
+	if err != nil {
+		ch.Logger.WithFields(logrus.Fields{"error": err}).Info("Unable to update the capabilities")
+		return
+	}
+
+	if !complete {
+		ch.Logger.Debug("Received part of the capabilities")
+		return
+	}
+
+	ch.Logger.Info("Updated the capabilities")
+
+	if ch.Listener != nil {
+		ch.Listener.CapabilitiesUpdated(ch.Receptor.AccountNumber, ch.Receptor.PeerNodeID)
+	}
+
+	return
+}
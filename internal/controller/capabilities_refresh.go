@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"context"
+)
+
+// CAPABILITIES_DIRECTIVE asks a node to respond with its current capabilities
+const CAPABILITIES_DIRECTIVE = "receptor:capabilities"
+
+// CapabilitiesRefresher is implemented by receptors that are able to ask the
+// node for its capabilities instead of waiting for the node to push them
+type CapabilitiesRefresher interface {
+	RefreshCapabilities(ctx context.Context) error
+}
+
+// RefreshCapabilities asks the node for its capabilities and replaces the
+// cached capabilities with the response.  The request is bounded by the
+// capabilities timeout of the connection.
+func (r *ReceptorService) RefreshCapabilities(ctx context.Context) error {
+
+	ctx, cancel := context.WithTimeout(ctx, r.capabilitiesTimeout())
+	defer cancel()
+
+	var responseMsg ResponseMessage
+	err := r.timeOperation(SLOW_OPERATION_CAPABILITIES, func() (err error) {
+		responseMsg, err = r.sendSyncDirective(ctx, r.PeerNodeID, []string{r.PeerNodeID}, CAPABILITIES_DIRECTIVE)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return r.UpdateCapabilities(responseMsg.Payload)
+}
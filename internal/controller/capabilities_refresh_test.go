@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+)
+
+// answerCapabilitiesRequest plays the part of the node by responding to the
+// next directive sent over the control channel
+func answerCapabilitiesRequest(t *testing.T, receptor *ReceptorService, transport *Transport, capabilities interface{}) {
+	msg := <-transport.ControlChannel
+
+	request, ok := msg.Message.(*protocol.PayloadMessage)
+	if !ok {
+		t.Errorf("Expected a payload message, got %+v", msg.Message)
+		return
+	}
+
+	if request.Data.Directive != CAPABILITIES_DIRECTIVE {
+		t.Errorf("Expected the %s directive, got %s", CAPABILITIES_DIRECTIVE, request.Data.Directive)
+	}
+
+	receptor.DispatchResponse(&protocol.PayloadMessage{
+		RoutingInfo: &protocol.RoutingMessage{Sender: testNodeID},
+		Data: protocol.InnerEnvelope{
+			MessageID:    "f8f1e292-a50b-4b35-b3a3-d5b4f5e4a9ef",
+			InResponseTo: request.Data.MessageID,
+			RawPayload:   capabilities,
+		},
+	})
+}
+
+func TestReceptorServiceRefreshCapabilities(t *testing.T) {
+	cfg := config.GetConfig()
+
+	transport := newTestTransport(false)
+	receptor := newTestReceptorService(cfg, transport)
+	defer receptor.Close(context.TODO())
+
+	go answerCapabilitiesRequest(t, receptor, transport, `{"max_work_threads": 12}`)
+
+	if err := receptor.RefreshCapabilities(context.TODO()); err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	capabilities, err := receptor.GetCapabilities(context.TODO())
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	expected := map[string]interface{}{"max_work_threads": float64(12)}
+	if !reflect.DeepEqual(capabilities, expected) {
+		t.Fatalf("Expected capabilities %+v, got %+v", expected, capabilities)
+	}
+}
+
+func TestReceptorServiceRefreshCapabilitiesKeepsCapabilitiesOnMalformedResponse(t *testing.T) {
+	cfg := config.GetConfig()
+
+	transport := newTestTransport(false)
+	receptor := newTestReceptorService(cfg, transport)
+	defer receptor.Close(context.TODO())
+
+	if err := receptor.UpdateCapabilities(map[string]interface{}{"max_work_threads": 1}); err != nil {
+		t.Fatalf("Unable to update the capabilities: %v", err)
+	}
+
+	go answerCapabilitiesRequest(t, receptor, transport, "not json")
+
+	err := receptor.RefreshCapabilities(context.TODO())
+	if _, ok := err.(MalformedCapabilitiesError); !ok {
+		t.Fatalf("Expected a MalformedCapabilitiesError, got %v", err)
+	}
+
+	capabilities, _ := receptor.GetCapabilities(context.TODO())
+	if !reflect.DeepEqual(capabilities, map[string]interface{}{"max_work_threads": 1}) {
+		t.Fatalf("Expected the cached capabilities to be kept, got %+v", capabilities)
+	}
+}
+
+func TestReceptorServiceRefreshCapabilitiesTimesOut(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.ReceptorSyncPingTimeout = 50 * time.Millisecond
+
+	receptor := newTestReceptorService(cfg, newTestTransport(false))
+	defer receptor.Close(context.TODO())
+
+	go func() { <-receptor.Transport.ControlChannel }()
+
+	if err := receptor.RefreshCapabilities(context.TODO()); err != requestTimedOut {
+		t.Fatalf("Expected the request to time out, got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"context"
+	"time"
+)
+
+// PartialCapabilitiesError is returned along with the capabilities received so
+// far when the node has not finished streaming its capabilities in time
+type PartialCapabilitiesError struct {
+}
+
+func (e PartialCapabilitiesError) Error() string {
+	return "node has not sent all of its capabilities"
+}
+
+// capabilitiesStream collects the capabilities that a node sends over several
+// messages
+type capabilitiesStream struct {
+	capabilities map[string]interface{}
+	startedAt    time.Time
+	complete     chan struct{}
+}
+
+// ReceiveCapabilities records capabilities sent by the node.  If more is set,
+// the capabilities are merged with the capabilities received earlier in the
+// stream, and the cached capabilities are only replaced once the last message
+// of the stream arrives.  It returns true once the cached capabilities have
+// been replaced.
+func (r *ReceptorService) ReceiveCapabilities(capabilities interface{}, more bool) (bool, error) {
+	parsedCapabilities, err := ParseCapabilities(capabilities)
+	if err != nil {
+		return false, err
+	}
+
+	r.metadataLock.Lock()
+	stream := r.capabilitiesStream
+	if stream == nil && more == false {
+		r.metadataLock.Unlock()
+		return true, r.UpdateCapabilities(parsedCapabilities)
+	}
+
+	if stream == nil {
+		stream = &capabilitiesStream{
+			capabilities: make(map[string]interface{}),
+			startedAt:    time.Now(),
+			complete:     make(chan struct{}),
+		}
+		r.capabilitiesStream = stream
+	}
+
+	for k, v := range parsedCapabilities {
+		stream.capabilities[k] = v
+	}
+
+	if more {
+		r.metadataLock.Unlock()
+		return false, nil
+	}
+
+	r.capabilitiesStream = nil
+	r.metadataLock.Unlock()
+
+	err = r.UpdateCapabilities(stream.capabilities)
+	close(stream.complete)
+
+	return true, err
+}
+
+// waitForCapabilities waits for the node to finish streaming its
+// capabilities.  The wait is bounded by ctx and by the capabilities timeout
+// of the connection (counted from the start of the stream, so that a stalled
+// stream does not hold up every caller).  If the stream does not complete in
+// time, a copy of the capabilities received so far is returned along with
+// false.
+func (r *ReceptorService) waitForCapabilities(ctx context.Context) (map[string]interface{}, bool) {
+	r.metadataLock.RLock()
+	stream := r.capabilitiesStream
+	r.metadataLock.RUnlock()
+
+	if stream == nil {
+		return nil, true
+	}
+
+	timer := time.NewTimer(time.Until(stream.startedAt.Add(r.capabilitiesTimeout())))
+	defer timer.Stop()
+
+	select {
+	case <-stream.complete:
+		return nil, true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	r.metadataLock.RLock()
+	defer r.metadataLock.RUnlock()
+
+	select {
+	case <-stream.complete:
+		return nil, true
+	default:
+	}
+
+	partialCapabilities := make(map[string]interface{}, len(stream.capabilities))
+	for k, v := range stream.capabilities {
+		partialCapabilities[k] = v
+	}
+
+	return partialCapabilities, false
+}
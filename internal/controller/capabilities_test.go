@@ -0,0 +1,233 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseCapabilities(t *testing.T) {
+	tests := []struct {
+		name         string
+		capabilities interface{}
+		expected     map[string]interface{}
+		reason       string
+	}{
+		{name: "nothing", capabilities: nil, reason: CAPABILITIES_EMPTY},
+		{name: "empty string", capabilities: "  ", reason: CAPABILITIES_EMPTY},
+		{name: "invalid json string", capabilities: `{"worker_versions": `, reason: CAPABILITIES_INVALID_JSON},
+		{name: "json string with unexpected schema", capabilities: `["a", "b"]`, reason: CAPABILITIES_UNEXPECTED_SCHEMA},
+		{name: "unexpected schema", capabilities: []interface{}{"a", "b"}, reason: CAPABILITIES_UNEXPECTED_SCHEMA},
+		{name: "number", capabilities: float64(42), reason: CAPABILITIES_UNEXPECTED_SCHEMA},
+		{
+			name:         "object",
+			capabilities: map[string]interface{}{"max_work_threads": float64(4), "new_field": "tolerated"},
+			expected:     map[string]interface{}{"max_work_threads": float64(4), "new_field": "tolerated"},
+		},
+		{
+			name:         "json encoded object",
+			capabilities: `{"max_work_threads": 4}`,
+			expected:     map[string]interface{}{"max_work_threads": float64(4)},
+		},
+	}
+
+	for _, tc := range tests {
+		capabilities, err := ParseCapabilities(tc.capabilities)
+
+		if tc.reason == "" {
+			if err != nil {
+				t.Fatalf("%s: expected the error to be nil, got %v", tc.name, err)
+			}
+			if cmp.Equal(tc.expected, capabilities) != true {
+				t.Fatalf("%s: expected %+v, got %+v", tc.name, tc.expected, capabilities)
+			}
+			continue
+		}
+
+		malformedErr, ok := err.(MalformedCapabilitiesError)
+		if !ok {
+			t.Fatalf("%s: expected a MalformedCapabilitiesError, got %v", tc.name, err)
+		}
+		if malformedErr.Reason != tc.reason {
+			t.Fatalf("%s: expected reason %s, got %s", tc.name, tc.reason, malformedErr.Reason)
+		}
+	}
+}
+
+func TestParseCapabilitiesJSON(t *testing.T) {
+	tests := []struct {
+		rawJSON string
+		reason  string
+	}{
+		{rawJSON: "", reason: CAPABILITIES_EMPTY},
+		{rawJSON: "null", reason: CAPABILITIES_EMPTY},
+		{rawJSON: "{not json", reason: CAPABILITIES_INVALID_JSON},
+		{rawJSON: `"a string"`, reason: CAPABILITIES_UNEXPECTED_SCHEMA},
+		{rawJSON: `{"max_work_threads": 4}`, reason: ""},
+	}
+
+	for _, tc := range tests {
+		_, err := ParseCapabilitiesJSON([]byte(tc.rawJSON))
+
+		if tc.reason == "" {
+			if err != nil {
+				t.Fatalf("%q: expected the error to be nil, got %v", tc.rawJSON, err)
+			}
+			continue
+		}
+
+		malformedErr, ok := err.(MalformedCapabilitiesError)
+		if !ok || malformedErr.Reason != tc.reason {
+			t.Fatalf("%q: expected reason %s, got %v", tc.rawJSON, tc.reason, err)
+		}
+	}
+}
+
+func TestReceptorServiceGetCapabilities(t *testing.T) {
+	tests := []struct {
+		metadata interface{}
+		reason   string
+	}{
+		{metadata: nil, reason: CAPABILITIES_EMPTY},
+		{metadata: "not an object", reason: CAPABILITIES_UNEXPECTED_SCHEMA},
+		{metadata: map[string]interface{}{}, reason: CAPABILITIES_EMPTY},
+		{metadata: map[string]interface{}{"capabilities": "{bad"}, reason: CAPABILITIES_INVALID_JSON},
+		{metadata: map[string]interface{}{"capabilities": map[string]interface{}{}}, reason: ""},
+	}
+
+	for _, tc := range tests {
+		receptor := &ReceptorService{Metadata: tc.metadata}
+
+		_, err := receptor.GetCapabilities(context.TODO())
+
+		if tc.reason == "" {
+			if err != nil {
+				t.Fatalf("%+v: expected the error to be nil, got %v", tc.metadata, err)
+			}
+			continue
+		}
+
+		malformedErr, ok := err.(MalformedCapabilitiesError)
+		if !ok || malformedErr.Reason != tc.reason {
+			t.Fatalf("%+v: expected reason %s, got %v", tc.metadata, tc.reason, err)
+		}
+	}
+}
+
+type testCapabilitiesUpdateListener struct {
+	updates []string
+}
+
+func (l *testCapabilitiesUpdateListener) CapabilitiesUpdated(account string, nodeID string) {
+	l.updates = append(l.updates, account+":"+nodeID)
+}
+
+func TestCapabilitiesHandlerUpdatesCachedCapabilities(t *testing.T) {
+	receptor := newTestReceptorService(config.GetConfig(), newTestTransport(false))
+	defer receptor.Close(context.TODO())
+
+	receptor.RegisterConnection(testNodeID,
+		map[string]interface{}{"capabilities": map[string]interface{}{"worker_versions": map[string]interface{}{"receptor_http": "1.0.0"}}, "other": "value"},
+		receptor.Transport)
+
+	listener := &testCapabilitiesUpdateListener{}
+	handler := CapabilitiesHandler{Receptor: receptor, Transport: receptor.Transport, Logger: receptor.logger, Listener: listener}
+
+	updatedCapabilities := map[string]interface{}{"worker_versions": map[string]interface{}{"receptor_http": "1.0.0", "receptor_ansible": "1.0.0"}}
+	handler.HandleMessage(context.TODO(), &protocol.CapabilitiesMessage{Command: protocol.CapabilitiesCommand, ID: testNodeID, Capabilities: updatedCapabilities})
+
+	capabilities, err := receptor.GetCapabilities(context.TODO())
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	if diff := cmp.Diff(map[string]interface{}(updatedCapabilities), capabilities); diff != "" {
+		t.Fatalf("Unexpected capabilities (-want +got):\n%s", diff)
+	}
+
+	if diff := cmp.Diff([]string{testAccount + ":" + testNodeID}, listener.updates); diff != "" {
+		t.Fatalf("Unexpected capability updates (-want +got):\n%s", diff)
+	}
+
+	// Malformed capabilities should not replace the cached capabilities
+	handler.HandleMessage(context.TODO(), &protocol.CapabilitiesMessage{Command: protocol.CapabilitiesCommand, ID: testNodeID, Capabilities: "{bad"})
+
+	capabilities, _ = receptor.GetCapabilities(context.TODO())
+	if diff := cmp.Diff(map[string]interface{}(updatedCapabilities), capabilities); diff != "" {
+		t.Fatalf("Unexpected capabilities (-want +got):\n%s", diff)
+	}
+
+	if len(listener.updates) != 1 {
+		t.Fatalf("Expected 1 capability update, got %d", len(listener.updates))
+	}
+}
+
+func TestReceptorServiceGetCapabilitiesReturnsPartialCapabilitiesOnTimeout(t *testing.T) {
+	receptor := newTestReceptorService(config.GetConfig(), newTestTransport(false))
+	defer receptor.Close(context.TODO())
+
+	handler := CapabilitiesHandler{Receptor: receptor, Transport: receptor.Transport, Logger: receptor.logger}
+
+	// Play the part of a slow node: send part of the capabilities and stall
+	handler.HandleMessage(context.TODO(), &protocol.CapabilitiesMessage{
+		Command:      protocol.CapabilitiesCommand,
+		ID:           testNodeID,
+		Capabilities: map[string]interface{}{"max_work_threads": float64(4)},
+		More:         true,
+	})
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+	defer cancel()
+
+	capabilities, err := receptor.GetCapabilities(ctx)
+	if err != (PartialCapabilitiesError{}) {
+		t.Fatalf("Expected %v, got %v", PartialCapabilitiesError{}, err)
+	}
+
+	if diff := cmp.Diff(map[string]interface{}{"max_work_threads": float64(4)}, capabilities); diff != "" {
+		t.Fatalf("Unexpected capabilities (-want +got):\n%s", diff)
+	}
+
+	handler.HandleMessage(context.TODO(), &protocol.CapabilitiesMessage{
+		Command:      protocol.CapabilitiesCommand,
+		ID:           testNodeID,
+		Capabilities: map[string]interface{}{"worker_versions": map[string]interface{}{"receptor_http": "1.0.0"}},
+	})
+
+	capabilities, err = receptor.GetCapabilities(context.TODO())
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	expected := map[string]interface{}{"max_work_threads": float64(4), "worker_versions": map[string]interface{}{"receptor_http": "1.0.0"}}
+	if diff := cmp.Diff(expected, capabilities); diff != "" {
+		t.Fatalf("Unexpected capabilities (-want +got):\n%s", diff)
+	}
+}
+
+func TestReceptorServiceGetCapabilitiesWaitsForTheStreamToComplete(t *testing.T) {
+	receptor := newTestReceptorService(config.GetConfig(), newTestTransport(false))
+	defer receptor.Close(context.TODO())
+
+	receptor.ReceiveCapabilities(map[string]interface{}{"max_work_threads": float64(4)}, true)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		receptor.ReceiveCapabilities(map[string]interface{}{"new_field": "tolerated"}, false)
+	}()
+
+	capabilities, err := receptor.GetCapabilities(context.TODO())
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	expected := map[string]interface{}{"max_work_threads": float64(4), "new_field": "tolerated"}
+	if diff := cmp.Diff(expected, capabilities); diff != "" {
+		t.Fatalf("Unexpected capabilities (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// capabilitiesWarmer fetches the capabilities of the nodes that did not report
+// them during the handshake, in the background, right after their connection
+// is registered, so that the first status query does not find the cache
+// empty.  It is shared by the receptor services created by a factory so that a
+// reconnect storm does not flood the nodes with requests: the fetches are rate
+// limited and at most maxConcurrent of them are in flight at the same time.  A
+// fetch that would wait longer than maxWait for its turn is skipped; the
+// capabilities are then left empty until the node pushes them or they are
+// refreshed.
+type capabilitiesWarmer struct {
+	limiter *sendRateLimiter
+	maxWait time.Duration
+	slots   chan struct{}
+}
+
+// newCapabilitiesWarmer returns nil if the warming is disabled
+func newCapabilitiesWarmer(cfg *config.Config) *capabilitiesWarmer {
+	if cfg == nil || !cfg.ReceptorCapabilitiesWarming {
+		return nil
+	}
+
+	maxConcurrent := cfg.ReceptorCapabilitiesWarmingMaxConcurrent
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+
+	rate := cfg.ReceptorCapabilitiesWarmingRate
+	return &capabilitiesWarmer{
+		limiter: newSendRateLimiter(rate, rate),
+		maxWait: cfg.ReceptorCapabilitiesWarmingMaxWait,
+		slots:   make(chan struct{}, maxConcurrent),
+	}
+}
+
+// warm fetches the capabilities of the node in the background unless they
+// were reported during the handshake
+func (w *capabilitiesWarmer) warm(r *ReceptorService) {
+	if w == nil || r.hasCapabilities() {
+		return
+	}
+
+	allowed, wait := w.limiter.reserve(time.Now(), w.maxWait)
+	if !allowed {
+		r.logger.WithFields(logrus.Fields{"retry_after": wait}).Debug("Too many capabilities being warmed...leaving the capabilities empty")
+		metrics.capabilitiesWarmingCounter.WithLabelValues("skipped").Inc()
+		return
+	}
+
+	go w.fetch(r, wait)
+}
+
+func (w *capabilitiesWarmer) fetch(r *ReceptorService, wait time.Duration) {
+	ctx := r.Transport.Ctx
+
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	select {
+	case w.slots <- struct{}{}:
+		defer func() { <-w.slots }()
+	case <-ctx.Done():
+		return
+	}
+
+	if err := r.RefreshCapabilities(ctx); err != nil {
+		r.logger.WithFields(logrus.Fields{"error": err}).Info("Unable to warm the capabilities of the node")
+		metrics.capabilitiesWarmingCounter.WithLabelValues("failed").Inc()
+		return
+	}
+
+	r.logger.Debug("Warmed the capabilities of the node")
+	metrics.capabilitiesWarmingCounter.WithLabelValues("warmed").Inc()
+}
+
+// hasCapabilities reports whether the node has reported its capabilities
+func (r *ReceptorService) hasCapabilities() bool {
+	r.metadataLock.RLock()
+	defer r.metadataLock.RUnlock()
+
+	metadata, ok := r.Metadata.(map[string]interface{})
+	return ok && metadata["capabilities"] != nil
+}
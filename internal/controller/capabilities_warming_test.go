@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+)
+
+func newCapabilitiesWarmingConfig(rate int, maxWait time.Duration) *config.Config {
+	cfg := config.GetConfig()
+	cfg.ReceptorCapabilitiesWarming = true
+	cfg.ReceptorCapabilitiesWarmingRate = rate
+	cfg.ReceptorCapabilitiesWarmingMaxWait = maxWait
+	cfg.ReceptorCapabilitiesWarmingMaxConcurrent = 10
+	cfg.ReceptorWarmupGracePeriod = 0
+	return cfg
+}
+
+func registerTestReceptorService(factory *ReceptorServiceFactory, metadata interface{}, transport *Transport) *ReceptorService {
+	receptor := factory.NewReceptorService(logger.Log.WithFields(logrus.Fields{"account": testAccount}), testAccount, "node-cloud-receptor-controller")
+	receptor.RegisterConnection(testNodeID, metadata, transport)
+	return receptor
+}
+
+func expectNoCapabilitiesRequest(t *testing.T, transport *Transport) {
+	select {
+	case msg := <-transport.ControlChannel:
+		t.Fatalf("Expected the capabilities not to be fetched, got %+v", msg.Message)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestCapabilitiesAreWarmedAfterRegistration(t *testing.T) {
+	transport := newTestTransport(false)
+	receptor := newTestReceptorService(newCapabilitiesWarmingConfig(10, time.Second), transport)
+	defer receptor.Close(context.TODO())
+
+	warmed := testutil.ToFloat64(metrics.capabilitiesWarmingCounter.WithLabelValues("warmed"))
+
+	answerCapabilitiesRequest(t, receptor, transport, `{"max_work_threads": 12}`)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !receptor.hasCapabilities() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the capabilities to be cached shortly after the registration")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	capabilities, err := receptor.GetCapabilities(context.TODO())
+	if err != nil || capabilities.(map[string]interface{})["max_work_threads"] != float64(12) {
+		t.Fatalf("Expected the warmed capabilities, got %+v (error: %v)", capabilities, err)
+	}
+
+	// The counter is incremented once the capabilities have been cached
+	time.Sleep(10 * time.Millisecond)
+	if testutil.ToFloat64(metrics.capabilitiesWarmingCounter.WithLabelValues("warmed")) != warmed+1 {
+		t.Fatalf("Expected the warmed counter to be incremented")
+	}
+}
+
+func TestCapabilitiesAreNotWarmedByDefault(t *testing.T) {
+	transport := newTestTransport(false)
+	receptor := newTestReceptorService(config.GetConfig(), transport)
+	defer receptor.Close(context.TODO())
+
+	expectNoCapabilitiesRequest(t, transport)
+}
+
+func TestCapabilitiesReportedDuringTheHandshakeAreNotWarmed(t *testing.T) {
+	factory := NewReceptorServiceFactory(nil, NewInMemoryOutboxStore(), newCapabilitiesWarmingConfig(10, time.Second))
+
+	transport := newTestTransport(false)
+	receptor := registerTestReceptorService(factory,
+		map[string]interface{}{"capabilities": map[string]interface{}{"max_work_threads": 4}}, transport)
+	defer receptor.Close(context.TODO())
+
+	expectNoCapabilitiesRequest(t, transport)
+}
+
+func TestCapabilitiesWarmingIsRateLimitedAcrossConnections(t *testing.T) {
+	factory := NewReceptorServiceFactory(nil, NewInMemoryOutboxStore(), newCapabilitiesWarmingConfig(1, 0))
+
+	skipped := testutil.ToFloat64(metrics.capabilitiesWarmingCounter.WithLabelValues("skipped"))
+
+	transports := []*Transport{newTestTransport(false), newTestTransport(false), newTestTransport(false)}
+	for _, transport := range transports {
+		receptor := registerTestReceptorService(factory, nil, transport)
+		defer receptor.Close(context.TODO())
+	}
+
+	// Only the first connection gets the single token of the bucket
+	select {
+	case <-transports[0].ControlChannel:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Expected the capabilities of the first connection to be fetched")
+	}
+
+	expectNoCapabilitiesRequest(t, transports[1])
+	expectNoCapabilitiesRequest(t, transports[2])
+
+	if testutil.ToFloat64(metrics.capabilitiesWarmingCounter.WithLabelValues("skipped")) != skipped+2 {
+		t.Fatalf("Expected the skipped counter to be incremented twice")
+	}
+}
+
+func TestCapabilitiesWarmingFailureLeavesTheCapabilitiesEmpty(t *testing.T) {
+	transport := newTestTransport(false)
+	receptor := newTestReceptorService(newCapabilitiesWarmingConfig(10, time.Second), transport)
+	defer receptor.Close(context.TODO())
+
+	failed := testutil.ToFloat64(metrics.capabilitiesWarmingCounter.WithLabelValues("failed"))
+
+	answerCapabilitiesRequest(t, receptor, transport, "not json")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for testutil.ToFloat64(metrics.capabilitiesWarmingCounter.WithLabelValues("failed")) != failed+1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the failed counter to be incremented")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if _, err := receptor.GetCapabilities(context.TODO()); err == nil {
+		t.Fatalf("Expected the capabilities to be left empty")
+	}
+}
@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// CloseNotifier is implemented by receptors that can tell integrations when
+// the connection to the node goes away
+type CloseNotifier interface {
+	OnClose(callback func(reason DisconnectReason))
+}
+
+type closeCallbackRunner interface {
+	runCloseCallbacks(reason DisconnectReason)
+}
+
+// OnClose registers a callback that is invoked once when the connection goes
+// away, for whatever reason, with the reason it went away for.  An empty
+// reason means the controller closed the connection without a specific
+// reason.  The callbacks are run in their own goroutines so that a slow
+// callback cannot hold up the close.  A callback registered after the
+// connection went away is invoked right away.
+func (r *ReceptorService) OnClose(callback func(reason DisconnectReason)) {
+	r.closeCallbacksLock.Lock()
+	defer r.closeCallbacksLock.Unlock()
+
+	if r.closedFor != nil {
+		go r.runCloseCallback(callback, *r.closedFor)
+		return
+	}
+
+	r.closeCallbacks = append(r.closeCallbacks, callback)
+}
+
+// runCloseCallbacks invokes the close callbacks.  Only the first call has any
+// effect.
+func (r *ReceptorService) runCloseCallbacks(reason DisconnectReason) {
+	r.closeCallbacksLock.Lock()
+	defer r.closeCallbacksLock.Unlock()
+
+	if r.closedFor != nil {
+		return
+	}
+	r.closedFor = &reason
+
+	for _, callback := range r.closeCallbacks {
+		go r.runCloseCallback(callback, reason)
+	}
+	r.closeCallbacks = nil
+}
+
+func (r *ReceptorService) runCloseCallback(callback func(reason DisconnectReason), reason DisconnectReason) {
+	defer func() {
+		if err := recover(); err != nil {
+			r.logger.WithFields(logrus.Fields{"reason": reason, "error": err}).Error("Connection close callback panicked")
+		}
+	}()
+
+	callback(reason)
+}
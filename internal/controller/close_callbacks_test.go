@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+// closeReceptorService runs the disconnect handler the way the response
+// reactor does once the connection's transport is done
+func closeReceptorService(receptor *ReceptorService, transport *Transport) {
+	disconnectHandler := DisconnectHandler{
+		AccountNumber: testAccount,
+		NodeID:        testNodeID,
+		ConnectionMgr: NewLocalConnectionManager(),
+		Logger:        logger.Log.WithFields(logrus.Fields{}),
+		Transport:     transport,
+		Receptor:      receptor,
+	}
+	disconnectHandler.HandleMessage(context.TODO(), nil)
+}
+
+func waitForCloseCallback(t *testing.T, reasons <-chan DisconnectReason) DisconnectReason {
+	select {
+	case reason := <-reasons:
+		return reason
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Timed out waiting for the close callback")
+		return ""
+	}
+}
+
+func expectNoCloseCallback(t *testing.T, reasons <-chan DisconnectReason) {
+	select {
+	case reason := <-reasons:
+		t.Fatalf("Expected the close callback to be invoked only once, it was invoked again with %q", reason)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestCloseCallbackIsInvokedOnceWithTheDisconnectReason(t *testing.T) {
+	testCases := []struct {
+		name   string
+		reason DisconnectReason
+	}{
+		{"normal", ""},
+		{"network", DISCONNECT_REASON_NETWORK},
+		{"idle", DISCONNECT_REASON_IDLE_TIMEOUT},
+		{"admin", DISCONNECT_REASON_ADMIN},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			transport := newBufferedTestTransport()
+			disconnectReason := tc.reason
+			transport.DisconnectReason = func() DisconnectReason { return disconnectReason }
+			receptor := newTestReceptorService(config.GetConfig(), transport)
+
+			reasons := make(chan DisconnectReason, 2)
+			receptor.OnClose(func(reason DisconnectReason) { reasons <- reason })
+
+			receptor.Close(context.TODO())
+			closeReceptorService(receptor, transport)
+			closeReceptorService(receptor, transport)
+
+			if reason := waitForCloseCallback(t, reasons); reason != tc.reason {
+				t.Fatalf("Expected the close callback to be invoked with %q, got %q", tc.reason, reason)
+			}
+			expectNoCloseCallback(t, reasons)
+		})
+	}
+}
+
+func TestCloseCallbackIsInvokedWithTheAdminCloseReason(t *testing.T) {
+	var closeReason CloseReason
+	transport := newBufferedTestTransport()
+	transport.SetCloseReason = func(reason CloseReason) { closeReason = reason }
+	transport.DisconnectReason = func() DisconnectReason { return DisconnectReasonForCloseReason(closeReason) }
+	receptor := newTestReceptorService(config.GetConfig(), transport)
+
+	reasons := make(chan DisconnectReason, 1)
+	receptor.OnClose(func(reason DisconnectReason) { reasons <- reason })
+
+	receptor.Close(WithCloseReason(context.TODO(), CLOSE_REASON_ADMIN_DISCONNECT))
+	closeReceptorService(receptor, transport)
+
+	if reason := waitForCloseCallback(t, reasons); reason != DISCONNECT_REASON_ADMIN {
+		t.Fatalf("Expected the close callback to be invoked with %q, got %q", DISCONNECT_REASON_ADMIN, reason)
+	}
+}
+
+func TestCloseCallbackRegisteredAfterTheCloseIsInvoked(t *testing.T) {
+	transport := newBufferedTestTransport()
+	transport.DisconnectReason = func() DisconnectReason { return DISCONNECT_REASON_NETWORK }
+	receptor := newTestReceptorService(config.GetConfig(), transport)
+
+	receptor.Close(context.TODO())
+	closeReceptorService(receptor, transport)
+
+	reasons := make(chan DisconnectReason, 1)
+	receptor.OnClose(func(reason DisconnectReason) { reasons <- reason })
+
+	if reason := waitForCloseCallback(t, reasons); reason != DISCONNECT_REASON_NETWORK {
+		t.Fatalf("Expected the close callback to be invoked with %q, got %q", DISCONNECT_REASON_NETWORK, reason)
+	}
+}
+
+func TestCloseCallbacksDoNotBlockTheClose(t *testing.T) {
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorService(config.GetConfig(), transport)
+
+	release := make(chan struct{})
+	defer close(release)
+	receptor.OnClose(func(reason DisconnectReason) { <-release })
+	receptor.OnClose(func(reason DisconnectReason) { panic("the callback failed") })
+
+	reasons := make(chan DisconnectReason, 1)
+	receptor.OnClose(func(reason DisconnectReason) { reasons <- reason })
+
+	closed := make(chan struct{})
+	go func() {
+		receptor.Close(context.TODO())
+		closeReceptorService(receptor, transport)
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Expected the close not to wait for the callbacks")
+	}
+
+	// The other callbacks are still invoked
+	waitForCloseCallback(t, reasons)
+}
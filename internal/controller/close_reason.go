@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"context"
+)
+
+// CloseReason describes why the controller closed the connection to a node.
+// The transport layer uses it to pick a close code that tells the node whether
+// to reconnect immediately, back off or stop reconnecting.
+type CloseReason string
+
+const (
+	CLOSE_REASON_NORMAL            CloseReason = "normal"
+	CLOSE_REASON_ADMIN_DISCONNECT  CloseReason = "admin_disconnect"
+	CLOSE_REASON_SHUTDOWN          CloseReason = "shutdown"
+	CLOSE_REASON_POLICY_VIOLATION  CloseReason = "policy_violation"
+	CLOSE_REASON_OVERLOADED        CloseReason = "overloaded"
+	CLOSE_REASON_PROTOCOL_MISMATCH CloseReason = "protocol_mismatch"
+	CLOSE_REASON_REBALANCE         CloseReason = "rebalance"
+)
+
+type closeReasonKey int
+
+var reasonKey closeReasonKey
+
+// WithCloseReason returns a copy of ctx that carries the reason for closing a
+// connection using the context
+func WithCloseReason(ctx context.Context, reason CloseReason) context.Context {
+	return context.WithValue(ctx, reasonKey, reason)
+}
+
+// GetCloseReason returns the close reason stored in ctx.  CLOSE_REASON_NORMAL
+// is returned if ctx does not carry a close reason.
+func GetCloseReason(ctx context.Context) CloseReason {
+	if reason, ok := ctx.Value(reasonKey).(CloseReason); ok {
+		return reason
+	}
+	return CLOSE_REASON_NORMAL
+}
+
+type reconnectHintKey int
+
+var hintKey reconnectHintKey
+
+// WithReconnectHint returns a copy of ctx that carries the pod the node should
+// reconnect to once the connection has been closed using the context
+func WithReconnectHint(ctx context.Context, pod string) context.Context {
+	return context.WithValue(ctx, hintKey, pod)
+}
+
+// GetReconnectHint returns the pod stored in ctx.  An empty string is returned
+// if ctx does not carry a reconnect hint.
+func GetReconnectHint(ctx context.Context) string {
+	if pod, ok := ctx.Value(hintKey).(string); ok {
+		return pod
+	}
+	return ""
+}
+
+// CloseReasonForError derives the close reason from an error that caused a
+// connection to be closed during the handshake
+func CloseReasonForError(err error) CloseReason {
+	switch err.(type) {
+	case ConnectionRejectedError, DuplicateConnectionError:
+		return CLOSE_REASON_POLICY_VIOLATION
+	case TooManyConnectionsError:
+		return CLOSE_REASON_OVERLOADED
+	case IncompatibleProtocolVersionError:
+		return CLOSE_REASON_PROTOCOL_MISMATCH
+	default:
+		return CLOSE_REASON_NORMAL
+	}
+}
+
+// DisconnectReason describes why a registered connection went away.  It is
+// reported in the disconnected connection events.
+type DisconnectReason string
+
+const (
+	DISCONNECT_REASON_ADMIN        DisconnectReason = "admin"
+	DISCONNECT_REASON_NETWORK      DisconnectReason = "network"
+	DISCONNECT_REASON_POLICY       DisconnectReason = "policy"
+	DISCONNECT_REASON_IDLE_TIMEOUT DisconnectReason = "idle_timeout"
+	DISCONNECT_REASON_SHUTDOWN     DisconnectReason = "shutdown"
+	DISCONNECT_REASON_REBALANCE    DisconnectReason = "rebalance"
+)
+
+// DisconnectReasonForCloseReason derives the disconnect reason of a connection
+// that was closed by the controller.  An empty reason is returned if the
+// connection was closed without a specific reason.
+func DisconnectReasonForCloseReason(reason CloseReason) DisconnectReason {
+	switch reason {
+	case CLOSE_REASON_ADMIN_DISCONNECT:
+		return DISCONNECT_REASON_ADMIN
+	case CLOSE_REASON_SHUTDOWN:
+		return DISCONNECT_REASON_SHUTDOWN
+	case CLOSE_REASON_POLICY_VIOLATION:
+		return DISCONNECT_REASON_POLICY
+	case CLOSE_REASON_REBALANCE:
+		return DISCONNECT_REASON_REBALANCE
+	default:
+		return ""
+	}
+}
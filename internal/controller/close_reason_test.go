@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+)
+
+func TestCloseReasonForError(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected CloseReason
+	}{
+		{ConnectionRejectedError{Reason: "denied"}, CLOSE_REASON_POLICY_VIOLATION},
+		{DuplicateConnectionError{}, CLOSE_REASON_POLICY_VIOLATION},
+		{TooManyConnectionsError{}, CLOSE_REASON_OVERLOADED},
+		{errors.New("Unable to convert message into HiMessage"), CLOSE_REASON_NORMAL},
+	}
+
+	for _, testCase := range testCases {
+		if reason := CloseReasonForError(testCase.err); reason != testCase.expected {
+			t.Errorf("Expected %s for %v, got %s", testCase.expected, testCase.err, reason)
+		}
+	}
+}
+
+func TestDisconnectReasonForCloseReason(t *testing.T) {
+	testCases := []struct {
+		reason   CloseReason
+		expected DisconnectReason
+	}{
+		{CLOSE_REASON_ADMIN_DISCONNECT, DISCONNECT_REASON_ADMIN},
+		{CLOSE_REASON_SHUTDOWN, DISCONNECT_REASON_SHUTDOWN},
+		{CLOSE_REASON_POLICY_VIOLATION, DISCONNECT_REASON_POLICY},
+		{CLOSE_REASON_NORMAL, ""},
+	}
+
+	for _, testCase := range testCases {
+		if reason := DisconnectReasonForCloseReason(testCase.reason); reason != testCase.expected {
+			t.Errorf("Expected %q for %s, got %q", testCase.expected, testCase.reason, reason)
+		}
+	}
+}
+
+func TestGetCloseReasonDefaultsToNormal(t *testing.T) {
+	if reason := GetCloseReason(context.TODO()); reason != CLOSE_REASON_NORMAL {
+		t.Fatalf("Expected %s, got %s", CLOSE_REASON_NORMAL, reason)
+	}
+}
+
+func TestReceptorServiceCloseRecordsTheCloseReason(t *testing.T) {
+	transport := newTestTransport(false)
+
+	var recorded CloseReason
+	transport.SetCloseReason = func(reason CloseReason) {
+		recorded = reason
+	}
+
+	receptor := newTestReceptorService(config.GetConfig(), transport)
+	receptor.Close(WithCloseReason(context.TODO(), CLOSE_REASON_SHUTDOWN))
+
+	if recorded != CLOSE_REASON_SHUTDOWN {
+		t.Fatalf("Expected %s, got %s", CLOSE_REASON_SHUTDOWN, recorded)
+	}
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ConnectionDetailer is implemented by receptors that can describe the
+// connection to the node without a round trip to the node
+type ConnectionDetailer interface {
+	GetConnectedAt(ctx context.Context) (time.Time, error)
+	GetMetadata(ctx context.Context) (interface{}, error)
+}
+
+// ConnectionLabeler is implemented by receptors that carry labels captured
+// from the headers of the websocket upgrade request
+type ConnectionLabeler interface {
+	GetLabels(ctx context.Context) (map[string]string, error)
+}
+
+// PodReporter is implemented by receptors that know which controller pod
+// serves the connection to the node
+type PodReporter interface {
+	GetPod() string
+}
+
+// CaptureLabels copies the allowed headers into a set of labels keyed by the
+// lower case header name.  Only the first value of a header is captured.
+func CaptureLabels(header http.Header, allowed []string) map[string]string {
+	labels := make(map[string]string)
+	for _, name := range allowed {
+		if value := header.Get(name); value != "" {
+			labels[strings.ToLower(name)] = value
+		}
+	}
+	return labels
+}
+
+// GetConnectedAt returns the time at which the node completed the handshake
+func (r *ReceptorService) GetConnectedAt(ctx context.Context) (time.Time, error) {
+	r.metadataLock.RLock()
+	defer r.metadataLock.RUnlock()
+
+	return r.connectedAt, nil
+}
+
+// GetMetadata returns the metadata that the node reported during the
+// handshake, including any capabilities that it has pushed since
+func (r *ReceptorService) GetMetadata(ctx context.Context) (interface{}, error) {
+	r.metadataLock.RLock()
+	defer r.metadataLock.RUnlock()
+
+	return r.Metadata, nil
+}
+
+// SetLabels sets the labels captured when the connection was established
+func (r *ReceptorService) SetLabels(labels map[string]string) {
+	r.metadataLock.Lock()
+	defer r.metadataLock.Unlock()
+
+	r.labels = labels
+}
+
+// GetLabels returns the labels captured when the connection was established
+func (r *ReceptorService) GetLabels(ctx context.Context) (map[string]string, error) {
+	r.metadataLock.RLock()
+	defer r.metadataLock.RUnlock()
+
+	return r.labels, nil
+}
+
+// GetPod returns the identity of this pod (see the Pod_ID setting), since the
+// node is connected to this pod
+func (r *ReceptorService) GetPod() string {
+	if r.config == nil {
+		return ""
+	}
+	return r.config.PodID
+}
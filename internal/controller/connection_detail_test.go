@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+)
+
+func TestReceptorServiceConnectionDetail(t *testing.T) {
+	cfg := config.GetConfig()
+
+	before := time.Now()
+	receptor := newTestReceptorService(cfg, newTestTransport(false))
+	defer receptor.Close(context.TODO())
+
+	connectedAt, err := receptor.GetConnectedAt(context.TODO())
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	if connectedAt.Before(before) || connectedAt.After(time.Now()) {
+		t.Fatalf("Expected the connection time to be the time of registration, got %s", connectedAt)
+	}
+
+	if err := receptor.UpdateCapabilities(map[string]interface{}{"max_work_threads": 12}); err != nil {
+		t.Fatalf("Unable to update the capabilities: %v", err)
+	}
+
+	metadata, err := receptor.GetMetadata(context.TODO())
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	if _, exists := metadata.(map[string]interface{})["capabilities"]; exists == false {
+		t.Fatalf("Expected the metadata to include the updated capabilities, got %+v", metadata)
+	}
+}
+
+func TestCaptureLabels(t *testing.T) {
+	header := http.Header{}
+	header.Add("X-Site", "raleigh")
+	header.Add("X-Site", "brno")
+	header.Add("X-Rack", "r12")
+	header.Add("Authorization", "secret")
+
+	labels := CaptureLabels(header, []string{"x-site", "X-Rack", "X-Missing"})
+
+	expected := map[string]string{"x-site": "raleigh", "x-rack": "r12"}
+	if !reflect.DeepEqual(labels, expected) {
+		t.Fatalf("Expected labels %+v, got %+v", expected, labels)
+	}
+}
+
+func TestReceptorServiceLabels(t *testing.T) {
+	cfg := config.GetConfig()
+
+	receptor := newTestReceptorService(cfg, newTestTransport(false))
+	defer receptor.Close(context.TODO())
+
+	receptor.SetLabels(map[string]string{"x-site": "raleigh"})
+
+	labels, err := receptor.GetLabels(context.TODO())
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	if labels["x-site"] != "raleigh" {
+		t.Fatalf("Expected the captured labels, got %+v", labels)
+	}
+}
@@ -0,0 +1,226 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WebhookRetryPolicy controls how many times the delivery of a connection
+// event to the webhook is attempted, and how long to wait between the attempts
+type WebhookRetryPolicy struct {
+	MaxAttempts    int
+	BackoffMin     time.Duration
+	BackoffMax     time.Duration
+	AttemptTimeout time.Duration
+}
+
+// backoff returns the time to wait after the given failed attempt (starting
+// at 1).  The wait doubles after each attempt, from BackoffMin up to
+// BackoffMax.
+func (p WebhookRetryPolicy) backoff(attempt int) time.Duration {
+	wait := p.BackoffMin
+	for i := 1; i < attempt && wait < p.BackoffMax; i++ {
+		wait *= 2
+	}
+
+	if p.BackoffMax > 0 && wait > p.BackoffMax {
+		wait = p.BackoffMax
+	}
+	return wait
+}
+
+// ConnectionEventDeadLetterSink receives the connection events that could not
+// be delivered to the webhook
+type ConnectionEventDeadLetterSink interface {
+	DeadLetter(event ConnectionEvent, err error)
+}
+
+type deadLetterLogger struct{}
+
+func (deadLetterLogger) DeadLetter(event ConnectionEvent, err error) {
+	logger.Log.WithFields(logrus.Fields{
+		"type":      event.Type,
+		"account":   event.Account,
+		"node_id":   event.NodeID,
+		"timestamp": event.Timestamp,
+		"error":     err,
+	}).Error("Unable to deliver the connection event to the webhook")
+}
+
+// ConnectionEventWebhook posts the connection events published by a broker to
+// a webhook.  The events of an account are delivered one at a time, in the
+// order they were published, so that a consumer never sees a disconnected
+// event before the connected event that preceded it.  The events of different
+// accounts are delivered concurrently.
+type ConnectionEventWebhook struct {
+	url        string
+	client     *http.Client
+	policy     WebhookRetryPolicy
+	queueSize  int
+	deadLetter ConnectionEventDeadLetterSink
+
+	// queues holds the events waiting to be delivered by account.  An account
+	// has an entry while its events are being delivered.
+	queues map[string][]ConnectionEvent
+	sync.Mutex
+}
+
+func NewConnectionEventWebhook(cfg *config.Config) *ConnectionEventWebhook {
+	return &ConnectionEventWebhook{
+		url:    cfg.ConnectionEventsWebhookUrl,
+		client: &http.Client{},
+		policy: WebhookRetryPolicy{
+			MaxAttempts:    cfg.ConnectionEventsWebhookMaxAttempts,
+			BackoffMin:     cfg.ConnectionEventsWebhookBackoffMin,
+			BackoffMax:     cfg.ConnectionEventsWebhookBackoffMax,
+			AttemptTimeout: cfg.ConnectionEventsWebhookAttemptTimeout,
+		},
+		queueSize:  cfg.ConnectionEventsWebhookQueueSize,
+		deadLetter: deadLetterLogger{},
+		queues:     make(map[string][]ConnectionEvent),
+	}
+}
+
+// SetDeadLetterSink replaces the sink of the undeliverable events, which logs
+// them by default
+func (w *ConnectionEventWebhook) SetDeadLetterSink(sink ConnectionEventDeadLetterSink) {
+	w.deadLetter = sink
+}
+
+// Run delivers the connection events published by the broker until the
+// context is cancelled
+func (w *ConnectionEventWebhook) Run(ctx context.Context, broker *ConnectionEventBroker) {
+	subscription := broker.Subscribe("")
+
+	for {
+		select {
+		case <-ctx.Done():
+			broker.Unsubscribe(subscription)
+			return
+		case event, ok := <-subscription.Events:
+			if !ok {
+				// The events are only queued here, so this should not
+				// happen unless the broker buffer is tiny
+				logger.Log.Warn("The connection event webhook fell behind the broker, resubscribing")
+				subscription = broker.Subscribe("")
+				continue
+			}
+			w.enqueue(ctx, event)
+		}
+	}
+}
+
+func (w *ConnectionEventWebhook) enqueue(ctx context.Context, event ConnectionEvent) {
+	w.Lock()
+	defer w.Unlock()
+
+	queue, delivering := w.queues[event.Account]
+	if w.queueSize > 0 && len(queue) >= w.queueSize {
+		w.deadLetterEvent(event, fmt.Errorf("too many events waiting to be delivered for the account"))
+		return
+	}
+
+	w.queues[event.Account] = append(queue, event)
+
+	if !delivering {
+		go w.deliverAccountEvents(ctx, event.Account)
+	}
+}
+
+// deliverAccountEvents delivers the queued events of the account until its
+// queue is empty
+func (w *ConnectionEventWebhook) deliverAccountEvents(ctx context.Context, account string) {
+	for {
+		w.Lock()
+		queue := w.queues[account]
+		if len(queue) == 0 || ctx.Err() != nil {
+			delete(w.queues, account)
+			w.Unlock()
+			return
+		}
+		event := queue[0]
+		w.queues[account] = queue[1:]
+		w.Unlock()
+
+		if err := w.deliver(ctx, event); err != nil {
+			w.deadLetterEvent(event, err)
+		}
+	}
+}
+
+// deliver posts the event to the webhook, retrying with backoff until the
+// webhook accepts it or the attempts are exhausted
+func (w *ConnectionEventWebhook) deliver(ctx context.Context, event ConnectionEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	maxAttempts := w.policy.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = w.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+
+		logger.Log.WithFields(logrus.Fields{"account": event.Account, "node_id": event.NodeID,
+			"attempt": attempt, "error": err}).Debug("Unable to deliver the connection event to the webhook")
+
+		if attempt >= maxAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
+		timer := time.NewTimer(w.policy.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (w *ConnectionEventWebhook) post(ctx context.Context, body []byte) error {
+	if w.policy.AttemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.policy.AttemptTimeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("the webhook responded with %s", resp.Status)
+	}
+
+	return nil
+}
+
+func (w *ConnectionEventWebhook) deadLetterEvent(event ConnectionEvent, err error) {
+	metrics.connectionEventWebhookDeadLetterCounter.Inc()
+	w.deadLetter.DeadLetter(event, err)
+}
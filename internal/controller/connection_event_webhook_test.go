@@ -0,0 +1,248 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+)
+
+// flakyWebhook fails the first failures attempts to deliver each event
+type flakyWebhook struct {
+	failures int
+	delay    time.Duration
+
+	lock      sync.Mutex
+	attempts  map[string][]time.Time
+	delivered map[string][]ConnectionEvent
+}
+
+func newFlakyWebhook(failures int) *flakyWebhook {
+	return &flakyWebhook{
+		failures:  failures,
+		attempts:  make(map[string][]time.Time),
+		delivered: make(map[string][]ConnectionEvent),
+	}
+}
+
+func eventKey(event ConnectionEvent) string {
+	return event.Type + "/" + event.Account + "/" + event.NodeID
+}
+
+func (f *flakyWebhook) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var event ConnectionEvent
+	if err := json.NewDecoder(req.Body).Decode(&event); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	f.lock.Lock()
+	key := eventKey(event)
+	f.attempts[key] = append(f.attempts[key], time.Now())
+	attempt := len(f.attempts[key])
+	f.lock.Unlock()
+
+	if attempt <= f.failures {
+		if f.delay > 0 {
+			time.Sleep(f.delay)
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	f.lock.Lock()
+	f.delivered[event.Account] = append(f.delivered[event.Account], event)
+	f.lock.Unlock()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (f *flakyWebhook) getAttempts(event ConnectionEvent) []time.Time {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]time.Time{}, f.attempts[eventKey(event)]...)
+}
+
+func (f *flakyWebhook) getDelivered(account string) []ConnectionEvent {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]ConnectionEvent{}, f.delivered[account]...)
+}
+
+type fakeDeadLetterSink struct {
+	lock   sync.Mutex
+	events []ConnectionEvent
+}
+
+func (s *fakeDeadLetterSink) DeadLetter(event ConnectionEvent, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.events = append(s.events, event)
+}
+
+func (s *fakeDeadLetterSink) getEvents() []ConnectionEvent {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]ConnectionEvent{}, s.events...)
+}
+
+func startTestWebhook(t *testing.T, handler http.Handler, policy WebhookRetryPolicy) (*ConnectionEventBroker, *fakeDeadLetterSink, func()) {
+	server := httptest.NewServer(handler)
+
+	cfg := config.GetConfig()
+	cfg.ConnectionEventsWebhookUrl = server.URL
+	cfg.ConnectionEventsWebhookMaxAttempts = policy.MaxAttempts
+	cfg.ConnectionEventsWebhookBackoffMin = policy.BackoffMin
+	cfg.ConnectionEventsWebhookBackoffMax = policy.BackoffMax
+	cfg.ConnectionEventsWebhookAttemptTimeout = policy.AttemptTimeout
+
+	deadLetters := &fakeDeadLetterSink{}
+	webhook := NewConnectionEventWebhook(cfg)
+	webhook.SetDeadLetterSink(deadLetters)
+
+	broker := NewConnectionEventBroker(100, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	go webhook.Run(ctx, broker)
+
+	waitFor(t, func() bool {
+		broker.Lock()
+		defer broker.Unlock()
+		return len(broker.subscriptions) == 1
+	})
+
+	return broker, deadLetters, func() {
+		cancel()
+		server.Close()
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestConnectionEventWebhookRetriesWithBackoff(t *testing.T) {
+	webhook := newFlakyWebhook(2)
+	broker, deadLetters, stop := startTestWebhook(t, webhook,
+		WebhookRetryPolicy{MaxAttempts: 5, BackoffMin: 20 * time.Millisecond, BackoffMax: time.Second})
+	defer stop()
+
+	event := ConnectionEvent{Type: CONNECTION_EVENT_CONNECTED, Account: "01", NodeID: "node-a", Timestamp: time.Now().UTC()}
+	broker.Publish(event)
+
+	waitFor(t, func() bool { return len(webhook.getDelivered("01")) == 1 })
+
+	attempts := webhook.getAttempts(event)
+	if len(attempts) != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", len(attempts))
+	}
+
+	if wait := attempts[1].Sub(attempts[0]); wait < 20*time.Millisecond {
+		t.Fatalf("Expected to wait at least 20ms before the second attempt, waited %s", wait)
+	}
+
+	if wait := attempts[2].Sub(attempts[1]); wait < 40*time.Millisecond {
+		t.Fatalf("Expected the backoff to double before the third attempt, waited %s", wait)
+	}
+
+	if len(deadLetters.getEvents()) != 0 {
+		t.Fatalf("Expected the event not to be dead lettered")
+	}
+}
+
+func TestConnectionEventWebhookPreservesTheOrderPerAccount(t *testing.T) {
+	webhook := newFlakyWebhook(1)
+	broker, _, stop := startTestWebhook(t, webhook,
+		WebhookRetryPolicy{MaxAttempts: 5, BackoffMin: time.Millisecond, BackoffMax: 5 * time.Millisecond})
+	defer stop()
+
+	published := map[string][]ConnectionEvent{}
+	for _, nodeID := range []string{"node-a", "node-b", "node-c", "node-d"} {
+		for _, eventType := range []string{CONNECTION_EVENT_CONNECTED, CONNECTION_EVENT_DISCONNECTED} {
+			for _, account := range []string{"01", "02"} {
+				event := ConnectionEvent{Type: eventType, Account: account, NodeID: nodeID, Timestamp: time.Now().UTC()}
+				published[account] = append(published[account], event)
+				broker.Publish(event)
+			}
+		}
+	}
+
+	for account, expected := range published {
+		waitFor(t, func() bool { return len(webhook.getDelivered(account)) == len(expected) })
+
+		for i, event := range webhook.getDelivered(account) {
+			if event.Type != expected[i].Type || event.NodeID != expected[i].NodeID {
+				t.Fatalf("Expected the event %d of account %s to be %+v, got %+v", i, account, expected[i], event)
+			}
+		}
+	}
+}
+
+func TestConnectionEventWebhookDeadLettersAfterTheLastAttempt(t *testing.T) {
+	webhook := newFlakyWebhook(1000)
+	broker, deadLetters, stop := startTestWebhook(t, webhook,
+		WebhookRetryPolicy{MaxAttempts: 3, BackoffMin: time.Millisecond, BackoffMax: 5 * time.Millisecond})
+	defer stop()
+
+	event := ConnectionEvent{Type: CONNECTION_EVENT_DISCONNECTED, Account: "01", NodeID: "node-a", Timestamp: time.Now().UTC()}
+	broker.Publish(event)
+
+	waitFor(t, func() bool { return len(deadLetters.getEvents()) == 1 })
+
+	if attempts := webhook.getAttempts(event); len(attempts) != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", len(attempts))
+	}
+
+	if deadLetter := deadLetters.getEvents()[0]; eventKey(deadLetter) != eventKey(event) {
+		t.Fatalf("Expected %+v to be dead lettered, got %+v", event, deadLetter)
+	}
+}
+
+func TestConnectionEventWebhookTimesOutSlowAttempts(t *testing.T) {
+	webhook := newFlakyWebhook(1)
+	webhook.delay = 500 * time.Millisecond
+	broker, deadLetters, stop := startTestWebhook(t, webhook,
+		WebhookRetryPolicy{MaxAttempts: 2, BackoffMin: time.Millisecond, BackoffMax: time.Millisecond,
+			AttemptTimeout: 50 * time.Millisecond})
+	defer stop()
+
+	event := ConnectionEvent{Type: CONNECTION_EVENT_CONNECTED, Account: "01", NodeID: "node-a", Timestamp: time.Now().UTC()}
+	broker.Publish(event)
+
+	waitFor(t, func() bool { return len(webhook.getDelivered("01")) == 1 })
+
+	attempts := webhook.getAttempts(event)
+	if len(attempts) != 2 {
+		t.Fatalf("Expected 2 attempts, got %d", len(attempts))
+	}
+
+	if wait := attempts[1].Sub(attempts[0]); wait >= webhook.delay {
+		t.Fatalf("Expected the slow attempt to be abandoned, the retry came after %s", wait)
+	}
+
+	if len(deadLetters.getEvents()) != 0 {
+		t.Fatalf("Expected the event not to be dead lettered")
+	}
+}
+
+func TestWebhookRetryPolicyBackoff(t *testing.T) {
+	policy := WebhookRetryPolicy{BackoffMin: 100 * time.Millisecond, BackoffMax: time.Second}
+
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond,
+		800 * time.Millisecond, time.Second, time.Second}
+	for i, e := range expected {
+		if backoff := policy.backoff(i + 1); backoff != e {
+			t.Errorf("Expected the backoff after attempt %d to be %s, got %s", i+1, e, backoff)
+		}
+	}
+}
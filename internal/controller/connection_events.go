@@ -0,0 +1,241 @@
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	CONNECTION_EVENT_CONNECTED    = "connected"
+	CONNECTION_EVENT_DISCONNECTED = "disconnected"
+
+	CONNECTION_EVENT_CAPABILITIES_UPDATED = "capabilities_updated"
+	CONNECTION_EVENT_METADATA_UPDATED     = "metadata_updated"
+
+	CONNECTION_EVENT_QUOTA_WARNING = "quota_warning"
+)
+
+type ConnectionEvent struct {
+	Type      string    `json:"type"`
+	Account   string    `json:"account"`
+	NodeID    string    `json:"node_id"`
+	Timestamp time.Time `json:"timestamp"`
+
+	// Reason is only set on disconnected events
+	Reason DisconnectReason `json:"reason,omitempty"`
+
+	// Connections, SoftLimit and Limit are only set on quota warning events
+	Connections int `json:"connections,omitempty"`
+	SoftLimit   int `json:"soft_limit,omitempty"`
+	Limit       int `json:"limit,omitempty"`
+}
+
+// ConnectionEventSubscription receives the connection events published after
+// the subscription was created.  The Events channel is closed when the
+// subscription is cancelled or when the subscriber falls too far behind.
+type ConnectionEventSubscription struct {
+	Events  <-chan ConnectionEvent
+	account string
+	events  chan ConnectionEvent
+}
+
+func (s *ConnectionEventSubscription) wants(event ConnectionEvent) bool {
+	return s.account == "" || s.account == event.Account
+}
+
+// ConnectionEventBroker fans out connection events to its subscribers.  Each
+// subscriber has a bounded buffer.  A subscriber whose buffer is full is
+// dropped so that a slow subscriber cannot block the registration of
+// connections.
+//
+// The most recent events are also kept in a ring buffer so that subscribers
+// that missed events can catch up.
+type ConnectionEventBroker struct {
+	subscriptions map[*ConnectionEventSubscription]struct{}
+	bufferSize    int
+
+	history      []ConnectionEvent
+	historyStart int
+	historySize  int
+
+	sync.Mutex
+}
+
+func NewConnectionEventBroker(bufferSize int, historySize int) *ConnectionEventBroker {
+	if historySize < 0 {
+		historySize = 0
+	}
+
+	return &ConnectionEventBroker{
+		subscriptions: make(map[*ConnectionEventSubscription]struct{}),
+		bufferSize:    bufferSize,
+		history:       make([]ConnectionEvent, 0, historySize),
+		historySize:   historySize,
+	}
+}
+
+// Subscribe creates a subscription for the events of the account.  An empty
+// account subscribes to the events of all accounts.
+func (b *ConnectionEventBroker) Subscribe(account string) *ConnectionEventSubscription {
+	events := make(chan ConnectionEvent, b.bufferSize)
+	subscription := &ConnectionEventSubscription{Events: events, account: account, events: events}
+
+	b.Lock()
+	defer b.Unlock()
+	b.subscriptions[subscription] = struct{}{}
+	metrics.connectionEventSubscribersGauge.Inc()
+
+	return subscription
+}
+
+func (b *ConnectionEventBroker) Unsubscribe(subscription *ConnectionEventSubscription) {
+	b.Lock()
+	defer b.Unlock()
+	b.removeSubscription(subscription)
+}
+
+func (b *ConnectionEventBroker) Publish(event ConnectionEvent) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.recordEvent(event)
+
+	for subscription := range b.subscriptions {
+		if subscription.wants(event) == false {
+			continue
+		}
+
+		select {
+		case subscription.events <- event:
+		default:
+			logger.Log.WithFields(logrus.Fields{"account": subscription.account}).Warn("Dropping slow connection event subscriber")
+			metrics.connectionEventSubscribersDroppedCounter.Inc()
+			b.removeSubscription(subscription)
+		}
+	}
+}
+
+// recordEvent adds the event to the ring buffer, overwriting the oldest event
+// once the buffer is full
+func (b *ConnectionEventBroker) recordEvent(event ConnectionEvent) {
+	if b.historySize == 0 {
+		return
+	}
+
+	if len(b.history) < b.historySize {
+		b.history = append(b.history, event)
+		return
+	}
+
+	b.history[b.historyStart] = event
+	b.historyStart = (b.historyStart + 1) % b.historySize
+}
+
+// RecentEvents returns, oldest first, up to limit of the retained events of
+// the account that were published after since.  An empty account returns the
+// events of all accounts.  A limit of 0 returns all the matching events.
+func (b *ConnectionEventBroker) RecentEvents(account string, since time.Time, limit int) []ConnectionEvent {
+	b.Lock()
+	defer b.Unlock()
+
+	events := make([]ConnectionEvent, 0)
+	for i := 0; i < len(b.history); i++ {
+		event := b.history[(b.historyStart+i)%len(b.history)]
+
+		if (account != "" && account != event.Account) || !event.Timestamp.After(since) {
+			continue
+		}
+
+		events = append(events, event)
+		if limit > 0 && len(events) == limit {
+			break
+		}
+	}
+
+	return events
+}
+
+func (b *ConnectionEventBroker) removeSubscription(subscription *ConnectionEventSubscription) {
+	if _, exists := b.subscriptions[subscription]; exists == false {
+		return
+	}
+	delete(b.subscriptions, subscription)
+	close(subscription.events)
+	metrics.connectionEventSubscribersGauge.Dec()
+}
+
+// CapabilitiesUpdateListener is implemented by connection registrars that want
+// to know when a registered node pushes updated capabilities
+type CapabilitiesUpdateListener interface {
+	CapabilitiesUpdated(account string, nodeID string)
+}
+
+// MetadataUpdateListener is implemented by connection registrars that want to
+// know when a registered node pushes updated metadata
+type MetadataUpdateListener interface {
+	MetadataUpdated(account string, nodeID string)
+}
+
+// ReasonedConnectionUnregistrar is implemented by connection registrars that
+// want to know why a connection went away
+type ReasonedConnectionUnregistrar interface {
+	UnregisterWithReason(account string, nodeID string, reason DisconnectReason)
+}
+
+// EventPublishingConnectionRegistrar publishes a connection event each time a
+// connection is registered or unregistered with the wrapped registrar, and
+// each time a registered node pushes updated capabilities or metadata
+type EventPublishingConnectionRegistrar struct {
+	registrar ConnectionRegistrar
+	events    *ConnectionEventBroker
+}
+
+func NewEventPublishingConnectionRegistrar(registrar ConnectionRegistrar, events *ConnectionEventBroker) ConnectionRegistrar {
+	return &EventPublishingConnectionRegistrar{
+		registrar: registrar,
+		events:    events,
+	}
+}
+
+func (r *EventPublishingConnectionRegistrar) Register(account string, nodeID string, client Receptor) error {
+	if err := r.registrar.Register(account, nodeID, client); err != nil {
+		return err
+	}
+
+	r.events.Publish(ConnectionEvent{Type: CONNECTION_EVENT_CONNECTED, Account: account, NodeID: nodeID, Timestamp: time.Now().UTC()})
+	return nil
+}
+
+func (r *EventPublishingConnectionRegistrar) Unregister(account string, nodeID string) {
+	r.UnregisterWithReason(account, nodeID, "")
+}
+
+func (r *EventPublishingConnectionRegistrar) UnregisterWithReason(account string, nodeID string, reason DisconnectReason) {
+	r.registrar.Unregister(account, nodeID)
+
+	r.events.Publish(ConnectionEvent{Type: CONNECTION_EVENT_DISCONNECTED, Account: account, NodeID: nodeID, Timestamp: time.Now().UTC(), Reason: reason})
+}
+
+func (r *EventPublishingConnectionRegistrar) CapabilitiesUpdated(account string, nodeID string) {
+	r.events.Publish(ConnectionEvent{Type: CONNECTION_EVENT_CAPABILITIES_UPDATED, Account: account, NodeID: nodeID, Timestamp: time.Now().UTC()})
+}
+
+func (r *EventPublishingConnectionRegistrar) MetadataUpdated(account string, nodeID string) {
+	r.events.Publish(ConnectionEvent{Type: CONNECTION_EVENT_METADATA_UPDATED, Account: account, NodeID: nodeID, Timestamp: time.Now().UTC()})
+}
+
+func (r *EventPublishingConnectionRegistrar) ConnectionQuotaWarning(account string, nodeID string, connections int, softLimit int, limit int) {
+	r.events.Publish(ConnectionEvent{
+		Type:        CONNECTION_EVENT_QUOTA_WARNING,
+		Account:     account,
+		NodeID:      nodeID,
+		Timestamp:   time.Now().UTC(),
+		Connections: connections,
+		SoftLimit:   softLimit,
+		Limit:       limit,
+	})
+}
@@ -0,0 +1,230 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+)
+
+func TestConnectionEventsArePublishedOnRegistration(t *testing.T) {
+	broker := NewConnectionEventBroker(10, 0)
+	allEvents := broker.Subscribe("")
+	accountEvents := broker.Subscribe("123")
+	defer broker.Unsubscribe(allEvents)
+	defer broker.Unsubscribe(accountEvents)
+
+	registrar := NewEventPublishingConnectionRegistrar(NewLocalConnectionManager(), broker)
+	registrar.Register("123", "node-a", &MockReceptor{})
+	registrar.Register("456", "node-b", &MockReceptor{})
+	registrar.Unregister("123", "node-a")
+
+	expected := []ConnectionEvent{
+		{Type: CONNECTION_EVENT_CONNECTED, Account: "123", NodeID: "node-a"},
+		{Type: CONNECTION_EVENT_CONNECTED, Account: "456", NodeID: "node-b"},
+		{Type: CONNECTION_EVENT_DISCONNECTED, Account: "123", NodeID: "node-a"},
+	}
+
+	for _, e := range expected {
+		event := <-allEvents.Events
+		if event.Type != e.Type || event.Account != e.Account || event.NodeID != e.NodeID {
+			t.Fatalf("Expected %+v, got %+v", e, event)
+		}
+	}
+
+	for _, e := range []ConnectionEvent{expected[0], expected[2]} {
+		event := <-accountEvents.Events
+		if event.Type != e.Type || event.Account != e.Account || event.NodeID != e.NodeID {
+			t.Fatalf("Expected %+v, got %+v", e, event)
+		}
+	}
+
+	if len(accountEvents.Events) != 0 {
+		t.Fatalf("Expected the events of other accounts to be filtered out")
+	}
+}
+
+func TestConnectionEventsAreNotPublishedForRejectedRegistration(t *testing.T) {
+	broker := NewConnectionEventBroker(10, 0)
+	events := broker.Subscribe("")
+	defer broker.Unsubscribe(events)
+
+	registrar := NewEventPublishingConnectionRegistrar(NewLocalConnectionManager(), broker)
+	registrar.Register("123", "node-a", &MockReceptor{})
+	if err := registrar.Register("123", "node-a", &MockReceptor{}); err != (DuplicateConnectionError{}) {
+		t.Fatalf("Expected %v, got %v", DuplicateConnectionError{}, err)
+	}
+
+	if len(events.Events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(events.Events))
+	}
+}
+
+func TestConnectionQuotaWarningEventIsPublished(t *testing.T) {
+	broker := NewConnectionEventBroker(10, 0)
+	events := broker.Subscribe("")
+	defer broker.Unsubscribe(events)
+
+	cm := NewLocalConnectionManagerWithConnectionLimit(ConnectionLimit{Default: 2, SoftPercent: 50})
+	registrar := NewEventPublishingConnectionRegistrar(cm, broker)
+	cm.SetConnectionQuotaWarningListener(registrar.(ConnectionQuotaWarningListener))
+
+	registrar.Register("123", "node-a", &MockReceptor{})
+	registrar.Register("123", "node-b", &MockReceptor{})
+	if err := registrar.Register("123", "node-c", &MockReceptor{}); err != (TooManyConnectionsError{}) {
+		t.Fatalf("Expected %v, got %v", TooManyConnectionsError{}, err)
+	}
+
+	expected := []ConnectionEvent{
+		{Type: CONNECTION_EVENT_QUOTA_WARNING, Account: "123", NodeID: "node-a", Connections: 1, SoftLimit: 1, Limit: 2},
+		{Type: CONNECTION_EVENT_CONNECTED, Account: "123", NodeID: "node-a"},
+		{Type: CONNECTION_EVENT_CONNECTED, Account: "123", NodeID: "node-b"},
+	}
+
+	for _, e := range expected {
+		event := <-events.Events
+		event.Timestamp = e.Timestamp
+		if event != e {
+			t.Fatalf("Expected %+v, got %+v", e, event)
+		}
+	}
+
+	if len(events.Events) != 0 {
+		t.Fatalf("Expected no more events, got %d", len(events.Events))
+	}
+}
+
+func TestSlowConnectionEventSubscriberIsDropped(t *testing.T) {
+	broker := NewConnectionEventBroker(1, 0)
+	slow := broker.Subscribe("")
+
+	broker.Publish(ConnectionEvent{Type: CONNECTION_EVENT_CONNECTED, Account: "123", NodeID: "node-a"})
+	broker.Publish(ConnectionEvent{Type: CONNECTION_EVENT_DISCONNECTED, Account: "123", NodeID: "node-a"})
+
+	if event, ok := <-slow.Events; !ok || event.Type != CONNECTION_EVENT_CONNECTED {
+		t.Fatalf("Expected the buffered event to be delivered, got %+v", event)
+	}
+
+	if _, ok := <-slow.Events; ok {
+		t.Fatalf("Expected the slow subscriber to be dropped")
+	}
+
+	// Unsubscribing a dropped subscriber should be safe
+	broker.Unsubscribe(slow)
+}
+
+func TestDisconnectedEventIncludesTheDisconnectReason(t *testing.T) {
+	reasons := []DisconnectReason{
+		DISCONNECT_REASON_ADMIN,
+		DISCONNECT_REASON_NETWORK,
+		DISCONNECT_REASON_POLICY,
+		DISCONNECT_REASON_IDLE_TIMEOUT,
+		DISCONNECT_REASON_SHUTDOWN,
+	}
+
+	for _, reason := range reasons {
+		broker := NewConnectionEventBroker(10, 0)
+		events := broker.Subscribe("")
+
+		registrar := NewEventPublishingConnectionRegistrar(NewLocalConnectionManager(), broker)
+		registrar.Register("123", "node-a", &MockReceptor{})
+
+		disconnectReason := reason
+		disconnectHandler := DisconnectHandler{
+			AccountNumber: "123",
+			NodeID:        "node-a",
+			ConnectionMgr: registrar,
+			Logger:        logger.Log.WithFields(logrus.Fields{}),
+			Transport:     &Transport{DisconnectReason: func() DisconnectReason { return disconnectReason }},
+		}
+		disconnectHandler.HandleMessage(context.TODO(), nil)
+
+		<-events.Events
+		event := <-events.Events
+		if event.Type != CONNECTION_EVENT_DISCONNECTED || event.Reason != reason {
+			t.Fatalf("Expected a disconnected event with reason %s, got %+v", reason, event)
+		}
+
+		broker.Unsubscribe(events)
+	}
+}
+
+func TestDisconnectedEventWithoutTransportHasNoReason(t *testing.T) {
+	broker := NewConnectionEventBroker(10, 0)
+	events := broker.Subscribe("")
+	defer broker.Unsubscribe(events)
+
+	registrar := NewEventPublishingConnectionRegistrar(NewLocalConnectionManager(), broker)
+	registrar.Register("123", "node-a", &MockReceptor{})
+
+	disconnectHandler := DisconnectHandler{
+		AccountNumber: "123",
+		NodeID:        "node-a",
+		ConnectionMgr: registrar,
+		Logger:        logger.Log.WithFields(logrus.Fields{}),
+	}
+	disconnectHandler.HandleMessage(context.TODO(), nil)
+
+	<-events.Events
+	event := <-events.Events
+	if event.Type != CONNECTION_EVENT_DISCONNECTED || event.Reason != "" {
+		t.Fatalf("Expected a disconnected event without a reason, got %+v", event)
+	}
+}
+
+func TestRecentConnectionEvents(t *testing.T) {
+	broker := NewConnectionEventBroker(10, 3)
+
+	start := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i, nodeID := range []string{"node-a", "node-b", "node-c", "node-d", "node-e"} {
+		account := "123"
+		if nodeID == "node-d" {
+			account = "456"
+		}
+		broker.Publish(ConnectionEvent{Type: CONNECTION_EVENT_CONNECTED, Account: account, NodeID: nodeID,
+			Timestamp: start.Add(time.Duration(i) * time.Minute)})
+	}
+
+	nodeIDs := func(events []ConnectionEvent) []string {
+		ids := make([]string, len(events))
+		for i, event := range events {
+			ids[i] = event.NodeID
+		}
+		return ids
+	}
+
+	testCases := []struct {
+		account  string
+		since    time.Time
+		limit    int
+		expected []string
+	}{
+		// The oldest events have been overwritten
+		{"", time.Time{}, 0, []string{"node-c", "node-d", "node-e"}},
+		{"", time.Time{}, 2, []string{"node-c", "node-d"}},
+		{"", start.Add(2 * time.Minute), 0, []string{"node-d", "node-e"}},
+		{"", start.Add(4 * time.Minute), 0, []string{}},
+		{"123", time.Time{}, 0, []string{"node-c", "node-e"}},
+		{"123", start.Add(2 * time.Minute), 1, []string{"node-e"}},
+	}
+
+	for _, tc := range testCases {
+		actual := nodeIDs(broker.RecentEvents(tc.account, tc.since, tc.limit))
+		if cmp.Equal(tc.expected, actual) != true {
+			t.Fatalf("Expected %v for account %q since %v (limit %d), got %v", tc.expected, tc.account, tc.since, tc.limit, actual)
+		}
+	}
+}
+
+func TestRecentConnectionEventsAreNotRetainedWithoutHistory(t *testing.T) {
+	broker := NewConnectionEventBroker(10, 0)
+	broker.Publish(ConnectionEvent{Type: CONNECTION_EVENT_CONNECTED, Account: "123", NodeID: "node-a", Timestamp: time.Now()})
+
+	if events := broker.RecentEvents("", time.Time{}, 0); len(events) != 0 {
+		t.Fatalf("Expected no events to be retained, got %+v", events)
+	}
+}
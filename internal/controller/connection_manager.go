@@ -2,7 +2,12 @@ package controller
 
 import (
 	"context"
+	"hash/fnv"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
 
@@ -15,6 +20,8 @@ type Receptor interface {
 	Ping(context.Context, string, string, []string) (interface{}, error)
 	Close(context.Context) error
 	GetCapabilities(context.Context) (interface{}, error)
+	GetRoutingTable(context.Context) (*RoutingTable, error)
+	GetHealth(context.Context) (string, error)
 }
 
 type DuplicateConnectionError struct {
@@ -24,6 +31,56 @@ func (d DuplicateConnectionError) Error() string {
 	return "duplicate node id"
 }
 
+type TooManyConnectionsError struct {
+}
+
+func (t TooManyConnectionsError) Error() string {
+	return "too many connections"
+}
+
+type UnsupportedBackendError struct {
+}
+
+func (u UnsupportedBackendError) Error() string {
+	return "unsupported for this backend"
+}
+
+// ConnectionLimit is the maximum number of connections that an account is
+// allowed to register.  A limit of 0 means the account is not limited.
+//
+// The soft limit is a percentage of the (hard) limit.  A warning is raised when
+// the number of connections of an account reaches the soft limit, giving
+// operators a chance to act before connections start being rejected.  A soft
+// limit of 0 disables the warning.
+type ConnectionLimit struct {
+	Default  int
+	Override map[string]int
+
+	SoftPercent         int
+	SoftPercentOverride map[string]int
+}
+
+func (cl ConnectionLimit) forAccount(account string) int {
+	if limit, exists := cl.Override[account]; exists {
+		return limit
+	}
+	return cl.Default
+}
+
+func (cl ConnectionLimit) softLimitForAccount(account string) int {
+	percent := cl.SoftPercent
+	if override, exists := cl.SoftPercentOverride[account]; exists {
+		percent = override
+	}
+	return cl.forAccount(account) * percent / 100
+}
+
+// ConnectionQuotaWarningListener is notified when the number of connections
+// of an account reaches its soft limit
+type ConnectionQuotaWarningListener interface {
+	ConnectionQuotaWarning(account string, nodeID string, connections int, softLimit int, limit int)
+}
+
 type ConnectionRegistrar interface {
 	Register(account string, node_id string, client Receptor) error
 	Unregister(account string, node_id string)
@@ -33,68 +90,239 @@ type ConnectionLocator interface {
 	GetConnection(account string, node_id string) Receptor
 	GetConnectionsByAccount(account string) map[string]Receptor
 	GetAllConnections() map[string]map[string]Receptor
+	GetConnectionsByAccountPrefix(prefix string, offset int, limit int) (map[string]map[string]Receptor, int)
+}
+
+// ImportedConnection is a connection that is believed to exist on the given
+// pod, used to prime the connection lookup during recovery
+type ImportedConnection struct {
+	Account string
+	NodeID  string
+	Pod     string
+}
+
+// ConnectionImporter is implemented by connection locators that can be primed
+// with connections that have not (yet) been registered by a gateway pod
+type ConnectionImporter interface {
+	ImportConnections(connections []ImportedConnection) (int, error)
+}
+
+// ConnectionOwnershipChange records a pod taking over (or giving up) the
+// ownership of a connection
+type ConnectionOwnershipChange struct {
+	Pod       string    `json:"pod"`
+	Timestamp time.Time `json:"timestamp"`
+	Reason    string    `json:"reason"`
+}
+
+// ConnectionHistoryReporter is implemented by connection locators that keep
+// track of the pods that owned a connection.  The changes are returned oldest
+// first.
+type ConnectionHistoryReporter interface {
+	GetConnectionHistory(account string, nodeID string) ([]ConnectionOwnershipChange, error)
 }
 
+// ConnectionGenerationReporter is implemented by connection locators that
+// count the changes to their set of connections.  The generation is bumped
+// every time a connection is registered or unregistered, so an unchanged
+// generation means an unchanged set of connections.
+type ConnectionGenerationReporter interface {
+	GetConnectionGeneration() uint64
+}
+
+// LocalConnectionManager keeps track of the connections that are attached to
+// this pod.  The connections are partitioned into shards, each with its own
+// lock, to reduce lock contention at high connection counts.  The shard is
+// selected by a hash of the account (rather than of the account and node id)
+// so that the duplicate connection check and the per-account connection limit
+// only have to consult a single shard.
 type LocalConnectionManager struct {
+	// generation is accessed atomically and kept first for 64-bit alignment
+	generation uint64
+
+	shards          []*connectionShard
+	connectionLimit ConnectionLimit
+
+	quotaWarningListener ConnectionQuotaWarningListener
+}
+
+type connectionShard struct {
 	connections map[string]map[string]Receptor
 	sync.RWMutex
 }
 
 func NewLocalConnectionManager() *LocalConnectionManager {
+	return NewLocalConnectionManagerWithConnectionLimit(ConnectionLimit{})
+}
+
+func NewLocalConnectionManagerWithConnectionLimit(limit ConnectionLimit) *LocalConnectionManager {
+	return NewShardedLocalConnectionManager(limit, 1)
+}
+
+func NewShardedLocalConnectionManager(limit ConnectionLimit, shardCount int) *LocalConnectionManager {
+	if shardCount < 1 {
+		shardCount = 1
+	}
+
+	shards := make([]*connectionShard, shardCount)
+	for i := range shards {
+		shards[i] = &connectionShard{connections: make(map[string]map[string]Receptor)}
+	}
+
 	return &LocalConnectionManager{
-		connections: make(map[string]map[string]Receptor),
+		shards:          shards,
+		connectionLimit: limit,
+	}
+}
+
+// SetConnectionQuotaWarningListener registers the listener that is notified
+// when an account reaches its soft connection limit.  It must be called before
+// any connections are registered.
+func (cm *LocalConnectionManager) SetConnectionQuotaWarningListener(listener ConnectionQuotaWarningListener) {
+	cm.quotaWarningListener = listener
+}
+
+func (cm *LocalConnectionManager) shardFor(account string) *connectionShard {
+	if len(cm.shards) == 1 {
+		return cm.shards[0]
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(account))
+	return cm.shards[h.Sum32()%uint32(len(cm.shards))]
+}
+
+// rLockAllShards read locks every shard (always in the same order) so that the
+// connections of all of the shards can be read as a consistent snapshot
+func (cm *LocalConnectionManager) rLockAllShards() func() {
+	for _, shard := range cm.shards {
+		shard.RLock()
+	}
+
+	return func() {
+		for _, shard := range cm.shards {
+			shard.RUnlock()
+		}
 	}
 }
 
 func (cm *LocalConnectionManager) Register(account string, node_id string, client Receptor) error {
-	cm.Lock()
-	defer cm.Unlock()
-	_, exists := cm.connections[account]
+	shard := cm.shardFor(account)
+	shard.Lock()
+	defer shard.Unlock()
+	_, exists := shard.connections[account]
 	if exists == true { // checking connection locally
-		_, exists = cm.connections[account][node_id]
+		_, exists = shard.connections[account][node_id]
 		if exists == true {
 			logger := logger.Log.WithFields(logrus.Fields{"account": account, "node_id": node_id})
 			logger.Warn("Attempting to register duplicate connection")
 			metrics.duplicateConnectionCounter.Inc()
 			return DuplicateConnectionError{}
 		}
-		cm.connections[account][node_id] = client
+
+		if err := cm.checkConnectionLimit(account, len(shard.connections[account])); err != nil {
+			logger := logger.Log.WithFields(logrus.Fields{"account": account, "node_id": node_id})
+			logger.Warnf("Attempting to register more than %d connections for account", cm.connectionLimit.forAccount(account))
+			return err
+		}
+
+		shard.connections[account][node_id] = client
 	} else {
-		cm.connections[account] = make(map[string]Receptor)
-		cm.connections[account][node_id] = client
+		shard.connections[account] = make(map[string]Receptor)
+		shard.connections[account][node_id] = client
 	}
 
+	atomic.AddUint64(&cm.generation, 1)
+
 	logger.Log.Printf("Registered a connection (%s, %s)", account, node_id)
+
+	cm.checkSoftLimit(account, node_id, len(shard.connections[account]))
+
+	return nil
+}
+
+func (cm *LocalConnectionManager) GetConnectionGeneration() uint64 {
+	return atomic.LoadUint64(&cm.generation)
+}
+
+// CheckConnectionQuota returns a TooManyConnectionsError if the account has
+// reached its connection limit
+func (cm *LocalConnectionManager) CheckConnectionQuota(account string) error {
+	shard := cm.shardFor(account)
+	shard.RLock()
+	defer shard.RUnlock()
+
+	if err := cm.checkConnectionLimit(account, len(shard.connections[account])); err != nil {
+		logger := logger.Log.WithFields(logrus.Fields{"account": account})
+		logger.Warnf("Account has reached its limit of %d connections", cm.connectionLimit.forAccount(account))
+		return err
+	}
+
 	return nil
 }
 
+func (cm *LocalConnectionManager) checkConnectionLimit(account string, connections int) error {
+	limit := cm.connectionLimit.forAccount(account)
+	if limit > 0 && connections >= limit {
+		metrics.tooManyConnectionsCounter.Inc()
+		return TooManyConnectionsError{}
+	}
+	return nil
+}
+
+// checkSoftLimit raises a warning when the registration of a connection takes
+// the account to its soft connection limit
+func (cm *LocalConnectionManager) checkSoftLimit(account string, node_id string, connections int) {
+	softLimit := cm.connectionLimit.softLimitForAccount(account)
+	if softLimit <= 0 || connections != softLimit {
+		return
+	}
+
+	limit := cm.connectionLimit.forAccount(account)
+
+	logger := logger.Log.WithFields(logrus.Fields{"account": account, "node_id": node_id})
+	logger.Warnf("Account has reached its soft limit of %d connections (limit %d)", softLimit, limit)
+	metrics.connectionQuotaWarningCounter.Inc()
+
+	if cm.quotaWarningListener != nil {
+		cm.quotaWarningListener.ConnectionQuotaWarning(account, node_id, connections, softLimit, limit)
+	}
+}
+
 func (cm *LocalConnectionManager) Unregister(account string, node_id string) {
-	cm.Lock()
-	defer cm.Unlock()
-	_, exists := cm.connections[account]
+	shard := cm.shardFor(account)
+	shard.Lock()
+	defer shard.Unlock()
+	_, exists := shard.connections[account]
 	if exists == false {
 		return
 	}
-	delete(cm.connections[account], node_id)
+	if _, exists = shard.connections[account][node_id]; !exists {
+		return
+	}
+	delete(shard.connections[account], node_id)
 
-	if len(cm.connections[account]) == 0 {
-		delete(cm.connections, account)
+	if len(shard.connections[account]) == 0 {
+		delete(shard.connections, account)
 	}
 
+	atomic.AddUint64(&cm.generation, 1)
+
 	logger.Log.Printf("Unregistered a connection (%s, %s)", account, node_id)
 }
 
 func (cm *LocalConnectionManager) GetConnection(account string, node_id string) Receptor {
 	var conn Receptor
 
-	cm.RLock()
-	defer cm.RUnlock()
-	_, exists := cm.connections[account]
+	shard := cm.shardFor(account)
+	shard.RLock()
+	defer shard.RUnlock()
+	_, exists := shard.connections[account]
 	if exists == false {
 		return nil
 	}
 
-	conn, exists = cm.connections[account][node_id]
+	conn, exists = shard.connections[account][node_id]
 	if exists == false {
 		return nil
 	}
@@ -103,17 +331,18 @@ func (cm *LocalConnectionManager) GetConnection(account string, node_id string)
 }
 
 func (cm *LocalConnectionManager) GetConnectionsByAccount(account string) map[string]Receptor {
-	cm.RLock()
-	defer cm.RUnlock()
+	shard := cm.shardFor(account)
+	shard.RLock()
+	defer shard.RUnlock()
 
 	connectionsPerAccount := make(map[string]Receptor)
 
-	_, exists := cm.connections[account]
+	_, exists := shard.connections[account]
 	if exists == false {
 		return connectionsPerAccount
 	}
 
-	for k, v := range cm.connections[account] {
+	for k, v := range shard.connections[account] {
 		connectionsPerAccount[k] = v
 	}
 
@@ -121,17 +350,69 @@ func (cm *LocalConnectionManager) GetConnectionsByAccount(account string) map[st
 }
 
 func (cm *LocalConnectionManager) GetAllConnections() map[string]map[string]Receptor {
-	cm.RLock()
-	defer cm.RUnlock()
+	defer cm.rLockAllShards()()
+
+	connectionMap := make(map[string]map[string]Receptor)
+
+	for _, shard := range cm.shards {
+		for accountNumber, accountMap := range shard.connections {
+			connectionMap[accountNumber] = make(map[string]Receptor)
+			for nodeID, receptorObj := range accountMap {
+				connectionMap[accountNumber][nodeID] = receptorObj
+			}
+		}
+	}
+
+	return connectionMap
+}
+
+// GetConnectionsByAccountPrefix returns the connections of the accounts that
+// start with the prefix.  The matching accounts are sorted, and only the page
+// of accounts selected by offset / limit is returned along with the total
+// number of matching accounts.
+func (cm *LocalConnectionManager) GetConnectionsByAccountPrefix(prefix string, offset int, limit int) (map[string]map[string]Receptor, int) {
+	defer cm.rLockAllShards()()
+
+	var matchingAccounts []string
+	for _, shard := range cm.shards {
+		for accountNumber := range shard.connections {
+			if strings.HasPrefix(accountNumber, prefix) {
+				matchingAccounts = append(matchingAccounts, accountNumber)
+			}
+		}
+	}
 
 	connectionMap := make(map[string]map[string]Receptor)
 
-	for accountNumber, accountMap := range cm.connections {
+	for _, accountNumber := range PaginateAccounts(matchingAccounts, offset, limit) {
 		connectionMap[accountNumber] = make(map[string]Receptor)
-		for nodeID, receptorObj := range accountMap {
+		for nodeID, receptorObj := range cm.shardFor(accountNumber).connections[accountNumber] {
 			connectionMap[accountNumber][nodeID] = receptorObj
 		}
 	}
 
-	return connectionMap
+	return connectionMap, len(matchingAccounts)
+}
+
+// PaginateAccounts sorts the accounts and returns the accounts in the page
+// selected by offset / limit
+func PaginateAccounts(accounts []string, offset int, limit int) []string {
+	sort.Strings(accounts)
+
+	if offset >= len(accounts) {
+		return []string{}
+	}
+
+	end := offset + limit
+	if end > len(accounts) {
+		end = len(accounts)
+	}
+
+	return accounts[offset:end]
+}
+
+// ImportConnections is a no-op.  The local connection manager only knows about
+// the connections that are attached to this pod.
+func (cm *LocalConnectionManager) ImportConnections(connections []ImportedConnection) (int, error) {
+	return 0, UnsupportedBackendError{}
 }
@@ -2,12 +2,18 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync/atomic"
 	"testing"
 
 	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
 )
 
 func init() {
@@ -35,6 +41,14 @@ func (mr *MockReceptor) GetCapabilities(context.Context) (interface{}, error) {
 	return nil, nil
 }
 
+func (mr *MockReceptor) GetRoutingTable(context.Context) (*RoutingTable, error) {
+	return nil, nil
+}
+
+func (mr *MockReceptor) GetHealth(context.Context) (string, error) {
+	return HEALTHY_STATUS, nil
+}
+
 func TestCheckForLocalConnectionThatDoesNotExist(t *testing.T) {
 	var cl ConnectionLocator
 	cl = NewLocalConnectionManager()
@@ -191,3 +205,239 @@ func TestRegisterLocalConnectionsWithDuplicateNodeIDs(t *testing.T) {
 		t.Fatalf("Expected to find the connection that was registered first")
 	}
 }
+
+func TestRegisterLocalConnectionsBeyondConnectionLimit(t *testing.T) {
+	limitedAccount := "0000001"
+	overriddenAccount := "0000002"
+
+	cm := NewLocalConnectionManagerWithConnectionLimit(ConnectionLimit{
+		Default:  2,
+		Override: map[string]int{overriddenAccount: 1},
+	})
+
+	var testRegistrations = []struct {
+		account string
+		nodeID  string
+		err     error
+	}{
+		{limitedAccount, "node-a", nil},
+		{limitedAccount, "node-b", nil},
+		{limitedAccount, "node-c", TooManyConnectionsError{}},
+		{overriddenAccount, "node-a", nil},
+		{overriddenAccount, "node-b", TooManyConnectionsError{}},
+	}
+
+	for _, r := range testRegistrations {
+		err := cm.Register(r.account, r.nodeID, &MockReceptor{})
+		if err != r.err {
+			t.Fatalf("Registering (%s, %s) - expected: %v, got: %v", r.account, r.nodeID, r.err, err)
+		}
+	}
+
+	if cm.GetConnection(limitedAccount, "node-c") != nil {
+		t.Fatalf("Expected the connection beyond the limit to not be registered")
+	}
+
+	// Freeing up a slot should allow a new connection to be registered
+	cm.Unregister(limitedAccount, "node-a")
+	err := cm.Register(limitedAccount, "node-c", &MockReceptor{})
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+}
+
+type quotaWarning struct {
+	account     string
+	nodeID      string
+	connections int
+	softLimit   int
+	limit       int
+}
+
+type recordingQuotaWarningListener struct {
+	warnings []quotaWarning
+}
+
+func (l *recordingQuotaWarningListener) ConnectionQuotaWarning(account string, nodeID string, connections int, softLimit int, limit int) {
+	l.warnings = append(l.warnings, quotaWarning{account, nodeID, connections, softLimit, limit})
+}
+
+func TestRegisterLocalConnectionsReachingSoftLimit(t *testing.T) {
+	limitedAccount := "0000001"
+	overriddenAccount := "0000002"
+
+	cm := NewLocalConnectionManagerWithConnectionLimit(ConnectionLimit{
+		Default:             5,
+		SoftPercent:         60,
+		SoftPercentOverride: map[string]int{overriddenAccount: 0},
+	})
+	listener := &recordingQuotaWarningListener{}
+	cm.SetConnectionQuotaWarningListener(listener)
+
+	warningsBefore := testutil.ToFloat64(metrics.connectionQuotaWarningCounter)
+
+	var testRegistrations = []struct {
+		account string
+		nodeID  string
+		err     error
+	}{
+		{limitedAccount, "node-a", nil},
+		{limitedAccount, "node-b", nil},
+		{limitedAccount, "node-c", nil}, // soft limit
+		{limitedAccount, "node-d", nil},
+		{limitedAccount, "node-e", nil},
+		{limitedAccount, "node-f", TooManyConnectionsError{}}, // hard limit
+		{overriddenAccount, "node-a", nil},
+		{overriddenAccount, "node-b", nil},
+		{overriddenAccount, "node-c", nil},
+	}
+
+	for _, r := range testRegistrations {
+		err := cm.Register(r.account, r.nodeID, &MockReceptor{})
+		if err != r.err {
+			t.Fatalf("Registering (%s, %s) - expected: %v, got: %v", r.account, r.nodeID, r.err, err)
+		}
+	}
+
+	expected := []quotaWarning{{limitedAccount, "node-c", 3, 3, 5}}
+	if !reflect.DeepEqual(listener.warnings, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, listener.warnings)
+	}
+
+	if delta := testutil.ToFloat64(metrics.connectionQuotaWarningCounter) - warningsBefore; delta != 1 {
+		t.Fatalf("Expected 1 quota warning to be counted, got %v", delta)
+	}
+}
+
+func TestGetLocalConnectionsByAccountPrefix(t *testing.T) {
+	receptorA := &MockReceptor{NodeID: "node-a"}
+	receptorB := &MockReceptor{NodeID: "node-b"}
+	receptorC := &MockReceptor{NodeID: "node-c"}
+
+	cm := NewLocalConnectionManager()
+	cm.Register("9000001", "node-a", receptorA)
+	cm.Register("9000001", "node-b", receptorB)
+	cm.Register("9000002", "node-c", receptorC)
+	cm.Register("9000003", "node-a", receptorA)
+	cm.Register("0000001", "node-a", receptorA)
+
+	tests := []struct {
+		offset   int
+		limit    int
+		expected map[string]map[string]Receptor
+	}{
+		{
+			offset: 0,
+			limit:  2,
+			expected: map[string]map[string]Receptor{
+				"9000001": {"node-a": receptorA, "node-b": receptorB},
+				"9000002": {"node-c": receptorC},
+			},
+		},
+		{
+			offset: 2,
+			limit:  2,
+			expected: map[string]map[string]Receptor{
+				"9000003": {"node-a": receptorA},
+			},
+		},
+		{
+			offset:   3,
+			limit:    2,
+			expected: map[string]map[string]Receptor{},
+		},
+	}
+
+	for _, tc := range tests {
+		receptorMap, count := cm.GetConnectionsByAccountPrefix("9", tc.offset, tc.limit)
+
+		if count != 3 {
+			t.Fatalf("Expected to find 3 matching accounts, but found %d", count)
+		}
+
+		if cmp.Equal(tc.expected, receptorMap) != true {
+			t.Fatalf("Excepted receptor map and actual receptor map do not match.  Excpected %+v, Actual %+v",
+				tc.expected, receptorMap)
+		}
+	}
+}
+
+func TestShardedLocalConnectionManager(t *testing.T) {
+	cm := NewShardedLocalConnectionManager(ConnectionLimit{Default: 2}, 8)
+
+	var testReceptors = make(map[string]map[string]Receptor)
+	for i := 0; i < 50; i++ {
+		account := fmt.Sprintf("%07d", i)
+		testReceptors[account] = map[string]Receptor{"node-a": &MockReceptor{}, "node-b": &MockReceptor{}}
+		for nodeID, receptor := range testReceptors[account] {
+			if err := cm.Register(account, nodeID, receptor); err != nil {
+				t.Fatalf("Expected the error to be nil, got %v", err)
+			}
+		}
+	}
+
+	if err := cm.Register("0000001", "node-c", &MockReceptor{}); err != (TooManyConnectionsError{}) {
+		t.Fatalf("Expected %v, got %v", TooManyConnectionsError{}, err)
+	}
+
+	if cmp.Equal(testReceptors, cm.GetAllConnections()) != true {
+		t.Fatalf("Excepted receptor map and actual receptor map do not match")
+	}
+
+	receptorMap, count := cm.GetConnectionsByAccountPrefix("000000", 0, 5)
+	if count != 10 || len(receptorMap) != 5 {
+		t.Fatalf("Expected 5 of 10 matching accounts, got %d of %d", len(receptorMap), count)
+	}
+	if _, exists := receptorMap["0000004"]; exists == false {
+		t.Fatalf("Expected the first page of accounts to be sorted, got %+v", receptorMap)
+	}
+
+	cm.Unregister("0000001", "node-a")
+	if cm.GetConnection("0000001", "node-a") != nil || cm.GetConnection("0000001", "node-b") == nil {
+		t.Fatalf("Expected only node-a of account 0000001 to be unregistered")
+	}
+}
+
+func benchmarkLocalConnectionManager(b *testing.B, shardCount int) {
+	// Keep the registration logging out of the measurements
+	level := logger.Log.GetLevel()
+	logger.Log.SetLevel(logrus.WarnLevel)
+	defer logger.Log.SetLevel(level)
+
+	cm := NewShardedLocalConnectionManager(ConnectionLimit{}, shardCount)
+
+	const accountCount = 1000
+	for i := 0; i < accountCount; i++ {
+		cm.Register(strconv.Itoa(i), "node-a", &MockReceptor{})
+	}
+
+	var counter uint64
+	var workers uint64
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		// Each worker registers its own node id to avoid duplicate connections
+		nodeID := fmt.Sprintf("node-%d", atomic.AddUint64(&workers, 1))
+
+		for pb.Next() {
+			i := atomic.AddUint64(&counter, 1)
+			account := strconv.Itoa(int(i % accountCount))
+
+			// Mix registrations with the (far more frequent) lookups
+			if i%10 == 0 {
+				cm.Register(account, nodeID, &MockReceptor{})
+				cm.Unregister(account, nodeID)
+			} else {
+				cm.GetConnection(account, "node-a")
+			}
+		}
+	})
+}
+
+func BenchmarkLocalConnectionManagerSingleShard(b *testing.B) {
+	benchmarkLocalConnectionManager(b, 1)
+}
+
+func BenchmarkLocalConnectionManagerSixteenShards(b *testing.B) {
+	benchmarkLocalConnectionManager(b, 16)
+}
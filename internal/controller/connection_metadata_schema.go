@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Only the first few validation errors are passed back to the node
+const maxReportedMetadataErrors = 3
+
+// MetadataSchemaConnectionPolicy validates the metadata that the node sent
+// in its handshake against a JSON schema before consulting the wrapped policy.
+// If warnOnly is set, a node with invalid metadata is logged but allowed to
+// connect.
+type MetadataSchemaConnectionPolicy struct {
+	policy   ConnectionPolicy
+	schema   *gojsonschema.Schema
+	warnOnly bool
+}
+
+func NewMetadataSchemaConnectionPolicy(policy ConnectionPolicy, schemaFile string, warnOnly bool) (*MetadataSchemaConnectionPolicy, error) {
+	path, err := filepath.Abs(schemaFile)
+	if err != nil {
+		return nil, err
+	}
+
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewReferenceLoader("file://" + filepath.ToSlash(path)))
+	if err != nil {
+		return nil, fmt.Errorf("unable to load the connection metadata schema %s: %w", schemaFile, err)
+	}
+
+	return &MetadataSchemaConnectionPolicy{
+		policy:   policy,
+		schema:   schema,
+		warnOnly: warnOnly,
+	}, nil
+}
+
+func (p *MetadataSchemaConnectionPolicy) Allow(account string, nodeID string, metadata interface{}) (bool, string) {
+	if reason := p.validate(metadata); reason != "" {
+		metrics.invalidMetadataCounter.Inc()
+
+		log := logger.Log.WithFields(logrus.Fields{"account": account, "node_id": nodeID, "reason": reason})
+		if !p.warnOnly {
+			log.Info("Connection metadata does not match the schema")
+			return false, reason
+		}
+
+		log.Warn("Connection metadata does not match the schema...allowing the connection")
+	}
+
+	return p.policy.Allow(account, nodeID, metadata)
+}
+
+func (p *MetadataSchemaConnectionPolicy) validate(metadata interface{}) string {
+	result, err := p.schema.Validate(gojsonschema.NewGoLoader(metadata))
+	if err != nil {
+		return "metadata could not be validated: " + err.Error()
+	}
+
+	if result.Valid() {
+		return ""
+	}
+
+	var errs []string
+	for i, resultErr := range result.Errors() {
+		if i == maxReportedMetadataErrors {
+			break
+		}
+		errs = append(errs, resultErr.String())
+	}
+
+	return "metadata does not match the schema: " + strings.Join(errs, "; ")
+}
@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"errors"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+)
+
+// ConnectionPolicy decides whether a node is allowed to connect.  The policy
+// is consulted during the handshake, before the connection is registered.  The
+// reason is passed back to the node when the connection is rejected.
+type ConnectionPolicy interface {
+	Allow(account string, nodeID string, metadata interface{}) (bool, string)
+}
+
+type ConnectionRejectedError struct {
+	Reason string
+}
+
+func (e ConnectionRejectedError) Error() string {
+	return "connection rejected: " + e.Reason
+}
+
+// NewConnectionPolicy returns the configured policy.  If a connection
+// metadata schema is configured, the metadata is validated before the policy
+// is consulted.
+func NewConnectionPolicy(cfg *config.Config) (ConnectionPolicy, error) {
+	var policy ConnectionPolicy
+	switch cfg.ConnectionPolicyImpl {
+	case "allow_all":
+		policy = AllowAllConnectionPolicy{}
+	case "deny_list":
+		policy = NewDenyListConnectionPolicy(cfg.ConnectionPolicyDeniedAccounts, cfg.ConnectionPolicyDeniedNodeIDs)
+	default:
+		return nil, errors.New("invalid connection policy implementation " + cfg.ConnectionPolicyImpl)
+	}
+
+	if cfg.ConnectionMetadataSchemaFile == "" {
+		return policy, nil
+	}
+
+	schemaPolicy, err := NewMetadataSchemaConnectionPolicy(policy, cfg.ConnectionMetadataSchemaFile, cfg.ConnectionMetadataSchemaWarnOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	return schemaPolicy, nil
+}
+
+// AllowAllConnectionPolicy allows every connection
+type AllowAllConnectionPolicy struct {
+}
+
+func (p AllowAllConnectionPolicy) Allow(account string, nodeID string, metadata interface{}) (bool, string) {
+	return true, ""
+}
+
+// DenyListConnectionPolicy rejects the connections of the denied accounts and
+// the connections from the denied node ids
+type DenyListConnectionPolicy struct {
+	deniedAccounts map[string]bool
+	deniedNodeIDs  map[string]bool
+}
+
+func NewDenyListConnectionPolicy(deniedAccounts []string, deniedNodeIDs []string) *DenyListConnectionPolicy {
+	policy := &DenyListConnectionPolicy{
+		deniedAccounts: make(map[string]bool),
+		deniedNodeIDs:  make(map[string]bool),
+	}
+
+	for _, account := range deniedAccounts {
+		policy.deniedAccounts[account] = true
+	}
+
+	for _, nodeID := range deniedNodeIDs {
+		policy.deniedNodeIDs[nodeID] = true
+	}
+
+	return policy
+}
+
+func (p *DenyListConnectionPolicy) Allow(account string, nodeID string, metadata interface{}) (bool, string) {
+	if p.deniedAccounts[account] {
+		return false, "account is not allowed to connect"
+	}
+
+	if p.deniedNodeIDs[nodeID] {
+		return false, "node is not allowed to connect"
+	}
+
+	return true, ""
+}
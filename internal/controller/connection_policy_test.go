@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+)
+
+func TestDenyListConnectionPolicy(t *testing.T) {
+	policy := NewDenyListConnectionPolicy([]string{"000001"}, []string{"node-b"})
+
+	testCases := []struct {
+		account        string
+		nodeID         string
+		expectedAllow  bool
+		expectedReason string
+	}{
+		{"123", "node-a", true, ""},
+		{"000001", "node-a", false, "account is not allowed to connect"},
+		{"123", "node-b", false, "node is not allowed to connect"},
+	}
+
+	for _, tc := range testCases {
+		allow, reason := policy.Allow(tc.account, tc.nodeID, nil)
+		if allow != tc.expectedAllow || reason != tc.expectedReason {
+			t.Fatalf("Expected (%v, %q) for %s/%s, got (%v, %q)",
+				tc.expectedAllow, tc.expectedReason, tc.account, tc.nodeID, allow, reason)
+		}
+	}
+}
+
+func TestNewConnectionPolicyRejectsUnknownImpl(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.ConnectionPolicyImpl = "allow_some"
+
+	if _, err := NewConnectionPolicy(cfg); err == nil {
+		t.Fatalf("Expected an error for an unknown connection policy implementation")
+	}
+}
+
+func TestMetadataSchemaConnectionPolicy(t *testing.T) {
+	testCases := []struct {
+		metadata      interface{}
+		warnOnly      bool
+		expectedAllow bool
+	}{
+		{map[string]interface{}{"version": "1.0", "capabilities": []interface{}{"ping"}}, false, true},
+		{map[string]interface{}{"hostname": "node-a"}, false, false},
+		{map[string]interface{}{"version": 1}, false, false},
+		{nil, false, false},
+		{map[string]interface{}{"hostname": "node-a"}, true, true},
+	}
+
+	for _, tc := range testCases {
+		policy, err := NewMetadataSchemaConnectionPolicy(AllowAllConnectionPolicy{}, "testdata/connection_metadata_schema.json", tc.warnOnly)
+		if err != nil {
+			t.Fatalf("Expected the error to be nil, got %v", err)
+		}
+
+		allow, reason := policy.Allow("123", "node-a", tc.metadata)
+		if allow != tc.expectedAllow {
+			t.Fatalf("Expected %v for metadata %v (warn only: %v), got %v (%q)",
+				tc.expectedAllow, tc.metadata, tc.warnOnly, allow, reason)
+		}
+	}
+}
+
+func TestMetadataSchemaConnectionPolicyConsultsWrappedPolicy(t *testing.T) {
+	denyList := NewDenyListConnectionPolicy([]string{"000001"}, nil)
+	policy, err := NewMetadataSchemaConnectionPolicy(denyList, "testdata/connection_metadata_schema.json", false)
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	metadata := map[string]interface{}{"version": "1.0"}
+	if allow, _ := policy.Allow("000001", "node-a", metadata); allow {
+		t.Fatalf("Expected the wrapped policy to reject the account")
+	}
+}
+
+func TestNewConnectionPolicyFailsOnMissingSchema(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.ConnectionMetadataSchemaFile = "testdata/does_not_exist.json"
+
+	if _, err := NewConnectionPolicy(cfg); err == nil {
+		t.Fatalf("Expected an error for a missing connection metadata schema")
+	}
+}
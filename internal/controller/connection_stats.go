@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"sync"
+	"time"
+)
+
+// ConnectionStatsTracker counts the reconnections of the nodes.  A
+// registration is a reconnection when the node disconnected within the
+// window.  Only the reconnections made within the window are counted.
+type ConnectionStatsTracker struct {
+	window time.Duration
+
+	// disconnectedAt holds the time the nodes disconnected, by account and
+	// node id
+	disconnectedAt map[string]time.Time
+	reconnects     []time.Time
+	lastPrune      time.Time
+
+	sync.Mutex
+}
+
+func NewConnectionStatsTracker(window time.Duration) *ConnectionStatsTracker {
+	return &ConnectionStatsTracker{
+		window:         window,
+		disconnectedAt: make(map[string]time.Time),
+		lastPrune:      time.Now(),
+	}
+}
+
+func (t *ConnectionStatsTracker) recordConnected(account string, nodeID string, now time.Time) {
+	t.Lock()
+	defer t.Unlock()
+
+	key := account + "/" + nodeID
+	if disconnectedAt, exists := t.disconnectedAt[key]; exists {
+		delete(t.disconnectedAt, key)
+		if now.Sub(disconnectedAt) <= t.window {
+			t.reconnects = append(t.reconnects, now)
+		}
+	}
+}
+
+func (t *ConnectionStatsTracker) recordDisconnected(account string, nodeID string, now time.Time) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.disconnectedAt[account+"/"+nodeID] = now
+
+	// Nodes that never come back would otherwise be kept forever
+	if now.Sub(t.lastPrune) > t.window {
+		t.prune(now)
+	}
+}
+
+// Reconnects returns the number of reconnections made within the window
+func (t *ConnectionStatsTracker) Reconnects() (int, time.Duration) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.prune(time.Now())
+
+	return len(t.reconnects), t.window
+}
+
+func (t *ConnectionStatsTracker) prune(now time.Time) {
+	for key, disconnectedAt := range t.disconnectedAt {
+		if now.Sub(disconnectedAt) > t.window {
+			delete(t.disconnectedAt, key)
+		}
+	}
+
+	expired := 0
+	for expired < len(t.reconnects) && now.Sub(t.reconnects[expired]) > t.window {
+		expired++
+	}
+	t.reconnects = t.reconnects[expired:]
+
+	t.lastPrune = now
+}
+
+// StatsRecordingConnectionRegistrar records the registrations and
+// unregistrations made with the wrapped registrar in a stats tracker
+type StatsRecordingConnectionRegistrar struct {
+	registrar ConnectionRegistrar
+	stats     *ConnectionStatsTracker
+}
+
+func NewStatsRecordingConnectionRegistrar(registrar ConnectionRegistrar, stats *ConnectionStatsTracker) ConnectionRegistrar {
+	return &StatsRecordingConnectionRegistrar{
+		registrar: registrar,
+		stats:     stats,
+	}
+}
+
+func (r *StatsRecordingConnectionRegistrar) Register(account string, nodeID string, client Receptor) error {
+	if err := r.registrar.Register(account, nodeID, client); err != nil {
+		return err
+	}
+
+	r.stats.recordConnected(account, nodeID, time.Now())
+	return nil
+}
+
+func (r *StatsRecordingConnectionRegistrar) Unregister(account string, nodeID string) {
+	r.registrar.Unregister(account, nodeID)
+
+	r.stats.recordDisconnected(account, nodeID, time.Now())
+}
@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConnectionStatsTrackerCountsReconnectsWithinTheWindow(t *testing.T) {
+	tracker := NewConnectionStatsTracker(time.Hour)
+	start := time.Now()
+
+	tracker.recordDisconnected("01", "node-a", start.Add(-2*time.Hour))
+	tracker.recordConnected("01", "node-a", start.Add(-30*time.Minute))
+
+	tracker.recordDisconnected("01", "node-b", start.Add(-20*time.Minute))
+	tracker.recordConnected("01", "node-b", start.Add(-10*time.Minute))
+
+	// A first connection is not a reconnection
+	tracker.recordConnected("02", "node-c", start)
+
+	if reconnects, window := tracker.Reconnects(); reconnects != 1 || window != time.Hour {
+		t.Fatalf("Expected 1 reconnect within 1h, got %d within %s", reconnects, window)
+	}
+}
+
+func TestConnectionStatsTrackerForgetsOldReconnects(t *testing.T) {
+	tracker := NewConnectionStatsTracker(time.Hour)
+	start := time.Now()
+
+	tracker.recordDisconnected("01", "node-a", start.Add(-3*time.Hour))
+	tracker.recordConnected("01", "node-a", start.Add(-150*time.Minute))
+
+	tracker.recordDisconnected("01", "node-b", start.Add(-3*time.Hour))
+
+	if reconnects, _ := tracker.Reconnects(); reconnects != 0 {
+		t.Fatalf("Expected the old reconnect to be forgotten, got %d reconnects", reconnects)
+	}
+
+	if len(tracker.disconnectedAt) != 0 {
+		t.Fatalf("Expected the old disconnections to be pruned, got %v", tracker.disconnectedAt)
+	}
+}
+
+func TestStatsRecordingConnectionRegistrarIgnoresRejectedRegistrations(t *testing.T) {
+	tracker := NewConnectionStatsTracker(time.Hour)
+	cm := NewLocalConnectionManager()
+	registrar := NewStatsRecordingConnectionRegistrar(cm, tracker)
+
+	registrar.Register("01", "node-a", &MockReceptor{})
+	registrar.Unregister("01", "node-a")
+	registrar.Register("01", "node-a", &MockReceptor{})
+	if err := registrar.Register("01", "node-a", &MockReceptor{}); err != (DuplicateConnectionError{}) {
+		t.Fatalf("Expected %v, got %v", DuplicateConnectionError{}, err)
+	}
+
+	if reconnects, _ := tracker.Reconnects(); reconnects != 1 {
+		t.Fatalf("Expected 1 reconnect, got %d", reconnects)
+	}
+
+	if cm.GetConnection("01", "node-a") == nil {
+		t.Fatalf("Expected the connection to be registered with the wrapped registrar")
+	}
+}
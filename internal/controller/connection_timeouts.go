@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ConnectionTimeouts overrides the timeouts of the requests sent to a node.
+// A zero timeout means that the global default (Receptor_Sync_Ping_Timeout)
+// applies.
+type ConnectionTimeouts struct {
+	Ping         time.Duration
+	Capabilities time.Duration
+	Send         time.Duration
+}
+
+// TimeoutConfigurable is implemented by receptors whose timeouts can be
+// overridden per connection, e.g. for nodes that are slow but healthy
+type TimeoutConfigurable interface {
+	SetTimeouts(ctx context.Context, timeouts ConnectionTimeouts) error
+	GetTimeouts(ctx context.Context) (ConnectionTimeouts, error)
+}
+
+// InvalidConnectionTimeoutError is returned when a timeout override is
+// negative or longer than Receptor_Max_Connection_Timeout
+type InvalidConnectionTimeoutError struct {
+	Name       string
+	Timeout    time.Duration
+	MaxTimeout time.Duration
+}
+
+func (e InvalidConnectionTimeoutError) Error() string {
+	if e.Timeout < 0 {
+		return fmt.Sprintf("the %s timeout must not be negative", e.Name)
+	}
+	return fmt.Sprintf("the %s timeout %s is longer than the maximum timeout %s", e.Name, e.Timeout, e.MaxTimeout)
+}
+
+func (r *ReceptorService) validateTimeout(name string, timeout time.Duration) error {
+	if timeout < 0 || (r.config.ReceptorMaxConnectionTimeout > 0 && timeout > r.config.ReceptorMaxConnectionTimeout) {
+		return InvalidConnectionTimeoutError{Name: name, Timeout: timeout, MaxTimeout: r.config.ReceptorMaxConnectionTimeout}
+	}
+	return nil
+}
+
+// SetTimeouts replaces the timeout overrides of the connection.  The overrides
+// are validated as a whole so that an invalid override does not leave the
+// connection half configured.
+func (r *ReceptorService) SetTimeouts(ctx context.Context, timeouts ConnectionTimeouts) error {
+	for name, timeout := range map[string]time.Duration{
+		"ping":         timeouts.Ping,
+		"capabilities": timeouts.Capabilities,
+		"send":         timeouts.Send} {
+		if err := r.validateTimeout(name, timeout); err != nil {
+			return err
+		}
+	}
+
+	r.metadataLock.Lock()
+	defer r.metadataLock.Unlock()
+
+	r.timeouts = timeouts
+
+	return nil
+}
+
+// GetTimeouts returns the effective timeouts of the connection, i.e. the
+// overrides with the global default filled in for the timeouts that are not
+// overridden
+func (r *ReceptorService) GetTimeouts(ctx context.Context) (ConnectionTimeouts, error) {
+	return ConnectionTimeouts{
+		Ping:         r.pingTimeout(),
+		Capabilities: r.capabilitiesTimeout(),
+		Send:         r.sendTimeout(),
+	}, nil
+}
+
+func (r *ReceptorService) timeout(override func(ConnectionTimeouts) time.Duration) time.Duration {
+	r.metadataLock.RLock()
+	defer r.metadataLock.RUnlock()
+
+	if timeout := override(r.timeouts); timeout > 0 {
+		return timeout
+	}
+
+	return r.config.ReceptorSyncPingTimeout
+}
+
+func (r *ReceptorService) pingTimeout() time.Duration {
+	return r.timeout(func(t ConnectionTimeouts) time.Duration { return t.Ping })
+}
+
+func (r *ReceptorService) capabilitiesTimeout() time.Duration {
+	return r.timeout(func(t ConnectionTimeouts) time.Duration { return t.Capabilities })
+}
+
+func (r *ReceptorService) sendTimeout() time.Duration {
+	return r.timeout(func(t ConnectionTimeouts) time.Duration { return t.Send })
+}
+
+// parseMetadataTimeouts reads the timeout overrides that a node can request in
+// the metadata of its handshake, e.g.
+//
+//	"timeouts": {"ping": "30s", "capabilities": 60}
+//
+// The timeouts are either durations or numbers of seconds.  The overrides that
+// cannot be parsed or that are out of range are logged and ignored; they do
+// not prevent the node from connecting.
+func (r *ReceptorService) parseMetadataTimeouts(metadata interface{}) ConnectionTimeouts {
+	var timeouts ConnectionTimeouts
+
+	metadataMap, ok := metadata.(map[string]interface{})
+	if !ok {
+		return timeouts
+	}
+
+	requested, ok := metadataMap["timeouts"].(map[string]interface{})
+	if !ok {
+		return timeouts
+	}
+
+	for name, field := range map[string]*time.Duration{
+		"ping":         &timeouts.Ping,
+		"capabilities": &timeouts.Capabilities,
+		"send":         &timeouts.Send} {
+
+		value, exists := requested[name]
+		if !exists {
+			continue
+		}
+
+		timeout, err := parseMetadataTimeout(value)
+		if err == nil {
+			err = r.validateTimeout(name, timeout)
+		}
+		if err != nil {
+			r.logger.WithFields(logrus.Fields{"error": err, "timeout": name}).Warn("Ignoring the timeout requested by the node")
+			continue
+		}
+
+		*field = timeout
+	}
+
+	return timeouts
+}
+
+func parseMetadataTimeout(value interface{}) (time.Duration, error) {
+	switch timeout := value.(type) {
+	case float64:
+		return time.Duration(timeout * float64(time.Second)), nil
+	case string:
+		return time.ParseDuration(timeout)
+	default:
+		return 0, fmt.Errorf("unexpected timeout %v", value)
+	}
+}
@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+)
+
+// answerPingAfter plays the part of a slow node by responding to the next
+// directive sent over the control channel after the delay
+func answerPingAfter(receptor *ReceptorService, transport *Transport, delay time.Duration) {
+	msg := <-transport.ControlChannel
+
+	request, ok := msg.Message.(*protocol.PayloadMessage)
+	if !ok {
+		return
+	}
+
+	time.Sleep(delay)
+
+	receptor.DispatchResponse(&protocol.PayloadMessage{
+		RoutingInfo: &protocol.RoutingMessage{Sender: testNodeID},
+		Data: protocol.InnerEnvelope{
+			MessageID:    "f8f1e292-a50b-4b35-b3a3-d5b4f5e4a9ef",
+			InResponseTo: request.Data.MessageID,
+			RawPayload:   `{"max_work_threads": 12}`,
+		},
+	})
+}
+
+func newSlowNodeTestConfig() *config.Config {
+	cfg := config.GetConfig()
+	cfg.ReceptorSyncPingTimeout = 50 * time.Millisecond
+	cfg.ReceptorMaxConnectionTimeout = 5 * time.Second
+	return cfg
+}
+
+func TestReceptorServicePingTimesOutWithTheGlobalTimeout(t *testing.T) {
+	transport := newTestTransport(false)
+	receptor := newTestReceptorService(newSlowNodeTestConfig(), transport)
+	defer receptor.Close(context.TODO())
+
+	// The node never answers; a late answer would be written to kafka
+	go func() { <-transport.ControlChannel }()
+
+	if _, err := receptor.Ping(context.TODO(), testAccount, testNodeID, []string{testNodeID}); err != requestTimedOut {
+		t.Fatalf("Expected the ping to time out, got %v", err)
+	}
+}
+
+func TestReceptorServicePingUsesTheConnectionTimeout(t *testing.T) {
+	transport := newTestTransport(false)
+	receptor := newTestReceptorService(newSlowNodeTestConfig(), transport)
+	defer receptor.Close(context.TODO())
+
+	if err := receptor.SetTimeouts(context.TODO(), ConnectionTimeouts{Ping: 2 * time.Second}); err != nil {
+		t.Fatalf("Unable to set the timeouts: %v", err)
+	}
+
+	go answerPingAfter(receptor, transport, 200*time.Millisecond)
+
+	if _, err := receptor.Ping(context.TODO(), testAccount, testNodeID, []string{testNodeID}); err != nil {
+		t.Fatalf("Expected the ping to succeed, got %v", err)
+	}
+}
+
+func TestReceptorServiceRefreshCapabilitiesUsesTheConnectionTimeout(t *testing.T) {
+	transport := newTestTransport(false)
+	receptor := newTestReceptorService(newSlowNodeTestConfig(), transport)
+	defer receptor.Close(context.TODO())
+
+	if err := receptor.SetTimeouts(context.TODO(), ConnectionTimeouts{Capabilities: 2 * time.Second}); err != nil {
+		t.Fatalf("Unable to set the timeouts: %v", err)
+	}
+
+	go answerPingAfter(receptor, transport, 200*time.Millisecond)
+
+	if err := receptor.RefreshCapabilities(context.TODO()); err != nil {
+		t.Fatalf("Expected the refresh to succeed, got %v", err)
+	}
+}
+
+func TestReceptorServiceSendMessageUsesTheConnectionTimeout(t *testing.T) {
+	transport := newTestTransport(false)
+	receptor := newTestReceptorService(newSlowNodeTestConfig(), transport)
+	defer receptor.Close(context.TODO())
+
+	if err := receptor.SetTimeouts(context.TODO(), ConnectionTimeouts{Send: 2 * time.Second}); err != nil {
+		t.Fatalf("Unable to set the timeouts: %v", err)
+	}
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		<-transport.Send
+	}()
+
+	if _, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "directive"); err != nil {
+		t.Fatalf("Expected the message to be sent, got %v", err)
+	}
+}
+
+func TestReceptorServiceGetTimeoutsFillsInTheGlobalTimeout(t *testing.T) {
+	receptor := newTestReceptorService(newSlowNodeTestConfig(), newTestTransport(false))
+	defer receptor.Close(context.TODO())
+
+	if err := receptor.SetTimeouts(context.TODO(), ConnectionTimeouts{Ping: time.Second}); err != nil {
+		t.Fatalf("Unable to set the timeouts: %v", err)
+	}
+
+	timeouts, _ := receptor.GetTimeouts(context.TODO())
+
+	expected := ConnectionTimeouts{Ping: time.Second, Capabilities: 50 * time.Millisecond, Send: 50 * time.Millisecond}
+	if timeouts != expected {
+		t.Fatalf("Expected the timeouts %+v, got %+v", expected, timeouts)
+	}
+}
+
+func TestReceptorServiceSetTimeoutsRejectsInvalidTimeouts(t *testing.T) {
+	receptor := newTestReceptorService(newSlowNodeTestConfig(), newTestTransport(false))
+	defer receptor.Close(context.TODO())
+
+	if err := receptor.SetTimeouts(context.TODO(), ConnectionTimeouts{Ping: time.Second}); err != nil {
+		t.Fatalf("Unable to set the timeouts: %v", err)
+	}
+
+	invalidTimeouts := []ConnectionTimeouts{
+		{Send: -time.Second},
+		{Ping: time.Second, Capabilities: time.Minute},
+	}
+
+	for _, timeouts := range invalidTimeouts {
+		err := receptor.SetTimeouts(context.TODO(), timeouts)
+		if _, ok := err.(InvalidConnectionTimeoutError); !ok {
+			t.Fatalf("Expected an InvalidConnectionTimeoutError for %+v, got %v", timeouts, err)
+		}
+	}
+
+	if timeouts, _ := receptor.GetTimeouts(context.TODO()); timeouts.Ping != time.Second {
+		t.Fatalf("Expected the previous timeouts to be kept, got %+v", timeouts)
+	}
+}
+
+func TestReceptorServiceReadsTheTimeoutsFromTheMetadata(t *testing.T) {
+	receptor := newTestReceptorService(newSlowNodeTestConfig(), newTestTransport(false))
+	defer receptor.Close(context.TODO())
+
+	metadata := map[string]interface{}{
+		"timeouts": map[string]interface{}{
+			"ping":         "1s",
+			"capabilities": float64(2),
+			"send":         "forever",
+		},
+	}
+	receptor.RegisterConnection(testNodeID, metadata, receptor.Transport)
+
+	timeouts, _ := receptor.GetTimeouts(context.TODO())
+
+	expected := ConnectionTimeouts{Ping: time.Second, Capabilities: 2 * time.Second, Send: 50 * time.Millisecond}
+	if timeouts != expected {
+		t.Fatalf("Expected the timeouts %+v, got %+v", expected, timeouts)
+	}
+}
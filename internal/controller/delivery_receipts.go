@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+
+	"github.com/google/uuid"
+	kafka "github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DELIVERY_OUTCOME_DELIVERED = "delivered"
+	DELIVERY_OUTCOME_ACKED     = "acked"
+	DELIVERY_OUTCOME_FAILED    = "failed"
+	DELIVERY_OUTCOME_EXPIRED   = "expired"
+)
+
+// DeliveryReceiptWriter is implemented by the kafka writer the delivery
+// receipts are produced with
+type DeliveryReceiptWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// DeliveryReceipt records the outcome of the delivery of a message to a node.
+// A message that is delivered and then acknowledged produces two receipts.
+type DeliveryReceipt struct {
+	JobID     string    `json:"job_id"`
+	Account   string    `json:"account"`
+	NodeID    string    `json:"node_id"`
+	Recipient string    `json:"recipient,omitempty"`
+	Directive string    `json:"directive,omitempty"`
+	Pod       string    `json:"pod"`
+	Outcome   string    `json:"outcome"`
+	Error     string    `json:"error,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// DeliveryReceiptProducer produces a receipt to a kafka topic each time a
+// message is written to a node, acknowledged by the node or fails to be
+// delivered.  The receipts give downstream services an audit log of the
+// deliveries that does not depend on the state kept by the gateway pods.
+type DeliveryReceiptProducer struct {
+	writer       DeliveryReceiptWriter
+	pod          string
+	writeTimeout time.Duration
+
+	pendingWrites sync.WaitGroup
+}
+
+func NewDeliveryReceiptProducer(w DeliveryReceiptWriter, pod string, cfg *config.Config) *DeliveryReceiptProducer {
+	return &DeliveryReceiptProducer{
+		writer:       w,
+		pod:          pod,
+		writeTimeout: cfg.KafkaResponsesWriteTimeout,
+	}
+}
+
+// Produce writes the receipt in the background.  The receipts are keyed by
+// job id so that the receipts of a message are kept in order.
+func (p *DeliveryReceiptProducer) Produce(receipt DeliveryReceipt) {
+	receipt.Pod = p.pod
+
+	value, err := json.Marshal(receipt)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{"error": err}).Error("Unable to marshal the delivery receipt")
+		metrics.deliveryReceiptFailureCounter.Inc()
+		return
+	}
+
+	p.pendingWrites.Add(1)
+
+	go func() {
+		defer p.pendingWrites.Done()
+
+		ctx := context.Background()
+		if p.writeTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, p.writeTimeout)
+			defer cancel()
+		}
+
+		if err := p.writer.WriteMessages(ctx, kafka.Message{Key: []byte(receipt.JobID), Value: value}); err != nil {
+			logger.Log.WithFields(logrus.Fields{"error": err, "message_id": receipt.JobID}).Warn("Unable to produce the delivery receipt to kafka")
+			metrics.deliveryReceiptFailureCounter.Inc()
+		}
+	}()
+}
+
+// Flush waits for the receipts that are still being written.  False is
+// returned if ctx is done first.
+func (p *DeliveryReceiptProducer) Flush(ctx context.Context) bool {
+	flushed := make(chan struct{})
+	go func() {
+		p.pendingWrites.Wait()
+		close(flushed)
+	}()
+
+	select {
+	case <-flushed:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// SetDeliveryReceiptProducer enables producing a delivery receipt for the
+// messages sent by the receptor services created by the factory
+func (fact *ReceptorServiceFactory) SetDeliveryReceiptProducer(producer *DeliveryReceiptProducer) {
+	fact.deliveryReceipts = producer
+}
+
+// recordDelivery produces a receipt for the message.  The error is only set
+// for the failed deliveries.
+func (r *ReceptorService) recordDelivery(message Message, outcome string, err error) {
+	if r.deliveryReceipts == nil {
+		return
+	}
+
+	receipt := DeliveryReceipt{
+		JobID:     message.MessageID.String(),
+		Account:   r.AccountNumber,
+		NodeID:    r.PeerNodeID,
+		Recipient: message.Recipient,
+		Directive: message.Directive,
+		Outcome:   outcome,
+		Timestamp: time.Now().UTC(),
+	}
+
+	if err != nil {
+		receipt.Error = err.Error()
+	}
+
+	r.deliveryReceipts.Produce(receipt)
+}
+
+// recordAckReceipt produces a receipt for a message acknowledged by the node.
+// Only the id of the message is known at that point.
+func (r *ReceptorService) recordAckReceipt(messageID uuid.UUID) {
+	r.recordDelivery(Message{MessageID: messageID}, DELIVERY_OUTCOME_ACKED, nil)
+}
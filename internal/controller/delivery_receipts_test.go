@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newDeliveryReceiptTestReceptorService(w DeliveryReceiptWriter) (*ReceptorService, *DeliveryReceiptProducer, *Transport) {
+	cfg := config.GetConfig()
+	cfg.ReceptorWarmupGracePeriod = 0
+
+	producer := NewDeliveryReceiptProducer(w, "gateway-1", cfg)
+
+	factory := NewReceptorServiceFactory(nil, NewInMemoryOutboxStore(), cfg)
+	factory.SetDeliveryReceiptProducer(producer)
+
+	transport := newBufferedTestTransport()
+	receptor := factory.NewReceptorService(logger.Log.WithFields(logrus.Fields{"account": testAccount}), testAccount, "node-cloud-receptor-controller")
+	receptor.RegisterConnection(testNodeID, nil, transport)
+
+	return receptor, producer, transport
+}
+
+func lastDeliveryReceipt(t *testing.T, producer *DeliveryReceiptProducer, w *fakeInventoryWriter, expected int) DeliveryReceipt {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if !producer.Flush(ctx) {
+		t.Fatalf("Timed out waiting for the delivery receipts to be written")
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if len(w.msgs) != expected {
+		t.Fatalf("Expected %d delivery receipts, got %d", expected, len(w.msgs))
+	}
+
+	var receipt DeliveryReceipt
+	if err := json.Unmarshal(w.msgs[expected-1].Value, &receipt); err != nil {
+		t.Fatalf("Unable to unmarshal the delivery receipt: %v", err)
+	}
+
+	if string(w.msgs[expected-1].Key) != receipt.JobID {
+		t.Fatalf("Expected the receipt to be keyed by the job id, got %s", w.msgs[expected-1].Key)
+	}
+
+	return receipt
+}
+
+func TestDeliveryReceiptIsProducedOnDeliveryAndAck(t *testing.T) {
+	w := &fakeInventoryWriter{}
+	receptor, producer, transport := newDeliveryReceiptTestReceptorService(w)
+	defer receptor.Close(context.TODO())
+
+	messageID, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action")
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	(<-transport.Send).OnSent()
+
+	receipt := lastDeliveryReceipt(t, producer, w, 1)
+	if receipt.JobID != messageID.String() || receipt.Outcome != DELIVERY_OUTCOME_DELIVERED {
+		t.Fatalf("Expected a delivered receipt for %s, got %+v", messageID, receipt)
+	}
+
+	if receipt.Account != testAccount || receipt.NodeID != testNodeID || receipt.Recipient != testNodeID ||
+		receipt.Directive != "worker:action" || receipt.Pod != "gateway-1" || receipt.Timestamp.IsZero() || receipt.Error != "" {
+		t.Fatalf("Unexpected delivery receipt %+v", receipt)
+	}
+
+	receptor.RecordAck(*messageID)
+
+	receipt = lastDeliveryReceipt(t, producer, w, 2)
+	if receipt.JobID != messageID.String() || receipt.Outcome != DELIVERY_OUTCOME_ACKED || receipt.NodeID != testNodeID {
+		t.Fatalf("Expected an acked receipt for %s, got %+v", messageID, receipt)
+	}
+}
+
+func TestDeliveryReceiptIsProducedOnFailure(t *testing.T) {
+	w := &fakeInventoryWriter{}
+	receptor, producer, transport := newDeliveryReceiptTestReceptorService(w)
+	defer receptor.Close(context.TODO())
+
+	messageID, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action")
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	(<-transport.Send).OnFailed(ErrConnectionClosed)
+
+	receipt := lastDeliveryReceipt(t, producer, w, 1)
+	if receipt.JobID != messageID.String() || receipt.Outcome != DELIVERY_OUTCOME_FAILED || receipt.Error != ErrConnectionClosed.Error() {
+		t.Fatalf("Expected a failed receipt for %s, got %+v", messageID, receipt)
+	}
+}
+
+func TestDeliveryReceiptsAreNotProducedByDefault(t *testing.T) {
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorService(config.GetConfig(), transport)
+	defer receptor.Close(context.TODO())
+
+	if _, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action"); err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	// Recording the delivery must not require a producer
+	(<-transport.Send).OnSent()
+
+	if receptor.deliveryReceipts != nil {
+		t.Fatalf("Expected the delivery receipts to be disabled")
+	}
+}
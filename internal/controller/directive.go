@@ -0,0 +1,25 @@
+package controller
+
+type DirectiveNotAllowedError struct {
+	Directive string
+}
+
+func (e DirectiveNotAllowedError) Error() string {
+	return "directive " + e.Directive + " is not allowed"
+}
+
+// VerifyDirective checks the directive against the allow-list of directives.
+// An empty allow-list allows all directives.
+func VerifyDirective(allowedDirectives []string, directive string) error {
+	if len(allowedDirectives) == 0 {
+		return nil
+	}
+
+	for _, allowed := range allowedDirectives {
+		if directive == allowed {
+			return nil
+		}
+	}
+
+	return DirectiveNotAllowedError{Directive: directive}
+}
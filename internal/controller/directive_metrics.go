@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"sync"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+)
+
+// OTHER_LABEL_VALUE replaces the values of a metric label beyond its cap
+const OTHER_LABEL_VALUE = "other"
+
+// labelValueLimiter caps the number of distinct values of a metric label.  The
+// first max values seen are used as is, the others are replaced by "other".
+type labelValueLimiter struct {
+	lock sync.Mutex
+	max  int
+	seen map[string]bool
+}
+
+func newLabelValueLimiter(max int) *labelValueLimiter {
+	return &labelValueLimiter{
+		max:  max,
+		seen: make(map[string]bool),
+	}
+}
+
+func (l *labelValueLimiter) value(v string) string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.seen[v] {
+		return v
+	}
+
+	if len(l.seen) >= l.max {
+		return OTHER_LABEL_VALUE
+	}
+
+	l.seen[v] = true
+	return v
+}
+
+// sentMessageMetrics records the messages passed to the nodes by directive.
+// The directive and account labels are capped to keep the cardinality of the
+// metrics bounded.
+type sentMessageMetrics struct {
+	accounts   *labelValueLimiter
+	directives *labelValueLimiter
+}
+
+func newSentMessageMetrics(cfg *config.Config) *sentMessageMetrics {
+	return &sentMessageMetrics{
+		accounts:   newLabelValueLimiter(cfg.DirectiveMetricsMaxAccounts),
+		directives: newLabelValueLimiter(cfg.DirectiveMetricsMaxDirectives),
+	}
+}
+
+func (m *sentMessageMetrics) record(account string, directive string, payload interface{}) {
+	directive = m.directives.value(directive)
+
+	metrics.sentMessageCounter.WithLabelValues(directive, m.accounts.value(account)).Inc()
+
+	metrics.sentMessagePayloadBytes.WithLabelValues(directive).Observe(float64(PayloadSize(payload)))
+}
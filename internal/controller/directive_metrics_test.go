@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestLabelValueLimiter(t *testing.T) {
+	limiter := newLabelValueLimiter(2)
+
+	for _, tc := range []struct {
+		value    string
+		expected string
+	}{
+		{"a", "a"},
+		{"b", "b"},
+		{"c", OTHER_LABEL_VALUE},
+		{"a", "a"},
+		{"d", OTHER_LABEL_VALUE},
+	} {
+		if actual := limiter.value(tc.value); actual != tc.expected {
+			t.Fatalf("Expected %q for %q, got %q", tc.expected, tc.value, actual)
+		}
+	}
+}
+
+func getPayloadSampleCount(t *testing.T, directive string) uint64 {
+	m := &dto.Metric{}
+	if err := metrics.sentMessagePayloadBytes.WithLabelValues(directive).(prometheus.Histogram).Write(m); err != nil {
+		t.Fatalf("Unable to read the payload size histogram: %v", err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestReceptorServiceSendMessageRecordsDirectiveMetrics(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.DirectiveMetricsMaxAccounts = 1
+	cfg.DirectiveMetricsMaxDirectives = 1
+
+	transport := newTestTransport(false)
+	receptor := newTestReceptorService(cfg, transport)
+	defer receptor.Close(context.TODO())
+
+	sent := metrics.sentMessageCounter.WithLabelValues("metrics:first", testAccount)
+	other := metrics.sentMessageCounter.WithLabelValues(OTHER_LABEL_VALUE, testAccount)
+	sentBefore, otherBefore := testutil.ToFloat64(sent), testutil.ToFloat64(other)
+	samplesBefore := getPayloadSampleCount(t, "metrics:first")
+
+	for _, directive := range []string{"metrics:first", "metrics:first", "metrics:second"} {
+		if _, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", directive); err != nil {
+			t.Fatalf("Expected the error to be nil, got %v", err)
+		}
+	}
+
+	if count := testutil.ToFloat64(sent) - sentBefore; count != 2 {
+		t.Fatalf("Expected 2 messages counted for the directive, got %v", count)
+	}
+
+	// The cap on the directives has been reached
+	if count := testutil.ToFloat64(other) - otherBefore; count != 1 {
+		t.Fatalf("Expected 1 message counted as other, got %v", count)
+	}
+
+	if samples := getPayloadSampleCount(t, "metrics:first") - samplesBefore; samples != 2 {
+		t.Fatalf("Expected 2 payload sizes recorded for the directive, got %v", samples)
+	}
+}
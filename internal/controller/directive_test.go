@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+)
+
+func TestVerifyDirective(t *testing.T) {
+	testCases := []struct {
+		allowedDirectives []string
+		directive         string
+		expectedError     error
+	}{
+		{nil, "worker:action", nil},
+		{[]string{"worker:action", "receptor_http:execute"}, "worker:action", nil},
+		{[]string{"worker:action"}, "worker:other_action", DirectiveNotAllowedError{Directive: "worker:other_action"}},
+	}
+
+	for _, tc := range testCases {
+		err := VerifyDirective(tc.allowedDirectives, tc.directive)
+		if err != tc.expectedError {
+			t.Fatalf("Expected %v for %s, got %v", tc.expectedError, tc.directive, err)
+		}
+	}
+}
+
+func TestReceptorServiceRejectsDirectiveThatIsNotAllowed(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.AllowedDirectives = []string{"worker:action"}
+	outbox := NewInMemoryOutboxStore()
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorServiceWithOutbox(cfg, transport, outbox)
+	defer receptor.Close(context.TODO())
+
+	_, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:other_action")
+	if err != (DirectiveNotAllowedError{Directive: "worker:other_action"}) {
+		t.Fatalf("Expected a DirectiveNotAllowedError, got %v", err)
+	}
+
+	if len(transport.Send) != 0 {
+		t.Fatalf("Expected the message to not be passed to the transport")
+	}
+
+	pending, _ := outbox.GetPending(context.TODO(), time.Now().Add(time.Minute))
+	if len(pending) != 0 {
+		t.Fatalf("Expected the message to not be added to the outbox, found %d entries", len(pending))
+	}
+}
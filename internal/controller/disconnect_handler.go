@@ -13,12 +13,44 @@ type DisconnectHandler struct {
 	NodeID        string
 	ConnectionMgr ConnectionRegistrar
 	Logger        *logrus.Entry
+
+	// Transport reports why the connection went away.  It can be nil.
+	Transport *Transport
+
+	// Receptor is closed once the connection is lost so that the messages
+	// still queued on the connection, and the senders waiting on them, are
+	// failed (or the messages forwarded to the pod that the node reconnects
+	// to).  It can be nil.
+	Receptor Receptor
 }
 
 func (dh DisconnectHandler) HandleMessage(ctx context.Context, m protocol.Message) {
-	dh.ConnectionMgr.Unregister(dh.AccountNumber, dh.NodeID)
-	dh.Logger.Debugf("DisconnectHandler - account (%s) / node id (%s) unregistered from connection manager",
+	reason := dh.disconnectReason()
+
+	if unregistrar, ok := dh.ConnectionMgr.(ReasonedConnectionUnregistrar); ok {
+		unregistrar.UnregisterWithReason(dh.AccountNumber, dh.NodeID, reason)
+	} else {
+		dh.ConnectionMgr.Unregister(dh.AccountNumber, dh.NodeID)
+	}
+	dh.Logger.WithFields(logrus.Fields{"reason": reason}).Debugf("DisconnectHandler - account (%s) / node id (%s) unregistered from connection manager",
 		dh.AccountNumber,
 		dh.NodeID)
+
+	if dh.Receptor != nil {
+		// The connection's context has already been cancelled
+		dh.Receptor.Close(context.Background())
+	}
+
+	if runner, ok := dh.Receptor.(closeCallbackRunner); ok {
+		runner.runCloseCallbacks(reason)
+	}
+
 	return
 }
+
+func (dh DisconnectHandler) disconnectReason() DisconnectReason {
+	if dh.Transport == nil || dh.Transport.DisconnectReason == nil {
+		return ""
+	}
+	return dh.Transport.DisconnectReason()
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/queue"
 
@@ -25,22 +26,27 @@ func (fact *MessageDispatcherFactory) NewDispatcher(account, nodeID string) *Mes
 	log.Println("Creating a new work dispatcher")
 	r := queue.StartConsumer(fact.readerConfig)
 	return &MessageDispatcher{
-		account: account,
-		nodeID:  nodeID,
-		reader:  r,
+		account:       account,
+		nodeID:        nodeID,
+		reader:        r,
+		commitTimeout: fact.readerConfig.CommitTimeout,
 	}
 }
 
 type MessageDispatcher struct {
-	account string
-	nodeID  string
-	reader  *kafka.Reader
+	account       string
+	nodeID        string
+	reader        *kafka.Reader
+	commitTimeout time.Duration
 }
 
 func (md *MessageDispatcher) GetKey() string {
 	return fmt.Sprintf("%s:%s", md.account, md.nodeID)
 }
 
+// StartDispatchingMessages passes the work requests consumed from kafka to the
+// channel until ctx is done.  A work request is only committed once it has
+// been passed to the channel.
 func (md *MessageDispatcher) StartDispatchingMessages(ctx context.Context, c chan<- Message) {
 	defer func() {
 		err := md.reader.Close()
@@ -51,32 +57,41 @@ func (md *MessageDispatcher) StartDispatchingMessages(ctx context.Context, c cha
 		log.Println("Kafka job reader leaving...")
 	}()
 
-	for {
-		log.Printf("Kafka job reader - waiting on a message from kafka...")
-		m, err := md.reader.ReadMessage(ctx)
-		if err != nil {
-			// FIXME:  do we need to call cancel here??
-			log.Println("Kafka job reader - error reading message: ", err)
-			break
-		}
+	log.Printf("Kafka job reader - waiting on messages from kafka...")
+	err := queue.ConsumeMessages(ctx, md.reader, md.commitTimeout, func(ctx context.Context, m kafka.Message) error {
+		return md.dispatchMessage(ctx, m, c)
+	})
+	if err != nil {
+		log.Println("Kafka job reader - error consuming messages: ", err)
+	}
+}
 
-		log.Printf("Kafka job reader - received message from %s-%d [%d]: %s: %s\n",
-			m.Topic,
-			m.Partition,
-			m.Offset,
-			string(m.Key),
-			string(m.Value))
+func (md *MessageDispatcher) dispatchMessage(ctx context.Context, m kafka.Message, c chan<- Message) error {
+	log.Printf("Kafka job reader - received message from %s-%d [%d]: %s: %s\n",
+		m.Topic,
+		m.Partition,
+		m.Offset,
+		string(m.Key),
+		string(m.Value))
 
-		if string(m.Key) == md.GetKey() {
-			// FIXME:
-			var w Message
-			if err := json.Unmarshal(m.Value, &w); err != nil {
-				log.Println("Unable to unmarshal message from kafka queue")
-				continue
-			}
-			c <- w
-		} else {
-			log.Println("Kafka job reader - received message but did not send. Account number not found.")
-		}
+	if string(m.Key) != md.GetKey() {
+		log.Println("Kafka job reader - received message but did not send. Account number not found.")
+		return nil
+	}
+
+	// FIXME:
+	var w Message
+	if err := json.Unmarshal(m.Value, &w); err != nil {
+		log.Println("Unable to unmarshal message from kafka queue")
+		return nil
+	}
+
+	// The connection is going away, so the work request is left for the
+	// next consumer
+	select {
+	case c <- w:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
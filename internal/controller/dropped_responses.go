@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// DroppedResponseTable keeps track of the senders that are waiting for a sync
+// response so that they can be told when their response was dropped.  The
+// transport only holds the most recent dropped message, so the sender that
+// reads it from the transport passes it on to the sender it is meant for.
+type DroppedResponseTable struct {
+	dispatchTable map[uuid.UUID]chan DroppedMessage
+	sync.Mutex
+}
+
+func (dt *DroppedResponseTable) Register(msgID uuid.UUID, droppedChannel chan DroppedMessage) {
+	dt.Lock()
+	dt.dispatchTable[msgID] = droppedChannel
+	dt.Unlock()
+}
+
+func (dt *DroppedResponseTable) Unregister(msgID uuid.UUID) {
+	dt.Lock()
+	delete(dt.dispatchTable, msgID)
+	dt.Unlock()
+}
+
+// Dispatch passes the dropped message to the sender of the request that it
+// responds to.  If the request is not known, the dropped message is passed to
+// all of the senders.
+func (dt *DroppedResponseTable) Dispatch(dropped DroppedMessage) {
+	dt.Lock()
+	defer dt.Unlock()
+
+	if dropped.InResponseTo != uuid.Nil {
+		if droppedChannel, exists := dt.dispatchTable[dropped.InResponseTo]; exists {
+			notifyDropped(droppedChannel, dropped)
+		}
+		return
+	}
+
+	for _, droppedChannel := range dt.dispatchTable {
+		notifyDropped(droppedChannel, dropped)
+	}
+}
+
+// notifyDropped does not block: a sender that has already been told about a
+// dropped message fails anyway
+func notifyDropped(droppedChannel chan DroppedMessage, dropped DroppedMessage) {
+	select {
+	case droppedChannel <- dropped:
+	default:
+	}
+}
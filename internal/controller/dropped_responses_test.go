@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+
+	"github.com/google/uuid"
+)
+
+// startPing pings the node in the background and returns the id of the ping
+// request once it has been passed to the transport
+func startPing(t *testing.T, receptor *ReceptorService, transport *Transport) (uuid.UUID, <-chan error) {
+	pingErr := make(chan error, 1)
+	go func() {
+		_, err := receptor.Ping(context.TODO(), testAccount, testNodeID, []string{testNodeID})
+		pingErr <- err
+	}()
+
+	select {
+	case msg := <-transport.ControlChannel:
+		return uuid.MustParse(msg.Message.(*protocol.PayloadMessage).Data.MessageID), pingErr
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for the ping to be passed to the transport")
+		return uuid.Nil, nil
+	}
+}
+
+func newDroppingTestTransport() *Transport {
+	transport := newTestTransport(false)
+	transport.Dropped = make(chan DroppedMessage, 1)
+	return transport
+}
+
+func TestReceptorServiceFailsTheSenderOfTheDroppedResponse(t *testing.T) {
+	transport := newDroppingTestTransport()
+	receptor := newTestReceptorService(config.GetConfig(), transport)
+	defer receptor.Close(context.TODO())
+
+	firstID, firstErr := startPing(t, receptor, transport)
+	secondID, secondErr := startPing(t, receptor, transport)
+
+	transport.Dropped <- DroppedMessage{Reason: errors.New("too large"), DroppedAt: time.Now(), InResponseTo: secondID}
+
+	select {
+	case err := <-secondErr:
+		if _, ok := err.(ResponseDroppedError); !ok {
+			t.Fatalf("Expected the ping to fail with a ResponseDroppedError, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the ping whose response was dropped to fail")
+	}
+
+	select {
+	case err := <-firstErr:
+		t.Fatalf("Expected the other ping to keep waiting for its response, got %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	receptor.DispatchResponse(&protocol.PayloadMessage{
+		RoutingInfo: &protocol.RoutingMessage{Sender: testNodeID},
+		Data: protocol.InnerEnvelope{
+			MessageID:    "f8f1e292-a50b-4b35-b3a3-d5b4f5e4a9ef",
+			InResponseTo: firstID.String(),
+			RawPayload:   "pong",
+		},
+	})
+
+	if err := <-firstErr; err != nil {
+		t.Fatalf("Expected the other ping to succeed, got %v", err)
+	}
+}
+
+func TestReceptorServiceFailsAllTheSendersWhenTheRequestOfTheDroppedMessageIsUnknown(t *testing.T) {
+	transport := newDroppingTestTransport()
+	receptor := newTestReceptorService(config.GetConfig(), transport)
+	defer receptor.Close(context.TODO())
+
+	_, firstErr := startPing(t, receptor, transport)
+	_, secondErr := startPing(t, receptor, transport)
+
+	transport.Dropped <- DroppedMessage{Reason: errors.New("too large"), DroppedAt: time.Now()}
+
+	for _, pingErr := range []<-chan error{firstErr, secondErr} {
+		select {
+		case err := <-pingErr:
+			if _, ok := err.(ResponseDroppedError); !ok {
+				t.Fatalf("Expected the ping to fail with a ResponseDroppedError, got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected both pings to fail")
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+
+	"github.com/sirupsen/logrus"
+)
+
+// FlowControlHandler lets a node that is under load pause, resume or slow
+// down the delivery of messages to it
+type FlowControlHandler struct {
+	Receptor  *ReceptorService
+	Transport *Transport
+	Logger    *logrus.Entry
+}
+
+func (fh FlowControlHandler) HandleMessage(ctx context.Context, m protocol.Message) {
+
+	if m.Type() != protocol.FlowControlMessageType {
+		fh.Logger.Infof("Invalid message type (type: %d): %v", m.Type(), m)
+		return
+	}
+
+	flowControlMessage, ok := m.(*protocol.FlowControlMessage)
+	if !ok {
+		fh.Logger.Info("Unable to convert message into FlowControlMessage")
+		return
+	}
+
+	switch flowControlMessage.Action {
+	case protocol.FlowControlPause:
+		fh.Receptor.PauseFromNode(ctx)
+
+	case protocol.FlowControlResume:
+		fh.Receptor.ResumeFromNode(ctx)
+
+	case protocol.FlowControlRate:
+		if flowControlMessage.Rate < 0 {
+			fh.Logger.Infof("Ignoring a negative flow control rate (%d)", flowControlMessage.Rate)
+			return
+		}
+
+		if fh.Transport.SetSendRate == nil {
+			fh.Logger.Info("The transport does not support limiting the send rate")
+			return
+		}
+
+		fh.Logger.WithFields(logrus.Fields{"rate": flowControlMessage.Rate}).Info("Limiting the send rate at the request of the node")
+		fh.Transport.SetSendRate(flowControlMessage.Rate)
+
+	default:
+		fh.Logger.Infof("Ignoring an unknown flow control action (%s)", flowControlMessage.Action)
+	}
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newTestFlowControlHandler(receptor *ReceptorService, transport *Transport) FlowControlHandler {
+	return FlowControlHandler{
+		Receptor:  receptor,
+		Transport: transport,
+		Logger:    logger.Log.WithFields(logrus.Fields{"account": testAccount}),
+	}
+}
+
+func flowControlMessage(action string, rate int) *protocol.FlowControlMessage {
+	return &protocol.FlowControlMessage{Command: protocol.FlowControlCommand, ID: testNodeID, Action: action, Rate: rate}
+}
+
+func TestFlowControlPauseHoldsMessages(t *testing.T) {
+	cfg := config.GetConfig()
+	outbox := NewInMemoryOutboxStore()
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorServiceWithOutbox(cfg, transport, outbox)
+	defer receptor.Close(context.TODO())
+
+	handler := newTestFlowControlHandler(receptor, transport)
+	handler.HandleMessage(context.TODO(), flowControlMessage(protocol.FlowControlPause, 0))
+
+	if receptor.IsPaused(context.TODO()) == false {
+		t.Fatalf("Expected the receptor to be paused")
+	}
+
+	messageID, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action")
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	if len(transport.Send) != 0 {
+		t.Fatalf("Expected no messages to be passed to the transport while paused, got %d", len(transport.Send))
+	}
+
+	entry, _ := outbox.Get(context.TODO(), *messageID)
+	if entry.Status != OUTBOX_PENDING_STATUS {
+		t.Fatalf("Expected the message to be pending, got %s", entry.Status)
+	}
+
+	handler.HandleMessage(context.TODO(), flowControlMessage(protocol.FlowControlResume, 0))
+
+	if len(transport.Send) != 1 {
+		t.Fatalf("Expected the held message to be passed to the transport, got %d", len(transport.Send))
+	}
+}
+
+func TestFlowControlResumeDoesNotUndoAnOperatorPause(t *testing.T) {
+	cfg := config.GetConfig()
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorService(cfg, transport)
+	defer receptor.Close(context.TODO())
+
+	handler := newTestFlowControlHandler(receptor, transport)
+
+	receptor.Pause(context.TODO())
+	handler.HandleMessage(context.TODO(), flowControlMessage(protocol.FlowControlPause, 0))
+
+	if _, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action"); err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	handler.HandleMessage(context.TODO(), flowControlMessage(protocol.FlowControlResume, 0))
+
+	if receptor.IsPaused(context.TODO()) == false || len(transport.Send) != 0 {
+		t.Fatalf("Expected the message to remain held until the operator resumes the delivery")
+	}
+
+	receptor.Resume(context.TODO())
+
+	if receptor.IsPaused(context.TODO()) || len(transport.Send) != 1 {
+		t.Fatalf("Expected the held message to be passed to the transport")
+	}
+}
+
+func TestFlowControlRateLimitsTheTransport(t *testing.T) {
+	cfg := config.GetConfig()
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorService(cfg, transport)
+	defer receptor.Close(context.TODO())
+
+	rate := -1
+	transport.SetSendRate = func(messagesPerSecond int) { rate = messagesPerSecond }
+
+	handler := newTestFlowControlHandler(receptor, transport)
+
+	handler.HandleMessage(context.TODO(), flowControlMessage(protocol.FlowControlRate, -5))
+	if rate != -1 {
+		t.Fatalf("Expected a negative rate to be ignored, got %d", rate)
+	}
+
+	handler.HandleMessage(context.TODO(), flowControlMessage(protocol.FlowControlRate, 5))
+	if rate != 5 {
+		t.Fatalf("Expected the send rate to be 5, got %d", rate)
+	}
+}
@@ -22,7 +22,8 @@ func NewGatewayConnectionRegistrar(rdc *redis.Client, cm ConnectionRegistrar, ho
 }
 
 func (rcm *GatewayConnectionRegistrar) Register(account string, node_id string, client Receptor) error {
-	if ExistsInRedis(rcm.redisClient, account, node_id) { // checking connection globally
+	if ExistsInRedis(rcm.redisClient, account, node_id) &&
+		!IsImportedInRedis(rcm.redisClient, account, node_id) { // checking connection globally
 		logger := logger.Log.WithFields(logrus.Fields{"account": account, "node_id": node_id})
 		logger.Warn("Attempting to register duplicate connection")
 		metrics.duplicateConnectionCounter.Inc()
@@ -180,3 +180,24 @@ func TestUnregisterWithGatewayConnectionManager(t *testing.T) {
 	assert.Equal(t, c.Get("01:node-d").Val(), hostname)
 	assert.Equal(t, lcm.GetConnection("01", "node-d"), &MockReceptor{NodeID: "node-d"})
 }
+
+func TestRegisterImportedWithGatewayConnectionManager(t *testing.T) {
+	s, _ := miniredis.Run()
+	defer s.Close()
+
+	c := newTestRedisClient(s.Addr())
+	lcm := NewLocalConnectionManager()
+
+	gcm := NewGatewayConnectionRegistrar(c, lcm, hostname)
+
+	_, _ = ImportWithRedis(c, "01", "node-a", "gateway-pod-9")
+
+	client := &MockReceptor{NodeID: "node-a"}
+	err := gcm.Register("01", "node-a", client)
+	if err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+
+	assert.Equal(t, c.Get("01:node-a").Val(), hostname)
+	assert.Equal(t, client, lcm.GetConnection("01", "node-a"))
+}
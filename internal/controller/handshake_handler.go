@@ -13,10 +13,14 @@ import (
 type HandshakeHandler struct {
 	AccountNumber            string
 	NodeID                   string
+	Labels                   map[string]string
 	Transport                *Transport
 	ReceptorServiceFactory   *ReceptorServiceFactory
 	ResponseReactor          ResponseReactor
 	ConnectionMgr            ConnectionRegistrar
+	RegistrationHooks        *RegistrationHookChain
+	MinProtocolVersion       int
+	AffinityPods             []string
 	MessageDispatcherFactory *MessageDispatcherFactory
 	Logger                   *logrus.Entry
 }
@@ -42,7 +46,45 @@ func (hh HandshakeHandler) HandleMessage(ctx context.Context, m protocol.Message
 	hh.Logger = hh.Logger.WithFields(logrus.Fields{"peer_node_id": hiMessage.ID})
 	hh.Logger.Info("Received handshake message")
 
-	responseHiMessage := protocol.HiMessage{Command: "HI", ID: hh.NodeID}
+	registrationRequest := RegistrationRequest{
+		Account:  hh.AccountNumber,
+		NodeID:   hiMessage.ID,
+		Metadata: hiMessage.Metadata,
+		Labels:   hh.Labels,
+	}
+
+	if hook, err := hh.RegistrationHooks.Run(registrationRequest); err != nil {
+		hh.Logger.WithFields(logrus.Fields{"registration_hook": hook, "error": err}).Warnf("Connection (%s:%s) rejected by a registration hook."+
+			"  Closing connection!", hh.AccountNumber, hiMessage.ID)
+
+		hh.Transport.ErrorChannel <- ReceptorErrorMessage{
+			AccountNumber: hh.AccountNumber,
+			Error:         err}
+
+		return
+	}
+
+	protocolVersion, err := NegotiateProtocolVersion(hiMessage.ProtocolVersion, hh.MinProtocolVersion)
+	if err != nil {
+		hh.Logger.WithFields(logrus.Fields{"error": err}).Warnf("Connection (%s:%s) speaks an unsupported version of the protocol."+
+			"  Closing connection!", hh.AccountNumber, hiMessage.ID)
+		metrics.incompatibleProtocolCounter.Inc()
+
+		hh.Transport.ErrorChannel <- ReceptorErrorMessage{
+			AccountNumber: hh.AccountNumber,
+			Error:         err}
+
+		return
+	}
+
+	hh.Logger = hh.Logger.WithFields(logrus.Fields{"protocol_version": protocolVersion})
+
+	responseHiMessage := protocol.HiMessage{
+		Command:         "HI",
+		ID:              hh.NodeID,
+		ProtocolVersion: protocolVersion,
+		Affinity:        AffinityHint(hiMessage.ID, hh.AffinityPods),
+	}
 
 	ctx, cancel := context.WithTimeout(ctx, time.Second*10) // FIXME:  add a configurable timeout
 	defer cancel()
@@ -65,8 +107,10 @@ func (hh HandshakeHandler) HandleMessage(ctx context.Context, m protocol.Message
 		hh.NodeID)
 
 	receptor.RegisterConnection(hiMessage.ID, hiMessage.Metadata, hh.Transport)
+	receptor.SetLabels(hh.Labels)
+	receptor.SetProtocolVersion(protocolVersion)
 
-	err := hh.ConnectionMgr.Register(hh.AccountNumber, hiMessage.ID, receptor)
+	err = hh.ConnectionMgr.Register(hh.AccountNumber, hiMessage.ID, receptor)
 	if err != nil {
 		// Abort the connection if this account number and node id are already registered
 		hh.Logger.WithFields(logrus.Fields{"error": err}).Infof("Unable to register connection "+
@@ -84,6 +128,8 @@ func (hh HandshakeHandler) HandleMessage(ctx context.Context, m protocol.Message
 		NodeID:        hiMessage.ID,
 		ConnectionMgr: hh.ConnectionMgr,
 		Logger:        hh.Logger,
+		Transport:     hh.Transport,
+		Receptor:      receptor,
 	}
 	hh.ResponseReactor.RegisterDisconnectHandler(disconnectHandler)
 
@@ -94,6 +140,40 @@ func (hh HandshakeHandler) HandleMessage(ctx context.Context, m protocol.Message
 	}
 	hh.ResponseReactor.RegisterHandler(protocol.RouteTableMessageType, routeTableHandler)
 
+	capabilitiesHandler := CapabilitiesHandler{
+		Receptor:  receptor,
+		Transport: hh.Transport,
+		Logger:    hh.Logger,
+	}
+	if listener, ok := hh.ConnectionMgr.(CapabilitiesUpdateListener); ok {
+		capabilitiesHandler.Listener = listener
+	}
+	hh.ResponseReactor.RegisterHandler(protocol.CapabilitiesMessageType, capabilitiesHandler)
+
+	metadataUpdateHandler := MetadataUpdateHandler{
+		Receptor:  receptor,
+		Transport: hh.Transport,
+		Logger:    hh.Logger,
+	}
+	if listener, ok := hh.ConnectionMgr.(MetadataUpdateListener); ok {
+		metadataUpdateHandler.Listener = listener
+	}
+	hh.ResponseReactor.RegisterHandler(protocol.MetadataUpdateMessageType, metadataUpdateHandler)
+
+	ackHandler := AckHandler{
+		Receptor:  receptor,
+		Transport: hh.Transport,
+		Logger:    hh.Logger,
+	}
+	hh.ResponseReactor.RegisterHandler(protocol.AckMessageType, ackHandler)
+
+	flowControlHandler := FlowControlHandler{
+		Receptor:  receptor,
+		Transport: hh.Transport,
+		Logger:    hh.Logger,
+	}
+	hh.ResponseReactor.RegisterHandler(protocol.FlowControlMessageType, flowControlHandler)
+
 	payloadHandler := PayloadHandler{AccountNumber: hh.AccountNumber,
 		Receptor:  receptor,
 		Transport: hh.Transport,
@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+)
+
+const (
+	HEALTHY_STATUS  = "healthy"
+	DEGRADED_STATUS = "degraded"
+	STALLED_STATUS  = "stalled"
+)
+
+// KeepaliveTracker records the last time that the read side of a connection
+// heard from the receptor node (pong or any other message)
+type KeepaliveTracker struct {
+	lastKeepalive int64
+}
+
+func NewKeepaliveTracker() *KeepaliveTracker {
+	kt := &KeepaliveTracker{}
+	kt.RecordKeepalive()
+	return kt
+}
+
+func (kt *KeepaliveTracker) RecordKeepalive() {
+	atomic.StoreInt64(&kt.lastKeepalive, time.Now().UnixNano())
+}
+
+func (kt *KeepaliveTracker) LastKeepalive() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&kt.lastKeepalive))
+}
+
+type HealthThresholds struct {
+	DegradedKeepaliveAge time.Duration
+	StalledKeepaliveAge  time.Duration
+
+	// Percentage of the send channel's capacity that is in use
+	DegradedSendChannelUsage int
+	StalledSendChannelUsage  int
+}
+
+func NewHealthThresholds(cfg *config.Config) HealthThresholds {
+	return HealthThresholds{
+		DegradedKeepaliveAge:     cfg.HealthDegradedKeepaliveAge,
+		StalledKeepaliveAge:      cfg.HealthStalledKeepaliveAge,
+		DegradedSendChannelUsage: cfg.HealthDegradedSendChannelUsage,
+		StalledSendChannelUsage:  cfg.HealthStalledSendChannelUsage,
+	}
+}
+
+// evaluateHealth determines the health of a connection from the age of the
+// last keepalive and the number of messages backed up in the send channel.
+// A zero lastKeepalive or a zero channel capacity means that signal is not
+// available and it is ignored.
+func evaluateHealth(thresholds HealthThresholds, now time.Time, lastKeepalive time.Time, channelDepth int, channelCapacity int) string {
+	keepaliveAge := time.Duration(0)
+	if !lastKeepalive.IsZero() {
+		keepaliveAge = now.Sub(lastKeepalive)
+	}
+
+	channelUsage := 0
+	if channelCapacity > 0 {
+		channelUsage = (channelDepth * 100) / channelCapacity
+	}
+
+	switch {
+	case exceedsDuration(keepaliveAge, thresholds.StalledKeepaliveAge),
+		exceedsUsage(channelUsage, thresholds.StalledSendChannelUsage):
+		return STALLED_STATUS
+	case exceedsDuration(keepaliveAge, thresholds.DegradedKeepaliveAge),
+		exceedsUsage(channelUsage, thresholds.DegradedSendChannelUsage):
+		return DEGRADED_STATUS
+	default:
+		return HEALTHY_STATUS
+	}
+}
+
+func exceedsDuration(value time.Duration, threshold time.Duration) bool {
+	return threshold > 0 && value >= threshold
+}
+
+func exceedsUsage(value int, threshold int) bool {
+	return threshold > 0 && value >= threshold
+}
@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvaluateHealth(t *testing.T) {
+	thresholds := HealthThresholds{
+		DegradedKeepaliveAge:     30 * time.Second,
+		StalledKeepaliveAge:      60 * time.Second,
+		DegradedSendChannelUsage: 50,
+		StalledSendChannelUsage:  100,
+	}
+
+	now := time.Now()
+
+	tests := []struct {
+		name            string
+		lastKeepalive   time.Time
+		channelDepth    int
+		channelCapacity int
+		expected        string
+	}{
+		{"recent keepalive and empty channel", now.Add(-1 * time.Second), 0, 10, HEALTHY_STATUS},
+		{"old keepalive", now.Add(-45 * time.Second), 0, 10, DEGRADED_STATUS},
+		{"very old keepalive", now.Add(-90 * time.Second), 0, 10, STALLED_STATUS},
+		{"half full channel", now, 5, 10, DEGRADED_STATUS},
+		{"full channel", now, 10, 10, STALLED_STATUS},
+		{"full channel and old keepalive", now.Add(-45 * time.Second), 10, 10, STALLED_STATUS},
+		{"no keepalive information", time.Time{}, 0, 10, HEALTHY_STATUS},
+		{"unbuffered channel", now, 0, 0, HEALTHY_STATUS},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := evaluateHealth(thresholds, now, tc.lastKeepalive, tc.channelDepth, tc.channelCapacity)
+			if actual != tc.expected {
+				t.Fatalf("expected: %s, got: %s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestEvaluateHealthWithDisabledThresholds(t *testing.T) {
+	now := time.Now()
+	actual := evaluateHealth(HealthThresholds{}, now, now.Add(-1*time.Hour), 10, 10)
+	if actual != HEALTHY_STATUS {
+		t.Fatalf("expected: %s, got: %s", HEALTHY_STATUS, actual)
+	}
+}
@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+
+	"github.com/google/uuid"
+	kafka "github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// InventoryWriter is implemented by the kafka writer the connection inventory
+// is produced with
+type InventoryWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+type InventoryConnection struct {
+	Account string `json:"account"`
+	NodeID  string `json:"node_id"`
+}
+
+// InventorySnapshot is a chunk of the connections registered with a pod.  The
+// chunks of a snapshot share the snapshot id.
+type InventorySnapshot struct {
+	SnapshotID  string                `json:"snapshot_id"`
+	Pod         string                `json:"pod"`
+	Timestamp   time.Time             `json:"timestamp"`
+	Chunk       int                   `json:"chunk"`
+	TotalChunks int                   `json:"total_chunks"`
+	Connections []InventoryConnection `json:"connections"`
+}
+
+// InventoryExporter periodically produces a snapshot of the connections
+// registered with this pod to a kafka topic, so that downstream services can
+// reconcile their inventory without polling the management api
+type InventoryExporter struct {
+	writer        InventoryWriter
+	connectionMgr ConnectionLocator
+	pod           string
+	interval      time.Duration
+	chunkSize     int
+}
+
+func NewInventoryExporter(w InventoryWriter, cm ConnectionLocator, pod string, cfg *config.Config) *InventoryExporter {
+	return &InventoryExporter{
+		writer:        w,
+		connectionMgr: cm,
+		pod:           pod,
+		interval:      cfg.InventoryExportInterval,
+		chunkSize:     cfg.InventoryExportChunkSize,
+	}
+}
+
+func (e *InventoryExporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.Export(ctx)
+		}
+	}
+}
+
+// Export produces a snapshot of the connections.  A write that takes longer
+// than the export interval is abandoned, since the next snapshot supersedes
+// it.
+func (e *InventoryExporter) Export(ctx context.Context) error {
+	snapshots := e.snapshot(time.Now().UTC())
+
+	msgs := make([]kafka.Message, len(snapshots))
+	for i, snapshot := range snapshots {
+		value, err := json.Marshal(snapshot)
+		if err != nil {
+			logger.Log.WithFields(logrus.Fields{"error": err}).Error("Unable to marshal the connection inventory snapshot")
+			metrics.inventoryExportFailureCounter.Inc()
+			return err
+		}
+		msgs[i] = kafka.Message{Key: []byte(e.pod), Value: value}
+	}
+
+	if e.interval > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.interval)
+		defer cancel()
+	}
+
+	if err := e.writer.WriteMessages(ctx, msgs...); err != nil {
+		logger.Log.WithFields(logrus.Fields{"error": err}).Error("Unable to produce the connection inventory snapshot to kafka")
+		metrics.inventoryExportFailureCounter.Inc()
+		return err
+	}
+
+	logger.Log.Debugf("Exported a connection inventory snapshot in %d chunks", len(snapshots))
+
+	return nil
+}
+
+func (e *InventoryExporter) snapshot(now time.Time) []InventorySnapshot {
+	var connections []InventoryConnection
+	for account, nodes := range e.connectionMgr.GetAllConnections() {
+		for nodeID := range nodes {
+			connections = append(connections, InventoryConnection{Account: account, NodeID: nodeID})
+		}
+	}
+
+	sort.Slice(connections, func(i, j int) bool {
+		if connections[i].Account != connections[j].Account {
+			return connections[i].Account < connections[j].Account
+		}
+		return connections[i].NodeID < connections[j].NodeID
+	})
+
+	chunkSize := e.chunkSize
+	if chunkSize <= 0 {
+		chunkSize = len(connections)
+	}
+
+	// An empty inventory is still exported so that downstream knows the pod
+	// has no connections
+	totalChunks := 1
+	if len(connections) > chunkSize {
+		totalChunks = (len(connections) + chunkSize - 1) / chunkSize
+	}
+
+	snapshotID := uuid.New().String()
+	snapshots := make([]InventorySnapshot, totalChunks)
+	for i := range snapshots {
+		start := i * chunkSize
+		end := start + chunkSize
+		if end > len(connections) {
+			end = len(connections)
+		}
+
+		chunk := connections[start:end]
+		if chunk == nil {
+			chunk = []InventoryConnection{}
+		}
+
+		snapshots[i] = InventorySnapshot{
+			SnapshotID:  snapshotID,
+			Pod:         e.pod,
+			Timestamp:   now,
+			Chunk:       i,
+			TotalChunks: totalChunks,
+			Connections: chunk,
+		}
+	}
+
+	return snapshots
+}
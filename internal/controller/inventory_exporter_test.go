@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+
+	kafka "github.com/segmentio/kafka-go"
+)
+
+type fakeInventoryWriter struct {
+	lock   sync.Mutex
+	msgs   []kafka.Message
+	writes int
+	err    error
+}
+
+func (w *fakeInventoryWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.writes++
+	if w.err != nil {
+		return w.err
+	}
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+func (w *fakeInventoryWriter) getWrites() int {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.writes
+}
+
+func newTestInventoryExporter(w InventoryWriter, chunkSize int) *InventoryExporter {
+	cm := NewLocalConnectionManager()
+	cm.Register("01", "node-a", &ReceptorService{})
+	cm.Register("01", "node-b", &ReceptorService{})
+	cm.Register("02", "node-c", &ReceptorService{})
+
+	cfg := config.GetConfig()
+	cfg.InventoryExportInterval = 10 * time.Millisecond
+	cfg.InventoryExportChunkSize = chunkSize
+
+	return NewInventoryExporter(w, cm, "10.0.0.1", cfg)
+}
+
+func TestInventoryExporterChunksTheSnapshot(t *testing.T) {
+	w := &fakeInventoryWriter{}
+	exporter := newTestInventoryExporter(w, 2)
+
+	if err := exporter.Export(context.TODO()); err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	if len(w.msgs) != 2 {
+		t.Fatalf("Expected 2 chunks, got %d", len(w.msgs))
+	}
+
+	var connections []InventoryConnection
+	var snapshotID string
+	for i, msg := range w.msgs {
+		if string(msg.Key) != "10.0.0.1" {
+			t.Fatalf("Expected the message to be keyed by the pod, got %s", msg.Key)
+		}
+
+		var snapshot InventorySnapshot
+		if err := json.Unmarshal(msg.Value, &snapshot); err != nil {
+			t.Fatalf("Unable to unmarshal the snapshot: %v", err)
+		}
+
+		if snapshot.Pod != "10.0.0.1" || snapshot.Chunk != i || snapshot.TotalChunks != 2 {
+			t.Fatalf("Unexpected snapshot chunk %+v", snapshot)
+		}
+
+		if i == 0 {
+			snapshotID = snapshot.SnapshotID
+		} else if snapshot.SnapshotID != snapshotID {
+			t.Fatalf("Expected the chunks to share the snapshot id %s, got %s", snapshotID, snapshot.SnapshotID)
+		}
+
+		connections = append(connections, snapshot.Connections...)
+	}
+
+	expected := []InventoryConnection{{"01", "node-a"}, {"01", "node-b"}, {"02", "node-c"}}
+	if len(connections) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, connections)
+	}
+	for i := range expected {
+		if connections[i] != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected, connections)
+		}
+	}
+}
+
+func TestInventoryExporterExportsAnEmptyInventory(t *testing.T) {
+	w := &fakeInventoryWriter{}
+	cfg := config.GetConfig()
+	exporter := NewInventoryExporter(w, NewLocalConnectionManager(), "10.0.0.1", cfg)
+
+	if err := exporter.Export(context.TODO()); err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	if len(w.msgs) != 1 {
+		t.Fatalf("Expected a single chunk, got %d", len(w.msgs))
+	}
+
+	var snapshot map[string]interface{}
+	json.Unmarshal(w.msgs[0].Value, &snapshot)
+	if connections, ok := snapshot["connections"].([]interface{}); !ok || len(connections) != 0 {
+		t.Fatalf("Expected an empty list of connections, got %+v", snapshot)
+	}
+}
+
+func TestInventoryExporterReportsWriteFailures(t *testing.T) {
+	writeErr := errors.New("broker unavailable")
+	exporter := newTestInventoryExporter(&fakeInventoryWriter{err: writeErr}, 0)
+
+	if err := exporter.Export(context.TODO()); err != writeErr {
+		t.Fatalf("Expected %v, got %v", writeErr, err)
+	}
+}
+
+func TestInventoryExporterStopsWhenCancelled(t *testing.T) {
+	w := &fakeInventoryWriter{}
+	exporter := newTestInventoryExporter(w, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		exporter.Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for w.getWrites() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the inventory to be exported periodically")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the exporter to stop once the context was cancelled")
+	}
+}
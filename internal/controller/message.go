@@ -1,6 +1,11 @@
 package controller
 
 import (
+	"context"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+
 	"github.com/google/uuid"
 )
 
@@ -10,8 +15,20 @@ type Message struct {
 	RouteList []string
 	Payload   interface{}
 	Directive string
+	ExpiresAt time.Time
+
+	// The request id and trace context are not stored in the SQL outbox
+	RequestID    string
+	TraceContext TraceContext
+}
+
+func (m Message) IsExpired(now time.Time) bool {
+	return !m.ExpiresAt.IsZero() && now.After(m.ExpiresAt)
 }
 
+// EOF_MESSAGE_TYPE is the message type of the last response to a message
+const EOF_MESSAGE_TYPE = "eof"
+
 type ResponseMessage struct {
 	AccountNumber string      `json:"account"`
 	Sender        string      `json:"sender"`
@@ -21,4 +38,34 @@ type ResponseMessage struct {
 	Code          int         `json:"code"`
 	InResponseTo  string      `json:"in_response_to"`
 	Serial        int         `json:"serial"`
+
+	// TraceContext is passed in the kafka message headers
+	TraceContext TraceContext `json:"-"`
+}
+
+// injectRequestID adds the id of the request that the message is sent for to
+// the envelope of a payload message so that the node can log it
+func injectRequestID(msg protocol.Message, requestID string) {
+	payloadMessage, ok := msg.(*protocol.PayloadMessage)
+	if !ok || requestID == "" {
+		return
+	}
+
+	payloadMessage.Data.RequestID = requestID
+}
+
+type messageExpiryKey int
+
+var expiresAtKey messageExpiryKey
+
+// WithMessageExpiry returns a copy of ctx that carries the time after which
+// a message sent using the context should no longer be delivered
+func WithMessageExpiry(ctx context.Context, expiresAt time.Time) context.Context {
+	return context.WithValue(ctx, expiresAtKey, expiresAt)
+}
+
+// GetMessageExpiry returns the message expiration time stored in ctx, if any
+func GetMessageExpiry(ctx context.Context) (time.Time, bool) {
+	expiresAt, ok := ctx.Value(expiresAtKey).(time.Time)
+	return expiresAt, ok
 }
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MessageForwarder hands a message that was queued for a node whose
+// connection to this pod was lost over to the pod that the node reconnects to
+type MessageForwarder interface {
+	ForwardMessage(ctx context.Context, account string, nodeID string, message Message) error
+}
+
+// ForwardedMessageReceiver is implemented by receptors that accept the
+// messages forwarded by another pod.  A forwarded message keeps its original
+// message id.
+type ForwardedMessageReceiver interface {
+	ReceiveForwardedMessage(ctx context.Context, message Message) error
+}
+
+// ReceiveForwardedMessage passes a message that was forwarded by another pod
+// to the transport
+func (r *ReceptorService) ReceiveForwardedMessage(ctx context.Context, message Message) error {
+	// The outbox is shared with the pod that forwarded the message when it
+	// is stored in a database
+	addToOutbox := true
+	if r.outbox != nil {
+		if _, err := r.outbox.Get(ctx, message.MessageID); err == nil {
+			r.updateOutboxStatus(message.MessageID, OUTBOX_PENDING_STATUS)
+			addToOutbox = false
+		}
+	}
+
+	r.logger.WithFields(logrus.Fields{"message_id": message.MessageID}).Info("Received a message forwarded by another pod")
+
+	return r.submit(ctx, message, addToOutbox)
+}
+
+// forwardHeldMessages forwards the messages that were held while the delivery
+// was paused
+func (r *ReceptorService) forwardHeldMessages() {
+	r.pauseLock.Lock()
+	held := r.pausedMessages
+	r.pausedMessages = nil
+	r.pauseLock.Unlock()
+
+	for _, message := range held {
+		go r.forwardMessage(message)
+	}
+}
+
+// forwardMessage forwards the message to the pod that the node reconnects to.
+// The message is marked as forwarded before it is handed over so that the
+// status set by the other pod is not overwritten.
+func (r *ReceptorService) forwardMessage(message Message) {
+	logger := r.logger.WithFields(logrus.Fields{"message_id": message.MessageID})
+
+	if message.IsExpired(time.Now()) {
+		logger.Info("Not forwarding an expired message")
+		metrics.forwardedMessageFailureCounter.Inc()
+		r.updateOutboxStatus(message.MessageID, OUTBOX_EXPIRED_STATUS)
+		r.recordDelivery(message, DELIVERY_OUTCOME_EXPIRED, nil)
+		return
+	}
+
+	r.updateOutboxStatus(message.MessageID, OUTBOX_FORWARDED_STATUS)
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.config.MessageForwardTimeout)
+	defer cancel()
+
+	if err := r.forwarder.ForwardMessage(ctx, r.AccountNumber, r.PeerNodeID, message); err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Info("Unable to forward the message to another pod")
+		metrics.forwardedMessageFailureCounter.Inc()
+		r.updateOutboxStatus(message.MessageID, OUTBOX_FAILED_STATUS)
+		r.recordDelivery(message, DELIVERY_OUTCOME_FAILED, err)
+		return
+	}
+
+	logger.Info("Forwarded the message to another pod")
+	metrics.forwardedMessageCounter.Inc()
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+)
+
+type testMessageForwarder struct {
+	forwarded chan Message
+	err       error
+}
+
+func (f *testMessageForwarder) ForwardMessage(ctx context.Context, account string, nodeID string, message Message) error {
+	f.forwarded <- message
+	return f.err
+}
+
+func newTestForwardingReceptorService(cfg *config.Config, outbox OutboxStore, forwarder MessageForwarder) *ReceptorService {
+	transportCtx, transportCancel := context.WithCancel(context.Background())
+	transport := &Transport{
+		Send:   make(chan ReceptorMessage, 1), // nothing reads from this channel
+		Ctx:    transportCtx,
+		Cancel: transportCancel,
+	}
+	receptor := newTestReceptorServiceWithOutbox(cfg, transport, outbox)
+	receptor.forwarder = forwarder
+	return receptor
+}
+
+func TestReceptorServiceForwardQueuedMessages(t *testing.T) {
+	tests := []struct {
+		name           string
+		forwardErr     error
+		expectedStatus string
+	}{
+		{name: "forwarded", expectedStatus: OUTBOX_FORWARDED_STATUS},
+		{name: "forward failed", forwardErr: errors.New("no pod"), expectedStatus: OUTBOX_FAILED_STATUS},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.GetConfig()
+			cfg.MessageForwardTimeout = time.Second
+			outbox := NewInMemoryOutboxStore()
+			forwarder := &testMessageForwarder{forwarded: make(chan Message, 1), err: tc.forwardErr}
+			receptor := newTestForwardingReceptorService(cfg, outbox, forwarder)
+
+			messageID, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action")
+			if err != nil {
+				t.Fatalf("Expected the error to be nil, got %v", err)
+			}
+
+			closeReceptorService(receptor, receptor.Transport)
+
+			select {
+			case message := <-forwarder.forwarded:
+				if message.MessageID != *messageID {
+					t.Fatalf("Expected message %s to be forwarded, got %s", messageID, message.MessageID)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("Expected the queued message to be forwarded")
+			}
+
+			waitForOutboxStatus(t, outbox, *messageID, tc.expectedStatus)
+		})
+	}
+}
+
+func TestReceptorServiceFailsQueuedMessagesWithoutForwarder(t *testing.T) {
+	outbox := NewInMemoryOutboxStore()
+	receptor := newTestForwardingReceptorService(config.GetConfig(), outbox, nil)
+
+	messageID, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action")
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	closeReceptorService(receptor, receptor.Transport)
+
+	entry, _ := outbox.Get(context.TODO(), *messageID)
+	if entry.Status != OUTBOX_FAILED_STATUS {
+		t.Fatalf("Expected the message to be %s, got %s", OUTBOX_FAILED_STATUS, entry.Status)
+	}
+}
@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"context"
+	"sort"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+
+	"github.com/sirupsen/logrus"
+)
+
+type MetadataUpdateHandler struct {
+	Receptor  *ReceptorService
+	Transport *Transport
+	Logger    *logrus.Entry
+
+	// Listener is notified when the metadata has been updated.  It can be
+	// nil.
+	Listener MetadataUpdateListener
+}
+
+func (mh MetadataUpdateHandler) HandleMessage(ctx context.Context, m protocol.Message) {
+
+	if m.Type() != protocol.MetadataUpdateMessageType {
+		mh.Logger.Infof("Invalid message type (type: %d): %v", m.Type(), m)
+		return
+	}
+
+	metadataUpdateMessage, ok := m.(*protocol.MetadataUpdateMessage)
+	if !ok {
+		mh.Logger.Info("Unable to convert message into MetadataUpdateMessage")
+		return
+	}
+
+	keys := mh.Receptor.UpdateMetadata(metadataUpdateMessage.Metadata)
+	if len(keys) == 0 {
+		mh.Logger.Debug("Received a metadata update without any change")
+		return
+	}
+
+	mh.Logger.WithFields(logrus.Fields{"keys": keys}).Info("Updated the metadata")
+
+	if mh.Listener != nil {
+		mh.Listener.MetadataUpdated(mh.Receptor.AccountNumber, mh.Receptor.PeerNodeID)
+	}
+
+	return
+}
+
+// UpdateMetadata merges the update pushed by the node into the metadata of the
+// connection.  The keys of the update replace the keys of the metadata, a key
+// set to null is removed.  The capabilities are left alone: they are updated
+// with the capabilities messages, which validate them.  The sorted list of the
+// keys that were updated or removed is returned.
+func (r *ReceptorService) UpdateMetadata(update map[string]interface{}) []string {
+	r.metadataLock.Lock()
+	defer r.metadataLock.Unlock()
+
+	metadata := make(map[string]interface{})
+	if currentMetadata, ok := r.Metadata.(map[string]interface{}); ok {
+		for k, v := range currentMetadata {
+			metadata[k] = v
+		}
+	}
+
+	var keys []string
+	for k, v := range update {
+		if k == "capabilities" {
+			r.logger.Debug("Ignoring the capabilities of the metadata update")
+			continue
+		}
+
+		if v == nil {
+			if _, exists := metadata[k]; !exists {
+				continue
+			}
+			delete(metadata, k)
+		} else {
+			metadata[k] = v
+		}
+
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	r.Metadata = metadata
+
+	return keys
+}
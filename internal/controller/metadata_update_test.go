@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMetadataUpdateHandlerMergesTheMetadata(t *testing.T) {
+	receptor := newTestReceptorService(config.GetConfig(), newTestTransport(false))
+	defer receptor.Close(context.TODO())
+
+	capabilities := map[string]interface{}{"max_work_threads": 4}
+	receptor.RegisterConnection(testNodeID,
+		map[string]interface{}{"capabilities": capabilities, "region": "us-east-1", "version": "1.0.0", "failover": true},
+		receptor.Transport)
+
+	broker := NewConnectionEventBroker(10, 0)
+	events := broker.Subscribe("")
+	defer broker.Unsubscribe(events)
+
+	registrar := NewEventPublishingConnectionRegistrar(NewLocalConnectionManager(), broker)
+
+	handler := MetadataUpdateHandler{Receptor: receptor, Transport: receptor.Transport, Logger: receptor.logger,
+		Listener: registrar.(MetadataUpdateListener)}
+
+	handler.HandleMessage(context.TODO(), &protocol.MetadataUpdateMessage{
+		Command: protocol.MetadataUpdateCommand,
+		ID:      testNodeID,
+		Metadata: map[string]interface{}{
+			"region":       "us-west-2",
+			"version":      "1.1.0",
+			"failover":     nil,
+			"capabilities": "ignored",
+		},
+	})
+
+	metadata, _ := receptor.GetMetadata(context.TODO())
+
+	expected := map[string]interface{}{"capabilities": capabilities, "region": "us-west-2", "version": "1.1.0"}
+	if diff := cmp.Diff(expected, metadata); diff != "" {
+		t.Fatalf("Unexpected metadata (-want +got):\n%s", diff)
+	}
+
+	event := <-events.Events
+	if event.Type != CONNECTION_EVENT_METADATA_UPDATED || event.Account != testAccount || event.NodeID != testNodeID {
+		t.Fatalf("Expected a metadata updated event, got %+v", event)
+	}
+
+	// An update that does not change anything is not published
+	handler.HandleMessage(context.TODO(), &protocol.MetadataUpdateMessage{
+		Command:  protocol.MetadataUpdateCommand,
+		ID:       testNodeID,
+		Metadata: map[string]interface{}{"failover": nil},
+	})
+
+	if len(events.Events) != 0 {
+		t.Fatalf("Expected no more events, got %d", len(events.Events))
+	}
+}
+
+func TestReceptorServiceUpdateMetadataWithoutHandshakeMetadata(t *testing.T) {
+	receptor := newTestReceptorService(config.GetConfig(), newTestTransport(false))
+	defer receptor.Close(context.TODO())
+
+	keys := receptor.UpdateMetadata(map[string]interface{}{"version": "1.1.0", "region": "us-west-2"})
+	if diff := cmp.Diff([]string{"region", "version"}, keys); diff != "" {
+		t.Fatalf("Unexpected updated keys (-want +got):\n%s", diff)
+	}
+
+	metadata, _ := receptor.GetMetadata(context.TODO())
+	if diff := cmp.Diff(map[string]interface{}{"region": "us-west-2", "version": "1.1.0"}, metadata); diff != "" {
+		t.Fatalf("Unexpected metadata (-want +got):\n%s", diff)
+	}
+}
@@ -1,24 +1,46 @@
 package controller
 
 import (
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/openmetrics"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"time"
 )
 
 type Metrics struct {
-	pingElapsed                          *prometheus.HistogramVec
-	duplicateConnectionCounter           prometheus.Counter
-	responseKafkaWriterGoRoutineGauge    prometheus.Gauge
-	responseKafkaWriterFailureCounter    prometheus.Counter
-	responseMessageWithoutHandlerCounter prometheus.Counter
-	responseMessageHandledCounter        prometheus.Counter
+	pingElapsed                              *openmetrics.HistogramVec
+	duplicateConnectionCounter               prometheus.Counter
+	tooManyConnectionsCounter                prometheus.Counter
+	connectionQuotaWarningCounter            prometheus.Counter
+	rejectedConnectionCounter                prometheus.Counter
+	invalidMetadataCounter                   prometheus.Counter
+	incompatibleProtocolCounter              prometheus.Counter
+	rejectedDirectiveCounter                 prometheus.Counter
+	responseKafkaWriterGoRoutineGauge        prometheus.Gauge
+	responseKafkaWriterFailureCounter        prometheus.Counter
+	responseMessageWithoutHandlerCounter     prometheus.Counter
+	responseMessageHandledCounter            prometheus.Counter
+	outboxStuckMessagesGauge                 prometheus.Gauge
+	outboxFailedMessagesCounter              prometheus.Counter
+	connectionEventSubscribersGauge          prometheus.Gauge
+	connectionEventSubscribersDroppedCounter prometheus.Counter
+	connectionEventWebhookDeadLetterCounter  prometheus.Counter
+	inventoryExportFailureCounter            prometheus.Counter
+	deliveryReceiptFailureCounter            prometheus.Counter
+	backpressureCounter                      prometheus.Counter
+	rateLimitedMessageCounter                prometheus.Counter
+	quotaExceededMessageCounter              prometheus.Counter
+	capabilitiesWarmingCounter               *prometheus.CounterVec
+	sentMessageCounter                       *prometheus.CounterVec
+	sentMessagePayloadBytes                  *prometheus.HistogramVec
+	forwardedMessageCounter                  prometheus.Counter
+	forwardedMessageFailureCounter           prometheus.Counter
 }
 
 func NewMetrics() *Metrics {
 	metrics := new(Metrics)
 
-	metrics.pingElapsed = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	metrics.pingElapsed = openmetrics.NewHistogramVec(prometheus.HistogramOpts{
 		Name: "receptor_controller_ping_seconds",
 		Help: "Number of seconds spent waiting on a synchronous ping",
 	},
@@ -30,6 +52,36 @@ func NewMetrics() *Metrics {
 		Help: "The number of receptor websocket connections with the same account number and node id",
 	})
 
+	metrics.tooManyConnectionsCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "receptor_controller_too_many_connections_count",
+		Help: "The number of receptor websocket connections rejected because the account reached its connection limit",
+	})
+
+	metrics.connectionQuotaWarningCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "receptor_controller_connection_quota_warning_count",
+		Help: "The number of times an account reached its soft connection limit",
+	})
+
+	metrics.rejectedConnectionCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "receptor_controller_rejected_connection_count",
+		Help: "The number of receptor websocket connections rejected by the connection policy",
+	})
+
+	metrics.invalidMetadataCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "receptor_controller_invalid_metadata_count",
+		Help: "The number of receptor websocket connections whose metadata did not match the connection metadata schema",
+	})
+
+	metrics.incompatibleProtocolCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "receptor_controller_incompatible_protocol_version_count",
+		Help: "The number of receptor websocket connections rejected because the node speaks an unsupported version of the protocol",
+	})
+
+	metrics.rejectedDirectiveCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "receptor_controller_rejected_directive_count",
+		Help: "The number of messages rejected because their directive is not allowed",
+	})
+
 	metrics.responseKafkaWriterGoRoutineGauge = promauto.NewGauge(prometheus.GaugeOpts{
 		Name: "receptor_controller_kafka_response_writer_go_routine_count",
 		Help: "The total number of active kakfa response writer go routines",
@@ -50,12 +102,89 @@ func NewMetrics() *Metrics {
 		Help: "The number of response messages handled",
 	})
 
+	metrics.outboxStuckMessagesGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "receptor_controller_outbox_stuck_message_count",
+		Help: "The number of messages found stuck in the pending state by the last outbox sweep",
+	})
+
+	metrics.outboxFailedMessagesCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "receptor_controller_outbox_failed_message_count",
+		Help: "The number of messages marked as failed or expired by the outbox sweeper",
+	})
+
+	metrics.connectionEventSubscribersGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "receptor_controller_connection_event_subscriber_count",
+		Help: "The number of active connection event subscribers",
+	})
+
+	metrics.connectionEventSubscribersDroppedCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "receptor_controller_connection_event_subscriber_dropped_count",
+		Help: "The number of connection event subscribers dropped for falling behind",
+	})
+
+	metrics.connectionEventWebhookDeadLetterCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "receptor_controller_connection_event_webhook_dead_letter_count",
+		Help: "The number of connection events that could not be delivered to the webhook",
+	})
+
+	metrics.inventoryExportFailureCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "receptor_controller_inventory_export_failure_count",
+		Help: "The number of connection inventory snapshots that failed to get produced to kafka topic",
+	})
+
+	metrics.deliveryReceiptFailureCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "receptor_controller_delivery_receipt_failure_count",
+		Help: "The number of delivery receipts that failed to get produced to kafka topic",
+	})
+
+	metrics.backpressureCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "receptor_controller_backpressure_count",
+		Help: "The number of messages rejected because too many messages were waiting to be sent to the node",
+	})
+
+	metrics.rateLimitedMessageCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "receptor_controller_rate_limited_message_count",
+		Help: "The number of messages rejected because they were sent to the node faster than its send rate limit",
+	})
+
+	metrics.quotaExceededMessageCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "receptor_controller_quota_exceeded_message_count",
+		Help: "The number of messages rejected because the account exceeded its bandwidth quota",
+	})
+
+	metrics.capabilitiesWarmingCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "receptor_controller_capabilities_warming_count",
+		Help: "The number of capabilities fetched in the background after a connection was registered, by result (warmed, failed or skipped)",
+	}, []string{"result"})
+
+	metrics.sentMessageCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "receptor_controller_sent_message_count",
+		Help: "The number of messages passed to the receptor nodes by directive",
+	}, []string{"directive", "account"})
+
+	metrics.sentMessagePayloadBytes = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "receptor_controller_sent_message_payload_bytes",
+		Help:    "The size of the payloads of the messages passed to the receptor nodes by directive",
+		Buckets: prometheus.ExponentialBuckets(64, 4, 8),
+	}, []string{"directive"})
+
+	metrics.forwardedMessageCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "receptor_controller_forwarded_message_count",
+		Help: "The number of queued messages forwarded to the pod that the receptor node reconnected to",
+	})
+
+	metrics.forwardedMessageFailureCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "receptor_controller_forwarded_message_failure_count",
+		Help: "The number of queued messages that could not be forwarded to another pod",
+	})
+
 	return metrics
 }
 
 type DurationRecorder struct {
-	elapsed   *prometheus.HistogramVec
+	elapsed   *openmetrics.HistogramVec
 	labels    prometheus.Labels
+	traceID   string
 	startTime time.Time
 }
 
@@ -66,7 +195,7 @@ func (dr *DurationRecorder) Start() {
 func (dr *DurationRecorder) Stop() {
 	recordedDuration := time.Since(dr.startTime)
 	if dr.elapsed != nil {
-		dr.elapsed.With(dr.labels).Observe(recordedDuration.Seconds())
+		dr.elapsed.ObserveWithTraceID(dr.labels, recordedDuration.Seconds(), dr.traceID)
 	}
 }
 
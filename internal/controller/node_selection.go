@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ROUND_ROBIN_NODE_SELECTION = "round_robin"
+	FIRST_NODE_SELECTION       = "first"
+)
+
+// maxNodeWeight is the weight of a node that has no messages waiting to be
+// sent to it
+const maxNodeWeight = 100
+
+type NoConnectionsError struct {
+}
+
+func (e NoConnectionsError) Error() string {
+	return "no connections found for the account"
+}
+
+// QueueDepthReporter is implemented by receptors that can report the number
+// of messages that are waiting to be written to the node
+type QueueDepthReporter interface {
+	GetQueueDepth() int
+}
+
+// GetQueueDepth returns the number of messages that have been passed to the
+// transport but not written to the node yet
+func (r *ReceptorService) GetQueueDepth() int {
+	return r.getInFlightCount()
+}
+
+// NodeSelector picks the node of an account that a message should be sent to
+type NodeSelector interface {
+	SelectNode(account string, connections map[string]Receptor) (string, Receptor)
+}
+
+// NewNodeSelector returns the node selection strategy selected by the
+// configuration
+func NewNodeSelector(cfg *config.Config) (NodeSelector, error) {
+	switch cfg.NodeSelectionStrategy {
+	case ROUND_ROBIN_NODE_SELECTION:
+		return NewWeightedRoundRobinSelector(), nil
+	case FIRST_NODE_SELECTION:
+		return FirstNodeSelector{}, nil
+	default:
+		return nil, errors.New("invalid node selection strategy " + cfg.NodeSelectionStrategy)
+	}
+}
+
+// FirstNodeSelector always picks the first node (ordered by node id)
+type FirstNodeSelector struct {
+}
+
+func (s FirstNodeSelector) SelectNode(account string, connections map[string]Receptor) (string, Receptor) {
+	nodeIDs := sortedNodeIDs(connections)
+	if len(nodeIDs) == 0 {
+		return "", nil
+	}
+	return nodeIDs[0], connections[nodeIDs[0]]
+}
+
+// WeightedRoundRobinSelector spreads the messages across the nodes of an
+// account using a smooth weighted round-robin.  The weight of a node
+// decreases with the number of messages waiting to be sent to it, so that the
+// nodes that are backed up are picked less often.  Nodes that do not report
+// their queue depth (e.g. the connections attached to another pod) get the
+// full weight.
+type WeightedRoundRobinSelector struct {
+	// currentWeights holds the current weight of each node, by account
+	currentWeights map[string]map[string]int
+	sync.Mutex
+}
+
+func NewWeightedRoundRobinSelector() *WeightedRoundRobinSelector {
+	return &WeightedRoundRobinSelector{
+		currentWeights: make(map[string]map[string]int),
+	}
+}
+
+func (s *WeightedRoundRobinSelector) SelectNode(account string, connections map[string]Receptor) (string, Receptor) {
+	s.Lock()
+	defer s.Unlock()
+
+	nodeIDs := sortedNodeIDs(connections)
+	if len(nodeIDs) == 0 {
+		delete(s.currentWeights, account)
+		return "", nil
+	}
+
+	// The weights of the nodes that are no longer connected are dropped
+	previousWeights := s.currentWeights[account]
+	currentWeights := make(map[string]int, len(nodeIDs))
+
+	selected := ""
+	totalWeight := 0
+	for _, nodeID := range nodeIDs {
+		weight := nodeWeight(connections[nodeID])
+		totalWeight += weight
+		currentWeights[nodeID] = previousWeights[nodeID] + weight
+		if selected == "" || currentWeights[nodeID] > currentWeights[selected] {
+			selected = nodeID
+		}
+	}
+
+	currentWeights[selected] -= totalWeight
+	s.currentWeights[account] = currentWeights
+
+	return selected, connections[selected]
+}
+
+func nodeWeight(client Receptor) int {
+	reporter, ok := client.(QueueDepthReporter)
+	if !ok {
+		return maxNodeWeight
+	}
+
+	weight := maxNodeWeight / (1 + reporter.GetQueueDepth())
+	if weight < 1 {
+		weight = 1
+	}
+	return weight
+}
+
+func sortedNodeIDs(connections map[string]Receptor) []string {
+	nodeIDs := make([]string, 0, len(connections))
+	for nodeID := range connections {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Strings(nodeIDs)
+	return nodeIDs
+}
+
+// AccountDispatcher sends messages to any one of the nodes of an account
+type AccountDispatcher struct {
+	locator  ConnectionLocator
+	selector NodeSelector
+}
+
+func NewAccountDispatcher(locator ConnectionLocator, selector NodeSelector) *AccountDispatcher {
+	return &AccountDispatcher{locator: locator, selector: selector}
+}
+
+// SendToAccount sends the message to the node picked by the node selector.
+// The quarantined connections are not considered.  The node id of the
+// recipient is returned along with the message id.
+func (d *AccountDispatcher) SendToAccount(ctx context.Context, account string, payload interface{}, directive string) (string, *uuid.UUID, error) {
+	connections := make(map[string]Receptor)
+	for nodeID, client := range d.locator.GetConnectionsByAccount(account) {
+		if !isQuarantined(ctx, client) {
+			connections[nodeID] = client
+		}
+	}
+
+	nodeID, client := d.selector.SelectNode(account, connections)
+	if client == nil {
+		return "", nil, NoConnectionsError{}
+	}
+
+	messageID, err := client.SendMessage(ctx, account, nodeID, []string{nodeID}, payload, directive)
+	if err != nil {
+		return nodeID, nil, err
+	}
+
+	return nodeID, messageID, nil
+}
@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+
+	"github.com/google/uuid"
+)
+
+// queuedMockReceptor reports a fixed queue depth and counts the messages sent to it
+type queuedMockReceptor struct {
+	MockReceptor
+	queueDepth int
+	sent       int
+}
+
+func (mr *queuedMockReceptor) SendMessage(context.Context, string, string, []string, interface{}, string) (*uuid.UUID, error) {
+	mr.sent++
+	messageID := uuid.New()
+	return &messageID, nil
+}
+
+func (mr *queuedMockReceptor) GetQueueDepth() int {
+	return mr.queueDepth
+}
+
+func countSelections(selector NodeSelector, connections map[string]Receptor, sends int) map[string]int {
+	selections := make(map[string]int)
+	for i := 0; i < sends; i++ {
+		nodeID, _ := selector.SelectNode("1234", connections)
+		selections[nodeID]++
+	}
+	return selections
+}
+
+func TestWeightedRoundRobinSelectorSpreadsMessagesEvenly(t *testing.T) {
+	connections := map[string]Receptor{
+		"node-a": &queuedMockReceptor{},
+		"node-b": &queuedMockReceptor{},
+		"node-c": &queuedMockReceptor{},
+	}
+
+	selections := countSelections(NewWeightedRoundRobinSelector(), connections, 300)
+
+	for nodeID := range connections {
+		if selections[nodeID] != 100 {
+			t.Fatalf("Expected node %s to be selected 100 times, got %v", nodeID, selections)
+		}
+	}
+}
+
+func TestWeightedRoundRobinSelectorDeprioritizesBackedUpNodes(t *testing.T) {
+	connections := map[string]Receptor{
+		"node-a": &queuedMockReceptor{},
+		"node-b": &queuedMockReceptor{queueDepth: 9},
+	}
+
+	selections := countSelections(NewWeightedRoundRobinSelector(), connections, 110)
+
+	if selections["node-a"] != 100 || selections["node-b"] != 10 {
+		t.Fatalf("Expected the backed up node to be selected 10 times out of 110, got %v", selections)
+	}
+}
+
+func TestWeightedRoundRobinSelectorForgetsDisconnectedNodes(t *testing.T) {
+	selector := NewWeightedRoundRobinSelector()
+
+	countSelections(selector, map[string]Receptor{"node-a": &queuedMockReceptor{}, "node-b": &queuedMockReceptor{}}, 3)
+
+	nodeID, _ := selector.SelectNode("1234", map[string]Receptor{})
+	if nodeID != "" {
+		t.Fatalf("Expected no node to be selected, got %s", nodeID)
+	}
+
+	if _, exists := selector.currentWeights["1234"]; exists {
+		t.Fatalf("Expected the weights of the account to be dropped")
+	}
+}
+
+func TestAccountDispatcherSendToAccount(t *testing.T) {
+	first := &queuedMockReceptor{}
+	second := &queuedMockReceptor{}
+
+	cm := NewLocalConnectionManager()
+	cm.Register("1234", "node-a", first)
+	cm.Register("1234", "node-b", second)
+
+	dispatcher := NewAccountDispatcher(cm, NewWeightedRoundRobinSelector())
+
+	for i := 0; i < 4; i++ {
+		nodeID, messageID, err := dispatcher.SendToAccount(context.TODO(), "1234", "payload", "worker:action")
+		if err != nil {
+			t.Fatalf("Expected the error to be nil, got %v", err)
+		}
+		if nodeID == "" || messageID == nil {
+			t.Fatalf("Expected the node id and message id to be returned")
+		}
+	}
+
+	if first.sent != 2 || second.sent != 2 {
+		t.Fatalf("Expected 2 messages to be sent to each node, got %d and %d", first.sent, second.sent)
+	}
+
+	_, _, err := dispatcher.SendToAccount(context.TODO(), "5678", "payload", "worker:action")
+	if err != (NoConnectionsError{}) {
+		t.Fatalf("Expected %v, got %v", NoConnectionsError{}, err)
+	}
+}
+
+func TestNewNodeSelector(t *testing.T) {
+	cfg := config.GetConfig()
+
+	cfg.NodeSelectionStrategy = FIRST_NODE_SELECTION
+	if selector, err := NewNodeSelector(cfg); err != nil || selector != (FirstNodeSelector{}) {
+		t.Fatalf("Expected the first node selector, got %v (%v)", selector, err)
+	}
+
+	cfg.NodeSelectionStrategy = "random"
+	if _, err := NewNodeSelector(cfg); err == nil {
+		t.Fatalf("Expected an invalid strategy to be rejected")
+	}
+}
@@ -0,0 +1,207 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+
+	"github.com/google/uuid"
+)
+
+const (
+	OUTBOX_PENDING_STATUS      = "pending"
+	OUTBOX_SENT_STATUS         = "sent"
+	OUTBOX_ACKNOWLEDGED_STATUS = "acknowledged"
+	OUTBOX_FAILED_STATUS       = "failed"
+	OUTBOX_FORWARDED_STATUS    = "forwarded"
+	OUTBOX_COMPLETED_STATUS    = "completed"
+	OUTBOX_CANCELLING_STATUS   = "cancelling"
+	OUTBOX_CANCELLED_STATUS    = "cancelled"
+	OUTBOX_EXPIRED_STATUS      = "expired"
+)
+
+// IsFinalOutboxStatus reports whether the job has ended.  The status of a
+// job that has ended is not changed anymore.
+func IsFinalOutboxStatus(status string) bool {
+	switch status {
+	case OUTBOX_COMPLETED_STATUS, OUTBOX_FAILED_STATUS, OUTBOX_CANCELLED_STATUS, OUTBOX_EXPIRED_STATUS:
+		return true
+	}
+	return false
+}
+
+// IsPrunableOutboxStatus reports whether the entry can be removed from the
+// outbox once the retention is over.  The entries that are still in flight
+// (pending, forwarded or cancelling) are kept.
+func IsPrunableOutboxStatus(status string) bool {
+	switch status {
+	case OUTBOX_SENT_STATUS, OUTBOX_ACKNOWLEDGED_STATUS:
+		return true
+	}
+	return IsFinalOutboxStatus(status)
+}
+
+type OutboxEntryNotFoundError struct {
+}
+
+func (e OutboxEntryNotFoundError) Error() string {
+	return "outbox entry not found"
+}
+
+// OutboxEntry records a message that has been accepted by SendMessage along
+// with its delivery status
+type OutboxEntry struct {
+	AccountNumber string
+
+	// NodeID is the node id of the connection that the message was sent on
+	NodeID string
+
+	Message   Message
+	Status    string
+	Attempts  int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (e OutboxEntry) IsExpired(now time.Time) bool {
+	return !e.Message.ExpiresAt.IsZero() && now.After(e.Message.ExpiresAt)
+}
+
+// OutboxStore persists messages before they are passed to the transport so
+// that a message is never lost without a record
+type OutboxStore interface {
+	Add(ctx context.Context, entry OutboxEntry) error
+	Get(ctx context.Context, messageID uuid.UUID) (*OutboxEntry, error)
+
+	// UpdateStatus returns a JobCompletedError, without changing the status,
+	// if the job has already ended
+	UpdateStatus(ctx context.Context, messageID uuid.UUID, status string) error
+	RecordAttempt(ctx context.Context, messageID uuid.UUID) error
+
+	// GetPending returns the pending entries that have not been updated since updatedBefore
+	GetPending(ctx context.Context, updatedBefore time.Time) ([]OutboxEntry, error)
+
+	// Prune removes the sent, acknowledged and ended (see IsFinalOutboxStatus)
+	// entries that have not been updated since updatedBefore
+	Prune(ctx context.Context, updatedBefore time.Time) (int, error)
+}
+
+// NewOutboxStore returns the outbox store implementation selected by the configuration
+func NewOutboxStore(cfg *config.Config) (OutboxStore, error) {
+	switch cfg.OutboxStoreImpl {
+	case "memory":
+		return NewInMemoryOutboxStore(), nil
+	case "sql":
+		db, err := sql.Open(cfg.OutboxDatabaseDriver, cfg.OutboxDatabaseUrl)
+		if err != nil {
+			return nil, err
+		}
+		store := NewSQLOutboxStore(db)
+		if err := store.CreateSchema(context.Background()); err != nil {
+			return nil, err
+		}
+		return store, nil
+	default:
+		return nil, errors.New("invalid outbox store implementation " + cfg.OutboxStoreImpl)
+	}
+}
+
+type InMemoryOutboxStore struct {
+	entries map[uuid.UUID]*OutboxEntry
+	sync.RWMutex
+}
+
+func NewInMemoryOutboxStore() *InMemoryOutboxStore {
+	return &InMemoryOutboxStore{
+		entries: make(map[uuid.UUID]*OutboxEntry),
+	}
+}
+
+func (s *InMemoryOutboxStore) Add(ctx context.Context, entry OutboxEntry) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.entries[entry.Message.MessageID] = &entry
+
+	return nil
+}
+
+func (s *InMemoryOutboxStore) Get(ctx context.Context, messageID uuid.UUID) (*OutboxEntry, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	entry, exists := s.entries[messageID]
+	if !exists {
+		return nil, OutboxEntryNotFoundError{}
+	}
+
+	entryCopy := *entry
+	return &entryCopy, nil
+}
+
+func (s *InMemoryOutboxStore) UpdateStatus(ctx context.Context, messageID uuid.UUID, status string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	entry, exists := s.entries[messageID]
+	if !exists {
+		return OutboxEntryNotFoundError{}
+	}
+
+	if IsFinalOutboxStatus(entry.Status) {
+		return JobCompletedError{}
+	}
+
+	entry.Status = status
+	entry.UpdatedAt = time.Now().UTC()
+
+	return nil
+}
+
+func (s *InMemoryOutboxStore) RecordAttempt(ctx context.Context, messageID uuid.UUID) error {
+	s.Lock()
+	defer s.Unlock()
+
+	entry, exists := s.entries[messageID]
+	if !exists {
+		return OutboxEntryNotFoundError{}
+	}
+
+	entry.Attempts++
+	entry.UpdatedAt = time.Now().UTC()
+
+	return nil
+}
+
+func (s *InMemoryOutboxStore) GetPending(ctx context.Context, updatedBefore time.Time) ([]OutboxEntry, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	var pending []OutboxEntry
+	for _, entry := range s.entries {
+		if entry.Status == OUTBOX_PENDING_STATUS && entry.UpdatedAt.Before(updatedBefore) {
+			pending = append(pending, *entry)
+		}
+	}
+
+	return pending, nil
+}
+
+func (s *InMemoryOutboxStore) Prune(ctx context.Context, updatedBefore time.Time) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	pruned := 0
+	for messageID, entry := range s.entries {
+		if IsPrunableOutboxStatus(entry.Status) && entry.UpdatedAt.Before(updatedBefore) {
+			delete(s.entries, messageID)
+			pruned++
+		}
+	}
+
+	return pruned, nil
+}
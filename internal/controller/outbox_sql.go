@@ -0,0 +1,227 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The queries use postgres style placeholders.  The postgres driver is linked
+// into the gateway and the job receiver.
+const (
+	createOutboxTable = `CREATE TABLE IF NOT EXISTS receptor_controller_outbox (
+		message_id VARCHAR(36) PRIMARY KEY,
+		account VARCHAR(64) NOT NULL,
+		node_id VARCHAR(255) NOT NULL DEFAULT '',
+		recipient VARCHAR(255) NOT NULL,
+		route TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		directive VARCHAR(255) NOT NULL,
+		expires_at TIMESTAMP NULL,
+		status VARCHAR(16) NOT NULL,
+		attempts INTEGER NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL)`
+
+	// The node id column was added after the table was first created
+	addOutboxNodeIDColumn = `ALTER TABLE receptor_controller_outbox ADD COLUMN IF NOT EXISTS node_id VARCHAR(255) NOT NULL DEFAULT ''`
+
+	insertOutboxEntry = `INSERT INTO receptor_controller_outbox
+		(message_id, account, node_id, recipient, route, payload, directive, expires_at, status, attempts, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+
+	selectOutboxEntries = `SELECT message_id, account, node_id, recipient, route, payload, directive, expires_at, status, attempts, created_at, updated_at
+		FROM receptor_controller_outbox`
+
+	selectOutboxEntry = selectOutboxEntries + ` WHERE message_id = $1`
+
+	selectPendingOutboxEntries = selectOutboxEntries + ` WHERE status = $1 AND updated_at < $2`
+
+	// The status of a job that has ended is not changed anymore
+	updateOutboxEntryStatus = `UPDATE receptor_controller_outbox SET status = $1, updated_at = $2 WHERE message_id = $3
+		AND status NOT IN (` + finalOutboxStatuses + `)`
+
+	updateOutboxEntryAttempts = `UPDATE receptor_controller_outbox SET attempts = attempts + 1, updated_at = $1 WHERE message_id = $2`
+
+	deleteOutboxEntries = `DELETE FROM receptor_controller_outbox WHERE status IN ('sent', 'acknowledged', ` + finalOutboxStatuses + `)
+		AND updated_at < $1`
+
+	// finalOutboxStatuses matches IsFinalOutboxStatus
+	finalOutboxStatuses = `'completed', 'failed', 'cancelled', 'expired'`
+)
+
+type SQLOutboxStore struct {
+	db *sql.DB
+}
+
+func NewSQLOutboxStore(db *sql.DB) *SQLOutboxStore {
+	return &SQLOutboxStore{db: db}
+}
+
+// CreateSchema creates the outbox table if it does not already exist
+func (s *SQLOutboxStore) CreateSchema(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, createOutboxTable); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, addOutboxNodeIDColumn)
+	return err
+}
+
+func (s *SQLOutboxStore) Add(ctx context.Context, entry OutboxEntry) error {
+	route, err := json.Marshal(entry.Message.RouteList)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(entry.Message.Payload)
+	if err != nil {
+		return err
+	}
+
+	var expiresAt sql.NullTime
+	if !entry.Message.ExpiresAt.IsZero() {
+		expiresAt = sql.NullTime{Time: entry.Message.ExpiresAt, Valid: true}
+	}
+
+	_, err = s.db.ExecContext(ctx, insertOutboxEntry,
+		entry.Message.MessageID.String(),
+		entry.AccountNumber,
+		entry.NodeID,
+		entry.Message.Recipient,
+		string(route),
+		string(payload),
+		entry.Message.Directive,
+		expiresAt,
+		entry.Status,
+		entry.Attempts,
+		entry.CreatedAt,
+		entry.UpdatedAt)
+
+	return err
+}
+
+func (s *SQLOutboxStore) Get(ctx context.Context, messageID uuid.UUID) (*OutboxEntry, error) {
+	row := s.db.QueryRowContext(ctx, selectOutboxEntry, messageID.String())
+
+	entry, err := scanOutboxEntry(row)
+	if err == sql.ErrNoRows {
+		return nil, OutboxEntryNotFoundError{}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+func (s *SQLOutboxStore) UpdateStatus(ctx context.Context, messageID uuid.UUID, status string) error {
+	result, err := s.db.ExecContext(ctx, updateOutboxEntryStatus, status, time.Now().UTC(), messageID.String())
+	if err != nil {
+		return err
+	}
+
+	if err := verifyRowsAffected(result); err != nil {
+		// The entry exists, but its job has already ended
+		if _, getErr := s.Get(ctx, messageID); getErr == nil {
+			return JobCompletedError{}
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *SQLOutboxStore) RecordAttempt(ctx context.Context, messageID uuid.UUID) error {
+	result, err := s.db.ExecContext(ctx, updateOutboxEntryAttempts, time.Now().UTC(), messageID.String())
+	if err != nil {
+		return err
+	}
+	return verifyRowsAffected(result)
+}
+
+func (s *SQLOutboxStore) GetPending(ctx context.Context, updatedBefore time.Time) ([]OutboxEntry, error) {
+	rows, err := s.db.QueryContext(ctx, selectPendingOutboxEntries, OUTBOX_PENDING_STATUS, updatedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pending []OutboxEntry
+	for rows.Next() {
+		entry, err := scanOutboxEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, *entry)
+	}
+
+	return pending, rows.Err()
+}
+
+func (s *SQLOutboxStore) Prune(ctx context.Context, updatedBefore time.Time) (int, error) {
+	result, err := s.db.ExecContext(ctx, deleteOutboxEntries, updatedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	pruned, err := result.RowsAffected()
+	return int(pruned), err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanOutboxEntry(row rowScanner) (*OutboxEntry, error) {
+	var entry OutboxEntry
+	var messageID, route, payload string
+	var expiresAt sql.NullTime
+
+	err := row.Scan(&messageID,
+		&entry.AccountNumber,
+		&entry.NodeID,
+		&entry.Message.Recipient,
+		&route,
+		&payload,
+		&entry.Message.Directive,
+		&expiresAt,
+		&entry.Status,
+		&entry.Attempts,
+		&entry.CreatedAt,
+		&entry.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.Message.MessageID, err = uuid.Parse(messageID); err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal([]byte(route), &entry.Message.RouteList); err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal([]byte(payload), &entry.Message.Payload); err != nil {
+		return nil, err
+	}
+	entry.Message.Payload = DecodePayload(entry.Message.Payload)
+
+	if expiresAt.Valid {
+		entry.Message.ExpiresAt = expiresAt.Time
+	}
+
+	return &entry, nil
+}
+
+func verifyRowsAffected(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return OutboxEntryNotFoundError{}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MessageResender is implemented by receptors that can resend a message from
+// the outbox using its original message id
+type MessageResender interface {
+	ResendMessage(ctx context.Context, entry OutboxEntry) error
+}
+
+// OutboxSweeper periodically looks for messages that have been stuck in the
+// pending state.  Stuck messages are resent if the connection to the recipient
+// is attached to this pod.  Messages that have expired are marked as expired,
+// and messages that have run out of attempts are marked as failed.
+type OutboxSweeper struct {
+	store            OutboxStore
+	connectionMgr    ConnectionLocator
+	interval         time.Duration
+	pendingThreshold time.Duration
+	maxAttempts      int
+	retention        time.Duration
+}
+
+func NewOutboxSweeper(store OutboxStore, cm ConnectionLocator, cfg *config.Config) *OutboxSweeper {
+	return &OutboxSweeper{
+		store:            store,
+		connectionMgr:    cm,
+		interval:         cfg.OutboxSweepInterval,
+		pendingThreshold: cfg.OutboxPendingThreshold,
+		maxAttempts:      cfg.OutboxMaxAttempts,
+		retention:        cfg.OutboxRetention,
+	}
+}
+
+func (s *OutboxSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Sweep(ctx)
+		}
+	}
+}
+
+func (s *OutboxSweeper) Sweep(ctx context.Context) {
+	now := time.Now().UTC()
+
+	pending, err := s.store.GetPending(ctx, now.Add(-s.pendingThreshold))
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{"error": err}).Error("Unable to retrieve the pending messages from the outbox")
+		return
+	}
+
+	metrics.outboxStuckMessagesGauge.Set(float64(len(pending)))
+
+	for _, entry := range pending {
+		s.sweepEntry(ctx, now, entry)
+	}
+
+	if s.retention > 0 {
+		pruned, err := s.store.Prune(ctx, now.Add(-s.retention))
+		if err != nil {
+			logger.Log.WithFields(logrus.Fields{"error": err}).Error("Unable to prune the outbox")
+		} else if pruned > 0 {
+			logger.Log.Debugf("Pruned %d messages from the outbox", pruned)
+		}
+	}
+}
+
+func (s *OutboxSweeper) sweepEntry(ctx context.Context, now time.Time, entry OutboxEntry) {
+	logger := logger.Log.WithFields(logrus.Fields{
+		"account":    entry.AccountNumber,
+		"recipient":  entry.Message.Recipient,
+		"message_id": entry.Message.MessageID,
+		"attempts":   entry.Attempts})
+
+	switch {
+	case entry.IsExpired(now):
+		logger.Warn("Message expired before it was sent")
+		s.markFailed(ctx, logger, entry, OUTBOX_EXPIRED_STATUS)
+		return
+	case entry.Attempts >= s.maxAttempts:
+		logger.Warn("Message was not sent after the maximum number of attempts")
+		s.markFailed(ctx, logger, entry, OUTBOX_FAILED_STATUS)
+		return
+	}
+
+	client := s.connectionMgr.GetConnection(entry.AccountNumber, entry.Message.Recipient)
+	if client == nil {
+		logger.Debug("No connection to the recipient...leaving the message pending")
+		return
+	}
+
+	resender, ok := client.(MessageResender)
+	if !ok {
+		logger.Debug("Connection to the recipient does not support resending messages...leaving the message pending")
+		return
+	}
+
+	logger.Info("Resending a pending message")
+	if err := resender.ResendMessage(ctx, entry); err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Info("Unable to resend the pending message")
+	}
+}
+
+func (s *OutboxSweeper) markFailed(ctx context.Context, logger *logrus.Entry, entry OutboxEntry, status string) {
+	metrics.outboxFailedMessagesCounter.Inc()
+
+	if err := s.store.UpdateStatus(ctx, entry.Message.MessageID, status); err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Errorf("Unable to mark the message as %s", status)
+	}
+}
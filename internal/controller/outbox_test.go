@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+
+	"github.com/google/uuid"
+)
+
+func addTestOutboxEntry(t *testing.T, store OutboxStore, status string, attempts int, updatedAt time.Time) uuid.UUID {
+	messageID, _ := uuid.NewRandom()
+	err := store.Add(context.TODO(), OutboxEntry{
+		AccountNumber: testAccount,
+		Message:       Message{MessageID: messageID, Recipient: testNodeID, RouteList: []string{testNodeID}, Payload: "payload", Directive: "worker:action"},
+		Status:        status,
+		Attempts:      attempts,
+		CreatedAt:     updatedAt,
+		UpdatedAt:     updatedAt,
+	})
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+	return messageID
+}
+
+func TestInMemoryOutboxStore(t *testing.T) {
+	store := NewInMemoryOutboxStore()
+	old := time.Now().UTC().Add(-time.Hour)
+
+	stalePending := addTestOutboxEntry(t, store, OUTBOX_PENDING_STATUS, 0, old)
+	addTestOutboxEntry(t, store, OUTBOX_PENDING_STATUS, 0, time.Now().UTC())
+	staleSent := addTestOutboxEntry(t, store, OUTBOX_SENT_STATUS, 0, old)
+
+	pending, _ := store.GetPending(context.TODO(), time.Now().UTC().Add(-time.Minute))
+	if len(pending) != 1 || pending[0].Message.MessageID != stalePending {
+		t.Fatalf("Expected only the stale pending message, got %+v", pending)
+	}
+
+	store.RecordAttempt(context.TODO(), stalePending)
+	entry, _ := store.Get(context.TODO(), stalePending)
+	if entry.Attempts != 1 || !entry.UpdatedAt.After(old) {
+		t.Fatalf("Expected the attempt to be recorded, got %+v", entry)
+	}
+
+	pruned, _ := store.Prune(context.TODO(), time.Now().UTC().Add(-time.Minute))
+	if pruned != 1 {
+		t.Fatalf("Expected 1 message to be pruned, got %d", pruned)
+	}
+
+	if _, err := store.Get(context.TODO(), staleSent); err != (OutboxEntryNotFoundError{}) {
+		t.Fatalf("Expected %v, got %v", OutboxEntryNotFoundError{}, err)
+	}
+
+	if err := store.UpdateStatus(context.TODO(), staleSent, OUTBOX_FAILED_STATUS); err != (OutboxEntryNotFoundError{}) {
+		t.Fatalf("Expected %v, got %v", OutboxEntryNotFoundError{}, err)
+	}
+}
+
+func TestOutboxSweeperResendsStuckMessages(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.OutboxPendingThreshold = time.Minute
+	cfg.OutboxMaxAttempts = 3
+
+	store := NewInMemoryOutboxStore()
+	receptor := newTestReceptorServiceWithOutbox(cfg, newTestTransport(false), store)
+	defer receptor.Close(context.TODO())
+
+	cm := NewLocalConnectionManager()
+	cm.Register(testAccount, testNodeID, receptor)
+
+	old := time.Now().UTC().Add(-time.Hour)
+	stuck := addTestOutboxEntry(t, store, OUTBOX_PENDING_STATUS, 0, old)
+	outOfAttempts := addTestOutboxEntry(t, store, OUTBOX_PENDING_STATUS, 3, old)
+	recent := addTestOutboxEntry(t, store, OUTBOX_PENDING_STATUS, 0, time.Now().UTC())
+
+	NewOutboxSweeper(store, cm, cfg).Sweep(context.TODO())
+
+	waitForOutboxStatus(t, store, stuck, OUTBOX_SENT_STATUS)
+	waitForOutboxStatus(t, store, outOfAttempts, OUTBOX_FAILED_STATUS)
+	waitForOutboxStatus(t, store, recent, OUTBOX_PENDING_STATUS)
+
+	entry, _ := store.Get(context.TODO(), stuck)
+	if entry.Attempts != 1 {
+		t.Fatalf("Expected 1 attempt, got %d", entry.Attempts)
+	}
+}
+
+func TestInMemoryOutboxStorePruneKeepsTheEntriesInFlight(t *testing.T) {
+	store := NewInMemoryOutboxStore()
+	old := time.Now().UTC().Add(-time.Hour)
+
+	forwarded := addTestOutboxEntry(t, store, OUTBOX_FORWARDED_STATUS, 0, old)
+	cancelling := addTestOutboxEntry(t, store, OUTBOX_CANCELLING_STATUS, 0, old)
+	addTestOutboxEntry(t, store, OUTBOX_ACKNOWLEDGED_STATUS, 0, old)
+	addTestOutboxEntry(t, store, OUTBOX_EXPIRED_STATUS, 0, old)
+
+	pruned, _ := store.Prune(context.TODO(), time.Now().UTC().Add(-time.Minute))
+	if pruned != 2 {
+		t.Fatalf("Expected 2 messages to be pruned, got %d", pruned)
+	}
+
+	for _, messageID := range []uuid.UUID{forwarded, cancelling} {
+		if _, err := store.Get(context.TODO(), messageID); err != nil {
+			t.Fatalf("Expected the entry in flight to be kept, got %v", err)
+		}
+	}
+}
+
+func TestInMemoryOutboxStoreKeepsTheStatusOfAJobThatHasEnded(t *testing.T) {
+	store := NewInMemoryOutboxStore()
+	messageID := addTestOutboxEntry(t, store, OUTBOX_CANCELLED_STATUS, 0, time.Now().UTC())
+
+	if err := store.UpdateStatus(context.TODO(), messageID, OUTBOX_CANCELLING_STATUS); err != (JobCompletedError{}) {
+		t.Fatalf("Expected %v, got %v", JobCompletedError{}, err)
+	}
+
+	entry, _ := store.Get(context.TODO(), messageID)
+	if entry.Status != OUTBOX_CANCELLED_STATUS {
+		t.Fatalf("Expected the job to stay %s, got %s", OUTBOX_CANCELLED_STATUS, entry.Status)
+	}
+}
+
+func TestOutboxSweeperExpiresExpiredMessages(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.OutboxPendingThreshold = time.Minute
+
+	store := NewInMemoryOutboxStore()
+	messageID, _ := uuid.NewRandom()
+	old := time.Now().UTC().Add(-time.Hour)
+	store.Add(context.TODO(), OutboxEntry{
+		AccountNumber: testAccount,
+		Message:       Message{MessageID: messageID, Recipient: testNodeID, ExpiresAt: old},
+		Status:        OUTBOX_PENDING_STATUS,
+		CreatedAt:     old,
+		UpdatedAt:     old,
+	})
+
+	NewOutboxSweeper(store, NewLocalConnectionManager(), cfg).Sweep(context.TODO())
+
+	waitForOutboxStatus(t, store, messageID, OUTBOX_EXPIRED_STATUS)
+}
+
+func TestOutboxSweeperLeavesMessagesPendingWithoutConnection(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.OutboxPendingThreshold = time.Minute
+
+	store := NewInMemoryOutboxStore()
+	messageID := addTestOutboxEntry(t, store, OUTBOX_PENDING_STATUS, 0, time.Now().UTC().Add(-time.Hour))
+
+	NewOutboxSweeper(store, NewLocalConnectionManager(), cfg).Sweep(context.TODO())
+
+	entry, _ := store.Get(context.TODO(), messageID)
+	if entry.Status != OUTBOX_PENDING_STATUS || entry.Attempts != 0 {
+		t.Fatalf("Expected the message to be left pending, got %+v", entry)
+	}
+}
+
+func TestReceptorServiceExpiresMessagesThatExpireBeforeTheyAreSent(t *testing.T) {
+	outbox := NewInMemoryOutboxStore()
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorServiceWithOutbox(config.GetConfig(), transport, outbox)
+	defer receptor.Close(context.TODO())
+
+	expiredCtx := WithMessageExpiry(context.TODO(), time.Now().Add(-1*time.Minute))
+	expiredID, err := receptor.SendMessage(expiredCtx, testAccount, testNodeID, []string{testNodeID}, "expired", "worker:action")
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	sentID, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "not expired", "worker:action")
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	// Play the part of the writer: drop the expired message and send the next one
+	(<-transport.Send).OnExpired()
+	(<-transport.Send).OnSent()
+
+	entry, _ := outbox.Get(context.TODO(), *expiredID)
+	if entry.Status != OUTBOX_EXPIRED_STATUS {
+		t.Fatalf("Expected the expired message to be %s, got %s", OUTBOX_EXPIRED_STATUS, entry.Status)
+	}
+
+	entry, _ = outbox.Get(context.TODO(), *sentID)
+	if entry.Status != OUTBOX_SENT_STATUS {
+		t.Fatalf("Expected the message to be %s, got %s", OUTBOX_SENT_STATUS, entry.Status)
+	}
+}
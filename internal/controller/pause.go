@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+type PausedMessageLimitError struct {
+}
+
+func (p PausedMessageLimitError) Error() string {
+	return "too many messages held while delivery is paused"
+}
+
+// Pausable is implemented by receptors that can hold messages rather than
+// delivering them to the node, e.g. while the node is being upgraded
+type Pausable interface {
+	Pause(ctx context.Context) error
+	Resume(ctx context.Context) error
+	IsPaused(ctx context.Context) bool
+}
+
+// Pause stops the delivery of messages to the node.  Messages sent while the
+// delivery is paused are held (up to the configured limit) until the delivery
+// is resumed.  Control messages (pings) are not held.
+func (r *ReceptorService) Pause(ctx context.Context) error {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+
+	if r.paused == false {
+		r.logger.Info("Pausing message delivery")
+		r.paused = true
+	}
+
+	return nil
+}
+
+// PauseFromNode stops the delivery of messages at the request of the node
+// (see FlowControlHandler).  It is tracked separately from Pause so that the
+// node cannot resume a delivery that was paused by an operator.
+func (r *ReceptorService) PauseFromNode(ctx context.Context) error {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+
+	if r.nodePaused == false {
+		r.logger.Info("Pausing message delivery at the request of the node")
+		r.nodePaused = true
+	}
+
+	return nil
+}
+
+// Resume passes the held messages to the transport in the order that they
+// were sent and then resumes the delivery of messages.  Messages sent while
+// the held messages are being flushed are queued behind them.
+func (r *ReceptorService) Resume(ctx context.Context) error {
+	r.logger.Info("Resuming message delivery")
+	r.resume(ctx, &r.paused)
+	return nil
+}
+
+// ResumeFromNode undoes PauseFromNode.  The held messages are only flushed if
+// the delivery has not also been paused by an operator.
+func (r *ReceptorService) ResumeFromNode(ctx context.Context) error {
+	r.logger.Info("Resuming message delivery at the request of the node")
+	r.resume(ctx, &r.nodePaused)
+	return nil
+}
+
+// resume clears the pausedBy flag.  The held messages are flushed unless the
+// delivery is still paused by another flag, in which case they remain held.
+func (r *ReceptorService) resume(ctx context.Context, pausedBy *bool) {
+	for {
+		r.pauseLock.Lock()
+		if len(r.pausedMessages) == 0 || r.isPausedByOtherThan(pausedBy) {
+			*pausedBy = false
+			r.pauseLock.Unlock()
+			return
+		}
+		message := r.pausedMessages[0]
+		r.pausedMessages = r.pausedMessages[1:]
+		r.pauseLock.Unlock()
+
+		r.flushPausedMessage(ctx, message)
+	}
+}
+
+// IsPaused reports whether the delivery has been paused, either by an
+// operator or by the node (including while the node is backing off)
+func (r *ReceptorService) IsPaused(ctx context.Context) bool {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+	return r.paused || r.nodePaused || r.backedOff
+}
+
+// isPausedByOtherThan reports whether the delivery is paused by a flag other
+// than pausedBy.  The pauseLock must be held.
+func (r *ReceptorService) isPausedByOtherThan(pausedBy *bool) bool {
+	for _, flag := range []*bool{&r.paused, &r.nodePaused, &r.warmingUp, &r.backedOff} {
+		if flag != pausedBy && *flag {
+			return true
+		}
+	}
+	return false
+}
+
+// holdMessage holds the message if the delivery is paused (or the connection
+// is warming up).  It returns false
+// if the message should be passed to the transport.
+func (r *ReceptorService) holdMessage(message Message) (bool, error) {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+
+	if r.isPausedByOtherThan(nil) == false {
+		return false, nil
+	}
+
+	if len(r.pausedMessages) >= r.config.ReceptorPausedMessageLimit {
+		r.logger.WithFields(logrus.Fields{"message_id": message.MessageID}).Warn("Too many messages held while delivery is paused")
+		return true, PausedMessageLimitError{}
+	}
+
+	r.logger.WithFields(logrus.Fields{"message_id": message.MessageID}).Info("Holding message while delivery is paused")
+	r.pausedMessages = append(r.pausedMessages, message)
+
+	return true, nil
+}
+
+func (r *ReceptorService) flushPausedMessage(ctx context.Context, message Message) {
+	ctx, cancel := context.WithTimeout(ctx, r.sendTimeout())
+	defer cancel()
+
+	if err := r.sendMessage(ctx, message); err != nil {
+		r.logger.WithFields(logrus.Fields{"error": err, "message_id": message.MessageID}).Info("Unable to send a held message")
+		r.updateOutboxStatus(message.MessageID, OUTBOX_FAILED_STATUS)
+		r.recordDelivery(message, DELIVERY_OUTCOME_FAILED, err)
+	}
+}
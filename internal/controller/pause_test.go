@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+
+	"github.com/google/uuid"
+)
+
+func newBufferedTestTransport() *Transport {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Transport{
+		Send:   make(chan ReceptorMessage, 10), // the test reads from this channel
+		Ctx:    ctx,
+		Cancel: cancel,
+	}
+}
+
+func TestReceptorServicePauseHoldsMessages(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.ReceptorPausedMessageLimit = 2
+	outbox := NewInMemoryOutboxStore()
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorServiceWithOutbox(cfg, transport, outbox)
+	defer receptor.Close(context.TODO())
+
+	receptor.Pause(context.TODO())
+	if receptor.IsPaused(context.TODO()) == false {
+		t.Fatalf("Expected the receptor to be paused")
+	}
+
+	first, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "first", "worker:action")
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	second, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "second", "worker:action")
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	if len(transport.Send) != 0 {
+		t.Fatalf("Expected no messages to be passed to the transport while paused, got %d", len(transport.Send))
+	}
+
+	_, err = receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "third", "worker:action")
+	if err != (PausedMessageLimitError{}) {
+		t.Fatalf("Expected %v, got %v", PausedMessageLimitError{}, err)
+	}
+
+	for _, messageID := range []*uuid.UUID{first, second} {
+		entry, _ := outbox.Get(context.TODO(), *messageID)
+		if entry.Status != OUTBOX_PENDING_STATUS {
+			t.Fatalf("Expected message %s to be pending, got %s", messageID, entry.Status)
+		}
+	}
+}
+
+func TestReceptorServiceResumeFlushesMessagesInOrder(t *testing.T) {
+	cfg := config.GetConfig()
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorService(cfg, transport)
+	defer receptor.Close(context.TODO())
+
+	receptor.Pause(context.TODO())
+
+	var expected []string
+	for _, payload := range []string{"first", "second", "third"} {
+		messageID, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, payload, "worker:action")
+		if err != nil {
+			t.Fatalf("Expected the error to be nil, got %v", err)
+		}
+		expected = append(expected, messageID.String())
+	}
+
+	if err := receptor.Resume(context.TODO()); err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	if receptor.IsPaused(context.TODO()) {
+		t.Fatalf("Expected the receptor to be resumed")
+	}
+
+	after, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "after", "worker:action")
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+	expected = append(expected, after.String())
+
+	for _, messageID := range expected {
+		msg := <-transport.Send
+		payloadMessage := msg.Message.(*protocol.PayloadMessage)
+		if payloadMessage.Data.MessageID != messageID {
+			t.Fatalf("Expected message %s, got %s", messageID, payloadMessage.Data.MessageID)
+		}
+	}
+}
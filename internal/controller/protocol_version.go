@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+)
+
+// ProtocolVersionReporter is implemented by receptors that know the version of
+// the protocol negotiated with the node during the handshake
+type ProtocolVersionReporter interface {
+	GetProtocolVersion(ctx context.Context) (int, error)
+}
+
+// IncompatibleProtocolVersionError is returned when a node advertises a
+// version of the protocol that is older than the oldest version supported by
+// the controller
+type IncompatibleProtocolVersionError struct {
+	Version    int
+	MinVersion int
+}
+
+func (e IncompatibleProtocolVersionError) Error() string {
+	return fmt.Sprintf("incompatible protocol version %d (the minimum supported version is %d)", e.Version, e.MinVersion)
+}
+
+// NegotiateProtocolVersion returns the version of the protocol used to talk to
+// a node that advertised the given version.  A node that did not advertise a
+// version speaks protocol.ProtocolVersion1.  A node newer than the controller
+// is talked to using protocol.CurrentProtocolVersion.
+func NegotiateProtocolVersion(advertised int, minVersion int) (int, error) {
+	if advertised <= 0 {
+		advertised = protocol.ProtocolVersion1
+	}
+
+	if advertised < minVersion {
+		return 0, IncompatibleProtocolVersionError{Version: advertised, MinVersion: minVersion}
+	}
+
+	if advertised > protocol.CurrentProtocolVersion {
+		return protocol.CurrentProtocolVersion, nil
+	}
+
+	return advertised, nil
+}
+
+// SetProtocolVersion records the version of the protocol negotiated with the
+// node during the handshake
+func (r *ReceptorService) SetProtocolVersion(version int) {
+	r.metadataLock.Lock()
+	defer r.metadataLock.Unlock()
+
+	r.protocolVersion = version
+}
+
+// GetProtocolVersion returns the version of the protocol negotiated with the
+// node during the handshake
+func (r *ReceptorService) GetProtocolVersion(ctx context.Context) (int, error) {
+	r.metadataLock.RLock()
+	defer r.metadataLock.RUnlock()
+
+	return r.protocolVersion, nil
+}
+
+// supportsBinaryPayloads reports whether the binary payloads can be written to
+// the node in binary frames.  The binary payloads sent to an older node are
+// encoded as json instead.
+func (r *ReceptorService) supportsBinaryPayloads() bool {
+	version, _ := r.GetProtocolVersion(context.Background())
+	return version >= protocol.ProtocolVersion2
+}
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+)
+
+func TestNegotiateProtocolVersion(t *testing.T) {
+	testCases := []struct {
+		advertised int
+		minVersion int
+		expected   int
+	}{
+		{0, protocol.ProtocolVersion1, protocol.ProtocolVersion1},
+		{protocol.ProtocolVersion1, protocol.ProtocolVersion1, protocol.ProtocolVersion1},
+		{protocol.ProtocolVersion2, protocol.ProtocolVersion1, protocol.ProtocolVersion2},
+		{protocol.CurrentProtocolVersion + 1, protocol.ProtocolVersion1, protocol.CurrentProtocolVersion},
+		{protocol.ProtocolVersion2, protocol.ProtocolVersion2, protocol.ProtocolVersion2},
+	}
+
+	for _, tc := range testCases {
+		version, err := NegotiateProtocolVersion(tc.advertised, tc.minVersion)
+		if err != nil {
+			t.Fatalf("Expected version %d to be compatible, got %v", tc.advertised, err)
+		}
+		if version != tc.expected {
+			t.Fatalf("Expected version %d to negotiate %d, got %d", tc.advertised, tc.expected, version)
+		}
+	}
+}
+
+func TestNegotiateProtocolVersionRejectsOlderNodes(t *testing.T) {
+	_, err := NegotiateProtocolVersion(0, protocol.ProtocolVersion2)
+
+	expected := IncompatibleProtocolVersionError{Version: protocol.ProtocolVersion1, MinVersion: protocol.ProtocolVersion2}
+	if err != expected {
+		t.Fatalf("Expected %v, got %v", expected, err)
+	}
+
+	if reason := CloseReasonForError(err); reason != CLOSE_REASON_PROTOCOL_MISMATCH {
+		t.Fatalf("Expected the close reason to be %s, got %s", CLOSE_REASON_PROTOCOL_MISMATCH, reason)
+	}
+}
+
+func TestReceptorServiceEncodesBinaryPayloadsAsJsonForOlderNodes(t *testing.T) {
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorService(config.GetConfig(), transport)
+	defer receptor.Close(context.TODO())
+
+	receptor.SetProtocolVersion(protocol.ProtocolVersion1)
+
+	data := []byte{0x1f, 0x8b, 0x08, 0x00}
+	if _, err := receptor.SendBinaryMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, data, "worker:upload"); err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	payloadMessage, ok := (<-transport.Send).Message.(*protocol.PayloadMessage)
+	if !ok {
+		t.Fatalf("Expected the binary payload to be sent in a json payload message")
+	}
+
+	if payload, ok := payloadMessage.Data.RawPayload.(BinaryPayload); !ok || !bytes.Equal(payload, data) {
+		t.Fatalf("Expected the payload to be encoded as json, got %#v", payloadMessage.Data.RawPayload)
+	}
+}
@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrQuarantined is returned when a message is sent to a connection that has
+// been quarantined
+var ErrQuarantined = errors.New("the connection has been quarantined")
+
+// Quarantinable is implemented by receptors that can stop routing messages to
+// the node while keeping the connection open, e.g. so that the node can be
+// inspected
+type Quarantinable interface {
+	Quarantine(ctx context.Context) error
+	Unquarantine(ctx context.Context) error
+	IsQuarantined(ctx context.Context) bool
+}
+
+// Quarantine refuses the messages sent to the node (with ErrQuarantined)
+// until the connection is unquarantined.  The connection stays registered and
+// control messages (pings) are still sent.
+func (r *ReceptorService) Quarantine(ctx context.Context) error {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+
+	if r.quarantined == false {
+		r.logger.Info("Quarantining the connection")
+		r.quarantined = true
+	}
+
+	return nil
+}
+
+// Unquarantine restores the routing of messages to the node
+func (r *ReceptorService) Unquarantine(ctx context.Context) error {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+
+	if r.quarantined {
+		r.logger.Info("Lifting the quarantine of the connection")
+		r.quarantined = false
+	}
+
+	return nil
+}
+
+func (r *ReceptorService) IsQuarantined(ctx context.Context) bool {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+	return r.quarantined
+}
+
+// isQuarantined reports whether the client refuses the messages because its
+// connection has been quarantined
+func isQuarantined(ctx context.Context, client Receptor) bool {
+	quarantinable, ok := client.(Quarantinable)
+	return ok && quarantinable.IsQuarantined(ctx)
+}
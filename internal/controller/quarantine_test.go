@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+)
+
+func TestReceptorServiceQuarantineRefusesMessages(t *testing.T) {
+	cfg := config.GetConfig()
+	outbox := NewInMemoryOutboxStore()
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorServiceWithOutbox(cfg, transport, outbox)
+	defer receptor.Close(context.TODO())
+
+	receptor.Quarantine(context.TODO())
+	if receptor.IsQuarantined(context.TODO()) == false {
+		t.Fatalf("Expected the connection to be quarantined")
+	}
+
+	messageID, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action")
+	if err != ErrQuarantined {
+		t.Fatalf("Expected %v, got %v", ErrQuarantined, err)
+	}
+
+	if messageID != nil {
+		t.Fatalf("Expected no message id to be returned, got %s", messageID)
+	}
+
+	_, err = receptor.SendMessageWithAck(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action")
+	if err != ErrQuarantined {
+		t.Fatalf("Expected %v, got %v", ErrQuarantined, err)
+	}
+
+	if len(transport.Send) != 0 {
+		t.Fatalf("Expected no messages to be passed to the transport while quarantined, got %d", len(transport.Send))
+	}
+
+	pending, _ := outbox.GetPending(context.TODO(), time.Now().Add(time.Minute))
+	if len(pending) != 0 {
+		t.Fatalf("Expected the refused messages not to be added to the outbox, got %d", len(pending))
+	}
+}
+
+func TestReceptorServiceUnquarantineRestoresRouting(t *testing.T) {
+	cfg := config.GetConfig()
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorServiceWithOutbox(cfg, transport, NewInMemoryOutboxStore())
+	defer receptor.Close(context.TODO())
+
+	receptor.Quarantine(context.TODO())
+	receptor.Unquarantine(context.TODO())
+
+	if receptor.IsQuarantined(context.TODO()) {
+		t.Fatalf("Expected the quarantine to be lifted")
+	}
+
+	_, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action")
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	if len(transport.Send) != 1 {
+		t.Fatalf("Expected the message to be passed to the transport, got %d", len(transport.Send))
+	}
+}
+
+type quarantinedMockReceptor struct {
+	queuedMockReceptor
+}
+
+func (mr *quarantinedMockReceptor) Quarantine(context.Context) error   { return nil }
+func (mr *quarantinedMockReceptor) Unquarantine(context.Context) error { return nil }
+func (mr *quarantinedMockReceptor) IsQuarantined(context.Context) bool { return true }
+
+func TestAccountDispatcherSkipsQuarantinedConnections(t *testing.T) {
+	healthy := &queuedMockReceptor{}
+	quarantined := &quarantinedMockReceptor{}
+
+	cm := NewLocalConnectionManager()
+	cm.Register("1234", "node-a", quarantined)
+	cm.Register("1234", "node-b", healthy)
+
+	dispatcher := NewAccountDispatcher(cm, FirstNodeSelector{})
+
+	nodeID, _, err := dispatcher.SendToAccount(context.TODO(), "1234", "payload", "worker:action")
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	if nodeID != "node-b" || quarantined.sent != 0 || healthy.sent != 1 {
+		t.Fatalf("Expected the message to be sent to node-b, got %s", nodeID)
+	}
+
+	cm.Unregister("1234", "node-b")
+
+	_, _, err = dispatcher.SendToAccount(context.TODO(), "1234", "payload", "worker:action")
+	if err != (NoConnectionsError{}) {
+		t.Fatalf("Expected %v, got %v", NoConnectionsError{}, err)
+	}
+}
@@ -8,31 +8,72 @@ import (
 	"time"
 
 	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/queue"
 	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
 
 	"github.com/google/uuid"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redhatinsights/platform-go-middlewares/request_id"
 	kafka "github.com/segmentio/kafka-go"
 
 	"github.com/sirupsen/logrus"
 )
 
 var (
-	connectionToReceptorNetworkLost = errors.New("Connection to receptor network lost")
-	requestCancelledBySender        = errors.New("Unable to complete the request.  Request cancelled by message sender.")
-	requestTimedOut                 = errors.New("Unable to complete the request.  Request timed out.")
-	accountMismatch                 = errors.New("Account mismatch.  Unable to complete the request.")
+	// ErrConnectionClosed is returned to the senders that are waiting on a
+	// connection when it is closed, and is passed to the OnFailed callback of
+	// the messages that were queued but never written
+	ErrConnectionClosed = errors.New("Connection to receptor network lost")
+
+	requestCancelledBySender = errors.New("Unable to complete the request.  Request cancelled by message sender.")
+	requestTimedOut          = errors.New("Unable to complete the request.  Request timed out.")
+	accountMismatch          = errors.New("Account mismatch.  Unable to complete the request.")
 )
 
+// ResponseDroppedError is returned to a sender waiting for a sync response
+// when a response from the node was dropped (e.g. because it was too large)
+type ResponseDroppedError struct {
+	Reason error
+}
+
+func (e ResponseDroppedError) Error() string {
+	return "Unable to complete the request.  The response from the node was dropped: " + e.Reason.Error()
+}
+
 type ReceptorServiceFactory struct {
 	kafkaWriter *kafka.Writer
+	outbox      OutboxStore
 	config      *config.Config
+
+	sentMessageMetrics *sentMessageMetrics
+
+	capabilitiesWarmer *capabilitiesWarmer
+
+	forwarder MessageForwarder
+
+	throughput *AccountThroughputTracker
+
+	deliveryReceipts *DeliveryReceiptProducer
+
+	// bandwidthQuota is shared by the receptor services so that the quota
+	// covers every connection of an account
+	bandwidthQuota *bandwidthQuota
+
+	// pendingResponseWrites tracks the responses that are still being
+	// written to kafka by the receptor services created by this factory
+	pendingResponseWrites sync.WaitGroup
 }
 
-func NewReceptorServiceFactory(w *kafka.Writer, cfg *config.Config) *ReceptorServiceFactory {
+func NewReceptorServiceFactory(w *kafka.Writer, outbox OutboxStore, cfg *config.Config) *ReceptorServiceFactory {
 	return &ReceptorServiceFactory{
 		kafkaWriter: w,
+		outbox:      outbox,
 		config:      cfg,
+
+		sentMessageMetrics: newSentMessageMetrics(cfg),
+		capabilitiesWarmer: newCapabilitiesWarmer(cfg),
+		bandwidthQuota:     newBandwidthQuota(cfg),
 	}
 }
 
@@ -43,75 +84,354 @@ func (fact *ReceptorServiceFactory) NewReceptorService(logger *logrus.Entry, acc
 		responseDispatcherRegistrar: &DispatcherTable{
 			dispatchTable: make(map[uuid.UUID]chan ResponseMessage),
 		},
-		kafkaWriter: fact.kafkaWriter,
-		config:      fact.config,
-		logger:      logger,
+		ackDispatcherRegistrar: &AckDispatcherTable{
+			dispatchTable: make(map[uuid.UUID]chan struct{}),
+		},
+		droppedResponseRegistrar: &DroppedResponseTable{
+			dispatchTable: make(map[uuid.UUID]chan DroppedMessage),
+		},
+		kafkaWriter:           fact.kafkaWriter,
+		pendingResponseWrites: &fact.pendingResponseWrites,
+		outbox:                fact.outbox,
+		config:                fact.config,
+		sentMessageMetrics:    fact.sentMessageMetrics,
+		capabilitiesWarmer:    fact.capabilitiesWarmer,
+		forwarder:             fact.forwarder,
+		throughput:            fact.throughput,
+		deliveryReceipts:      fact.deliveryReceipts,
+		bandwidthQuota:        fact.bandwidthQuota,
+		protocolVersion:       protocol.CurrentProtocolVersion,
+		logger:                logger,
 	}
 }
 
+// SetMessageForwarder enables forwarding the messages queued on a lost
+// connection to the pod that the node reconnects to
+func (fact *ReceptorServiceFactory) SetMessageForwarder(forwarder MessageForwarder) {
+	fact.forwarder = forwarder
+}
+
+// Close flushes the responses that are still being written to kafka and then
+// closes the kafka writer.  If ctx is done before the flush completes, the
+// writer is closed anyway and the messages that could not be flushed are
+// logged.
+func (fact *ReceptorServiceFactory) Close(ctx context.Context) error {
+	flushed := make(chan struct{})
+	go func() {
+		fact.pendingResponseWrites.Wait()
+		close(flushed)
+	}()
+
+	select {
+	case <-flushed:
+	case <-ctx.Done():
+		logger.Log.Warn("Timed out waiting for responses to be written to kafka")
+	}
+
+	return queue.CloseProducer(ctx, fact.kafkaWriter)
+}
+
 type ReceptorService struct {
 	AccountNumber string
 	NodeID        string
 	PeerNodeID    string
 
-	Metadata interface{}
+	Metadata     interface{}
+	connectedAt  time.Time
+	labels       map[string]string
+	metadataLock sync.RWMutex
+
+	// protocolVersion is the version of the protocol negotiated with the node
+	// during the handshake.  It is guarded by the metadataLock.
+	protocolVersion int
+
+	// timeouts overrides the timeouts of the requests sent to the node.  It
+	// is guarded by the metadataLock.
+	timeouts ConnectionTimeouts
+
+	// capabilitiesStream holds the capabilities received so far while the
+	// node is streaming its capabilities.  It is guarded by the metadataLock.
+	capabilitiesStream *capabilitiesStream
 
 	Transport *Transport
 
-	responseDispatcherRegistrar *DispatcherTable
+	routingTable routingTableStore
 
-	kafkaWriter *kafka.Writer
-	config      *config.Config
-	logger      *logrus.Entry
+	responseDispatcherRegistrar *DispatcherTable
+	ackDispatcherRegistrar      *AckDispatcherTable
+	droppedResponseRegistrar    *DroppedResponseTable
+
+	kafkaWriter           *kafka.Writer
+	pendingResponseWrites *sync.WaitGroup
+	outbox                OutboxStore
+	config                *config.Config
+	sentMessageMetrics    *sentMessageMetrics
+	capabilitiesWarmer    *capabilitiesWarmer
+	forwarder             MessageForwarder
+	throughput            *AccountThroughputTracker
+	deliveryReceipts      *DeliveryReceiptProducer
+	bandwidthQuota        *bandwidthQuota
+	logger                *logrus.Entry
+
+	closeOnce sync.Once
+	closed    bool
+	sendLock  sync.RWMutex
+
+	pauseLock      sync.Mutex
+	paused         bool
+	nodePaused     bool
+	warmingUp      bool
+	pausedMessages []Message
+	warmedUp       chan struct{}
+
+	// backedOff is set while the node asked the controller to wait before
+	// sending it anything else (see BackOff)
+	backedOff      bool
+	backOffUntil   time.Time
+	backOffTimer   *time.Timer
+	backOffStopped bool
+
+	// quarantined is guarded by the pauseLock
+	quarantined bool
+
+	inFlightLock sync.Mutex
+	inFlight     int
+
+	sendRateLimiterOnce sync.Once
+	sendRateLimiter     *sendRateLimiter
+
+	// sendSequencer orders the sends when ReceptorStrictSendOrdering is set
+	sendSequencer sendSequencer
+
+	// closedFor is set to the reason the connection went away once the close
+	// callbacks have been run
+	closeCallbacksLock sync.Mutex
+	closeCallbacks     []func(reason DisconnectReason)
+	closedFor          *DisconnectReason
+
+	// cancelling holds the messages that a cancel command has been sent for
+	cancellingLock sync.Mutex
+	cancelling     map[uuid.UUID]struct{}
 }
 
 func (r *ReceptorService) RegisterConnection(peerNodeID string, metadata interface{}, transport *Transport) error {
 	r.logger.Info("Registering a connection to node ", peerNodeID)
 
 	r.PeerNodeID = peerNodeID
+	r.metadataLock.Lock()
 	r.Metadata = metadata
+	r.connectedAt = time.Now()
+	r.timeouts = r.parseMetadataTimeouts(metadata)
+	r.metadataLock.Unlock()
 	r.Transport = transport
 
+	r.startWarmup()
+
+	r.capabilitiesWarmer.warm(r)
+
 	return nil
 }
 
-func (r *ReceptorService) UpdateRoutingTable(edges string, seen string) error {
-	r.logger.Debug("edges:", edges)
+func (r *ReceptorService) UpdateRoutingTable(rawEdges [][]interface{}, seen []string) error {
+	r.logger.Debug("edges:", rawEdges)
 	r.logger.Debug("seen:", seen)
+
+	edges, err := protocol.ParseEdges(rawEdges)
+	if err != nil {
+		r.logger.WithFields(logrus.Fields{"error": err}).Info("Unable to parse the routing table edges")
+		return err
+	}
+
+	r.routingTable.update(edges, seen)
+
 	return nil
 }
 
+// SendMessage sends a message to the recipient.  If the route is empty, the
+// route is computed from the routing table of the connection.
 func (r *ReceptorService) SendMessage(msgSenderCtx context.Context, account string, recipient string, route []string, payload interface{}, directive string) (*uuid.UUID, error) {
 
 	if account != r.AccountNumber {
 		return nil, accountMismatch
 	}
 
+	if len(route) == 0 {
+		var err error
+		if route, err = r.routeTo(recipient); err != nil {
+			r.logger.WithFields(logrus.Fields{"recipient": recipient}).Info("Unable to compute a route to the recipient")
+			return nil, err
+		}
+	}
+
 	messageID, err := uuid.NewRandom()
 	if err != nil {
 		r.logger.Info("Unable to generate UUID for routing the job...cannot proceed")
 		return nil, err
 	}
 
-	payloadMessage, err := protocol.BuildPayloadMessage(
-		messageID,
-		r.NodeID,
-		recipient,
-		route,
-		"directive",
-		directive,
-		payload)
+	if err := r.submitMessage(msgSenderCtx, messageID, recipient, route, payload, directive); err != nil {
+		return nil, err
+	}
+
+	return &messageID, nil
+}
+
+// submitMessage builds the message from the sender's context and submits it.
+// With strict send ordering, the message is submitted only once the messages
+// submitted before it have been passed to the transport.
+func (r *ReceptorService) submitMessage(msgSenderCtx context.Context, messageID uuid.UUID, recipient string, route []string, payload interface{}, directive string) error {
+
+	endTurn, err := r.waitForSendTurn(msgSenderCtx)
+	if err != nil {
+		return err
+	}
+	defer endTurn()
+
+	message := Message{
+		MessageID: messageID,
+		Recipient: recipient,
+		RouteList: route,
+		Payload:   payload,
+		Directive: directive,
+		RequestID: request_id.GetReqID(msgSenderCtx),
+	}
+
+	if expiresAt, exists := GetMessageExpiry(msgSenderCtx); exists {
+		message.ExpiresAt = expiresAt
+	}
+
+	if tc, exists := GetTraceContext(msgSenderCtx); exists {
+		message.TraceContext = tc
+	}
+
+	return r.submit(msgSenderCtx, message, true)
+}
+
+// submit adds the message to the outbox and passes it to the transport
+// (unless the delivery is paused)
+func (r *ReceptorService) submit(msgSenderCtx context.Context, message Message, addToOutbox bool) (err error) {
+	defer func() { r.recordThroughput(message, err) }()
+
+	if r.IsQuarantined(msgSenderCtx) {
+		r.logger.WithFields(logrus.Fields{"recipient": message.Recipient, "message_id": message.MessageID}).Info("Refusing a message sent to a quarantined connection")
+		return ErrQuarantined
+	}
+
+	if err := VerifyDirective(r.config.AllowedDirectives, message.Directive); err != nil {
+		r.logger.WithFields(logrus.Fields{"audit": true, "recipient": message.Recipient, "directive": message.Directive}).Warn("Rejected a message with a directive that is not allowed")
+		metrics.rejectedDirectiveCounter.Inc()
+		return err
+	}
+
+	if err := r.waitForSendRateLimit(msgSenderCtx); err != nil {
+		return err
+	}
+
+	releaseBandwidth, err := r.reserveBandwidth(message)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			releaseBandwidth()
+		}
+	}()
+
+	messageID := message.MessageID
+
+	if r.outbox != nil && addToOutbox {
+		now := time.Now().UTC()
+		err := r.outbox.Add(msgSenderCtx, OutboxEntry{
+			AccountNumber: r.AccountNumber,
+			NodeID:        r.PeerNodeID,
+			Message:       message,
+			Status:        OUTBOX_PENDING_STATUS,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+		if err != nil {
+			r.logger.WithFields(logrus.Fields{"error": err}).Info("Unable to add the message to the outbox...cannot proceed")
+			return err
+		}
+	}
+
+	held, err := r.holdMessage(message)
+	if err != nil {
+		r.updateOutboxStatus(messageID, OUTBOX_FAILED_STATUS)
+		r.recordDelivery(message, DELIVERY_OUTCOME_FAILED, err)
+		return err
+	} else if held {
+		r.recordSentMessage(message)
+		return nil
+	}
+
 	r.logger.Infof("Sending PayloadMessage - %s\n", messageID)
 
-	msgSenderCtx, cancel := context.WithTimeout(msgSenderCtx, r.config.ReceptorSyncPingTimeout)
+	msgSenderCtx, cancel := context.WithTimeout(msgSenderCtx, r.sendTimeout())
 	defer cancel()
 
-	err = r.sendMessage(msgSenderCtx, payloadMessage)
+	err = r.timeOperation(SLOW_OPERATION_SEND, func() error {
+		return r.sendMessage(msgSenderCtx, message)
+	})
 	if err != nil {
-		return nil, err
+		// The sender is told that the message was not sent so the
+		// sweeper should not attempt to resend it
+		r.updateOutboxStatus(messageID, OUTBOX_FAILED_STATUS)
+		r.recordDelivery(message, DELIVERY_OUTCOME_FAILED, err)
+		return err
 	}
 
-	return &messageID, nil
+	r.recordSentMessage(message)
+
+	return nil
+}
+
+func (r *ReceptorService) recordSentMessage(message Message) {
+	if r.sentMessageMetrics != nil {
+		r.sentMessageMetrics.record(r.AccountNumber, message.Directive, message.Payload)
+	}
+}
+
+// ResendMessage passes a message from the outbox that has been stuck in the
+// pending state to the transport again
+func (r *ReceptorService) ResendMessage(ctx context.Context, entry OutboxEntry) error {
+
+	if entry.AccountNumber != r.AccountNumber {
+		return accountMismatch
+	}
+
+	// The message is likely being held, it will be sent once the delivery
+	// is resumed
+	if r.IsPaused(ctx) {
+		r.logger.Debugf("Not resending PayloadMessage - %s while delivery is paused\n", entry.Message.MessageID)
+		return nil
+	}
+
+	if r.outbox != nil {
+		if err := r.outbox.RecordAttempt(ctx, entry.Message.MessageID); err != nil {
+			return err
+		}
+	}
+
+	r.logger.Infof("Resending PayloadMessage - %s\n", entry.Message.MessageID)
+
+	ctx, cancel := context.WithTimeout(ctx, r.sendTimeout())
+	defer cancel()
+
+	return r.sendMessage(ctx, entry.Message)
+}
+
+func (r *ReceptorService) updateOutboxStatus(messageID uuid.UUID, status string) {
+	if r.outbox == nil {
+		return
+	}
+
+	err := r.outbox.UpdateStatus(context.Background(), messageID, status)
+	if _, ok := err.(JobCompletedError); ok {
+		r.logger.WithFields(logrus.Fields{"message_id": messageID, "status": status}).Debug("Not updating the status of a job that has ended")
+	} else if err != nil {
+		r.logger.WithFields(logrus.Fields{"error": err, "message_id": messageID}).Warn("Unable to update the status of the message in the outbox")
+	}
 }
 
 func (r *ReceptorService) Ping(msgSenderCtx context.Context, account string, recipient string, route []string) (interface{}, error) {
@@ -120,10 +440,45 @@ func (r *ReceptorService) Ping(msgSenderCtx context.Context, account string, rec
 		return nil, accountMismatch
 	}
 
+	msgSenderCtx, cancel := context.WithTimeout(msgSenderCtx, r.pingTimeout())
+	defer cancel()
+
+	pingDurationRecorder := DurationRecorder{elapsed: metrics.pingElapsed,
+		labels: prometheus.Labels{"account": r.AccountNumber, "recipient": r.PeerNodeID}}
+	if tc, ok := GetTraceContext(msgSenderCtx); ok {
+		pingDurationRecorder.traceID = tc.TraceID()
+	}
+	pingDurationRecorder.Start()
+
+	var responseMsg ResponseMessage
+	err := r.timeOperation(SLOW_OPERATION_PING, func() (err error) {
+		responseMsg, err = r.sendSyncDirective(msgSenderCtx, recipient, route, "receptor:ping")
+		return err
+	})
+	pingDurationRecorder.Stop()
+	if err != nil {
+		return nil, err
+	}
+
+	return responseMsg, nil
+}
+
+// sendSyncDirective sends a directive over the control channel and waits for
+// the node to respond to it
+func (r *ReceptorService) sendSyncDirective(msgSenderCtx context.Context, recipient string, route []string, directive string) (ResponseMessage, error) {
+
+	if err := r.waitForWarmup(msgSenderCtx); err != nil {
+		return ResponseMessage{}, err
+	}
+
+	if err := r.checkBackOff(); err != nil {
+		return ResponseMessage{}, err
+	}
+
 	messageID, err := uuid.NewRandom()
 	if err != nil {
 		r.logger.Info("Unable to generate UUID for routing the job...cannot proceed")
-		return nil, err
+		return ResponseMessage{}, err
 	}
 
 	payloadMessage, err := protocol.BuildPayloadMessage(
@@ -132,34 +487,33 @@ func (r *ReceptorService) Ping(msgSenderCtx context.Context, account string, rec
 		recipient,
 		route,
 		"directive",
-		"receptor:ping",
+		directive,
 		time.Now().UTC())
 
+	injectRequestID(payloadMessage, request_id.GetReqID(msgSenderCtx))
+
+	if tc, exists := GetTraceContext(msgSenderCtx); exists {
+		injectTraceContext(payloadMessage, tc)
+	}
+
 	responseChannel := make(chan ResponseMessage)
 
 	r.logger.Info("Registering a sync response handler")
 	r.responseDispatcherRegistrar.Register(messageID, responseChannel)
 	defer r.responseDispatcherRegistrar.Unregister(messageID)
 
-	msgSenderCtx, cancel := context.WithTimeout(msgSenderCtx, r.config.ReceptorSyncPingTimeout)
-	defer cancel()
+	droppedChannel := make(chan DroppedMessage, 1)
+	r.droppedResponseRegistrar.Register(messageID, droppedChannel)
+	defer r.droppedResponseRegistrar.Unregister(messageID)
 
-	pingDurationRecorder := DurationRecorder{elapsed: metrics.pingElapsed,
-		labels: prometheus.Labels{"account": r.AccountNumber, "recipient": r.PeerNodeID}}
-	pingDurationRecorder.Start()
+	sentAt := time.Now()
 
 	err = r.sendControlMessage(msgSenderCtx, payloadMessage)
 	if err != nil {
-		return nil, err
+		return ResponseMessage{}, err
 	}
 
-	responseMsg, err := r.waitForResponse(msgSenderCtx, responseChannel)
-	pingDurationRecorder.Stop()
-	if err != nil {
-		return nil, err
-	}
-
-	return responseMsg, nil
+	return r.waitForResponse(msgSenderCtx, responseChannel, droppedChannel, sentAt)
 }
 
 // FIXME:  Does it make sense to move this logic to the transport object?  Or am I missing an abstraction?
@@ -170,11 +524,89 @@ func (r *ReceptorService) sendControlMessage(msgSenderCtx context.Context, msgTo
 	return sendMessage(r.logger, r.Transport.Ctx, r.Transport.ControlChannel, msgSenderCtx, msg)
 }
 
-func (r *ReceptorService) sendMessage(msgSenderCtx context.Context, msgToSend protocol.Message) error {
+func (r *ReceptorService) sendMessage(msgSenderCtx context.Context, message Message) error {
 
-	msg := ReceptorMessage{AccountNumber: r.AccountNumber, Message: msgToSend}
+	payloadMessage, err := protocol.BuildPayloadMessage(
+		message.MessageID,
+		r.NodeID,
+		message.Recipient,
+		message.RouteList,
+		"directive",
+		message.Directive,
+		message.Payload)
+	if err != nil {
+		r.logger.WithFields(logrus.Fields{"error": err}).Info("Unable to build the payload message")
+		return err
+	}
+
+	injectRequestID(payloadMessage, message.RequestID)
+	injectTraceContext(payloadMessage, message.TraceContext)
+
+	if data, ok := message.Payload.(BinaryPayload); ok && r.supportsBinaryPayloads() {
+		payloadMessage = protocol.NewBinaryPayloadMessage(payloadMessage.(*protocol.PayloadMessage), data)
+	}
+
+	msg := ReceptorMessage{
+		AccountNumber: r.AccountNumber,
+		Message:       payloadMessage,
+		ExpiresAt:     message.ExpiresAt,
+		OnSent: func() {
+			r.releaseInFlightSlot()
+			if r.isCancelling(message.MessageID) {
+				return
+			}
+			r.updateOutboxStatus(message.MessageID, OUTBOX_SENT_STATUS)
+			r.recordDelivery(message, DELIVERY_OUTCOME_DELIVERED, nil)
+		},
+		OnFailed: func(err error) {
+			r.releaseInFlightSlot()
+			if r.stopCancelling(message.MessageID) {
+				r.logger.WithFields(logrus.Fields{"message_id": message.MessageID}).Info("Cancelled message was not sent before the connection closed")
+				r.updateOutboxStatus(message.MessageID, OUTBOX_CANCELLED_STATUS)
+				return
+			}
+			if r.forwarder != nil && err == ErrConnectionClosed {
+				go r.forwardMessage(message)
+				return
+			}
+			r.logger.WithFields(logrus.Fields{"message_id": message.MessageID, "error": err}).Info("Message was not sent before the connection closed")
+			r.updateOutboxStatus(message.MessageID, OUTBOX_FAILED_STATUS)
+			r.recordDelivery(message, DELIVERY_OUTCOME_FAILED, err)
+		},
+		OnExpired: func() {
+			r.releaseInFlightSlot()
+			if r.stopCancelling(message.MessageID) {
+				r.updateOutboxStatus(message.MessageID, OUTBOX_CANCELLED_STATUS)
+				return
+			}
+			// Expire the message right away rather than leaving it pending
+			// until the outbox sweeper notices that it has expired
+			r.logger.WithFields(logrus.Fields{"message_id": message.MessageID}).Info("Message expired before it was sent")
+			r.updateOutboxStatus(message.MessageID, OUTBOX_EXPIRED_STATUS)
+			r.recordDelivery(message, DELIVERY_OUTCOME_EXPIRED, nil)
+		},
+	}
+
+	// Hold the read lock while passing the message to the async layer so that
+	// Close() cannot close the send channel out from under us
+	r.sendLock.RLock()
+	defer r.sendLock.RUnlock()
+
+	if r.closed {
+		r.logger.Info("Connection to receptor network has been closed")
+		return ErrConnectionClosed
+	}
+
+	if err := r.acquireInFlightSlot(); err != nil {
+		return err
+	}
 
-	return sendMessage(r.logger, r.Transport.Ctx, r.Transport.Send, msgSenderCtx, msg)
+	err = sendMessage(r.logger, r.Transport.Ctx, r.Transport.Send, msgSenderCtx, msg)
+	if err != nil {
+		r.releaseInFlightSlot()
+	}
+
+	return err
 }
 
 func sendMessage(logger *logrus.Entry, transportCtx context.Context, sendChannel chan ReceptorMessage, msgSenderCtx context.Context, msgToSend ReceptorMessage) error {
@@ -187,7 +619,7 @@ func sendMessage(logger *logrus.Entry, transportCtx context.Context, sendChannel
 
 	case <-transportCtx.Done():
 		logger.Info("Connection to receptor network lost")
-		return connectionToReceptorNetworkLost
+		return ErrConnectionClosed
 
 	case <-msgSenderCtx.Done():
 		switch msgSenderCtx.Err().(error) {
@@ -202,33 +634,54 @@ func sendMessage(logger *logrus.Entry, transportCtx context.Context, sendChannel
 }
 
 // FIXME:  Does it make sense to move this logic to the transport object?  Or am I missing an abstraction?
-func (r *ReceptorService) waitForResponse(msgSenderCtx context.Context, responseChannel chan ResponseMessage) (ResponseMessage, error) {
+// waitForResponse waits for the response to a request sent at sentAt.  The
+// wait fails if the response is dropped, or if a message that responds to an
+// unknown request is dropped after the request was sent since the dropped
+// message may have been the response.
+func (r *ReceptorService) waitForResponse(msgSenderCtx context.Context, responseChannel chan ResponseMessage, droppedChannel chan DroppedMessage, sentAt time.Time) (ResponseMessage, error) {
 	r.logger.Info("Waiting for a sync response")
 	nilResponseMessage := ResponseMessage{}
 
-	select {
-
-	case responseMsg := <-responseChannel:
-		return responseMsg, nil
-
-	case <-r.Transport.Ctx.Done():
-		r.logger.Info("Connection to receptor network lost")
-		return nilResponseMessage, connectionToReceptorNetworkLost
-
-	case <-msgSenderCtx.Done():
-		switch msgSenderCtx.Err().(error) {
-		case context.DeadlineExceeded:
-			r.logger.Info("Timed out waiting for response for message")
-			return nilResponseMessage, requestTimedOut
-		default:
-			r.logger.Info("Message cancelled by sender")
-			return nilResponseMessage, requestCancelledBySender
+	for {
+		select {
+
+		case responseMsg := <-responseChannel:
+			return responseMsg, nil
+
+		case <-r.Transport.Ctx.Done():
+			r.logger.Info("Connection to receptor network lost")
+			return nilResponseMessage, ErrConnectionClosed
+
+		case dropped := <-r.Transport.Dropped:
+			// The dropped message may be meant for another sender
+			r.droppedResponseRegistrar.Dispatch(dropped)
+
+		case dropped := <-droppedChannel:
+			if dropped.DroppedAt.Before(sentAt) {
+				continue
+			}
+			r.logger.WithFields(logrus.Fields{"error": dropped.Reason}).Info("Response from the node was dropped")
+			return nilResponseMessage, ResponseDroppedError{Reason: dropped.Reason}
+
+		case <-msgSenderCtx.Done():
+			switch msgSenderCtx.Err().(error) {
+			case context.DeadlineExceeded:
+				r.logger.Info("Timed out waiting for response for message")
+				return nilResponseMessage, requestTimedOut
+			default:
+				r.logger.Info("Message cancelled by sender")
+				return nilResponseMessage, requestCancelledBySender
+			}
 		}
 	}
 }
 
 func (r *ReceptorService) DispatchResponse(payloadMessage *protocol.PayloadMessage) {
 
+	if payloadMessage.Data.RetryAfter > 0 {
+		r.BackOff(retryAfterDuration(payloadMessage.Data.RetryAfter))
+	}
+
 	responseMessage := ResponseMessage{
 		AccountNumber: r.AccountNumber,
 		Sender:        payloadMessage.RoutingInfo.Sender,
@@ -238,6 +691,7 @@ func (r *ReceptorService) DispatchResponse(payloadMessage *protocol.PayloadMessa
 		Code:          payloadMessage.Data.Code,
 		InResponseTo:  payloadMessage.Data.InResponseTo,
 		Serial:        payloadMessage.Data.Serial,
+		TraceContext:  extractTraceContext(payloadMessage),
 	}
 
 	inResponseTo, err := uuid.Parse(payloadMessage.Data.InResponseTo)
@@ -256,19 +710,34 @@ func (r *ReceptorService) DispatchResponse(payloadMessage *protocol.PayloadMessa
 
 	r.logger.WithFields(logrus.Fields{"in_response_to": inResponseTo}).Info("Dispatching response message")
 
+	if responseMessage.MessageType == EOF_MESSAGE_TYPE {
+		r.recordJobEnded(inResponseTo)
+	}
+
 	jsonResponseMessage, err := json.Marshal(responseMessage)
 	if err != nil {
 		r.logger.Info("JSON marshal of ResponseMessage failed, err:", err)
 		return
 	}
 
-	// FIXME:  spawn a go routine here?  Make sure to honor the ctx
+	if r.pendingResponseWrites != nil {
+		r.pendingResponseWrites.Add(1)
+	}
+
+	// The response has already been received from the node, so the write is
+	// not tied to the connection (which is closed during shutdown).  It is
+	// bounded by the write timeout instead.
 	go func() {
+		if r.pendingResponseWrites != nil {
+			defer r.pendingResponseWrites.Done()
+		}
+
 		metrics.responseKafkaWriterGoRoutineGauge.Inc()
-		err = r.kafkaWriter.WriteMessages(r.Transport.Ctx,
+		err = queue.WriteMessages(context.Background(), r.kafkaWriter, r.config.KafkaResponsesWriteTimeout,
 			kafka.Message{
-				Key:   []byte(payloadMessage.Data.InResponseTo),
-				Value: jsonResponseMessage,
+				Key:     []byte(payloadMessage.Data.InResponseTo),
+				Value:   jsonResponseMessage,
+				Headers: responseMessage.TraceContext.kafkaHeaders(),
 			})
 
 		if err != nil {
@@ -281,30 +750,173 @@ func (r *ReceptorService) DispatchResponse(payloadMessage *protocol.PayloadMessa
 
 }
 
+// Close shuts down the connection to the receptor node.  The read and write
+// goroutines are signalled to exit and the send channel is closed.  If the
+// goroutines have not exited within the configured close timeout (or before
+// ctx is done), the underlying connection is forcibly closed.  The node is
+// told why the connection was closed using the close reason carried by ctx
+// (see WithCloseReason).  Senders waiting on the connection are failed with
+// ErrConnectionClosed, as are the messages that were queued but not yet
+// written.  Close is safe to call multiple times.
 func (r *ReceptorService) Close(ctx context.Context) error {
-	r.logger.Info("Closing connection")
-	r.Transport.Cancel()
+	r.closeOnce.Do(func() {
+		reason := GetCloseReason(ctx)
+		r.logger.WithFields(logrus.Fields{"reason": reason}).Info("Closing connection")
+
+		if r.Transport.SetCloseReason != nil {
+			r.Transport.SetCloseReason(reason)
+		}
+
+		if hint := GetReconnectHint(ctx); hint != "" && r.Transport.SetReconnectHint != nil {
+			r.logger.WithFields(logrus.Fields{"reconnect_to": hint}).Info("Asking the node to reconnect to another pod")
+			r.Transport.SetReconnectHint(hint)
+		}
+
+		r.Transport.Cancel()
+
+		// Any in-flight sends will bail out now that the transport has been
+		// cancelled, so grabbing the write lock will not block for long
+		r.sendLock.Lock()
+		r.closed = true
+		close(r.Transport.Send)
+		r.sendLock.Unlock()
+
+		r.stopBackOff()
+
+		r.waitForTransportToClose(ctx)
+
+		r.failQueuedMessages()
+
+		if r.forwarder != nil {
+			r.forwardHeldMessages()
+		}
+	})
+
 	return nil
 }
 
+// failQueuedMessages drains the messages that were left on the (closed) send
+// channel when the transport stopped writing
+func (r *ReceptorService) failQueuedMessages() {
+	for msg := range r.Transport.Send {
+		if msg.OnFailed != nil {
+			msg.OnFailed(ErrConnectionClosed)
+		}
+	}
+}
+
+func (r *ReceptorService) waitForTransportToClose(ctx context.Context) {
+	if r.Transport.Closed == nil {
+		return
+	}
+
+	timer := time.NewTimer(r.config.ReceptorCloseTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-r.Transport.Closed:
+		r.logger.Debug("Connection closed gracefully")
+		return
+	case <-timer.C:
+		r.logger.Info("Timed out waiting for connection to close gracefully...forcing the connection closed")
+	case <-ctx.Done():
+		r.logger.Info("Close cancelled while waiting for connection to close gracefully...forcing the connection closed")
+	}
+
+	if r.Transport.ForceClose != nil {
+		r.Transport.ForceClose()
+	}
+}
+
+// UpdateCapabilities replaces the capabilities that the node reported during
+// the handshake.  The capabilities are validated before they are cached.
+func (r *ReceptorService) UpdateCapabilities(capabilities interface{}) error {
+	parsedCapabilities, err := ParseCapabilities(capabilities)
+	if err != nil {
+		return err
+	}
+
+	r.metadataLock.Lock()
+	defer r.metadataLock.Unlock()
+
+	metadata := make(map[string]interface{})
+	if currentMetadata, ok := r.Metadata.(map[string]interface{}); ok {
+		for k, v := range currentMetadata {
+			metadata[k] = v
+		}
+	}
+	metadata["capabilities"] = parsedCapabilities
+
+	r.Metadata = metadata
+
+	return nil
+}
+
+// GetCapabilities returns the cached capabilities of the node.  If the node
+// is streaming new capabilities, the stream is waited for (see
+// waitForCapabilities).
 func (r *ReceptorService) GetCapabilities(ctx context.Context) (interface{}, error) {
-	emptyCapabilities := struct{}{}
+	if partialCapabilities, complete := r.waitForCapabilities(ctx); !complete {
+		return partialCapabilities, PartialCapabilitiesError{}
+	}
+
+	r.metadataLock.RLock()
+	defer r.metadataLock.RUnlock()
 
 	if r.Metadata == nil {
-		return emptyCapabilities, nil
+		return nil, MalformedCapabilitiesError{Reason: CAPABILITIES_EMPTY}
 	}
 
 	metadata, ok := r.Metadata.(map[string]interface{})
 	if ok != true {
-		return emptyCapabilities, nil
+		return nil, MalformedCapabilitiesError{Reason: CAPABILITIES_UNEXPECTED_SCHEMA, Raw: rawCapabilities(r.Metadata)}
 	}
 
 	capabilities, exist := metadata["capabilities"]
 	if exist != true {
-		return emptyCapabilities, nil
+		return nil, MalformedCapabilitiesError{Reason: CAPABILITIES_EMPTY}
+	}
+
+	parsedCapabilities, err := ParseCapabilities(capabilities)
+	if err != nil {
+		return nil, err
+	}
+
+	return parsedCapabilities, nil
+}
+
+func (r *ReceptorService) GetHealth(ctx context.Context) (string, error) {
+	var lastKeepalive time.Time
+	if r.Transport.Keepalive != nil {
+		lastKeepalive = r.Transport.Keepalive.LastKeepalive()
+	}
+
+	health := evaluateHealth(NewHealthThresholds(r.config),
+		time.Now(),
+		lastKeepalive,
+		len(r.Transport.Send),
+		cap(r.Transport.Send))
+
+	return health, nil
+}
+
+func (r *ReceptorService) GetRoutingTable(ctx context.Context) (*RoutingTable, error) {
+	return r.routingTable.get(), nil
+}
+
+// routeTo computes the shortest route to the recipient.  The node on the
+// other end of the connection is always reachable, even before it has sent
+// its routing table.
+func (r *ReceptorService) routeTo(recipient string) ([]string, error) {
+	routingTable := r.routingTable.get()
+	routingTable.Edges = append(routingTable.Edges, protocol.Edge{Left: r.NodeID, Right: r.PeerNodeID, Cost: 1})
+
+	route, found := routingTable.shortestRoute(r.NodeID, recipient)
+	if !found {
+		return nil, UnreachableRecipientError{Recipient: recipient}
 	}
 
-	return capabilities, nil
+	return route, nil
 }
 
 type DispatcherTable struct {
@@ -0,0 +1,565 @@
+package controller
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/queue"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redhatinsights/platform-go-middlewares/request_id"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	testAccount = "0000001"
+	testNodeID  = "node-a"
+)
+
+// testTransport simulates the websocket layer.  A single goroutine drains the
+// send channel until the transport is cancelled.  If ignoreCancel is set, the
+// goroutine only exits once ForceClose is called.
+func newTestTransport(ignoreCancel bool) *Transport {
+	ctx, cancel := context.WithCancel(context.Background())
+	forceClosed := make(chan struct{})
+	var forceCloseOnce sync.Once
+
+	transport := &Transport{
+		Send:           make(chan ReceptorMessage),
+		Recv:           make(chan protocol.Message),
+		ControlChannel: make(chan ReceptorMessage),
+		ErrorChannel:   make(chan ReceptorErrorMessage),
+		Ctx:            ctx,
+		Cancel:         cancel,
+		Closed:         make(chan struct{}),
+		ForceClose:     func() { forceCloseOnce.Do(func() { close(forceClosed) }) },
+	}
+
+	go func() {
+		defer close(transport.Closed)
+
+		done := ctx.Done()
+		if ignoreCancel {
+			done = nil
+		}
+
+		send := transport.Send
+		for {
+			select {
+			case <-done:
+				return
+			case <-forceClosed:
+				return
+			case msg, ok := <-send:
+				if !ok {
+					if !ignoreCancel {
+						return
+					}
+					send = nil
+					break
+				}
+				if msg.OnSent != nil {
+					msg.OnSent()
+				}
+			}
+		}
+	}()
+
+	return transport
+}
+
+// newCapturingTransport simulates a node.  The messages passed to the send
+// and control channels are captured instead of being written.
+func newCapturingTransport() (*Transport, chan ReceptorMessage) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sent := make(chan ReceptorMessage, 1)
+	transport := &Transport{
+		Send:           make(chan ReceptorMessage),
+		Recv:           make(chan protocol.Message),
+		ControlChannel: make(chan ReceptorMessage),
+		ErrorChannel:   make(chan ReceptorErrorMessage),
+		Ctx:            ctx,
+		Cancel:         cancel,
+	}
+
+	go func() {
+		for {
+			select {
+			case msg := <-transport.Send:
+				sent <- msg
+			case msg := <-transport.ControlChannel:
+				sent <- msg
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return transport, sent
+}
+
+func waitForSentPayloadMessage(t *testing.T, sent chan ReceptorMessage) *protocol.PayloadMessage {
+	select {
+	case msg := <-sent:
+		return msg.Message.(*protocol.PayloadMessage)
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the message to be passed to the transport")
+	}
+	return nil
+}
+
+func newTestReceptorService(cfg *config.Config, transport *Transport) *ReceptorService {
+	return newTestReceptorServiceWithOutbox(cfg, transport, NewInMemoryOutboxStore())
+}
+
+func newTestReceptorServiceWithOutbox(cfg *config.Config, transport *Transport, outbox OutboxStore) *ReceptorService {
+	// The warmup is covered by the tests in warmup_test.go
+	cfg.ReceptorWarmupGracePeriod = 0
+	return newWarmingUpTestReceptorService(cfg, transport, outbox)
+}
+
+func newWarmingUpTestReceptorService(cfg *config.Config, transport *Transport, outbox OutboxStore) *ReceptorService {
+	logger := logger.Log.WithFields(logrus.Fields{"account": testAccount})
+	factory := NewReceptorServiceFactory(nil, outbox, cfg)
+	receptor := factory.NewReceptorService(logger, testAccount, "node-cloud-receptor-controller")
+	receptor.RegisterConnection(testNodeID, nil, transport)
+	return receptor
+}
+
+func waitForGoroutineCount(t *testing.T, expected int) {
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > expected {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected at most %d goroutines, but found %d", expected, runtime.NumGoroutine())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReceptorServiceCloseDoesNotLeakGoroutines(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	cfg := config.GetConfig()
+	receptor := newTestReceptorService(cfg, newTestTransport(false))
+
+	err := receptor.Close(context.TODO())
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	waitForGoroutineCount(t, baseline)
+}
+
+func TestReceptorServiceCloseIsIdempotent(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	cfg := config.GetConfig()
+	receptor := newTestReceptorService(cfg, newTestTransport(false))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			receptor.Close(context.TODO())
+		}()
+	}
+	wg.Wait()
+
+	receptor.Close(context.TODO())
+
+	waitForGoroutineCount(t, baseline)
+}
+
+func TestReceptorServiceCloseForcesCloseAfterTimeout(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	cfg := config.GetConfig()
+	cfg.ReceptorCloseTimeout = 10 * time.Millisecond
+	transport := newTestTransport(true)
+	receptor := newTestReceptorService(cfg, transport)
+
+	receptor.Close(context.TODO())
+
+	select {
+	case <-transport.Closed:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the transport to be forcibly closed")
+	}
+
+	waitForGoroutineCount(t, baseline)
+}
+
+func TestReceptorServiceCloseWithInFlightSendMessage(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	cfg := config.GetConfig()
+	receptor := newTestReceptorService(cfg, newTestTransport(false))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action")
+		}()
+	}
+
+	receptor.Close(context.TODO())
+	wg.Wait()
+
+	_, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action")
+	if err != ErrConnectionClosed {
+		t.Fatalf("Expected %v, got %v", ErrConnectionClosed, err)
+	}
+
+	waitForGoroutineCount(t, baseline)
+}
+
+func TestReceptorServiceCloseFailsPendingAwait(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.ReceptorAckTimeout = time.Minute
+	receptor := newTestReceptorService(cfg, newBufferedTestTransport())
+
+	errChan := make(chan error, 1)
+	go func() {
+		_, err := receptor.SendMessageWithAck(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action")
+		errChan <- err
+	}()
+
+	// Give the sender a chance to start waiting for the ack
+	time.Sleep(50 * time.Millisecond)
+
+	receptor.Close(context.TODO())
+
+	select {
+	case err := <-errChan:
+		if err != ErrConnectionClosed {
+			t.Fatalf("Expected %v, got %v", ErrConnectionClosed, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the pending sender to return once the connection was closed")
+	}
+}
+
+func TestReceptorServiceNodeDisconnectFailsPendingAwait(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.ReceptorAckTimeout = time.Minute
+	outbox := NewInMemoryOutboxStore()
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorServiceWithOutbox(cfg, transport, outbox)
+
+	messageIDs := make(chan *uuid.UUID, 1)
+	errChan := make(chan error, 1)
+	go func() {
+		messageID, err := receptor.SendMessageWithAck(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action")
+		messageIDs <- messageID
+		errChan <- err
+	}()
+
+	// Give the sender a chance to queue the message and start waiting
+	time.Sleep(50 * time.Millisecond)
+
+	// The node drops the connection: the transport goes away first, then the
+	// disconnect handler runs.  The controller never calls Close itself.
+	transport.Cancel()
+	closeReceptorService(receptor, transport)
+
+	select {
+	case err := <-errChan:
+		if err != ErrConnectionClosed {
+			t.Fatalf("Expected %v, got %v", ErrConnectionClosed, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the pending sender to return once the node dropped the connection")
+	}
+
+	entry, _ := outbox.Get(context.TODO(), *<-messageIDs)
+	if entry.Status != OUTBOX_FAILED_STATUS {
+		t.Fatalf("Expected the queued message to be %s, got %s", OUTBOX_FAILED_STATUS, entry.Status)
+	}
+
+	if count := receptor.getInFlightCount(); count != 0 {
+		t.Fatalf("Expected no messages in flight, got %d", count)
+	}
+}
+
+func TestReceptorServiceCloseFailsQueuedMessages(t *testing.T) {
+	cfg := config.GetConfig()
+	outbox := NewInMemoryOutboxStore()
+	receptor := newTestReceptorServiceWithOutbox(cfg, newBufferedTestTransport(), outbox)
+
+	messageID, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action")
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	receptor.Close(context.TODO())
+
+	entry, _ := outbox.Get(context.TODO(), *messageID)
+	if entry.Status != OUTBOX_FAILED_STATUS {
+		t.Fatalf("Expected the message to be %s, got %s", OUTBOX_FAILED_STATUS, entry.Status)
+	}
+}
+
+func TestReceptorServiceUpdateRoutingTable(t *testing.T) {
+	cfg := config.GetConfig()
+	receptor := newTestReceptorService(cfg, newTestTransport(false))
+	defer receptor.Close(context.TODO())
+
+	rawEdges := [][]interface{}{{"node-a", "node-b", float64(1)}, {"node-b", "node-c", float64(2)}}
+	seen := []string{"node-a", "node-b", "node-c"}
+
+	err := receptor.UpdateRoutingTable(rawEdges, seen)
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	routingTable, _ := receptor.GetRoutingTable(context.TODO())
+
+	expected := &RoutingTable{
+		Edges: []protocol.Edge{{Left: "node-a", Right: "node-b", Cost: 1}, {Left: "node-b", Right: "node-c", Cost: 2}},
+		Seen:  seen,
+	}
+	if cmp.Equal(expected, routingTable) != true {
+		t.Fatalf("Expected routing table %+v, got %+v", expected, routingTable)
+	}
+
+	err = receptor.UpdateRoutingTable([][]interface{}{{"node-a"}}, seen)
+	if err == nil {
+		t.Fatalf("Expected an error for an invalid edge")
+	}
+
+	routingTable, _ = receptor.GetRoutingTable(context.TODO())
+	if cmp.Equal(expected, routingTable) != true {
+		t.Fatalf("Expected routing table to be unchanged after an invalid update, got %+v", routingTable)
+	}
+}
+
+func waitForOutboxStatus(t *testing.T, outbox OutboxStore, messageID uuid.UUID, expected string) {
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		entry, err := outbox.Get(context.TODO(), messageID)
+		if err == nil && entry.Status == expected {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the outbox entry to be %s, got %+v (error: %v)", expected, entry, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReceptorServiceSendMessageRecordsMessageInOutbox(t *testing.T) {
+	cfg := config.GetConfig()
+	outbox := NewInMemoryOutboxStore()
+	receptor := newTestReceptorServiceWithOutbox(cfg, newTestTransport(false), outbox)
+	defer receptor.Close(context.TODO())
+
+	messageID, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action")
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	waitForOutboxStatus(t, outbox, *messageID, OUTBOX_SENT_STATUS)
+
+	entry, _ := outbox.Get(context.TODO(), *messageID)
+	if entry.AccountNumber != testAccount || entry.Message.Recipient != testNodeID || entry.Message.Directive != "worker:action" {
+		t.Fatalf("Unexpected outbox entry %+v", entry)
+	}
+}
+
+func TestReceptorServiceSendMessageFailureIsRecordedInOutbox(t *testing.T) {
+	cfg := config.GetConfig()
+	outbox := NewInMemoryOutboxStore()
+	transportCtx, transportCancel := context.WithCancel(context.Background())
+	transport := &Transport{
+		Send:   make(chan ReceptorMessage), // nothing reads from this channel
+		Ctx:    transportCtx,
+		Cancel: transportCancel,
+	}
+	receptor := newTestReceptorServiceWithOutbox(cfg, transport, outbox)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := receptor.SendMessage(ctx, testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action")
+	if err != requestCancelledBySender {
+		t.Fatalf("Expected %v, got %v", requestCancelledBySender, err)
+	}
+
+	pending, _ := outbox.GetPending(context.TODO(), time.Now().Add(time.Minute))
+	if len(pending) != 0 {
+		t.Fatalf("Expected no pending messages, got %+v", pending)
+	}
+
+	receptor.Close(context.TODO())
+}
+
+func TestReceptorServiceFactoryCloseFlushesPendingResponses(t *testing.T) {
+	// The broker accepts connections but never responds, so the response
+	// write only completes once the write timeout has been reached
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to start the fake broker: %v", err)
+	}
+	defer listener.Close()
+
+	var connsLock sync.Mutex
+	var conns []net.Conn
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			connsLock.Lock()
+			conns = append(conns, conn)
+			connsLock.Unlock()
+		}
+	}()
+	defer func() {
+		connsLock.Lock()
+		defer connsLock.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+
+	cfg := config.GetConfig()
+	cfg.KafkaResponsesWriteTimeout = 200 * time.Millisecond
+
+	w := queue.StartProducer(&queue.ProducerConfig{
+		Brokers:      []string{listener.Addr().String()},
+		Topic:        "platform.receptor-controller.responses",
+		BatchSize:    1,
+		BatchBytes:   1048576,
+		Compression:  queue.NoCompression,
+		WriteTimeout: cfg.KafkaResponsesWriteTimeout,
+	})
+
+	logger := logger.Log.WithFields(logrus.Fields{"account": testAccount})
+	factory := NewReceptorServiceFactory(w, NewInMemoryOutboxStore(), cfg)
+	receptor := factory.NewReceptorService(logger, testAccount, "node-cloud-receptor-controller")
+
+	failuresBefore := testutil.ToFloat64(metrics.responseKafkaWriterFailureCounter)
+
+	receptor.DispatchResponse(&protocol.PayloadMessage{
+		RoutingInfo: &protocol.RoutingMessage{Sender: testNodeID},
+		Data: protocol.InnerEnvelope{
+			MessageID:    uuid.New().String(),
+			InResponseTo: uuid.New().String(),
+			RawPayload:   "response",
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// The fake broker never responds, so the writer is unable to flush the
+	// message left behind by the failed write
+	err = factory.Close(ctx)
+	if _, ok := err.(queue.CloseTimeoutError); !ok {
+		t.Fatalf("Expected a CloseTimeoutError, got %v", err)
+	}
+
+	// The pending write must have been attempted (and failed against the
+	// fake broker) before the writer was closed
+	failures := testutil.ToFloat64(metrics.responseKafkaWriterFailureCounter) - failuresBefore
+	if failures != 1 {
+		t.Fatalf("Expected the pending response write to complete before close, got %v failed writes", failures)
+	}
+}
+
+func TestReceptorServiceSendMessageRoutes(t *testing.T) {
+	transport, sent := newCapturingTransport()
+	defer transport.Cancel()
+
+	receptor := newTestReceptorService(config.GetConfig(), transport)
+
+	// node-a is connected to the gateway.  node-c can be reached through
+	// node-b (cost 2) or through node-d (cost 5).
+	rawEdges := [][]interface{}{
+		{"node-a", "node-b", float64(1)},
+		{"node-b", "node-c", float64(1)},
+		{"node-a", "node-d", float64(1)},
+		{"node-d", "node-c", float64(4)},
+	}
+	if err := receptor.UpdateRoutingTable(rawEdges, []string{"node-a", "node-b", "node-c", "node-d"}); err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	testCases := []struct {
+		recipient     string
+		route         []string
+		expectedRoute []string
+	}{
+		{"node-c", []string{"node-a", "node-d", "node-c"}, []string{"node-a", "node-d", "node-c"}},
+		{"node-c", nil, []string{"node-a", "node-b", "node-c"}},
+		{"node-d", []string{}, []string{"node-a", "node-d"}},
+		{testNodeID, nil, []string{testNodeID}},
+	}
+
+	for _, tc := range testCases {
+		if _, err := receptor.SendMessage(context.TODO(), testAccount, tc.recipient, tc.route, "payload", "fred:flintstone"); err != nil {
+			t.Fatalf("Expected the error to be nil, got %v", err)
+		}
+
+		route := waitForSentPayloadMessage(t, sent).RoutingInfo.RouteList
+		if cmp.Equal(tc.expectedRoute, route) != true {
+			t.Fatalf("Expected the message to %s to be sent with route %v, got %v", tc.recipient, tc.expectedRoute, route)
+		}
+	}
+}
+
+func TestReceptorServiceSendMessageToUnreachableRecipient(t *testing.T) {
+	transport, _ := newCapturingTransport()
+	defer transport.Cancel()
+
+	receptor := newTestReceptorService(config.GetConfig(), transport)
+
+	messageID, err := receptor.SendMessage(context.TODO(), testAccount, "node-z", nil, "payload", "fred:flintstone")
+	if messageID != nil {
+		t.Fatalf("Expected the message id to be nil, got %v", messageID)
+	}
+	if err != (UnreachableRecipientError{Recipient: "node-z"}) {
+		t.Fatalf("Expected an unreachable recipient error, got %v", err)
+	}
+}
+
+func TestReceptorServiceSendsRequestIDToNode(t *testing.T) {
+	transport, sent := newCapturingTransport()
+	defer transport.Cancel()
+
+	receptor := newTestReceptorService(config.GetConfig(), transport)
+
+	ctx := context.WithValue(context.Background(), request_id.RequestIDKey, "test-request-id")
+
+	if _, err := receptor.SendMessage(ctx, testAccount, testNodeID, []string{testNodeID}, "payload", "fred:flintstone"); err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	if requestID := waitForSentPayloadMessage(t, sent).Data.RequestID; requestID != "test-request-id" {
+		t.Fatalf("Expected the request id to be sent to the node, got %q", requestID)
+	}
+
+	// The ping is not answered, it only needs to be sent
+	pingCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+
+	go receptor.Ping(pingCtx, testAccount, testNodeID, []string{testNodeID})
+
+	if requestID := waitForSentPayloadMessage(t, sent).Data.RequestID; requestID != "test-request-id" {
+		t.Fatalf("Expected the request id to be sent to the node with the ping, got %q", requestID)
+	}
+}
@@ -1,18 +1,42 @@
 package controller
 
 import (
+	"encoding/json"
 	"strings"
+	"time"
 
 	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
 	"github.com/go-redis/redis"
 )
 
 var allConnectionsKey = "connections"
+var importedConnectionsKey = "imported_connections"
+
+const (
+	OWNERSHIP_REGISTERED_REASON   = "registered"
+	OWNERSHIP_UNREGISTERED_REASON = "unregistered"
+	OWNERSHIP_IMPORTED_REASON     = "imported"
+
+	// maxConnectionHistory is the number of ownership changes kept per node
+	maxConnectionHistory = 50
+
+	// connectionHistoryTTL is how long the history of a node that no longer
+	// connects is kept
+	connectionHistoryTTL = 7 * 24 * time.Hour
+)
 
 func getConnectionKey(account, nodeID string) string {
 	return account + ":" + nodeID
 }
 
+func getConnectionHistoryKey(account, nodeID string) string {
+	return "history:" + account + ":" + nodeID
+}
+
+func getJobLocationKey(jobID string) string {
+	return "job:" + jobID
+}
+
 func getAllConnectionsIndexVal(account, nodeID, hostname string) string {
 	return account + ":" + nodeID + ":" + hostname
 }
@@ -41,11 +65,20 @@ func ExistsInRedis(client *redis.Client, account, nodeID string) bool {
 	return client.Exists(account+":"+nodeID).Val() != 0
 }
 
+// IsImportedInRedis returns true if the connection was primed by an import
+// and a real registration for the connection has not been seen yet
+func IsImportedInRedis(client *redis.Client, account, nodeID string) bool {
+	return client.SIsMember(importedConnectionsKey, getConnectionKey(account, nodeID)).Val()
+}
+
 func RegisterWithRedis(client *redis.Client, account, nodeID, hostname string) error {
 	var res bool
 	var regErr error
 
 	_, err := client.TxPipelined(func(pipe redis.Pipeliner) error {
+		if IsImportedInRedis(client, account, nodeID) {
+			removeImportedConnection(client, account, nodeID)
+		}
 		res, regErr = client.SetNX(getConnectionKey(account, nodeID), hostname, 0).Result()
 		if res {
 			addIndexes(client, account, nodeID, hostname)
@@ -62,6 +95,8 @@ func RegisterWithRedis(client *redis.Client, account, nodeID, hostname string) e
 		return DuplicateConnectionError{}
 	}
 
+	recordOwnershipChange(client, account, nodeID, hostname, OWNERSHIP_REGISTERED_REASON)
+
 	logger.Log.Printf("Registered a connection (%s, %s) to Redis", account, nodeID)
 	return nil
 }
@@ -69,13 +104,122 @@ func RegisterWithRedis(client *redis.Client, account, nodeID, hostname string) e
 func UnregisterWithRedis(client *redis.Client, account, nodeID, hostname string) {
 	_, err := client.TxPipelined(func(pipe redis.Pipeliner) error {
 		client.Del(getConnectionKey(account, nodeID))
+		client.SRem(importedConnectionsKey, getConnectionKey(account, nodeID))
 		removeIndexes(client, account, nodeID, hostname)
 		return nil
 	})
 
 	if err != nil {
 		logger.Log.Print("Error attempting to unregister connection from Redis")
+		return
 	}
+
+	recordOwnershipChange(client, account, nodeID, hostname, OWNERSHIP_UNREGISTERED_REASON)
+}
+
+// ImportWithRedis primes the connection lookup with a connection that is
+// believed to be on the given pod.  A connection that has been registered by a
+// gateway pod is never overwritten.  An imported connection is replaced by the
+// next real registration of the same account / node id.  Returns false if the
+// connection was skipped.
+func ImportWithRedis(client *redis.Client, account, nodeID, hostname string) (bool, error) {
+	var imported bool
+
+	_, err := client.TxPipelined(func(pipe redis.Pipeliner) error {
+		if ExistsInRedis(client, account, nodeID) {
+			if !IsImportedInRedis(client, account, nodeID) {
+				return nil
+			}
+			removeImportedConnection(client, account, nodeID)
+		}
+
+		if err := client.Set(getConnectionKey(account, nodeID), hostname, 0).Err(); err != nil {
+			return err
+		}
+		client.SAdd(importedConnectionsKey, getConnectionKey(account, nodeID))
+		addIndexes(client, account, nodeID, hostname)
+		imported = true
+		return nil
+	})
+
+	if err != nil {
+		logger.Log.Print("Error attempting to import connection to Redis")
+		return false, err
+	}
+	if !imported {
+		logger.Log.Printf("Connection (%s, %s) already registered. Not importing.", account, nodeID)
+		return false, nil
+	}
+
+	recordOwnershipChange(client, account, nodeID, hostname, OWNERSHIP_IMPORTED_REASON)
+
+	logger.Log.Printf("Imported a connection (%s, %s) to Redis", account, nodeID)
+	return true, nil
+}
+
+// recordOwnershipChange appends the change to the history of the node.  Only
+// the most recent changes are kept.  A failure to record the change is logged,
+// it does not fail the registration.
+func recordOwnershipChange(client *redis.Client, account, nodeID, hostname, reason string) {
+	change, _ := json.Marshal(ConnectionOwnershipChange{Pod: hostname, Timestamp: time.Now().UTC(), Reason: reason})
+
+	key := getConnectionHistoryKey(account, nodeID)
+	_, err := client.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.RPush(key, change)
+		pipe.LTrim(key, -maxConnectionHistory, -1)
+		pipe.Expire(key, connectionHistoryTTL)
+		return nil
+	})
+
+	if err != nil {
+		logger.Log.Printf("Error attempting to record the ownership change of connection (%s, %s) in Redis", account, nodeID)
+	}
+}
+
+// GetRedisConnectionHistory returns the ownership changes of the node, oldest
+// first
+func GetRedisConnectionHistory(client *redis.Client, account, nodeID string) ([]ConnectionOwnershipChange, error) {
+	entries, err := client.LRange(getConnectionHistoryKey(account, nodeID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]ConnectionOwnershipChange, 0, len(entries))
+	for _, entry := range entries {
+		var change ConnectionOwnershipChange
+		if err := json.Unmarshal([]byte(entry), &change); err != nil {
+			return nil, err
+		}
+		history = append(history, change)
+	}
+
+	return history, nil
+}
+
+// RecordJobLocationWithRedis records the pod that a job was sent through, so
+// that the services that do not own the connections can find the job later.
+// A ttl of 0 keeps the record forever.
+func RecordJobLocationWithRedis(client *redis.Client, jobID, hostname string, ttl time.Duration) error {
+	return client.Set(getJobLocationKey(jobID), hostname, ttl).Err()
+}
+
+// GetRedisJobLocation returns the pod that the job was sent through, or an
+// empty string if the job is not known
+func GetRedisJobLocation(client *redis.Client, jobID string) (string, error) {
+	hostname, err := client.Get(getJobLocationKey(jobID)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return hostname, err
+}
+
+func removeImportedConnection(client *redis.Client, account, nodeID string) {
+	hostname, err := GetRedisConnection(client, account, nodeID)
+	if err == nil {
+		removeIndexes(client, account, nodeID, hostname)
+	}
+	client.Del(getConnectionKey(account, nodeID))
+	client.SRem(importedConnectionsKey, getConnectionKey(account, nodeID))
 }
 
 func GetRedisConnection(client *redis.Client, account, nodeID string) (string, error) {
@@ -128,3 +272,33 @@ func GetAllRedisConnections(client *redis.Client) (map[string]map[string]string,
 	}
 	return connectionsMap, err
 }
+
+// GetRedisConnectionsByAccountPrefix returns the connections of all accounts
+// that start with the prefix
+func GetRedisConnectionsByAccountPrefix(client *redis.Client, prefix string) (map[string]map[string]string, error) {
+	connectionsMap := make(map[string]map[string]string)
+
+	var cursor uint64
+	for {
+		var matchingConnections []string
+		var err error
+
+		matchingConnections, cursor, err = client.SScan(allConnectionsKey, cursor, prefix+"*", 1000).Result()
+		if err != nil {
+			return connectionsMap, err
+		}
+
+		for _, conn := range matchingConnections {
+			s := strings.Split(conn, ":")
+			account, nodeID, hostname := s[0], s[1], s[2]
+			if _, exists := connectionsMap[account]; !exists {
+				connectionsMap[account] = make(map[string]string)
+			}
+			connectionsMap[account][nodeID] = hostname
+		}
+
+		if cursor == 0 {
+			return connectionsMap, nil
+		}
+	}
+}
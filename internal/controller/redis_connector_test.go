@@ -286,3 +286,157 @@ func TestGetAllRedisConnections(t *testing.T) {
 		"02": {"node-b": testHost},
 	}, res)
 }
+
+func TestImportWithRedis(t *testing.T) {
+	s, _ := miniredis.Run()
+	defer s.Close()
+
+	c := newTestRedisClient(s.Addr())
+
+	_ = RegisterWithRedis(c, "01", "node-a", testHost)
+
+	tests := []struct {
+		account      string
+		nodeID       string
+		hostname     string
+		expectedHost string
+		imported     bool
+	}{
+		{account: "01", nodeID: "node-a", hostname: "gateway-pod-9", expectedHost: testHost, imported: false},
+		{account: "01", nodeID: "node-b", hostname: "gateway-pod-9", expectedHost: "gateway-pod-9", imported: true},
+		{account: "01", nodeID: "node-b", hostname: "gateway-pod-8", expectedHost: "gateway-pod-8", imported: true},
+	}
+
+	for _, tc := range tests {
+		imported, err := ImportWithRedis(c, tc.account, tc.nodeID, tc.hostname)
+		if err != nil {
+			t.Fatalf("error importing connection: %v", err)
+		}
+		assert.Equal(t, imported, tc.imported)
+		assert.Equal(t, c.Get(tc.account+":"+tc.nodeID).Val(), tc.expectedHost)
+	}
+
+	assert.Equal(t, c.SMembers("gateway-pod-9").Val(), []string{})
+	assert.Equal(t, c.SMembers("gateway-pod-8").Val(), []string{"01:node-b"})
+	assert.Equal(t, c.SMembers("01").Val(), []string{"node-a:" + testHost, "node-b:gateway-pod-8"})
+}
+
+func TestRegisterWithRedisReplacesImportedConnection(t *testing.T) {
+	s, _ := miniredis.Run()
+	defer s.Close()
+
+	c := newTestRedisClient(s.Addr())
+
+	_, _ = ImportWithRedis(c, "01", "node-a", "gateway-pod-9")
+
+	err := RegisterWithRedis(c, "01", "node-a", testHost)
+	if err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+
+	assert.Equal(t, c.Get("01:node-a").Val(), testHost)
+	assert.Equal(t, IsImportedInRedis(c, "01", "node-a"), false)
+	assert.Equal(t, c.SMembers("01").Val(), []string{"node-a:" + testHost})
+	assert.Equal(t, c.SMembers("connections").Val(), []string{"01:node-a:" + testHost})
+	assert.Equal(t, c.SMembers("gateway-pod-9").Val(), []string{})
+
+	err = RegisterWithRedis(c, "01", "node-a", "dupe-conn")
+	assert.Equal(t, err, DuplicateConnectionError{})
+}
+
+func TestGetRedisConnectionsByAccountPrefix(t *testing.T) {
+	s, _ := miniredis.Run()
+	defer s.Close()
+
+	c := newTestRedisClient(s.Addr())
+
+	_ = RegisterWithRedis(c, "901", "node-a", testHost)
+	_ = RegisterWithRedis(c, "901", "node-b", testHost)
+	_ = RegisterWithRedis(c, "902", "node-a", "gateway-pod-9")
+	_ = RegisterWithRedis(c, "01", "node-a", testHost)
+
+	tests := []struct {
+		prefix string
+		want   map[string]map[string]string
+	}{
+		{prefix: "90", want: map[string]map[string]string{
+			"901": {"node-a": testHost, "node-b": testHost},
+			"902": {"node-a": "gateway-pod-9"},
+		}},
+		{prefix: "902", want: map[string]map[string]string{"902": {"node-a": "gateway-pod-9"}}},
+		{prefix: "8", want: map[string]map[string]string{}},
+	}
+
+	for _, tc := range tests {
+		res, err := GetRedisConnectionsByAccountPrefix(c, tc.prefix)
+		if err != nil {
+			t.Fatalf("error getting connections by account prefix: %v", err)
+		}
+		assert.Equal(t, res, tc.want)
+	}
+}
+
+func TestConnectionHistoryRecordsOwnershipChanges(t *testing.T) {
+	s, _ := miniredis.Run()
+	defer s.Close()
+
+	c := newTestRedisClient(s.Addr())
+
+	_, _ = ImportWithRedis(c, "01", "node-a", "gateway-pod-9")
+	_ = RegisterWithRedis(c, "01", "node-a", "gateway-pod-1")
+	UnregisterWithRedis(c, "01", "node-a", "gateway-pod-1")
+	_ = RegisterWithRedis(c, "01", "node-a", "gateway-pod-2")
+
+	// A duplicate registration does not change the owner
+	_ = RegisterWithRedis(c, "01", "node-a", "gateway-pod-3")
+
+	history, err := GetRedisConnectionHistory(c, "01", "node-a")
+	if err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+
+	expected := []struct {
+		pod    string
+		reason string
+	}{
+		{pod: "gateway-pod-9", reason: OWNERSHIP_IMPORTED_REASON},
+		{pod: "gateway-pod-1", reason: OWNERSHIP_REGISTERED_REASON},
+		{pod: "gateway-pod-1", reason: OWNERSHIP_UNREGISTERED_REASON},
+		{pod: "gateway-pod-2", reason: OWNERSHIP_REGISTERED_REASON},
+	}
+
+	if len(history) != len(expected) {
+		t.Fatalf("expected %d ownership changes, got: %+v", len(expected), history)
+	}
+	for i, change := range history {
+		assert.Equal(t, change.Pod, expected[i].pod)
+		assert.Equal(t, change.Reason, expected[i].reason)
+		if i > 0 && change.Timestamp.Before(history[i-1].Timestamp) {
+			t.Fatalf("expected the ownership changes to be in order, got: %+v", history)
+		}
+	}
+
+	history, _ = GetRedisConnectionHistory(c, "01", "node-b")
+	assert.Equal(t, history, []ConnectionOwnershipChange{})
+}
+
+func TestConnectionHistoryIsCapped(t *testing.T) {
+	s, _ := miniredis.Run()
+	defer s.Close()
+
+	c := newTestRedisClient(s.Addr())
+
+	for i := 0; i < maxConnectionHistory; i++ {
+		_ = RegisterWithRedis(c, "01", "node-a", "gateway-pod-1")
+		UnregisterWithRedis(c, "01", "node-a", "gateway-pod-1")
+	}
+	_ = RegisterWithRedis(c, "01", "node-a", "gateway-pod-2")
+
+	history, _ := GetRedisConnectionHistory(c, "01", "node-a")
+	if len(history) != maxConnectionHistory {
+		t.Fatalf("expected %d ownership changes, got: %d", maxConnectionHistory, len(history))
+	}
+
+	assert.Equal(t, history[0].Reason, OWNERSHIP_UNREGISTERED_REASON)
+	assert.Equal(t, history[len(history)-1].Pod, "gateway-pod-2")
+}
@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"sync"
+)
+
+const (
+	REGISTRATION_HOOK_POLICY = "policy"
+	REGISTRATION_HOOK_QUOTA  = "quota"
+)
+
+// RegistrationRequest describes the connection that a node is trying to
+// register
+type RegistrationRequest struct {
+	Account  string
+	NodeID   string
+	Metadata interface{}
+	Labels   map[string]string
+}
+
+// RegistrationHook is run during the handshake, before the connection is
+// registered.  Returning an error rejects the connection; the error is passed
+// to the transport as is so that it determines the close code sent to the node.
+type RegistrationHook func(req RegistrationRequest) error
+
+type registrationHook struct {
+	name string
+	hook RegistrationHook
+}
+
+// RegistrationHookChain runs the registration hooks in the order they were
+// added.  The chain stops at the first hook that rejects the connection.
+type RegistrationHookChain struct {
+	lock  sync.RWMutex
+	hooks []registrationHook
+}
+
+func NewRegistrationHookChain() *RegistrationHookChain {
+	return &RegistrationHookChain{}
+}
+
+// Add appends a hook to the chain.  A hook added with the name of an existing
+// hook replaces it, keeping its position in the chain.
+func (c *RegistrationHookChain) Add(name string, hook RegistrationHook) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for i := range c.hooks {
+		if c.hooks[i].name == name {
+			c.hooks[i].hook = hook
+			return
+		}
+	}
+
+	c.hooks = append(c.hooks, registrationHook{name: name, hook: hook})
+}
+
+// Names returns the names of the hooks in the order they are run
+func (c *RegistrationHookChain) Names() []string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	names := make([]string, 0, len(c.hooks))
+	for _, h := range c.hooks {
+		names = append(names, h.name)
+	}
+	return names
+}
+
+// Run runs the hooks in order.  The name of the hook that rejected the
+// connection is returned along with its error.
+func (c *RegistrationHookChain) Run(req RegistrationRequest) (string, error) {
+	if c == nil {
+		return "", nil
+	}
+
+	c.lock.RLock()
+	hooks := make([]registrationHook, len(c.hooks))
+	copy(hooks, c.hooks)
+	c.lock.RUnlock()
+
+	for _, h := range hooks {
+		if err := h.hook(req); err != nil {
+			return h.name, err
+		}
+	}
+
+	return "", nil
+}
+
+// NewConnectionPolicyHook rejects the connections that the policy does not
+// allow with a ConnectionRejectedError
+func NewConnectionPolicyHook(policy ConnectionPolicy) RegistrationHook {
+	return func(req RegistrationRequest) error {
+		if allowed, reason := policy.Allow(req.Account, req.NodeID, req.Metadata); !allowed {
+			metrics.rejectedConnectionCounter.Inc()
+			return ConnectionRejectedError{Reason: reason}
+		}
+		return nil
+	}
+}
+
+// ConnectionQuotaChecker is implemented by connection managers that enforce a
+// per-account connection limit
+type ConnectionQuotaChecker interface {
+	CheckConnectionQuota(account string) error
+}
+
+// NewConnectionQuotaHook rejects the connections of the accounts that have
+// reached their connection limit with a TooManyConnectionsError.  This saves
+// completing the handshake of a connection that would not be registered; the
+// limit is still enforced when the connection is registered, as connections
+// of the same account can be racing through the handshake.
+func NewConnectionQuotaHook(checker ConnectionQuotaChecker) RegistrationHook {
+	return func(req RegistrationRequest) error {
+		return checker.CheckConnectionQuota(req.Account)
+	}
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func recordingHook(name string, calls *[]string, err error) RegistrationHook {
+	return func(req RegistrationRequest) error {
+		*calls = append(*calls, name)
+		return err
+	}
+}
+
+func TestRegistrationHookChainRunsTheHooksInOrder(t *testing.T) {
+	var calls []string
+
+	chain := NewRegistrationHookChain()
+	chain.Add("first", recordingHook("first", &calls, nil))
+	chain.Add("second", recordingHook("second", &calls, nil))
+	chain.Add("third", recordingHook("third", &calls, nil))
+
+	if hook, err := chain.Run(RegistrationRequest{Account: testAccount, NodeID: testNodeID}); err != nil {
+		t.Fatalf("Expected the connection to be accepted, %s returned %v", hook, err)
+	}
+
+	if diff := cmp.Diff([]string{"first", "second", "third"}, calls); diff != "" {
+		t.Fatalf("Unexpected hook calls (-want +got):\n%s", diff)
+	}
+}
+
+func TestRegistrationHookChainStopsAtTheFirstRejection(t *testing.T) {
+	var calls []string
+	rejection := TooManyConnectionsError{}
+
+	chain := NewRegistrationHookChain()
+	chain.Add("first", recordingHook("first", &calls, nil))
+	chain.Add("second", recordingHook("second", &calls, rejection))
+	chain.Add("third", recordingHook("third", &calls, nil))
+
+	hook, err := chain.Run(RegistrationRequest{Account: testAccount, NodeID: testNodeID})
+	if err != rejection {
+		t.Fatalf("Expected the error of the rejecting hook, got %v", err)
+	}
+
+	if hook != "second" {
+		t.Fatalf("Expected the rejecting hook to be second, got %s", hook)
+	}
+
+	if diff := cmp.Diff([]string{"first", "second"}, calls); diff != "" {
+		t.Fatalf("Unexpected hook calls (-want +got):\n%s", diff)
+	}
+}
+
+func TestRegistrationHookChainReplacesAHookInPlace(t *testing.T) {
+	var calls []string
+
+	chain := NewRegistrationHookChain()
+	chain.Add("first", recordingHook("first", &calls, nil))
+	chain.Add("second", recordingHook("second", &calls, nil))
+	chain.Add("first", recordingHook("replaced", &calls, nil))
+
+	if diff := cmp.Diff([]string{"first", "second"}, chain.Names()); diff != "" {
+		t.Fatalf("Unexpected hook names (-want +got):\n%s", diff)
+	}
+
+	chain.Run(RegistrationRequest{Account: testAccount, NodeID: testNodeID})
+
+	if diff := cmp.Diff([]string{"replaced", "second"}, calls); diff != "" {
+		t.Fatalf("Unexpected hook calls (-want +got):\n%s", diff)
+	}
+}
+
+func TestConnectionPolicyHook(t *testing.T) {
+	hook := NewConnectionPolicyHook(NewDenyListConnectionPolicy(nil, []string{"node-b"}))
+
+	if err := hook(RegistrationRequest{Account: testAccount, NodeID: "node-a"}); err != nil {
+		t.Fatalf("Expected node-a to be allowed, got %v", err)
+	}
+
+	err := hook(RegistrationRequest{Account: testAccount, NodeID: "node-b"})
+	var rejected ConnectionRejectedError
+	if !errors.As(err, &rejected) || rejected.Reason != "node is not allowed to connect" {
+		t.Fatalf("Expected node-b to be rejected by the policy, got %v", err)
+	}
+}
+
+func TestConnectionQuotaHook(t *testing.T) {
+	cm := NewLocalConnectionManagerWithConnectionLimit(ConnectionLimit{Default: 1})
+	hook := NewConnectionQuotaHook(cm)
+
+	if err := hook(RegistrationRequest{Account: testAccount, NodeID: "node-a"}); err != nil {
+		t.Fatalf("Expected the first connection to be allowed, got %v", err)
+	}
+
+	if err := cm.Register(testAccount, "node-a", nil); err != nil {
+		t.Fatalf("Expected the first connection to be registered, got %v", err)
+	}
+
+	if err := hook(RegistrationRequest{Account: testAccount, NodeID: "node-b"}); err != (TooManyConnectionsError{}) {
+		t.Fatalf("Expected a TooManyConnectionsError, got %v", err)
+	}
+
+	if err := hook(RegistrationRequest{Account: "0000002", NodeID: "node-b"}); err != nil {
+		t.Fatalf("Expected the connection of another account to be allowed, got %v", err)
+	}
+}
@@ -2,7 +2,6 @@ package controller
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
 
@@ -28,9 +27,10 @@ func (rth RouteTableHandler) HandleMessage(ctx context.Context, m protocol.Messa
 		return
 	}
 
-	rth.Receptor.UpdateRoutingTable(
-		fmt.Sprintf("%s", routingTableMessage.Edges),
-		fmt.Sprintf("%s", routingTableMessage.Seen))
+	err := rth.Receptor.UpdateRoutingTable(routingTableMessage.Edges, routingTableMessage.Seen)
+	if err != nil {
+		rth.Logger.WithFields(logrus.Fields{"error": err}).Info("Unable to update the routing table")
+	}
 
 	return
 }
@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"sync"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+)
+
+type RoutingTable struct {
+	Edges []protocol.Edge `json:"edges"`
+	Seen  []string        `json:"seen"`
+}
+
+type routingTableStore struct {
+	table RoutingTable
+	sync.RWMutex
+}
+
+func (rts *routingTableStore) update(edges []protocol.Edge, seen []string) {
+	rts.Lock()
+	defer rts.Unlock()
+	rts.table = RoutingTable{Edges: edges, Seen: seen}
+}
+
+func (rts *routingTableStore) get() *RoutingTable {
+	rts.RLock()
+	defer rts.RUnlock()
+
+	// Hand back a copy so that callers cannot modify the stored routing table
+	table := &RoutingTable{
+		Edges: make([]protocol.Edge, len(rts.table.Edges)),
+		Seen:  make([]string, len(rts.table.Seen)),
+	}
+	copy(table.Edges, rts.table.Edges)
+	copy(table.Seen, rts.table.Seen)
+
+	return table
+}
+
+type UnreachableRecipientError struct {
+	Recipient string
+}
+
+func (e UnreachableRecipientError) Error() string {
+	return "no route to recipient " + e.Recipient
+}
+
+// shortestRoute computes the cheapest route from the node to the recipient
+// using the edges of the routing table.  The route lists the nodes that the
+// message passes through after leaving the node, ending with the recipient.
+// The edges are bidirectional.
+func (rt *RoutingTable) shortestRoute(from string, to string) ([]string, bool) {
+	neighbors := make(map[string]map[string]int)
+	addEdge := func(left string, right string, cost int) {
+		if neighbors[left] == nil {
+			neighbors[left] = make(map[string]int)
+		}
+		if existing, exists := neighbors[left][right]; !exists || cost < existing {
+			neighbors[left][right] = cost
+		}
+	}
+	for _, edge := range rt.Edges {
+		addEdge(edge.Left, edge.Right, edge.Cost)
+		addEdge(edge.Right, edge.Left, edge.Cost)
+	}
+
+	costs := map[string]int{from: 0}
+	previous := make(map[string]string)
+	visited := make(map[string]bool)
+
+	for {
+		// Visit the cheapest node that has not been visited yet.  Ties are
+		// broken by node id so that the route is stable.
+		current, found := "", false
+		for node, cost := range costs {
+			if visited[node] {
+				continue
+			}
+			if !found || cost < costs[current] || (cost == costs[current] && node < current) {
+				current, found = node, true
+			}
+		}
+
+		if !found {
+			return nil, false
+		}
+
+		if current == to {
+			break
+		}
+
+		visited[current] = true
+
+		for neighbor, cost := range neighbors[current] {
+			if visited[neighbor] {
+				continue
+			}
+			if existing, exists := costs[neighbor]; !exists || costs[current]+cost < existing {
+				costs[neighbor] = costs[current] + cost
+				previous[neighbor] = current
+			}
+		}
+	}
+
+	route := []string{}
+	for node := to; node != from; node = previous[node] {
+		route = append([]string{node}, route...)
+	}
+
+	return route, true
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"context"
+	"sync"
+)
+
+// sendSequencer hands out turns to the senders of a connection.  A sender
+// does not get its turn before the senders that took a turn before it are
+// done, so the messages are passed to the transport in the order that their
+// senders took a turn.
+type sendSequencer struct {
+	lock sync.Mutex
+
+	// last is closed once the last sender that took a turn is done.  It is
+	// nil until the first turn is taken.
+	last  chan struct{}
+	turns uint64
+}
+
+// takeTurn returns a channel that is closed when the turn has come, and the
+// function that ends the turn
+func (s *sendSequencer) takeTurn() (<-chan struct{}, func()) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	previous := s.last
+	if previous == nil {
+		previous = make(chan struct{})
+		close(previous)
+	}
+
+	current := make(chan struct{})
+	s.last = current
+	s.turns++
+
+	var doneOnce sync.Once
+	done := func() {
+		doneOnce.Do(func() { close(current) })
+	}
+
+	return previous, done
+}
+
+func (s *sendSequencer) getTurns() uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.turns
+}
+
+// waitForSendTurn returns once the messages sent to the connection before
+// this one have been passed to the transport, if the sends are strictly
+// ordered.  The returned function must be called once the message has been
+// passed to the transport (or failed to be).
+func (r *ReceptorService) waitForSendTurn(ctx context.Context) (func(), error) {
+	if !r.config.ReceptorStrictSendOrdering {
+		return func() {}, nil
+	}
+
+	turn, done := r.sendSequencer.takeTurn()
+
+	select {
+	case <-turn:
+		return done, nil
+	case <-ctx.Done():
+		// The turn is only given up once the senders ahead of this one
+		// are done, so that the ones behind it cannot overtake them
+		go func() {
+			<-turn
+			done()
+		}()
+
+		switch ctx.Err() {
+		case context.DeadlineExceeded:
+			return nil, requestTimedOut
+		default:
+			return nil, requestCancelledBySender
+		}
+	}
+}
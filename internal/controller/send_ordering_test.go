@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+)
+
+func newStrictlyOrderedTestReceptorService() (*ReceptorService, *Transport) {
+	cfg := config.GetConfig()
+	cfg.ReceptorStrictSendOrdering = true
+
+	// Nothing is passed to the transport until the test reads from it
+	ctx, cancel := context.WithCancel(context.Background())
+	transport := &Transport{
+		Send:   make(chan ReceptorMessage),
+		Ctx:    ctx,
+		Cancel: cancel,
+	}
+
+	return newTestReceptorService(cfg, transport), transport
+}
+
+// sendInTurn sends the payload in the background, and returns once the send
+// has taken its turn
+func sendInTurn(t *testing.T, ctx context.Context, receptor *ReceptorService, payload int) <-chan error {
+	turns := receptor.sendSequencer.getTurns()
+
+	sent := make(chan error, 1)
+	go func() {
+		_, err := receptor.SendMessage(ctx, testAccount, testNodeID, []string{testNodeID}, payload, "worker:action")
+		sent <- err
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for receptor.sendSequencer.getTurns() == turns {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the send of %d to take its turn", payload)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	return sent
+}
+
+func receivePayload(t *testing.T, transport *Transport) int {
+	select {
+	case msg := <-transport.Send:
+		return msg.Message.(*protocol.PayloadMessage).Data.RawPayload.(int)
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Timed out waiting for a message to be passed to the transport")
+		return -1
+	}
+}
+
+func TestStrictSendOrderingPassesTheMessagesInTheOrderTheyWereSent(t *testing.T) {
+	receptor, transport := newStrictlyOrderedTestReceptorService()
+	defer receptor.Close(context.TODO())
+
+	// The first send blocks on the transport while the others are waiting
+	// for their turn
+	var sent []<-chan error
+	for payload := 0; payload < 20; payload++ {
+		sent = append(sent, sendInTurn(t, context.TODO(), receptor, payload))
+	}
+
+	for expected := 0; expected < 20; expected++ {
+		if payload := receivePayload(t, transport); payload != expected {
+			t.Fatalf("Expected the message %d to be passed to the transport, got %d", expected, payload)
+		}
+
+		if err := <-sent[expected]; err != nil {
+			t.Fatalf("Expected the error to be nil, got %v", err)
+		}
+	}
+}
+
+func TestStrictSendOrderingIsKeptWhenASenderGivesUp(t *testing.T) {
+	receptor, transport := newStrictlyOrderedTestReceptorService()
+	defer receptor.Close(context.TODO())
+
+	first := sendInTurn(t, context.TODO(), receptor, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := sendInTurn(t, ctx, receptor, 2)
+
+	last := sendInTurn(t, context.TODO(), receptor, 3)
+
+	cancel()
+	if err := <-cancelled; err != requestCancelledBySender {
+		t.Fatalf("Expected the cancelled send to fail with %v, got %v", requestCancelledBySender, err)
+	}
+
+	// The send behind the cancelled one must not overtake the first one
+	if payload := receivePayload(t, transport); payload != 1 {
+		t.Fatalf("Expected the first message to be passed to the transport first, got %d", payload)
+	}
+
+	if payload := receivePayload(t, transport); payload != 3 {
+		t.Fatalf("Expected the last message to be passed to the transport, got %d", payload)
+	}
+
+	if err := <-first; err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+	if err := <-last; err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+}
+
+func TestSendsAreNotOrderedByDefault(t *testing.T) {
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorService(config.GetConfig(), transport)
+	defer receptor.Close(context.TODO())
+
+	if _, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, 1, "worker:action"); err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	if turns := receptor.sendSequencer.getTurns(); turns != 0 {
+		t.Fatalf("Expected the send not to take a turn, got %d turns", turns)
+	}
+}
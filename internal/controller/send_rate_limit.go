@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrRateLimited is returned when messages are sent to the node faster than
+// its send rate limit allows
+var ErrRateLimited = errors.New("messages are being sent to the node faster than its send rate limit")
+
+// sendRateLimiter is a token bucket that limits the rate at which messages are
+// sent to a node.  The bucket holds up to burst tokens and is refilled at rate
+// tokens per second.
+type sendRateLimiter struct {
+	lock   sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newSendRateLimiter returns nil (no limit) if rate is not greater than 0.  A
+// burst lower than 1 is raised to 1.
+func newSendRateLimiter(rate int, burst int) *sendRateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &sendRateLimiter{
+		rate:   float64(rate),
+		burst:  float64(burst),
+		tokens: float64(burst),
+	}
+}
+
+// reserve takes a token from the bucket.  If the bucket is empty, the next
+// token is reserved as long as it becomes available within maxWait, and the
+// time until it is available is returned.  Otherwise it returns false without
+// taking a token.
+func (l *sendRateLimiter) reserve(now time.Time, maxWait time.Duration) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if !l.last.IsZero() {
+		elapsed := now.Sub(l.last).Seconds()
+		l.tokens = math.Min(l.burst, l.tokens+elapsed*l.rate)
+	}
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+	if wait > maxWait {
+		return false, wait
+	}
+
+	// The tokens go negative so that the next senders wait their turn
+	l.tokens--
+	return true, wait
+}
+
+// sendRateLimit returns the number of messages per second that can be sent to
+// the node.  Zero means there is no limit.
+func (r *ReceptorService) sendRateLimit() int {
+	if limit, exists := r.config.ReceptorSendRateLimitOverride[r.AccountNumber]; exists {
+		return limit
+	}
+	return r.config.ReceptorSendRateLimit
+}
+
+// waitForSendRateLimit returns once the message can be sent without exceeding
+// the send rate limit of the node.  Depending on the configured max wait, a
+// message sent over the limit waits for its turn or is rejected with
+// ErrRateLimited.
+func (r *ReceptorService) waitForSendRateLimit(ctx context.Context) error {
+	r.sendRateLimiterOnce.Do(func() {
+		r.sendRateLimiter = newSendRateLimiter(r.sendRateLimit(), r.config.ReceptorSendRateLimitBurst)
+	})
+
+	allowed, wait := r.sendRateLimiter.reserve(time.Now(), r.config.ReceptorSendRateLimitMaxWait)
+	if !allowed {
+		r.logger.WithFields(logrus.Fields{"retry_after": wait}).Warn("Send rate limit exceeded...rejecting message")
+		metrics.rateLimitedMessageCounter.Inc()
+		return ErrRateLimited
+	}
+
+	if wait == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		switch ctx.Err() {
+		case context.DeadlineExceeded:
+			return requestTimedOut
+		default:
+			return requestCancelledBySender
+		}
+	}
+}
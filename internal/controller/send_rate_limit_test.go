@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func floodReceptorService(receptor *ReceptorService, count int) (int, int, error) {
+	accepted, rejected := 0, 0
+	for i := 0; i < count; i++ {
+		_, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action")
+		switch err {
+		case nil:
+			accepted++
+		case ErrRateLimited:
+			rejected++
+		default:
+			return accepted, rejected, err
+		}
+	}
+	return accepted, rejected, nil
+}
+
+func TestReceptorServiceSendMessageRejectsMessagesOverTheSendRateLimit(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.ReceptorSendRateLimit = 1
+	cfg.ReceptorSendRateLimitBurst = 5
+	cfg.ReceptorSendRateLimitMaxWait = 0
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorService(cfg, transport)
+	defer receptor.Close(context.TODO())
+
+	rateLimited := testutil.ToFloat64(metrics.rateLimitedMessageCounter)
+
+	accepted, rejected, err := floodReceptorService(receptor, 20)
+	if err != nil {
+		t.Fatalf("Expected the error to be nil or %v, got %v", ErrRateLimited, err)
+	}
+
+	if accepted != 5 || rejected != 15 {
+		t.Fatalf("Expected the burst of 5 messages to be accepted and 15 to be rejected, got %d and %d", accepted, rejected)
+	}
+
+	if len(transport.Send) != 5 {
+		t.Fatalf("Expected the 5 accepted messages to be queued, got %d", len(transport.Send))
+	}
+
+	if testutil.ToFloat64(metrics.rateLimitedMessageCounter) != rateLimited+15 {
+		t.Fatalf("Expected the rate limited counter to be incremented")
+	}
+}
+
+func TestReceptorServiceSendMessageWaitsForTheSendRateLimit(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.ReceptorSendRateLimit = 50
+	cfg.ReceptorSendRateLimitBurst = 2
+	cfg.ReceptorSendRateLimitMaxWait = time.Second
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorService(cfg, transport)
+	defer receptor.Close(context.TODO())
+
+	start := time.Now()
+	accepted, rejected, err := floodReceptorService(receptor, 8)
+	elapsed := time.Since(start)
+
+	if err != nil || accepted != 8 || rejected != 0 {
+		t.Fatalf("Expected the 8 messages to be accepted, got %d accepted, %d rejected (error: %v)", accepted, rejected, err)
+	}
+
+	// The 6 messages past the burst are throttled to 50 per second
+	if elapsed < 100*time.Millisecond {
+		t.Fatalf("Expected the messages past the burst to be throttled, but they were sent in %s", elapsed)
+	}
+}
+
+func TestReceptorServiceSendMessageRejectsMessagesThatWouldWaitTooLong(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.ReceptorSendRateLimit = 10
+	cfg.ReceptorSendRateLimitBurst = 1
+	cfg.ReceptorSendRateLimitMaxWait = 50 * time.Millisecond
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorService(cfg, transport)
+	defer receptor.Close(context.TODO())
+
+	// The next token is 100ms away once the burst is used up
+	accepted, rejected, err := floodReceptorService(receptor, 2)
+	if err != nil || accepted != 1 || rejected != 1 {
+		t.Fatalf("Expected 1 message to be accepted and 1 to be rejected, got %d and %d (error: %v)", accepted, rejected, err)
+	}
+}
+
+func TestReceptorServiceSendRateLimitOverride(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.ReceptorSendRateLimit = 0
+	cfg.ReceptorSendRateLimitOverride = map[string]int{testAccount: 1}
+	cfg.ReceptorSendRateLimitBurst = 1
+	cfg.ReceptorSendRateLimitMaxWait = 0
+	transport := newBufferedTestTransport()
+	receptor := newTestReceptorService(cfg, transport)
+	defer receptor.Close(context.TODO())
+
+	accepted, rejected, err := floodReceptorService(receptor, 3)
+	if err != nil || accepted != 1 || rejected != 2 {
+		t.Fatalf("Expected 1 message to be accepted and 2 to be rejected, got %d and %d (error: %v)", accepted, rejected, err)
+	}
+}
+
+func TestSendRateLimiterRefillsTheBucket(t *testing.T) {
+	limiter := newSendRateLimiter(10, 1)
+	start := time.Now()
+
+	if allowed, _ := limiter.reserve(start, 0); !allowed {
+		t.Fatalf("Expected the first message to be allowed")
+	}
+
+	if allowed, wait := limiter.reserve(start, 0); allowed || wait != 100*time.Millisecond {
+		t.Fatalf("Expected the second message to be rejected with a wait of 100ms, got %v and %s", allowed, wait)
+	}
+
+	if allowed, _ := limiter.reserve(start.Add(100*time.Millisecond), 0); !allowed {
+		t.Fatalf("Expected a message to be allowed once the bucket has been refilled")
+	}
+
+	if newSendRateLimiter(0, 1) != nil {
+		t.Fatalf("Expected no limiter without a rate")
+	}
+}
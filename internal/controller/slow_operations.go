@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The operations whose latency is checked against the slow operation
+// thresholds.  The names are the keys of Slow_Operation_Threshold_Overrides.
+const (
+	SLOW_OPERATION_PING         = "ping"
+	SLOW_OPERATION_CAPABILITIES = "capabilities"
+	SLOW_OPERATION_SEND         = "send"
+)
+
+// slowOperationThreshold returns the latency above which the operation is
+// logged as slow.  Zero means the operation is never logged as slow.
+func (r *ReceptorService) slowOperationThreshold(operation string) time.Duration {
+	if threshold, exists := r.config.SlowOperationThresholdOverride[operation]; exists {
+		return time.Duration(threshold) * time.Millisecond
+	}
+	return r.config.SlowOperationThreshold
+}
+
+// timeOperation runs the operation and logs a slow_operation warning if it
+// took longer than the threshold of the operation.  The warning is logged
+// whether or not the operation failed; a timed out operation is usually the
+// slowest of all.
+func (r *ReceptorService) timeOperation(operation string, run func() error) error {
+	start := time.Now()
+	err := run()
+	r.checkSlowOperation(operation, time.Since(start), err)
+	return err
+}
+
+func (r *ReceptorService) checkSlowOperation(operation string, duration time.Duration, err error) {
+	threshold := r.slowOperationThreshold(operation)
+	if threshold <= 0 || duration <= threshold {
+		return
+	}
+
+	fields := logrus.Fields{
+		"slow_operation": true,
+		"operation":      operation,
+		"duration_ms":    duration.Milliseconds(),
+		"threshold_ms":   threshold.Milliseconds(),
+		"account":        r.AccountNumber,
+		"node_id":        r.PeerNodeID,
+	}
+	if err != nil {
+		fields["error"] = err
+	}
+
+	r.logger.WithFields(fields).Warnf("Slow %s operation", operation)
+}
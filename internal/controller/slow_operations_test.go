@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func newSlowOperationTestReceptorService(cfg *config.Config, transport *Transport) (*ReceptorService, *test.Hook) {
+	receptor := newTestReceptorService(cfg, transport)
+
+	log, hook := test.NewNullLogger()
+	receptor.logger = log.WithFields(logrus.Fields{})
+
+	return receptor, hook
+}
+
+func slowOperationEntries(hook *test.Hook) []*logrus.Entry {
+	var entries []*logrus.Entry
+	for _, entry := range hook.AllEntries() {
+		if entry.Data["slow_operation"] == true {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func TestReceptorServiceLogsSlowOperations(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.SlowOperationThreshold = 20 * time.Millisecond
+	receptor, hook := newSlowOperationTestReceptorService(cfg, newTestTransport(false))
+	defer receptor.Close(context.TODO())
+
+	operationErr := errors.New("operation failed")
+	err := receptor.timeOperation("mock", func() error {
+		time.Sleep(50 * time.Millisecond)
+		return operationErr
+	})
+	if err != operationErr {
+		t.Fatalf("Expected the error of the operation to be returned, got %v", err)
+	}
+
+	entries := slowOperationEntries(hook)
+	if len(entries) != 1 {
+		t.Fatalf("Expected a slow_operation warning, got %d", len(entries))
+	}
+
+	entry := entries[0]
+	if entry.Level != logrus.WarnLevel {
+		t.Fatalf("Expected a warning, got %s", entry.Level)
+	}
+
+	expected := map[string]interface{}{
+		"operation":    "mock",
+		"threshold_ms": int64(20),
+		"account":      testAccount,
+		"node_id":      testNodeID,
+		"error":        operationErr,
+	}
+	for key, value := range expected {
+		if entry.Data[key] != value {
+			t.Fatalf("Expected %s to be %v, got %v", key, value, entry.Data[key])
+		}
+	}
+
+	if duration, _ := entry.Data["duration_ms"].(int64); duration < 50 {
+		t.Fatalf("Expected the duration to be at least 50ms, got %v", entry.Data["duration_ms"])
+	}
+}
+
+func TestReceptorServiceDoesNotLogFastOperations(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.SlowOperationThreshold = 500 * time.Millisecond
+	receptor, hook := newSlowOperationTestReceptorService(cfg, newTestTransport(false))
+	defer receptor.Close(context.TODO())
+
+	receptor.timeOperation("mock", func() error { return nil })
+
+	if entries := slowOperationEntries(hook); len(entries) != 0 {
+		t.Fatalf("Expected no slow_operation warning, got %d", len(entries))
+	}
+}
+
+func TestReceptorServiceSlowOperationThresholdOverrides(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.SlowOperationThreshold = 0
+	cfg.SlowOperationThresholdOverride = map[string]int{SLOW_OPERATION_PING: 20}
+	transport := newTestTransport(false)
+	receptor, hook := newSlowOperationTestReceptorService(cfg, transport)
+	defer receptor.Close(context.TODO())
+
+	go answerPingAfter(receptor, transport, 50*time.Millisecond)
+
+	if _, err := receptor.Ping(context.TODO(), testAccount, testNodeID, []string{testNodeID}); err != nil {
+		t.Fatalf("Expected the ping to succeed, got %v", err)
+	}
+
+	// Only the ping has a threshold
+	receptor.timeOperation(SLOW_OPERATION_SEND, func() error {
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	})
+
+	entries := slowOperationEntries(hook)
+	if len(entries) != 1 || entries[0].Data["operation"] != SLOW_OPERATION_PING {
+		t.Fatalf("Expected a single slow_operation warning for the ping, got %+v", entries)
+	}
+}
@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+	kafka "github.com/segmentio/kafka-go"
+)
+
+const (
+	TRACEPARENT_HEADER = "traceparent"
+	TRACESTATE_HEADER  = "tracestate"
+)
+
+var errInvalidTraceParent = errors.New("invalid traceparent")
+
+// TraceContext identifies the span a message was sent from.  The fields hold
+// the traceparent and tracestate values defined by the W3C trace context
+// specification.  The nodes are expected to copy them from the message into
+// their responses.
+type TraceContext struct {
+	TraceParent string
+	TraceState  string
+}
+
+// ParseTraceContext verifies the format of a traceparent value.  The
+// tracestate value is vendor specific, so it is passed along as is.
+func ParseTraceContext(traceParent string, traceState string) (TraceContext, error) {
+	fields := strings.Split(traceParent, "-")
+	if len(fields) < 4 {
+		return TraceContext{}, errInvalidTraceParent
+	}
+
+	version, traceID, spanID, flags := fields[0], fields[1], fields[2], fields[3]
+
+	// Future versions may append fields, version 00 has exactly four
+	if version == "ff" || (version == "00" && len(fields) != 4) {
+		return TraceContext{}, errInvalidTraceParent
+	}
+
+	if !isLowerHex(version, 2) || !isLowerHex(traceID, 32) || !isLowerHex(spanID, 16) || !isLowerHex(flags, 2) {
+		return TraceContext{}, errInvalidTraceParent
+	}
+
+	if strings.Trim(traceID, "0") == "" || strings.Trim(spanID, "0") == "" {
+		return TraceContext{}, errInvalidTraceParent
+	}
+
+	return TraceContext{TraceParent: traceParent, TraceState: traceState}, nil
+}
+
+func isLowerHex(s string, length int) bool {
+	if len(s) != length || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+func (tc TraceContext) isEmpty() bool {
+	return tc.TraceParent == ""
+}
+
+// TraceID returns the id of the trace, taken from the traceparent value
+func (tc TraceContext) TraceID() string {
+	fields := strings.Split(tc.TraceParent, "-")
+	if len(fields) < 2 {
+		return ""
+	}
+	return fields[1]
+}
+
+type traceContextKeyType int
+
+var traceContextKey traceContextKeyType
+
+// WithTraceContext returns a copy of ctx that carries the trace context of
+// the span that messages sent using the context belong to
+func WithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey, tc)
+}
+
+// GetTraceContext returns the trace context stored in ctx, if any
+func GetTraceContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceContextKey).(TraceContext)
+	return tc, ok
+}
+
+// injectTraceContext adds the trace context to the envelope of a payload
+// message
+func injectTraceContext(msg protocol.Message, tc TraceContext) {
+	payloadMessage, ok := msg.(*protocol.PayloadMessage)
+	if !ok || tc.isEmpty() {
+		return
+	}
+
+	payloadMessage.Data.TraceParent = tc.TraceParent
+	payloadMessage.Data.TraceState = tc.TraceState
+}
+
+// extractTraceContext returns the trace context that the node echoed back in
+// a response.  A malformed trace context is ignored.
+func extractTraceContext(payloadMessage *protocol.PayloadMessage) TraceContext {
+	tc, err := ParseTraceContext(payloadMessage.Data.TraceParent, payloadMessage.Data.TraceState)
+	if err != nil {
+		return TraceContext{}
+	}
+	return tc
+}
+
+// kafkaHeaders returns the headers that carry the trace context in a kafka
+// message
+func (tc TraceContext) kafkaHeaders() []kafka.Header {
+	if tc.isEmpty() {
+		return nil
+	}
+
+	headers := []kafka.Header{{Key: TRACEPARENT_HEADER, Value: []byte(tc.TraceParent)}}
+	if tc.TraceState != "" {
+		headers = append(headers, kafka.Header{Key: TRACESTATE_HEADER, Value: []byte(tc.TraceState)})
+	}
+
+	return headers
+}
+
+// TraceContextFromKafkaHeaders returns the trace context carried in the
+// headers of a kafka message
+func TraceContextFromKafkaHeaders(headers []kafka.Header) (TraceContext, error) {
+	var traceParent, traceState string
+	for _, header := range headers {
+		switch header.Key {
+		case TRACEPARENT_HEADER:
+			traceParent = string(header.Value)
+		case TRACESTATE_HEADER:
+			traceState = string(header.Value)
+		}
+	}
+
+	return ParseTraceContext(traceParent, traceState)
+}
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+
+	"github.com/google/uuid"
+)
+
+const testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParseTraceContext(t *testing.T) {
+	tests := []struct {
+		traceParent string
+		valid       bool
+	}{
+		{testTraceParent, true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", true},
+		{"", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902zz-01", false},
+	}
+
+	for _, tc := range tests {
+		_, err := ParseTraceContext(tc.traceParent, "")
+		if tc.valid && err != nil {
+			t.Errorf("Expected %q to be valid, got %v", tc.traceParent, err)
+		} else if !tc.valid && err == nil {
+			t.Errorf("Expected %q to be invalid", tc.traceParent)
+		}
+	}
+}
+
+func TestTraceContextKafkaHeaders(t *testing.T) {
+	expected := TraceContext{TraceParent: testTraceParent, TraceState: "vendor=value"}
+
+	actual, err := TraceContextFromKafkaHeaders(expected.kafkaHeaders())
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	if actual != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, actual)
+	}
+
+	if headers := (TraceContext{}).kafkaHeaders(); headers != nil {
+		t.Fatalf("Expected no headers for an empty trace context, got %+v", headers)
+	}
+}
+
+func TestTraceContextTraceID(t *testing.T) {
+	tc := TraceContext{TraceParent: testTraceParent}
+	if tc.TraceID() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("Expected the trace id to be extracted from the traceparent, got %q", tc.TraceID())
+	}
+
+	if traceID := (TraceContext{}).TraceID(); traceID != "" {
+		t.Fatalf("Expected no trace id for an empty trace context, got %q", traceID)
+	}
+}
+
+// The mock node copies the trace context of the message it receives into its
+// response
+func TestTraceContextSurvivesRoundTripThroughNode(t *testing.T) {
+	transport, sent := newCapturingTransport()
+	defer transport.Cancel()
+
+	receptor := newTestReceptorServiceWithOutbox(config.GetConfig(), transport, nil)
+
+	expected := TraceContext{TraceParent: testTraceParent, TraceState: "vendor=value"}
+
+	messageID, err := receptor.SendMessage(WithTraceContext(context.Background(), expected),
+		testAccount, testNodeID, []string{testNodeID}, "payload", "fred:flintstone")
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	request := waitForSentPayloadMessage(t, sent)
+
+	responses := make(chan ResponseMessage, 1)
+	receptor.responseDispatcherRegistrar.Register(*messageID, responses)
+	defer receptor.responseDispatcherRegistrar.Unregister(*messageID)
+
+	receptor.DispatchResponse(&protocol.PayloadMessage{
+		RoutingInfo: &protocol.RoutingMessage{Sender: testNodeID, Recipient: receptor.NodeID},
+		Data: protocol.InnerEnvelope{
+			MessageID:    uuid.New().String(),
+			InResponseTo: request.Data.MessageID,
+			RawPayload:   "response",
+			TraceParent:  request.Data.TraceParent,
+			TraceState:   request.Data.TraceState,
+		},
+	})
+
+	select {
+	case response := <-responses:
+		if response.TraceContext != expected {
+			t.Fatalf("Expected the trace context %+v, got %+v", expected, response.TraceContext)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the response to be dispatched")
+	}
+}
@@ -2,13 +2,46 @@ package controller
 
 import (
 	"context"
+	"time"
 
 	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+
+	"github.com/google/uuid"
 )
 
 type ReceptorMessage struct {
 	AccountNumber string
 	Message       protocol.Message
+
+	// ExpiresAt is the time after which the message should no longer be
+	// delivered.  The zero value means the message never expires.
+	ExpiresAt time.Time
+
+	// OnSent is called by the transport layer once the message has been
+	// written to the connection.  It can be nil.
+	OnSent func()
+
+	// OnFailed is called if the message could not be written, or if the
+	// connection is closed before the message has been written.  It can be nil.
+	OnFailed func(error)
+
+	// OnExpired is called by the transport layer if the message is dropped
+	// because it expired before it could be written.  It can be nil.
+	OnExpired func()
+}
+
+func (rm ReceptorMessage) IsExpired(now time.Time) bool {
+	return !rm.ExpiresAt.IsZero() && now.After(rm.ExpiresAt)
+}
+
+// DroppedMessage records why a message from the node was not passed on
+type DroppedMessage struct {
+	Reason    error
+	DroppedAt time.Time
+
+	// InResponseTo is the id of the request that the dropped message
+	// responds to.  It is uuid.Nil if the request is not known.
+	InResponseTo uuid.UUID
 }
 
 type ReceptorErrorMessage struct {
@@ -34,6 +67,46 @@ type Transport struct {
 	// to the go routine managing write side of the websocket
 	ErrorChannel chan ReceptorErrorMessage
 
+	// Dropped holds the most recent message from the node that was dropped
+	// by the transport layer (e.g. because it was too large).  A sender
+	// waiting for a sync response fails if the response to its request was
+	// dropped, or if a message that responds to an unknown request was
+	// dropped after its request was sent.  It can be nil.
+	Dropped chan DroppedMessage
+
+	// Keepalive tracks the last time the receptor node was heard from
+	Keepalive *KeepaliveTracker
+
+	// Activity tracks the last time a message was exchanged with the
+	// receptor node.  It can be nil.
+	Activity *ActivityTracker
+
 	Ctx    context.Context
 	Cancel context.CancelFunc
+
+	// Closed is closed by the transport layer once the goroutines
+	// managing the read and write sides of the websocket have exited
+	Closed chan struct{}
+
+	// ForceClose tears down the underlying connection without waiting
+	// for the read and write goroutines to exit on their own
+	ForceClose func()
+
+	// SetCloseReason records why the connection is being closed so that
+	// the transport layer can tell the node.  It must be called before
+	// Cancel and can be nil.
+	SetCloseReason func(reason CloseReason)
+
+	// SetReconnectHint records the pod the node should reconnect to so that
+	// the transport layer can tell the node when the connection is closed.
+	// It must be called before Cancel and can be nil.
+	SetReconnectHint func(pod string)
+
+	// SetSendRate limits the number of messages per second that are written
+	// to the node.  A rate of 0 removes the limit.  It can be nil.
+	SetSendRate func(messagesPerSecond int)
+
+	// DisconnectReason reports why the connection went away.  It is only
+	// meaningful once Ctx is done and can be nil.
+	DisconnectReason func() DisconnectReason
 }
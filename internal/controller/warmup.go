@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"context"
+	"time"
+)
+
+// WarmupReporter is implemented by receptors that hold the messages sent
+// right after the connection was registered, while the connection is
+// getting ready
+type WarmupReporter interface {
+	IsWarmingUp() bool
+}
+
+// startWarmup holds the messages sent during the configured grace period
+// after the connection has been registered, so that they are not passed to
+// the transport before the connection is ready.  The held messages are
+// flushed (unless the delivery has been paused) once the grace period is over.
+func (r *ReceptorService) startWarmup() {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+
+	r.warmedUp = make(chan struct{})
+
+	if r.config == nil || r.config.ReceptorWarmupGracePeriod <= 0 {
+		close(r.warmedUp)
+		return
+	}
+
+	r.logger.Debug("Warming up the connection for ", r.config.ReceptorWarmupGracePeriod)
+	r.warmingUp = true
+
+	warmedUp := r.warmedUp
+	time.AfterFunc(r.config.ReceptorWarmupGracePeriod, func() {
+		r.logger.Debug("Connection warmed up")
+		r.resume(context.Background(), &r.warmingUp)
+		close(warmedUp)
+	})
+}
+
+// IsWarmingUp reports whether the grace period after the registration of the
+// connection is still running
+func (r *ReceptorService) IsWarmingUp() bool {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+	return r.warmingUp
+}
+
+// waitForWarmup waits for the grace period to be over.  It is used by the
+// sync directives (pings), which are not held.
+func (r *ReceptorService) waitForWarmup(ctx context.Context) error {
+	r.pauseLock.Lock()
+	warmedUp := r.warmedUp
+	r.pauseLock.Unlock()
+
+	if warmedUp == nil {
+		return nil
+	}
+
+	select {
+	case <-warmedUp:
+		return nil
+	case <-r.Transport.Ctx.Done():
+		r.logger.Info("Connection to receptor network lost")
+		return ErrConnectionClosed
+	case <-ctx.Done():
+		switch ctx.Err().(error) {
+		case context.DeadlineExceeded:
+			r.logger.Info("Timed out waiting for the connection to warm up")
+			return requestTimedOut
+		default:
+			r.logger.Info("Message cancelled by sender")
+			return requestCancelledBySender
+		}
+	}
+}
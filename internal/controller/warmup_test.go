@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+)
+
+func TestReceptorServiceHoldsMessagesWhileWarmingUp(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.ReceptorWarmupGracePeriod = 100 * time.Millisecond
+	outbox := NewInMemoryOutboxStore()
+	transport := newBufferedTestTransport()
+	receptor := newWarmingUpTestReceptorService(cfg, transport, outbox)
+	defer receptor.Close(context.TODO())
+
+	if receptor.IsWarmingUp() == false {
+		t.Fatalf("Expected the connection to be warming up")
+	}
+
+	messageID, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action")
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	if len(transport.Send) != 0 {
+		t.Fatalf("Expected the message to be held while the connection is warming up")
+	}
+
+	select {
+	case msg := <-transport.Send:
+		payloadMessage := msg.Message.(*protocol.PayloadMessage)
+		if payloadMessage.Data.MessageID != messageID.String() {
+			t.Fatalf("Expected message %s to be flushed, got %s", messageID, payloadMessage.Data.MessageID)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the held message to be flushed once the connection warmed up")
+	}
+
+	if receptor.IsWarmingUp() {
+		t.Fatalf("Expected the connection to have warmed up")
+	}
+}
+
+func TestReceptorServiceKeepsMessagesHeldAfterWarmupWhilePaused(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.ReceptorWarmupGracePeriod = 50 * time.Millisecond
+	outbox := NewInMemoryOutboxStore()
+	transport := newBufferedTestTransport()
+	receptor := newWarmingUpTestReceptorService(cfg, transport, outbox)
+	defer receptor.Close(context.TODO())
+
+	receptor.Pause(context.TODO())
+
+	_, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action")
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	waitForWarmup(t, receptor)
+
+	if len(transport.Send) != 0 {
+		t.Fatalf("Expected the message to remain held while the delivery is paused")
+	}
+
+	receptor.Resume(context.TODO())
+
+	if len(transport.Send) != 1 {
+		t.Fatalf("Expected the held message to be passed to the transport, got %d", len(transport.Send))
+	}
+}
+
+func TestReceptorServicePingWaitsForWarmup(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.ReceptorWarmupGracePeriod = time.Second
+	receptor := newWarmingUpTestReceptorService(cfg, newBufferedTestTransport(), NewInMemoryOutboxStore())
+	defer receptor.Close(context.TODO())
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := receptor.Ping(ctx, testAccount, testNodeID, []string{testNodeID})
+	if err != requestTimedOut {
+		t.Fatalf("Expected %v, got %v", requestTimedOut, err)
+	}
+}
+
+func TestReceptorServiceWarmupCanBeDisabled(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.ReceptorWarmupGracePeriod = 0
+	transport := newBufferedTestTransport()
+	receptor := newWarmingUpTestReceptorService(cfg, transport, NewInMemoryOutboxStore())
+	defer receptor.Close(context.TODO())
+
+	if receptor.IsWarmingUp() {
+		t.Fatalf("Expected the connection not to be warming up")
+	}
+
+	_, err := receptor.SendMessage(context.TODO(), testAccount, testNodeID, []string{testNodeID}, "payload", "worker:action")
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	if len(transport.Send) != 1 {
+		t.Fatalf("Expected the message to be passed to the transport right away")
+	}
+}
+
+func waitForWarmup(t *testing.T, receptor *ReceptorService) {
+	deadline := time.Now().Add(time.Second)
+	for receptor.IsWarmingUp() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the connection to warm up")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
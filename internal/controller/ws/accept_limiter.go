@@ -0,0 +1,66 @@
+package ws
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// acceptLimiter is a token bucket that limits the rate at which websocket
+// connections are accepted.  The bucket holds up to burst tokens and is
+// refilled at rate tokens per second.
+type acceptLimiter struct {
+	lock   sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newAcceptLimiter returns nil (no limit) if rate is not greater than 0.  A
+// burst lower than 1 is raised to 1.
+func newAcceptLimiter(rate int, burst int) *acceptLimiter {
+	if rate <= 0 {
+		return nil
+	}
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &acceptLimiter{
+		rate:   float64(rate),
+		burst:  float64(burst),
+		tokens: float64(burst),
+	}
+}
+
+// allow takes a token from the bucket.  If the bucket is empty, it returns
+// false along with the time until the next token is available.
+func (l *acceptLimiter) allow(now time.Time) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if !l.last.IsZero() {
+		elapsed := now.Sub(l.last).Seconds()
+		l.tokens = math.Min(l.burst, l.tokens+elapsed*l.rate)
+	}
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return true, 0
+	}
+
+	retryAfter := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+	return false, retryAfter
+}
+
+// retryAfterSeconds rounds the retry delay up to whole seconds
+func retryAfterSeconds(retryAfter time.Duration) int {
+	return int(math.Ceil(retryAfter.Seconds()))
+}
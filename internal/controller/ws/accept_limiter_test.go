@@ -0,0 +1,44 @@
+package ws
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Accept limiter", func() {
+	It("Should allow a burst and then refill at the configured rate", func() {
+		limiter := newAcceptLimiter(2, 3)
+		now := time.Now()
+
+		for i := 0; i < 3; i++ {
+			allowed, _ := limiter.allow(now)
+			Expect(allowed).To(BeTrue())
+		}
+
+		allowed, retryAfter := limiter.allow(now)
+		Expect(allowed).To(BeFalse())
+		Expect(retryAfter).To(Equal(500 * time.Millisecond))
+		Expect(retryAfterSeconds(retryAfter)).To(Equal(1))
+
+		allowed, _ = limiter.allow(now.Add(500 * time.Millisecond))
+		Expect(allowed).To(BeTrue())
+
+		// The bucket does not fill past the burst
+		for i := 0; i < 3; i++ {
+			allowed, _ = limiter.allow(now.Add(time.Hour))
+			Expect(allowed).To(BeTrue())
+		}
+		allowed, _ = limiter.allow(now.Add(time.Hour))
+		Expect(allowed).To(BeFalse())
+	})
+
+	It("Should not limit the connections when the rate is 0", func() {
+		limiter := newAcceptLimiter(0, 0)
+		Expect(limiter).To(BeNil())
+
+		allowed, _ := limiter.allow(time.Now())
+		Expect(allowed).To(BeTrue())
+	})
+})
@@ -3,11 +3,14 @@ package ws
 import (
 	"context"
 	"errors"
+	"net"
+	"sync"
 	"time"
 
 	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
 	"github.com/RedHatInsights/platform-receptor-controller/internal/controller"
 	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"github.com/sirupsen/logrus"
 )
@@ -28,11 +31,114 @@ type rcClient struct {
 	// recv is a channel on which responses are sent.
 	recv chan protocol.Message
 
+	// droppedChannel holds the most recent message that was dropped
+	droppedChannel chan controller.DroppedMessage
+
 	cancel context.CancelFunc
 
+	keepalive *controller.KeepaliveTracker
+
+	activity *controller.ActivityTracker
+
 	logger *logrus.Entry
 
 	config *config.Config
+
+	closeReasonLock sync.Mutex
+	closeReason     controller.CloseReason
+	reconnectTo     string
+	readTimedOut    bool
+
+	sendRateLock sync.Mutex
+	sendInterval time.Duration
+}
+
+// setSendRate limits the number of messages per second that are written from
+// the send channel.  Control messages are not limited.
+func (c *rcClient) setSendRate(messagesPerSecond int) {
+	c.sendRateLock.Lock()
+	defer c.sendRateLock.Unlock()
+
+	if messagesPerSecond > 0 {
+		c.sendInterval = time.Second / time.Duration(messagesPerSecond)
+	} else {
+		c.sendInterval = 0
+	}
+}
+
+func (c *rcClient) getSendInterval() time.Duration {
+	c.sendRateLock.Lock()
+	defer c.sendRateLock.Unlock()
+	return c.sendInterval
+}
+
+func (c *rcClient) setCloseReason(reason controller.CloseReason) {
+	c.closeReasonLock.Lock()
+	defer c.closeReasonLock.Unlock()
+	c.closeReason = reason
+}
+
+func (c *rcClient) setReconnectHint(pod string) {
+	c.closeReasonLock.Lock()
+	defer c.closeReasonLock.Unlock()
+	c.reconnectTo = pod
+}
+
+// recordReadError remembers whether the read side of the connection gave up
+// because the node stopped responding
+func (c *rcClient) recordReadError(err error) {
+	netErr, ok := err.(net.Error)
+
+	c.closeReasonLock.Lock()
+	defer c.closeReasonLock.Unlock()
+	c.readTimedOut = ok && netErr.Timeout()
+}
+
+// getDisconnectReason reports why the connection went away.  A connection
+// closed by the controller is reported with the reason it was closed for.
+// Otherwise, the node either stopped responding or the connection was lost.
+func (c *rcClient) getDisconnectReason() controller.DisconnectReason {
+	c.closeReasonLock.Lock()
+	defer c.closeReasonLock.Unlock()
+
+	if c.closeReason != "" {
+		return controller.DisconnectReasonForCloseReason(c.closeReason)
+	}
+
+	if c.readTimedOut {
+		return controller.DISCONNECT_REASON_IDLE_TIMEOUT
+	}
+
+	return controller.DISCONNECT_REASON_NETWORK
+}
+
+// writeCloseMessage tells the node why the controller closed the connection.
+// Nothing is written if the connection is being torn down for some other
+// reason (e.g. the node went away).
+func (c *rcClient) writeCloseMessage() {
+	reason, text := c.closeMessage()
+	if reason == "" {
+		return
+	}
+
+	c.socket.SetWriteDeadline(time.Now().Add(c.config.WriteWait))
+	c.socket.WriteMessage(websocket.CloseMessage,
+		websocket.FormatCloseMessage(closeCode(reason), text))
+}
+
+// closeMessage returns the reason the controller closed the connection for and
+// the text of the close message: the reason, followed by the pod the node
+// should reconnect to, if any (e.g. "rebalance reconnect_to=gateway-1").
+func (c *rcClient) closeMessage() (controller.CloseReason, string) {
+	c.closeReasonLock.Lock()
+	defer c.closeReasonLock.Unlock()
+
+	text := string(c.closeReason)
+	if c.reconnectTo != "" {
+		text += " reconnect_to=" + c.reconnectTo
+	}
+
+	return c.closeReason, text
 }
 
 func (c *rcClient) read(ctx context.Context) {
@@ -47,11 +153,21 @@ func (c *rcClient) read(ctx context.Context) {
 
 		if err != nil {
 			c.logger.WithFields(logrus.Fields{"error": err}).Error("Error while getting a reader from the websocket")
+			c.recordReadError(err)
 			return
 		}
 
-		message, err := protocol.ReadMessage(r)
-		if err != nil {
+		message, err := protocol.ReadMessageWithLimit(r, c.config.MaxResponsePayloadSize)
+		if tooLarge, ok := err.(protocol.FrameTooLargeError); ok {
+			// The rest of the websocket message is discarded by the next
+			// call to NextReader
+			c.logger.WithFields(logrus.Fields{"error": err}).Warn("Dropping a message that is too large")
+			metrics.TotalMessagesTooLargeCounter.Inc()
+			c.socket.SetReadDeadline(time.Time{})
+			c.keepalive.RecordKeepalive()
+			c.reportDroppedMessage(tooLarge)
+			continue
+		} else if err != nil {
 			c.logger.WithFields(logrus.Fields{"error": err}).Error("Error while reading receptor message")
 			return
 		}
@@ -59,11 +175,39 @@ func (c *rcClient) read(ctx context.Context) {
 		// The read has completed...disable the read deadline
 		c.socket.SetReadDeadline(time.Time{})
 
+		c.keepalive.RecordKeepalive()
+		c.activity.RecordActivity()
+
 		metrics.TotalMessagesReceivedCounter.Inc()
 
 		c.logger.Tracef("Received message: %+v", message)
 
-		c.recv <- message
+		select {
+		case c.recv <- message:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// reportDroppedMessage records why a message was dropped.  Only the most
+// recent dropped message is kept.
+func (c *rcClient) reportDroppedMessage(err protocol.FrameTooLargeError) {
+	dropped := controller.DroppedMessage{Reason: err, DroppedAt: time.Now()}
+	if inResponseTo, parseErr := uuid.Parse(err.InResponseTo); parseErr == nil {
+		dropped.InResponseTo = inResponseTo
+	}
+	for {
+		select {
+		case c.droppedChannel <- dropped:
+			return
+		default:
+		}
+
+		select {
+		case <-c.droppedChannel:
+		default:
+		}
 	}
 }
 
@@ -76,6 +220,7 @@ func (c *rcClient) configurePongHandler() {
 		c.socket.SetPongHandler(func(data string) error {
 			// c.logger.Debug("Got a pong message")
 			c.socket.SetReadDeadline(time.Now().Add(c.config.PongWait))
+			c.keepalive.RecordKeepalive()
 			return nil
 		})
 	} else {
@@ -104,6 +249,8 @@ func (c *rcClient) writeMessage(msg controller.ReceptorMessage) error {
 
 	w.Close()
 
+	c.activity.RecordActivity()
+
 	return nil
 }
 
@@ -116,12 +263,22 @@ func (c *rcClient) write(ctx context.Context) {
 		pingTicker.Stop()
 	}()
 
+	// While the send rate is limited, the send channel is not read until the
+	// throttle fires.  The messages wait in the send channel in the meantime.
+	send := c.send
+	var throttle <-chan time.Time
+
 	for {
 
 		select {
 		case <-ctx.Done():
+			c.writeCloseMessage()
 			return
 
+		case <-throttle:
+			send = c.send
+			throttle = nil
+
 		case errMsg := <-c.errorChannel:
 			c.logger.WithFields(logrus.Fields{"error": errMsg.Error}).Error("Received an error from the sync layer")
 
@@ -130,8 +287,12 @@ func (c *rcClient) write(ctx context.Context) {
 				break
 			}
 
+			// The handshake response may still be queued on the control
+			// channel.  It is written first so that the close does not overtake it.
+			c.writePendingControlMessages()
+
 			c.socket.WriteMessage(websocket.CloseMessage,
-				websocket.FormatCloseMessage(websocket.CloseNormalClosure, errMsg.Error.Error()))
+				websocket.FormatCloseMessage(closeCode(controller.CloseReasonForError(errMsg.Error)), errMsg.Error.Error()))
 			// FIXME: is a sleep needed here??
 			return
 
@@ -143,13 +304,37 @@ func (c *rcClient) write(ctx context.Context) {
 				return
 			}
 
-		case msg := <-c.send:
+		case msg, ok := <-send:
+			if !ok {
+				c.logger.Debug("Send channel has been closed")
+				c.writeCloseMessage()
+				return
+			}
+			if msg.IsExpired(time.Now()) {
+				c.logger.WithFields(logrus.Fields{"expires_at": msg.ExpiresAt}).Info("Dropping expired message")
+				c.logger.Tracef("Expired message: %+v", msg)
+				metrics.TotalMessagesExpiredCounter.Inc()
+				if msg.OnExpired != nil {
+					msg.OnExpired()
+				}
+				break
+			}
 			c.logger.Tracef("Sending message received from send channel: %+v", msg)
 			err := c.writeMessage(msg)
 			if err != nil {
 				c.logger.WithFields(logrus.Fields{"error": err}).Error("Error while sending a message")
+				if msg.OnFailed != nil {
+					msg.OnFailed(err)
+				}
 				return
 			}
+			if msg.OnSent != nil {
+				msg.OnSent()
+			}
+			if interval := c.getSendInterval(); interval > 0 {
+				send = nil
+				throttle = time.After(interval)
+			}
 
 		case <-pingTicker.C:
 			// c.logger.Debug("Sending a ping message")
@@ -162,6 +347,20 @@ func (c *rcClient) write(ctx context.Context) {
 	}
 }
 
+func (c *rcClient) writePendingControlMessages() {
+	for {
+		select {
+		case msg := <-c.controlChannel:
+			if err := c.writeMessage(msg); err != nil {
+				c.logger.WithFields(logrus.Fields{"error": err}).Error("Error while sending a control message")
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
 func (c *rcClient) configurePingTicker() *time.Ticker {
 
 	if c.config.PingPeriod > 0 {
@@ -0,0 +1,119 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/controller"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var _ = Describe("Disconnect reasons", func() {
+	It("Should report a lost connection as a network disconnect", func() {
+		client := &rcClient{}
+		client.recordReadError(errors.New("unexpected EOF"))
+		Expect(client.getDisconnectReason()).To(Equal(controller.DISCONNECT_REASON_NETWORK))
+	})
+
+	It("Should report a read timeout as an idle timeout", func() {
+		client := &rcClient{}
+		client.recordReadError(timeoutError{})
+		Expect(client.getDisconnectReason()).To(Equal(controller.DISCONNECT_REASON_IDLE_TIMEOUT))
+	})
+
+	It("Should prefer the reason the controller closed the connection for", func() {
+		client := &rcClient{}
+		client.setCloseReason(controller.CLOSE_REASON_ADMIN_DISCONNECT)
+		client.recordReadError(timeoutError{})
+		Expect(client.getDisconnectReason()).To(Equal(controller.DISCONNECT_REASON_ADMIN))
+	})
+})
+
+var _ = Describe("Close messages", func() {
+	It("Should only contain the reason without a reconnect hint", func() {
+		client := &rcClient{}
+		client.setCloseReason(controller.CLOSE_REASON_ADMIN_DISCONNECT)
+
+		reason, text := client.closeMessage()
+		Expect(reason).To(Equal(controller.CLOSE_REASON_ADMIN_DISCONNECT))
+		Expect(text).To(Equal("admin_disconnect"))
+	})
+
+	It("Should tell the node which pod to reconnect to", func() {
+		client := &rcClient{}
+		client.setCloseReason(controller.CLOSE_REASON_REBALANCE)
+		client.setReconnectHint("gateway-2")
+
+		reason, text := client.closeMessage()
+		Expect(closeCode(reason)).To(Equal(websocket.CloseGoingAway))
+		Expect(text).To(Equal("rebalance reconnect_to=gateway-2"))
+		Expect(client.getDisconnectReason()).To(Equal(controller.DISCONNECT_REASON_REBALANCE))
+	})
+})
+
+var _ = Describe("Send rate", func() {
+	It("Should convert the send rate into an interval between messages", func() {
+		client := &rcClient{}
+		Expect(client.getSendInterval()).To(Equal(time.Duration(0)))
+
+		client.setSendRate(4)
+		Expect(client.getSendInterval()).To(Equal(250 * time.Millisecond))
+
+		client.setSendRate(0)
+		Expect(client.getSendInterval()).To(Equal(time.Duration(0)))
+	})
+})
+
+var _ = Describe("Writing the messages", func() {
+	It("Should fail the message that could not be written", func() {
+		sockets := make(chan *websocket.Conn, 1)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			socket, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+			Expect(err).NotTo(HaveOccurred())
+			sockets <- socket
+		}))
+		defer server.Close()
+
+		c, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+		Expect(err).NotTo(HaveOccurred())
+		defer c.Close()
+
+		client := &rcClient{
+			account: "540155",
+			config:  config.GetConfig(),
+			socket:  <-sockets,
+			send:    make(chan controller.ReceptorMessage, 1),
+			logger:  logger.Log.WithFields(logrus.Fields{}),
+		}
+
+		failed := make(chan error, 1)
+		client.send <- controller.ReceptorMessage{
+			AccountNumber: "0000001",
+			Message:       &protocol.PayloadMessage{},
+			OnSent:        func() { Fail("The message should not have been sent") },
+			OnFailed:      func(err error) { failed <- err },
+		}
+
+		client.write(context.TODO())
+
+		Eventually(failed).Should(Receive(HaveOccurred()))
+	})
+})
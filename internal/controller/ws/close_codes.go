@@ -0,0 +1,28 @@
+package ws
+
+import (
+	"github.com/RedHatInsights/platform-receptor-controller/internal/controller"
+	"github.com/gorilla/websocket"
+)
+
+// closeCodes maps the reason for closing a connection to the websocket close
+// code sent to the node.  A node is expected to reconnect immediately after a
+// normal closure or going away, back off after try again later and stop
+// reconnecting after a policy violation or a protocol error (until the node
+// is upgraded).
+var closeCodes = map[controller.CloseReason]int{
+	controller.CLOSE_REASON_NORMAL:            websocket.CloseNormalClosure,
+	controller.CLOSE_REASON_ADMIN_DISCONNECT:  websocket.CloseNormalClosure,
+	controller.CLOSE_REASON_SHUTDOWN:          websocket.CloseGoingAway,
+	controller.CLOSE_REASON_POLICY_VIOLATION:  websocket.ClosePolicyViolation,
+	controller.CLOSE_REASON_OVERLOADED:        websocket.CloseTryAgainLater,
+	controller.CLOSE_REASON_PROTOCOL_MISMATCH: websocket.CloseProtocolError,
+	controller.CLOSE_REASON_REBALANCE:         websocket.CloseGoingAway,
+}
+
+func closeCode(reason controller.CloseReason) int {
+	if code, exists := closeCodes[reason]; exists {
+		return code
+	}
+	return websocket.CloseNormalClosure
+}
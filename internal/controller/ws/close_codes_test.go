@@ -0,0 +1,24 @@
+package ws
+
+import (
+	"github.com/RedHatInsights/platform-receptor-controller/internal/controller"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/gorilla/websocket"
+)
+
+var _ = Describe("Close codes", func() {
+	It("Should map each close reason to a websocket close code", func() {
+		Expect(closeCode(controller.CLOSE_REASON_NORMAL)).To(Equal(websocket.CloseNormalClosure))
+		Expect(closeCode(controller.CLOSE_REASON_ADMIN_DISCONNECT)).To(Equal(websocket.CloseNormalClosure))
+		Expect(closeCode(controller.CLOSE_REASON_SHUTDOWN)).To(Equal(websocket.CloseGoingAway))
+		Expect(closeCode(controller.CLOSE_REASON_POLICY_VIOLATION)).To(Equal(websocket.ClosePolicyViolation))
+		Expect(closeCode(controller.CLOSE_REASON_OVERLOADED)).To(Equal(websocket.CloseTryAgainLater))
+	})
+
+	It("Should use a normal closure for an unknown close reason", func() {
+		Expect(closeCode(controller.CloseReason("unknown"))).To(Equal(websocket.CloseNormalClosure))
+	})
+})
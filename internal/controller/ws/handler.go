@@ -2,7 +2,11 @@ package ws
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
 	"github.com/RedHatInsights/platform-receptor-controller/internal/controller"
@@ -16,37 +20,79 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+var errAcceptRateExceeded = errors.New("connection accept rate exceeded")
+
 type ReceptorController struct {
 	connectionMgr            controller.ConnectionRegistrar
+	registrationHooks        *controller.RegistrationHookChain
 	router                   *mux.Router
 	config                   *config.Config
 	responseReactorFactory   *controller.ResponseReactorFactory
 	messageDispatcherFactory *controller.MessageDispatcherFactory
 	receptorServiceFactory   *controller.ReceptorServiceFactory
+	acceptLimiter            *acceptLimiter
 }
 
-func NewReceptorController(cfg *config.Config, cm controller.ConnectionRegistrar, r *mux.Router, rd *controller.ResponseReactorFactory, md *controller.MessageDispatcherFactory, rs *controller.ReceptorServiceFactory) *ReceptorController {
+// NewReceptorController returns a controller whose registration hook chain
+// starts with the connection policy.  More hooks can be appended with
+// AddRegistrationHook.
+func NewReceptorController(cfg *config.Config, cm controller.ConnectionRegistrar, cp controller.ConnectionPolicy, r *mux.Router, rd *controller.ResponseReactorFactory, md *controller.MessageDispatcherFactory, rs *controller.ReceptorServiceFactory) *ReceptorController {
+	registrationHooks := controller.NewRegistrationHookChain()
+	registrationHooks.Add(controller.REGISTRATION_HOOK_POLICY, controller.NewConnectionPolicyHook(cp))
+
 	return &ReceptorController{
 		connectionMgr:            cm,
+		registrationHooks:        registrationHooks,
 		router:                   r,
 		config:                   cfg,
 		responseReactorFactory:   rd,
 		messageDispatcherFactory: md,
 		receptorServiceFactory:   rs,
+		acceptLimiter:            newAcceptLimiter(cfg.AcceptRate, cfg.AcceptBurst),
 	}
 }
 
+// AddRegistrationHook appends a hook to the chain run before a connection is
+// registered, or replaces the hook with the same name
+func (rc *ReceptorController) AddRegistrationHook(name string, hook controller.RegistrationHook) {
+	rc.registrationHooks.Add(name, hook)
+}
+
 func (rc *ReceptorController) Routes() {
 	router := rc.router.PathPrefix("/wss/receptor-controller").Subrouter()
 	router.Use(logger.AccessLoggerMiddleware, identity.EnforceIdentity)
 	router.HandleFunc("/gateway", rc.handleWebSocket()).Methods(http.MethodGet)
 }
 
+// rejectConnection closes the connection with try again later as soon as it
+// is upgraded.  The number of seconds the node should back off for is passed
+// in a Retry-After header and in the text of the close message.
+func (rc *ReceptorController) rejectConnection(w http.ResponseWriter, req *http.Request, upgrader *websocket.Upgrader, logger *logrus.Entry, retryAfter int) {
+	responseHeader := http.Header{}
+	responseHeader.Set("Retry-After", strconv.Itoa(retryAfter))
+
+	socket, err := upgrader.Upgrade(w, req, responseHeader)
+	if err != nil {
+		logger.WithFields(logrus.Fields{"error": err}).Error("Upgrading to a websocket connection failed")
+		return
+	}
+	defer socket.Close()
+
+	text := fmt.Sprintf("%s, retry after %d seconds", errAcceptRateExceeded, retryAfter)
+	socket.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseTryAgainLater, text),
+		time.Now().Add(rc.config.WriteWait))
+}
+
 func (rc *ReceptorController) handleWebSocket() http.HandlerFunc {
 
 	return func(w http.ResponseWriter, req *http.Request) {
 
-		upgrader := &websocket.Upgrader{ReadBufferSize: rc.config.SocketBufferSize, WriteBufferSize: rc.config.SocketBufferSize}
+		upgrader := &websocket.Upgrader{
+			ReadBufferSize:    rc.config.SocketBufferSize,
+			WriteBufferSize:   rc.config.SocketBufferSize,
+			EnableCompression: rc.config.SocketCompression,
+		}
 
 		requestId := request_id.GetReqID(req.Context())
 		rhIdentity := identity.Get(req.Context())
@@ -60,6 +106,13 @@ func (rc *ReceptorController) handleWebSocket() http.HandlerFunc {
 		metrics.ActiveConnectionCounter.Inc()
 		defer metrics.ActiveConnectionCounter.Dec()
 
+		if allowed, retryAfter := rc.acceptLimiter.allow(time.Now()); !allowed {
+			metrics.RateLimitedConnectionCounter.Inc()
+			logger.WithFields(logrus.Fields{"retry_after": retryAfter}).Warn("Connection accept rate exceeded...rejecting connection")
+			rc.rejectConnection(w, req, upgrader, logger, retryAfterSeconds(retryAfter))
+			return
+		}
+
 		socket, err := upgrader.Upgrade(w, req, nil)
 		if err != nil {
 			logger.WithFields(logrus.Fields{"error": err}).Error("Upgrading to a websocket connection failed")
@@ -68,6 +121,14 @@ func (rc *ReceptorController) handleWebSocket() http.HandlerFunc {
 
 		logger.Info("Accepted websocket connection")
 
+		// The compression level only applies if the node negotiated
+		// permessage-deflate.  Compressed frames are read transparently.
+		if rc.config.SocketCompression {
+			if err := socket.SetCompressionLevel(rc.config.SocketCompressionLevel); err != nil {
+				logger.WithFields(logrus.Fields{"error": err}).Warn("Invalid websocket compression level...using the default level")
+			}
+		}
+
 		client := &rcClient{
 			account:        rhIdentity.Identity.AccountNumber,
 			config:         rc.config,
@@ -76,6 +137,9 @@ func (rc *ReceptorController) handleWebSocket() http.HandlerFunc {
 			controlChannel: make(chan controller.ReceptorMessage, rc.config.BufferedChannelSize),
 			errorChannel:   make(chan controller.ReceptorErrorMessage),
 			recv:           make(chan protocol.Message, rc.config.BufferedChannelSize),
+			droppedChannel: make(chan controller.DroppedMessage, 1),
+			keepalive:      controller.NewKeepaliveTracker(),
+			activity:       controller.NewActivityTracker(),
 			logger:         logger,
 		}
 
@@ -85,12 +149,22 @@ func (rc *ReceptorController) handleWebSocket() http.HandlerFunc {
 		client.cancel = cancel
 
 		transport := &controller.Transport{
-			Send:           client.send,
-			Recv:           client.recv,
-			ControlChannel: client.controlChannel,
-			ErrorChannel:   client.errorChannel,
-			Cancel:         client.cancel,
-			Ctx:            ctx,
+			Send:             client.send,
+			Recv:             client.recv,
+			ControlChannel:   client.controlChannel,
+			ErrorChannel:     client.errorChannel,
+			Dropped:          client.droppedChannel,
+			Keepalive:        client.keepalive,
+			Activity:         client.activity,
+			Cancel:           client.cancel,
+			Ctx:              ctx,
+			Closed:           make(chan struct{}),
+			ForceClose:       func() { socket.Close() },
+			SetCloseReason:   client.setCloseReason,
+			SetReconnectHint: client.setReconnectHint,
+			SetSendRate:      client.setSendRate,
+
+			DisconnectReason: client.getDisconnectReason,
 		}
 
 		responseReactor := rc.responseReactorFactory.NewResponseReactor(logger, transport.Recv)
@@ -101,7 +175,11 @@ func (rc *ReceptorController) handleWebSocket() http.HandlerFunc {
 			ResponseReactor:          responseReactor,
 			AccountNumber:            rhIdentity.Identity.AccountNumber,
 			NodeID:                   rc.config.ReceptorControllerNodeId,
+			Labels:                   controller.CaptureLabels(req.Header, rc.config.ConnectionLabelHeaders),
 			ConnectionMgr:            rc.connectionMgr,
+			RegistrationHooks:        rc.registrationHooks,
+			MinProtocolVersion:       rc.config.ReceptorMinProtocolVersion,
+			AffinityPods:             rc.config.AffinityPods,
 			MessageDispatcherFactory: rc.messageDispatcherFactory,
 			Logger:                   logger,
 		}
@@ -116,9 +194,17 @@ func (rc *ReceptorController) handleWebSocket() http.HandlerFunc {
 		// Should the client have a 'handler' function that manages the connection?
 		// ex. setting up ping pong, timeouts, cleanup, and calling the goroutines
 		// go messageDispatcher.StartDispatchingMessages(ctx, client.send)
-		go client.write(ctx)
+		writerDone := make(chan struct{})
+		go func() {
+			client.write(ctx)
+			close(writerDone)
+		}()
 		client.read(ctx)
 
+		cancel()
+		<-writerDone
+		close(transport.Closed)
+
 		logger.Info("Closing websocket connection")
 	}
 }
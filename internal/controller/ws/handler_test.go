@@ -1,13 +1,17 @@
 package ws
 
 import (
+	"context"
 	"encoding/base64"
+	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/RedHatInsights/platform-receptor-controller/internal/config"
 	"github.com/RedHatInsights/platform-receptor-controller/internal/controller"
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
 	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/queue"
 	"github.com/RedHatInsights/platform-receptor-controller/internal/receptor/protocol"
 	"github.com/segmentio/kafka-go"
@@ -19,6 +23,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 	"github.com/posener/wstest"
+	"github.com/sirupsen/logrus"
 )
 
 func readSocket(c *websocket.Conn, mt protocol.NetworkMessageType) (protocol.Message, error) {
@@ -75,8 +80,8 @@ var _ = Describe("WsController", func() {
 			Topic:   cfg.KafkaResponsesTopic,
 		})
 		rd := controller.NewResponseReactorFactory()
-		rs := controller.NewReceptorServiceFactory(kw, cfg)
-		rc = NewReceptorController(cfg, cr, wsMux, rd, md, rs)
+		rs := controller.NewReceptorServiceFactory(kw, controller.NewInMemoryOutboxStore(), cfg)
+		rc = NewReceptorController(cfg, cr, controller.AllowAllConnectionPolicy{}, wsMux, rd, md, rs)
 		rc.Routes()
 
 		d = wstest.NewDialer(rc.router)
@@ -138,6 +143,80 @@ var _ = Describe("WsController", func() {
 
 				m, _ := readSocket(c, 1)
 				Expect(m.Type()).To(Equal(protocol.HiMessageType))
+				Expect(m.(*protocol.HiMessage).Affinity).To(BeEmpty())
+			})
+
+			It("Should suggest the pod that the node should prefer", func() {
+				cfg.AffinityPods = []string{"gateway-0", "gateway-1", "gateway-2"}
+
+				c, _, err := d.Dial("ws://localhost:8080/wss/receptor-controller/gateway", header)
+				Expect(err).NotTo(HaveOccurred())
+				defer c.Close()
+
+				hiMessage := protocol.HiMessage{Command: "HI", ID: "TestClient"}
+				writeSocket(c, &hiMessage)
+
+				m, err := readSocket(c, protocol.HiMessageType)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(m.(*protocol.HiMessage).Affinity).To(Equal(controller.AffinityHint("TestClient", cfg.AffinityPods)))
+			})
+		})
+	})
+
+	Describe("Connecting to the receptor controller with compression enabled", func() {
+		Context("With a node that supports permessage-deflate", func() {
+			It("Should negotiate compression and round trip the messages", func() {
+				cfg.SocketCompression = true
+				d.EnableCompression = true
+
+				c, resp, err := d.Dial("ws://localhost:8080/wss/receptor-controller/gateway", header)
+				Expect(err).NotTo(HaveOccurred())
+				defer c.Close()
+
+				Expect(resp.Header.Get("Sec-Websocket-Extensions")).To(ContainSubstring("permessage-deflate"))
+
+				nodeID := "TestClient"
+
+				handshakeMessage := protocol.HiMessage{Command: "HI", ID: nodeID}
+				writeSocket(c, &handshakeMessage)
+
+				readSocket(c, protocol.HiMessageType)
+
+				cl := cr.(controller.ConnectionLocator)
+				var receptor controller.Receptor
+				Eventually(func() controller.Receptor {
+					receptor = cl.GetConnection("540155", nodeID)
+					return receptor
+				}).ShouldNot(BeNil())
+
+				payload := strings.Repeat("a large and very compressible payload ", 1000)
+				messageID, err := receptor.SendMessage(context.TODO(), "540155", nodeID, []string{nodeID}, payload, "worker:action")
+				Expect(err).NotTo(HaveOccurred())
+
+				m, err := readSocket(c, protocol.PayloadMessageType)
+				Expect(err).NotTo(HaveOccurred())
+
+				payloadMessage := m.(*protocol.PayloadMessage)
+				Expect(payloadMessage.Data.MessageID).To(Equal(messageID.String()))
+				Expect(payloadMessage.Data.RawPayload).To(Equal(payload))
+			})
+		})
+
+		Context("With a node that does not support permessage-deflate", func() {
+			It("Should not negotiate compression", func() {
+				cfg.SocketCompression = true
+
+				c, resp, err := d.Dial("ws://localhost:8080/wss/receptor-controller/gateway", header)
+				Expect(err).NotTo(HaveOccurred())
+				defer c.Close()
+
+				Expect(resp.Header.Get("Sec-Websocket-Extensions")).To(BeEmpty())
+
+				hiMessage := protocol.HiMessage{Command: "HI", ID: "TestClient"}
+				writeSocket(c, &hiMessage)
+
+				m, _ := readSocket(c, protocol.HiMessageType)
+				Expect(m.Type()).To(Equal(protocol.HiMessageType))
 			})
 		})
 	})
@@ -176,6 +255,470 @@ var _ = Describe("WsController", func() {
 		})
 	})
 
+	Describe("Connecting to the receptor controller and sending an expired message", func() {
+		Context("With an open connection and successful handshake", func() {
+			It("Should drop the expired message and deliver the messages that follow it", func() {
+				c, _, err := d.Dial("ws://localhost:8080/wss/receptor-controller/gateway", header)
+				Expect(err).NotTo(HaveOccurred())
+				defer c.Close()
+
+				nodeID := "TestClient"
+
+				handshakeMessage := protocol.HiMessage{Command: "HI", ID: nodeID}
+				writeSocket(c, &handshakeMessage)
+
+				readSocket(c, protocol.HiMessageType)
+
+				cl := cr.(controller.ConnectionLocator)
+				var receptor controller.Receptor
+				Eventually(func() controller.Receptor {
+					receptor = cl.GetConnection("540155", nodeID)
+					return receptor
+				}).ShouldNot(BeNil())
+
+				expiredCtx := controller.WithMessageExpiry(context.TODO(), time.Now().Add(-1*time.Minute))
+				_, err = receptor.SendMessage(expiredCtx, "540155", nodeID, []string{nodeID}, "expired", "worker:action")
+				Expect(err).NotTo(HaveOccurred())
+
+				messageID, err := receptor.SendMessage(context.TODO(), "540155", nodeID, []string{nodeID}, "not expired", "worker:action")
+				Expect(err).NotTo(HaveOccurred())
+
+				m, err := readSocket(c, protocol.PayloadMessageType)
+				Expect(err).NotTo(HaveOccurred())
+
+				payloadMessage := m.(*protocol.PayloadMessage)
+				Expect(payloadMessage.Data.MessageID).To(Equal(messageID.String()))
+			})
+
+			It("Should not delay the messages queued behind the expired messages", func() {
+				c, _, err := d.Dial("ws://localhost:8080/wss/receptor-controller/gateway", header)
+				Expect(err).NotTo(HaveOccurred())
+				defer c.Close()
+
+				nodeID := "TestClient"
+
+				handshakeMessage := protocol.HiMessage{Command: "HI", ID: nodeID}
+				writeSocket(c, &handshakeMessage)
+
+				readSocket(c, protocol.HiMessageType)
+
+				cl := cr.(controller.ConnectionLocator)
+				var receptor controller.Receptor
+				Eventually(func() controller.Receptor {
+					receptor = cl.GetConnection("540155", nodeID)
+					return receptor
+				}).ShouldNot(BeNil())
+
+				writeSocket(c, &protocol.FlowControlMessage{Command: protocol.FlowControlCommand, ID: nodeID, Action: protocol.FlowControlRate, Rate: 4})
+
+				// Give the gateway a chance to process the flow control message
+				time.Sleep(100 * time.Millisecond)
+
+				firstID, err := receptor.SendMessage(context.TODO(), "540155", nodeID, []string{nodeID}, "first", "worker:action")
+				Expect(err).NotTo(HaveOccurred())
+
+				expiredCtx := controller.WithMessageExpiry(context.TODO(), time.Now().Add(-1*time.Minute))
+				for i := 0; i < 3; i++ {
+					_, err = receptor.SendMessage(expiredCtx, "540155", nodeID, []string{nodeID}, "expired", "worker:action")
+					Expect(err).NotTo(HaveOccurred())
+				}
+
+				nextID, err := receptor.SendMessage(context.TODO(), "540155", nodeID, []string{nodeID}, "next", "worker:action")
+				Expect(err).NotTo(HaveOccurred())
+
+				m, err := readSocket(c, protocol.PayloadMessageType)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(m.(*protocol.PayloadMessage).Data.MessageID).To(Equal(firstID.String()))
+				firstReceivedAt := time.Now()
+
+				// The expired messages do not use up the send rate of the node
+				m, err = readSocket(c, protocol.PayloadMessageType)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(m.(*protocol.PayloadMessage).Data.MessageID).To(Equal(nextID.String()))
+				Expect(time.Since(firstReceivedAt)).To(BeNumerically("<", 500*time.Millisecond))
+			})
+		})
+	})
+
+	Describe("Connecting to the receptor controller and sending a binary message", func() {
+		Context("With an open connection and successful handshake", func() {
+			It("Should write the payload to the node as is in a binary frame", func() {
+				c, _, err := d.Dial("ws://localhost:8080/wss/receptor-controller/gateway", header)
+				Expect(err).NotTo(HaveOccurred())
+				defer c.Close()
+
+				nodeID := "TestClient"
+
+				handshakeMessage := protocol.HiMessage{Command: "HI", ID: nodeID, ProtocolVersion: protocol.ProtocolVersion2}
+				writeSocket(c, &handshakeMessage)
+
+				readSocket(c, protocol.HiMessageType)
+
+				cl := cr.(controller.ConnectionLocator)
+				var receptor controller.Receptor
+				Eventually(func() controller.Receptor {
+					receptor = cl.GetConnection("540155", nodeID)
+					return receptor
+				}).ShouldNot(BeNil())
+
+				sender, ok := receptor.(controller.BinaryMessageSender)
+				Expect(ok).To(BeTrue())
+
+				data := []byte{0x1f, 0x8b, 0x08, 0x00, 0xff, '"', '[', 0x00}
+				messageID, err := sender.SendBinaryMessage(context.TODO(), "540155", nodeID, []string{nodeID}, data, "worker:upload")
+				Expect(err).NotTo(HaveOccurred())
+
+				m, err := readSocket(c, protocol.BinaryPayloadMessageType)
+				Expect(err).NotTo(HaveOccurred())
+
+				binaryMessage := m.(*protocol.BinaryPayloadMessage)
+				Expect(binaryMessage.Payload).To(Equal(data))
+				Expect(binaryMessage.Data.MessageID).To(Equal(messageID.String()))
+				Expect(binaryMessage.Data.Directive).To(Equal("worker:upload"))
+			})
+		})
+	})
+
+	Describe("Connecting to the receptor controller and negotiating the protocol version", func() {
+		Context("With an open connection and sending Hi", func() {
+
+			handshake := func(nodeID string, version int) (*websocket.Conn, controller.Receptor) {
+				c, _, err := d.Dial("ws://localhost:8080/wss/receptor-controller/gateway", header)
+				Expect(err).NotTo(HaveOccurred())
+
+				handshakeMessage := protocol.HiMessage{Command: "HI", ID: nodeID, ProtocolVersion: version}
+				writeSocket(c, &handshakeMessage)
+
+				m, err := readSocket(c, protocol.HiMessageType)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(m.(*protocol.HiMessage).ProtocolVersion).To(Equal(version))
+
+				cl := cr.(controller.ConnectionLocator)
+				var receptor controller.Receptor
+				Eventually(func() controller.Receptor {
+					receptor = cl.GetConnection("540155", nodeID)
+					return receptor
+				}).ShouldNot(BeNil())
+
+				return c, receptor
+			}
+
+			It("Should record the version advertised by the node", func() {
+				c, receptor := handshake("TestClient", protocol.ProtocolVersion2)
+				defer c.Close()
+
+				version, err := receptor.(controller.ProtocolVersionReporter).GetProtocolVersion(context.TODO())
+				Expect(err).NotTo(HaveOccurred())
+				Expect(version).To(Equal(protocol.ProtocolVersion2))
+			})
+
+			It("Should talk to a newer node using the current version", func() {
+				c, _, err := d.Dial("ws://localhost:8080/wss/receptor-controller/gateway", header)
+				Expect(err).NotTo(HaveOccurred())
+				defer c.Close()
+
+				handshakeMessage := protocol.HiMessage{Command: "HI", ID: "TestClient", ProtocolVersion: protocol.CurrentProtocolVersion + 1}
+				writeSocket(c, &handshakeMessage)
+
+				m, err := readSocket(c, protocol.HiMessageType)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(m.(*protocol.HiMessage).ProtocolVersion).To(Equal(protocol.CurrentProtocolVersion))
+			})
+
+			It("Should encode the binary payloads as json for a node that speaks the first version", func() {
+				c, receptor := handshake("TestClient", protocol.ProtocolVersion1)
+				defer c.Close()
+
+				data := []byte{0x1f, 0x8b, 0x08, 0x00}
+				sender := receptor.(controller.BinaryMessageSender)
+				_, err := sender.SendBinaryMessage(context.TODO(), "540155", "TestClient", []string{"TestClient"}, data, "worker:upload")
+				Expect(err).NotTo(HaveOccurred())
+
+				m, err := readSocket(c, protocol.PayloadMessageType)
+				Expect(err).NotTo(HaveOccurred())
+
+				payloadMessage := m.(*protocol.PayloadMessage)
+				Expect(payloadMessage.Data.RawPayload).To(Equal(map[string]interface{}{"$binary": base64.StdEncoding.EncodeToString(data)}))
+			})
+
+			It("Should reject a node that speaks an unsupported version", func() {
+				cfg.ReceptorMinProtocolVersion = protocol.ProtocolVersion2
+
+				c, _, err := d.Dial("ws://localhost:8080/wss/receptor-controller/gateway", header)
+				Expect(err).NotTo(HaveOccurred())
+				defer c.Close()
+
+				hiMessage := protocol.HiMessage{Command: "HI", ID: "OldNode"}
+				writeSocket(c, &hiMessage)
+
+				_, _, err = c.NextReader()
+				Expect(err).Should(MatchError(&websocket.CloseError{
+					Code: websocket.CloseProtocolError,
+					Text: "incompatible protocol version 1 (the minimum supported version is 2)",
+				}))
+
+				cl := cr.(controller.ConnectionLocator)
+				Expect(cl.GetConnection("540155", "OldNode")).Should(BeNil())
+			})
+		})
+	})
+
+	Describe("Connecting to the receptor controller and pushing updated capabilities", func() {
+		Context("With an open connection and successful handshake", func() {
+			It("Should update the capabilities of the connection", func() {
+				c, _, err := d.Dial("ws://localhost:8080/wss/receptor-controller/gateway", header)
+				Expect(err).NotTo(HaveOccurred())
+				defer c.Close()
+
+				nodeID := "TestClient"
+
+				handshakeMessage := protocol.HiMessage{
+					Command:  "HI",
+					ID:       nodeID,
+					Metadata: map[string]interface{}{"capabilities": map[string]interface{}{"max_work_threads": 1}},
+				}
+				writeSocket(c, &handshakeMessage)
+
+				readSocket(c, protocol.HiMessageType)
+
+				cl := cr.(controller.ConnectionLocator)
+				var receptor controller.Receptor
+				Eventually(func() controller.Receptor {
+					receptor = cl.GetConnection("540155", nodeID)
+					return receptor
+				}).ShouldNot(BeNil())
+
+				capabilitiesMessage := protocol.CapabilitiesMessage{
+					Command:      protocol.CapabilitiesCommand,
+					ID:           nodeID,
+					Capabilities: map[string]interface{}{"max_work_threads": 12},
+				}
+				writeSocket(c, &capabilitiesMessage)
+
+				Eventually(func() interface{} {
+					capabilities, _ := receptor.GetCapabilities(context.TODO())
+					return capabilities
+				}).Should(Equal(map[string]interface{}{"max_work_threads": float64(12)}))
+			})
+		})
+	})
+
+	Describe("Connecting to the receptor controller and pushing updated metadata", func() {
+		Context("With an open connection and successful handshake", func() {
+			It("Should merge the update into the metadata of the connection", func() {
+				c, _, err := d.Dial("ws://localhost:8080/wss/receptor-controller/gateway", header)
+				Expect(err).NotTo(HaveOccurred())
+				defer c.Close()
+
+				nodeID := "TestClient"
+
+				handshakeMessage := protocol.HiMessage{
+					Command: "HI",
+					ID:      nodeID,
+					Metadata: map[string]interface{}{
+						"capabilities": map[string]interface{}{"max_work_threads": 1},
+						"region":       "us-east-1",
+						"version":      "1.0.0",
+						"failover":     true,
+					},
+				}
+				writeSocket(c, &handshakeMessage)
+
+				readSocket(c, protocol.HiMessageType)
+
+				cl := cr.(controller.ConnectionLocator)
+				var receptor controller.Receptor
+				Eventually(func() controller.Receptor {
+					receptor = cl.GetConnection("540155", nodeID)
+					return receptor
+				}).ShouldNot(BeNil())
+
+				metadataUpdateMessage := protocol.MetadataUpdateMessage{
+					Command:  protocol.MetadataUpdateCommand,
+					ID:       nodeID,
+					Metadata: map[string]interface{}{"region": "us-west-2", "version": "1.1.0", "failover": nil},
+				}
+				writeSocket(c, &metadataUpdateMessage)
+
+				detailer := receptor.(controller.ConnectionDetailer)
+				Eventually(func() interface{} {
+					metadata, _ := detailer.GetMetadata(context.TODO())
+					return metadata
+				}).Should(Equal(map[string]interface{}{
+					"capabilities": map[string]interface{}{"max_work_threads": float64(1)},
+					"region":       "us-west-2",
+					"version":      "1.1.0",
+				}))
+			})
+		})
+	})
+
+	Describe("Connecting to the receptor controller and responding with a payload that is too large", func() {
+		Context("With an open connection and successful handshake", func() {
+			It("Should fail the ping and keep the connection open", func() {
+				cfg.MaxResponsePayloadSize = 256
+
+				c, _, err := d.Dial("ws://localhost:8080/wss/receptor-controller/gateway", header)
+				Expect(err).NotTo(HaveOccurred())
+				defer c.Close()
+
+				nodeID := "TestClient"
+
+				handshakeMessage := protocol.HiMessage{Command: "HI", ID: nodeID}
+				writeSocket(c, &handshakeMessage)
+
+				readSocket(c, protocol.HiMessageType)
+
+				cl := cr.(controller.ConnectionLocator)
+				var receptor controller.Receptor
+				Eventually(func() controller.Receptor {
+					receptor = cl.GetConnection("540155", nodeID)
+					return receptor
+				}).ShouldNot(BeNil())
+
+				respondToPing := func(payload string) error {
+					pingErr := make(chan error, 1)
+					go func() {
+						_, err := receptor.Ping(context.TODO(), "540155", nodeID, []string{nodeID})
+						pingErr <- err
+					}()
+
+					m, err := readSocket(c, protocol.PayloadMessageType)
+					Expect(err).NotTo(HaveOccurred())
+					ping := m.(*protocol.PayloadMessage)
+
+					response := protocol.PayloadMessage{
+						RoutingInfo: &protocol.RoutingMessage{Sender: nodeID, Recipient: ping.RoutingInfo.Sender},
+						Data: protocol.InnerEnvelope{
+							MessageID:    "response",
+							InResponseTo: ping.Data.MessageID,
+							RawPayload:   payload,
+						},
+					}
+					writeSocket(c, &response)
+
+					return <-pingErr
+				}
+
+				err = respondToPing(strings.Repeat("x", 1024))
+				Expect(err).To(BeAssignableToTypeOf(controller.ResponseDroppedError{}))
+				Expect(err.Error()).To(ContainSubstring("exceeds the limit of 256 bytes"))
+
+				Expect(respondToPing("pong")).NotTo(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("Connecting to the receptor controller and sending flow control messages", func() {
+		Context("With an open connection and successful handshake", func() {
+			It("Should hold the messages while the node has paused the delivery", func() {
+				c, _, err := d.Dial("ws://localhost:8080/wss/receptor-controller/gateway", header)
+				Expect(err).NotTo(HaveOccurred())
+				defer c.Close()
+
+				nodeID := "TestClient"
+
+				handshakeMessage := protocol.HiMessage{Command: "HI", ID: nodeID}
+				writeSocket(c, &handshakeMessage)
+
+				readSocket(c, protocol.HiMessageType)
+
+				cl := cr.(controller.ConnectionLocator)
+				var receptor controller.Receptor
+				Eventually(func() controller.Receptor {
+					receptor = cl.GetConnection("540155", nodeID)
+					return receptor
+				}).ShouldNot(BeNil())
+
+				writeSocket(c, &protocol.FlowControlMessage{Command: protocol.FlowControlCommand, ID: nodeID, Action: protocol.FlowControlPause})
+
+				pausable := receptor.(controller.Pausable)
+				Eventually(func() bool { return pausable.IsPaused(context.TODO()) }).Should(BeTrue())
+
+				messageID, err := receptor.SendMessage(context.TODO(), "540155", nodeID, []string{nodeID}, "held", "worker:action")
+				Expect(err).NotTo(HaveOccurred())
+
+				writeSocket(c, &protocol.FlowControlMessage{Command: protocol.FlowControlCommand, ID: nodeID, Action: protocol.FlowControlResume})
+
+				m, err := readSocket(c, protocol.PayloadMessageType)
+				Expect(err).NotTo(HaveOccurred())
+
+				payloadMessage := m.(*protocol.PayloadMessage)
+				Expect(payloadMessage.Data.MessageID).To(Equal(messageID.String()))
+			})
+
+			It("Should limit the rate at which the messages are written to the node", func() {
+				c, _, err := d.Dial("ws://localhost:8080/wss/receptor-controller/gateway", header)
+				Expect(err).NotTo(HaveOccurred())
+				defer c.Close()
+
+				nodeID := "TestClient"
+
+				handshakeMessage := protocol.HiMessage{Command: "HI", ID: nodeID}
+				writeSocket(c, &handshakeMessage)
+
+				readSocket(c, protocol.HiMessageType)
+
+				cl := cr.(controller.ConnectionLocator)
+				var receptor controller.Receptor
+				Eventually(func() controller.Receptor {
+					receptor = cl.GetConnection("540155", nodeID)
+					return receptor
+				}).ShouldNot(BeNil())
+
+				writeSocket(c, &protocol.FlowControlMessage{Command: protocol.FlowControlCommand, ID: nodeID, Action: protocol.FlowControlRate, Rate: 4})
+
+				// Give the gateway a chance to process the flow control message
+				time.Sleep(100 * time.Millisecond)
+
+				for i := 0; i < 2; i++ {
+					_, err = receptor.SendMessage(context.TODO(), "540155", nodeID, []string{nodeID}, "throttled", "worker:action")
+					Expect(err).NotTo(HaveOccurred())
+				}
+
+				_, err = readSocket(c, protocol.PayloadMessageType)
+				Expect(err).NotTo(HaveOccurred())
+				firstReceivedAt := time.Now()
+
+				_, err = readSocket(c, protocol.PayloadMessageType)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(time.Since(firstReceivedAt)).To(BeNumerically(">=", 200*time.Millisecond))
+			})
+		})
+	})
+
+	Describe("Connecting to the receptor controller with label headers", func() {
+		Context("With an open connection and successful handshake", func() {
+			It("Should capture the allowed headers as labels of the connection", func() {
+				cfg.ConnectionLabelHeaders = []string{"X-Site"}
+
+				header.Add("X-Site", "raleigh")
+				header.Add("X-Rack", "r12")
+
+				c, _, err := d.Dial("ws://localhost:8080/wss/receptor-controller/gateway", header)
+				Expect(err).NotTo(HaveOccurred())
+				defer c.Close()
+
+				nodeID := "TestClient"
+
+				handshakeMessage := protocol.HiMessage{Command: "HI", ID: nodeID}
+				writeSocket(c, &handshakeMessage)
+
+				readSocket(c, protocol.HiMessageType)
+
+				cl := cr.(controller.ConnectionLocator)
+				Eventually(func() map[string]string {
+					receptor := cl.GetConnection("540155", nodeID)
+					if receptor == nil {
+						return nil
+					}
+					labels, _ := receptor.(controller.ConnectionLabeler).GetLabels(context.TODO())
+					return labels
+				}).Should(Equal(map[string]string{"x-site": "raleigh"}))
+			})
+		})
+	})
+
 	Describe("Connecting to the receptor controller with duplicate account and node id", func() {
 		Context("With an open connection and open a new connection and send Hi with the same account and node id", func() {
 			It("Should in return receive an error on the second connection", func() {
@@ -202,6 +745,151 @@ var _ = Describe("WsController", func() {
 		})
 	})
 
+	Describe("Connecting to the receptor controller from a denied node", func() {
+		Context("With an open connection and sending Hi", func() {
+			It("Should close the connection with a policy violation and not register the node", func() {
+				rc.AddRegistrationHook(controller.REGISTRATION_HOOK_POLICY,
+					controller.NewConnectionPolicyHook(controller.NewDenyListConnectionPolicy(nil, []string{"DeniedNode"})))
+
+				c, _, err := d.Dial("ws://localhost:8080/wss/receptor-controller/gateway", header)
+				Expect(err).NotTo(HaveOccurred())
+				defer c.Close()
+
+				hiMessage := protocol.HiMessage{Command: "HI", ID: "DeniedNode"}
+				writeSocket(c, &hiMessage)
+
+				_, _, err = c.NextReader()
+				Expect(err).Should(MatchError(&websocket.CloseError{
+					Code: websocket.ClosePolicyViolation,
+					Text: "connection rejected: node is not allowed to connect",
+				}))
+
+				cl := cr.(controller.ConnectionLocator)
+				Expect(cl.GetConnection("540155", "DeniedNode")).Should(BeNil())
+			})
+		})
+	})
+
+	Describe("Connecting to the receptor controller and being disconnected by the controller", func() {
+		Context("With an open connection and successful handshake", func() {
+
+			closeConnection := func(ctx context.Context) error {
+				c, _, err := d.Dial("ws://localhost:8080/wss/receptor-controller/gateway", header)
+				Expect(err).NotTo(HaveOccurred())
+				defer c.Close()
+
+				nodeID := "TestClient"
+
+				handshakeMessage := protocol.HiMessage{Command: "HI", ID: nodeID}
+				writeSocket(c, &handshakeMessage)
+
+				readSocket(c, protocol.HiMessageType)
+
+				cl := cr.(controller.ConnectionLocator)
+				var receptor controller.Receptor
+				Eventually(func() controller.Receptor {
+					receptor = cl.GetConnection("540155", nodeID)
+					return receptor
+				}).ShouldNot(BeNil())
+
+				go receptor.Close(ctx)
+
+				_, _, err = c.NextReader()
+				return err
+			}
+
+			It("Should close the connection with a normal closure after an admin disconnect", func() {
+				err := closeConnection(controller.WithCloseReason(context.TODO(), controller.CLOSE_REASON_ADMIN_DISCONNECT))
+				Expect(err).Should(MatchError(&websocket.CloseError{
+					Code: websocket.CloseNormalClosure,
+					Text: string(controller.CLOSE_REASON_ADMIN_DISCONNECT),
+				}))
+			})
+
+			It("Should close the connection with going away during shutdown", func() {
+				err := closeConnection(controller.WithCloseReason(context.TODO(), controller.CLOSE_REASON_SHUTDOWN))
+				Expect(err).Should(MatchError(&websocket.CloseError{
+					Code: websocket.CloseGoingAway,
+					Text: string(controller.CLOSE_REASON_SHUTDOWN),
+				}))
+			})
+
+			It("Should close the connection with a normal closure when no reason is given", func() {
+				err := closeConnection(context.TODO())
+				Expect(err).Should(MatchError(&websocket.CloseError{
+					Code: websocket.CloseNormalClosure,
+					Text: string(controller.CLOSE_REASON_NORMAL),
+				}))
+			})
+		})
+	})
+
+	Describe("Connecting to the receptor controller when the account has too many connections", func() {
+		Context("With an open connection and sending Hi from another node", func() {
+			It("Should close the connection with try again later", func() {
+				cr = controller.NewLocalConnectionManagerWithConnectionLimit(controller.ConnectionLimit{Default: 1})
+				rc.connectionMgr = cr
+
+				// The test dialer only supports a single connection, so the
+				// connection that uses up the limit is registered directly
+				existing := rc.receptorServiceFactory.NewReceptorService(logger.Log.WithFields(logrus.Fields{}), "540155", "ExistingClient")
+				Expect(cr.Register("540155", "ExistingClient", existing)).To(Succeed())
+
+				overflow, _, err := d.Dial("ws://localhost:8080/wss/receptor-controller/gateway", header)
+				Expect(err).NotTo(HaveOccurred())
+				defer overflow.Close()
+
+				writeSocket(overflow, &protocol.HiMessage{Command: "HI", ID: "TestClient"})
+				readSocket(overflow, protocol.HiMessageType)
+
+				_, _, err = overflow.NextReader()
+				Expect(err).Should(MatchError(&websocket.CloseError{
+					Code: websocket.CloseTryAgainLater,
+					Text: controller.TooManyConnectionsError{}.Error(),
+				}))
+			})
+		})
+	})
+
+	Describe("Connecting to the receptor controller faster than the accept rate", func() {
+		Context("With a flood of connection attempts", func() {
+			It("Should close the excess connections with try again later", func() {
+				rc.acceptLimiter = newAcceptLimiter(1, 2)
+
+				accepted := 0
+				for i := 0; i < 5; i++ {
+					// The test dialer only supports a single connection
+					if i > 0 {
+						d = wstest.NewDialer(rc.router)
+					}
+
+					c, resp, err := d.Dial("ws://localhost:8080/wss/receptor-controller/gateway", header)
+					Expect(err).NotTo(HaveOccurred())
+					defer c.Close()
+
+					if resp.Header.Get("Retry-After") != "" {
+						Expect(resp.Header.Get("Retry-After")).To(Equal("1"))
+						_, _, err = c.NextReader()
+						Expect(err).Should(MatchError(&websocket.CloseError{
+							Code: websocket.CloseTryAgainLater,
+							Text: "connection accept rate exceeded, retry after 1 seconds",
+						}))
+						continue
+					}
+
+					accepted++
+					writeSocket(c, &protocol.HiMessage{Command: "HI", ID: fmt.Sprintf("TestClient-%d", i)})
+					readSocket(c, protocol.HiMessageType)
+				}
+
+				// The bucket refills at 1 connection per second, at most
+				// one more connection is accepted while flooding
+				Expect(accepted).To(BeNumerically(">=", 2))
+				Expect(accepted).To(BeNumerically("<=", 3))
+			})
+		})
+	})
+
 	Describe("Connecting to the receptor controller with a handshake that takes too long", func() {
 		Context("With an open connection and trying to read from the connection", func() {
 			It("Should in return receive connection closed error", func() {
@@ -10,6 +10,9 @@ type Metrics struct {
 	ActiveConnectionCounter      prometheus.Gauge
 	TotalMessagesSentCounter     prometheus.Counter
 	TotalMessagesReceivedCounter prometheus.Counter
+	TotalMessagesExpiredCounter  prometheus.Counter
+	TotalMessagesTooLargeCounter prometheus.Counter
+	RateLimitedConnectionCounter prometheus.Counter
 }
 
 func NewMetrics() *Metrics {
@@ -35,6 +38,21 @@ func NewMetrics() *Metrics {
 		Help: "The total number of messages received over a websocket connection",
 	})
 
+	metrics.TotalMessagesExpiredCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "receptor_controller_websocket_total_messages_expired_count",
+		Help: "The total number of messages dropped because they expired before being sent over a websocket connection",
+	})
+
+	metrics.TotalMessagesTooLargeCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "receptor_controller_websocket_total_messages_too_large_count",
+		Help: "The total number of messages received over a websocket connection that were dropped because they were too large",
+	})
+
+	metrics.RateLimitedConnectionCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "receptor_controller_websocket_rate_limited_connection_count",
+		Help: "The total number of receptor websocket connections rejected because the accept rate was exceeded",
+	})
+
 	return metrics
 }
 
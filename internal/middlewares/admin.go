@@ -0,0 +1,41 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	adminErrorMessage = "Forbidden"
+)
+
+// AdminMiddleware allows the passage of the admin client ids into the RequireAdmin middleware
+type AdminMiddleware struct {
+	AdminClientIDs []string
+}
+
+// RequireAdmin only allows service to service requests from one of the configured admin
+// clients.  It must be chained after the Authenticate middleware.
+func (amw *AdminMiddleware) RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := r.Context().Value(principalKey).(serviceToServicePrincipal)
+		if !ok || !amw.isAdmin(principal.GetClientID()) {
+			logger.Log.WithFields(logrus.Fields{"client_id": principal.GetClientID()}).Debug("Admin authorization failure")
+			http.Error(w, adminErrorMessage, http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (amw *AdminMiddleware) isAdmin(clientID string) bool {
+	for _, adminClientID := range amw.AdminClientIDs {
+		if clientID == adminClientID {
+			return true
+		}
+	}
+	return false
+}
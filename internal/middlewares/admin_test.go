@@ -0,0 +1,67 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/middlewares"
+)
+
+const (
+	adminFailure = "Forbidden"
+)
+
+func adminBoiler(req *http.Request, expectedStatusCode int, expectedBody string, amw *middlewares.AuthMiddleware, adminMw *middlewares.AdminMiddleware) {
+	rr := httptest.NewRecorder()
+	handler := amw.Authenticate(adminMw.RequireAdmin(GetTestHandler(EXPECTED_ACCOUNT_FROM_TOKEN)))
+	handler.ServeHTTP(rr, req)
+
+	Expect(rr.Code).To(Equal(expectedStatusCode))
+	Expect(rr.Body.String()).To(Equal(expectedBody))
+}
+
+var _ = Describe("Admin", func() {
+	var (
+		req     *http.Request
+		amw     *middlewares.AuthMiddleware
+		adminMw *middlewares.AdminMiddleware
+	)
+
+	BeforeEach(func() {
+		knownSecrets := make(map[string]interface{})
+		knownSecrets["test_client_1"] = "12345"
+		knownSecrets["admin_client"] = "67890"
+		amw = &middlewares.AuthMiddleware{Secrets: knownSecrets}
+		adminMw = &middlewares.AdminMiddleware{AdminClientIDs: []string{"admin_client"}}
+		r, err := http.NewRequest("POST", "/admin/connections/import", nil)
+		if err != nil {
+			panic("Test error unable to get new request")
+		}
+		req = r
+	})
+
+	It("Should return 200 for an admin client", func() {
+		req.Header.Add(TOKEN_HEADER_CLIENT_NAME, "admin_client")
+		req.Header.Add(TOKEN_HEADER_ACCOUNT_NAME, EXPECTED_ACCOUNT_FROM_TOKEN)
+		req.Header.Add(TOKEN_HEADER_PSK_NAME, "67890")
+
+		adminBoiler(req, 200, "", amw, adminMw)
+	})
+
+	It("Should return 403 for a client that is not an admin", func() {
+		req.Header.Add(TOKEN_HEADER_CLIENT_NAME, "test_client_1")
+		req.Header.Add(TOKEN_HEADER_ACCOUNT_NAME, EXPECTED_ACCOUNT_FROM_TOKEN)
+		req.Header.Add(TOKEN_HEADER_PSK_NAME, "12345")
+
+		adminBoiler(req, 403, adminFailure+"\n", amw, adminMw)
+	})
+
+	It("Should return 403 when using identity header authentication", func() {
+		req.Header.Add(IDENTITY_HEADER_NAME, VALID_IDENTITY_HEADER)
+
+		adminBoiler(req, 403, adminFailure+"\n", amw, adminMw)
+	})
+})
@@ -61,6 +61,13 @@ func GetPrincipal(ctx context.Context) (Principal, bool) {
 	return p, ok
 }
 
+// GetClientID returns the client id of a service to service principal.  An
+// empty string is returned if the request was authenticated some other way.
+func GetClientID(ctx context.Context) string {
+	p, _ := ctx.Value(principalKey).(serviceToServicePrincipal)
+	return p.GetClientID()
+}
+
 type serviceCredentials struct {
 	clientID string
 	account  string
@@ -67,6 +67,20 @@ var _ = Describe("Auth", func() {
 				boiler(req, 200, "", EXPECTED_ACCOUNT_FROM_TOKEN, amw)
 			})
 
+			It("Should record the client id of the principal", func() {
+				req.Header.Add(TOKEN_HEADER_CLIENT_NAME, "test_client_1")
+				req.Header.Add(TOKEN_HEADER_ACCOUNT_NAME, EXPECTED_ACCOUNT_FROM_TOKEN)
+				req.Header.Add(TOKEN_HEADER_PSK_NAME, "12345")
+
+				var clientID string
+				handler := amw.Authenticate(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+					clientID = middlewares.GetClientID(req.Context())
+				}))
+				handler.ServeHTTP(httptest.NewRecorder(), req)
+
+				Expect(clientID).To(Equal("test_client_1"))
+			})
+
 			It("Should return a 401 when the key is incorrect", func() {
 				req.Header.Add(TOKEN_HEADER_CLIENT_NAME, "test_client_1")
 				req.Header.Add(TOKEN_HEADER_ACCOUNT_NAME, EXPECTED_ACCOUNT_FROM_TOKEN)
@@ -0,0 +1,105 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	concurrencyLimitErrorMessage = "Too many concurrent requests"
+	concurrencyLimitRetryAfter   = 1
+)
+
+var concurrencySlotKey key = 1
+
+var shedRequestCounter = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "receptor_controller_shed_request_count",
+	Help: "The total number of requests rejected because too many requests were being handled",
+})
+
+// ConcurrencyLimitMiddleware caps the number of requests that are handled at
+// the same time.  A request that arrives while the cap is reached waits up to
+// the queue timeout for another request to complete.  It is rejected with a
+// 503 if no request completes in time.
+type ConcurrencyLimitMiddleware struct {
+	slots        chan struct{}
+	queueTimeout time.Duration
+}
+
+// NewConcurrencyLimitMiddleware allows up to maxConcurrent requests to be
+// handled at the same time.  A maxConcurrent of 0 means the requests are not
+// limited.  A queueTimeout of 0 means the excess requests are rejected right
+// away.
+func NewConcurrencyLimitMiddleware(maxConcurrent int, queueTimeout time.Duration) *ConcurrencyLimitMiddleware {
+	cmw := &ConcurrencyLimitMiddleware{queueTimeout: queueTimeout}
+	if maxConcurrent > 0 {
+		cmw.slots = make(chan struct{}, maxConcurrent)
+	}
+	return cmw
+}
+
+// Limit holds one of the slots while the request is being handled
+func (cmw *ConcurrencyLimitMiddleware) Limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cmw.slots == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if cmw.acquire(r.Context()) == false {
+			logger.Log.WithFields(logrus.Fields{"path": r.URL.Path}).Debug("Rejecting request...too many concurrent requests")
+			shedRequestCounter.Inc()
+			w.Header().Set("Retry-After", strconv.Itoa(concurrencyLimitRetryAfter))
+			http.Error(w, concurrencyLimitErrorMessage, http.StatusServiceUnavailable)
+			return
+		}
+
+		var releaseOnce sync.Once
+		release := func() { releaseOnce.Do(func() { <-cmw.slots }) }
+		defer release()
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), concurrencySlotKey, release)))
+	})
+}
+
+func (cmw *ConcurrencyLimitMiddleware) acquire(ctx context.Context) bool {
+	select {
+	case cmw.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	if cmw.queueTimeout <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(cmw.queueTimeout)
+	defer timer.Stop()
+
+	select {
+	case cmw.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// ReleaseConcurrencySlot gives back the slot held by a request.  Handlers that
+// keep the request open for a long time (e.g. to stream events) call it so
+// that they do not count against the cap.  It does nothing if the request is
+// not limited.
+func ReleaseConcurrencySlot(ctx context.Context) {
+	if release, ok := ctx.Value(concurrencySlotKey).(func()); ok {
+		release()
+	}
+}
@@ -0,0 +1,118 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/middlewares"
+)
+
+var _ = Describe("ConcurrencyLimit", func() {
+	var (
+		entered chan struct{}
+		release chan struct{}
+	)
+
+	// blockingHandler holds the request until release is closed.  The
+	// channels of the spec are captured so that the requests still held by
+	// a previous spec do not read the channels of the next one.
+	blockingHandler := func(releaseSlot bool) http.Handler {
+		entered, release := entered, release
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if releaseSlot {
+				middlewares.ReleaseConcurrencySlot(r.Context())
+			}
+			entered <- struct{}{}
+			<-release
+		})
+	}
+
+	// startRequests submits n requests concurrently and waits for all of them
+	// to be handled
+	startRequests := func(handler http.Handler, n int) chan *httptest.ResponseRecorder {
+		responses := make(chan *httptest.ResponseRecorder, n)
+		for i := 0; i < n; i++ {
+			go func() {
+				rr := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", "/connection", nil)
+				handler.ServeHTTP(rr, req)
+				responses <- rr
+			}()
+		}
+		for i := 0; i < n; i++ {
+			Eventually(entered).Should(Receive())
+		}
+		return responses
+	}
+
+	serve := func(handler http.Handler) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/connection", nil)
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	BeforeEach(func() {
+		entered = make(chan struct{}, 10)
+		release = make(chan struct{})
+	})
+
+	It("Should shed the requests over the cap", func() {
+		handler := middlewares.NewConcurrencyLimitMiddleware(2, 0).Limit(blockingHandler(false))
+
+		responses := startRequests(handler, 2)
+
+		rr := serve(handler)
+		Expect(rr.Code).To(Equal(http.StatusServiceUnavailable))
+		Expect(rr.Header().Get("Retry-After")).To(Equal("1"))
+
+		close(release)
+		Eventually(responses).Should(Receive(WithTransform(func(rr *httptest.ResponseRecorder) int { return rr.Code }, Equal(http.StatusOK))))
+		Eventually(responses).Should(Receive(WithTransform(func(rr *httptest.ResponseRecorder) int { return rr.Code }, Equal(http.StatusOK))))
+
+		Expect(serve(handler).Code).To(Equal(http.StatusOK))
+	})
+
+	It("Should queue the requests over the cap until a slot is released", func() {
+		handler := middlewares.NewConcurrencyLimitMiddleware(1, 5*time.Second).Limit(blockingHandler(false))
+
+		responses := startRequests(handler, 1)
+
+		queued := make(chan *httptest.ResponseRecorder, 1)
+		go func() { queued <- serve(handler) }()
+
+		Consistently(entered, 100*time.Millisecond).ShouldNot(Receive())
+
+		close(release)
+		Eventually(responses).Should(Receive())
+		Eventually(entered).Should(Receive())
+		Eventually(queued).Should(Receive(WithTransform(func(rr *httptest.ResponseRecorder) int { return rr.Code }, Equal(http.StatusOK))))
+	})
+
+	It("Should shed the queued requests once the queue timeout expires", func() {
+		handler := middlewares.NewConcurrencyLimitMiddleware(1, 50*time.Millisecond).Limit(blockingHandler(false))
+
+		startRequests(handler, 1)
+		defer close(release)
+
+		Expect(serve(handler).Code).To(Equal(http.StatusServiceUnavailable))
+	})
+
+	It("Should not count the requests that released their slot", func() {
+		handler := middlewares.NewConcurrencyLimitMiddleware(1, 0).Limit(blockingHandler(true))
+
+		startRequests(handler, 3)
+		close(release)
+	})
+
+	It("Should not limit the requests when the cap is 0", func() {
+		handler := middlewares.NewConcurrencyLimitMiddleware(0, 0).Limit(blockingHandler(false))
+
+		startRequests(handler, 5)
+		close(release)
+	})
+})
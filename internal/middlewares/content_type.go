@@ -0,0 +1,32 @@
+package middlewares
+
+import (
+	"mime"
+	"net/http"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	jsonContentType         = "application/json"
+	contentTypeErrorMessage = "Unsupported Media Type: the Content-Type must be " + jsonContentType
+)
+
+// RequireJSONContentType rejects requests whose Content-Type is missing or is
+// not application/json with a 415.  Parameters of the media type (e.g. the
+// charset) are ignored.  It is meant to wrap the handlers of the routes that
+// accept a json body; routes that do not take a body should not use it.
+func RequireJSONContentType(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType := r.Header.Get("Content-Type")
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || mediaType != jsonContentType {
+			logger.Log.WithFields(logrus.Fields{"content_type": contentType}).Debug("Rejected a request with an unsupported content type")
+			http.Error(w, contentTypeErrorMessage, http.StatusUnsupportedMediaType)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
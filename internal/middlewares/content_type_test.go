@@ -0,0 +1,66 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/middlewares"
+)
+
+var _ = Describe("ContentType", func() {
+	var (
+		req *http.Request
+	)
+
+	BeforeEach(func() {
+		r, err := http.NewRequest("POST", "/connection/status", strings.NewReader(`{"account": "1234", "node_id": "345"}`))
+		if err != nil {
+			panic("Test error unable to get new request")
+		}
+		req = r
+	})
+
+	sendRequest := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler := middlewares.RequireJSONContentType(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	It("Should return 200 for a json content type", func() {
+		req.Header.Add("Content-Type", "application/json")
+
+		Expect(sendRequest().Code).To(Equal(http.StatusOK))
+	})
+
+	It("Should return 200 for a json content type with a charset", func() {
+		req.Header.Add("Content-Type", "application/json; charset=UTF-8")
+
+		Expect(sendRequest().Code).To(Equal(http.StatusOK))
+	})
+
+	It("Should return 415 when the content type is missing", func() {
+		rr := sendRequest()
+
+		Expect(rr.Code).To(Equal(http.StatusUnsupportedMediaType))
+		Expect(rr.Body.String()).To(ContainSubstring("application/json"))
+	})
+
+	It("Should return 415 for a form content type", func() {
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+		Expect(sendRequest().Code).To(Equal(http.StatusUnsupportedMediaType))
+	})
+
+	It("Should return 415 for a malformed content type", func() {
+		req.Header.Add("Content-Type", "application/json;;")
+
+		Expect(sendRequest().Code).To(Equal(http.StatusUnsupportedMediaType))
+	})
+})
@@ -0,0 +1,45 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/redhatinsights/platform-go-middlewares/request_id"
+)
+
+const (
+	RequestIDHeader        = "X-Request-Id"
+	PrincipalAccountHeader = "X-Principal-Account"
+)
+
+// ResponseHeadersMiddleware adds headers to the response that allow clients to
+// correlate their logs with ours
+type ResponseHeadersMiddleware struct {
+	IncludePrincipal bool
+}
+
+// AddRequestID echoes the request id in the response.  It must be chained after
+// the request_id middleware.
+func (rmw *ResponseHeadersMiddleware) AddRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requestID := request_id.GetReqID(r.Context()); requestID != "" {
+			w.Header().Set(RequestIDHeader, requestID)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AddPrincipal identifies the authenticated caller in the response.  The
+// header is only added if IncludePrincipal is set.  It must be chained after
+// the Authenticate middleware.
+func (rmw *ResponseHeadersMiddleware) AddPrincipal(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rmw.IncludePrincipal {
+			if principal, ok := GetPrincipal(r.Context()); ok {
+				w.Header().Set(PrincipalAccountHeader, principal.GetAccount())
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
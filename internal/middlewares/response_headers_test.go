@@ -0,0 +1,64 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/middlewares"
+	"github.com/redhatinsights/platform-go-middlewares/request_id"
+)
+
+func responseHeadersBoiler(req *http.Request, amw *middlewares.AuthMiddleware, rmw *middlewares.ResponseHeadersMiddleware) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	handler := request_id.ConfiguredRequestID("x-rh-insights-request-id")(
+		rmw.AddRequestID(amw.Authenticate(rmw.AddPrincipal(GetTestHandler(EXPECTED_ACCOUNT_FROM_IDENTITY_HEADER)))))
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+var _ = Describe("ResponseHeaders", func() {
+	var (
+		req *http.Request
+		amw *middlewares.AuthMiddleware
+	)
+
+	BeforeEach(func() {
+		amw = &middlewares.AuthMiddleware{Secrets: map[string]interface{}{}}
+		r, err := http.NewRequest("GET", "/connection", nil)
+		if err != nil {
+			panic("Test error unable to get new request")
+		}
+		r.Header.Add("x-rh-insights-request-id", "request-1234")
+		req = r
+	})
+
+	It("Should echo the request id", func() {
+		req.Header.Add(IDENTITY_HEADER_NAME, VALID_IDENTITY_HEADER)
+
+		rr := responseHeadersBoiler(req, amw, &middlewares.ResponseHeadersMiddleware{})
+
+		Expect(rr.Code).To(Equal(200))
+		Expect(rr.Header().Get(middlewares.RequestIDHeader)).To(Equal("request-1234"))
+		Expect(rr.Header().Get(middlewares.PrincipalAccountHeader)).To(Equal(""))
+	})
+
+	It("Should echo the request id when authentication fails", func() {
+		rr := responseHeadersBoiler(req, amw, &middlewares.ResponseHeadersMiddleware{IncludePrincipal: true})
+
+		Expect(rr.Code).To(Equal(401))
+		Expect(rr.Header().Get(middlewares.RequestIDHeader)).To(Equal("request-1234"))
+		Expect(rr.Header().Get(middlewares.PrincipalAccountHeader)).To(Equal(""))
+	})
+
+	It("Should identify the principal when enabled", func() {
+		req.Header.Add(IDENTITY_HEADER_NAME, VALID_IDENTITY_HEADER)
+
+		rr := responseHeadersBoiler(req, amw, &middlewares.ResponseHeadersMiddleware{IncludePrincipal: true})
+
+		Expect(rr.Code).To(Equal(200))
+		Expect(rr.Header().Get(middlewares.PrincipalAccountHeader)).To(Equal(EXPECTED_ACCOUNT_FROM_IDENTITY_HEADER))
+	})
+})
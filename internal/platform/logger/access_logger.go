@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"net/http"
+	"strings"
+	"sync/atomic"
 
 	"github.com/gorilla/handlers"
 	"github.com/sirupsen/logrus"
@@ -14,20 +17,96 @@ func AccessLoggerMiddleware(next http.Handler) http.Handler {
 	return handlers.CustomLoggingHandler(ioutil.Discard, next, logrusAccessLogAdapter)
 }
 
+// AccessLogVerbosity controls which requests are written to the access log.
+// The mutating requests are always logged.  Only a fraction of the read-only
+// (GET, HEAD and OPTIONS) requests are logged, set by ReadOnlySampleRate
+// between 0 (none) and 1 (all).  The requests whose path starts with one of
+// the suppressed paths are never logged.
+type AccessLogVerbosity struct {
+	ReadOnlySampleRate float64
+	SuppressedPaths    []string
+}
+
+// NewAccessLoggerMiddleware returns an access logger with the given verbosity.
+// The read-only requests are sampled deterministically: with a rate of 0.1,
+// exactly one request out of every ten is logged.
+func NewAccessLoggerMiddleware(verbosity AccessLogVerbosity) func(http.Handler) http.Handler {
+	sampler := &accessLogSampler{rate: math.Max(0, math.Min(1, verbosity.ReadOnlySampleRate))}
+
+	return func(next http.Handler) http.Handler {
+		logged := handlers.CustomLoggingHandler(ioutil.Discard, next, func(w io.Writer, params handlers.LogFormatterParams) {
+			if sampler.rate < 1 && isReadOnlyRequest(params.Request) {
+				logrusAccessLogEntry(params).WithFields(logrus.Fields{"sample_rate": sampler.rate}).Info("access")
+				return
+			}
+			logrusAccessLogEntry(params).Info("access")
+		})
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isSuppressedPath(r.URL.Path, verbosity.SuppressedPaths) ||
+				(isReadOnlyRequest(r) && !sampler.sample()) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			logged.ServeHTTP(w, r)
+		})
+	}
+}
+
+type accessLogSampler struct {
+	rate  float64
+	count uint64
+}
+
+// sample returns true every time the number of requests times the rate
+// crosses an integer
+func (s *accessLogSampler) sample() bool {
+	if s.rate >= 1 {
+		return true
+	}
+	if s.rate <= 0 {
+		return false
+	}
+
+	n := atomic.AddUint64(&s.count, 1)
+	return math.Floor(float64(n)*s.rate) > math.Floor(float64(n-1)*s.rate)
+}
+
+func isReadOnlyRequest(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func isSuppressedPath(path string, suppressedPaths []string) bool {
+	for _, suppressed := range suppressedPaths {
+		if suppressed != "" && strings.HasPrefix(path, suppressed) {
+			return true
+		}
+	}
+	return false
+}
+
 // This is a bit of a hack as well.  This method should be writing to the io.Writer.
 // Unfortunately, it doesn't seem possible to use the logrus Fields when writing
 // to the io.Writer object directly.  It might be cleaner to implement our own
 // logging handler eventually.
 func logrusAccessLogAdapter(w io.Writer, params handlers.LogFormatterParams) {
+	logrusAccessLogEntry(params).Info("access")
+}
+
+func logrusAccessLogEntry(params handlers.LogFormatterParams) *logrus.Entry {
 	request := fmt.Sprintf("%s %s %s", params.Request.Method, params.Request.URL, params.Request.Proto)
 	requestID := getRequestIdFromRequest(params.Request)
-	Log.WithFields(logrus.Fields{
+	return Log.WithFields(logrus.Fields{
 		"remote_addr": params.Request.RemoteAddr,
 		"request":     request,
 		"request_id":  requestID,
 		"status":      params.StatusCode,
 		"size":        params.Size},
-	).Info("access")
+	)
 }
 
 func getRequestIdFromRequest(request *http.Request) *string {
@@ -0,0 +1,82 @@
+package logger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func serveAccessLoggerTestRequests(t *testing.T, verbosity AccessLogVerbosity, method string, path string, count int) *test.Hook {
+	log, hook := test.NewNullLogger()
+	previous := Log
+	Log = log
+	defer func() { Log = previous }()
+
+	handler := NewAccessLoggerMiddleware(verbosity)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for i := 0; i < count; i++ {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(method, path, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected the request to be handled, got %d", rr.Code)
+		}
+	}
+
+	return hook
+}
+
+func TestAccessLoggerAlwaysLogsMutatingRequests(t *testing.T) {
+	verbosity := AccessLogVerbosity{ReadOnlySampleRate: 0}
+
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete} {
+		hook := serveAccessLoggerTestRequests(t, verbosity, method, "/connection/disconnect", 10)
+		if entries := len(hook.AllEntries()); entries != 10 {
+			t.Fatalf("Expected the 10 %s requests to be logged, got %d", method, entries)
+		}
+
+		if _, sampled := hook.LastEntry().Data["sample_rate"]; sampled {
+			t.Fatalf("Expected the %s requests not to be sampled", method)
+		}
+	}
+}
+
+func TestAccessLoggerSamplesReadOnlyRequests(t *testing.T) {
+	testCases := []struct {
+		rate     float64
+		expected int
+	}{
+		{1, 100},
+		{0.25, 25},
+		{0.1, 10},
+		{0, 0},
+	}
+
+	for _, tc := range testCases {
+		hook := serveAccessLoggerTestRequests(t, AccessLogVerbosity{ReadOnlySampleRate: tc.rate}, http.MethodGet, "/connection", 100)
+		if entries := len(hook.AllEntries()); entries != tc.expected {
+			t.Fatalf("Expected %d of the 100 requests to be logged with a rate of %g, got %d", tc.expected, tc.rate, entries)
+		}
+
+		if tc.rate > 0 && tc.rate < 1 && hook.LastEntry().Data["sample_rate"] != tc.rate {
+			t.Fatalf("Expected the sample rate to be logged, got %v", hook.LastEntry().Data)
+		}
+	}
+}
+
+func TestAccessLoggerSuppressesPaths(t *testing.T) {
+	verbosity := AccessLogVerbosity{ReadOnlySampleRate: 1, SuppressedPaths: []string{"/healthz"}}
+
+	hook := serveAccessLoggerTestRequests(t, verbosity, http.MethodGet, "/healthz/detailed", 10)
+	if entries := len(hook.AllEntries()); entries != 0 {
+		t.Fatalf("Expected the health checks not to be logged, got %d", entries)
+	}
+
+	hook = serveAccessLoggerTestRequests(t, verbosity, http.MethodGet, "/connection", 1)
+	if entries := len(hook.AllEntries()); entries != 1 {
+		t.Fatalf("Expected the listing to be logged, got %d", entries)
+	}
+}
@@ -0,0 +1,163 @@
+package openmetrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+var posInf = math.Inf(1)
+
+// escaper escapes the help texts and the label values
+var escaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// WriteMetricFamilies writes the metric families in the OpenMetrics text
+// format.  The exemplars of the histogram buckets are looked up in exemplars
+// (which can be nil).
+func WriteMetricFamilies(out io.Writer, families []*dto.MetricFamily, exemplars *ExemplarStore) error {
+	w := bufio.NewWriter(out)
+
+	for _, family := range families {
+		writeMetricFamily(w, family, exemplars)
+	}
+
+	w.WriteString("# EOF\n")
+
+	return w.Flush()
+}
+
+func writeMetricFamily(w *bufio.Writer, family *dto.MetricFamily, exemplars *ExemplarStore) {
+	name := family.GetName()
+	metricType := "unknown"
+
+	switch family.GetType() {
+	case dto.MetricType_COUNTER:
+		// The name of a counter family does not include the _total suffix
+		name = strings.TrimSuffix(name, "_total")
+		metricType = "counter"
+	case dto.MetricType_GAUGE:
+		metricType = "gauge"
+	case dto.MetricType_SUMMARY:
+		metricType = "summary"
+	case dto.MetricType_HISTOGRAM:
+		metricType = "histogram"
+	}
+
+	w.WriteString("# TYPE " + name + " " + metricType + "\n")
+	if family.Help != nil {
+		w.WriteString("# HELP " + name + " " + escaper.Replace(family.GetHelp()) + "\n")
+	}
+
+	for _, metric := range family.Metric {
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			writeSample(w, name+"_total", metric, "", "", metric.GetCounter().GetValue())
+		case dto.MetricType_GAUGE:
+			writeSample(w, name, metric, "", "", metric.GetGauge().GetValue())
+		case dto.MetricType_SUMMARY:
+			writeSummary(w, name, metric)
+		case dto.MetricType_HISTOGRAM:
+			writeHistogram(w, name, metric, exemplars)
+		default:
+			writeSample(w, name, metric, "", "", metric.GetUntyped().GetValue())
+		}
+	}
+}
+
+func writeSummary(w *bufio.Writer, name string, metric *dto.Metric) {
+	summary := metric.GetSummary()
+	for _, quantile := range summary.Quantile {
+		writeSample(w, name, metric, "quantile", formatFloat(quantile.GetQuantile()), quantile.GetValue())
+	}
+	writeSample(w, name+"_sum", metric, "", "", summary.GetSampleSum())
+	writeSample(w, name+"_count", metric, "", "", float64(summary.GetSampleCount()))
+}
+
+func writeHistogram(w *bufio.Writer, name string, metric *dto.Metric, exemplars *ExemplarStore) {
+	histogram := metric.GetHistogram()
+	key := seriesKey(name, labelPairsToMap(metric.Label))
+
+	writeBucket := func(upperBound float64, cumulativeCount uint64) {
+		writeSampleWithoutNewline(w, name+"_bucket", metric, "le", formatBucketUpperBound(upperBound), float64(cumulativeCount))
+		if exemplar, exists := exemplars.get(key, upperBound); exists {
+			writeExemplar(w, exemplar)
+		}
+		w.WriteString("\n")
+	}
+
+	infBucketWritten := false
+	for _, bucket := range histogram.Bucket {
+		writeBucket(bucket.GetUpperBound(), bucket.GetCumulativeCount())
+		infBucketWritten = infBucketWritten || math.IsInf(bucket.GetUpperBound(), 1)
+	}
+
+	// The +Inf bucket is mandatory in OpenMetrics
+	if !infBucketWritten {
+		writeBucket(posInf, histogram.GetSampleCount())
+	}
+
+	writeSample(w, name+"_sum", metric, "", "", histogram.GetSampleSum())
+	writeSample(w, name+"_count", metric, "", "", float64(histogram.GetSampleCount()))
+}
+
+func writeSample(w *bufio.Writer, name string, metric *dto.Metric, extraLabelName string, extraLabelValue string, value float64) {
+	writeSampleWithoutNewline(w, name, metric, extraLabelName, extraLabelValue, value)
+	w.WriteString("\n")
+}
+
+func writeSampleWithoutNewline(w *bufio.Writer, name string, metric *dto.Metric, extraLabelName string, extraLabelValue string, value float64) {
+	w.WriteString(name)
+
+	labelPairs := make([]string, 0, len(metric.Label)+1)
+	for _, labelPair := range metric.Label {
+		labelPairs = append(labelPairs, formatLabelPair(labelPair.GetName(), labelPair.GetValue()))
+	}
+	if extraLabelName != "" {
+		labelPairs = append(labelPairs, formatLabelPair(extraLabelName, extraLabelValue))
+	}
+	if len(labelPairs) > 0 {
+		w.WriteString("{" + strings.Join(labelPairs, ",") + "}")
+	}
+
+	w.WriteString(" " + formatFloat(value))
+
+	if metric.TimestampMs != nil {
+		w.WriteString(" " + formatFloat(float64(metric.GetTimestampMs())/1000))
+	}
+}
+
+func writeExemplar(w *bufio.Writer, exemplar Exemplar) {
+	w.WriteString(" # {" + formatLabelPair("trace_id", exemplar.TraceID) + "} " + formatFloat(exemplar.Value))
+	w.WriteString(" " + formatFloat(float64(exemplar.Timestamp.UnixNano())/1e9))
+}
+
+func formatLabelPair(name string, value string) string {
+	return name + `="` + escaper.Replace(value) + `"`
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
+
+// formatBucketUpperBound returns the canonical representation of the upper
+// bound of a bucket (the integral upper bounds include a decimal point)
+func formatBucketUpperBound(f float64) string {
+	formatted := formatFloat(f)
+	if math.IsInf(f, 0) || math.IsNaN(f) || strings.ContainsAny(formatted, ".e") {
+		return formatted
+	}
+	return formatted + ".0"
+}
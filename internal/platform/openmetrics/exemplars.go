@@ -0,0 +1,135 @@
+package openmetrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Exemplar links an observation of a histogram to the trace it was made in
+type Exemplar struct {
+	TraceID   string
+	Value     float64
+	Timestamp time.Time
+}
+
+// ExemplarStore keeps the latest exemplar observed in each bucket of the
+// histograms.  The exemplars are only exposed in the OpenMetrics format.
+type ExemplarStore struct {
+	// exemplars holds the exemplars by series and bucket upper bound
+	exemplars map[string]map[float64]Exemplar
+	sync.Mutex
+}
+
+func NewExemplarStore() *ExemplarStore {
+	return &ExemplarStore{
+		exemplars: make(map[string]map[float64]Exemplar),
+	}
+}
+
+// DefaultExemplarStore holds the exemplars of the histograms registered with
+// the default prometheus registry
+var DefaultExemplarStore = NewExemplarStore()
+
+func (s *ExemplarStore) record(key string, upperBound float64, exemplar Exemplar) {
+	s.Lock()
+	defer s.Unlock()
+
+	bucketExemplars, exists := s.exemplars[key]
+	if !exists {
+		bucketExemplars = make(map[float64]Exemplar)
+		s.exemplars[key] = bucketExemplars
+	}
+
+	bucketExemplars[upperBound] = exemplar
+}
+
+func (s *ExemplarStore) get(key string, upperBound float64) (Exemplar, bool) {
+	if s == nil {
+		return Exemplar{}, false
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	exemplar, exists := s.exemplars[key][upperBound]
+	return exemplar, exists
+}
+
+// seriesKey identifies a series of a metric family.  The label pairs are
+// sorted by name so that the key does not depend on the order of the labels.
+func seriesKey(name string, labels map[string]string) string {
+	labelNames := make([]string, 0, len(labels))
+	for labelName := range labels {
+		labelNames = append(labelNames, labelName)
+	}
+	sort.Strings(labelNames)
+
+	var key strings.Builder
+	key.WriteString(name)
+	for _, labelName := range labelNames {
+		key.WriteString("\xff" + labelName + "=" + labels[labelName])
+	}
+	return key.String()
+}
+
+func labelPairsToMap(labelPairs []*dto.LabelPair) map[string]string {
+	labels := make(map[string]string, len(labelPairs))
+	for _, labelPair := range labelPairs {
+		labels[labelPair.GetName()] = labelPair.GetValue()
+	}
+	return labels
+}
+
+// HistogramVec is a prometheus histogram that can keep the trace id of an
+// observation as the exemplar of the bucket the observation falls into
+type HistogramVec struct {
+	*prometheus.HistogramVec
+	name      string
+	buckets   []float64
+	exemplars *ExemplarStore
+}
+
+// NewHistogramVec creates a histogram registered with the default prometheus
+// registry, whose exemplars are kept in the DefaultExemplarStore
+func NewHistogramVec(opts prometheus.HistogramOpts, labelNames []string) *HistogramVec {
+	buckets := opts.Buckets
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+
+	return &HistogramVec{
+		HistogramVec: promauto.NewHistogramVec(opts, labelNames),
+		name:         prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name),
+		buckets:      buckets,
+		exemplars:    DefaultExemplarStore,
+	}
+}
+
+// ObserveWithTraceID adds an observation to the histogram.  If traceID is
+// set, the observation replaces the exemplar of its bucket.
+func (h *HistogramVec) ObserveWithTraceID(labels prometheus.Labels, value float64, traceID string) {
+	h.With(labels).Observe(value)
+
+	if traceID == "" {
+		return
+	}
+
+	h.exemplars.record(seriesKey(h.name, labels), bucketUpperBound(h.buckets, value),
+		Exemplar{TraceID: traceID, Value: value, Timestamp: time.Now()})
+}
+
+// bucketUpperBound returns the upper bound of the bucket that value falls into
+func bucketUpperBound(buckets []float64, value float64) float64 {
+	for _, upperBound := range buckets {
+		if value <= upperBound {
+			return upperBound
+		}
+	}
+	return posInf
+}
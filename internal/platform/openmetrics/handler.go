@@ -0,0 +1,84 @@
+package openmetrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	OPENMETRICS_MEDIA_TYPE   = "application/openmetrics-text"
+	OPENMETRICS_CONTENT_TYPE = OPENMETRICS_MEDIA_TYPE + "; version=1.0.0; charset=utf-8"
+)
+
+// Handler returns the handler of the /metrics endpoint.  The metrics
+// registered with the default prometheus registry are exported in the
+// OpenMetrics format (along with the exemplars of the histograms) when the
+// scraper prefers it, and in the legacy prometheus format otherwise.
+func Handler() http.Handler {
+	return newHandler(prometheus.DefaultGatherer, DefaultExemplarStore, promhttp.Handler())
+}
+
+func newHandler(gatherer prometheus.Gatherer, exemplars *ExemplarStore, legacyHandler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !prefersOpenMetrics(req.Header.Get("Accept")) {
+			legacyHandler.ServeHTTP(w, req)
+			return
+		}
+
+		families, err := gatherer.Gather()
+		if err != nil {
+			logger.Log.WithFields(logrus.Fields{"error": err}).Error("Unable to gather the metrics")
+			http.Error(w, "An error has occurred while gathering the metrics: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", OPENMETRICS_CONTENT_TYPE)
+		w.WriteHeader(http.StatusOK)
+
+		if err := WriteMetricFamilies(w, families, exemplars); err != nil {
+			logger.Log.WithFields(logrus.Fields{"error": err}).Error("Unable to write the metrics")
+		}
+	})
+}
+
+// prefersOpenMetrics reports whether the Accept header of a scrape gives the
+// OpenMetrics format at least the same quality as any other format
+func prefersOpenMetrics(accept string) bool {
+	openMetricsQuality := 0.0
+	otherQuality := 0.0
+
+	for _, mediaRange := range strings.Split(accept, ",") {
+		params := strings.Split(mediaRange, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		if mediaType == "" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range params[1:] {
+			keyValue := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(keyValue) != 2 || strings.ToLower(keyValue[0]) != "q" {
+				continue
+			}
+			if q, err := strconv.ParseFloat(keyValue[1], 64); err == nil {
+				quality = q
+			}
+		}
+
+		if mediaType == OPENMETRICS_MEDIA_TYPE {
+			if quality > openMetricsQuality {
+				openMetricsQuality = quality
+			}
+		} else if quality > otherQuality {
+			otherQuality = quality
+		}
+	}
+
+	return openMetricsQuality > 0 && openMetricsQuality >= otherQuality
+}
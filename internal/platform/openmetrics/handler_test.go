@@ -0,0 +1,155 @@
+package openmetrics
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const testTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+func newTestHandler() (http.Handler, *HistogramVec) {
+	registry := prometheus.NewRegistry()
+	exemplars := NewExemplarStore()
+
+	histogram := &HistogramVec{
+		HistogramVec: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "test_latency_seconds",
+			Help:    "Latency of the \"test\" requests",
+			Buckets: []float64{0.1, 1},
+		}, []string{"account"}),
+		name:      "test_latency_seconds",
+		buckets:   []float64{0.1, 1},
+		exemplars: exemplars,
+	}
+
+	counter := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "test_requests_total",
+		Help: "Number of test requests",
+	})
+	counter.Add(2)
+
+	registry.MustRegister(histogram.HistogramVec, counter)
+
+	return newHandler(registry, exemplars, promhttp.HandlerFor(registry, promhttp.HandlerOpts{})), histogram
+}
+
+func scrape(t *testing.T, handler http.Handler, accept string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("GET", "/metrics", nil)
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+	req.Header.Set("Accept", accept)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestHandlerExportsOpenMetricsWithExemplars(t *testing.T) {
+	handler, histogram := newTestHandler()
+
+	histogram.ObserveWithTraceID(prometheus.Labels{"account": "1234"}, 0.05, testTraceID)
+	histogram.ObserveWithTraceID(prometheus.Labels{"account": "1234"}, 0.5, "")
+
+	rr := scrape(t, handler, "application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5,*/*;q=0.1")
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	if contentType := rr.Header().Get("Content-Type"); contentType != OPENMETRICS_CONTENT_TYPE {
+		t.Fatalf("Expected the content type to be %q, got %q", OPENMETRICS_CONTENT_TYPE, contentType)
+	}
+
+	body, _ := ioutil.ReadAll(rr.Body)
+	lines := strings.Split(string(body), "\n")
+
+	expectedLines := []string{
+		"# TYPE test_latency_seconds histogram",
+		`# HELP test_latency_seconds Latency of the \"test\" requests`,
+		`test_latency_seconds_bucket{account="1234",le="1.0"} 2`,
+		`test_latency_seconds_bucket{account="1234",le="+Inf"} 2`,
+		`test_latency_seconds_count{account="1234"} 2`,
+		"# TYPE test_requests counter",
+		"test_requests_total 2",
+	}
+	for _, expectedLine := range expectedLines {
+		if !containsLine(lines, expectedLine) {
+			t.Fatalf("Expected the line %q in the metrics, got:\n%s", expectedLine, body)
+		}
+	}
+
+	exemplarPrefix := fmt.Sprintf(`test_latency_seconds_bucket{account="1234",le="0.1"} 1 # {trace_id="%s"} 0.05 `, testTraceID)
+	if !containsLinePrefix(lines, exemplarPrefix) {
+		t.Fatalf("Expected the bucket to carry the exemplar %q, got:\n%s", exemplarPrefix, body)
+	}
+
+	if !strings.HasSuffix(string(body), "# EOF\n") {
+		t.Fatalf("Expected the metrics to end with the EOF marker, got:\n%s", body)
+	}
+}
+
+func TestHandlerFallsBackToThePrometheusFormat(t *testing.T) {
+	handler, histogram := newTestHandler()
+
+	histogram.ObserveWithTraceID(prometheus.Labels{"account": "1234"}, 0.05, testTraceID)
+
+	for _, accept := range []string{"", "text/plain;version=0.0.4", "application/openmetrics-text;q=0.2,text/plain;q=0.5"} {
+		rr := scrape(t, handler, accept)
+
+		if contentType := rr.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain") {
+			t.Fatalf("Expected the prometheus format for %q, got %q", accept, contentType)
+		}
+
+		body, _ := ioutil.ReadAll(rr.Body)
+		if strings.Contains(string(body), testTraceID) || strings.Contains(string(body), "# EOF") {
+			t.Fatalf("Expected the prometheus format for %q, got:\n%s", accept, body)
+		}
+	}
+}
+
+func TestPrefersOpenMetrics(t *testing.T) {
+	tests := []struct {
+		accept   string
+		expected bool
+	}{
+		{"application/openmetrics-text", true},
+		{"application/openmetrics-text; version=0.0.1; q=0.75, text/plain; version=0.0.4; q=0.5", true},
+		{"text/plain;q=0.5, application/openmetrics-text;q=0.5", true},
+		{"application/openmetrics-text;q=0", false},
+		{"text/plain, application/openmetrics-text;q=0.9", false},
+		{"*/*", false},
+		{"", false},
+	}
+
+	for _, tc := range tests {
+		if actual := prefersOpenMetrics(tc.accept); actual != tc.expected {
+			t.Errorf("Expected %v for %q, got %v", tc.expected, tc.accept, actual)
+		}
+	}
+}
+
+func containsLine(lines []string, expected string) bool {
+	for _, line := range lines {
+		if line == expected {
+			return true
+		}
+	}
+	return false
+}
+
+func containsLinePrefix(lines []string, prefix string) bool {
+	for _, line := range lines {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
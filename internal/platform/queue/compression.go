@@ -0,0 +1,37 @@
+package queue
+
+import (
+	"fmt"
+
+	kafka "github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/gzip"
+	"github.com/segmentio/kafka-go/lz4"
+	"github.com/segmentio/kafka-go/snappy"
+)
+
+const (
+	NoCompression = "none"
+)
+
+// compressionCodecs maps the supported compression names to their codecs.
+// zstd is only available when built with cgo (see compression_zstd.go).
+var compressionCodecs = map[string]kafka.CompressionCodec{
+	"gzip":   gzip.NewCompressionCodec(),
+	"snappy": snappy.NewCompressionCodec(),
+	"lz4":    lz4.NewCompressionCodec(),
+}
+
+// getCompressionCodec returns the codec for the compression name.  A nil codec
+// is returned for "none" (or an empty name).
+func getCompressionCodec(name string) (kafka.CompressionCodec, error) {
+	if name == "" || name == NoCompression {
+		return nil, nil
+	}
+
+	codec, exists := compressionCodecs[name]
+	if !exists {
+		return nil, fmt.Errorf("unsupported kafka compression codec: %s", name)
+	}
+
+	return codec, nil
+}
@@ -0,0 +1,12 @@
+//go:build cgo
+// +build cgo
+
+package queue
+
+import (
+	"github.com/segmentio/kafka-go/zstd"
+)
+
+func init() {
+	compressionCodecs["zstd"] = zstd.NewCompressionCodec()
+}
@@ -1,10 +1,49 @@
 package queue
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
 	kafka "github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
 )
 
+// MessageReader is implemented by the kafka reader the messages are consumed
+// with
+type MessageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// MessageHandler handles a message consumed by ConsumeMessages.  The message
+// is only committed if the handler returns nil.
+type MessageHandler func(ctx context.Context, m kafka.Message) error
+
+// HandlerError is returned by ConsumeMessages when the handler of a message
+// failed.  The message has not been committed.
+type HandlerError struct {
+	Message kafka.Message
+	Err     error
+}
+
+func (e HandlerError) Error() string {
+	return fmt.Sprintf("unable to handle the message from %s-%d [%d]: %s", e.Message.Topic, e.Message.Partition, e.Message.Offset, e.Err)
+}
+
+// rebalanceCommitAttempts is the number of times the commit of a message is
+// attempted while the consumer group is rebalancing
+const rebalanceCommitAttempts = 5
+
+// rebalanceRetryInterval is the delay between these attempts
+var rebalanceRetryInterval = 200 * time.Millisecond
+
+// maxUncommittedMessages bounds the number of handled messages that are
+// remembered because they could not be committed during a rebalance
+const maxUncommittedMessages = 1024
+
 func StartConsumer(cfg *ConsumerConfig) *kafka.Reader {
 	logger.Log.Info("Starting a new kafka consumer...")
 	logger.Log.Info("Kafka consumer configuration: ", cfg)
@@ -14,9 +53,144 @@ func StartConsumer(cfg *ConsumerConfig) *kafka.Reader {
 		Topic:       cfg.Topic,
 		GroupID:     cfg.GroupID,
 		StartOffset: cfg.ConsumerOffset,
+		MaxWait:     cfg.ReadTimeout,
 	})
 
 	logger.Log.Info("Kafka consumer config: ", r.Config())
 
 	return r
 }
+
+// ConsumeMessages passes the messages fetched by the reader to the handler,
+// one at a time, and commits each message once its handler has succeeded.
+// When ctx is done, the loop stops fetching; a message that is being handled
+// is still handled (the handler is given ctx and decides whether it can
+// complete) and committed if its handler succeeds, before the loop returns
+// nil.  The commit itself is bounded by commitTimeout rather than by ctx.
+//
+// A message is never committed if its handler fails.  Since committing a later
+// message would commit the failed one too, the loop stops and returns a
+// HandlerError so that the message is consumed again.
+//
+// When the consumer group rebalances, the commit of the message that was just
+// handled fails until the reader has joined the new generation, so it is
+// retried.  If it still fails, the loop keeps going: the partitions are
+// consumed again from their last committed offset once they are reassigned.
+// The messages that were handled but not committed are remembered, and are
+// only committed (not handled a second time) when they are fetched again.
+func ConsumeMessages(ctx context.Context, r MessageReader, commitTimeout time.Duration, handler MessageHandler) error {
+	uncommitted := make(uncommittedMessages)
+
+	for {
+		m, err := r.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				logger.Log.Debug("Kafka consumer shutting down")
+				return nil
+			}
+			return err
+		}
+
+		if uncommitted.contains(m) {
+			logger.Log.WithFields(logrus.Fields{"topic": m.Topic, "partition": m.Partition, "offset": m.Offset}).
+				Info("The message was already handled before a rebalance...only committing it")
+		} else if err := handler(ctx, m); err != nil {
+			logger.Log.WithFields(logrus.Fields{"topic": m.Topic, "partition": m.Partition, "offset": m.Offset, "error": err}).
+				Warn("Unable to handle the message...not committing it")
+			return HandlerError{Message: m, Err: err}
+		}
+
+		if err := commitMessage(r, commitTimeout, m); err != nil {
+			if !isRebalanceError(err) {
+				logger.Log.WithFields(logrus.Fields{"topic": m.Topic, "partition": m.Partition, "offset": m.Offset, "error": err}).
+					Error("Unable to commit the message")
+				return err
+			}
+
+			logger.Log.WithFields(logrus.Fields{"topic": m.Topic, "partition": m.Partition, "offset": m.Offset, "error": err}).
+				Warn("Unable to commit the message while the consumer group is rebalancing...it will be committed when it is consumed again")
+			uncommitted.add(m)
+		} else {
+			uncommitted.committed(m)
+		}
+
+		if ctx.Err() != nil {
+			logger.Log.Debug("Kafka consumer shutting down after committing the in-flight message")
+			return nil
+		}
+	}
+}
+
+// commitMessage commits the message even if the consumer is shutting down.
+// The commit is retried while the consumer group is rebalancing.
+func commitMessage(r MessageReader, timeout time.Duration, m kafka.Message) error {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := r.CommitMessages(ctx, m)
+		if err == nil || !isRebalanceError(err) || attempt == rebalanceCommitAttempts {
+			return err
+		}
+
+		select {
+		case <-time.After(rebalanceRetryInterval):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// isRebalanceError returns true for the errors returned when committing to a
+// generation of the consumer group that has ended
+func isRebalanceError(err error) bool {
+	var kafkaErr kafka.Error
+	if !errors.As(err, &kafkaErr) {
+		return false
+	}
+
+	switch kafkaErr {
+	case kafka.RebalanceInProgress, kafka.IllegalGeneration, kafka.UnknownMemberId, kafka.NotCoordinatorForGroup:
+		return true
+	}
+	return false
+}
+
+type messagePosition struct {
+	topic     string
+	partition int
+	offset    int64
+}
+
+// uncommittedMessages remembers the messages that were handled but could not
+// be committed because of a rebalance, by their position in the topic
+type uncommittedMessages map[messagePosition]struct{}
+
+func (u uncommittedMessages) contains(m kafka.Message) bool {
+	_, ok := u[messagePosition{m.Topic, m.Partition, m.Offset}]
+	return ok
+}
+
+func (u uncommittedMessages) add(m kafka.Message) {
+	if len(u) >= maxUncommittedMessages {
+		// The partitions of these messages were most likely assigned to
+		// another consumer
+		for position := range u {
+			delete(u, position)
+		}
+	}
+	u[messagePosition{m.Topic, m.Partition, m.Offset}] = struct{}{}
+}
+
+// committed forgets the messages of the partition up to the committed one
+func (u uncommittedMessages) committed(m kafka.Message) {
+	for position := range u {
+		if position.topic == m.Topic && position.partition == m.Partition && position.offset <= m.Offset {
+			delete(u, position)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	kafka "github.com/segmentio/kafka-go"
+)
+
+// fakeMessageReader hands out the messages sent to its channel, and blocks
+// until ctx is done once there are none left
+type fakeMessageReader struct {
+	msgs chan kafka.Message
+
+	lock      sync.Mutex
+	committed []kafka.Message
+	commitErr error
+
+	// commitHook, when set, can fail a commit
+	commitHook func(m kafka.Message) error
+}
+
+func newFakeMessageReader(msgs ...kafka.Message) *fakeMessageReader {
+	r := &fakeMessageReader{msgs: make(chan kafka.Message, len(msgs))}
+	for _, m := range msgs {
+		r.msgs <- m
+	}
+	return r
+}
+
+func (r *fakeMessageReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case m := <-r.msgs:
+		return m, nil
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	}
+}
+
+func (r *fakeMessageReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	// The commit must not be abandoned because the consumer is shutting down
+	if ctx.Err() != nil {
+		r.commitErr = ctx.Err()
+		return ctx.Err()
+	}
+
+	if r.commitHook != nil {
+		for _, m := range msgs {
+			if err := r.commitHook(m); err != nil {
+				return err
+			}
+		}
+	}
+
+	r.committed = append(r.committed, msgs...)
+	return nil
+}
+
+func (r *fakeMessageReader) getCommitted() []kafka.Message {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]kafka.Message{}, r.committed...)
+}
+
+func consumeInBackground(ctx context.Context, r MessageReader, handler MessageHandler) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- ConsumeMessages(ctx, r, time.Second, handler)
+	}()
+	return done
+}
+
+func waitForConsumer(t *testing.T, done <-chan error) error {
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for the consumer to return")
+		return nil
+	}
+}
+
+func TestConsumeMessagesCommitsEachHandledMessage(t *testing.T) {
+	r := newFakeMessageReader(kafka.Message{Offset: 1}, kafka.Message{Offset: 2}, kafka.Message{Offset: 3})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var handled []int64
+	done := consumeInBackground(ctx, r, func(ctx context.Context, m kafka.Message) error {
+		handled = append(handled, m.Offset)
+		if len(handled) == 3 {
+			cancel()
+		}
+		return nil
+	})
+
+	if err := waitForConsumer(t, done); err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	committed := r.getCommitted()
+	if len(handled) != 3 || len(committed) != 3 {
+		t.Fatalf("Expected the 3 messages to be handled and committed, got %d handled and %d committed", len(handled), len(committed))
+	}
+
+	for i, m := range committed {
+		if m.Offset != int64(i+1) {
+			t.Fatalf("Expected the messages to be committed in order, got %+v", committed)
+		}
+	}
+}
+
+func TestConsumeMessagesFinishesTheInFlightMessageOnShutdown(t *testing.T) {
+	r := newFakeMessageReader(kafka.Message{Offset: 1}, kafka.Message{Offset: 2})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handled := 0
+
+	done := consumeInBackground(ctx, r, func(ctx context.Context, m kafka.Message) error {
+		close(started)
+		<-release
+		handled++
+		return nil
+	})
+
+	<-started
+	cancel()
+
+	select {
+	case <-done:
+		t.Fatalf("Expected the consumer to wait for the in-flight message to be handled")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+
+	if err := waitForConsumer(t, done); err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	if handled != 1 {
+		t.Fatalf("Expected only the in-flight message to be handled, got %d", handled)
+	}
+
+	committed := r.getCommitted()
+	if len(committed) != 1 || committed[0].Offset != 1 {
+		t.Fatalf("Expected the in-flight message to be committed, got %+v (commit error: %v)", committed, r.commitErr)
+	}
+}
+
+func TestConsumeMessagesDoesNotCommitAFailedMessageOnShutdown(t *testing.T) {
+	r := newFakeMessageReader(kafka.Message{Offset: 1}, kafka.Message{Offset: 2})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	handlerErr := errors.New("the connection went away")
+	done := consumeInBackground(ctx, r, func(ctx context.Context, m kafka.Message) error {
+		cancel()
+		<-ctx.Done()
+		return handlerErr
+	})
+
+	err := waitForConsumer(t, done)
+
+	handlerError, ok := err.(HandlerError)
+	if !ok || handlerError.Err != handlerErr || handlerError.Message.Offset != 1 {
+		t.Fatalf("Expected a HandlerError for the in-flight message, got %v", err)
+	}
+
+	if committed := r.getCommitted(); len(committed) != 0 {
+		t.Fatalf("Expected the failed message not to be committed, got %+v", committed)
+	}
+}
+
+func TestConsumeMessagesStopsAtTheFirstFailedMessage(t *testing.T) {
+	r := newFakeMessageReader(kafka.Message{Offset: 1}, kafka.Message{Offset: 2}, kafka.Message{Offset: 3})
+
+	done := consumeInBackground(context.Background(), r, func(ctx context.Context, m kafka.Message) error {
+		if m.Offset == 2 {
+			return errors.New("unable to handle the message")
+		}
+		return nil
+	})
+
+	if _, ok := waitForConsumer(t, done).(HandlerError); !ok {
+		t.Fatalf("Expected a HandlerError")
+	}
+
+	// Committing the third message would commit the failed one too
+	committed := r.getCommitted()
+	if len(committed) != 1 || committed[0].Offset != 1 {
+		t.Fatalf("Expected only the first message to be committed, got %+v", committed)
+	}
+}
+
+func TestConsumeMessagesRetriesTheCommitDuringARebalance(t *testing.T) {
+	defer func(interval time.Duration) { rebalanceRetryInterval = interval }(rebalanceRetryInterval)
+	rebalanceRetryInterval = time.Millisecond
+
+	r := newFakeMessageReader(kafka.Message{Offset: 1}, kafka.Message{Offset: 2})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	attempts := 0
+	r.commitHook = func(m kafka.Message) error {
+		attempts++
+		if attempts <= 2 {
+			return kafka.RebalanceInProgress
+		}
+		return nil
+	}
+
+	handled := 0
+	done := consumeInBackground(ctx, r, func(ctx context.Context, m kafka.Message) error {
+		handled++
+		if handled == 2 {
+			cancel()
+		}
+		return nil
+	})
+
+	if err := waitForConsumer(t, done); err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	committed := r.getCommitted()
+	if handled != 2 || len(committed) != 2 || committed[0].Offset != 1 || committed[1].Offset != 2 {
+		t.Fatalf("Expected the messages to be committed once the rebalance was over, got %+v", committed)
+	}
+}
+
+func TestConsumeMessagesDoesNotLoseOrReprocessMessagesAcrossARebalance(t *testing.T) {
+	defer func(interval time.Duration) { rebalanceRetryInterval = interval }(rebalanceRetryInterval)
+	rebalanceRetryInterval = time.Millisecond
+
+	r := &fakeMessageReader{msgs: make(chan kafka.Message, 10)}
+	for offset := int64(1); offset <= 3; offset++ {
+		r.msgs <- kafka.Message{Topic: "jobs", Partition: 0, Offset: offset}
+	}
+
+	// The generation ends while the second message is being handled, so
+	// none of the attempts to commit it succeed.  The partition is then
+	// reassigned, and the new generation consumes it again from the last
+	// committed offset.
+	failedCommits := 0
+	r.commitHook = func(m kafka.Message) error {
+		if m.Offset != 2 || failedCommits == rebalanceCommitAttempts {
+			return nil
+		}
+
+		failedCommits++
+		if failedCommits == rebalanceCommitAttempts {
+			for len(r.msgs) > 0 {
+				<-r.msgs
+			}
+			for offset := int64(2); offset <= 4; offset++ {
+				r.msgs <- kafka.Message{Topic: "jobs", Partition: 0, Offset: offset}
+			}
+		}
+		return kafka.IllegalGeneration
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var handled []int64
+	done := consumeInBackground(ctx, r, func(ctx context.Context, m kafka.Message) error {
+		handled = append(handled, m.Offset)
+		if m.Offset == 4 {
+			cancel()
+		}
+		return nil
+	})
+
+	if err := waitForConsumer(t, done); err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	// The redelivered message is not handled a second time
+	if len(handled) != 4 || handled[0] != 1 || handled[1] != 2 || handled[2] != 3 || handled[3] != 4 {
+		t.Fatalf("Expected each message to be handled once, got %v", handled)
+	}
+
+	var committedOffsets []int64
+	for _, m := range r.getCommitted() {
+		committedOffsets = append(committedOffsets, m.Offset)
+	}
+
+	if len(committedOffsets) != 4 || committedOffsets[0] != 1 || committedOffsets[1] != 2 ||
+		committedOffsets[2] != 3 || committedOffsets[3] != 4 {
+		t.Fatalf("Expected every message to be committed in order, got %v", committedOffsets)
+	}
+}
+
+func TestConsumeMessagesStopsOnACommitErrorOutsideARebalance(t *testing.T) {
+	r := newFakeMessageReader(kafka.Message{Offset: 1}, kafka.Message{Offset: 2})
+
+	commitErr := errors.New("the broker went away")
+	r.commitHook = func(m kafka.Message) error {
+		return commitErr
+	}
+
+	handled := 0
+	done := consumeInBackground(context.Background(), r, func(ctx context.Context, m kafka.Message) error {
+		handled++
+		return nil
+	})
+
+	if err := waitForConsumer(t, done); err != commitErr {
+		t.Fatalf("Expected the commit error to be returned, got %v", err)
+	}
+
+	if handled != 1 {
+		t.Fatalf("Expected the consumer to stop after the first message, got %d handled", handled)
+	}
+}
@@ -1,22 +1,104 @@
 package queue
 
 import (
+	"context"
+	"fmt"
+	"time"
+
 	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
 	kafka "github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
 )
 
+type WriteTimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e WriteTimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s writing messages to kafka", e.Timeout)
+}
+
+type CloseTimeoutError struct {
+	Unflushed int64
+}
+
+func (e CloseTimeoutError) Error() string {
+	return fmt.Sprintf("timed out closing the kafka producer with %d messages left unflushed", e.Unflushed)
+}
+
 func StartProducer(cfg *ProducerConfig) *kafka.Writer {
 	logger.Log.Info("Starting a new Kafka producer..")
 	logger.Log.Info("Kafka producer configuration: ", cfg)
 
+	codec, err := getCompressionCodec(cfg.Compression)
+	if err != nil {
+		logger.Log.Fatal("Unable to configure the Kafka producer: ", err)
+	}
+
+	if codec != nil {
+		logger.Log.Info("Kafka producer compression codec: ", codec.Name())
+	} else {
+		logger.Log.Info("Kafka producer compression codec: ", NoCompression)
+	}
+
+	// Without batching, each message is written as soon as it is produced
+	batchSize := 1
+	if cfg.Batching {
+		batchSize = cfg.BatchSize
+		logger.Log.Infof("Kafka producer batching: up to %d messages, lingering %s", batchSize, cfg.BatchTimeout)
+	}
+
 	w := kafka.NewWriter(kafka.WriterConfig{
-		Brokers:    cfg.Brokers,
-		Topic:      cfg.Topic,
-		BatchSize:  cfg.BatchSize,
-		BatchBytes: cfg.BatchBytes,
+		Brokers:          cfg.Brokers,
+		Topic:            cfg.Topic,
+		BatchSize:        batchSize,
+		BatchTimeout:     cfg.BatchTimeout,
+		BatchBytes:       cfg.BatchBytes,
+		CompressionCodec: codec,
+		ReadTimeout:      cfg.WriteTimeout,
+		WriteTimeout:     cfg.WriteTimeout,
 	})
 
 	logger.Log.Info("Producing messages to topic: ", cfg.Topic)
 
 	return w
 }
+
+// WriteMessages writes the messages using the producer.  The writer's own
+// timeouts do not cover every step of a write (e.g. looking up the partitions
+// of the topic), so the write is abandoned with a WriteTimeoutError if it has
+// not completed within the timeout.
+func WriteMessages(ctx context.Context, w *kafka.Writer, timeout time.Duration, msgs ...kafka.Message) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	err := w.WriteMessages(ctx, msgs...)
+	if err == context.DeadlineExceeded {
+		return WriteTimeoutError{Timeout: timeout}
+	}
+
+	return err
+}
+
+// CloseProducer flushes the messages buffered by the producer and closes it.
+// If ctx is done before the flush completes, the number of messages that
+// could not be flushed is logged and a CloseTimeoutError is returned.
+func CloseProducer(ctx context.Context, w *kafka.Writer) error {
+	closed := make(chan error, 1)
+	go func() {
+		closed <- w.Close()
+	}()
+
+	select {
+	case err := <-closed:
+		return err
+	case <-ctx.Done():
+		stats := w.Stats()
+		logger.Log.WithFields(logrus.Fields{"topic": stats.Topic, "unflushed": stats.QueueLength}).
+			Error("Timed out flushing messages while closing the Kafka producer")
+		return CloseTimeoutError{Unflushed: stats.QueueLength}
+	}
+}
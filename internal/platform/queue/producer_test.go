@@ -0,0 +1,258 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+
+	kafka "github.com/segmentio/kafka-go"
+)
+
+func init() {
+	logger.InitLogger()
+}
+
+// startNonResponsiveBroker accepts connections but never responds to any
+// requests
+func startNonResponsiveBroker(t *testing.T) (string, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to start the fake broker: %v", err)
+	}
+
+	var lock sync.Mutex
+	var conns []net.Conn
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			lock.Lock()
+			conns = append(conns, conn)
+			lock.Unlock()
+		}
+	}()
+
+	stop := func() {
+		listener.Close()
+		lock.Lock()
+		defer lock.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}
+
+	return listener.Addr().String(), stop
+}
+
+func TestWriteMessagesTimesOutWithNonResponsiveBroker(t *testing.T) {
+	broker, stopBroker := startNonResponsiveBroker(t)
+	defer stopBroker()
+
+	writeTimeout := 200 * time.Millisecond
+
+	w := StartProducer(&ProducerConfig{
+		Brokers:      []string{broker},
+		Topic:        "platform.receptor-controller.responses",
+		BatchSize:    1,
+		BatchBytes:   1048576,
+		Compression:  NoCompression,
+		WriteTimeout: writeTimeout,
+	})
+
+	start := time.Now()
+	err := WriteMessages(context.Background(), w, writeTimeout, kafka.Message{Value: []byte("response")})
+	elapsed := time.Since(start)
+
+	if err != (WriteTimeoutError{Timeout: writeTimeout}) {
+		t.Fatalf("Expected %v, got %v", WriteTimeoutError{Timeout: writeTimeout}, err)
+	}
+
+	if elapsed > 2*writeTimeout {
+		t.Fatalf("Expected the write to give up within %s, but it took %s", writeTimeout, elapsed)
+	}
+}
+
+func TestCloseProducerTimesOutWithUnflushedMessages(t *testing.T) {
+	broker, stopBroker := startNonResponsiveBroker(t)
+	defer stopBroker()
+
+	w := kafka.NewWriter(kafka.WriterConfig{
+		Brokers:     []string{broker},
+		Topic:       "platform.receptor-controller.responses",
+		Async:       true,
+		ReadTimeout: time.Second,
+	})
+
+	msgs := []kafka.Message{{Value: []byte("response-1")}, {Value: []byte("response-2")}}
+	if err := w.WriteMessages(context.Background(), msgs...); err != nil {
+		t.Fatalf("Unable to enqueue the messages: %v", err)
+	}
+
+	closeTimeout := 200 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := CloseProducer(ctx, w)
+	elapsed := time.Since(start)
+
+	if err != (CloseTimeoutError{Unflushed: int64(len(msgs))}) {
+		t.Fatalf("Expected %v, got %v", CloseTimeoutError{Unflushed: int64(len(msgs))}, err)
+	}
+
+	if elapsed > 2*closeTimeout {
+		t.Fatalf("Expected the close to give up within %s, but it took %s", closeTimeout, elapsed)
+	}
+}
+
+// testKafkaBrokers returns the brokers of the kafka used by the tests that
+// need a real broker (e.g. KAFKA_TEST_BROKERS=localhost:29092 with the docker
+// compose environment).  The tests are skipped when it is not set.
+func testKafkaBrokers(tb testing.TB) []string {
+	brokers := os.Getenv("KAFKA_TEST_BROKERS")
+	if brokers == "" {
+		tb.Skip("KAFKA_TEST_BROKERS is not set")
+	}
+	return strings.Split(brokers, ",")
+}
+
+func startTestProducer(brokers []string, topic string, batching bool) *kafka.Writer {
+	return StartProducer(&ProducerConfig{
+		Brokers:      brokers,
+		Topic:        topic,
+		BatchSize:    100,
+		BatchBytes:   1048576,
+		Batching:     batching,
+		BatchTimeout: 10 * time.Millisecond,
+		Compression:  NoCompression,
+		WriteTimeout: 10 * time.Second,
+	})
+}
+
+func TestStartProducerWithoutBatching(t *testing.T) {
+	w := startTestProducer([]string{"127.0.0.1:9092"}, "platform.receptor-controller.responses", false)
+	defer w.Close()
+
+	if stats := w.Stats(); stats.MaxBatchSize != 1 {
+		t.Fatalf("Expected each message to be written on its own, got a batch size of %d", stats.MaxBatchSize)
+	}
+}
+
+func TestStartProducerWithBatching(t *testing.T) {
+	w := startTestProducer([]string{"127.0.0.1:9092"}, "platform.receptor-controller.responses", true)
+	defer w.Close()
+
+	stats := w.Stats()
+	if stats.MaxBatchSize != 100 {
+		t.Fatalf("Expected a batch size of 100, got %d", stats.MaxBatchSize)
+	}
+
+	if stats.BatchTimeout != 10*time.Millisecond {
+		t.Fatalf("Expected a batch timeout of 10ms, got %s", stats.BatchTimeout)
+	}
+}
+
+func TestBatchedMessagesKeepTheirHeaders(t *testing.T) {
+	brokers := testKafkaBrokers(t)
+	topic := fmt.Sprintf("receptor-controller-batching-test-%d", time.Now().UnixNano())
+
+	w := startTestProducer(brokers, topic, true)
+	defer w.Close()
+
+	// The messages are written concurrently, as the responses are, so that
+	// they end up in the same batch
+	const count = 20
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := WriteMessages(context.Background(), w, 10*time.Second, kafka.Message{
+				Key:     []byte(fmt.Sprintf("message-%d", i)),
+				Value:   []byte("response"),
+				Headers: []kafka.Header{{Key: "request_id", Value: []byte(fmt.Sprintf("request-%d", i))}},
+			})
+			if err != nil {
+				t.Errorf("Unable to write the message: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if stats := w.Stats(); stats.Writes >= count {
+		t.Fatalf("Expected the messages to be batched, got %d writes for %d messages", stats.Writes, count)
+	}
+
+	r := kafka.NewReader(kafka.ReaderConfig{Brokers: brokers, Topic: topic, Partition: 0})
+	defer r.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for i := 0; i < count; i++ {
+		msg, err := r.ReadMessage(ctx)
+		if err != nil {
+			t.Fatalf("Unable to read the message: %v", err)
+		}
+
+		expectedRequestID := "request-" + strings.TrimPrefix(string(msg.Key), "message-")
+		if len(msg.Headers) != 1 || msg.Headers[0].Key != "request_id" || string(msg.Headers[0].Value) != expectedRequestID {
+			t.Fatalf("Expected the message %s to carry the request_id header %s, got %+v", msg.Key, expectedRequestID, msg.Headers)
+		}
+	}
+}
+
+func benchmarkWriteMessages(b *testing.B, batching bool) {
+	brokers := testKafkaBrokers(b)
+	topic := fmt.Sprintf("receptor-controller-batching-benchmark-%d", time.Now().UnixNano())
+
+	w := startTestProducer(brokers, topic, batching)
+	defer w.Close()
+
+	msg := kafka.Message{
+		Value:   []byte(`{"account":"0000001","sender":"node-a","message_type":"response","payload":"{}"}`),
+		Headers: []kafka.Header{{Key: "request_id", Value: []byte("request")}},
+	}
+
+	// Warm up the connection to the broker
+	if err := WriteMessages(context.Background(), w, 10*time.Second, msg); err != nil {
+		b.Fatalf("Unable to write the message: %v", err)
+	}
+	// Taking the stats resets the counters
+	w.Stats()
+
+	// Each response is written by its own goroutine
+	b.SetParallelism(50)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := WriteMessages(context.Background(), w, 10*time.Second, msg); err != nil {
+				b.Errorf("Unable to write the message: %v", err)
+			}
+		}
+	})
+	b.StopTimer()
+
+	if stats := w.Stats(); stats.Writes > 0 {
+		b.ReportMetric(float64(stats.Messages)/float64(stats.Writes), "msgs/write")
+	}
+}
+
+// The benchmarks need a broker: KAFKA_TEST_BROKERS=localhost:29092 go test -run XXX -bench WriteMessages ./internal/platform/queue
+func BenchmarkWriteMessagesWithoutBatching(b *testing.B) {
+	benchmarkWriteMessages(b, false)
+}
+
+func BenchmarkWriteMessagesWithBatching(b *testing.B) {
+	benchmarkWriteMessages(b, true)
+}
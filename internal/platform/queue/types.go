@@ -1,10 +1,18 @@
 package queue
 
+import (
+	"time"
+)
+
 type ProducerConfig struct {
-	Brokers    []string
-	Topic      string
-	BatchSize  int
-	BatchBytes int
+	Brokers      []string
+	Topic        string
+	BatchSize    int
+	BatchBytes   int
+	Batching     bool
+	BatchTimeout time.Duration
+	Compression  string
+	WriteTimeout time.Duration
 }
 
 type ConsumerConfig struct {
@@ -12,4 +20,6 @@ type ConsumerConfig struct {
 	Topic          string
 	GroupID        string
 	ConsumerOffset int64
+	ReadTimeout    time.Duration
+	CommitTimeout  time.Duration
 }
@@ -0,0 +1,39 @@
+//go:build linux
+// +build linux
+
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestStartHTTPServerWithReusePortSharesTheAddress(t *testing.T) {
+	listener, err := listen("127.0.0.1:0", true)
+	if err != nil {
+		t.Fatalf("Unable to create a listener: %v", err)
+	}
+	defer listener.Close()
+
+	srv, err := StartHTTPServer(listener.Addr().String(), "test", mux.NewRouter(), true, nil)
+	if err != nil {
+		t.Fatalf("Expected the address to be shared, got %v", err)
+	}
+
+	srv.Shutdown(context.TODO())
+}
+
+func TestStartHTTPServerWithReusePortFailsWhenTheAddressIsNotShared(t *testing.T) {
+	listener, err := listen("127.0.0.1:0", false)
+	if err != nil {
+		t.Fatalf("Unable to create a listener: %v", err)
+	}
+	defer listener.Close()
+
+	_, err = StartHTTPServer(listener.Addr().String(), "test", mux.NewRouter(), true, nil)
+	if err == nil {
+		t.Fatalf("Expected binding to an address that is not shared to fail")
+	}
+}
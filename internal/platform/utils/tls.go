@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+	"github.com/sirupsen/logrus"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// CertificateReloader serves a certificate that is loaded from disk and can
+// be reloaded (e.g. after the certificate has been rotated) without
+// restarting the server.
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+
+	lock        sync.RWMutex
+	certificate *tls.Certificate
+}
+
+// NewCertificateReloader loads the certificate and key.  An error is returned
+// if either file can not be loaded.
+func NewCertificateReloader(certFile, keyFile string) (*CertificateReloader, error) {
+	cr := &CertificateReloader{certFile: certFile, keyFile: keyFile}
+	if err := cr.Reload(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// Reload loads the certificate and key again.  The previous certificate is
+// kept if the files can not be loaded.
+func (cr *CertificateReloader) Reload() error {
+	certificate, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return fmt.Errorf("unable to load the certificate %s: %w", cr.certFile, err)
+	}
+
+	cr.lock.Lock()
+	defer cr.lock.Unlock()
+	cr.certificate = &certificate
+
+	return nil
+}
+
+// GetCertificate returns the most recently loaded certificate.  It is meant
+// to be used as the GetCertificate callback of a tls.Config.
+func (cr *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.lock.RLock()
+	defer cr.lock.RUnlock()
+	return cr.certificate, nil
+}
+
+// ReloadOnSignal reloads the certificate each time the process receives a
+// SIGHUP
+func (cr *CertificateReloader) ReloadOnSignal() {
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, syscall.SIGHUP)
+
+	go func() {
+		for range signalChan {
+			if err := cr.Reload(); err != nil {
+				logger.Log.WithFields(logrus.Fields{"error": err}).Error("Unable to reload the certificate...keeping the previous certificate")
+				continue
+			}
+			logger.Log.Infof("Reloaded the certificate %s", cr.certFile)
+		}
+	}()
+}
+
+// NewTLSConfig builds the tls.Config of a server that serves the certificate
+// loaded by the reloader.  The minimum version is given as "1.0", "1.1", "1.2"
+// or "1.3".  The cipher suites are given by their names (e.g.
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256), the default cipher suites are
+// used if none are given.  The cipher suites of TLS 1.3 are not configurable.
+func NewTLSConfig(cr *CertificateReloader, minVersion string, cipherSuites []string) (*tls.Config, error) {
+	version, ok := tlsVersions[minVersion]
+	if !ok {
+		return nil, fmt.Errorf("invalid minimum TLS version %s", minVersion)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     version,
+		GetCertificate: cr.GetCertificate,
+	}
+
+	if len(cipherSuites) > 0 {
+		suiteIDs := make(map[string]uint16)
+		for _, suite := range tls.CipherSuites() {
+			suiteIDs[suite.Name] = suite.ID
+		}
+
+		for _, name := range cipherSuites {
+			id, ok := suiteIDs[name]
+			if !ok {
+				return nil, fmt.Errorf("invalid or insecure TLS cipher suite %s", name)
+			}
+			tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, id)
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// ConfigureServerTLS builds the tls.Config of a server from the configured
+// certificate and key.  The certificate is reloaded each time the process
+// receives a SIGHUP.  Nil is returned if no certificate is configured, in
+// which case the server serves HTTP.
+func ConfigureServerTLS(certFile, keyFile, minVersion string, cipherSuites []string) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+
+	cr, err := NewCertificateReloader(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := NewTLSConfig(cr, minVersion, cipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	cr.ReloadOnSignal()
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,207 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// writeTestCertificate writes a self-signed certificate for 127.0.0.1 and its
+// key to dir.  The DER encoded certificate is returned.
+func writeTestCertificate(t *testing.T, dir string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Unable to generate a key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Unable to create a certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Unable to marshal the key: %v", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "tls.crt"), certPEM, 0600); err != nil {
+		t.Fatalf("Unable to write the certificate: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "tls.key"), keyPEM, 0600); err != nil {
+		t.Fatalf("Unable to write the key: %v", err)
+	}
+
+	return der
+}
+
+func newTestCertificateDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatalf("Unable to create a temporary directory: %v", err)
+	}
+	return dir
+}
+
+func TestStartHTTPServerWithTLS(t *testing.T) {
+	dir := newTestCertificateDir(t)
+	defer os.RemoveAll(dir)
+
+	der := writeTestCertificate(t, dir)
+
+	cr, err := NewCertificateReloader(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"))
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	tlsConfig, err := NewTLSConfig(cr, "1.3", nil)
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to create a listener: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/ping", func(w http.ResponseWriter, req *http.Request) {})
+
+	srv, err := StartHTTPServer(addr, "test", router, false, tlsConfig)
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+	defer srv.Close()
+
+	certificate, _ := x509.ParseCertificate(der)
+	roots := x509.NewCertPool()
+	roots.AddCert(certificate)
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+
+	resp, err := client.Get("https://" + addr + "/ping")
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if resp.TLS == nil || resp.TLS.Version != tls.VersionTLS13 {
+		t.Fatalf("Expected the request to be served over TLS 1.3, got %+v", resp.TLS)
+	}
+
+	// A client that only supports TLS 1.2 is rejected
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, MaxVersion: tls.VersionTLS12}}}
+	if resp, err := client.Get("https://" + addr + "/ping"); err == nil {
+		resp.Body.Close()
+		t.Fatalf("Expected a TLS 1.2 client to be rejected")
+	}
+}
+
+func TestCertificateReloaderReload(t *testing.T) {
+	dir := newTestCertificateDir(t)
+	defer os.RemoveAll(dir)
+
+	writeTestCertificate(t, dir)
+
+	cr, err := NewCertificateReloader(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"))
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	rotated := writeTestCertificate(t, dir)
+	if err := cr.Reload(); err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	certificate, _ := cr.GetCertificate(nil)
+	if !bytes.Equal(certificate.Certificate[0], rotated) {
+		t.Fatalf("Expected the rotated certificate to be served")
+	}
+
+	// A broken certificate does not replace the one being served
+	ioutil.WriteFile(filepath.Join(dir, "tls.crt"), []byte("not a certificate"), 0600)
+	if err := cr.Reload(); err == nil {
+		t.Fatalf("Expected reloading a broken certificate to fail")
+	}
+
+	certificate, _ = cr.GetCertificate(nil)
+	if !bytes.Equal(certificate.Certificate[0], rotated) {
+		t.Fatalf("Expected the previous certificate to still be served")
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir := newTestCertificateDir(t)
+	defer os.RemoveAll(dir)
+
+	writeTestCertificate(t, dir)
+
+	cr, err := NewCertificateReloader(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"))
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	tests := []struct {
+		minVersion   string
+		cipherSuites []string
+		valid        bool
+	}{
+		{minVersion: "1.2", valid: true},
+		{minVersion: "1.2", cipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}, valid: true},
+		{minVersion: "1.4", valid: false},
+		{minVersion: "", valid: false},
+		{minVersion: "1.2", cipherSuites: []string{"TLS_NOT_A_CIPHER_SUITE"}, valid: false},
+		{minVersion: "1.2", cipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}, valid: false},
+	}
+
+	for _, tc := range tests {
+		tlsConfig, err := NewTLSConfig(cr, tc.minVersion, tc.cipherSuites)
+		if tc.valid && err != nil {
+			t.Fatalf("Expected %s %v to be valid, got %v", tc.minVersion, tc.cipherSuites, err)
+		}
+		if !tc.valid && err == nil {
+			t.Fatalf("Expected %s %v to be rejected, got %+v", tc.minVersion, tc.cipherSuites, tlsConfig)
+		}
+	}
+}
+
+func TestConfigureServerTLSWithoutCertificate(t *testing.T) {
+	tlsConfig, err := ConfigureServerTLS("", "", "1.2", nil)
+	if tlsConfig != nil || err != nil {
+		t.Fatalf("Expected no tls config, got %+v, %v", tlsConfig, err)
+	}
+}
@@ -2,35 +2,118 @@ package utils
 
 import (
 	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
 	"net"
 	"net/http"
 	"os"
+	"sync/atomic"
 
 	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
 
-func StartHTTPServer(addr, name string, handler *mux.Router) *http.Server {
-	srv := &http.Server{
-		Addr:    addr,
-		Handler: handler,
+// HTTPServer is an http.Server that keeps track of the number of requests
+// that are being handled so that it can be reported when a graceful shutdown
+// times out.
+type HTTPServer struct {
+	*http.Server
+	inFlight int64
+}
+
+// InFlightRequests returns the number of requests that are being handled
+func (s *HTTPServer) InFlightRequests() int64 {
+	return atomic.LoadInt64(&s.inFlight)
+}
+
+func (s *HTTPServer) countInFlight(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt64(&s.inFlight, 1)
+		defer atomic.AddInt64(&s.inFlight, -1)
+		handler.ServeHTTP(w, req)
+	})
+}
+
+// StartHTTPServer binds to addr before returning so that a bad address (or an
+// address that is already in use) is reported at startup.  The server is then
+// run in the background.
+//
+// If reusePort is set, the socket is bound with SO_REUSEPORT (linux only) so
+// that a new process can bind the same address and take over the listener
+// while this process drains its connections.
+//
+// If tlsConfig is not nil, the server serves HTTPS.  Otherwise it serves HTTP.
+func StartHTTPServer(addr, name string, handler *mux.Router, reusePort bool, tlsConfig *tls.Config) (*HTTPServer, error) {
+	srv := &HTTPServer{
+		Server: &http.Server{
+			Addr:      addr,
+			TLSConfig: tlsConfig,
+		},
+	}
+	srv.Handler = srv.countInFlight(handler)
+
+	listener, err := listen(addr, reusePort)
+	if err != nil {
+		return nil, fmt.Errorf("unable to bind the %s server to %s: %w", name, addr, err)
 	}
 
 	go func() {
-		logger.Log.Infof("Starting %s server:  %s", name, addr)
-		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		logger.Log.Infof("Starting %s server:  %s", name, listener.Addr())
+		var err error
+		if tlsConfig != nil {
+			// The certificate is provided by the tls config
+			err = srv.ServeTLS(listener, "", "")
+		} else {
+			err = srv.Serve(listener)
+		}
+		if err != http.ErrServerClosed {
 			logger.Log.WithFields(logrus.Fields{"error": err}).Fatalf("%s server error", name)
 		}
 	}()
 
-	return srv
+	return srv, nil
+}
+
+func listen(addr string, reusePort bool) (net.Listener, error) {
+	if reusePort == false {
+		return net.Listen("tcp", addr)
+	}
+
+	lc := net.ListenConfig{Control: reusePortControl}
+	return lc.Listen(context.Background(), "tcp", addr)
+}
+
+// ListenAddr returns the address a server should listen on.  An address passed
+// on the command line (flagName) takes precedence over the configured
+// address, which takes precedence over the default value of the flag.
+func ListenAddr(flagName string, configured string) string {
+	f := flag.Lookup(flagName)
+
+	explicit := false
+	flag.Visit(func(v *flag.Flag) {
+		if v.Name == flagName {
+			explicit = true
+		}
+	})
+
+	if explicit == false && configured != "" {
+		return configured
+	}
+
+	return f.Value.String()
 }
 
-func ShutdownHTTPServer(ctx context.Context, name string, srv *http.Server) {
+// ShutdownHTTPServer waits for the requests that are being handled to
+// complete until ctx is done.  The connections of the requests that are still
+// being handled at that point are closed.
+func ShutdownHTTPServer(ctx context.Context, name string, srv *HTTPServer) {
 	logger.Log.Infof("Shutting down %s server", name)
 	if err := srv.Shutdown(ctx); err != nil {
-		logger.Log.Infof("Error shutting down %s server: %e", name, err)
+		logger.Log.WithFields(logrus.Fields{"error": err, "in_flight_requests": srv.InFlightRequests()}).
+			Warnf("Timed out shutting down the %s server...closing the remaining connections", name)
+		srv.Close()
 	}
 }
 
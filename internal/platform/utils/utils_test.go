@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/platform-receptor-controller/internal/platform/logger"
+	"github.com/gorilla/mux"
+)
+
+func init() {
+	logger.InitLogger()
+}
+
+func TestStartHTTPServerFailsOnBadAddress(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1:notaport", "127.0.0.1:70000"} {
+		srv, err := StartHTTPServer(addr, "test", mux.NewRouter(), false, nil)
+		if err == nil {
+			srv.Shutdown(context.TODO())
+			t.Fatalf("Expected binding to %s to fail", addr)
+		}
+	}
+}
+
+func TestStartHTTPServerFailsWhenAddressIsInUse(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to create a listener: %v", err)
+	}
+	defer listener.Close()
+
+	_, err = StartHTTPServer(listener.Addr().String(), "test", mux.NewRouter(), false, nil)
+	if err == nil {
+		t.Fatalf("Expected binding to an address in use to fail")
+	}
+}
+
+func TestStartHTTPServer(t *testing.T) {
+	srv, err := StartHTTPServer("127.0.0.1:0", "test", mux.NewRouter(), false, nil)
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	srv.Shutdown(context.TODO())
+}
+
+func TestShutdownHTTPServerClosesRequestsStillInFlight(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to create a listener: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	release := make(chan struct{})
+	defer close(release)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/slow", func(w http.ResponseWriter, req *http.Request) {
+		<-release
+	})
+
+	srv, err := StartHTTPServer(addr, "test", router, false, nil)
+	if err != nil {
+		t.Fatalf("Expected the error to be nil, got %v", err)
+	}
+
+	requestErr := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/slow")
+		if err == nil {
+			resp.Body.Close()
+		}
+		requestErr <- err
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for srv.InFlightRequests() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected 1 request in flight, got %d", srv.InFlightRequests())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	ShutdownHTTPServer(ctx, "test", srv)
+
+	select {
+	case err := <-requestErr:
+		if err == nil {
+			t.Fatalf("Expected the request still in flight to be cut off")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the request still in flight to be cut off")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"regexp"
 )
 
 var (
@@ -15,12 +16,31 @@ var (
 	errFrameDataTooShort = errors.New("frame data too short")
 )
 
+// FrameTooLargeError is returned when a frame announces more data than the
+// reader accepts.  The frame data is not kept.
+type FrameTooLargeError struct {
+	Length uint32
+	Limit  uint32
+
+	// InResponseTo is the id of the message that the dropped payload
+	// message responds to.  It is empty if it is not known.
+	InResponseTo string
+}
+
+func (e FrameTooLargeError) Error() string {
+	return fmt.Sprintf("frame data length of %d bytes exceeds the limit of %d bytes", e.Length, e.Limit)
+}
+
 type frameType int8
 
 const (
 	HeaderFrameType  frameType = 0
 	PayloadFrameType frameType = 1
 	CommandFrameType frameType = 2
+
+	// BinaryFrameType carries the payload of a binary payload message as is.
+	// It follows the payload frame of the message.
+	BinaryFrameType frameType = 3
 )
 
 type messageID [16]byte
@@ -71,7 +91,7 @@ func (f *FrameHeader) unmarshal(buf []byte) error {
 }
 
 func (f *FrameHeader) isValidType() bool {
-	return f.Type == HeaderFrameType || f.Type == PayloadFrameType || f.Type == CommandFrameType
+	return f.Type == HeaderFrameType || f.Type == PayloadFrameType || f.Type == CommandFrameType || f.Type == BinaryFrameType
 }
 
 func (f *FrameHeader) marshal() ([]byte, error) {
@@ -86,7 +106,9 @@ func (f *FrameHeader) marshal() ([]byte, error) {
 	return w.Bytes(), nil
 }
 
-func readFrame(r io.Reader) (*FrameHeader, error) {
+// readFrame reads a frame header.  If limit is greater than 0, a frame that
+// announces more than limit bytes of data is rejected.
+func readFrame(r io.Reader, limit uint32) (*FrameHeader, error) {
 	buf := make([]byte, FrameHeaderLength)
 
 	_, err := io.ReadFull(r, buf)
@@ -102,9 +124,47 @@ func readFrame(r io.Reader) (*FrameHeader, error) {
 		return nil, err
 	}
 
+	if limit > 0 && f.Length > limit {
+		return nil, FrameTooLargeError{Length: f.Length, Limit: limit}
+	}
+
 	return f, nil
 }
 
+// inResponseToTailSize is the number of bytes at the end of a payload frame
+// that are searched for the in_response_to field.  The field follows the
+// raw_payload in the envelope.
+const inResponseToTailSize = 1024
+
+var inResponseToPattern = regexp.MustCompile(`"in_response_to"\s*:\s*"([^"\\]*)"`)
+
+// scanInResponseTo reads the data of a payload frame that is too large and
+// returns the in_response_to field of the envelope, or "" if it is not found.
+// Only the end of the data is kept.
+func scanInResponseTo(r io.Reader) string {
+	buf := make([]byte, 4096)
+	var tail []byte
+
+	for {
+		n, err := r.Read(buf)
+		tail = append(tail, buf[:n]...)
+		if len(tail) > inResponseToTailSize {
+			copy(tail, tail[len(tail)-inResponseToTailSize:])
+			tail = tail[:inResponseToTailSize]
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	matches := inResponseToPattern.FindAllSubmatch(tail, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+
+	return string(matches[len(matches)-1][1])
+}
+
 func readFrameData(r io.Reader, dataLength uint32) ([]byte, error) {
 	buf := make([]byte, dataLength)
 	_, err := io.ReadFull(r, buf)
@@ -23,8 +23,36 @@ const (
 	RouteTableMessageType NetworkMessageType = 2
 	RoutingMessageType    NetworkMessageType = 3
 	PayloadMessageType    NetworkMessageType = 4
+
+	// CapabilitiesMessageType is sent by a node when its capabilities change
+	// after the handshake (e.g. after a new worker is installed)
+	CapabilitiesMessageType NetworkMessageType = 5
+
+	// AckMessageType is sent by a node when it has received a payload message
+	AckMessageType NetworkMessageType = 6
+
+	// FlowControlMessageType is sent by a node that wants the controller to
+	// pause, resume or slow down the delivery of messages
+	FlowControlMessageType NetworkMessageType = 7
+
+	// CancelMessageType is sent by the controller to cancel a payload
+	// message that was sent to the node
+	CancelMessageType NetworkMessageType = 8
+
+	// BinaryPayloadMessageType is a payload message whose payload is written
+	// to the node as is rather than being encoded as json
+	BinaryPayloadMessageType NetworkMessageType = 9
+
+	// MetadataUpdateMessageType is sent by a node when its metadata changes
+	// after the handshake (e.g. after an in-place upgrade)
+	MetadataUpdateMessageType NetworkMessageType = 10
 )
 
+// BinaryPayloadEncoding is the payload encoding of the envelope of a binary
+// payload message.  The payload is carried by the binary frame that follows
+// the payload frame.
+const BinaryPayloadEncoding = "binary"
+
 const jsonTimeFormat = "2006-01-02T15:04:05.999999999"
 
 type Message interface {
@@ -34,8 +62,15 @@ type Message interface {
 }
 
 func ReadMessage(r io.Reader) (Message, error) {
+	return ReadMessageWithLimit(r, 0)
+}
+
+// ReadMessageWithLimit reads a message whose frames each carry at most limit
+// bytes of data.  A FrameTooLargeError is returned for a larger frame before
+// its data is read.  A limit of 0 means the frames are not limited.
+func ReadMessageWithLimit(r io.Reader, limit uint32) (Message, error) {
 
-	f, err := readFrame(r)
+	f, err := readFrame(r, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -49,8 +84,13 @@ func ReadMessage(r io.Reader) (Message, error) {
 		// The current frame is a header frame...so the next chunk of
 		// data should be a payload frame followed by the payload data
 
-		payloadFrame, err := readFrame(r)
-		if err != nil {
+		payloadFrame, err := readFrame(r, limit)
+		if tooLarge, ok := err.(FrameTooLargeError); ok {
+			// The payload is skipped, but the request that it responds to
+			// is looked up so that the sender of the request can be told
+			tooLarge.InResponseTo = scanInResponseTo(io.LimitReader(r, int64(tooLarge.Length)))
+			return nil, tooLarge
+		} else if err != nil {
 			log.Println("unable to read payload frame:", err)
 			return nil, err
 		}
@@ -71,18 +111,53 @@ func ReadMessage(r io.Reader) (Message, error) {
 		payloadMessage := pm.(*PayloadMessage)
 		payloadMessage.RoutingInfo = message.(*RoutingMessage)
 
+		if payloadMessage.Data.PayloadEncoding == BinaryPayloadEncoding {
+			return readBinaryPayload(r, limit, payloadMessage)
+		}
+
 		return payloadMessage, err
 	}
 
 	return message, err
 }
 
+// readBinaryPayload reads the binary frame that follows the payload frame of a
+// binary payload message
+func readBinaryPayload(r io.Reader, limit uint32, payloadMessage *PayloadMessage) (Message, error) {
+	binaryFrame, err := readFrame(r, limit)
+	if tooLarge, ok := err.(FrameTooLargeError); ok {
+		tooLarge.InResponseTo = payloadMessage.Data.InResponseTo
+		return nil, tooLarge
+	} else if err != nil {
+		log.Println("unable to read binary frame:", err)
+		return nil, err
+	}
+
+	if binaryFrame.Type != BinaryFrameType {
+		log.Printf("read invalid frame type...expected binary frame '%d' received frame type '%d'",
+			BinaryFrameType,
+			binaryFrame.Type)
+		return nil, errInvalidMessage
+	}
+
+	data, err := readFrameData(r, binaryFrame.Length)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BinaryPayloadMessage{PayloadMessage: *payloadMessage, Payload: data}, nil
+}
+
 func WriteMessage(w io.Writer, message Message) error {
 
 	if message.Type() == PayloadMessageType {
 		return writePayloadMessage(w, message)
 	}
 
+	if message.Type() == BinaryPayloadMessageType {
+		return writeBinaryPayloadMessage(w, message.(*BinaryPayloadMessage))
+	}
+
 	messageBuffer, err := message.marshal()
 	if err != nil {
 		// FIXME: log the error
@@ -123,13 +198,33 @@ func writePayloadMessage(w io.Writer, message Message) error {
 	return nil
 }
 
+func writeBinaryPayloadMessage(w io.Writer, message *BinaryPayloadMessage) error {
+	if err := writePayloadMessage(w, &message.PayloadMessage); err != nil {
+		return err
+	}
+
+	return writeFrame(w, BinaryFrameType, message.Payload)
+}
+
 func buildCommandMessage(buff []byte) (Message, error) {
 	msgString := string(buff)
 
 	var m Message
 
-	// FIXME: look into dynamically unmarshalling json
-	if strings.Contains(msgString, "HI") {
+	// The capabilities can contain just about anything (including "HI" and
+	// "ROUTE") so check the command explicitly
+	command := getCommand(buff)
+	if command == CapabilitiesCommand {
+		m = new(CapabilitiesMessage)
+	} else if command == AckCommand {
+		m = new(AckMessage)
+	} else if command == FlowControlCommand {
+		m = new(FlowControlMessage)
+	} else if command == CancelCommand {
+		m = new(CancelMessage)
+	} else if command == MetadataUpdateCommand {
+		m = new(MetadataUpdateMessage)
+	} else if strings.Contains(msgString, "HI") {
 		m = new(HiMessage)
 	} else if strings.Contains(msgString, "ROUTE") {
 		m = new(RouteTableMessage)
@@ -141,13 +236,38 @@ func buildCommandMessage(buff []byte) (Message, error) {
 	return m, nil
 }
 
+func getCommand(buff []byte) string {
+	var command struct {
+		Command string `json:"cmd"`
+	}
+
+	if err := json.Unmarshal(buff, &command); err != nil {
+		return ""
+	}
+
+	return command.Command
+}
+
 var _ Message = &HiMessage{}
 
+// The versions of the protocol negotiated during the handshake.  A node that
+// does not advertise a version in its HiMessage speaks ProtocolVersion1.
+const (
+	ProtocolVersion1 = 1
+
+	// ProtocolVersion2 adds the binary payload messages
+	ProtocolVersion2 = 2
+
+	CurrentProtocolVersion = ProtocolVersion2
+)
+
 type HiMessage struct {
 	Command         string      `json:"cmd"`
 	ID              string      `json:"id"`
 	ExpireTimestamp interface{} `json:"expire_time"` // FIXME:
 	Metadata        interface{} `json:"meta"`
+	ProtocolVersion int         `json:"protocol_version,omitempty"`
+	Affinity        string      `json:"affinity,omitempty"` // the pod the node should prefer when it reconnects
 	// b'{"cmd": "HI", "id": "node-b", "expire_time": 1571507551.7103958}\x1b[K'
 }
 
@@ -212,9 +332,45 @@ func (m *RoutingMessage) marshal() ([]byte, error) {
 }
 
 type Edge struct {
-	Left  string
-	Right string
-	Cost  int
+	Left  string `json:"left"`
+	Right string `json:"right"`
+	Cost  int    `json:"cost"`
+}
+
+// ParseEdges converts the raw edges from a RouteTableMessage
+// (ex. [["node-a", "node-b", 1], ...]) into a list of Edges
+func ParseEdges(rawEdges [][]interface{}) ([]Edge, error) {
+	edges := make([]Edge, 0, len(rawEdges))
+
+	for _, rawEdge := range rawEdges {
+		if len(rawEdge) != 3 {
+			return nil, fmt.Errorf("invalid edge (expected 3 elements): %v", rawEdge)
+		}
+
+		left, ok := rawEdge[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid edge (left node is not a string): %v", rawEdge)
+		}
+
+		right, ok := rawEdge[1].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid edge (right node is not a string): %v", rawEdge)
+		}
+
+		var cost int
+		switch c := rawEdge[2].(type) {
+		case float64:
+			cost = int(c)
+		case int:
+			cost = c
+		default:
+			return nil, fmt.Errorf("invalid edge (cost is not a number): %v", rawEdge)
+		}
+
+		edges = append(edges, Edge{Left: left, Right: right, Cost: cost})
+	}
+
+	return edges, nil
 }
 
 var _ Message = &RouteTableMessage{}
@@ -261,6 +417,227 @@ func (m *RouteTableMessage) marshal() ([]byte, error) {
 	return b, nil
 }
 
+const CapabilitiesCommand = "CAPABILITIES"
+
+var _ Message = &CapabilitiesMessage{}
+
+type CapabilitiesMessage struct {
+	Command      string      `json:"cmd"`
+	ID           string      `json:"id"`
+	Capabilities interface{} `json:"capabilities"`
+
+	// More is set when the node streams its capabilities over several
+	// messages.  The last message of the stream does not set it.
+	More bool `json:"more,omitempty"`
+
+	// b'{"cmd": "CAPABILITIES",
+	//    "id": "node-b",
+	//    "capabilities": {"max_work_threads": 12,
+	//                     "worker_versions": {"receptor_http": "1.0.0"}}}'
+}
+
+func (m *CapabilitiesMessage) Type() NetworkMessageType {
+	return CapabilitiesMessageType
+}
+
+func (m *CapabilitiesMessage) unmarshal(b []byte) error {
+	if err := json.Unmarshal(b, m); err != nil {
+		log.Println("unmarshal of CapabilitiesMessage failed, err:", err)
+		return err
+	}
+
+	return nil
+}
+
+func (m *CapabilitiesMessage) marshal() ([]byte, error) {
+
+	b, err := json.Marshal(m)
+
+	if err != nil {
+		log.Println("marshal of CapabilitiesMessage failed, err:", err)
+		return nil, err
+	}
+
+	return b, nil
+}
+
+const AckCommand = "ACK"
+
+var _ Message = &AckMessage{}
+
+type AckMessage struct {
+	Command   string `json:"cmd"`
+	ID        string `json:"id"`
+	MessageID string `json:"message_id"`
+
+	// RetryAfter is the number of seconds that the node asks the controller
+	// to wait before sending it anything else.  Zero means the node is not
+	// backing off.
+	RetryAfter float64 `json:"retry_after,omitempty"`
+
+	// b'{"cmd": "ACK",
+	//    "id": "node-b",
+	//    "message_id": "a8ee3e2d-6ea8-4cc5-a3d4-4c8ef49c4e8a",
+	//    "retry_after": 30}'
+}
+
+func (m *AckMessage) Type() NetworkMessageType {
+	return AckMessageType
+}
+
+func (m *AckMessage) unmarshal(b []byte) error {
+	if err := json.Unmarshal(b, m); err != nil {
+		log.Println("unmarshal of AckMessage failed, err:", err)
+		return err
+	}
+
+	return nil
+}
+
+func (m *AckMessage) marshal() ([]byte, error) {
+
+	b, err := json.Marshal(m)
+
+	if err != nil {
+		log.Println("marshal of AckMessage failed, err:", err)
+		return nil, err
+	}
+
+	return b, nil
+}
+
+const FlowControlCommand = "FLOW_CONTROL"
+
+const (
+	FlowControlPause  = "pause"
+	FlowControlResume = "resume"
+	FlowControlRate   = "rate"
+)
+
+var _ Message = &FlowControlMessage{}
+
+type FlowControlMessage struct {
+	Command string `json:"cmd"`
+	ID      string `json:"id"`
+	Action  string `json:"action"`
+
+	// Rate is the number of messages per second that the node can accept
+	// (rate action only).  A rate of 0 removes the limit.
+	Rate int `json:"rate,omitempty"`
+
+	// b'{"cmd": "FLOW_CONTROL",
+	//    "id": "node-b",
+	//    "action": "rate",
+	//    "rate": 5}'
+}
+
+func (m *FlowControlMessage) Type() NetworkMessageType {
+	return FlowControlMessageType
+}
+
+func (m *FlowControlMessage) unmarshal(b []byte) error {
+	if err := json.Unmarshal(b, m); err != nil {
+		log.Println("unmarshal of FlowControlMessage failed, err:", err)
+		return err
+	}
+
+	return nil
+}
+
+func (m *FlowControlMessage) marshal() ([]byte, error) {
+
+	b, err := json.Marshal(m)
+
+	if err != nil {
+		log.Println("marshal of FlowControlMessage failed, err:", err)
+		return nil, err
+	}
+
+	return b, nil
+}
+
+const MetadataUpdateCommand = "METADATA"
+
+var _ Message = &MetadataUpdateMessage{}
+
+type MetadataUpdateMessage struct {
+	Command string `json:"cmd"`
+	ID      string `json:"id"`
+
+	// Metadata is merged into the metadata sent in the HiMessage.  A key set
+	// to null is removed from the metadata.
+	Metadata map[string]interface{} `json:"meta"`
+
+	// b'{"cmd": "METADATA",
+	//    "id": "node-b",
+	//    "meta": {"region": "us-west-2",
+	//             "version": "1.2.0"}}'
+}
+
+func (m *MetadataUpdateMessage) Type() NetworkMessageType {
+	return MetadataUpdateMessageType
+}
+
+func (m *MetadataUpdateMessage) unmarshal(b []byte) error {
+	if err := json.Unmarshal(b, m); err != nil {
+		log.Println("unmarshal of MetadataUpdateMessage failed, err:", err)
+		return err
+	}
+
+	return nil
+}
+
+func (m *MetadataUpdateMessage) marshal() ([]byte, error) {
+
+	b, err := json.Marshal(m)
+
+	if err != nil {
+		log.Println("marshal of MetadataUpdateMessage failed, err:", err)
+		return nil, err
+	}
+
+	return b, nil
+}
+
+const CancelCommand = "CANCEL"
+
+var _ Message = &CancelMessage{}
+
+type CancelMessage struct {
+	Command   string `json:"cmd"`
+	ID        string `json:"id"`
+	MessageID string `json:"message_id"`
+
+	// b'{"cmd": "CANCEL",
+	//    "id": "node-a",
+	//    "message_id": "a8ee3e2d-6ea8-4cc5-a3d4-4c8ef49c4e8a"}'
+}
+
+func (m *CancelMessage) Type() NetworkMessageType {
+	return CancelMessageType
+}
+
+func (m *CancelMessage) unmarshal(b []byte) error {
+	if err := json.Unmarshal(b, m); err != nil {
+		log.Println("unmarshal of CancelMessage failed, err:", err)
+		return err
+	}
+
+	return nil
+}
+
+func (m *CancelMessage) marshal() ([]byte, error) {
+
+	b, err := json.Marshal(m)
+
+	if err != nil {
+		log.Println("marshal of CancelMessage failed, err:", err)
+		return nil, err
+	}
+
+	return b, nil
+}
+
 var _ Message = &PayloadMessage{}
 
 type PayloadMessage struct {
@@ -304,6 +681,18 @@ type InnerEnvelope struct {
 	InResponseTo string      `json:"in_response_to"`
 	Code         int         `json:"code"`
 	Serial       int         `json:"serial"`
+	RequestID    string      `json:"request_id,omitempty"`
+	TraceParent  string      `json:"traceparent,omitempty"`
+	TraceState   string      `json:"tracestate,omitempty"`
+
+	// PayloadEncoding is set to BinaryPayloadEncoding when the payload is
+	// carried by a binary frame instead of RawPayload
+	PayloadEncoding string `json:"payload_encoding,omitempty"`
+
+	// RetryAfter is set by a node responding to a ping or a directive to ask
+	// the controller to wait that many seconds before sending it anything
+	// else (see AckMessage)
+	RetryAfter float64 `json:"retry_after,omitempty"`
 }
 
 type Time struct {
@@ -355,3 +744,26 @@ func BuildPayloadMessage(messageId uuid.UUID, sender string, recipient string, r
 
 	return payloadMessage, nil
 }
+
+var _ Message = &BinaryPayloadMessage{}
+
+// BinaryPayloadMessage is a payload message whose payload is written to the
+// node as is, in a binary frame that follows the payload frame, rather than
+// being encoded as json in the envelope
+type BinaryPayloadMessage struct {
+	PayloadMessage
+	Payload []byte
+}
+
+func (m *BinaryPayloadMessage) Type() NetworkMessageType {
+	return BinaryPayloadMessageType
+}
+
+// NewBinaryPayloadMessage turns the payload message into a binary payload
+// message carrying data
+func NewBinaryPayloadMessage(payloadMessage *PayloadMessage, data []byte) *BinaryPayloadMessage {
+	message := &BinaryPayloadMessage{PayloadMessage: *payloadMessage, Payload: data}
+	message.Data.RawPayload = nil
+	message.Data.PayloadEncoding = BinaryPayloadEncoding
+	return message
+}
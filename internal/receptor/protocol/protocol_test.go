@@ -5,6 +5,9 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 )
@@ -145,6 +148,163 @@ func TestReadCommandMessageRouteTable(t *testing.T) {
 	if routeTableMessage.Command != "ROUTE" {
 		t.Fatalf("incorrect command")
 	}
+
+	edges, err := ParseEdges(routeTableMessage.Edges)
+	if err != nil {
+		t.Fatalf("unexpected error parsing edges: %s", err)
+	}
+
+	expectedEdges := []Edge{{Left: "node-a", Right: "node-b", Cost: 1}}
+	if !reflect.DeepEqual(edges, expectedEdges) {
+		t.Fatalf("incorrect edges: expected %+v, got %+v", expectedEdges, edges)
+	}
+}
+
+func TestReadCommandMessageCapabilities(t *testing.T) {
+	commandMessage := []byte("{\"cmd\": \"CAPABILITIES\", \"id\": \"node_01\", \"capabilities\": {\"worker_versions\": {\"HI\": \"1.0.0\", \"ROUTE\": \"1.0.0\"}}}")
+
+	b := generateFrameByteArray(CommandFrameType, 123, commandMessage)
+
+	r := bytes.NewReader(b)
+	message, err := ReadMessage(r)
+	if err != nil {
+		t.Fatalf("unexpected error reading message: %s", err)
+	}
+
+	if message.Type() != CapabilitiesMessageType {
+		t.Fatalf("incorrect message type")
+	}
+
+	capabilitiesMessage := message.(*CapabilitiesMessage)
+	if capabilitiesMessage.Command != CapabilitiesCommand {
+		t.Fatalf("incorrect command")
+	}
+
+	expectedCapabilities := map[string]interface{}{"worker_versions": map[string]interface{}{"HI": "1.0.0", "ROUTE": "1.0.0"}}
+	if !reflect.DeepEqual(capabilitiesMessage.Capabilities, expectedCapabilities) {
+		t.Fatalf("incorrect capabilities: expected %+v, got %+v", expectedCapabilities, capabilitiesMessage.Capabilities)
+	}
+}
+
+func TestReadCommandMessageAck(t *testing.T) {
+	commandMessage := []byte("{\"cmd\": \"ACK\", \"id\": \"node_01\", \"message_id\": \"a8ee3e2d-6ea8-4cc5-a3d4-4c8ef49c4e8a\"}")
+
+	b := generateFrameByteArray(CommandFrameType, 123, commandMessage)
+
+	r := bytes.NewReader(b)
+	message, err := ReadMessage(r)
+	if err != nil {
+		t.Fatalf("unexpected error reading message: %s", err)
+	}
+
+	if message.Type() != AckMessageType {
+		t.Fatalf("incorrect message type")
+	}
+
+	ackMessage := message.(*AckMessage)
+	if ackMessage.ID != "node_01" || ackMessage.MessageID != "a8ee3e2d-6ea8-4cc5-a3d4-4c8ef49c4e8a" {
+		t.Fatalf("incorrect ack message: %+v", ackMessage)
+	}
+}
+
+func TestReadCommandMessageAckWithRetryAfter(t *testing.T) {
+	commandMessage := []byte("{\"cmd\": \"ACK\", \"id\": \"node_01\", \"message_id\": \"a8ee3e2d-6ea8-4cc5-a3d4-4c8ef49c4e8a\", \"retry_after\": 2.5}")
+
+	b := generateFrameByteArray(CommandFrameType, 123, commandMessage)
+
+	r := bytes.NewReader(b)
+	message, err := ReadMessage(r)
+	if err != nil {
+		t.Fatalf("unexpected error reading message: %s", err)
+	}
+
+	ackMessage := message.(*AckMessage)
+	if ackMessage.RetryAfter != 2.5 {
+		t.Fatalf("incorrect retry after: %+v", ackMessage)
+	}
+}
+
+func TestReadCommandMessageFlowControl(t *testing.T) {
+	commandMessage := []byte("{\"cmd\": \"FLOW_CONTROL\", \"id\": \"node_01\", \"action\": \"rate\", \"rate\": 5}")
+
+	b := generateFrameByteArray(CommandFrameType, 123, commandMessage)
+
+	r := bytes.NewReader(b)
+	message, err := ReadMessage(r)
+	if err != nil {
+		t.Fatalf("unexpected error reading message: %s", err)
+	}
+
+	if message.Type() != FlowControlMessageType {
+		t.Fatalf("incorrect message type")
+	}
+
+	flowControlMessage := message.(*FlowControlMessage)
+	if flowControlMessage.ID != "node_01" || flowControlMessage.Action != FlowControlRate || flowControlMessage.Rate != 5 {
+		t.Fatalf("incorrect flow control message: %+v", flowControlMessage)
+	}
+}
+
+func TestReadCommandMessageCancel(t *testing.T) {
+	commandMessage := []byte("{\"cmd\": \"CANCEL\", \"id\": \"node_01\", \"message_id\": \"a8ee3e2d-6ea8-4cc5-a3d4-4c8ef49c4e8a\"}")
+
+	b := generateFrameByteArray(CommandFrameType, 123, commandMessage)
+
+	r := bytes.NewReader(b)
+	message, err := ReadMessage(r)
+	if err != nil {
+		t.Fatalf("unexpected error reading message: %s", err)
+	}
+
+	if message.Type() != CancelMessageType {
+		t.Fatalf("incorrect message type")
+	}
+
+	cancelMessage := message.(*CancelMessage)
+	if cancelMessage.ID != "node_01" || cancelMessage.MessageID != "a8ee3e2d-6ea8-4cc5-a3d4-4c8ef49c4e8a" {
+		t.Fatalf("incorrect cancel message: %+v", cancelMessage)
+	}
+}
+
+func TestReadCommandMessageMetadataUpdate(t *testing.T) {
+	// The metadata can contain just about anything, including "HI" and "ROUTE"
+	commandMessage := []byte("{\"cmd\": \"METADATA\", \"id\": \"node_01\", \"meta\": {\"region\": \"HI\", \"version\": null}}")
+
+	b := generateFrameByteArray(CommandFrameType, 123, commandMessage)
+
+	r := bytes.NewReader(b)
+	message, err := ReadMessage(r)
+	if err != nil {
+		t.Fatalf("unexpected error reading message: %s", err)
+	}
+
+	if message.Type() != MetadataUpdateMessageType {
+		t.Fatalf("incorrect message type")
+	}
+
+	metadataUpdateMessage := message.(*MetadataUpdateMessage)
+	expected := map[string]interface{}{"region": "HI", "version": nil}
+	if metadataUpdateMessage.ID != "node_01" || !reflect.DeepEqual(metadataUpdateMessage.Metadata, expected) {
+		t.Fatalf("incorrect metadata update message: %+v", metadataUpdateMessage)
+	}
+}
+
+func TestParseEdgesInvalidEdges(t *testing.T) {
+
+	subTests := map[string][][]interface{}{
+		"too_short":    {{"node-a", "node-b"}},
+		"invalid_left": {{1, "node-b", 1}},
+		"invalid_cost": {{"node-a", "node-b", "one"}},
+	}
+
+	for testName, rawEdges := range subTests {
+		t.Run(testName, func(t *testing.T) {
+			_, err := ParseEdges(rawEdges)
+			if err == nil {
+				t.Fatalf("[%s] invalid response...expected an error, got success", testName)
+			}
+		})
+	}
 }
 
 func TestReadCommandMessageInvalidMessages(t *testing.T) {
@@ -250,6 +410,67 @@ func TestReadHeaderAndPayloadWithShortPayloadData(t *testing.T) {
 	}
 }
 
+func TestReadMessageWithLimitRejectsOversizedPayload(t *testing.T) {
+	routingMessage := []byte("{\"sender\": \"1234\", \"recipient\": \"345\", \"route_list\": [\"678\"]}")
+
+	b := generateFrameByteArray(HeaderFrameType, 123, routingMessage)
+
+	// The payload frame announces far more data than is sent
+	payloadFrame := FrameHeader{Type: PayloadFrameType, Version: 1, ID: 123, Length: 1 << 31}
+	payloadHeader, err := payloadFrame.marshal()
+	if err != nil {
+		t.Fatalf("unable to marshal the payload frame: %v", err)
+	}
+	b = append(b, payloadHeader...)
+	b = append(b, []byte("{\"message_id\": \"123\"")...)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	message, err := ReadMessageWithLimit(bytes.NewReader(b), 1024)
+
+	runtime.ReadMemStats(&after)
+
+	expected := FrameTooLargeError{Length: 1 << 31, Limit: 1024}
+	if message != nil || err != expected {
+		t.Fatalf("expected %v, got %v", expected, err)
+	}
+
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Fatalf("expected the payload not to be allocated, %d bytes were allocated", allocated)
+	}
+}
+
+func TestReadMessageWithLimitReportsTheRequestOfAnOversizedPayload(t *testing.T) {
+	routingMessage := []byte("{\"sender\": \"1234\", \"recipient\": \"345\", \"route_list\": [\"678\"]}")
+	payloadMessage := []byte("{\"message_id\": \"123\", \"raw_payload\": \"" + strings.Repeat("x", 8192) +
+		"\", \"in_response_to\": \"a9b6bc9a-23a6-49a7-96a0-4baef8f1186c\", \"serial\": 1}")
+
+	b := generateFrameByteArray(HeaderFrameType, 123, routingMessage)
+	b = append(b, generateFrameByteArray(PayloadFrameType, 123, payloadMessage)...)
+
+	_, err := ReadMessageWithLimit(bytes.NewReader(b), 1024)
+
+	expected := FrameTooLargeError{Length: uint32(len(payloadMessage)), Limit: 1024, InResponseTo: "a9b6bc9a-23a6-49a7-96a0-4baef8f1186c"}
+	if err != expected {
+		t.Fatalf("expected %v, got %v", expected, err)
+	}
+}
+
+func TestReadMessageWithLimitRejectsOversizedCommand(t *testing.T) {
+	b := generateFrameByteArray(CommandFrameType, 123, []byte("{\"cmd\": \"HI\", \"id\": \"940-23\"}"))
+
+	_, err := ReadMessageWithLimit(bytes.NewReader(b), 8)
+	if _, ok := err.(FrameTooLargeError); !ok {
+		t.Fatalf("expected a frame too large error, got %v", err)
+	}
+
+	message, err := ReadMessageWithLimit(bytes.NewReader(b), 1024)
+	if err != nil || message.Type() != HiMessageType {
+		t.Fatalf("expected a hi message, got %v (%v)", message, err)
+	}
+}
+
 func TestReadHeaderFollowedByIncorrectFrame(t *testing.T) {
 	routingMessage := []byte("{\"sender\": \"1234\", \"recipient\": \"345\", \"route_list\": [\"678\"]}")
 
@@ -377,6 +598,76 @@ func TestWritePayloadMessage(t *testing.T) {
 	verifyRoutingMessage(t, &routingMessage, readPayloadMessage.RoutingInfo)
 }
 
+func TestWriteBinaryPayloadMessage(t *testing.T) {
+	var w bytes.Buffer
+	me := "node-cloud-receptor-controller"
+	routingMessage := RoutingMessage{Sender: me,
+		Recipient: "node-b",
+		RouteList: []string{"node-b"},
+	}
+
+	innerMessage := InnerEnvelope{
+		MessageID:   "1234-123-1234",
+		Sender:      me,
+		Recipient:   "node-b",
+		MessageType: "directive",
+		Directive:   "demo:upload",
+		Timestamp:   Time{time.Now().UTC()},
+	}
+
+	// Not valid utf-8 and not valid json
+	data := []byte{0x1f, 0x8b, 0x08, 0x00, 0xff, 0xfe, '"', '{', 0x00, '\\'}
+
+	binaryMessage := NewBinaryPayloadMessage(&PayloadMessage{RoutingInfo: &routingMessage, Data: innerMessage}, data)
+
+	err := WriteMessage(&w, binaryMessage)
+	if err != nil {
+		t.Fatalf("unexpected error writing message")
+	}
+
+	readMessage, err := ReadMessage(&w)
+	if err != nil {
+		t.Fatalf("reading the binary payload message failed: %s\n", err)
+	}
+
+	readBinaryMessage, ok := readMessage.(*BinaryPayloadMessage)
+	if !ok || readMessage.Type() != BinaryPayloadMessageType {
+		t.Fatalf("expected a binary payload message, got %T", readMessage)
+	}
+
+	if !bytes.Equal(readBinaryMessage.Payload, data) {
+		t.Fatalf("binary payloads are unequal, expected: %v got: %v", data, readBinaryMessage.Payload)
+	}
+
+	if readBinaryMessage.Data.PayloadEncoding != BinaryPayloadEncoding || readBinaryMessage.Data.RawPayload != nil {
+		t.Fatalf("expected the envelope to announce the binary payload without carrying it, got %+v", readBinaryMessage.Data)
+	}
+
+	if readBinaryMessage.Data.MessageID != innerMessage.MessageID || readBinaryMessage.Data.Directive != innerMessage.Directive {
+		t.Fatalf("inner messages are unequal, expected: %+v got: %+v", innerMessage, readBinaryMessage.Data)
+	}
+
+	verifyRoutingMessage(t, &routingMessage, readBinaryMessage.RoutingInfo)
+}
+
+func TestReadBinaryPayloadMessageWithoutBinaryFrame(t *testing.T) {
+	var w bytes.Buffer
+
+	payloadMessage := PayloadMessage{RoutingInfo: &RoutingMessage{Recipient: "node-b"},
+		Data: InnerEnvelope{MessageID: "1234-123-1234", PayloadEncoding: BinaryPayloadEncoding, Timestamp: Time{time.Now().UTC()}}}
+	if err := WriteMessage(&w, &payloadMessage); err != nil {
+		t.Fatalf("unexpected error writing message")
+	}
+
+	if err := WriteMessage(&w, &HiMessage{Command: "HI", ID: "node-b"}); err != nil {
+		t.Fatalf("unexpected error writing message")
+	}
+
+	if _, err := ReadMessage(&w); err != errInvalidMessage {
+		t.Fatalf("expected %v, got %v", errInvalidMessage, err)
+	}
+}
+
 func verifyRoutingMessage(t *testing.T, expected *RoutingMessage, actual *RoutingMessage) {
 	if expected.Recipient != actual.Recipient {
 		t.Fatalf("routing messages are not equal, expected Recipient: %s, got: %s\n",